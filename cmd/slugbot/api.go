@@ -0,0 +1,96 @@
+//go:build !noapi
+
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net"
+	"time"
+
+	"slugbot/internal/api"
+	"slugbot/internal/api/controlpb"
+	"slugbot/internal/config"
+	"slugbot/internal/features"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/secrets"
+
+	"github.com/bwmarrin/discordgo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// startAPIServer serves the gRPC control API if [api] is configured. It
+// returns nil if the API is off or couldn't start.
+func startAPIServer(session *discordgo.Session) stopper {
+	cfg := config.Get().API
+	if cfg.Listen == "" || !features.Enabled(features.API) {
+		return nil
+	}
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		slog.Error("couldn't start the control API: ", err)
+		return nil
+	}
+
+	unary, stream := api.BearerAuth(func(token string) (api.Caller, bool) {
+		service, err := secrets.Get(secrets.APIToken)
+		if err != nil && !errors.Is(err, secrets.ErrNotFound) {
+			slog.Warn("API token unavailable: ", err)
+		}
+		if service != "" && subtle.ConstantTimeCompare([]byte(token), []byte(service)) == 1 {
+			return api.Caller{}, true
+		}
+		owner, err := apiTokens.Validate(token, time.Now())
+		if err != nil {
+			return api.Caller{}, false
+		}
+		return api.Caller{UserID: owner.UserID, Username: owner.Username}, true
+	})
+	server := grpc.NewServer(grpc.UnaryInterceptor(unary), grpc.StreamInterceptor(stream))
+	controlpb.RegisterControlServer(server, &api.Server{
+		Queue:    &audioQueue,
+		Channels: cfg.Channels,
+		Commands: recurringCommands,
+		Submit: func(caller api.Caller, spec *controlpb.JobSpec) (*controlpb.SubmitJobResponse, error) {
+			source := spec.GetSource()
+			if source == "" {
+				source = "the API"
+			}
+			var user *discordgo.User
+			if caller.UserID != "" {
+				user = &discordgo.User{ID: caller.UserID, Username: caller.Username}
+			}
+			messageID, jobIDs, err := submitJob(session, spec.GetChannelId(), spec.GetCommand(), source, user)
+			if err != nil {
+				return nil, err
+			}
+			return &controlpb.SubmitJobResponse{MessageId: messageID, JobIds: jobIDs}, nil
+		},
+	})
+
+	healthpb.RegisterHealthServer(server, featureHealth())
+
+	go func() {
+		slog.Info("serving the control API on ", cfg.Listen)
+		if err := server.Serve(listener); err != nil {
+			slog.Error("control API stopped: ", err)
+		}
+	}()
+	return server
+}
+
+// featureHealth reports the bot as serving, and each feature as serving or
+// not by whether it's enabled, under service names like "slugbot.audio".
+func featureHealth() *health.Server {
+	checks := health.NewServer()
+	for _, f := range features.All {
+		status := healthpb.HealthCheckResponse_NOT_SERVING
+		if features.Enabled(f) {
+			status = healthpb.HealthCheckResponse_SERVING
+		}
+		checks.SetServingStatus("slugbot."+string(f), status)
+	}
+	return checks
+}
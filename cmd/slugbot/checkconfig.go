@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/backend"
+	"slugbot/internal/config"
+	"slugbot/internal/discord"
+	jobs "slugbot/internal/exec"
+	"slugbot/internal/features"
+	"slugbot/internal/results"
+	"slugbot/internal/secrets"
+	"slugbot/internal/store"
+	"slugbot/internal/tools"
+)
+
+const checkConfigUsage = `Usage: slugbot check-config <file>
+
+Checks a config file before the bot goes live: that it parses and has no unknown
+keys, that the programs and models it names exist, that its listen addresses
+are free, and that the Discord token and optional backends it uses answer.`
+
+// checkTimeout bounds each connection check.
+const checkTimeout = 5 * time.Second
+
+// configChecker prints one line per check and remembers whether any failed.
+type configChecker struct {
+	out    io.Writer
+	failed int
+}
+
+func (c *configChecker) report(name string, err error) {
+	if err != nil {
+		c.failed++
+		fmt.Fprintf(c.out, "FAIL  %s: %v\n", name, err)
+		return
+	}
+	fmt.Fprintf(c.out, "ok    %s\n", name)
+}
+
+// runCheckConfig checks a config file and the deployment it describes without
+// connecting to the gateway, and returns the process exit code.
+func runCheckConfig(args []string, stdout io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(stdout, checkConfigUsage)
+		return 2
+	}
+
+	cfg, err := config.LoadStrict(args[0])
+	if err != nil {
+		fmt.Fprintln(stdout, "FAIL ", err)
+		return 1
+	}
+	config.Set(cfg)
+	c := &configChecker{out: stdout}
+	c.report("config "+args[0], nil)
+
+	c.checkValues(cfg)
+	c.checkTools(cfg)
+	c.checkModels(cfg)
+	c.checkListeners(cfg)
+	c.checkBackends(cfg)
+
+	if c.failed > 0 {
+		fmt.Fprintf(stdout, "%d check(s) failed\n", c.failed)
+		return 1
+	}
+	fmt.Fprintln(stdout, "all checks passed")
+	return 0
+}
+
+// checkValues checks the settings the bot would otherwise only reject once
+// they're used.
+func (c *configChecker) checkValues(cfg *config.Config) {
+	if cfg.Persona.Color != "" {
+		_, err := discord.ParseColor(cfg.Persona.Color)
+		c.report("persona.color", err)
+	}
+	if cfg.Results.Template != "" {
+		c.report("results.template", results.Check(cfg.Results.Template))
+	}
+	if operation := strings.Fields(cfg.Interactions.Avatar); features.Enabled(features.Image) && (len(operation) == 0 || simCommandHandlers[operation[0]] == nil) {
+		c.report("interactions.avatar", fmt.Errorf("`%s` isn't a .sim operation", cfg.Interactions.Avatar))
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		c.report("tracing.sample_ratio", fmt.Errorf("%v isn't between 0 and 1", cfg.Tracing.SampleRatio))
+	}
+	switch cfg.QueueView.Placement {
+	case jobs.PlaceChannels, jobs.PlaceBusiest:
+	case jobs.PlaceThread:
+		if len(cfg.QueueView.Channels) == 0 {
+			c.report("queue_view.channels", errors.New(`"thread" placement needs channels to start its threads in`))
+		}
+	default:
+		c.report("queue_view.placement", fmt.Errorf(`%q isn't "channels", "busiest", or "thread"`, cfg.QueueView.Placement))
+	}
+	if cfg.Attribution.Watermark && cfg.Attribution.KeyFile != "" {
+		_, err := os.Stat(cfg.Attribution.KeyFile)
+		c.report("attribution.key_file", err)
+	}
+}
+
+// checkTools checks that the programs the enabled features run can be found.
+func (c *configChecker) checkTools(cfg *config.Config) {
+	var needed []string
+	if features.Enabled(features.Audio) {
+		needed = append(needed, tools.Sag, tools.Python, tools.FFmpeg)
+	}
+	if features.Enabled(features.Image) {
+		needed = append(needed, tools.Magick, tools.QREncode)
+		if !features.Enabled(features.Audio) {
+			needed = append(needed, tools.FFmpeg)
+		}
+	}
+	for _, tool := range needed {
+		c.report("tools."+tool, findProgram(tools.Path(tool)))
+	}
+	if cfg.Attribution.Watermark {
+		c.report("attribution.audiowmark", findProgram(tools.Resolve(cfg.Attribution.Audiowmark)))
+	}
+	if features.Enabled(features.Dashboard) && cfg.Dashboard.NvidiaSMI != "" {
+		c.report("dashboard.nvidia_smi", findProgram(tools.Resolve(cfg.Dashboard.NvidiaSMI)))
+	}
+}
+
+// findProgram checks that a resolved program exists and can be run.
+func findProgram(path string) error {
+	if _, err := exec.LookPath(path); err != nil {
+		return fmt.Errorf("`%s` not found", path)
+	}
+	return nil
+}
+
+// checkModels checks that the store opens, and that the active checkpoint and
+// any checkpoints .scompare uses are installed.
+func (c *configChecker) checkModels(cfg *config.Config) {
+	dataStore, err := store.Open(cfg.Store.Dir)
+	c.report("store.dir "+cfg.Store.Dir, err)
+	if err != nil || !features.Enabled(features.Audio) {
+		return
+	}
+
+	models := &backend.Models{Dir: audioModels.Dir, Store: dataStore}
+	if err := models.Load(); err != nil {
+		c.report("active model", err)
+	} else if active := models.Active(); active != "" {
+		c.report("active model "+active, models.Validate(active))
+	}
+	for _, name := range cfg.Compare.Models {
+		if name != "small" && name != "full" {
+			c.report("compare.models "+name, models.Validate(name))
+		}
+	}
+}
+
+// checkListeners checks that the enabled servers' listen addresses can be
+// bound, which fails if they're malformed or something else already has them.
+func (c *configChecker) checkListeners(cfg *config.Config) {
+	listeners := []struct {
+		name    string
+		addr    string
+		enabled bool
+	}{
+		{"api.listen", cfg.API.Listen, features.Enabled(features.API)},
+		{"dashboard.listen", cfg.Dashboard.Listen, features.Enabled(features.Dashboard)},
+		{"webhooks.listen", cfg.Webhooks.Listen, true},
+	}
+	for _, l := range listeners {
+		if l.addr == "" || !l.enabled {
+			continue
+		}
+		listener, err := net.Listen("tcp", l.addr)
+		if err == nil {
+			listener.Close()
+		}
+		c.report(l.name+" "+l.addr, err)
+	}
+}
+
+// checkBackends checks the Discord token against the REST API and that the
+// optional backends the config names accept connections.
+func (c *configChecker) checkBackends(cfg *config.Config) {
+	c.report("discord token", checkDiscordToken())
+
+	if cfg.LLM.Endpoint != "" {
+		c.report("llm.endpoint "+cfg.LLM.Endpoint, dialURL(cfg.LLM.Endpoint))
+	}
+	if cfg.Tracing.Endpoint != "" {
+		c.report("tracing.endpoint "+cfg.Tracing.Endpoint, dial(cfg.Tracing.Endpoint))
+	}
+}
+
+// checkDiscordToken looks up the bot's own user, which needs a valid token
+// but no gateway connection.
+func checkDiscordToken() error {
+	token, err := secrets.Get(secrets.DiscordToken)
+	if err != nil {
+		return err
+	}
+	dg, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return err
+	}
+	dg.Client.Timeout = checkTimeout
+	_, err = dg.User("@me")
+	return err
+}
+
+// dialURL dials the host of an HTTP(S) URL, on its scheme's port if it names none.
+func dialURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	if u.Hostname() == "" || port == "" {
+		return fmt.Errorf("`%s` isn't an http or https URL", rawURL)
+	}
+	return dial(net.JoinHostPort(u.Hostname(), port))
+}
+
+// dial opens and closes a TCP connection to addr.
+func dial(addr string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
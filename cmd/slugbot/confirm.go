@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/commands/audio"
+	"slugbot/internal/config"
+	"slugbot/internal/discord"
+	"slugbot/internal/exec"
+	"slugbot/internal/format"
+)
+
+// pendingConfirm is a long `.saudio` job waiting for its author to check its parameters.
+type pendingConfirm struct {
+	message *discordgo.MessageCreate
+	command *audio.StableAudioCommand
+	summary string
+}
+
+var pendingConfirms = newPendingConfirmations[pendingConfirm]("This job has expired; send the command again to start over.")
+
+// needsConfirmation reports whether a job is expected to take long enough on
+// the GPU that its author should check its parameters before it's queued.
+func needsConfirmation(command *audio.StableAudioCommand) bool {
+	above := config.Get().Confirm.Above
+	return above > 0 && jobGPUTime(command) > above
+}
+
+// editNeedsConfirmation reports whether an edit to the message of a waiting
+// `.saudio` job would make the job take longer, and long enough that it would
+// have been asked about. Such an edit isn't applied, since nobody confirms it.
+func editNeedsConfirmation(session *discordgo.Session, edited *discordgo.Message) (time.Duration, bool) {
+	parts := strings.Fields(edited.Content)
+	if len(parts) == 0 || (parts[0] != ".saudio" && parts[0] != ".saudiosm") {
+		return 0, false
+	}
+	var current exec.Task
+	for _, info := range audioQueue.Jobs() {
+		if triggered, ok := info.Task.(exec.Triggered); ok && info.State == exec.StateWaiting && triggered.MessageID() == edited.ID {
+			current = info.Task
+			break
+		}
+	}
+	if current == nil {
+		return 0, false
+	}
+	command := &audio.StableAudioCommand{}
+	command.SetContext(session, &discordgo.MessageCreate{Message: edited})
+	gpuTime := jobGPUTime(command)
+	return gpuTime, needsConfirmation(command) && gpuTime > jobGPUTime(current)
+}
+
+// askToConfirm replies with what a job will generate, and queues it once its
+// author confirms. A job whose flags don't parse is queued as it is, so it
+// fails with the usual explanation.
+func askToConfirm(session *discordgo.Session, message *discordgo.MessageCreate, command *audio.StableAudioCommand) error {
+	summary, err := command.Summary()
+	if err != nil {
+		queueSaudio(session, message, command)
+		return nil
+	}
+
+	token := pendingConfirms.add(pendingConfirm{message: message, command: command, summary: summary}, config.Get().Confirm.Timeout)
+
+	command.Log().Info("asking for confirmation: ", summary)
+	_, err = session.ChannelMessageSendComplex(message.ChannelID, &discordgo.MessageSend{
+		Content:   "This will take about " + format.Duration(jobGPUTime(command)) + ":\n" + summary,
+		Reference: message.Reference(),
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "Queue it", Emoji: &discordgo.ComponentEmoji{Name: "✅"}, Style: discordgo.SuccessButton, CustomID: discord.ComponentID("confirm-ok", token)},
+				discordgo.Button{Label: "Cancel", Emoji: &discordgo.ComponentEmoji{Name: "❌"}, Style: discordgo.SecondaryButton, CustomID: discord.ComponentID("confirm-cancel", token)},
+			}},
+		},
+		// the prompt shouldn't ping anyone
+		AllowedMentions: &discordgo.MessageAllowedMentions{RepliedUser: true},
+	})
+	return err
+}
+
+func registerConfirmComponents(router *discord.ComponentRouter) {
+	router.Handle("confirm-ok", func(s *discordgo.Session, i *discordgo.InteractionCreate, token string) error {
+		pending, ok := takePendingConfirm(s, i, token)
+		if !ok {
+			return nil
+		}
+		if err := resolveConfirmation(s, i, "✅ "+pending.summary); err != nil {
+			return err
+		}
+		queueSaudio(s, pending.message, pending.command)
+		return nil
+	})
+
+	router.Handle("confirm-cancel", func(s *discordgo.Session, i *discordgo.InteractionCreate, token string) error {
+		pending, ok := takePendingConfirm(s, i, token)
+		if !ok {
+			return nil
+		}
+		return resolveConfirmation(s, i, "❌ Cancelled: "+pending.summary+"\nFix the flags and send it again.")
+	})
+}
+
+// takePendingConfirm removes and returns the pending job if the clicking user is its author.
+// Otherwise it tells the clicker why nothing happened.
+func takePendingConfirm(s *discordgo.Session, i *discordgo.InteractionCreate, token string) (pendingConfirm, bool) {
+	return pendingConfirms.take(s, i, token, func(pending pendingConfirm, userID string) bool {
+		return userID == pending.message.Author.ID
+	}, onlyAskerText)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/config"
+	"slugbot/internal/discord"
+	"slugbot/internal/helpers"
+	"slugbot/internal/io/slog"
+)
+
+// messageCommand is a context-menu command, shown under Apps when
+// right-clicking a message, that runs on the message's files.
+type messageCommand struct {
+	prefix string // the top-level command it runs, which has to be enabled for it to be offered
+	run    func(s *discordgo.Session, i *discordgo.InteractionCreate, target *discordgo.Message) error
+}
+
+// messageCommands are the context-menu commands by name; Discord allows an
+// application five.
+var messageCommands = map[string]messageCommand{
+	"Limit audio":        {".slimit", runOnTarget(".slimit")},
+	"Generate variation": {".saudio", askForVariation},
+	"Polar distort":      {".sim", runOnTarget(".sim polar 0")},
+	"Distort image":      {".sim", openSimPicker},
+	"Delete result":      {".sdelete", deleteTarget},
+}
+
+// distortAvatarCommand is the user context-menu command, shown under Apps
+// when right-clicking a user, which runs the configured `.sim` operation on
+// their avatar.
+const distortAvatarCommand = "Distort avatar"
+
+// handleMessageCommand runs a context-menu command on the message it was used on.
+func handleMessageCommand(s *discordgo.Session, i *discordgo.InteractionCreate, data discordgo.ApplicationCommandInteractionData) error {
+	command, ok := messageCommands[data.Name]
+	if !ok || !commandEnabled(command.prefix) {
+		return discord.RespondEphemeral(s, i, "That command isn't available anymore.")
+	}
+	var target *discordgo.Message
+	if data.Resolved != nil {
+		target = data.Resolved.Messages[data.TargetID]
+	}
+	if target == nil {
+		return discord.RespondEphemeral(s, i, "Couldn't find the message to run that on.")
+	}
+	// the interaction can come from a channel the target was resolved without
+	if target.ChannelID == "" {
+		target.ChannelID = i.ChannelID
+	}
+	return command.run(s, i, target)
+}
+
+// runOnTarget runs a command on the target message's files. They're carried
+// over to the command's trigger because, without the message content intent,
+// they're only visible in the interaction.
+func runOnTarget(content string) func(*discordgo.Session, *discordgo.InteractionCreate, *discordgo.Message) error {
+	return func(s *discordgo.Session, i *discordgo.InteractionCreate, target *discordgo.Message) error {
+		shown := "`" + content + "` on " + messageLink(i.GuildID, target.ChannelID, target.ID)
+		return runInteraction(s, i, shown, &discordgo.Message{Content: content, Attachments: target.Attachments, Embeds: target.Embeds})
+	}
+}
+
+// openSimPicker offers the `.sim` operations to run on the target's image.
+func openSimPicker(s *discordgo.Session, i *discordgo.InteractionCreate, target *discordgo.Message) error {
+	if len(helpers.MessageImages(target)) == 0 {
+		return discord.RespondEphemeral(s, i, "That message has no image to distort.")
+	}
+	interactionTargets.put(target)
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:    "Pick an image operation to run on " + messageLink(i.GuildID, target.ChannelID, target.ID) + ":",
+			Components: simPickerComponents(target.ID),
+		},
+	})
+}
+
+// deleteTarget runs `.sdelete` as a reply to the target.
+func deleteTarget(s *discordgo.Session, i *discordgo.InteractionCreate, target *discordgo.Message) error {
+	shown := "`.sdelete` on " + messageLink(i.GuildID, target.ChannelID, target.ID)
+	return runInteraction(s, i, shown, &discordgo.Message{Content: ".sdelete",
+		MessageReference: &discordgo.MessageReference{MessageID: target.ID, ChannelID: target.ChannelID}})
+}
+
+// askForVariation asks for the prompt of a generation that starts from the
+// target's audio, filled in with the prompt that made it if it's one of the
+// bot's results.
+func askForVariation(s *discordgo.Session, i *discordgo.InteractionCreate, target *discordgo.Message) error {
+	if len(helpers.MessageAudio(target)) == 0 {
+		return discord.RespondEphemeral(s, i, "That message has no WAV file to make a variation of.")
+	}
+	interactionTargets.put(target)
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: discord.ComponentID("variation", target.ID),
+			Title:    "Generate variation",
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID:    "prompt",
+						Label:       "Prompt, with any .saudio flags",
+						Style:       discordgo.TextInputShort,
+						Placeholder: "rainy jazz --strength 5",
+						Value:       originalPrompt(s, target),
+						Required:    true,
+						MaxLength:   400,
+					},
+				}},
+			},
+		},
+	})
+}
+
+// originalPrompt returns the arguments of the `.saudio` command a result
+// replies to, or "" if it isn't one or they can't be read.
+func originalPrompt(s *discordgo.Session, result *discordgo.Message) string {
+	if result.MessageReference == nil || result.MessageReference.MessageID == "" {
+		return ""
+	}
+	trigger, err := s.ChannelMessage(result.ChannelID, result.MessageReference.MessageID)
+	if err != nil {
+		slog.Debug("couldn't fetch the trigger of a result: ", err)
+		return ""
+	}
+	prefix, args, _ := strings.Cut(trigger.Content, " ")
+	if prefix != ".saudio" {
+		return ""
+	}
+	return strings.TrimSpace(args)
+}
+
+// distortAvatar runs the configured `.sim` operation on the target user's
+// avatar, as their server profile shows it.
+func distortAvatar(s *discordgo.Session, i *discordgo.InteractionCreate, data discordgo.ApplicationCommandInteractionData) error {
+	operation := strings.TrimSpace(config.Get().Interactions.Avatar)
+	if _, ok := simCommandHandlers[strings.SplitN(operation, " ", 2)[0]]; !ok || !commandEnabled(".sim") {
+		return discord.RespondEphemeral(s, i, "Avatar distortion isn't available.")
+	}
+	var user *discordgo.User
+	if data.Resolved != nil {
+		user = data.Resolved.Users[data.TargetID]
+	}
+	if user == nil {
+		return discord.RespondEphemeral(s, i, "Couldn't find that user.")
+	}
+
+	avatarURL := user.AvatarURL("1024")
+	if member := data.Resolved.Members[data.TargetID]; member != nil && member.Avatar != "" {
+		member.User, member.GuildID = user, i.GuildID
+		avatarURL = member.AvatarURL("1024")
+	}
+	name := "avatar" + path.Ext(strings.SplitN(avatarURL, "?", 2)[0])
+
+	content := ".sim " + operation
+	shown := "`" + content + "` on " + user.Mention() + "'s avatar"
+	return runInteraction(s, i, shown, &discordgo.Message{Content: content,
+		Attachments: []*discordgo.MessageAttachment{{ID: data.TargetID, Filename: name, URL: avatarURL}}})
+}
+
+func registerContextMenuComponents(router *discord.ComponentRouter) {
+	// submitting a variation's prompt generates from the target's audio
+	router.Handle("variation", func(s *discordgo.Session, i *discordgo.InteractionCreate, targetID string) error {
+		target := interactionTargets.get(targetID)
+		if target == nil {
+			return discord.RespondEphemeral(s, i, "This request has expired; use Generate variation on the message again.")
+		}
+		content := ".saudio " + strings.TrimSpace(modalValue(i.ModalSubmitData(), "prompt"))
+		shown := "`" + content + "` from " + messageLink(i.GuildID, target.ChannelID, target.ID)
+		return runInteraction(s, i, shown, &discordgo.Message{Content: content, Attachments: target.Attachments})
+	})
+}
+
+// interactionTargetTTL is how long a context-menu command's form or menu
+// keeps its message; it's as long as Discord lets the bot follow up on an
+// interaction.
+const interactionTargetTTL = 15 * time.Minute
+
+// interactionTargets remembers the messages a context-menu command was used
+// on while it asks for more: without the message content intent, a message's
+// attachments are only visible in the interaction itself.
+var interactionTargets = &targetCache{messages: map[string]interactionTarget{}}
+
+type interactionTarget struct {
+	message *discordgo.Message
+	added   time.Time
+}
+
+type targetCache struct {
+	mutex    sync.Mutex
+	messages map[string]interactionTarget
+}
+
+func (c *targetCache) put(message *discordgo.Message) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for id, target := range c.messages {
+		if time.Since(target.added) > interactionTargetTTL {
+			delete(c.messages, id)
+		}
+	}
+	c.messages[message.ID] = interactionTarget{message: message, added: time.Now()}
+}
+
+// get returns a remembered message, or nil if it's unknown or expired.
+func (c *targetCache) get(messageID string) *discordgo.Message {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	target, ok := c.messages[messageID]
+	if !ok || time.Since(target.added) > interactionTargetTTL {
+		return nil
+	}
+	return target.message
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"slices"
+	"time"
+
+	"slugbot/internal/config"
+	"slugbot/internal/credits"
+	"slugbot/internal/exec"
+	"slugbot/internal/io/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// finishQueuedJob records a finished job in the usage analytics, charges for
+// it, reacts to its trigger, and notifies its submitter.
+func finishQueuedJob(task exec.Task, err error) {
+	recordQueuedJob(task, err)
+	chargeCredits(task, err)
+	reactFinished(task, err)
+	notifyFinished(task, err)
+}
+
+// admitCredits refuses jobs their submitter can't afford, counting the jobs
+// they already have waiting, which haven't been charged yet.
+func admitCredits(tasks []exec.Task, queued []exec.TaskInfo) error {
+	if creditLedger == nil || len(tasks) == 0 {
+		return nil
+	}
+	userID := taskOwner(tasks[0])
+	if creditExempt(userID) {
+		return nil
+	}
+
+	var cost int64
+	for _, task := range tasks {
+		cost += jobCost(task)
+	}
+	if cost == 0 {
+		return nil
+	}
+	for _, info := range queued {
+		if taskOwner(info.Task) == userID {
+			cost += jobCost(info.Task)
+		}
+	}
+	return creditLedger.Check(userID, cost)
+}
+
+// chargeCredits charges the submitter of a job that succeeded. Failed and
+// cancelled jobs are free.
+func chargeCredits(task exec.Task, err error) {
+	if creditLedger == nil || err != nil {
+		return
+	}
+	userID := taskOwner(task)
+	cost := jobCost(task)
+	if creditExempt(userID) || cost == 0 {
+		return
+	}
+	balance, err := creditLedger.Charge(userID, cost, time.Now())
+	if err != nil {
+		slog.Error("couldn't charge user ", userID, " ", cost, " credits: ", err)
+		return
+	}
+	slog.With("trace", task.TraceID()).Info("charged user ", userID, " ", cost, " credits; ", balance, " left")
+}
+
+// jobCost prices a job by its estimated GPU time. Jobs that don't use the GPU are free.
+func jobCost(task exec.Task) int64 {
+	gpuTime := jobGPUTime(task)
+	if gpuTime == 0 {
+		return 0
+	}
+	return credits.Cost(gpuTime, config.Get().Credits.PerGPUMinute)
+}
+
+// jobGPUTime estimates how long a job will use the GPU, assuming the
+// configured unknown_job for shapes with no history yet. It's 0 for jobs that
+// don't use the GPU.
+func jobGPUTime(task exec.Task) time.Duration {
+	estimable, ok := task.(exec.Estimable)
+	if !ok {
+		return 0
+	}
+	if _, ok := estimable.Shape(); !ok {
+		return 0
+	}
+	gpuTime, ok := audioQueue.Estimate(task)
+	if !ok {
+		gpuTime = config.Get().Credits.UnknownJob
+	}
+	return gpuTime
+}
+
+// creditExempt reports whether a user's jobs are free: admins listed in the
+// config, and jobs submitted with the shared webhook or API secrets.
+func creditExempt(userID string) bool {
+	return userID == "" || userID == webhookUserID || slices.Contains(config.Get().Admin.Users, userID)
+}
+
+// jobUncapped reports whether a job's submitter may have more jobs queued
+// than the per-user limits allow: anyone whose jobs are free, except for the
+// shared webhook user, who has a limit of its own, and members of the
+// configured uncapped roles.
+func jobUncapped(task exec.Task) bool {
+	if owner := taskOwner(task); owner != webhookUserID && creditExempt(owner) {
+		return true
+	}
+	triggered, ok := task.(interface {
+		TriggerMessage() *discordgo.MessageCreate
+	})
+	if !ok || triggered.TriggerMessage() == nil || triggered.TriggerMessage().Member == nil {
+		return false
+	}
+	roles := config.Get().Queue.UncappedRoles
+	return slices.ContainsFunc(triggered.TriggerMessage().Member.Roles, func(role string) bool {
+		return slices.Contains(roles, role)
+	})
+}
+
+// ownerMaxWaiting gives the shared webhook user its own limit on waiting jobs.
+func ownerMaxWaiting(owner string) (int, bool) {
+	if owner == webhookUserID {
+		return config.Get().Queue.MaxWebhookWaiting, true
+	}
+	return 0, false
+}
+
+// taskOwner returns the ID of the user who submitted a job.
+func taskOwner(task exec.Task) string {
+	triggered, ok := task.(interface {
+		TriggerMessage() *discordgo.MessageCreate
+	})
+	if !ok || triggered.TriggerMessage() == nil || triggered.TriggerMessage().Author == nil {
+		return ""
+	}
+	return triggered.TriggerMessage().Author.ID
+}
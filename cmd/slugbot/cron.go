@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"slugbot/internal/io/slog"
+	"slugbot/internal/recurring"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// runRecurringJob posts a header for one run of a recurring job, then runs its
+// command in reply to the header as if the job's creator had typed it.
+func runRecurringJob(session *discordgo.Session, job recurring.Job) {
+	header, err := session.ChannelMessageSend(job.ChannelID, fmt.Sprintf("Recurring job `%s`: `%s`", job.Name, job.Command))
+	if err != nil {
+		slog.Error("couldn't start recurring job ", job.Name, ": ", err)
+		return
+	}
+	dispatchUnattended(session, &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        header.ID,
+		ChannelID: job.ChannelID,
+		GuildID:   job.GuildID,
+		Author:    &discordgo.User{ID: job.CreatedBy},
+		Content:   job.Command,
+	}})
+}
+
+// recurringCommands are the top-level commands a recurring job may run. It's
+// filled in at startup, since topCommandHandlers indirectly refers to it.
+var recurringCommands []string
+
+// allowedRecurringCommands lists every enabled command but the admin ones,
+// token management, deletion, and code blocks.
+func allowedRecurringCommands() []string {
+	var allowed []string
+	for name := range topCommandHandlers {
+		if name != ".sadmin" && name != ".stoken" && name != ".sdelete" && name != ".sforgetme" && !strings.HasPrefix(name, "```") && commandEnabled(name) {
+			allowed = append(allowed, name)
+		}
+	}
+	slices.Sort(allowed)
+	return allowed
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/config"
+	"slugbot/internal/discord"
+	"slugbot/internal/event"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/promptspec"
+	"slugbot/internal/schedule"
+	"slugbot/internal/store"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// voteEmoji are the reactions a recap's first entries are voted on with.
+var voteEmoji = []string{"1️⃣", "2️⃣", "3️⃣", "4️⃣", "5️⃣", "6️⃣", "7️⃣", "8️⃣", "9️⃣", "🔟"}
+
+// linkTextReplacer keeps a prompt from closing the markdown link it's shown in.
+var linkTextReplacer = strings.NewReplacer("[", "(", "]", ")")
+
+// recapMaxChars keeps a recap within an embed's 4096-character description.
+const recapMaxChars = 3900
+
+// startPromptOfTheDay schedules the daily theme and the recap of each round,
+// and recaps any rounds that closed while the bot was down.
+func startPromptOfTheDay(session *discordgo.Session) error {
+	cfg := config.Get().PromptOfDay
+	if cfg.Channel == "" {
+		return nil
+	}
+	if len(cfg.Themes) == 0 {
+		return errors.New("[prompt_of_the_day] needs at least one theme")
+	}
+	cron, err := schedule.ParseCron(cfg.Schedule)
+	if err != nil {
+		return err
+	}
+	everyMinute, _ := schedule.ParseCron("* * * * *")
+
+	scheduler.Add("prompt-of-the-day", cron, func(at time.Time) {
+		if err := openDailyPrompt(session, at); err != nil {
+			slog.Error("couldn't post prompt of the day: ", err)
+		}
+	})
+	scheduler.Add("prompt-of-the-day-recap", everyMinute, func(at time.Time) {
+		closeDueEvents(session, at)
+	})
+	closeDueEvents(session, time.Now())
+	return nil
+}
+
+// openDailyPrompt announces a random theme and opens it for entries.
+func openDailyPrompt(session *discordgo.Session, at time.Time) error {
+	cfg := config.Get().PromptOfDay
+	theme := cfg.Themes[rand.IntN(len(cfg.Themes))]
+	closes := at.Add(cfg.Duration)
+
+	announcement, err := session.ChannelMessageSend(cfg.Channel, fmt.Sprintf(
+		"**Prompt of the day:** %s\nReply to this message with `.saudio <your take on it>` to enter. Entries close <t:%d:R>, then there's a recap and a vote.",
+		theme, closes.Unix()))
+	if err != nil {
+		return err
+	}
+
+	guildID := announcement.GuildID
+	if channel, err := commands.LookupChannel(session, cfg.Channel); err == nil {
+		guildID = channel.GuildID
+	}
+	slog.Info("opened prompt of the day ", announcement.ID, ": ", theme)
+	return dailyEvents.Open(event.Event{
+		ID:        announcement.ID,
+		GuildID:   guildID,
+		ChannelID: cfg.Channel,
+		Theme:     theme,
+		Opened:    at,
+		Closes:    closes,
+	})
+}
+
+// enterDailyPrompt tags a queued `.saudio` as an entry to the prompt of the
+// day it replies to, if any.
+func enterDailyPrompt(session *discordgo.Session, message *discordgo.MessageCreate) {
+	if message.MessageReference == nil || dailyEvents.Store == nil {
+		return
+	}
+
+	prompt := ""
+	if parts := strings.Fields(message.Content); len(parts) > 1 {
+		if params, err := promptspec.ParseArgs(parts[1:]); err == nil {
+			prompt = params.Prompt
+		}
+	}
+	err := dailyEvents.AddEntry(message.MessageReference.MessageID, event.Entry{
+		UserID:    message.Author.ID,
+		MessageID: message.ID,
+		Prompt:    prompt,
+		At:        time.Now(),
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		// an ordinary reply
+	case errors.Is(err, event.ErrClosed):
+		session.ChannelMessageSendReply(message.ChannelID, "That prompt of the day has closed, so this isn't entered, but it'll still be generated.", message.Reference())
+	case err != nil:
+		slog.Warn("couldn't enter prompt of the day: ", err)
+	default:
+		session.MessageReactionAdd(message.ChannelID, message.ID, "🎟️")
+	}
+}
+
+// closeDueEvents posts the recap of every round whose entries have closed.
+func closeDueEvents(session *discordgo.Session, now time.Time) {
+	if dailyEvents.Store == nil {
+		return
+	}
+	due, err := dailyEvents.Due(now)
+	if err != nil {
+		slog.Error("couldn't check prompt of the day: ", err)
+		return
+	}
+	for _, e := range due {
+		e, err := dailyEvents.Close(e.ID)
+		if err != nil {
+			slog.Error("couldn't close prompt of the day ", e.ID, ": ", err)
+			continue
+		}
+		if err := postRecap(session, e); err != nil {
+			slog.Error("couldn't post prompt of the day recap: ", err)
+		}
+	}
+}
+
+// postRecap lists a round's entries in reply to its announcement, with a vote
+// reaction for each of the first ten.
+func postRecap(session *discordgo.Session, e event.Event) error {
+	reference := &discordgo.MessageReference{MessageID: e.ID, ChannelID: e.ChannelID, FailIfNotExists: new(bool)}
+	if len(e.Entries) == 0 {
+		_, err := session.ChannelMessageSendComplex(e.ChannelID, &discordgo.MessageSend{
+			Content:   fmt.Sprintf("Nobody entered **%s**. Maybe next time!", e.Theme),
+			Reference: reference,
+		})
+		return err
+	}
+
+	guild := e.GuildID
+	if guild == "" {
+		guild = "@me"
+	}
+	var lines []string
+	size := 0
+	for i, entry := range e.Entries {
+		marker := "•"
+		if i < len(voteEmoji) {
+			marker = voteEmoji[i]
+		}
+		prompt := entry.Prompt
+		if prompt == "" {
+			prompt = "entry"
+		}
+		line := fmt.Sprintf("%s <@%s>: [%s](https://discord.com/channels/%s/%s/%s)",
+			marker, entry.UserID, truncateRunes(linkTextReplacer.Replace(prompt), 80), guild, e.ChannelID, entry.MessageID)
+		if size+len(line) > recapMaxChars {
+			lines = append(lines, fmt.Sprintf("…and %d more", len(e.Entries)-i))
+			break
+		}
+		lines = append(lines, line)
+		size += len(line) + 1
+	}
+
+	embeds := []*discordgo.MessageEmbed{{
+		Title:       "Prompt of the day: " + e.Theme,
+		Description: strings.Join(lines, "\n"),
+		Footer:      &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("%d entries", len(e.Entries))},
+	}}
+	discord.Brand(e.ChannelID, embeds)
+	recap, err := session.ChannelMessageSendComplex(e.ChannelID, &discordgo.MessageSend{
+		Content:   fmt.Sprintf("Entries for **%s** are closed! Vote for your favorite with the reactions below.", e.Theme),
+		Embeds:    embeds,
+		Reference: reference,
+	})
+	if err != nil {
+		return err
+	}
+	for i := range min(len(e.Entries), len(voteEmoji)) {
+		if err := session.MessageReactionAdd(e.ChannelID, recap.ID, voteEmoji[i]); err != nil {
+			slog.Warn("couldn't add vote reaction: ", err)
+		}
+	}
+	return nil
+}
+
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
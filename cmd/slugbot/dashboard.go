@@ -0,0 +1,121 @@
+//go:build !nodashboard
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"slugbot/internal/config"
+	"slugbot/internal/dashboard"
+	"slugbot/internal/exec"
+	"slugbot/internal/features"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/secrets"
+	"slugbot/internal/tools"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// startDashboard serves the web dashboard if [dashboard] is configured. It
+// returns nil if the dashboard is off.
+func startDashboard(session *discordgo.Session) *http.Server {
+	cfg := config.Get().Dashboard
+	if cfg.Listen == "" || !features.Enabled(features.Dashboard) {
+		return nil
+	}
+
+	server := &dashboard.Server{
+		Queue: &audioQueue,
+		OAuth: &dashboard.OAuth{
+			ClientID: cfg.ClientID,
+			ClientSecret: func() string {
+				secret, err := secrets.Get(secrets.DashboardSecret)
+				if err != nil {
+					slog.Warn("dashboard client secret unavailable: ", err)
+				}
+				return secret
+			},
+			RedirectURL: cfg.RedirectURL,
+		},
+		Access:     func(userID string) dashboard.Access { return dashboardAccess(session, userID) },
+		SessionTTL: cfg.SessionTTL,
+		Paused: func(paused bool, user dashboard.User) {
+			_, _, waiting := audioQueue.Status()
+			notice := fmt.Sprintf("✅ Maintenance is over; resuming %d queued job(s).", waiting)
+			if paused {
+				notice = fmt.Sprintf("🔧 Maintenance: new generations are being held (%d queued) and will run once it's over.", waiting)
+			}
+			for _, channel := range config.Get().Maintenance.Channels {
+				if _, err := session.ChannelMessageSend(channel, notice); err != nil {
+					slog.Warn("couldn't post maintenance notice in ", channel, ": ", err)
+				}
+			}
+		},
+		Cancelled: func(info exec.TaskInfo, user dashboard.User) {
+			triggered, ok := info.Task.(interface {
+				TriggerMessage() *discordgo.MessageCreate
+			})
+			if !ok || triggered.TriggerMessage() == nil {
+				return
+			}
+			message := triggered.TriggerMessage()
+			session.ChannelMessageSendReply(message.ChannelID,
+				fmt.Sprintf("Job `%s` was cancelled from the dashboard by %s.", info.ID, user.Username), message.Reference())
+		},
+	}
+	if cfg.NvidiaSMI != "" {
+		server.GPUs = dashboard.NvidiaSMI(tools.Resolve(cfg.NvidiaSMI))
+	}
+
+	httpServer := &http.Server{Addr: cfg.Listen, Handler: server.Handler(), ReadHeaderTimeout: readHeaderTimeout}
+	go func() {
+		slog.Info("serving the dashboard on ", cfg.Listen)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("dashboard stopped: ", err)
+		}
+	}()
+	return httpServer
+}
+
+// dashboardAccess mirrors a user's standing in Discord: they can see jobs from
+// every guild they share with the bot, and manage jobs in the guilds where
+// they're an administrator, the same as `.sadmin` asks of them.
+func dashboardAccess(session *discordgo.Session, userID string) dashboard.Access {
+	access := dashboard.Access{
+		Guilds:    map[string]bool{},
+		Superuser: slices.Contains(config.Get().Admin.Users, userID),
+	}
+	if session.State == nil {
+		return access
+	}
+	for _, guild := range session.State.Guilds {
+		member, err := session.State.Member(guild.ID, userID)
+		if err != nil {
+			if member, err = session.GuildMember(guild.ID, userID); err != nil {
+				continue // not in this guild
+			}
+		}
+		access.Guilds[guild.ID] = isGuildAdmin(guild, member)
+	}
+	return access
+}
+
+// isGuildAdmin reports whether a member owns the guild or holds a role with Administrator.
+func isGuildAdmin(guild *discordgo.Guild, member *discordgo.Member) bool {
+	if guild.OwnerID == member.User.ID {
+		return true
+	}
+	for _, role := range guild.Roles {
+		// every member has the @everyone role, whose ID is the guild's
+		if role.ID != guild.ID && !slices.Contains(member.Roles, role.ID) {
+			continue
+		}
+		if role.Permissions&discordgo.PermissionAdministrator != 0 {
+			return true
+		}
+	}
+	return false
+}
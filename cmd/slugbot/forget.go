@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/commands/account"
+	"slugbot/internal/commands/traits"
+	"slugbot/internal/discord"
+	"slugbot/internal/io/slog"
+)
+
+// how long a `.sforgetme` waits for confirmation before it's dropped
+const forgetConfirmTimeout = 5 * time.Minute
+
+// pendingForget is a `.sforgetme` waiting for its author to confirm it.
+type pendingForget struct {
+	message *discordgo.MessageCreate
+}
+
+var pendingForgets = newPendingConfirmations[pendingForget]("This request has expired; run `.sforgetme` again to start over.")
+
+func newForgetCommand(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) *account.ForgetCommand {
+	command := &account.ForgetCommand{Queue: &audioQueue, Tokens: apiTokens, Events: dailyEvents, Votes: resultVotes, Audit: auditLog, Ledger: creditLedger, Prefs: userPrefs}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+	return command
+}
+
+// handleDotSforgetme asks the author to confirm before anything is deleted.
+func handleDotSforgetme(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := newForgetCommand(ctx, session, message)
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, command.Usage())
+		return err
+	}
+
+	token := pendingForgets.add(pendingForget{message: message}, forgetConfirmTimeout)
+
+	_, err := session.ChannelMessageSendComplex(message.ChannelID, &discordgo.MessageSend{
+		Content:   "This deletes your job history and results, including those not yet delivered or published, API tokens, prompt-of-the-day entries, the votes on your results, audit entries, and preferences. It can't be undone.",
+		Reference: message.Reference(),
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "Forget me", Style: discordgo.DangerButton, CustomID: discord.ComponentID("forgetme-ok", token)},
+				discordgo.Button{Label: "Cancel", Style: discordgo.SecondaryButton, CustomID: discord.ComponentID("forgetme-cancel", token)},
+			}},
+		},
+	})
+	return err
+}
+
+func registerForgetComponents(router *discord.ComponentRouter) {
+	router.Handle("forgetme-ok", func(s *discordgo.Session, i *discordgo.InteractionCreate, token string) error {
+		pending, ok := takePendingForget(s, i, token)
+		if !ok {
+			return nil
+		}
+		if err := resolveConfirmation(s, i, "Forgetting you..."); err != nil {
+			return err
+		}
+
+		ctx := withTraceID(context.Background(), traits.NewTraceID())
+		command := newForgetCommand(ctx, s, pending.message)
+		command.Log().Info("applying .sforgetme command...")
+		if err := command.Apply(); err != nil {
+			slog.Error("couldn't forget user ", pending.message.Author.ID, ": ", err)
+			s.ChannelMessageSendReply(pending.message.ChannelID, "Couldn't finish forgetting you: "+err.Error(), pending.message.Reference())
+		}
+		return nil
+	})
+
+	router.Handle("forgetme-cancel", func(s *discordgo.Session, i *discordgo.InteractionCreate, token string) error {
+		if _, ok := takePendingForget(s, i, token); !ok {
+			return nil
+		}
+		return resolveConfirmation(s, i, "Cancelled; nothing was deleted.")
+	})
+}
+
+// takePendingForget removes and returns the pending request if the clicking user is its author.
+// Otherwise it tells the clicker why nothing happened.
+func takePendingForget(s *discordgo.Session, i *discordgo.InteractionCreate, token string) (pendingForget, bool) {
+	return pendingForgets.take(s, i, token, func(pending pendingForget, userID string) bool {
+		return userID == pending.message.Author.ID
+	}, onlyAskerText)
+}
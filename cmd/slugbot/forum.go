@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/config"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/promptspec"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// forumRequest turns the opening message of a post in one of the configured
+// forum channels into the command it asks for: the post's title is the
+// prompt, and its body holds `.saudio` flags or a ```saudio block. Results and
+// progress go to the post's thread, since that's where the message is.
+func forumRequest(session *discordgo.Session, message *discordgo.Message) (string, bool) {
+	forums := config.Get().Forum.Channels
+	// a post's opening message shares its ID with the post's thread
+	if len(forums) == 0 || message.ID != message.ChannelID {
+		return "", false
+	}
+	thread, err := commands.LookupChannel(session, message.ChannelID)
+	if err != nil || !thread.IsThread() || !slices.Contains(forums, thread.ParentID) {
+		return "", false
+	}
+
+	title := strings.TrimSpace(thread.Name)
+	body := strings.TrimSpace(message.Content)
+	if strings.HasPrefix(body, ".") {
+		// an explicit command is run as written
+		return body, true
+	}
+	if strings.HasPrefix(body, "```saudio") {
+		return withTitlePrompt(body, title), true
+	}
+	return strings.TrimSpace(".saudio " + title + " " + body), true
+}
+
+// withTitlePrompt adds the title as the prompt of a ```saudio block that
+// doesn't have prompts of its own.
+func withTitlePrompt(block string, title string) string {
+	if !strings.HasSuffix(block, "```") || len(block) < len("```saudio```") {
+		return block
+	}
+	inner := block[len("```saudio") : len(block)-3]
+	params, err := promptspec.ParseTOML(inner)
+	if err != nil || len(params.Prompts) > 0 || strings.Contains(inner, "[prompts]") {
+		return block
+	}
+	return fmt.Sprintf("```saudio%s\n[prompts]\n%q = 1.0\n```", strings.TrimRight(inner, "\n"), title)
+}
+
+// threadDeleteHandler cancels the queued jobs of a forum post that was deleted.
+func threadDeleteHandler(session *discordgo.Session, deleted *discordgo.ThreadDelete) {
+	if deleted.Channel == nil || !slices.Contains(config.Get().Forum.Channels, deleted.ParentID) {
+		return
+	}
+	for {
+		task, ok := audioQueue.Cancel(deleted.ID)
+		if !ok {
+			break
+		}
+		slog.With("trace", task.TraceID()).Info("forum post was deleted; cancelled queued job")
+	}
+	queueNotices.Delete(deleted.ID)
+}
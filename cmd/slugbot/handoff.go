@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"slugbot/internal/config"
+	"slugbot/internal/exec"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/lease"
+)
+
+// handoffPoll is how often a process waiting to take over tries for the
+// lease, which bounds how long the bot is offline between the two.
+const handoffPoll = 500 * time.Millisecond
+
+// errHandedOver is why a job that was still running when the drain ran out
+// is stopped; it's requeued, and so handed over with the rest of the queue.
+var errHandedOver = errors.New("stopped so a new bot process could take over")
+
+// holdLease takes the store's lease for this process. If another process
+// holds it, with takeover, it asks that one to hand over and waits until it
+// has; handedOver reports whether it did. Without takeover, it fails instead.
+func holdLease(cfg *config.Config, takeover bool) (l *lease.Lease, handedOver bool, err error) {
+	ttl := cfg.Handoff.LeaseTTL
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	host, _ := os.Hostname()
+	l = &lease.Lease{Dir: cfg.Store.Dir, Owner: fmt.Sprintf("%s:%d", host, os.Getpid()), TTL: ttl}
+	err = l.Acquire()
+	if !errors.Is(err, lease.ErrHeld) {
+		return l, false, err
+	}
+	if !takeover {
+		return nil, false, fmt.Errorf("%w; start with -handoff to take over from it", err)
+	}
+
+	slog.Info("waiting for the running bot to hand over: ", err)
+	var asked time.Time
+	for {
+		// the request lapses, so a process that gave up isn't handed over to
+		if time.Since(asked) >= l.TTL/3 {
+			if err := l.Request(); err != nil {
+				return nil, false, err
+			}
+			asked = time.Now()
+		}
+		time.Sleep(handoffPoll)
+		err := l.Acquire()
+		if err == nil {
+			return l, true, nil
+		}
+		if !errors.Is(err, lease.ErrHeld) {
+			return nil, false, err
+		}
+	}
+}
+
+// keepLease renews the lease until done is closed. It closes handoff once
+// another process asks to take over, and lost if the lease goes to another
+// process anyway, since this one can't safely keep writing to the store then.
+func keepLease(l *lease.Lease, handoff chan<- struct{}, lost chan<- struct{}, done <-chan struct{}) {
+	ticker := time.NewTicker(l.TTL / 3)
+	defer ticker.Stop()
+
+	asked := false
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		requested, err := l.Renew()
+		if errors.Is(err, lease.ErrLost) {
+			slog.Error("another bot process took over the store; stopping")
+			close(lost)
+			return
+		}
+		if err != nil {
+			slog.Warn("couldn't renew the store's lease: ", err)
+			continue
+		}
+		if requested && !asked {
+			asked = true
+			close(handoff)
+		}
+	}
+}
+
+// handOver gets this process ready to exit for the one that asked to take
+// over. No more jobs are started, and the running one gets up to drain to
+// finish before it's stopped and requeued, so the next process finds it with
+// the rest of the queue in the journal. Meanwhile, commands are still taken,
+// and their jobs are queued for the next process.
+func handOver(drain time.Duration) {
+	slog.Info("handing over to a new bot process")
+	audioQueue.Pause()
+
+	deadline := time.Now().Add(drain)
+	interrupted := false
+	for {
+		info, ok := audioQueue.Running()
+		if !ok {
+			return
+		}
+		if !interrupted && time.Now().After(deadline) {
+			interrupted = true
+			if interruptible, ok := info.Task.(exec.Interruptible); ok && interruptible.Interrupt(errHandedOver, true) {
+				slog.Info("stopped job ", info.ID, " to hand it over")
+			} else {
+				slog.Warn("job ", info.ID, " is still running, and can't be stopped to hand it over")
+				return
+			}
+		}
+		time.Sleep(time.Second)
+	}
+}
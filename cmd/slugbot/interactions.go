@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/config"
+	"slugbot/internal/discord"
+	"slugbot/internal/features"
+	"slugbot/internal/io/slog"
+)
+
+// slashDescriptions are the top-level commands offered as slash commands, e.g.
+// `/saudio args:rainy jazz --steps 8` for `.saudio rainy jazz --steps 8`.
+var slashDescriptions = map[string]string{
+	".saudio":    "Generate audio from a prompt",
+	".sim":       "Run an image operation",
+	".sqr":       "Draw text or a link as a QR code",
+	".stext":     "Draw text as an image",
+	".slimit":    "Limit the loudness of a WAV file",
+	".sadmin":    "Manage the bot (admins only)",
+	".scompare":  "Generate a prompt with two models side by side",
+	".ssweep":    "Generate a prompt across a range of settings",
+	".squeue":    "Show the job queue",
+	".sjob":      "Show, cancel, or offer to swap a job",
+	".stoken":    "Manage your personal API token",
+	".scredits":  "Show your credit balance",
+	".sforgetme": "Delete everything the bot keeps about you",
+	".sprefs":    "Show or change your preferences",
+	".stop10":    "Show this server's top-voted results of the week",
+}
+
+// inputCommands are the slash commands that take an optional file to work on.
+var inputCommands = map[string]bool{".saudio": true, ".sim": true, ".slimit": true}
+
+// blockCommands are commands written as a code block; their slash commands
+// ask for the block's text in a form.
+var blockCommands = map[string]string{
+	"saudio-config": "```saudio",
+	"sflow":         "```sflow",
+}
+
+// applicationCommands returns the commands to register: the message and user
+// context-menu commands, and in interactions-only mode a slash command for every enabled
+// command, which are cleared again when the mode is turned off.
+func applicationCommands() []*discordgo.ApplicationCommand {
+	var registered []*discordgo.ApplicationCommand
+	for name, command := range messageCommands {
+		if commandEnabled(command.prefix) {
+			registered = append(registered, &discordgo.ApplicationCommand{Name: name, Type: discordgo.MessageApplicationCommand})
+		}
+	}
+	if commandEnabled(".sim") {
+		registered = append(registered, &discordgo.ApplicationCommand{Name: distortAvatarCommand, Type: discordgo.UserApplicationCommand})
+	}
+	if !config.Get().Interactions.Only {
+		slices.SortFunc(registered, func(a, b *discordgo.ApplicationCommand) int { return strings.Compare(a.Name, b.Name) })
+		return registered
+	}
+
+	for prefix, description := range slashDescriptions {
+		if !commandEnabled(prefix) {
+			continue
+		}
+		options := []*discordgo.ApplicationCommandOption{{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "args",
+			Description: "Everything after " + prefix + ", as you'd type it",
+			Required:    !bareCommands[prefix],
+		}}
+		if inputCommands[prefix] {
+			options = append(options, &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionAttachment,
+				Name:        "input",
+				Description: "The file to work on",
+			})
+		}
+		registered = append(registered, &discordgo.ApplicationCommand{
+			Name:        strings.TrimPrefix(prefix, "."),
+			Description: description,
+			Options:     options,
+		})
+	}
+	if features.Enabled(features.Audio) {
+		registered = append(registered,
+			&discordgo.ApplicationCommand{Name: "saudio-config", Description: "Generate audio from a ```saudio config block"},
+			&discordgo.ApplicationCommand{Name: "sflow", Description: "Run a ```sflow workflow"},
+		)
+	}
+
+	slices.SortFunc(registered, func(a, b *discordgo.ApplicationCommand) int { return strings.Compare(a.Name, b.Name) })
+	return registered
+}
+
+// registerApplicationCommands replaces the bot's registered commands with
+// applicationCommands, in each configured guild or else globally.
+func registerApplicationCommands(session *discordgo.Session) {
+	appID := session.State.User.ID
+	guilds := config.Get().Interactions.Guilds
+	if len(guilds) == 0 {
+		guilds = []string{""}
+	}
+	registered := applicationCommands()
+	for _, guildID := range guilds {
+		if _, err := session.ApplicationCommandBulkOverwrite(appID, guildID, registered); err != nil {
+			slog.Error("couldn't register application commands in guild '", guildID, "': ", err)
+		}
+	}
+	if len(registered) > 0 {
+		slog.Info("registered ", len(registered), " application command(s)")
+	}
+}
+
+// applicationCommandHandler runs slash and context-menu commands through the
+// same dispatch as typed ones.
+func applicationCommandHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+	data := i.ApplicationCommandData()
+
+	var err error
+	switch {
+	case data.CommandType == discordgo.MessageApplicationCommand:
+		err = handleMessageCommand(s, i, data)
+	case data.CommandType == discordgo.UserApplicationCommand:
+		err = distortAvatar(s, i, data)
+	case blockCommands[data.Name] != "":
+		err = askForBlock(s, i, data.Name)
+	default:
+		err = handleSlashCommand(s, i, data)
+	}
+	if err != nil {
+		slog.Error("application command '", data.Name, "' failed: ", err)
+		discord.RespondEphemeral(s, i, fmt.Sprintf("Something went wrong: %v", err))
+	}
+}
+
+// handleSlashCommand turns a slash command back into the prefix command it stands for.
+func handleSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate, data discordgo.ApplicationCommandInteractionData) error {
+	content := "." + data.Name
+	if _, ok := slashDescriptions[content]; !ok {
+		return discord.RespondEphemeral(s, i, "That command isn't available anymore.")
+	}
+	var attachments []*discordgo.MessageAttachment
+	for _, option := range data.Options {
+		switch option.Name {
+		case "args":
+			content += " " + option.StringValue()
+		case "input":
+			if id, ok := option.Value.(string); ok && data.Resolved != nil && data.Resolved.Attachments[id] != nil {
+				attachments = append(attachments, data.Resolved.Attachments[id])
+			}
+		}
+	}
+	return runInteraction(s, i, "`/"+strings.TrimPrefix(content, ".")+"`", &discordgo.Message{Content: content, Attachments: attachments})
+}
+
+// askForBlock opens a form for the text of a code-block command.
+func askForBlock(s *discordgo.Session, i *discordgo.InteractionCreate, name string) error {
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: discord.ComponentID("slash-block", name),
+			Title:    "/" + name,
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID: "block",
+						Label:    "Contents of the " + blockCommands[name] + " block",
+						Style:    discordgo.TextInputParagraph,
+						Required: true,
+					},
+				}},
+			},
+		},
+	})
+}
+
+func registerInteractionComponents(router *discord.ComponentRouter) {
+	// submitting a code-block command's form runs the block
+	router.Handle("slash-block", func(s *discordgo.Session, i *discordgo.InteractionCreate, name string) error {
+		fence, ok := blockCommands[name]
+		if !ok {
+			return discord.RespondEphemeral(s, i, "That command isn't available anymore.")
+		}
+		content := fence + "\n" + strings.TrimSpace(modalValue(i.ModalSubmitData(), "block")) + "\n```"
+		return runInteraction(s, i, "`/"+name+"`\n"+content, &discordgo.Message{Content: content})
+	})
+}
+
+// runInteraction answers an interaction with a message showing what's being
+// run, then dispatches trigger as if it had been sent as that message, so
+// replies, reactions, and progress attach to it like they would to a typed
+// command.
+func runInteraction(s *discordgo.Session, i *discordgo.InteractionCreate, shown string, trigger *discordgo.Message) error {
+	user := discord.InteractionUser(i)
+	if user == nil {
+		return fmt.Errorf("missing user on interaction")
+	}
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:         shown,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	response, err := s.InteractionResponse(i.Interaction)
+	if err != nil {
+		return fmt.Errorf("couldn't look up the interaction's response: %w", err)
+	}
+
+	trigger.ID = response.ID
+	trigger.ChannelID = i.ChannelID
+	trigger.GuildID = i.GuildID
+	trigger.Author = user
+	trigger.Member = i.Member
+	slog.Info("running interaction for ", user.ID, ": ", trigger.Content)
+	dispatch(s, &discordgo.MessageCreate{Message: trigger})
+	return nil
+}
+
+// messageLink links to a message; guildID is "" in DMs.
+func messageLink(guildID, channelID, messageID string) string {
+	if guildID == "" {
+		guildID = "@me"
+	}
+	return "https://discord.com/channels/" + guildID + "/" + channelID + "/" + messageID
+}
@@ -2,41 +2,179 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"slices"
 	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/zalando/go-keyring"
+	"go.opentelemetry.io/otel/attribute"
 
+	"slugbot/internal/alert"
+	"slugbot/internal/analytics"
+	"slugbot/internal/apitoken"
+	"slugbot/internal/archive"
+	"slugbot/internal/audit"
+	"slugbot/internal/backend"
+	"slugbot/internal/cache"
 	"slugbot/internal/commands"
+	"slugbot/internal/commands/account"
+	"slugbot/internal/commands/admin"
 	"slugbot/internal/commands/audio"
-	"slugbot/internal/commands/image"
+	"slugbot/internal/commands/traits"
+	"slugbot/internal/config"
+	"slugbot/internal/credits"
+	"slugbot/internal/discord"
+	"slugbot/internal/eta"
+	"slugbot/internal/event"
 	"slugbot/internal/exec"
+	"slugbot/internal/fakesag"
+	"slugbot/internal/features"
+	"slugbot/internal/format"
+	"slugbot/internal/helpers"
+	"slugbot/internal/io/logfile"
 	"slugbot/internal/io/slog"
+	"slugbot/internal/llm"
+	"slugbot/internal/policy"
+	"slugbot/internal/prefs"
+	"slugbot/internal/presets"
+	"slugbot/internal/provenance"
+	"slugbot/internal/quota"
+	"slugbot/internal/ratings"
+	"slugbot/internal/recurring"
+	"slugbot/internal/results"
+	"slugbot/internal/schedule"
+	"slugbot/internal/secrets"
+	"slugbot/internal/store"
+	"slugbot/internal/telemetry"
+	"slugbot/internal/utils"
 )
 
 // Top-level commands such as `.saudio` or `.slimit`
-var topCommandHandlers = map[string]func(*discordgo.Session, *discordgo.MessageCreate) error{
-	".sim":      handleDotSim,
-	".saudio":   handleDotSaudio,
-	".saudiosm": handleDotSaudio,
-	"```saudio": handleDotSaudioConfig,
-	"```toml":   handleDotSaudioConfig,
-	".slimit":   handleDotSlimit,
+var topCommandHandlers = map[string]func(context.Context, *discordgo.Session, *discordgo.MessageCreate) error{
+	".sim":       handleDotSim,
+	".saudio":    handleDotSaudio,
+	".saudiosm":  handleDotSaudio,
+	"```saudio":  handleDotSaudioConfig,
+	"```toml":    handleDotSaudioConfig,
+	"```sflow":   handleDotSflow,
+	".slimit":    handleDotSlimit,
+	".sadmin":    handleDotSadmin,
+	".scompare":  handleDotScompare,
+	".ssweep":    handleDotSsweep,
+	".squeue":    handleDotSqueue,
+	".sjob":      handleDotSjob,
+	".stoken":    handleDotStoken,
+	".scredits":  handleDotScredits,
+	".sdelete":   handleDotSdelete,
+	".sforgetme": handleDotSforgetme,
+	".sprefs":    handleDotSprefs,
+	".stop10":    handleDotStop10,
 }
 
-// Subcommands for `.sim`
-var simCommandHandlers = map[string]func() commands.CommandHandler{
-	"arc":       func() commands.CommandHandler { return &image.ArcDistortCommand{} },
-	"barrel":    func() commands.CommandHandler { return &image.BarrelDistortCommand{} },
-	"ibarrel":   func() commands.CommandHandler { return &image.InverseBarrelDistortCommand{} },
-	"polar":     func() commands.CommandHandler { return &image.PolarDistortCommand{} },
-	"ipolar":    func() commands.CommandHandler { return &image.InversePolarDistortCommand{} },
-	"genframes": func() commands.CommandHandler { return &image.GenFramesCommand{} },
+// Top-level commands that run another one, so policies on that command cover them too
+var commandAliases = map[string]string{
+	".saudiosm": ".saudio",
+	"```toml":   "```saudio",
+}
+
+// Top-level commands that do something without any arguments
+var bareCommands = map[string]bool{
+	".squeue":    true,
+	".scredits":  true,
+	".sdelete":   true, // acts on the message it replies to
+	".sforgetme": true,
+	".sprefs":    true,
+	".stop10":    true,
+	".sim":       true, // posts the operation picker
+	".slimit":    true, // works on an attached wav or the one it replies to
+}
+
+// Subcommands for `.sim`; sim.go adds them unless the build leaves out images
+var simCommandHandlers = map[string]func() commands.CommandHandler{}
+
+// simBatchCommand wraps an image command to run over each image in a zip,
+// for `.sim <op> --batch`; ok is false if the command can't be. It's set
+// where the image commands are built in.
+var simBatchCommand = func(op commands.CommandHandler) (batch commands.CommandHandler, ok bool) { return nil, false }
+
+// commandFeatures names the feature each top-level command belongs to; a
+// command whose feature is off is ignored like an unknown one.
+var commandFeatures = map[string]features.Feature{
+	".sim":      features.Image,
+	".sqr":      features.Image,
+	".stext":    features.Image,
+	".saudio":   features.Audio,
+	".saudiosm": features.Audio,
+	"```saudio": features.Audio,
+	"```toml":   features.Audio,
+	"```sflow":  features.Audio,
+	".slimit":   features.Audio,
+	".scompare": features.Audio,
+	".ssweep":   features.Audio,
+	".squeue":   features.Audio,
+	".sjob":     features.Audio,
+	".stop10":   features.Audio,
+	".stoken":   features.API,
+}
+
+// Subcommands for `.sadmin`; only admins may run these
+var adminCommandHandlers = map[string]func(context.Context, *discordgo.Session, *discordgo.MessageCreate) error{
+	"archive":        handleSadminArchive,
+	"attribution":    handleSadminAttribution,
+	"audit":          handleSadminAudit,
+	"bench":          handleSadminBench,
+	"credits":        handleSadminCredits,
+	"cron":           handleSadminCron,
+	"inject-failure": handleSadminInjectFailure,
+	"maintenance":    handleSadminMaintenance,
+	"model":          handleSadminModel,
+	"nsfw":           handleSadminNSFW,
+	"persona":        handleSadminPersona,
+	"preset":         handleSadminPreset,
+	"preview":        handleSadminPreview,
+	"reactions":      handleSadminReactions,
+	"redeliver":      handleSadminRedeliver,
+	"stats":          handleSadminStats,
+	"template":       handleSadminTemplate,
+}
+
+// `.sadmin queue restore` dispatches the restored jobs, and dispatch reads the
+// handler tables, so it's added once they're initialized
+func init() {
+	adminCommandHandlers["queue"] = handleSadminQueue
+}
+
+// commandEnabled reports whether a top-level command's feature is on.
+func commandEnabled(name string) bool {
+	feature, ok := commandFeatures[name]
+	return !ok || features.Enabled(feature)
+}
+
+// stopper is a server that can be shut down, e.g. the control API.
+type stopper interface {
+	Stop()
+}
+
+// hiddenAdminCommands aren't listed when an admin mistypes a subcommand.
+var hiddenAdminCommands = map[string]bool{"inject-failure": true}
+
+// botAdminCommands affect every guild, e.g. the shared queue, credits and
+// model, so only the users in [admin] may run them, not each guild's admins.
+var botAdminCommands = map[string]bool{
+	"bench":          true,
+	"credits":        true,
+	"inject-failure": true,
+	"maintenance":    true,
+	"model":          true,
+	"queue":          true,
+	"redeliver":      true,
 }
 
 const usage = `Usage: .saudio [flags] <prompt words>
@@ -48,8 +186,13 @@ Flags:
   --help, -h, --usage
         display this help message
 
-  --negative
-        if present, makes all of the prompt words that follow this flag negative
+  --negative, --
+        if present, makes all of the prompt words that follow this flag negative,
+        e.g. .saudio rainy jazz -- drums vocals
+
+  [neg: <words>]
+        makes the words in the brackets negative, wherever they are in the prompt,
+        e.g. .saudio rainy [neg: drums] jazz
 
   --strength int
         how strongly the model follows your prompt
@@ -65,26 +208,119 @@ Flags:
   --length int
         length of the audio clip to generate, in seconds; default: 30
         best quality at ~30s; >85s may exhaust GPU VRAM
+
+  --enhance
+        rewrite the prompt into a more detailed one with an LLM before
+        generating; only available if the bot has an LLM configured
+
+  --keep-progress
+        instead of deleting the progress message when the clip is done,
+        leave it as a summary like "generated in 3m 12s, seed 1234"
+
+  --input int|string
+        which attached wav to use as input audio when there are several,
+        by position (starting at 1) or filename; default: the first
 `
 
 var audioQueue = *exec.NewTaskQueue()
 var audioQueueView *exec.TaskQueueView
+var queueViewPlacement *exec.ViewPlacement
+var jobEstimator = &eta.Estimator{}
+var componentRouter = discord.NewComponentRouter()
+var listingPages = discord.NewPaginator(componentRouter)
+var deliveredMessages = discord.NewDeduper(time.Hour)
+var llmClient *llm.Client
+var dataStore *store.Store
+var presetCatalog = &presets.Catalog{}
+var guildPolicies = &policy.Policies{}
+var provenanceLabels = &provenance.Labeler{Policies: guildPolicies}
+var resultFormatter = &results.Formatter{Policies: guildPolicies}
+var resultArchive = &archive.Archiver{Policies: guildPolicies}
+var dailyEvents = &event.Store{}
+var resultVotes = &ratings.Store{}
+var scheduler = &schedule.Scheduler{}
+var recurringJobs = &recurring.Runner{Scheduler: scheduler}
+var apiTokens = &apitoken.Registry{}
+var creditLedger *credits.Ledger
+var auditLog = &audit.Log{}
+var userPrefs = &prefs.Registry{}
+var userQuota *quota.Tracker
+var usageStats *analytics.Collector
+var audioModels = &backend.Models{Dir: "models"}
 
-func UpdateQueueViewCallback(view *exec.TaskQueueView) {
+// UpdateQueueViewCallback keeps view up to date. It looks at the queue every
+// interval, but only refreshes the messages that often while the view is in a
+// hurry; otherwise refreshes slow down, up to every maxInterval.
+func UpdateQueueViewCallback(view *exec.TaskQueueView, interval time.Duration, maxInterval time.Duration) {
 	if view == nil {
 		slog.Error("received nil view in UpdateQueueViewCallback")
 		return
 	}
 
-	ticker := time.NewTicker(2 * time.Second)
+	pace := &utils.Backoff{Min: interval, Max: maxInterval}
+	var refreshed time.Time
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
-	for range ticker.C {
+	for now := range ticker.C {
+		hurry := view.Hurry()
+		if !hurry && now.Sub(refreshed) < pace.Interval() {
+			continue
+		}
+		pace.Next(hurry)
+		refreshed = now
 		if err := view.Refresh(); err != nil {
-			slog.Error("failed to refresh view in channel %s; %v\r\n", view.ChannelID, err)
+			slog.Error("failed to refresh queue view; ", err)
 		}
 	}
 }
 
+// mirrorQueueView tells the queue view's placement a job was started from a
+// channel, which can show the view there or move it.
+func mirrorQueueView(channelID string) {
+	queueViewPlacement.JobStarted(channelID)
+}
+
+// startQueueView restores the queue view's messages, moves them to where the
+// configured placement puts them, and keeps them up to date.
+func startQueueView(session *discordgo.Session) {
+	cfg := config.Get().QueueView
+	view := exec.NewTaskQueueView(&audioQueue, session, dataStore, cfg.Pin)
+	if err := view.Load(); err != nil {
+		slog.Error("error loading queue view, ", err)
+	}
+	placement := &exec.ViewPlacement{View: view, Placement: cfg.Placement, Channels: cfg.Channels, JobChannels: cfg.JobChannels, Window: cfg.BusiestWindow}
+	if err := placement.Place(); err != nil {
+		slog.Error("error placing queue view, ", err)
+	}
+	queueViewPlacement = placement
+	audioQueueView = view
+
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	go UpdateQueueViewCallback(view, interval, cfg.MaxInterval)
+}
+
+type traceIDKey struct{}
+
+func withTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+func traceIDFrom(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
+
+type unattendedKey struct{}
+
+// isUnattended reports whether a command was dispatched with dispatchUnattended.
+func isUnattended(ctx context.Context) bool {
+	unattended, _ := ctx.Value(unattendedKey{}).(bool)
+	return unattended
+}
+
 func getCommandList() string {
 	var keys []string
 	for key := range simCommandHandlers {
@@ -94,7 +330,21 @@ func getCommandList() string {
 }
 
 func messageCreateHandler(session *discordgo.Session, message *discordgo.MessageCreate) {
-	if message == nil || message.Author == nil || message.Author.Bot {
+	if message == nil || message.Author == nil || message.Author.Bot || config.Get().Interactions.Only {
+		return
+	}
+
+	// Discord can redeliver messages after a reconnect; never run one twice
+	if !deliveredMessages.First(message.ID) {
+		slog.Warn("ignoring redelivered message ", message.ID)
+		return
+	}
+
+	// forum posts may be only a title and an attachment, so check them first
+	if request, ok := forumRequest(session, message.Message); ok {
+		msg := *message.Message
+		msg.Content = request
+		dispatch(session, &discordgo.MessageCreate{Message: &msg})
 		return
 	}
 
@@ -102,10 +352,33 @@ func messageCreateHandler(session *discordgo.Session, message *discordgo.Message
 	if len(content) < 1 {
 		return
 	}
+
+	if config.Get().NaturalLang.Enabled && isBotMention(session, message) {
+		handleMention(session, message)
+		return
+	}
+
+	dispatch(session, message)
+}
+
+// dispatch runs the top-level command a message starts with, if any.
+func dispatch(session *discordgo.Session, message *discordgo.MessageCreate) {
+	dispatchContext(context.Background(), session, message)
+}
+
+// dispatchUnattended runs a command that nobody is there to answer prompts
+// about, e.g. a resumed, restored, submitted or recurring job, so its jobs are
+// queued without asking for confirmation.
+func dispatchUnattended(session *discordgo.Session, message *discordgo.MessageCreate) {
+	dispatchContext(context.WithValue(context.Background(), unattendedKey{}, true), session, message)
+}
+
+func dispatchContext(parent context.Context, session *discordgo.Session, message *discordgo.MessageCreate) {
 	parts := strings.Fields(message.Content)
 
-	// if it doesn't have at least a top level command + argument, ignore it
-	if len(parts) < 2 {
+	// if it doesn't have at least a top level command + argument, ignore it,
+	// unless it's one of the commands that work on their own
+	if len(parts) < 1 || (len(parts) < 2 && !bareCommands[parts[0]]) {
 		return
 	}
 
@@ -114,19 +387,125 @@ func messageCreateHandler(session *discordgo.Session, message *discordgo.Message
 	if !ok {
 		return
 	}
+	if !commandEnabled(parts[0]) {
+		return
+	}
+
+	traceID := traits.NewTraceID()
+	log := slog.With("trace", traceID)
+	log.Info("dispatching ", parts[0], " from user ", message.Author.ID, " in channel ", message.ChannelID)
+
+	ctx, span := telemetry.Start(parent, "dispatch",
+		telemetry.TraceIDAttr(traceID),
+		attribute.String("slugbot.command", parts[0]),
+		attribute.String("discord.channel_id", message.ChannelID),
+	)
+	ctx = withTraceID(ctx, traceID)
+	if userPref, err := userPrefs.Get(message.Author.ID); err != nil {
+		log.Warn("couldn't load preferences: ", err)
+	} else {
+		ctx = prefs.NewContext(ctx, userPref)
+	}
+
+	// admins need to be able to run maintenance even when over quota
+	if parts[0] != ".sadmin" {
+		if err := userQuota.Check(message.Author.ID); err != nil {
+			log.Warn("refusing command: ", err)
+			telemetry.End(span, err)
+			session.ChannelMessageSendReply(message.ChannelID, fmt.Sprintf("Can't start a new job yet (%v); try again once your earlier results have been delivered.", err), message.Reference())
+			return
+		}
+	}
+
+	key := commandKey(parts)
+	if reason, restricted := nsfwRestriction(session, message, key); restricted {
+		log.Warn("refusing command outside an age-restricted channel: ", reason)
+		telemetry.End(span, nil)
+		session.ChannelMessageSendReply(message.ChannelID, "Sorry, "+reason+".", message.Reference())
+		return
+	}
+	usageStats.Count(message.GuildID, key)
+	recordSubmitter(message)
+
+	err := topCommandHandler(ctx, session, message)
+	telemetry.End(span, err)
+	if err != nil {
+		usageStats.Fail(message.GuildID, key)
+		log.Error("Command handler failed with error: ", err)
+		session.ChannelMessageSend(message.ChannelID, commands.FailureText(session, err, traceID)+commands.TraceFooter(traceID))
+	}
+}
+
+// nsfwRestriction reports whether the guild's NSFW policy keeps a command out
+// of the channel it was sent in, and why. An alias is restricted along with
+// the command it runs; admin commands are never restricted.
+func nsfwRestriction(session *discordgo.Session, message *discordgo.MessageCreate, key string) (reason string, restricted bool) {
+	if message.GuildID == "" || strings.HasPrefix(key, ".sadmin") {
+		return "", false
+	}
+	nsfw, err := guildPolicies.NSFW(message.GuildID)
+	if err != nil {
+		slog.Warn(err)
+	}
+	reason, restricted = nsfw.Restricts(key, message.Content)
+	if canonical, ok := commandAliases[key]; ok && !restricted {
+		reason, restricted = nsfw.Restricts(canonical, message.Content)
+	}
+	if !restricted || commands.IsNSFWChannel(session, message.ChannelID) {
+		return "", false
+	}
+	return reason, true
+}
+
+// commandKey names a command for usage analytics. Subcommands are only included
+// when they're registered, so free-form user text never ends up in the stats.
+func commandKey(parts []string) string {
+	if len(parts) < 2 {
+		return parts[0]
+	}
+	switch parts[0] {
+	case ".sim":
+		if _, ok := simCommandHandlers[parts[1]]; ok {
+			return ".sim " + parts[1]
+		}
+	case ".sadmin":
+		if _, ok := adminCommandHandlers[parts[1]]; ok {
+			return ".sadmin " + parts[1]
+		}
+	}
+	return parts[0]
+}
+
+// recordQueuedJob adds a finished queued job's settings and outcome to the usage analytics.
+func recordQueuedJob(task exec.Task, err error) {
+	triggered, ok := task.(interface {
+		TriggerMessage() *discordgo.MessageCreate
+	})
+	if !ok || triggered.TriggerMessage() == nil {
+		return
+	}
+	message := triggered.TriggerMessage()
 
-	err := topCommandHandler(session, message)
+	if estimable, ok := task.(exec.Estimable); ok {
+		if shape, ok := estimable.Shape(); ok {
+			usageStats.Shape(message.GuildID, shape)
+		}
+	}
 	if err != nil {
-		slog.Error("Command handler failed with error: %w", err)
-		session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Received error while executing command: %v", err))
+		if parts := strings.Fields(message.Content); len(parts) > 1 {
+			usageStats.Fail(message.GuildID, commandKey(parts))
+		}
 	}
 }
 
-func handleDotSim(session *discordgo.Session, message *discordgo.MessageCreate) error {
+func handleDotSim(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
 	if len(strings.TrimSpace(message.Content)) < 1 {
 		return fmt.Errorf("tried to handle .sim command without any message content")
 	}
 	parts := strings.Fields(message.Content)
+	if len(parts) < 2 {
+		return sendSimPicker(session, message)
+	}
 	commandString := parts[1]
 	commandConstructor, ok := simCommandHandlers[commandString]
 	if !ok {
@@ -134,19 +513,56 @@ func handleDotSim(session *discordgo.Session, message *discordgo.MessageCreate)
 		return nil
 	}
 
+	// --input is shared by every image command, so it's handled here rather than in each Validate
+	content, input, err := helpers.SplitInputFlag(message.Content)
+	if err != nil {
+		return err
+	}
+	// and so is --batch, which runs the command over each image in a zip
+	fields := strings.Fields(content)
+	batch := slices.Contains(fields, "--batch")
+	if batch {
+		content = strings.Join(slices.DeleteFunc(fields, func(field string) bool { return field == "--batch" }), " ")
+	}
+	// and --no-watermark, with which admins get a result without the guild's watermark
+	fields = strings.Fields(content)
+	if slices.Contains(fields, "--no-watermark") {
+		if !commands.IsAdmin(session, message) {
+			session.ChannelMessageSendReply(message.ChannelID, "Only admins can use `--no-watermark`.", message.Reference())
+			return nil
+		}
+		content = strings.Join(slices.DeleteFunc(fields, func(field string) bool { return field == "--no-watermark" }), " ")
+		ctx = helpers.WithoutWatermark(ctx)
+	}
+
 	command := commandConstructor()
-	command.SetContext(session, message)
+	if batch {
+		op := command
+		op.SetContext(session, commands.EditedMessage(message, content))
+		if command, ok = simBatchCommand(op); !ok {
+			session.ChannelMessageSendReply(message.ChannelID, "`"+commandString+"` can't be used with `--batch`.", message.Reference())
+			return nil
+		}
+	}
+	command.SetContext(session, commands.EditedMessage(message, content))
+	command.SetInput(input)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+	// image commands run right away, and can take a few seconds to download, render, and upload
+	defer discord.Typing(discord.ConcreteSession{Session: session}, message.ChannelID)()
 	if err := command.Apply(); err != nil {
 		return err
 	}
 
-	slog.Info("applying .sim command...")
+	slog.With("trace", command.TraceID()).Info("applying .sim command...")
 	return nil
 }
 
-func handleDotSaudio(session *discordgo.Session, message *discordgo.MessageCreate) error {
-	command := &audio.StableAudioCommand{}
+func handleDotSaudio(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &audio.StableAudioCommand{Estimator: jobEstimator, LLM: llmClient, Quota: userQuota, Models: audioModels, Labels: provenanceLabels, Results: resultFormatter, Archive: resultArchive, Votes: resultVotes}
 	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
 
 	// need to validate input before we can save the prompt
 	if err := command.Validate(); err != nil {
@@ -167,46 +583,671 @@ func handleDotSaudio(session *discordgo.Session, message *discordgo.MessageCreat
 		return nil
 	}
 
-	if audioQueueView == nil {
-		audioQueueView := *exec.NewTaskQueueView(&audioQueue, session, message.ChannelID)
-		go UpdateQueueViewCallback(&audioQueueView)
+	if needsConfirmation(command) && !isUnattended(ctx) {
+		return askToConfirm(session, message, command)
+	}
+	queueSaudio(session, message, command)
+	return nil
+}
+
+// queueSaudio queues a `.saudio` job and enters it for the prompt of the day.
+func queueSaudio(session *discordgo.Session, message *discordgo.MessageCreate, command *audio.StableAudioCommand) {
+	mirrorQueueView(message.ChannelID)
+
+	command.Log().Info("applying saudio command...")
+	if enqueueAudio(session, message, command) {
+		enterDailyPrompt(session, message)
+	}
+}
+
+// enqueueAudio queues a generation and, if it won't start right away, tells the
+// user their position and (when there's enough history) the estimated wait.
+// It reports whether the job was queued.
+func enqueueAudio(session *discordgo.Session, message *discordgo.MessageCreate, task exec.Task) bool {
+	ahead, err := audioQueue.Enqueue(task)
+	if err != nil {
+		rejectEnqueue(session, message, err)
+		return false
+	}
+	replyQueuePosition(session, message, ahead)
+	return true
+}
+
+// queueRefused reports whether err is the queue turning a job away, rather than the job failing.
+func queueRefused(err error) bool {
+	return errors.Is(err, exec.ErrQueueFull) || errors.Is(err, exec.ErrOwnerBusy) || errors.Is(err, credits.ErrInsufficient) || errors.Is(err, quota.ErrOverQuota)
+}
+
+// rejectEnqueue tells the user why their job wasn't queued.
+func rejectEnqueue(session *discordgo.Session, message *discordgo.MessageCreate, err error) {
+	reactRefused(session, message)
+	if errors.Is(err, credits.ErrInsufficient) {
+		session.ChannelMessageSendReply(message.ChannelID,
+			fmt.Sprintf("Sorry, you don't have enough credits for that (%v). Check your balance with `.scredits`.", err), message.Reference())
+		return
+	}
+	if errors.Is(err, exec.ErrOwnerBusy) {
+		session.ChannelMessageSendReply(message.ChannelID,
+			fmt.Sprintf("Sorry, %v. Try again once one of them has started.", err), message.Reference())
+		return
+	}
+	if errors.Is(err, quota.ErrOverQuota) {
+		session.ChannelMessageSendReply(message.ChannelID,
+			fmt.Sprintf("Can't queue that yet (%v); try again once your earlier jobs have finished and their results have been delivered.", err), message.Reference())
+		return
+	}
+	rejectQueueFull(session, message, err)
+}
+
+// queueFullAlerts decides when turned-away jobs are worth telling the admins about.
+var queueFullAlerts = &alert.Threshold{}
+
+// rejectQueueFull tells the user why their job wasn't queued, with the current
+// depth and how long the queue would take to drain, and alerts the admins if it
+// keeps happening.
+func rejectQueueFull(session *discordgo.Session, message *discordgo.MessageCreate, err error) {
+	_, _, waiting := audioQueue.Status()
+	reply := fmt.Sprintf("Sorry, %v", err)
+	if wait, ok := audioQueue.EstimateWait(waiting + 1); ok {
+		reply += fmt.Sprintf(" (about %s of work)", format.Duration(wait))
+	}
+	session.ChannelMessageSendReply(message.ChannelID, reply+". Try again in a bit.", message.Reference())
+
+	if queueFullAlerts.Hit() {
+		cfg := config.Get()
+		alert.Send(session, cfg.Admin.AlertChannels, fmt.Sprintf("The generation queue turned away %d or more jobs in the last %s; %d are waiting (limit %d).",
+			cfg.Queue.AlertAfter, format.Duration(cfg.Queue.AlertWindow), waiting, audioQueue.MaxDepth))
+	}
+}
+
+// replyQueuePosition tells the user their job IDs and how many jobs are ahead of theirs.
+func replyQueuePosition(session *discordgo.Session, message *discordgo.MessageCreate, ahead int) {
+	paused, _, _ := audioQueue.Status()
+
+	var status string
+	if ahead == 0 && !paused {
+		status = "starting now"
+	} else if paused {
+		status = fmt.Sprintf("queued behind %d job(s); the queue is paused for maintenance, so it'll start once that's over", ahead)
+	} else {
+		status = fmt.Sprintf("queued behind %d job(s)", ahead)
+		if wait, ok := audioQueue.EstimateWait(ahead); ok {
+			status += "; estimated start in ~" + format.Duration(wait)
+		}
+	}
+
+	ids := audioQueue.JobIDs(message.ID)
+	if (len(ids) == 0 && ahead == 0 && !paused) || isQuiet(message.Author) {
+		// it's already done, so there's nothing to look up, or its author only wants the result
+		return
+	}
+	reactQueued(session, message)
+	reply := strings.ToUpper(status[:1]) + status[1:] + "."
+	if len(ids) > 0 {
+		reply = fmt.Sprintf("Job `%s`: %s.\nCheck on it with `.sjob %s`.", strings.Join(ids, "`, `"), status, ids[0])
+	}
+	notice, err := session.ChannelMessageSendReply(message.ChannelID, reply, message.Reference())
+	if err == nil {
+		queueNotices.Store(message.ID, notice.ID)
+	}
+}
+
+func handleDotScompare(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &audio.CompareCommand{
+		Queue:  &audioQueue,
+		Models: config.Get().Compare.Models,
+		Audio:  audioModels,
+		Quota:  userQuota,
+		Labels: provenanceLabels,
+	}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, command.Usage())
+		return err
+	}
+
+	command.Log().Info("applying .scompare command...")
+	if err := command.Apply(); queueRefused(err) {
+		rejectEnqueue(session, message, err)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	replyQueuePosition(session, message, command.Ahead())
+	return nil
+}
+
+func handleDotSsweep(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &audio.SweepCommand{
+		Queue:   &audioQueue,
+		Audio:   audioModels,
+		Quota:   userQuota,
+		Labels:  provenanceLabels,
+		MaxJobs: config.Get().Sweep.MaxJobs,
 	}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
 
-	slog.Info("applying saudio command...")
-	audioQueue.Enqueue(command)
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, command.Usage())
+		return err
+	}
+
+	command.Log().Info("applying .ssweep command...")
+	if err := command.Apply(); queueRefused(err) {
+		rejectEnqueue(session, message, err)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	replyQueuePosition(session, message, command.Ahead())
 	return nil
 }
 
-func handleDotSaudioConfig(session *discordgo.Session, message *discordgo.MessageCreate) error {
-	command := &audio.StableAudioWithConfigCommand{}
+func handleDotSaudioConfig(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &audio.StableAudioWithConfigCommand{Estimator: jobEstimator, Quota: userQuota, Models: audioModels, Labels: provenanceLabels, Results: resultFormatter, Archive: resultArchive, Votes: resultVotes}
 	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	mirrorQueueView(message.ChannelID)
 
-	if audioQueueView == nil {
-		audioQueueView := *exec.NewTaskQueueView(&audioQueue, session, message.ChannelID)
-		go UpdateQueueViewCallback(&audioQueueView)
+	// a [sweep] table turns the block into a grid of jobs
+	grid := &audio.GridCommand{
+		Queue:   &audioQueue,
+		Audio:   audioModels,
+		Quota:   userQuota,
+		Labels:  provenanceLabels,
+		MaxJobs: config.Get().Sweep.MaxJobs,
+	}
+	grid.SetContext(session, message)
+	grid.SetTraceID(traceIDFrom(ctx))
+	grid.SetTraceContext(ctx)
+	if grid.HasSweep() {
+		if err := grid.Validate(); err != nil {
+			session.ChannelMessageSend(message.ChannelID, err.Error()+"\n"+grid.Usage())
+			return err
+		}
+		grid.Log().Info("applying saudio grid command...")
+		if err := grid.Apply(); queueRefused(err) {
+			rejectEnqueue(session, message, err)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		replyQueuePosition(session, message, grid.Ahead())
+		return nil
 	}
 
-	slog.Info("applying saudio w/ config command...")
-	audioQueue.Enqueue(command)
+	command.Log().Info("applying saudio w/ config command...")
+	enqueueAudio(session, message, command)
 	return nil
 }
 
-func handleDotSlimit(session *discordgo.Session, message *discordgo.MessageCreate) error {
-	command := &audio.LimitCommand{}
+// handleDotSflow queues the stages of a ```sflow workflow as a chain.
+func handleDotSflow(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &audio.WorkflowCommand{Queue: &audioQueue, Models: audioModels, Quota: userQuota, Labels: provenanceLabels, Estimator: jobEstimator}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, err.Error()+"\n"+command.Usage())
+		return err
+	}
+
+	mirrorQueueView(message.ChannelID)
+
+	command.Log().Info("applying sflow command...")
+	if err := command.Apply(); queueRefused(err) {
+		rejectEnqueue(session, message, err)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	replyQueuePosition(session, message, command.Ahead())
+	return nil
+}
+
+func handleDotSqueue(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &audio.QueueCommand{Queue: &audioQueue, Pages: listingPages}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	command.Log().Info("applying .squeue command...")
+	return command.Apply()
+}
+
+func handleDotSjob(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	if parts := strings.Fields(message.Content); len(parts) > 1 && parts[1] == "swap" {
+		return handleJobSwap(session, message)
+	}
+
+	command := &audio.JobCommand{Queue: &audioQueue}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, command.Usage())
+		return err
+	}
+
+	command.Log().Info("applying .sjob command...")
+	return command.Apply()
+}
+
+func handleDotStoken(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &account.TokenCommand{Tokens: apiTokens}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, err.Error())
+		return err
+	}
+
+	command.Log().Info("applying .stoken command...")
+	if err := command.Apply(); err != nil {
+		session.ChannelMessageSendReply(message.ChannelID, err.Error(), message.Reference())
+		return err
+	}
+	return nil
+}
+
+func handleDotSprefs(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &account.PrefsCommand{Prefs: userPrefs, Email: config.Get().Notify.SMTPHost != ""}
 	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
 
-	slog.Info("applying .slimit command...")
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, command.Usage())
+		return err
+	}
+
+	command.Log().Info("applying .sprefs command...")
+	if err := command.Apply(); err != nil {
+		session.ChannelMessageSendReply(message.ChannelID, "Couldn't change your preferences: "+err.Error()+".", message.Reference())
+		return err
+	}
+	return nil
+}
+
+// isQuiet reports whether a user only wants their results; see prefs.Prefs.
+func isQuiet(user *discordgo.User) bool {
+	if user == nil {
+		return false
+	}
+	userPref, err := userPrefs.Get(user.ID)
+	if err != nil {
+		slog.Warn("couldn't load preferences of ", user.ID, ": ", err)
+	}
+	return userPref.Quiet
+}
+
+func handleDotScredits(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &account.CreditsCommand{Ledger: creditLedger, PerMinute: config.Get().Credits.PerGPUMinute}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, command.Usage())
+		return err
+	}
+
+	command.Log().Info("applying .scredits command...")
+	return command.Apply()
+}
+
+func handleDotStop10(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &audio.TopCommand{Votes: resultVotes}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, err.Error())
+		return nil
+	}
+
+	command.Log().Info("applying .stop10 command...")
+	return command.Apply()
+}
+
+func handleDotSdelete(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &account.DeleteCommand{Queue: &audioQueue, Audit: auditLog}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, command.Usage())
+		return err
+	}
+
+	command.Log().Info("applying .sdelete command...")
+	if err := command.Apply(); err != nil {
+		session.ChannelMessageSendReply(message.ChannelID, "Couldn't delete that: "+err.Error(), message.Reference())
+		return nil
+	}
+	return nil
+}
+
+func handleDotSlimit(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	content, input, err := helpers.SplitInputFlag(message.Content)
+	if err != nil {
+		return err
+	}
+
+	command := &audio.LimitCommand{}
+	command.SetContext(session, commands.EditedMessage(message, content))
+	command.SetInput(input)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	command.Log().Info("applying .slimit command...")
 	command.Apply()
 	return nil
 }
 
+func handleDotSadmin(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	if !commands.IsAdmin(session, message) {
+		session.ChannelMessageSend(message.ChannelID, "Only admins can use `.sadmin`.")
+		return nil
+	}
+
+	parts := strings.Fields(message.Content)
+	handler, ok := adminCommandHandlers[parts[1]]
+	if !ok {
+		var keys []string
+		for key := range adminCommandHandlers {
+			if !hiddenAdminCommands[key] {
+				keys = append(keys, "`"+key+"`")
+			}
+		}
+		session.ChannelMessageSend(message.ChannelID, "Received unknown admin command '`"+parts[1]+"`'; must be one of '"+strings.Join(keys, ", ")+"'")
+		return nil
+	}
+	if botAdminCommands[parts[1]] && !commands.IsBotAdmin(message) {
+		session.ChannelMessageSend(message.ChannelID, "Only the bot's admins can use `.sadmin "+parts[1]+"`, since it affects every server.")
+		return nil
+	}
+	return handler(ctx, session, message)
+}
+
+func handleSadminBench(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.BenchCommand{Store: dataStore}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+	command.SetPrompt("(benchmark)")
+
+	// the benchmark uses the GPU, so it waits its turn like any other generation
+	command.Log().Info("queueing benchmark...")
+	if _, err := audioQueue.Enqueue(command); err != nil {
+		return err
+	}
+	session.ChannelMessageSend(message.ChannelID, "Benchmark queued; results will be posted when it finishes.")
+	return nil
+}
+
+func handleSadminMaintenance(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.MaintenanceCommand{Queue: &audioQueue, Channels: config.Get().Maintenance.Channels}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	command.Log().Info("applying .sadmin maintenance command...")
+	return command.Apply()
+}
+
+func handleSadminModel(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.ModelCommand{Models: audioModels, Queue: &audioQueue}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	command.Log().Info("applying .sadmin model command...")
+	return command.Apply()
+}
+
+func handleSadminStats(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.StatsCommand{Analytics: usageStats}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	command.Log().Info("applying .sadmin stats command...")
+	return command.Apply()
+}
+
+func handleSadminRedeliver(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.RedeliverCommand{Archive: resultArchive}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, command.Usage())
+		return nil
+	}
+	command.Log().Info("applying .sadmin redeliver command...")
+	return command.Apply()
+}
+
+func handleSadminQueue(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.QueueCommand{Queue: &audioQueue, Restore: func(job exec.JournalEntry) string {
+		// a job that's already back in the queue would otherwise run twice
+		if len(audioQueue.JobIDs(job.MessageID)) > 0 {
+			return "it's already queued"
+		}
+		return restoreJob(session, job)
+	}}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, command.Usage())
+		return nil
+	}
+	command.Log().Info("applying .sadmin queue command...")
+	return command.Apply()
+}
+
+func handleSadminPreset(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.PresetCommand{Presets: presetCatalog}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, command.Usage())
+		return nil
+	}
+	return command.Apply()
+}
+
+func handleSadminNSFW(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.NSFWCommand{Policies: guildPolicies}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, command.Usage())
+		return nil
+	}
+
+	command.Log().Info("applying .sadmin nsfw command...")
+	return command.Apply()
+}
+
+func handleSadminInjectFailure(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.InjectFailureCommand{}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, err.Error())
+		return nil
+	}
+
+	command.Log().Info("applying .sadmin inject-failure command...")
+	return command.Apply()
+}
+
+func handleSadminPersona(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.PersonaCommand{Policies: guildPolicies}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, err.Error())
+		return nil
+	}
+
+	command.Log().Info("applying .sadmin persona command...")
+	return command.Apply()
+}
+
+func handleSadminTemplate(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.TemplateCommand{Policies: guildPolicies}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, err.Error())
+		return nil
+	}
+
+	command.Log().Info("applying .sadmin template command...")
+	return command.Apply()
+}
+
+func handleSadminArchive(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.ArchiveCommand{Policies: guildPolicies}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, err.Error())
+		return nil
+	}
+
+	command.Log().Info("applying .sadmin archive command...")
+	return command.Apply()
+}
+
+func handleSadminPreview(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.PreviewCommand{Policies: guildPolicies}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, err.Error())
+		return nil
+	}
+
+	command.Log().Info("applying .sadmin preview command...")
+	return command.Apply()
+}
+
+func handleSadminReactions(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.ReactionsCommand{Policies: guildPolicies}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, command.Usage())
+		return nil
+	}
+
+	command.Log().Info("applying .sadmin reactions command...")
+	return command.Apply()
+}
+
+func handleSadminAttribution(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.AttributionCommand{Policies: guildPolicies}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, command.Usage())
+		return nil
+	}
+
+	command.Log().Info("applying .sadmin attribution command...")
+	return command.Apply()
+}
+
+func handleSadminAudit(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.AuditCommand{Audit: auditLog, Pages: listingPages}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, err.Error())
+		return nil
+	}
+
+	command.Log().Info("applying .sadmin audit command...")
+	return command.Apply()
+}
+
+func handleSadminCredits(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.CreditsCommand{Ledger: creditLedger}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, err.Error())
+		return nil
+	}
+
+	command.Log().Info("applying .sadmin credits command...")
+	return command.Apply()
+}
+
+func handleSadminCron(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	command := &admin.CronCommand{Jobs: recurringJobs, Commands: recurringCommands, Pages: listingPages}
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, err.Error())
+		return nil
+	}
+
+	command.Log().Info("applying .sadmin cron command...")
+	return command.Apply()
+}
+
 func loadDiscordToken() (string, error) {
-	token, err := keyring.Get("slugbot-production", "token")
-	if err == keyring.ErrNotFound {
+	token, err := secrets.Get(secrets.DiscordToken)
+	if errors.Is(err, secrets.ErrNotFound) {
 		fmt.Print("Enter your Discord API token:")
 		input, _ := bufio.NewReader(os.Stdin).ReadString('\n')
 		input = strings.TrimSpace(input)
-		if err := keyring.Set("slugbot-production", "token", input); err != nil {
+		if err := secrets.Set(secrets.DiscordToken, input); err != nil {
 			return "", fmt.Errorf("couldn't save token: %w", err)
 		}
 		return input, nil
@@ -216,9 +1257,157 @@ func loadDiscordToken() (string, error) {
 	return token, nil
 }
 
+// setupServices opens the store and wires up the queue and the services
+// commands use. It's shared by the bot and `slugbot run`, which only leave out
+// what needs a live gateway.
+func setupServices(cfg *config.Config) error {
+	var err error
+	dataStore, err = store.Open(cfg.Store.Dir)
+	if err != nil {
+		return err
+	}
+	presetCatalog.Store = dataStore
+	guildPolicies.Store = dataStore
+	dailyEvents.Store = dataStore
+	resultVotes.Store = dataStore
+	recurringJobs.Store = dataStore
+	apiTokens.Store = dataStore
+	auditLog.Store = dataStore
+	setupScanner(cfg)
+	userPrefs.Store = dataStore
+	guildDepartures.Store, guildDepartures.Grace = dataStore, cfg.Prune.Grace
+	discord.TrackProgressMessages(dataStore)
+	discord.KeepUndelivered(dataStore, filepath.Join(cfg.Store.Dir, "undelivered"))
+	discord.UsePreviews(resultSubmitter)
+	discord.AttributeResults(resultOwner)
+	recurringJobs.CatchUpWithin = cfg.Recurring.CatchUpWithin
+	recurringCommands = allowedRecurringCommands()
+	jobEstimator.Store = dataStore
+	audioModels.Store = dataStore
+	if err := audioModels.Load(); err != nil {
+		slog.Warn(err)
+	}
+	llmClient = llm.NewClient(cfg.LLM)
+	userQuota = quota.NewTracker(cfg.Quota.MaxUserBytes)
+	registerMentionComponents(componentRouter)
+	registerForgetComponents(componentRouter)
+	registerConfirmComponents(componentRouter)
+	registerSimPickerComponents(componentRouter)
+	registerRedeliverComponents(componentRouter)
+	registerSwapComponents(componentRouter)
+	registerInteractionComponents(componentRouter)
+	registerContextMenuComponents(componentRouter)
+	registerPreviewComponents(componentRouter)
+	audioQueue.Estimator = jobEstimator
+	audioQueue.MaxDepth = cfg.Queue.MaxDepth
+	audioQueue.Owner, audioQueue.Uncapped = taskOwner, jobUncapped
+	audioQueue.MaxOwnerWaiting, audioQueue.MaxWaitingFor = cfg.Queue.MaxUserWaiting, ownerMaxWaiting
+	queueFullAlerts.Count, queueFullAlerts.Window = cfg.Queue.AlertAfter, cfg.Queue.AlertWindow
+	audioQueue.OnFinish = finishQueuedJob
+	audioQueue.Journal = &exec.Journal{Store: dataStore}
+	audioQueue.Admit = admitJobs
+	if cfg.Credits.Enabled {
+		creditLedger = &credits.Ledger{Store: dataStore, Starting: cfg.Credits.Starting}
+	}
+	return nil
+}
+
 func main() {
+	// the staging bot runs this binary in place of sag
+	if stepTime := os.Getenv(fakesag.EnvVar); stepTime != "" {
+		os.Exit(runFakeSag(stepTime, os.Args[1:]))
+	}
+
+	configPath := flag.String("config", "slugbot.toml", "path to the bot's TOML config file")
+	takeover := flag.Bool("handoff", false, "take over from the bot process running against the same store, instead of refusing to start")
+	flag.Parse()
+
+	if flag.Arg(0) == "secrets" {
+		os.Exit(runSecretsCommand(flag.Args()[1:], os.Stdin, os.Stdout))
+	}
+	if flag.Arg(0) == "check-config" {
+		os.Exit(runCheckConfig(flag.Args()[1:], os.Stdout))
+	}
+	if flag.Arg(0) == "run" {
+		os.Exit(runLocal(*configPath, flag.Args()[1:], os.Stdout))
+	}
+	if flag.Arg(0) == "mirror" {
+		os.Exit(runMirror(*configPath, flag.Args()[1:], os.Stdout))
+	}
+
 	slog.SetLevel(slog.LevelTrace)
 
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		slog.Error("error loading config, ", err)
+		return
+	}
+	config.Set(cfg)
+
+	if cfg.Log.File != "" {
+		logFile := &logfile.Writer{
+			Path:     cfg.Log.File,
+			MaxSize:  cfg.Log.MaxSize,
+			MaxAge:   cfg.Log.MaxAge,
+			Compress: cfg.Log.Compress,
+			Keep:     cfg.Log.Keep,
+			Retain:   cfg.Log.Retain,
+		}
+		if err := logFile.Open(); err != nil {
+			slog.Error("logging to stderr only: ", err)
+		} else {
+			defer logFile.Close()
+			slog.AlsoWriteTo(logFile)
+		}
+	}
+
+	storeLease, handedOver, err := holdLease(cfg, *takeover)
+	if err != nil {
+		slog.Error("error taking the store's lease, ", err)
+		return
+	}
+	// released last, once everything else is written
+	defer storeLease.Release()
+	handoff := make(chan struct{})
+	leaseLost := make(chan struct{})
+	leaseDone := make(chan struct{})
+	defer close(leaseDone)
+	go keepLease(storeLease, handoff, leaseLost, leaseDone)
+
+	if err := setupServices(cfg); err != nil {
+		slog.Error("error opening store, ", err)
+		return
+	}
+
+	if cfg.Analytics.Enabled {
+		usageStats = &analytics.Collector{Store: dataStore}
+		analyticsDone := make(chan struct{})
+		defer close(analyticsDone)
+		defer usageStats.Flush()
+		go usageStats.Start(cfg.Analytics.FlushInterval, analyticsDone)
+	}
+
+	var downloadCache *cache.Cache
+	if cfg.Cache.Enabled {
+		downloadCache, err = cache.New(cfg.Cache.Dir, cfg.Cache.TTL)
+		if err != nil {
+			slog.Error("error creating download cache, ", err)
+			return
+		}
+		helpers.SetDownloadCache(downloadCache)
+	}
+	janitor := &cache.Janitor{Cache: downloadCache, Interval: cfg.Cache.JanitorInterval, MaxTempAge: cfg.Cache.MaxTempAge}
+	janitorDone := make(chan struct{})
+	defer close(janitorDone)
+	go janitor.Start(janitorDone)
+
+	shutdownTracing, err := telemetry.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		slog.Error("error initializing tracing, ", err)
+		return
+	}
+	defer shutdownTracing(context.Background())
+
 	token, err := loadDiscordToken()
 	if err != nil {
 		slog.Error("error loading Discord token, ", err)
@@ -231,18 +1420,84 @@ func main() {
 		return
 	}
 
+	slog.Info("features: ", features.Summary())
+	reactionSession = dg
+	usePersonas(dg)
+	dg.AddHandler(guildCreateHandler)
+	dg.AddHandler(guildReturnHandler)
+	dg.AddHandler(guildDeleteHandler)
 	dg.AddHandler(messageCreateHandler)
+	dg.AddHandler(messageUpdateHandler)
+	dg.AddHandler(messageDeleteHandler)
+	dg.AddHandler(threadDeleteHandler)
+	dg.AddHandler(voteAddHandler)
+	dg.AddHandler(voteRemoveHandler)
+	dg.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		componentRouter.Route(s, i)
+	})
+	dg.AddHandler(applicationCommandHandler)
+	if !cfg.Interactions.Only {
+		// prefix commands have to read what people write
+		dg.Identify.Intents |= discordgo.IntentMessageContent
+	}
+
+	// before the connection opens, so handlers see the view
+	startQueueView(dg)
+	left := readLeftovers()
+	left.handedOver = handedOver
 
 	err = dg.Open()
 	if err != nil {
 		slog.Error("error opening connection,", err)
 		return
 	}
+	registerApplicationCommands(dg)
+	resumeLeftovers(dg, left)
+
+	audio.SetWatchdog(&exec.Watchdog{
+		Queue:      &audioQueue,
+		StallAfter: cfg.Watchdog.StallAfter,
+		Kill:       cfg.Watchdog.Kill,
+		Requeue:    cfg.Watchdog.Requeue,
+		OnStall: func(info exec.TaskInfo, idle time.Duration, action string) {
+			alert.Send(dg, config.Get().Admin.AlertChannels, fmt.Sprintf("Job `%s` (`%s`) made no progress for %s; %s.", info.ID, info.Task.Prompt(), format.Duration(idle), action))
+		},
+	})
+
+	if err := startPromptOfTheDay(dg); err != nil {
+		slog.Error("error starting prompt of the day, ", err)
+	}
+	recurringJobs.Run = func(job recurring.Job) { runRecurringJob(dg, job) }
+	if err := recurringJobs.Load(time.Now()); err != nil {
+		slog.Error("error loading recurring jobs, ", err)
+	}
+	if server := startWebhookServer(dg); server != nil {
+		defer server.Close()
+	}
+	if server := startAPIServer(dg); server != nil {
+		defer server.Stop()
+	}
+	if server := startDashboard(dg); server != nil {
+		defer server.Close()
+	}
+
+	startPruning()
+
+	schedulerDone := make(chan struct{})
+	defer close(schedulerDone)
+	go scheduler.Start(schedulerDone)
 
 	fmt.Println("Bot is now running. Press CTRL-C to exit.")
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt)
-	<-stop
+	select {
+	case <-stop:
+	case <-leaseLost:
+	case <-handoff:
+		handOver(cfg.Handoff.Drain)
+	}
 
+	// disconnected before the lease is released, so the next process never
+	// sees events alongside this one
 	dg.Close()
 }
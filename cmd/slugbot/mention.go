@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/config"
+	"slugbot/internal/discord"
+	"slugbot/internal/intent"
+	"slugbot/internal/io/slog"
+)
+
+// how long an interpreted request waits for confirmation before it's dropped
+const mentionConfirmTimeout = 10 * time.Minute
+
+// pendingMention is an interpreted request waiting for its author to confirm it.
+type pendingMention struct {
+	message *discordgo.MessageCreate
+	command string
+}
+
+var pendingMentions = newPendingConfirmations[pendingMention]("This request has expired; mention me again to start over.")
+
+// isBotMention reports whether a message starts by mentioning the bot.
+func isBotMention(session *discordgo.Session, message *discordgo.MessageCreate) bool {
+	if session.State == nil || session.State.User == nil {
+		return false
+	}
+	botID := session.State.User.ID
+	content := strings.TrimSpace(message.Content)
+	return strings.HasPrefix(content, "<@"+botID+">") || strings.HasPrefix(content, "<@!"+botID+">")
+}
+
+// handleMention interprets a plain-language request and asks its author to confirm the resulting command.
+func handleMention(session *discordgo.Session, message *discordgo.MessageCreate) {
+	var req *intent.Request
+	var err error
+	if config.Get().NaturalLang.UseLLM {
+		req, err = intent.InterpretWithLLM(context.Background(), llmClient, message.Content)
+	} else {
+		req, err = intent.Interpret(message.Content)
+	}
+	if err != nil {
+		session.ChannelMessageSendReply(message.ChannelID, "Sorry, "+err.Error()+". Try something like \"make me 20 seconds of rainy jazz\".", message.Reference())
+		return
+	}
+
+	command := req.Command()
+	token := pendingMentions.add(pendingMention{message: message, command: command}, mentionConfirmTimeout)
+
+	slog.Info("interpreted mention from ", message.Author.ID, " as: ", command)
+
+	_, err = session.ChannelMessageSendComplex(message.ChannelID, &discordgo.MessageSend{
+		Content:   "I'll run:\n```\n" + command + "\n```",
+		Reference: message.Reference(),
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "Queue it", Style: discordgo.SuccessButton, CustomID: discord.ComponentID("mention-ok", token)},
+				discordgo.Button{Label: "Cancel", Style: discordgo.SecondaryButton, CustomID: discord.ComponentID("mention-cancel", token)},
+			}},
+		},
+	})
+	if err != nil {
+		slog.Error("couldn't send mention confirmation: ", err)
+	}
+}
+
+func registerMentionComponents(router *discord.ComponentRouter) {
+	router.Handle("mention-ok", func(s *discordgo.Session, i *discordgo.InteractionCreate, token string) error {
+		pending, ok := takePendingMention(s, i, token)
+		if !ok {
+			return nil
+		}
+		if err := resolveConfirmation(s, i, "Queued:\n```\n"+pending.command+"\n```"); err != nil {
+			return err
+		}
+
+		// run the interpreted command as if the user had typed it in their original message
+		msg := *pending.message.Message
+		msg.Content = pending.command
+		dispatch(s, &discordgo.MessageCreate{Message: &msg})
+		return nil
+	})
+
+	router.Handle("mention-cancel", func(s *discordgo.Session, i *discordgo.InteractionCreate, token string) error {
+		if _, ok := takePendingMention(s, i, token); !ok {
+			return nil
+		}
+		return resolveConfirmation(s, i, "Cancelled.")
+	})
+}
+
+// takePendingMention removes and returns the pending request if the clicking user is its author.
+// Otherwise it tells the clicker why nothing happened.
+func takePendingMention(s *discordgo.Session, i *discordgo.InteractionCreate, token string) (pendingMention, bool) {
+	return pendingMentions.take(s, i, token, func(pending pendingMention, userID string) bool {
+		return userID == pending.message.Author.ID
+	}, onlyAskerText)
+}
+
+// resolveConfirmation replaces the confirmation message's text and removes its buttons.
+func resolveConfirmation(s *discordgo.Session, i *discordgo.InteractionCreate, content string) error {
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+}
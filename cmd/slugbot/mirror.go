@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/config"
+	"slugbot/internal/fakesag"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/secrets"
+	"slugbot/internal/utils"
+)
+
+const mirrorUsage = `Usage: slugbot mirror
+
+Runs a staging bot, to try changes against real traffic before they're
+promoted. It connects with the mirror_token secret, so it sees the messages
+in whichever guilds that bot has been added to, and runs their commands as
+the bot would, except that:
+  - nothing is sent to Discord; what would have been is logged instead
+  - generations don't run sag; a stand-in writes fake progress and silence
+  - its store is a temporary one, so the real bot's state isn't touched
+  - the [llm] endpoint and email are turned off
+Stop it with CTRL-C.`
+
+// runMirror runs the staging bot until it's interrupted, and returns the
+// process exit code.
+func runMirror(configPath string, args []string, stdout io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprintln(stdout, mirrorUsage)
+		return 2
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintln(stdout, err)
+		return 1
+	}
+	token, err := secrets.Get(secrets.MirrorToken)
+	if err != nil {
+		fmt.Fprintf(stdout, "couldn't get the staging bot's token; set it with `slugbot secrets set %s`: %v\n", secrets.MirrorToken, err)
+		return 1
+	}
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(stdout, err)
+		return 1
+	}
+	storeDir, err := os.MkdirTemp(utils.TempDir(), "slugbot-mirror-*")
+	if err != nil {
+		fmt.Fprintln(stdout, err)
+		return 1
+	}
+	defer os.RemoveAll(storeDir)
+
+	cfg.Store.Dir = storeDir
+	cfg.LLM.Endpoint = ""
+	cfg.Notify.SMTPHost = ""
+	// sag is this binary, which runs as fake sag when it's told to
+	cfg.Tools.Sag = self
+	os.Setenv(fakesag.EnvVar, cfg.Mirror.StepTime.String())
+	config.Set(cfg)
+	if err := setupServices(cfg); err != nil {
+		fmt.Fprintln(stdout, "error opening store, ", err)
+		return 1
+	}
+
+	session, err := discordgo.New("Bot " + token)
+	if err != nil {
+		fmt.Fprintln(stdout, err)
+		return 1
+	}
+	local := &localDiscord{out: logLines{prefix: "mirror: "}, messages: map[string]*discordgo.Message{}}
+	session.Client = &http.Client{Transport: &mirrorDiscord{local: local, real: http.DefaultTransport}}
+	reactionSession = session
+	session.AddHandler(messageCreateHandler)
+	if !cfg.Interactions.Only {
+		session.Identify.Intents |= discordgo.IntentMessageContent
+	}
+	if err := session.Open(); err != nil {
+		fmt.Fprintln(stdout, "error opening connection, ", err)
+		return 1
+	}
+	defer session.Close()
+
+	fmt.Fprintln(stdout, "Mirroring. Press CTRL-C to exit.")
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+	return 0
+}
+
+// runFakeSag runs this binary as sag for the staging bot (see fakesag), and
+// returns the process exit code.
+func runFakeSag(stepTime string, args []string) int {
+	step, _ := time.ParseDuration(stepTime)
+	if err := fakesag.Run(args, os.Stdin, step); err != nil {
+		fmt.Fprintln(os.Stderr, "fake sag:", err)
+		return 1
+	}
+	return 0
+}
+
+// mirrorDiscord is a read-only view of Discord's REST API for the staging
+// bot. Reads go to Discord, so commands see real messages and attachments;
+// anything that would change something goes to a localDiscord, which logs
+// it, as do reads of the messages it made up.
+type mirrorDiscord struct {
+	local *localDiscord
+	real  http.RoundTripper
+}
+
+func (m *mirrorDiscord) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method == http.MethodGet && !m.local.made(r.URL.Path) {
+		return m.real.RoundTrip(r)
+	}
+	return m.local.RoundTrip(r)
+}
+
+// logLines logs each line written to it.
+type logLines struct {
+	prefix string
+}
+
+func (w logLines) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		slog.Info(w.prefix, line)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,10 @@
+//go:build noapi
+
+package main
+
+import "github.com/bwmarrin/discordgo"
+
+// startAPIServer does nothing; the control API was left out of the build.
+func startAPIServer(session *discordgo.Session) stopper {
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build nodashboard
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// startDashboard does nothing; the dashboard was left out of the build.
+func startDashboard(session *discordgo.Session) *http.Server {
+	return nil
+}
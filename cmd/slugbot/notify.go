@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/config"
+	"slugbot/internal/exec"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/notify"
+	"slugbot/internal/secrets"
+)
+
+// notifyTimeout is how long a notification may take before it's given up on.
+const notifyTimeout = 30 * time.Second
+
+// notifyFinished tells a job's submitter it finished, if they asked to be
+// told and it took long enough. A message's jobs are reported together, once
+// the last of them finishes.
+func notifyFinished(task exec.Task, err error) {
+	triggered, ok := task.(interface {
+		TriggerMessage() *discordgo.MessageCreate
+	})
+	if reactionSession == nil || !ok || triggered.TriggerMessage() == nil || triggered.TriggerMessage().Author == nil {
+		return
+	}
+	message := triggered.TriggerMessage()
+	cfg := config.Get().Notify
+	took := time.Since(message.Timestamp)
+	// the finishing job itself still counts as running
+	if took < cfg.After || len(audioQueue.JobIDs(message.ID)) > 1 {
+		return
+	}
+
+	userID := message.Author.ID
+	userPref, prefErr := userPrefs.Get(userID)
+	if prefErr != nil {
+		slog.Warn("couldn't load preferences of ", userID, ": ", prefErr)
+	}
+	providers := notify.Providers{Session: reactionSession, SMTP: notify.SMTP{Addr: cfg.SMTPHost, Username: cfg.SMTPUser, From: cfg.From}}
+	if cfg.SMTPUser != "" {
+		providers.SMTP.Password, _ = secrets.Get(secrets.SMTPPassword)
+	}
+	notifier := providers.For(userPref)
+	if notifier == nil {
+		return
+	}
+
+	notice := notify.Notice{
+		UserID:    userID,
+		GuildID:   message.GuildID,
+		ChannelID: message.ChannelID,
+		MessageID: message.ID,
+		Prompt:    task.Prompt(),
+		Err:       err,
+		Took:      took,
+	}
+	log := slog.With("trace", task.TraceID())
+	// webhooks and mail servers can be slow, and the queue shouldn't wait on them
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if err := notifier.Notify(ctx, notice); err != nil {
+			log.Warn("couldn't notify user ", userID, " by ", userPref.Notify, ": ", err)
+			return
+		}
+		log.Info("notified user ", userID, " by ", userPref.Notify)
+	}()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/commands/traits"
+	"slugbot/internal/discord"
+)
+
+// onlyAskerText is told to anyone but a request's author who answers its confirmation.
+const onlyAskerText = "Only the person who asked can confirm this."
+
+// pendingConfirmations holds requests waiting on someone to press one of
+// their confirmation's buttons, by the token in the buttons' IDs.
+type pendingConfirmations[T any] struct {
+	expiredText string // told to whoever answers a confirmation after it expires
+
+	mutex   sync.Mutex
+	byToken map[string]pendingConfirmation[T]
+}
+
+type pendingConfirmation[T any] struct {
+	value   T
+	expires time.Time
+}
+
+func newPendingConfirmations[T any](expiredText string) *pendingConfirmations[T] {
+	return &pendingConfirmations[T]{expiredText: expiredText, byToken: map[string]pendingConfirmation[T]{}}
+}
+
+// add holds value for timeout, dropping any held values that have expired,
+// and returns the token to answer it with.
+func (p *pendingConfirmations[T]) add(value T, timeout time.Duration) string {
+	token := traits.NewTraceID()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for key, pending := range p.byToken {
+		if time.Now().After(pending.expires) {
+			delete(p.byToken, key)
+		}
+	}
+	p.byToken[token] = pendingConfirmation[T]{value: value, expires: time.Now().Add(timeout)}
+	return token
+}
+
+// take removes and returns the value held for token if the clicking user may
+// answer it. Otherwise it tells the clicker why nothing happened, with
+// refusedText if they may not.
+func (p *pendingConfirmations[T]) take(s *discordgo.Session, i *discordgo.InteractionCreate, token string, mayAnswer func(value T, userID string) bool, refusedText string) (T, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var zero T
+	pending, ok := p.byToken[token]
+	if !ok || time.Now().After(pending.expires) {
+		delete(p.byToken, token)
+		discord.RespondEphemeral(s, i, p.expiredText)
+		return zero, false
+	}
+	if !mayAnswer(pending.value, discord.InteractionUserID(i)) {
+		discord.RespondEphemeral(s, i, refusedText)
+		return zero, false
+	}
+	delete(p.byToken, token)
+	return pending.value, true
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"slugbot/internal/discord"
+	"slugbot/internal/io/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// usePersonas styles the bot's messages with the persona of the guild each
+// one is sent to.
+func usePersonas(session *discordgo.Session) {
+	guildOf := func(channelID string) string {
+		if session.State == nil {
+			return ""
+		}
+		channel, err := session.State.Channel(channelID)
+		if err != nil {
+			return ""
+		}
+		return channel.GuildID
+	}
+	discord.UsePersonas(guildOf, guildPersona)
+}
+
+// guildPersona returns the persona of a guild, logging rather than failing
+// on a bad color.
+func guildPersona(guildID string) discord.Persona {
+	persona, err := guildPolicies.Persona(guildID)
+	if err != nil {
+		slog.Warn("couldn't load persona policy: ", err)
+	}
+	color := 0
+	if persona.Color != "" {
+		if color, err = discord.ParseColor(persona.Color); err != nil {
+			slog.Warn("ignoring persona color for guild ", guildID, ": ", err)
+		}
+	}
+	return discord.Persona{Nickname: persona.Nickname, Color: color}
+}
+
+// guildCreateHandler sets the bot's nickname in each guild it joins or
+// reconnects to, in case it was changed by hand or the persona changed while
+// the bot was down.
+func guildCreateHandler(session *discordgo.Session, guild *discordgo.GuildCreate) {
+	if err := discord.ApplyNickname(session, guild.ID); err != nil {
+		slog.Warn("couldn't set nickname in guild ", guild.ID, ": ", err)
+	}
+}
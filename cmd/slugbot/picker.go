@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/discord"
+	"slugbot/internal/io/slog"
+)
+
+// discord limits select-option descriptions and text-input placeholders to 100 characters
+const pickerTextMaxLen = 100
+
+// simUsage describes a `.sim` operation in plain text for the picker.
+func simUsage(name string) string {
+	usage := simCommandHandlers[name]().Usage()
+	usage = strings.TrimPrefix(usage, "Usage: ")
+	usage = strings.ReplaceAll(usage, "`", "")
+	if r := []rune(usage); len(r) > pickerTextMaxLen {
+		usage = string(r[:pickerTextMaxLen-1]) + "…"
+	}
+	return usage
+}
+
+// sendSimPicker answers a bare `.sim` with a menu of the image operations.
+func sendSimPicker(session *discordgo.Session, message *discordgo.MessageCreate) error {
+	_, err := session.ChannelMessageSendComplex(message.ChannelID, &discordgo.MessageSend{
+		Content:    "Pick an image operation to run on the most recent image:",
+		Reference:  message.Reference(),
+		Components: simPickerComponents(""),
+	})
+	return err
+}
+
+// simPickerComponents is the menu of image operations. targetID is the
+// message whose image they run on, remembered in interactionTargets, or "" for the
+// channel's most recent image.
+func simPickerComponents(targetID string) []discordgo.MessageComponent {
+	names := make([]string, 0, len(simCommandHandlers))
+	for name := range simCommandHandlers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	options := make([]discordgo.SelectMenuOption, len(names))
+	for i, name := range names {
+		options[i] = discordgo.SelectMenuOption{Label: name, Value: name, Description: simUsage(name)}
+	}
+	if targetID == "" {
+		targetID = "menu"
+	}
+
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{
+				CustomID:    discord.ComponentID("sim-pick", targetID),
+				Placeholder: "Choose an operation",
+				Options:     options,
+			},
+		}},
+	}
+}
+
+func registerSimPickerComponents(router *discord.ComponentRouter) {
+	// choosing an operation asks for its arguments
+	router.Handle("sim-pick", func(s *discordgo.Session, i *discordgo.InteractionCreate, targetID string) error {
+		values := i.MessageComponentData().Values
+		if len(values) != 1 {
+			return fmt.Errorf("expected one choice, got %d", len(values))
+		}
+		name := values[0]
+		if _, ok := simCommandHandlers[name]; !ok {
+			return discord.RespondEphemeral(s, i, "That operation isn't available anymore.")
+		}
+		customID := discord.ComponentID("sim-args", name)
+		if targetID != "menu" {
+			customID += "/" + targetID
+		}
+
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseModal,
+			Data: &discordgo.InteractionResponseData{
+				CustomID: customID,
+				Title:    ".sim " + name,
+				Components: []discordgo.MessageComponent{
+					discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "args",
+							Label:       "Arguments",
+							Style:       discordgo.TextInputShort,
+							Placeholder: simUsage(name),
+							Required:    false,
+							MaxLength:   200,
+						},
+					}},
+				},
+			},
+		})
+	})
+
+	// submitting the arguments runs the command as if the user had typed it
+	router.Handle("sim-args", func(s *discordgo.Session, i *discordgo.InteractionCreate, arg string) error {
+		name, targetID, _ := strings.Cut(arg, "/")
+		if _, ok := simCommandHandlers[name]; !ok {
+			return discord.RespondEphemeral(s, i, "That operation isn't available anymore.")
+		}
+		user := discord.InteractionUser(i)
+		if user == nil || i.Message == nil {
+			return fmt.Errorf("missing user or message on modal submission")
+		}
+
+		// the picker message stands in for the trigger; it has no attachments,
+		// so the command falls back to the channel's most recent image, unless
+		// the picker was opened on a message, whose files are carried over
+		trigger := &discordgo.Message{
+			ID:        i.Message.ID,
+			ChannelID: i.ChannelID,
+			GuildID:   i.GuildID,
+			Author:    user,
+			Content:   strings.TrimSpace(".sim " + name + " " + modalValue(i.ModalSubmitData(), "args")),
+		}
+		source := "the most recent image"
+		if targetID != "" {
+			target := interactionTargets.get(targetID)
+			if target == nil {
+				return discord.RespondEphemeral(s, i, "This menu has expired; use Distort image on the message again.")
+			}
+			trigger.Attachments, trigger.Embeds = target.Attachments, target.Embeds
+			source = "the chosen image"
+		}
+
+		if err := discord.RespondEphemeral(s, i, "Running `"+trigger.Content+"` on "+source+"..."); err != nil {
+			return err
+		}
+		slog.Info("running picked command for ", user.ID, ": ", trigger.Content)
+		dispatch(s, &discordgo.MessageCreate{Message: trigger})
+		return nil
+	})
+}
+
+// modalValue returns the value of the text input with the given custom ID in a modal submission.
+func modalValue(data discordgo.ModalSubmitInteractionData, customID string) string {
+	for _, component := range data.Components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, inner := range row.Components {
+			if input, ok := inner.(*discordgo.TextInput); ok && input.CustomID == customID {
+				return input.Value
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/discord"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/ratings"
+	"slugbot/internal/results"
+	"slugbot/internal/store"
+)
+
+// submitterTTL is how long a command's results can still be held back for,
+// or attributed to, its submitter; it's well past how long a job sits in the queue.
+const submitterTTL = 24 * time.Hour
+
+// submitters remembers who sent each command, by the command's message ID,
+// since results only know what they reply to.
+var submitters = struct {
+	sync.Mutex
+	users map[string]submitter
+}{users: map[string]submitter{}}
+
+type submitter struct {
+	userID  string
+	preview bool // whether the command's results wait for its submitter's approval
+	added   time.Time
+}
+
+// recordSubmitter remembers who sent a command, and whether its guild
+// previews results. Admin commands are always answered in the channel.
+func recordSubmitter(message *discordgo.MessageCreate) {
+	if message.GuildID == "" || message.Author == nil {
+		return
+	}
+	preview := false
+	if !strings.HasPrefix(message.Content, ".sadmin") {
+		var err error
+		if preview, err = guildPolicies.ResultPreview(message.GuildID); err != nil {
+			slog.Warn(err)
+		}
+	}
+
+	submitters.Lock()
+	defer submitters.Unlock()
+	for id, entry := range submitters.users {
+		if time.Since(entry.added) > submitterTTL {
+			delete(submitters.users, id)
+		}
+	}
+	submitters.users[message.ID] = submitter{userID: message.Author.ID, preview: preview, added: time.Now()}
+}
+
+// resultSubmitter returns who has to approve a result replying to a command,
+// or "" if it's posted right away.
+func resultSubmitter(channelID string, replyToID string) string {
+	entry, ok := lookupSubmitter(replyToID)
+	if !ok || !entry.preview {
+		return ""
+	}
+	return entry.userID
+}
+
+// resultOwner returns who sent the command a result replies to, or "" if
+// it isn't known.
+func resultOwner(channelID string, replyToID string) string {
+	entry, _ := lookupSubmitter(replyToID)
+	return entry.userID
+}
+
+func lookupSubmitter(messageID string) (submitter, bool) {
+	submitters.Lock()
+	defer submitters.Unlock()
+	entry, ok := submitters.users[messageID]
+	if !ok || time.Since(entry.added) > submitterTTL {
+		return submitter{}, false
+	}
+	return entry, true
+}
+
+// publishing holds the IDs of the previews being posted, so a double click
+// doesn't post one twice.
+var publishing = struct {
+	sync.Mutex
+	ids map[string]bool
+}{ids: map[string]bool{}}
+
+func registerPreviewComponents(router *discord.ComponentRouter) {
+	router.Handle(discord.PublishPrefix, func(s *discordgo.Session, i *discordgo.InteractionCreate, id string) error {
+		publishing.Lock()
+		busy := publishing.ids[id]
+		publishing.ids[id] = true
+		publishing.Unlock()
+		if busy {
+			return discord.RespondEphemeral(s, i, "This result is already being published.")
+		}
+		defer func() {
+			publishing.Lock()
+			delete(publishing.ids, id)
+			publishing.Unlock()
+		}()
+
+		// the upload can take longer than Discord waits for a response
+		if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredMessageUpdate,
+		}); err != nil {
+			return err
+		}
+
+		content := "Published."
+		msg, entry, err := discord.Publish(s, id)
+		if errors.Is(err, store.ErrNotFound) {
+			content = "This result has already been published or discarded."
+		} else if err != nil {
+			slog.Warn("couldn't publish result ", id, ": ", err)
+			_, err = s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+				Content: "Publishing failed; try again later.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			})
+			return err
+		} else {
+			slog.Info("published result ", entry.ID, " in channel ", entry.ChannelID)
+			if err := resultArchive.Mirror(s, "", msg, results.Fields{}); err != nil {
+				slog.Warn("couldn't archive result ", entry.ID, ": ", err)
+			}
+			trackPublishedVotes(s, msg, discord.InteractionUserID(i))
+			content = "Published in <#" + entry.ChannelID + ">."
+		}
+
+		_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content:    &content,
+			Components: &[]discordgo.MessageComponent{},
+		})
+		return err
+	})
+
+	router.Handle(discord.DiscardPrefix, func(s *discordgo.Session, i *discordgo.InteractionCreate, id string) error {
+		content := "Discarded."
+		if err := discord.Discard(id); errors.Is(err, store.ErrNotFound) {
+			content = "This result has already been published or discarded."
+		} else if err != nil {
+			return err
+		}
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    content,
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+	})
+}
+
+// trackPublishedVotes starts tracking the votes on a published result, which
+// only the person who asked for it can publish.
+func trackPublishedVotes(session *discordgo.Session, msg *discordgo.Message, submitterID string) {
+	channel, err := commands.LookupChannel(session, msg.ChannelID)
+	if err != nil {
+		slog.Warn("couldn't look up the channel of published result ", msg.ID, ": ", err)
+		return
+	}
+	if err := resultVotes.Add(ratings.Result{
+		GuildID:   channel.GuildID,
+		ChannelID: msg.ChannelID,
+		MessageID: msg.ID,
+		UserID:    submitterID,
+		Posted:    time.Now(),
+	}); err != nil {
+		slog.Warn("couldn't track votes on published result ", msg.ID, ": ", err)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/config"
+	"slugbot/internal/exec"
+	"slugbot/internal/format"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/prune"
+	"slugbot/internal/schedule"
+)
+
+var guildDepartures = &prune.Departures{}
+
+// guildDeleteHandler schedules a guild's data to be pruned once the bot has
+// been removed from it. Guilds that only became unavailable, in an outage,
+// are left alone.
+func guildDeleteHandler(session *discordgo.Session, guild *discordgo.GuildDelete) {
+	grace := config.Get().Prune.Grace
+	if guild.Unavailable || grace <= 0 {
+		return
+	}
+	if err := guildDepartures.Left(guild.ID, time.Now()); err != nil {
+		slog.Error("couldn't schedule pruning of guild ", guild.ID, ": ", err)
+		return
+	}
+	slog.Info("removed from guild ", guild.ID, "; its data will be pruned in ", format.Duration(grace))
+}
+
+// guildReturnHandler keeps the data of a guild that added the bot back before it was pruned.
+func guildReturnHandler(session *discordgo.Session, guild *discordgo.GuildCreate) {
+	returned, err := guildDepartures.Returned(guild.ID)
+	if err != nil {
+		slog.Error("couldn't cancel pruning of guild ", guild.ID, ": ", err)
+	} else if returned {
+		slog.Info("added back to guild ", guild.ID, "; keeping its data")
+	}
+}
+
+// startPruning prunes the guilds that have been gone long enough now and
+// then every hour.
+func startPruning() {
+	if config.Get().Prune.Grace <= 0 {
+		return
+	}
+	hourly, _ := schedule.ParseCron("0 * * * *")
+	scheduler.Add("guild-prune", hourly, pruneDepartedGuilds)
+	go pruneDepartedGuilds(time.Now())
+}
+
+// pruneDepartedGuilds prunes every guild that's been gone longer than the
+// grace period. A guild that's only partly pruned is tried again next time.
+func pruneDepartedGuilds(now time.Time) {
+	due, err := guildDepartures.Due(now)
+	if err != nil {
+		slog.Error("couldn't list guilds to prune: ", err)
+		return
+	}
+	for _, guildID := range due {
+		purged, err := pruneGuild(guildID)
+		if err != nil {
+			slog.Error("couldn't prune all of guild ", guildID, ", will try again: ", err)
+		}
+		slog.Info("pruned guild ", guildID, ": ", strings.Join(purged, ", "))
+		if err != nil {
+			continue
+		}
+		if err := guildDepartures.Done(guildID); err != nil {
+			slog.Warn("couldn't mark guild ", guildID, " as pruned: ", err)
+		}
+	}
+}
+
+// pruneGuild deletes everything stored about a guild, and returns what it deleted.
+func pruneGuild(guildID string) ([]string, error) {
+	var purged []string
+	var errs []error
+	count := func(what string, n int, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", what, err))
+		}
+		purged = append(purged, fmt.Sprintf("%d %s", n, what))
+	}
+
+	n, err := guildPolicies.ForgetGuild(guildID)
+	count("setting(s)", n, err)
+	n, err = presetCatalog.ForgetGuild(guildID)
+	count("preset(s)", n, err)
+	n, err = pruneRecurringJobs(guildID)
+	count("recurring job(s)", n, err)
+	n, err = dailyEvents.RemoveGuild(guildID)
+	count("prompt-of-the-day round(s)", n, err)
+	n, err = resultVotes.RemoveGuild(guildID)
+	count("voted result(s)", n, err)
+	n, err = auditLog.PurgeGuild(guildID)
+	count("audit entry(ies)", n, err)
+	if err := usageStats.ForgetGuild(guildID); err != nil {
+		errs = append(errs, fmt.Errorf("usage analytics: %w", err))
+	}
+	jobs, files := pruneJobs(guildID)
+	purged = append(purged, fmt.Sprintf("%d finished job(s)", jobs), fmt.Sprintf("%d output file(s)", files))
+	return purged, errors.Join(errs...)
+}
+
+// pruneRecurringJobs unschedules and deletes a guild's recurring jobs.
+func pruneRecurringJobs(guildID string) (int, error) {
+	jobs, err := recurringJobs.List(guildID)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, job := range jobs {
+		ok, err := recurringJobs.Remove(guildID, job.Name)
+		if err != nil {
+			return removed, err
+		}
+		if ok {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// pruneJobs removes a guild's finished jobs and their output files, and
+// returns how many of each it removed.
+func pruneJobs(guildID string) (int, int) {
+	jobs, files := 0, 0
+	for _, info := range audioQueue.History() {
+		if taskGuild(info.Task) != guildID {
+			continue
+		}
+		if producing, ok := info.Task.(exec.Producing); ok {
+			for _, output := range producing.Outputs() {
+				if err := os.Remove(output); err == nil {
+					files++
+				} else if !errors.Is(err, os.ErrNotExist) {
+					slog.Warn("couldn't remove ", output, ": ", err)
+				}
+			}
+		}
+		if audioQueue.Forget(info.ID) {
+			jobs++
+		}
+	}
+	return jobs, files
+}
+
+// taskGuild returns the ID of the guild a job was submitted in.
+func taskGuild(task exec.Task) string {
+	triggered, ok := task.(interface {
+		TriggerMessage() *discordgo.MessageCreate
+	})
+	if !ok || triggered.TriggerMessage() == nil || triggered.TriggerMessage().Message == nil {
+		return ""
+	}
+	return triggered.TriggerMessage().GuildID
+}
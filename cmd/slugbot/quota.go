@@ -0,0 +1,57 @@
+package main
+
+import (
+	"slugbot/internal/commands/audio"
+	"slugbot/internal/exec"
+)
+
+// admitJobs refuses jobs their submitter doesn't have the disk quota or the
+// credits for, given the jobs already queued.
+func admitJobs(tasks []exec.Task, queued []exec.TaskInfo) error {
+	if err := admitQuota(tasks, queued); err != nil {
+		return err
+	}
+	return admitCredits(tasks, queued)
+}
+
+// admitQuota refuses jobs whose results wouldn't fit in their submitter's
+// disk quota, counting the jobs they already have waiting or running, whose
+// results aren't on disk yet. A job stops counting once it leaves the queue,
+// so one that's cancelled or fails gives its share back.
+func admitQuota(tasks []exec.Task, queued []exec.TaskInfo) error {
+	if userQuota == nil || len(tasks) == 0 {
+		return nil
+	}
+	userID := taskOwner(tasks[0])
+	if userID == "" {
+		return nil
+	}
+
+	var expected int64
+	for _, task := range tasks {
+		expected += jobBytes(task)
+	}
+	if expected == 0 {
+		return nil
+	}
+	for _, info := range queued {
+		if taskOwner(info.Task) == userID {
+			expected += jobBytes(info.Task)
+		}
+	}
+	return userQuota.CheckFits(userID, expected)
+}
+
+// jobBytes estimates how much disk a job's results will take up, or 0 for
+// jobs that don't say.
+func jobBytes(task exec.Task) int64 {
+	estimable, ok := task.(exec.Estimable)
+	if !ok {
+		return 0
+	}
+	shape, ok := estimable.Shape()
+	if !ok {
+		return 0
+	}
+	return int64(shape.Length * audio.WAVBytesPerSecond)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"slugbot/internal/config"
+	"slugbot/internal/exec"
+	"slugbot/internal/io/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// reactionSession adds the acknowledgement reactions of finished jobs; it's
+// set once the bot has connected.
+var reactionSession *discordgo.Session
+
+// guildReactions returns the acknowledgement reactions for a message's guild,
+// or false if they're turned off there or its author is in quiet mode.
+func guildReactions(message *discordgo.MessageCreate) (config.Reactions, bool) {
+	if isQuiet(message.Author) {
+		return config.Reactions{}, false
+	}
+	reactions, err := guildPolicies.Reactions(message.GuildID)
+	if err != nil {
+		slog.Warn("couldn't load reactions policy: ", err)
+	}
+	return reactions, reactions.Enabled
+}
+
+// react adds a reaction to a message, if there's an emoji for it.
+func react(session *discordgo.Session, message *discordgo.MessageCreate, emoji string) {
+	if emoji == "" {
+		return
+	}
+	if err := session.MessageReactionAdd(message.ChannelID, message.ID, emoji); err != nil {
+		slog.Warn("couldn't react to message ", message.ID, ": ", err)
+	}
+}
+
+// unreact takes the bot's own reaction off a message.
+func unreact(session *discordgo.Session, message *discordgo.MessageCreate, emoji string) {
+	if emoji == "" {
+		return
+	}
+	if err := session.MessageReactionRemove(message.ChannelID, message.ID, emoji, "@me"); err != nil {
+		slog.Warn("couldn't remove reaction from message ", message.ID, ": ", err)
+	}
+}
+
+// reactQueued acknowledges a message whose jobs were just queued.
+func reactQueued(session *discordgo.Session, message *discordgo.MessageCreate) {
+	if reactions, ok := guildReactions(message); ok {
+		react(session, message, reactions.Queued)
+	}
+}
+
+// reactRefused marks a message whose jobs the queue turned away.
+func reactRefused(session *discordgo.Session, message *discordgo.MessageCreate) {
+	if reactions, ok := guildReactions(message); ok {
+		react(session, message, reactions.Failed)
+	}
+}
+
+// reactFinished marks a job's trigger as succeeded or failed. The queued
+// reaction comes off once the message has no other jobs waiting, or as soon
+// as one of them fails.
+func reactFinished(task exec.Task, err error) {
+	triggered, ok := task.(interface {
+		TriggerMessage() *discordgo.MessageCreate
+	})
+	if reactionSession == nil || !ok || triggered.TriggerMessage() == nil {
+		return
+	}
+	message := triggered.TriggerMessage()
+	reactions, ok := guildReactions(message)
+	if !ok {
+		return
+	}
+
+	// the finishing job itself still counts as running
+	if err != nil || len(audioQueue.JobIDs(message.ID)) <= 1 {
+		unreact(reactionSession, message, reactions.Queued)
+	}
+	if err != nil {
+		react(reactionSession, message, reactions.Failed)
+	} else {
+		react(reactionSession, message, reactions.Succeeded)
+	}
+}
+
+// voteAddHandler counts a 👍 or 👎 added to one of the bot's results.
+func voteAddHandler(session *discordgo.Session, reaction *discordgo.MessageReactionAdd) {
+	countVote(session, reaction.MessageReaction, 1)
+}
+
+// voteRemoveHandler takes back a 👍 or 👎 removed from one of the bot's results.
+func voteRemoveHandler(session *discordgo.Session, reaction *discordgo.MessageReactionRemove) {
+	countVote(session, reaction.MessageReaction, -1)
+}
+
+func countVote(session *discordgo.Session, reaction *discordgo.MessageReaction, delta int) {
+	// the bot's own reactions aren't votes
+	if session.State != nil && session.State.User != nil && reaction.UserID == session.State.User.ID {
+		return
+	}
+	if err := resultVotes.Vote(reaction.GuildID, reaction.MessageID, reaction.UserID, reaction.Emoji.Name, delta); err != nil {
+		slog.Warn("couldn't count vote on message ", reaction.MessageID, ": ", err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/discord"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/results"
+	"slugbot/internal/store"
+)
+
+// redelivering holds the IDs of the results being resent, so a double click
+// doesn't post one twice.
+var redelivering = struct {
+	sync.Mutex
+	ids map[string]bool
+}{ids: map[string]bool{}}
+
+func registerRedeliverComponents(router *discord.ComponentRouter) {
+	router.Handle(discord.RedeliverPrefix, func(s *discordgo.Session, i *discordgo.InteractionCreate, id string) error {
+		redelivering.Lock()
+		busy := redelivering.ids[id]
+		redelivering.ids[id] = true
+		redelivering.Unlock()
+		if busy {
+			return discord.RespondEphemeral(s, i, "This result is already being resent.")
+		}
+		defer func() {
+			redelivering.Lock()
+			delete(redelivering.ids, id)
+			redelivering.Unlock()
+		}()
+
+		// the upload can take longer than Discord waits for a response
+		if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredMessageUpdate,
+		}); err != nil {
+			return err
+		}
+
+		content := "Delivered."
+		msg, entry, err := discord.Redeliver(s, id)
+		if errors.Is(err, store.ErrNotFound) {
+			content = "This result has already been delivered or deleted."
+		} else if err != nil {
+			slog.Warn("couldn't redeliver result ", id, ": ", err)
+			_, err = s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+				Content: "Resending failed again; try once more later.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			})
+			return err
+		} else {
+			slog.Info("redelivered result ", entry.ID, " in channel ", entry.ChannelID)
+			if err := resultArchive.Mirror(s, "", msg, results.Fields{}); err != nil {
+				slog.Warn("couldn't archive result ", entry.ID, ": ", err)
+			}
+		}
+
+		_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content:    &content,
+			Components: &[]discordgo.MessageComponent{},
+		})
+		return err
+	})
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/config"
+	"slugbot/internal/discord"
+	"slugbot/internal/exec"
+	"slugbot/internal/io/slog"
+)
+
+// maxReportedJobs is how many lost or requeued jobs the resumption report
+// lists before summing up the rest, to stay within a message.
+const maxReportedJobs = 10
+
+// maxReportedResults is how many undelivered results get a message with a
+// delivery button of their own; the rest are left to `.sadmin redeliver`.
+const maxReportedResults = 10
+
+// leftovers is the work the last run didn't finish, read before the bot
+// connects, while nothing new can be queued or uploaded.
+type leftovers struct {
+	jobs     []exec.JournalEntry
+	uploads  []discord.Undelivered
+	progress []discord.LeftoverProgress
+
+	// handedOver is set when the last run handed over to this one, so its
+	// waiting jobs weren't cut off, and are queued again whatever the limit
+	handedOver bool
+}
+
+// readLeftovers collects the jobs and uploads the last run didn't finish.
+func readLeftovers() leftovers {
+	var left leftovers
+	var err error
+	if left.jobs, err = audioQueue.Journal.Recover(); err != nil {
+		slog.Warn("couldn't read every unfinished job from the last run: ", err)
+	}
+	if left.uploads, err = discord.RecoverUploads(); err != nil {
+		slog.Warn("couldn't save every interrupted upload from the last run: ", err)
+	}
+	if left.progress, err = discord.LeftoverProgressMessages(); err != nil {
+		slog.Warn("couldn't read every progress message left from the last run: ", err)
+	}
+	return left
+}
+
+// resumeLeftovers queues the last run's unfinished jobs again where it can,
+// tells each job's progress message whether it was requeued or has to be sent
+// again, and reports which were lost, which were requeued, and which results
+// were left undelivered to the admin alert channels.
+func resumeLeftovers(session *discordgo.Session, left leftovers) {
+	resumed := map[string]bool{} // trigger message IDs of the requeued jobs
+	defer recoverProgress(session, left.progress, resumed)
+	if len(left.jobs) == 0 && len(left.uploads) == 0 {
+		return
+	}
+
+	var lost, requeued []string
+	seen := map[string]bool{}
+	for _, job := range left.jobs {
+		// the stages of a chain share their trigger, and run again together
+		if seen[job.MessageID] {
+			continue
+		}
+		seen[job.MessageID] = true
+
+		line := describeLeftover(job)
+		resume := resumeJob
+		if left.handedOver && job.State == exec.StateWaiting {
+			resume = requeueJob
+		}
+		if reason := resume(session, job); reason != "" {
+			slog.Warn("lost job ", job.JobID, " from the last run: ", reason)
+			lost = append(lost, line+": "+reason)
+		} else {
+			slog.Info("requeued job ", job.JobID, " from the last run")
+			resumed[job.MessageID] = true
+			requeued = append(requeued, line)
+		}
+	}
+
+	report := []string{"Restarted with unfinished work from the last run."}
+	if left.handedOver {
+		report = []string{"Took over from the last bot process, with its unfinished work."}
+	}
+	report = appendSection(report, "Lost", lost)
+	report = appendSection(report, "Requeued", requeued)
+	if len(left.uploads) > 0 {
+		report = append(report, fmt.Sprintf("**Never delivered** (%d): results that were on disk when their upload was cut off; each has a button below.", len(left.uploads)))
+	}
+	text := truncateRunes(strings.Join(report, "\n"), 2000)
+
+	for _, channelID := range config.Get().Admin.AlertChannels {
+		if _, err := session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			Content:         text,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		}); err != nil {
+			slog.Error("couldn't send the resumption report to channel ", channelID, ": ", err)
+			continue
+		}
+		offerRedelivery(session, channelID, left.uploads)
+	}
+}
+
+// recoverProgress replaces the progress messages the last run left behind
+// with what became of their jobs.
+func recoverProgress(session *discordgo.Session, progress []discord.LeftoverProgress, resumed map[string]bool) {
+	n, err := discord.RecoverProgressMessages(discord.ConcreteSession{Session: session}, progress, func(triggerID string) bool { return resumed[triggerID] })
+	if err != nil {
+		slog.Warn("couldn't clean up every progress message left from the last run: ", err)
+	} else if n > 0 {
+		slog.Info("updated ", n, " progress message(s) from the last run")
+	}
+}
+
+// resumeJob dispatches a lost job's trigger again, or returns why it can't be.
+func resumeJob(session *discordgo.Session, job exec.JournalEntry) string {
+	limit := config.Get().Queue.Resume
+	if job.Resumed >= limit {
+		if limit == 0 {
+			return "resuming is off"
+		}
+		return fmt.Sprintf("it was already cut off by %d restart(s)", job.Resumed+1)
+	}
+	return requeueJob(session, job)
+}
+
+// requeueJob dispatches a job's trigger again, or returns why it can't be.
+func requeueJob(session *discordgo.Session, job exec.JournalEntry) string {
+	current, err := session.ChannelMessage(job.ChannelID, job.MessageID)
+	if err != nil {
+		return "its message is gone"
+	}
+
+	trigger := job.Trigger()
+	if trigger.Author == nil {
+		return "it doesn't say who asked for it"
+	}
+	// attachment links expire, so fresh ones are better when there are any
+	if len(current.Attachments) > 0 {
+		trigger.Attachments = current.Attachments
+	}
+	dispatchUnattended(session, trigger)
+	return queuedAgain(job.MessageID)
+}
+
+// restoreJob dispatches the message a job from a queue dump was triggered by,
+// or returns why it can't be. A dump can be edited by hand, so it only picks
+// the message: who asked, and for what, come from the message itself.
+func restoreJob(session *discordgo.Session, job exec.JournalEntry) string {
+	current, err := session.ChannelMessage(job.ChannelID, job.MessageID)
+	if err != nil {
+		return "its message is gone"
+	}
+	if current.Author == nil || current.Author.Bot {
+		return "its message wasn't sent by a user"
+	}
+	// fetched messages don't say which guild they're in
+	if channel, err := commands.LookupChannel(session, current.ChannelID); err == nil {
+		current.GuildID = channel.GuildID
+	}
+	dispatchUnattended(session, &discordgo.MessageCreate{Message: current})
+	return queuedAgain(job.MessageID)
+}
+
+// queuedAgain returns "" if a job triggered by messageID is in the queue, or
+// else why it isn't.
+func queuedAgain(messageID string) string {
+	for _, info := range audioQueue.Jobs() {
+		if triggered, ok := info.Task.(exec.Triggered); ok && triggered.MessageID() == messageID {
+			return ""
+		}
+	}
+	return "it couldn't be queued again"
+}
+
+// describeLeftover names a lost job, where it came from, and who asked for it.
+func describeLeftover(job exec.JournalEntry) string {
+	line := fmt.Sprintf("`%s` (`%s`, %s) in <#%s>", job.JobID, truncateRunes(strings.ReplaceAll(job.Prompt, "`", "'"), 60), job.State, job.ChannelID)
+	if job.Author != nil {
+		line += " for " + job.Author.Mention()
+	}
+	return line
+}
+
+// appendSection adds a titled list to a report, if there's anything in it.
+func appendSection(report []string, title string, lines []string) []string {
+	if len(lines) == 0 {
+		return report
+	}
+	report = append(report, fmt.Sprintf("**%s** (%d):", title, len(lines)))
+	for i, line := range lines {
+		if i == maxReportedJobs {
+			report = append(report, fmt.Sprintf("- ... and %d more", len(lines)-i))
+			break
+		}
+		report = append(report, "- "+line)
+	}
+	return report
+}
+
+// offerRedelivery posts a message with a delivery button for each result
+// whose upload was cut off, since a button's message is replaced once it's pressed.
+func offerRedelivery(session *discordgo.Session, channelID string, uploads []discord.Undelivered) {
+	for i, entry := range uploads {
+		if i == maxReportedResults {
+			session.ChannelMessageSend(channelID, fmt.Sprintf("%d more results weren't delivered; see `.sadmin redeliver`.", len(uploads)-i))
+			return
+		}
+		content := fmt.Sprintf("Result `%s` for <#%s>", entry.ID, entry.ChannelID)
+		if _, err := session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			Content:         content,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+					discordgo.Button{Label: "Deliver", Style: discordgo.PrimaryButton, CustomID: discord.ComponentID(discord.RedeliverPrefix, entry.ID)},
+				}},
+			},
+		}); err != nil {
+			slog.Error("couldn't offer redelivery of result ", entry.ID, ": ", err)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/config"
+	"slugbot/internal/exec"
+	"slugbot/internal/helpers"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/utils"
+)
+
+const runUsage = `Usage: slugbot run "<command>" [--input <file>]... [--out <dir>]
+
+Runs a command through the same pipeline as a message sent to the bot: it's
+parsed, queued, run, and its results are written to --out instead of being
+uploaded. Each --input file is attached to the command's message. Replies and
+progress messages are printed as they'd be sent. Nothing connects to Discord,
+and the bot's store isn't touched; the local user counts as an admin.`
+
+// Who and where a local run's message comes from.
+const (
+	localChannelID = "local"
+	localUserID    = "local-user"
+	localBotID     = "local-bot"
+)
+
+// stringsFlag collects a repeatable flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// runLocal runs one command without Discord and returns the process exit code.
+func runLocal(configPath string, args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	flags.Usage = func() { fmt.Fprintln(stdout, runUsage) }
+	var inputs stringsFlag
+	flags.Var(&inputs, "input", "file to attach to the command's message; may be repeated")
+	outDir := flags.String("out", "run-output", "directory results are written to")
+
+	// the command may come before or after the flags
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	content := flags.Arg(0)
+	if err := flags.Parse(flags.Args()[1:]); err != nil || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintln(stdout, err)
+		return 1
+	}
+	storeDir, err := os.MkdirTemp(utils.TempDir(), "slugbot-run-*")
+	if err != nil {
+		fmt.Fprintln(stdout, err)
+		return 1
+	}
+	defer os.RemoveAll(storeDir)
+	cfg.Store.Dir = storeDir
+	cfg.Admin.Users = append(cfg.Admin.Users, localUserID)
+	config.Set(cfg)
+
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		fmt.Fprintln(stdout, err)
+		return 1
+	}
+	if err := setupServices(cfg); err != nil {
+		fmt.Fprintln(stdout, "error opening store, ", err)
+		return 1
+	}
+
+	attachments, stopServing, err := serveInputs(inputs)
+	if err != nil {
+		fmt.Fprintln(stdout, err)
+		return 1
+	}
+	defer stopServing()
+
+	local := &localDiscord{out: stdout, dir: *outDir, messages: map[string]*discordgo.Message{}}
+	session, err := discordgo.New("Bot local")
+	if err != nil {
+		fmt.Fprintln(stdout, err)
+		return 1
+	}
+	session.Client = &http.Client{Transport: local}
+	session.State.User = &discordgo.User{ID: localBotID, Username: "slugbot", Bot: true}
+	reactionSession = session
+
+	var failed atomic.Int32
+	audioQueue.OnFinish = func(task exec.Task, err error) {
+		finishQueuedJob(task, err)
+		if err != nil {
+			failed.Add(1)
+		}
+	}
+
+	message := &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:          local.nextID(),
+		ChannelID:   localChannelID,
+		Content:     content,
+		Author:      &discordgo.User{ID: localUserID, Username: "local"},
+		Attachments: attachments,
+		Timestamp:   time.Now(),
+	}}
+	local.remember(message.Message)
+	dispatchUnattended(session, message)
+	waitForQueue()
+
+	local.mutex.Lock()
+	fmt.Fprintf(stdout, "done; %d file(s) written to %s\n", local.files, *outDir)
+	local.mutex.Unlock()
+	if failed.Load() > 0 {
+		return 1
+	}
+	return 0
+}
+
+// waitForQueue returns once the queue has run everything it was given.
+func waitForQueue() {
+	for {
+		_, busy, waiting := audioQueue.Status()
+		if !busy && waiting == 0 {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// serveInputs serves files over HTTP on the loopback interface, so commands
+// can download them like Discord attachments, and returns them as attachments.
+func serveInputs(paths []string) ([]*discordgo.MessageAttachment, func(), error) {
+	if len(paths) == 0 {
+		return nil, func() {}, nil
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't serve inputs: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	var attachments []*discordgo.MessageAttachment
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			listener.Close()
+			return nil, nil, fmt.Errorf("couldn't read input: %w", err)
+		}
+		name := filepath.Base(path)
+		route := fmt.Sprintf("/%d/%s", i+1, name)
+		mux.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
+			http.ServeFile(w, r, path)
+		})
+		attachments = append(attachments, &discordgo.MessageAttachment{
+			ID:          strconv.Itoa(i + 1),
+			URL:         "http://" + listener.Addr().String() + route,
+			Filename:    name,
+			ContentType: helpers.ContentTypeFromFilename(name),
+			Size:        int(info.Size()),
+		})
+	}
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	return attachments, func() { server.Close() }, nil
+}
+
+// localDiscord stands in for Discord's REST API: messages are printed, their
+// files are written to a directory, and they're kept so they can be fetched
+// back, e.g. by a command that looks for an input in the channel's history.
+type localDiscord struct {
+	out   io.Writer
+	dir   string // where files are written; if empty, they're only named
+	files int
+
+	mutex    sync.Mutex
+	lastID   int
+	messages map[string]*discordgo.Message
+	order    []string
+}
+
+func (d *localDiscord) nextID() string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.lastID++
+	return strconv.Itoa(d.lastID)
+}
+
+func (d *localDiscord) remember(message *discordgo.Message) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.messages[message.ID] = message
+	d.order = append(d.order, message.ID)
+}
+
+// made reports whether a REST path is one of the messages it took.
+func (d *localDiscord) made(path string) bool {
+	parts := strings.Split(strings.TrimPrefix(path, "/api/v"+discordgo.APIVersion+"/"), "/")
+	if len(parts) != 4 || parts[0] != "channels" || parts[2] != "messages" {
+		return false
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	_, ok := d.messages[parts[3]]
+	return ok
+}
+
+func (d *localDiscord) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api/v"+discordgo.APIVersion+"/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case r.Method == http.MethodDelete:
+		if len(parts) == 4 && parts[0] == "channels" && parts[2] == "messages" {
+			fmt.Fprintf(d.out, "[deleted %s]\n", parts[3])
+		}
+		return d.respond(r, http.StatusNoContent, nil)
+
+	case len(parts) >= 6 && parts[4] == "reactions":
+		if r.Method == http.MethodPut {
+			fmt.Fprintf(d.out, "[reacted %s to %s]\n", parts[5], parts[3])
+		}
+		return d.respond(r, http.StatusNoContent, nil)
+
+	case len(parts) == 2 && parts[0] == "channels":
+		return d.respond(r, http.StatusOK, &discordgo.Channel{ID: parts[1], Type: discordgo.ChannelTypeDM})
+
+	case len(parts) == 3 && parts[0] == "channels" && parts[2] == "messages" && r.Method == http.MethodGet:
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		history := make([]*discordgo.Message, 0, len(d.order))
+		for i := len(d.order) - 1; i >= 0; i-- {
+			history = append(history, d.messages[d.order[i]])
+		}
+		return d.respond(r, http.StatusOK, history)
+
+	case len(parts) == 3 && parts[0] == "channels" && parts[2] == "messages" && r.Method == http.MethodPost:
+		message, err := d.send(r, parts[1])
+		if err != nil {
+			return nil, err
+		}
+		return d.respond(r, http.StatusOK, message)
+
+	case len(parts) == 4 && parts[0] == "channels" && parts[2] == "messages":
+		var edit discordgo.MessageEdit
+		if r.Method == http.MethodPatch {
+			if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+				return nil, err
+			}
+		}
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		message, ok := d.messages[parts[3]]
+		if !ok {
+			return d.respond(r, http.StatusNotFound, &discordgo.APIErrorMessage{Code: discordgo.ErrCodeUnknownMessage, Message: "Unknown Message"})
+		}
+		if edit.Content != nil {
+			message.Content = *edit.Content
+			fmt.Fprintf(d.out, "[edited %s] %s\n", message.ID, message.Content)
+		}
+		return d.respond(r, http.StatusOK, message)
+	}
+
+	slog.Debug("local run ignored Discord request ", r.Method, " ", path)
+	return d.respond(r, http.StatusOK, struct{}{})
+}
+
+// send takes a new message, writing its files to the output directory.
+func (d *localDiscord) send(r *http.Request, channelID string) (*discordgo.Message, error) {
+	message := &discordgo.Message{ID: d.nextID(), ChannelID: channelID, Timestamp: time.Now(),
+		Author: &discordgo.User{ID: localBotID, Username: "slugbot", Bot: true}}
+
+	var send discordgo.MessageSend
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		if err := json.NewDecoder(r.Body).Decode(&send); err != nil {
+			return nil, err
+		}
+	} else {
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			if part.FormName() == "payload_json" {
+				if err := json.NewDecoder(part).Decode(&send); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			path, err := d.save(message.ID, part)
+			if err != nil {
+				return nil, err
+			}
+			message.Attachments = append(message.Attachments, &discordgo.MessageAttachment{
+				ID: strconv.Itoa(len(message.Attachments) + 1), Filename: part.FileName(), URL: "file://" + path,
+			})
+		}
+	}
+
+	message.Content, message.Embeds = send.Content, send.Embeds
+	fmt.Fprintf(d.out, "[message %s] %s\n", message.ID, message.Content)
+	for _, embed := range send.Embeds {
+		fmt.Fprintf(d.out, "  [embed] %s %s\n", embed.Title, embed.Description)
+	}
+	d.remember(message)
+	return message, nil
+}
+
+// save writes an uploaded file to the output directory.
+func (d *localDiscord) save(messageID string, part *multipart.Part) (string, error) {
+	if d.dir == "" {
+		fmt.Fprintf(d.out, "[file] %s\n", part.FileName())
+		return part.FileName(), nil
+	}
+	path := filepath.Join(d.dir, messageID+"-"+filepath.Base(part.FileName()))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, part); err != nil {
+		return "", err
+	}
+
+	d.mutex.Lock()
+	d.files++
+	d.mutex.Unlock()
+	fmt.Fprintf(d.out, "[file] %s\n", path)
+	return path, nil
+}
+
+func (d *localDiscord) respond(r *http.Request, status int, body any) (*http.Response, error) {
+	var text []byte
+	if body != nil {
+		var err error
+		if text, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(string(text))),
+		Request:    r,
+	}, nil
+}
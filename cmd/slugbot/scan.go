@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/audit"
+	"slugbot/internal/commands"
+	"slugbot/internal/config"
+	"slugbot/internal/helpers"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/scan"
+)
+
+// setupScanner checks downloaded inputs with the configured scanner, if any.
+func setupScanner(cfg *config.Config) {
+	var scanner scan.Scanner
+	switch {
+	case cfg.Scan.Clamd != "":
+		scanner = &scan.Clamd{Address: cfg.Scan.Clamd, Timeout: cfg.Scan.Timeout}
+	case cfg.Scan.URL != "":
+		scanner = &scan.HTTP{URL: cfg.Scan.URL, Client: &http.Client{Timeout: cfg.Scan.Timeout}}
+	default:
+		helpers.SetInputScanner(nil)
+		return
+	}
+	helpers.SetInputScanner(func(path string, message *discordgo.MessageCreate) error {
+		return scanInput(scanner, cfg.Scan.FailOpen, path, message)
+	})
+}
+
+// scanInput scans a file downloaded for message, records the outcome in the
+// audit log, and returns an error for the user if the file is refused.
+func scanInput(scanner scan.Scanner, failOpen bool, path string, message *discordgo.MessageCreate) error {
+	verdict, err := scanner.Scan(path)
+
+	var outcome string
+	var refused error
+	switch {
+	case err != nil && failOpen:
+		slog.Warn("couldn't scan the input to message ", message.ID, ", using it anyway: ", err)
+		outcome = "scan failed, used anyway"
+	case err != nil:
+		slog.Error("couldn't scan the input to message ", message.ID, ": ", err)
+		outcome = "scan failed, refused"
+		refused = commands.NewUserError("couldn't check your file for viruses right now, so it wasn't used; try again later", err)
+	case verdict.Flagged:
+		slog.Warn("input to message ", message.ID, " was flagged: ", verdict.Reason)
+		outcome = fmt.Sprintf("flagged (%s), refused", verdict.Reason)
+		refused = commands.NewUserError("your file was flagged by the bot's virus scanner, so it wasn't used", scan.ErrFlagged)
+	default:
+		outcome = "clean"
+	}
+
+	var userID string
+	if message.Author != nil {
+		userID = message.Author.ID
+	}
+	if err := auditLog.Record(audit.Entry{
+		GuildID: message.GuildID,
+		ActorID: userID,
+		UserID:  userID,
+		Action:  "scan",
+		Detail:  fmt.Sprintf("%s; input to message %s in <#%s>", outcome, message.ID, message.ChannelID),
+	}); err != nil {
+		slog.Error("couldn't record a scan in the audit log: ", err)
+	}
+	return refused
+}
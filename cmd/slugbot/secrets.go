@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"slugbot/internal/secrets"
+)
+
+const secretsUsage = `Usage: slugbot secrets <command>
+
+  list              show which secrets are set, and where
+  set <name>        read a secret from stdin and save it in the keyring
+  delete <name>     remove a secret from the keyring
+
+Any secret can instead be given in the environment as SLUGBOT_<NAME>, e.g. SLUGBOT_LLM_API_KEY.`
+
+// runSecretsCommand manages the bot's secrets from the command line and
+// returns the process exit code.
+func runSecretsCommand(args []string, stdin io.Reader, stdout io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stdout, secretsUsage)
+		return 2
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		for _, name := range secrets.Names() {
+			status := secrets.Status(name)
+			if status == "" {
+				status = "not set"
+			}
+			fmt.Fprintf(stdout, "%-16s %-9s %s\n", name, status, secrets.Known[name])
+		}
+		return 0
+
+	case args[0] == "set" && len(args) == 2:
+		fmt.Fprintf(stdout, "Enter %s: ", args[1])
+		value, _ := bufio.NewReader(stdin).ReadString('\n')
+		value = strings.TrimSpace(value)
+		if value == "" {
+			fmt.Fprintln(stdout, "\nno value given; nothing saved")
+			return 1
+		}
+		if err := secrets.Set(args[1], value); err != nil {
+			fmt.Fprintln(stdout, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "saved %s\n", args[1])
+		return 0
+
+	case args[0] == "delete" && len(args) == 2:
+		if err := secrets.Delete(args[1]); err != nil {
+			fmt.Fprintln(stdout, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "deleted %s\n", args[1])
+		return 0
+	}
+
+	fmt.Fprintln(stdout, secretsUsage)
+	return 2
+}
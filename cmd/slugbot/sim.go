@@ -0,0 +1,70 @@
+//go:build !noimage
+
+package main
+
+import (
+	"context"
+	"maps"
+	"strings"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/commands/image"
+	"slugbot/internal/config"
+	"slugbot/internal/discord"
+	"slugbot/internal/helpers"
+	"slugbot/internal/io/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// the image operations are only built in without the noimage tag
+func init() {
+	maps.Copy(simCommandHandlers, map[string]func() commands.CommandHandler{
+		"arc":       func() commands.CommandHandler { return &image.ArcDistortCommand{} },
+		"barrel":    func() commands.CommandHandler { return &image.BarrelDistortCommand{} },
+		"ibarrel":   func() commands.CommandHandler { return &image.InverseBarrelDistortCommand{} },
+		"polar":     func() commands.CommandHandler { return &image.PolarDistortCommand{} },
+		"ipolar":    func() commands.CommandHandler { return &image.InversePolarDistortCommand{} },
+		"genframes": func() commands.CommandHandler { return &image.GenFramesCommand{} },
+		"animate":   func() commands.CommandHandler { return &image.AnimateCommand{} },
+		"filter":    func() commands.CommandHandler { return &image.FilterCommand{} },
+		"tile":      func() commands.CommandHandler { return &image.TileCommand{} },
+		"preset": func() commands.CommandHandler {
+			return &image.PresetCommand{Presets: presetCatalog, Pages: listingPages}
+		},
+	})
+	topCommandHandlers[".sqr"] = handleDotSqr
+	topCommandHandlers[".stext"] = handleDotStext
+	simBatchCommand = func(op commands.CommandHandler) (commands.CommandHandler, bool) {
+		batchable, ok := op.(image.Batchable)
+		if !ok {
+			return nil, false
+		}
+		limits := config.Get().Limits
+		return &image.BatchCommand{Op: batchable, Limits: helpers.ArchiveLimits{MaxFiles: limits.BatchImages, MaxBytes: limits.BatchBytes}}, true
+	}
+}
+
+func handleDotSqr(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	return applyImageUtility(ctx, session, message, &image.QRCommand{})
+}
+
+func handleDotStext(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
+	return applyImageUtility(ctx, session, message, &image.TextCommand{})
+}
+
+// applyImageUtility runs one of the image commands that make an image from
+// scratch, rather than working on one. Like `.sim`, they run right away.
+func applyImageUtility(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate, command commands.CommandHandler) error {
+	command.SetContext(session, message)
+	command.SetTraceID(traceIDFrom(ctx))
+	command.SetTraceContext(ctx)
+
+	if err := command.Validate(); err != nil {
+		session.ChannelMessageSend(message.ChannelID, command.Usage())
+		return nil
+	}
+	defer discord.Typing(discord.ConcreteSession{Session: session}, message.ChannelID)()
+	slog.With("trace", command.TraceID()).Info("applying ", strings.Fields(message.Content)[0], " command...")
+	return command.Apply()
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/audit"
+	"slugbot/internal/commands/audio"
+	"slugbot/internal/discord"
+	"slugbot/internal/exec"
+	"slugbot/internal/format"
+	"slugbot/internal/io/slog"
+)
+
+// how long an offer to swap jobs waits for the other owner to take it
+const swapOfferTimeout = 10 * time.Minute
+
+const swapUsage = "Usage: `.sjob swap <their job> [your job]`, e.g. `.sjob swap B2C4`; your job can be left out if you only have one waiting"
+
+// pendingSwap is an offer from one user to trade their waiting job's place in
+// the queue for another user's.
+type pendingSwap struct {
+	message *discordgo.MessageCreate // the `.sjob swap` that made the offer
+	mine    string                   // the offering user's job
+	theirs  string
+	ownerID string // who has to accept
+}
+
+var pendingSwaps = newPendingConfirmations[pendingSwap]("This offer has expired; run `.sjob swap` again to make a new one.")
+
+// handleJobSwap offers to trade places in the queue with another user's
+// waiting job, with buttons for them to accept or decline.
+func handleJobSwap(session *discordgo.Session, message *discordgo.MessageCreate) error {
+	args := strings.Fields(message.Content)
+	if len(args) < 3 || len(args) > 4 {
+		_, err := session.ChannelMessageSend(message.ChannelID, swapUsage)
+		return err
+	}
+
+	theirs, ok := audioQueue.Info(args[2])
+	if !ok || theirs.State != exec.StateWaiting || !audio.JobVisibleTo(theirs, message) {
+		return replySwap(session, message, fmt.Sprintf("Job `%s` isn't waiting in the queue.", strings.ToUpper(args[2])))
+	}
+	ownerID := taskOwner(theirs.Task)
+	switch ownerID {
+	case "":
+		return replySwap(session, message, fmt.Sprintf("Job `%s` doesn't belong to anyone who could agree to a swap.", theirs.ID))
+	case message.Author.ID:
+		return replySwap(session, message, fmt.Sprintf("Job `%s` is already yours.", theirs.ID))
+	}
+
+	var mine exec.TaskInfo
+	if len(args) == 4 {
+		mine, ok = audioQueue.Info(args[3])
+		if !ok || mine.State != exec.StateWaiting || taskOwner(mine.Task) != message.Author.ID {
+			return replySwap(session, message, fmt.Sprintf("You don't have a job `%s` waiting in the queue.", strings.ToUpper(args[3])))
+		}
+	} else {
+		var waiting []exec.TaskInfo
+		for _, info := range audioQueue.Jobs() {
+			if info.State == exec.StateWaiting && taskOwner(info.Task) == message.Author.ID {
+				waiting = append(waiting, info)
+			}
+		}
+		switch len(waiting) {
+		case 0:
+			return replySwap(session, message, "You don't have any jobs waiting in the queue to swap.")
+		case 1:
+			mine = waiting[0]
+		default:
+			return replySwap(session, message, "You have several jobs waiting; say which to swap with `.sjob swap <their job> <your job>`.")
+		}
+	}
+
+	token := pendingSwaps.add(pendingSwap{message: message, mine: mine.ID, theirs: theirs.ID, ownerID: ownerID}, swapOfferTimeout)
+
+	slog.Info("user ", message.Author.ID, " offered to swap job ", mine.ID, " for ", theirs.ID)
+	_, err := session.ChannelMessageSendComplex(message.ChannelID, &discordgo.MessageSend{
+		Content: fmt.Sprintf("<@%s>, %s would like to swap places in the queue: your job `%s` (%d ahead of it) for their job `%s` (%d ahead of it). The offer lasts %s.",
+			ownerID, message.Author.Mention(), theirs.ID, theirs.Position, mine.ID, mine.Position, format.Duration(swapOfferTimeout)),
+		Reference: message.Reference(),
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "Swap", Emoji: &discordgo.ComponentEmoji{Name: "🔁"}, Style: discordgo.SuccessButton, CustomID: discord.ComponentID("swap-ok", token)},
+				discordgo.Button{Label: "Decline", Style: discordgo.SecondaryButton, CustomID: discord.ComponentID("swap-decline", token)},
+			}},
+		},
+		// only the owner of the other job is asked
+		AllowedMentions: &discordgo.MessageAllowedMentions{Users: []string{ownerID}},
+	})
+	return err
+}
+
+func replySwap(session *discordgo.Session, message *discordgo.MessageCreate, content string) error {
+	_, err := session.ChannelMessageSendReply(message.ChannelID, content, message.Reference())
+	return err
+}
+
+func registerSwapComponents(router *discord.ComponentRouter) {
+	router.Handle("swap-ok", func(s *discordgo.Session, i *discordgo.InteractionCreate, token string) error {
+		pending, ok := takePendingSwap(s, i, token, false)
+		if !ok {
+			return nil
+		}
+		// either job could have started, or changed hands, since the offer
+		mine, _ := audioQueue.Info(pending.mine)
+		theirs, _ := audioQueue.Info(pending.theirs)
+		if taskOwner(mine.Task) != pending.message.Author.ID || taskOwner(theirs.Task) != pending.ownerID {
+			return resolveConfirmation(s, i, "These jobs have changed since the offer, so they weren't swapped.")
+		}
+		err := audioQueue.Swap(pending.mine, pending.theirs)
+		if errors.Is(err, exec.ErrNotSwappable) {
+			return resolveConfirmation(s, i, fmt.Sprintf("Couldn't swap: %v.", err))
+		} else if err != nil {
+			return err
+		}
+
+		if err := auditLog.Record(audit.Entry{
+			GuildID: pending.message.GuildID,
+			ActorID: pending.ownerID,
+			UserID:  pending.message.Author.ID,
+			Action:  "swap",
+			Detail:  fmt.Sprintf("job %s, %d ahead of it, traded places with job %s, %d ahead of it", theirs.ID, theirs.Position, mine.ID, mine.Position),
+		}); err != nil {
+			slog.Error("couldn't record a job swap in the audit log: ", err)
+		}
+		mine, _ = audioQueue.Info(pending.mine)
+		theirs, _ = audioQueue.Info(pending.theirs)
+		return resolveConfirmation(s, i, fmt.Sprintf("🔁 Swapped: job `%s` now has %d ahead of it, and job `%s` %d.", mine.ID, mine.Position, theirs.ID, theirs.Position))
+	})
+
+	router.Handle("swap-decline", func(s *discordgo.Session, i *discordgo.InteractionCreate, token string) error {
+		pending, ok := takePendingSwap(s, i, token, true)
+		if !ok {
+			return nil
+		}
+		return resolveConfirmation(s, i, fmt.Sprintf("The offer to swap jobs `%s` and `%s` was called off.", pending.mine, pending.theirs))
+	})
+}
+
+// takePendingSwap removes and returns the pending offer if the clicking user
+// is the owner of the job it asks for, or, if byEither, the one who made it.
+// Otherwise it tells the clicker why nothing happened.
+func takePendingSwap(s *discordgo.Session, i *discordgo.InteractionCreate, token string, byEither bool) (pendingSwap, bool) {
+	return pendingSwaps.take(s, i, token, func(pending pendingSwap, userID string) bool {
+		return userID == pending.ownerID || byEither && userID == pending.message.Author.ID
+	}, "Only the owner of the other job can answer this.")
+}
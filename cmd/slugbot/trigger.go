@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"slugbot/internal/config"
+	"slugbot/internal/format"
+	"slugbot/internal/io/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// queueNotices maps a queued job's triggering message ID to the ID of the
+// "Queued behind N job(s)" reply, so the reply can follow edits and deletes.
+var queueNotices sync.Map
+
+// messageUpdateHandler re-parses a queued job when the user edits the message
+// that triggered it. Jobs that have already started are left alone.
+func messageUpdateHandler(session *discordgo.Session, update *discordgo.MessageUpdate) {
+	// embeds resolving also produce updates; only react to the user's own edits
+	if update.Message == nil || update.EditedTimestamp == nil || update.Author == nil || update.Author.Bot || config.Get().Interactions.Only {
+		return
+	}
+
+	// a forum post's body is only part of its command
+	edited := *update.Message
+	if request, ok := forumRequest(session, update.Message); ok {
+		edited.Content = request
+	}
+
+	// an edit mustn't sneak a restricted prompt past the check the original went through
+	if parts := strings.Fields(edited.Content); len(parts) > 0 {
+		if reason, restricted := nsfwRestriction(session, &discordgo.MessageCreate{Message: &edited}, commandKey(parts)); restricted {
+			cancelled := 0
+			for {
+				if _, ok := audioQueue.Cancel(update.ID); !ok {
+					break
+				}
+				cancelled++
+			}
+			if cancelled > 0 {
+				session.ChannelMessageSendReply(update.ChannelID, "Cancelled your queued job: "+reason+".", update.Reference())
+			}
+			return
+		}
+	}
+
+	if gpuTime, ok := editNeedsConfirmation(session, &edited); ok {
+		session.ChannelMessageSendReply(update.ChannelID, "With your edit the job would take about "+format.Duration(gpuTime)+", so it will run as originally queued. Send it as a new message to confirm the longer job.", update.Reference())
+		return
+	}
+
+	found, err := audioQueue.Edit(update.ID, edited.Content)
+	if !found {
+		queueNotices.Delete(update.ID)
+		return
+	}
+
+	reference := &discordgo.MessageReference{MessageID: update.ID, ChannelID: update.ChannelID}
+	if err != nil {
+		slog.Warn("couldn't apply edit to queued job: ", err)
+		session.ChannelMessageSendReply(update.ChannelID, "Couldn't apply your edit, so the job will run as originally queued: "+err.Error(), reference)
+		return
+	}
+
+	if noticeID, ok := queueNotices.Load(update.ID); ok {
+		session.MessageReactionAdd(update.ChannelID, noticeID.(string), "✏️")
+	}
+}
+
+// messageDeleteHandler cancels a queued job when the user deletes the message
+// that triggered it, and removes the job's queue notice.
+func messageDeleteHandler(session *discordgo.Session, deleted *discordgo.MessageDelete) {
+	if deleted.Message == nil {
+		return
+	}
+
+	noticeID, hasNotice := queueNotices.LoadAndDelete(deleted.ID)
+
+	// grouped commands like .scompare queue several jobs for one message
+	cancelled := 0
+	for {
+		task, ok := audioQueue.Cancel(deleted.ID)
+		if !ok {
+			break
+		}
+		slog.With("trace", task.TraceID()).Info("triggering message was deleted; cancelled queued job")
+		cancelled++
+	}
+	if cancelled == 0 {
+		return
+	}
+
+	if hasNotice {
+		if err := session.ChannelMessageDelete(deleted.ChannelID, noticeID.(string)); err != nil {
+			slog.Warn("couldn't delete queue notice: ", err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"slugbot/internal/apitoken"
+	"slugbot/internal/commands"
+	"slugbot/internal/config"
+	"slugbot/internal/exec"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/secrets"
+	"slugbot/internal/webhook"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// startWebhookServer serves signed job submissions if [webhooks] is configured.
+// It returns nil if webhooks are off.
+func startWebhookServer(session *discordgo.Session) *http.Server {
+	cfg := config.Get().Webhooks
+	if cfg.Listen == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/webhooks/jobs", &webhook.Handler{
+		Secret: func() string {
+			secret, err := secrets.Get(secrets.WebhookSecret)
+			if err != nil {
+				slog.Warn("webhook secret unavailable: ", err)
+			}
+			return secret
+		},
+		MaxSkew:  cfg.MaxSkew,
+		Channels: cfg.Channels,
+		Commands: recurringCommands,
+		Authenticate: func(token string) (apitoken.Token, error) {
+			return apiTokens.Validate(token, time.Now())
+		},
+		Submit: func(req webhook.Request, owner *apitoken.Token) (webhook.Response, error) {
+			var user *discordgo.User
+			if owner != nil {
+				user = &discordgo.User{ID: owner.UserID, Username: owner.Username}
+			}
+			messageID, jobIDs, err := submitJob(session, req.ChannelID, req.Command, req.Source, user)
+			return webhook.Response{MessageID: messageID, JobIDs: jobIDs}, err
+		},
+	})
+
+	server := &http.Server{Addr: cfg.Listen, Handler: mux, ReadHeaderTimeout: readHeaderTimeout}
+	go func() {
+		slog.Info("accepting webhooks on ", cfg.Listen)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("webhook server stopped: ", err)
+		}
+	}()
+	return server
+}
+
+// readHeaderTimeout is how long the dashboard and webhook servers wait for a
+// request's headers, so clients that never finish sending them can't hold
+// connections open.
+const readHeaderTimeout = 10 * time.Second
+
+// webhookUserID is who jobs submitted with the shared webhook secret run as.
+const webhookUserID = "webhook"
+
+// submitJob posts a header for a job submitted from outside Discord in its
+// channel, then runs the job's command in reply to the header. Jobs submitted
+// with a personal token run as its owner, user; others run as a shared
+// "webhook" user. It returns the header's ID and the IDs of the jobs the
+// command queued.
+func submitJob(session *discordgo.Session, channelID string, command string, source string, user *discordgo.User) (messageID string, jobIDs []string, err error) {
+	if source == "" {
+		source = "webhook"
+	}
+	header := fmt.Sprintf("Job from %s: `%s`", source, command)
+	if user != nil {
+		header = fmt.Sprintf("Job from %s for <@%s>: `%s`", source, user.ID, command)
+	} else {
+		// submitted jobs share one quota, under a user ID no Discord account has
+		user = &discordgo.User{ID: webhookUserID, Username: source}
+	}
+	posted, err := session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         header,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("couldn't post to channel: %w", err)
+	}
+	slog.Info("running submitted job from ", source, " as user ", user.ID, ": ", command)
+
+	guildID := posted.GuildID
+	if channel, err := commands.LookupChannel(session, channelID); err == nil {
+		guildID = channel.GuildID
+	}
+
+	dispatchUnattended(session, &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        posted.ID,
+		ChannelID: channelID,
+		GuildID:   guildID,
+		Author:    user,
+		Content:   command,
+	}})
+
+	jobIDs = audioQueue.JobIDs(posted.ID)
+	if len(jobIDs) == 0 {
+		if _, _, waiting := audioQueue.Status(); audioQueue.MaxDepth > 0 && waiting >= audioQueue.MaxDepth {
+			return posted.ID, nil, exec.ErrQueueFull
+		}
+		return posted.ID, nil, fmt.Errorf("the job wasn't queued; see the channel for why")
+	}
+	return posted.ID, jobIDs, nil
+}
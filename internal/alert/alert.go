@@ -0,0 +1,56 @@
+package alert
+
+import (
+	"sync"
+	"time"
+
+	"slugbot/internal/io/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Threshold fires when an event happens Count times within Window, and then
+// stays quiet for a Window so a sustained problem doesn't flood the admins.
+type Threshold struct {
+	Count  int // 0 disables the threshold
+	Window time.Duration
+
+	mutex sync.Mutex
+	hits  []time.Time
+	fired time.Time
+}
+
+// Hit records an event and reports whether the threshold fired.
+func (t *Threshold) Hit() bool {
+	if t == nil || t.Count <= 0 {
+		return false
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	recent := t.hits[:0]
+	for _, hit := range t.hits {
+		if now.Sub(hit) < t.Window {
+			recent = append(recent, hit)
+		}
+	}
+	t.hits = append(recent, now)
+
+	if len(t.hits) < t.Count || now.Sub(t.fired) < t.Window {
+		return false
+	}
+	t.fired = now
+	t.hits = nil
+	return true
+}
+
+// Send logs an operational alert and posts it to each of the admin channels.
+func Send(session *discordgo.Session, channels []string, text string) {
+	slog.Warn("alert: ", text)
+	for _, channelID := range channels {
+		if _, err := session.ChannelMessageSend(channelID, "⚠️ "+text); err != nil {
+			slog.Error("couldn't send alert to channel ", channelID, ": ", err)
+		}
+	}
+}
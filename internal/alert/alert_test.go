@@ -0,0 +1,31 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThreshold_FiresOncePerWindow(t *testing.T) {
+	threshold := &Threshold{Count: 3, Window: 50 * time.Millisecond}
+	require.False(t, threshold.Hit())
+	require.False(t, threshold.Hit())
+	require.True(t, threshold.Hit())
+
+	// still within the window after firing
+	for range 3 {
+		require.False(t, threshold.Hit())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	require.False(t, threshold.Hit())
+	require.False(t, threshold.Hit())
+	require.True(t, threshold.Hit())
+}
+
+func TestThreshold_DisabledOrNil(t *testing.T) {
+	var threshold *Threshold
+	require.False(t, threshold.Hit())
+	require.False(t, (&Threshold{}).Hit())
+}
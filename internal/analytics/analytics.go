@@ -0,0 +1,258 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"slugbot/internal/eta"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/store"
+)
+
+const bucket = "analytics"
+
+// Summary is a guild's aggregated usage. It holds only counts: no user IDs,
+// prompts, or message content are ever recorded.
+type Summary struct {
+	Commands map[string]int            `json:"commands"` // e.g. ".saudio", ".sim barrel"
+	Failures map[string]int            `json:"failures"`
+	Params   map[string]map[string]int `json:"params"` // parameter -> bucket -> count
+}
+
+func newSummary() *Summary {
+	return &Summary{
+		Commands: map[string]int{},
+		Failures: map[string]int{},
+		Params:   map[string]map[string]int{},
+	}
+}
+
+func (s *Summary) merge(other *Summary) {
+	for command, n := range other.Commands {
+		s.Commands[command] += n
+	}
+	for command, n := range other.Failures {
+		s.Failures[command] += n
+	}
+	for param, buckets := range other.Params {
+		if s.Params[param] == nil {
+			s.Params[param] = map[string]int{}
+		}
+		for value, n := range buckets {
+			s.Params[param][value] += n
+		}
+	}
+}
+
+// FailureRate returns the fraction of a command's runs that failed.
+func (s *Summary) FailureRate(command string) float64 {
+	if s.Commands[command] == 0 {
+		return 0
+	}
+	return float64(s.Failures[command]) / float64(s.Commands[command])
+}
+
+// CommandNames returns the recorded commands, most used first.
+func (s *Summary) CommandNames() []string {
+	names := make([]string, 0, len(s.Commands))
+	for name := range s.Commands {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if s.Commands[names[i]] != s.Commands[names[j]] {
+			return s.Commands[names[i]] > s.Commands[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// Collector aggregates usage in memory and periodically merges it into the
+// store, one summary per guild. A nil Collector records nothing, which is how
+// analytics stay off unless enabled in the config.
+type Collector struct {
+	Store *store.Store
+
+	mutex   sync.Mutex
+	pending map[string]*Summary // guild ID -> not yet flushed
+}
+
+func (c *Collector) summary(guildID string) *Summary {
+	if c.pending == nil {
+		c.pending = map[string]*Summary{}
+	}
+	if c.pending[guildID] == nil {
+		c.pending[guildID] = newSummary()
+	}
+	return c.pending[guildID]
+}
+
+// Count records that a command was run in a guild.
+func (c *Collector) Count(guildID string, command string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.summary(guildID).Commands[command]++
+}
+
+// Fail records that a run of a command failed.
+func (c *Collector) Fail(guildID string, command string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.summary(guildID).Failures[command]++
+}
+
+// Shape records the bucketed parameters of a generation.
+func (c *Collector) Shape(guildID string, shape eta.Shape) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	params := c.summary(guildID).Params
+	for param, value := range map[string]string{
+		"model":  shape.Model,
+		"steps":  stepsBucket(shape.Steps),
+		"length": lengthBucket(shape.Length),
+	} {
+		if params[param] == nil {
+			params[param] = map[string]int{}
+		}
+		params[param][value]++
+	}
+}
+
+func stepsBucket(steps int64) string {
+	switch {
+	case steps <= 25:
+		return "≤25"
+	case steps <= 50:
+		return "26-50"
+	case steps <= 100:
+		return "51-100"
+	default:
+		return ">100"
+	}
+}
+
+func lengthBucket(length float64) string {
+	switch {
+	case length <= 10:
+		return "≤10s"
+	case length <= 30:
+		return "11-30s"
+	case length <= 60:
+		return "31-60s"
+	default:
+		return ">60s"
+	}
+}
+
+// Flush merges everything recorded since the last flush into the store.
+func (c *Collector) Flush() error {
+	if c == nil || c.Store == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var errs []error
+	for guildID, pending := range c.pending {
+		stored, err := c.load(guildID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		stored.merge(pending)
+		if err := c.Store.Put(bucket, guildKey(guildID), stored); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delete(c.pending, guildID)
+	}
+	return errors.Join(errs...)
+}
+
+// Summary returns everything recorded for a guild, flushed or not.
+func (c *Collector) Summary(guildID string) (*Summary, error) {
+	if c == nil {
+		return nil, fmt.Errorf("usage analytics aren't enabled")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	summary := newSummary()
+	if c.Store != nil {
+		stored, err := c.load(guildID)
+		if err != nil {
+			return nil, err
+		}
+		summary.merge(stored)
+	}
+	if pending := c.pending[guildID]; pending != nil {
+		summary.merge(pending)
+	}
+	return summary, nil
+}
+
+// ForgetGuild deletes everything recorded for a guild, flushed or not.
+func (c *Collector) ForgetGuild(guildID string) error {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.pending, guildID)
+	if c.Store == nil || guildID == "" {
+		return nil
+	}
+	return c.Store.Delete(bucket, guildKey(guildID))
+}
+
+// Start flushes every interval until done is closed. Callers should Flush once
+// more on shutdown.
+func (c *Collector) Start(interval time.Duration, done <-chan struct{}) {
+	if c == nil || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Flush(); err != nil {
+				slog.Warn("couldn't flush usage analytics: ", err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// load reads a guild's stored summary. The caller must hold the mutex.
+func (c *Collector) load(guildID string) (*Summary, error) {
+	summary := newSummary()
+	if err := c.Store.Get(bucket, guildKey(guildID), summary); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("couldn't load usage analytics: %w", err)
+	}
+	return summary, nil
+}
+
+// guildKey keeps DMs, which have no guild, in their own summary.
+func guildKey(guildID string) string {
+	if guildID == "" {
+		return "dm"
+	}
+	return guildID
+}
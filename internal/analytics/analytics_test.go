@@ -0,0 +1,71 @@
+package analytics
+
+import (
+	"testing"
+
+	"slugbot/internal/eta"
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_SummaryIncludesFlushedAndPending(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	c := &Collector{Store: s}
+
+	c.Count("g1", ".saudio")
+	c.Count("g1", ".saudio")
+	c.Fail("g1", ".saudio")
+	c.Shape("g1", eta.Shape{Model: "small", Steps: 8, Length: 5})
+	require.NoError(t, c.Flush())
+
+	c.Count("g1", ".sim barrel")
+	c.Count("g2", ".saudio")
+
+	summary, err := c.Summary("g1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{".saudio": 2, ".sim barrel": 1}, summary.Commands)
+	require.Equal(t, 0.5, summary.FailureRate(".saudio"))
+	require.Equal(t, []string{".saudio", ".sim barrel"}, summary.CommandNames())
+	require.Equal(t, 1, summary.Params["length"]["≤10s"])
+	require.Equal(t, 1, summary.Params["model"]["small"])
+
+	// flushing again merges rather than overwrites
+	require.NoError(t, c.Flush())
+	reopened := &Collector{Store: s}
+	summary, err = reopened.Summary("g1")
+	require.NoError(t, err)
+	require.Equal(t, 2, summary.Commands[".saudio"])
+	require.Equal(t, 1, summary.Commands[".sim barrel"])
+}
+
+func TestCollector_ForgetGuild(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	c := &Collector{Store: s}
+
+	c.Count("g1", ".saudio")
+	c.Count("g2", ".saudio")
+	require.NoError(t, c.Flush())
+	c.Count("g1", ".sim barrel")
+
+	require.NoError(t, c.ForgetGuild("g1"))
+	require.NoError(t, c.Flush())
+	summary, err := c.Summary("g1")
+	require.NoError(t, err)
+	require.Empty(t, summary.Commands)
+	summary, err = c.Summary("g2")
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.Commands[".saudio"])
+}
+
+func TestCollector_NilRecordsNothing(t *testing.T) {
+	var c *Collector
+	c.Count("g1", ".saudio")
+	c.Fail("g1", ".saudio")
+	require.NoError(t, c.Flush())
+
+	_, err := c.Summary("g1")
+	require.Error(t, err)
+}
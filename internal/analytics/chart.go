@@ -0,0 +1,89 @@
+package analytics
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"slugbot/internal/format"
+	"slugbot/internal/utils"
+)
+
+// maxChartCommands keeps the chart readable in a Discord embed.
+const maxChartCommands = 15
+
+// Text renders a summary as a short plain-text table.
+func (s *Summary) Text() string {
+	if len(s.Commands) == 0 {
+		return "No usage recorded yet."
+	}
+
+	var b strings.Builder
+	b.WriteString("command                runs  failed\n")
+	for _, name := range s.CommandNames() {
+		fmt.Fprintf(&b, "%-20s %6d  %6s\n", name, s.Commands[name], format.Percent(s.FailureRate(name)))
+	}
+
+	params := make([]string, 0, len(s.Params))
+	for param := range s.Params {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+	for _, param := range params {
+		values := make([]string, 0, len(s.Params[param]))
+		for value, n := range s.Params[param] {
+			values = append(values, fmt.Sprintf("%s: %d", value, n))
+		}
+		sort.Strings(values)
+		fmt.Fprintf(&b, "\n%s — %s", param, strings.Join(values, ", "))
+	}
+	return b.String()
+}
+
+// RenderChart draws a stacked bar chart of successful and failed runs per
+// command into a PNG at outFile using gnuplot.
+func (s *Summary) RenderChart(title string, outFile string) error {
+	if _, err := exec.LookPath("gnuplot"); err != nil {
+		return fmt.Errorf("gnuplot isn't installed: %w", err)
+	}
+
+	data, err := os.CreateTemp(utils.TempDir(), "slugbot-stats-*.dat")
+	if err != nil {
+		return fmt.Errorf("couldn't create chart data file: %w", err)
+	}
+	defer os.Remove(data.Name())
+
+	names := s.CommandNames()
+	if len(names) > maxChartCommands {
+		names = names[:maxChartCommands]
+	}
+	for _, name := range names {
+		failed := s.Failures[name]
+		fmt.Fprintf(data, "%q %d %d\n", name, s.Commands[name]-failed, failed)
+	}
+	if err := data.Close(); err != nil {
+		return fmt.Errorf("couldn't write chart data file: %w", err)
+	}
+
+	script := fmt.Sprintf(`set terminal pngcairo size 900,450
+set output %q
+set title %q
+set style data histograms
+set style histogram rowstacked
+set style fill solid 0.8 border -1
+set boxwidth 0.7
+set xtics rotate by -30
+set ylabel "runs"
+set key top right
+plot %q using 2:xtic(1) title "succeeded" lc rgb "#5865F2", '' using 3 title "failed" lc rgb "#ED4245"
+`, outFile, title, data.Name())
+
+	command := exec.Command("gnuplot")
+	command.Stdin = strings.NewReader(script)
+	if out, err := command.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to render chart: %w\nOutput: %s", err, string(out))
+	}
+	return nil
+}
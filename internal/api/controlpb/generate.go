@@ -0,0 +1,3 @@
+package controlpb
+
+//go:generate protoc -I ../../../proto --go_out=../../.. --go_opt=module=slugbot --go-grpc_out=../../.. --go-grpc_opt=module=slugbot slugbot/v1/control.proto
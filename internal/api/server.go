@@ -0,0 +1,251 @@
+// Package api serves the Control gRPC service defined in
+// proto/slugbot/v1/control.proto.
+package api
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"slugbot/internal/api/controlpb"
+	"slugbot/internal/exec"
+
+	"github.com/bwmarrin/discordgo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements the Control service on top of the bot's queue.
+type Server struct {
+	controlpb.UnimplementedControlServer
+
+	Queue        *exec.TaskQueue
+	Submit       func(caller Caller, spec *controlpb.JobSpec) (*controlpb.SubmitJobResponse, error) // runs a validated job spec
+	Channels     []string                                                                           // channels jobs may be submitted to
+	Commands     []string                                                                           // top-level commands jobs may run
+	PollInterval time.Duration                                                                      // how often WatchJob checks for changes; defaults to a second
+}
+
+// Caller is who a request authenticated as. The zero Caller is the shared
+// service token, whose jobs aren't tied to any user.
+type Caller struct {
+	UserID   string
+	Username string
+}
+
+// Sees reports whether the caller may look at a job: the service token sees
+// every job, and a personal token only its owner's.
+func (c Caller) Sees(info exec.TaskInfo) bool {
+	return c.UserID == "" || c.UserID == ownerOf(info)
+}
+
+type callerKey struct{}
+
+// CallerFrom returns who the request in ctx authenticated as.
+func CallerFrom(ctx context.Context) Caller {
+	caller, _ := ctx.Value(callerKey{}).(Caller)
+	return caller
+}
+
+func (s *Server) SubmitJob(ctx context.Context, req *controlpb.SubmitJobRequest) (*controlpb.SubmitJobResponse, error) {
+	spec := req.GetSpec()
+	if spec == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing job spec")
+	}
+	if !slices.Contains(s.Channels, spec.GetChannelId()) {
+		return nil, status.Errorf(codes.PermissionDenied, "channel %q isn't open to the API", spec.GetChannelId())
+	}
+	fields := strings.Fields(spec.GetCommand())
+	if len(fields) < 2 || !slices.Contains(s.Commands, fields[0]) {
+		return nil, status.Errorf(codes.InvalidArgument, "command must be one of %s followed by its arguments", strings.Join(s.Commands, ", "))
+	}
+
+	resp, err := s.Submit(CallerFrom(ctx), spec)
+	if errors.Is(err, exec.ErrQueueFull) || errors.Is(err, exec.ErrOwnerBusy) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return resp, nil
+}
+
+func (s *Server) GetJob(ctx context.Context, req *controlpb.GetJobRequest) (*controlpb.JobStatus, error) {
+	info, ok := s.job(ctx, req.GetId())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no job %q", req.GetId())
+	}
+	return JobStatus(info), nil
+}
+
+func (s *Server) WatchJob(req *controlpb.WatchJobRequest, stream grpc.ServerStreamingServer[controlpb.ProgressEvent]) error {
+	interval := s.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last *controlpb.JobStatus
+	for {
+		info, ok := s.job(stream.Context(), req.GetId())
+		if !ok {
+			return status.Errorf(codes.NotFound, "no job %q", req.GetId())
+		}
+		current := JobStatus(info)
+		if last == nil || !sameStatus(last, current) {
+			if err := stream.Send(&controlpb.ProgressEvent{Status: current}); err != nil {
+				return err
+			}
+			last = current
+		}
+		if finished(info.State) {
+			return nil
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) CancelJob(ctx context.Context, req *controlpb.CancelJobRequest) (*controlpb.CancelJobResponse, error) {
+	if _, ok := s.job(ctx, req.GetId()); !ok {
+		return nil, status.Errorf(codes.NotFound, "no job %q", req.GetId())
+	}
+	return &controlpb.CancelJobResponse{Cancelled: s.Queue.CancelJob(req.GetId())}, nil
+}
+
+func (s *Server) ListQueue(ctx context.Context, req *controlpb.ListQueueRequest) (*controlpb.ListQueueResponse, error) {
+	resp := &controlpb.ListQueueResponse{}
+	caller := CallerFrom(ctx)
+	for _, info := range s.Queue.Jobs() {
+		if !caller.Sees(info) {
+			continue
+		}
+		resp.Jobs = append(resp.Jobs, JobStatus(info))
+	}
+	return resp, nil
+}
+
+// job looks up a job the caller in ctx may see.
+func (s *Server) job(ctx context.Context, id string) (exec.TaskInfo, bool) {
+	info, ok := s.Queue.Info(id)
+	if !ok || !CallerFrom(ctx).Sees(info) {
+		return exec.TaskInfo{}, false
+	}
+	return info, true
+}
+
+// JobStatus converts the queue's record of a job to its API form.
+func JobStatus(info exec.TaskInfo) *controlpb.JobStatus {
+	js := &controlpb.JobStatus{
+		Id:       info.ID,
+		State:    jobStates[info.State],
+		Position: int32(info.Position),
+		Prompt:   info.Task.Prompt(),
+		TraceId:  info.Task.TraceID(),
+		Enqueued: timestamp(info.Enqueued),
+		Started:  timestamp(info.Started),
+		Finished: timestamp(info.Finished),
+	}
+	if triggered, ok := info.Task.(exec.Triggered); ok {
+		js.MessageId = triggered.MessageID()
+	}
+	if progressing, ok := info.Task.(exec.Progressing); ok && info.State == exec.StateRunning {
+		js.Progress = progressing.Progress()
+	}
+	if info.Err != nil {
+		js.Error = info.Err.Error()
+	}
+	return js
+}
+
+var jobStates = map[exec.TaskState]controlpb.JobState{
+	exec.StateWaiting:   controlpb.JobState_JOB_STATE_WAITING,
+	exec.StateRunning:   controlpb.JobState_JOB_STATE_RUNNING,
+	exec.StateDone:      controlpb.JobState_JOB_STATE_DONE,
+	exec.StateFailed:    controlpb.JobState_JOB_STATE_FAILED,
+	exec.StateCancelled: controlpb.JobState_JOB_STATE_CANCELLED,
+}
+
+func finished(state exec.TaskState) bool {
+	return state == exec.StateDone || state == exec.StateFailed || state == exec.StateCancelled
+}
+
+// sameStatus compares the fields of a status that change as a job moves through the queue.
+func sameStatus(a, b *controlpb.JobStatus) bool {
+	return a.GetState() == b.GetState() && a.GetPosition() == b.GetPosition() && a.GetProgress() == b.GetProgress()
+}
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// BearerAuth returns interceptors that require "authorization: Bearer <token>"
+// metadata that authenticate accepts, and record who the caller is for
+// CallerFrom.
+func BearerAuth(authenticate func(token string) (Caller, bool)) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	check := func(ctx context.Context) (context.Context, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, got := range md.Get("authorization") {
+			token, ok := strings.CutPrefix(got, "Bearer ")
+			if !ok || token == "" {
+				continue
+			}
+			if caller, ok := authenticate(token); ok {
+				return context.WithValue(ctx, callerKey{}, caller), nil
+			}
+		}
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+	}
+	unary := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := check(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+	stream := func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := check(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+	}
+	return unary, stream
+}
+
+// authedStream carries the caller in its context.
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authedStream) Context() context.Context {
+	return s.ctx
+}
+
+// ownerOf returns the ID of the user a job runs as, if it's known.
+func ownerOf(info exec.TaskInfo) string {
+	triggered, ok := info.Task.(interface {
+		TriggerMessage() *discordgo.MessageCreate
+	})
+	if !ok {
+		return ""
+	}
+	if m := triggered.TriggerMessage(); m != nil && m.Message != nil && m.Author != nil {
+		return m.Author.ID
+	}
+	return ""
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"slugbot/internal/api/controlpb"
+	"slugbot/internal/exec"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeTask struct {
+	messageID string
+	author    string
+	started   chan struct{}
+	release   chan struct{}
+}
+
+func newFakeTask(messageID string) *fakeTask {
+	return &fakeTask{messageID: messageID, started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (t *fakeTask) Apply() error {
+	close(t.started)
+	<-t.release
+	return nil
+}
+func (t *fakeTask) HandleError(error)                   {}
+func (t *fakeTask) Prompt() string                      { return "prompt " + t.messageID }
+func (t *fakeTask) TraceID() string                     { return t.messageID }
+func (t *fakeTask) TraceContext() context.Context       { return context.Background() }
+func (t *fakeTask) SetTraceContext(ctx context.Context) {}
+func (t *fakeTask) MessageID() string                   { return t.messageID }
+func (t *fakeTask) TriggerMessage() *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{Message: &discordgo.Message{ID: t.messageID, Author: &discordgo.User{ID: t.author}}}
+}
+
+// dial serves s over an in-memory connection and returns a client for it.
+// token is the service token; "user:<id>" tokens act as personal tokens of user <id>.
+func dial(t *testing.T, s *Server, token string) controlpb.ControlClient {
+	listener := bufconn.Listen(1 << 20)
+	unary, stream := BearerAuth(func(got string) (Caller, bool) {
+		if userID, ok := strings.CutPrefix(got, "user:"); ok {
+			return Caller{UserID: userID, Username: "user " + userID}, true
+		}
+		return Caller{}, got == token
+	})
+	server := grpc.NewServer(grpc.UnaryInterceptor(unary), grpc.StreamInterceptor(stream))
+	controlpb.RegisterControlServer(server, s)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return controlpb.NewControlClient(conn)
+}
+
+func authed(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestServer_RequiresBearerToken(t *testing.T) {
+	client := dial(t, &Server{Queue: exec.NewTaskQueue()}, "s3cret")
+
+	_, err := client.ListQueue(context.Background(), &controlpb.ListQueueRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.ListQueue(authed("wrong"), &controlpb.ListQueueRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.ListQueue(authed("s3cret"), &controlpb.ListQueueRequest{})
+	require.NoError(t, err)
+}
+
+func TestServer_ListWatchAndCancel(t *testing.T) {
+	q := exec.NewTaskQueue()
+	client := dial(t, &Server{Queue: q, PollInterval: 5 * time.Millisecond}, "tok")
+	ctx := authed("tok")
+
+	running := newFakeTask("running")
+	q.Enqueue(running)
+	<-running.started
+	q.Enqueue(newFakeTask("waiting"))
+
+	list, err := client.ListQueue(ctx, &controlpb.ListQueueRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.Jobs, 2)
+	require.Equal(t, controlpb.JobState_JOB_STATE_RUNNING, list.Jobs[0].State)
+	require.Equal(t, "waiting", list.Jobs[1].MessageId)
+	require.EqualValues(t, 1, list.Jobs[1].Position)
+
+	cancelled, err := client.CancelJob(ctx, &controlpb.CancelJobRequest{Id: list.Jobs[1].Id})
+	require.NoError(t, err)
+	require.True(t, cancelled.Cancelled)
+
+	watch, err := client.WatchJob(ctx, &controlpb.WatchJobRequest{Id: list.Jobs[0].Id})
+	require.NoError(t, err)
+	event, err := watch.Recv()
+	require.NoError(t, err)
+	require.Equal(t, controlpb.JobState_JOB_STATE_RUNNING, event.Status.State)
+
+	close(running.release)
+	event, err = watch.Recv()
+	require.NoError(t, err)
+	require.Equal(t, controlpb.JobState_JOB_STATE_DONE, event.Status.State)
+	require.NotNil(t, event.Status.Finished)
+
+	_, err = client.GetJob(ctx, &controlpb.GetJobRequest{Id: "ZZZZ"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_SubmitJobChecksChannelAndCommand(t *testing.T) {
+	var submitted []*controlpb.JobSpec
+	client := dial(t, &Server{
+		Queue:    exec.NewTaskQueue(),
+		Channels: []string{"c1"},
+		Commands: []string{".saudio"},
+		Submit: func(caller Caller, spec *controlpb.JobSpec) (*controlpb.SubmitJobResponse, error) {
+			submitted = append(submitted, spec)
+			return &controlpb.SubmitJobResponse{MessageId: "m1", JobIds: []string{"ABCD"}}, nil
+		},
+	}, "tok")
+	ctx := authed("tok")
+
+	resp, err := client.SubmitJob(ctx, &controlpb.SubmitJobRequest{Spec: &controlpb.JobSpec{ChannelId: "c1", Command: ".saudio rain"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"ABCD"}, resp.JobIds)
+
+	_, err = client.SubmitJob(ctx, &controlpb.SubmitJobRequest{Spec: &controlpb.JobSpec{ChannelId: "c2", Command: ".saudio rain"}})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.SubmitJob(ctx, &controlpb.SubmitJobRequest{Spec: &controlpb.JobSpec{ChannelId: "c1", Command: ".sadmin stats"}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Len(t, submitted, 1)
+}
+
+func TestServer_PersonalTokens(t *testing.T) {
+	q := exec.NewTaskQueue()
+	running := newFakeTask("m1")
+	defer close(running.release)
+	q.Enqueue(running)
+	<-running.started
+	mine, theirs := newFakeTask("m2"), newFakeTask("m3")
+	mine.author, theirs.author = "u1", "u2"
+	q.Enqueue(mine)
+	q.Enqueue(theirs)
+
+	var callers []Caller
+	client := dial(t, &Server{
+		Queue:    q,
+		Channels: []string{"c1"},
+		Commands: []string{".saudio"},
+		Submit: func(caller Caller, spec *controlpb.JobSpec) (*controlpb.SubmitJobResponse, error) {
+			callers = append(callers, caller)
+			return &controlpb.SubmitJobResponse{}, nil
+		},
+	}, "tok")
+
+	_, err := client.SubmitJob(authed("user:u1"), &controlpb.SubmitJobRequest{Spec: &controlpb.JobSpec{ChannelId: "c1", Command: ".saudio rain"}})
+	require.NoError(t, err)
+	_, err = client.SubmitJob(authed("tok"), &controlpb.SubmitJobRequest{Spec: &controlpb.JobSpec{ChannelId: "c1", Command: ".saudio rain"}})
+	require.NoError(t, err)
+	require.Equal(t, []Caller{{UserID: "u1", Username: "user u1"}, {}}, callers)
+
+	jobs := q.Jobs()
+	listed, err := client.ListQueue(authed("user:u1"), &controlpb.ListQueueRequest{})
+	require.NoError(t, err)
+	require.Len(t, listed.Jobs, 1)
+	require.Equal(t, jobs[1].ID, listed.Jobs[0].Id)
+	listed, err = client.ListQueue(authed("tok"), &controlpb.ListQueueRequest{})
+	require.NoError(t, err)
+	require.Len(t, listed.Jobs, 3)
+
+	_, err = client.GetJob(authed("user:u1"), &controlpb.GetJobRequest{Id: jobs[2].ID})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	watch, err := client.WatchJob(authed("user:u1"), &controlpb.WatchJobRequest{Id: jobs[2].ID})
+	require.NoError(t, err)
+	_, err = watch.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.CancelJob(authed("user:u1"), &controlpb.CancelJobRequest{Id: jobs[2].ID})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	resp, err := client.CancelJob(authed("user:u1"), &controlpb.CancelJobRequest{Id: jobs[1].ID})
+	require.NoError(t, err)
+	require.True(t, resp.Cancelled)
+	resp, err = client.CancelJob(authed("tok"), &controlpb.CancelJobRequest{Id: jobs[2].ID})
+	require.NoError(t, err)
+	require.True(t, resp.Cancelled)
+}
@@ -0,0 +1,194 @@
+// Package apitoken issues and checks personal API tokens, which tie webhook
+// and gRPC submissions to the Discord user who created the token.
+//
+// A token looks like "slug_<id>_<secret>". Only a hash of the secret is
+// stored, so a token can't be recovered once it's been handed out.
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"slugbot/internal/store"
+)
+
+const (
+	bucket = "api_tokens"
+	prefix = "slug_"
+
+	// MaxPerUser bounds how many tokens one user may hold at once.
+	MaxPerUser = 5
+
+	// lastUsedEvery throttles how often a token's LastUsed is written back.
+	lastUsedEvery = time.Minute
+)
+
+var (
+	ErrInvalid = errors.New("invalid API token")
+	ErrTooMany = fmt.Errorf("you already have %d API tokens; revoke one first", MaxPerUser)
+)
+
+// Token is an issued token, without its secret.
+type Token struct {
+	ID       string    `json:"id"` // public part of the token, shown in listings
+	UserID   string    `json:"user_id"`
+	Username string    `json:"username"`
+	Name     string    `json:"name"` // the owner's label for it, e.g. "daw-plugin"
+	Created  time.Time `json:"created"`
+	LastUsed time.Time `json:"last_used"` // zero if it's never been used
+	Hash     string    `json:"hash"`      // hex SHA-256 of the secret
+}
+
+// Registry keeps issued tokens in a store.
+type Registry struct {
+	Store *store.Store
+
+	mutex sync.Mutex
+}
+
+// Create issues a new token for a user. The returned string is the only time
+// the whole token is available.
+func (r *Registry) Create(userID string, username string, name string, now time.Time) (string, Token, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	owned, err := r.listLocked(userID)
+	if err != nil {
+		return "", Token{}, err
+	}
+	if len(owned) >= MaxPerUser {
+		return "", Token{}, ErrTooMany
+	}
+
+	id, secret := randomString(6), randomString(24)
+	for {
+		var existing Token
+		if err := r.Store.Get(bucket, id, &existing); errors.Is(err, store.ErrNotFound) {
+			break
+		} else if err != nil {
+			return "", Token{}, err
+		}
+		id = randomString(6)
+	}
+
+	token := Token{ID: id, UserID: userID, Username: username, Name: name, Created: now, Hash: hash(secret)}
+	if err := r.Store.Put(bucket, id, token); err != nil {
+		return "", Token{}, err
+	}
+	return prefix + id + "_" + secret, token, nil
+}
+
+// Validate checks a token and returns who it belongs to.
+func (r *Registry) Validate(plaintext string, now time.Time) (Token, error) {
+	rest, ok := strings.CutPrefix(plaintext, prefix)
+	if !ok {
+		return Token{}, ErrInvalid
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return Token{}, ErrInvalid
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var token Token
+	if err := r.Store.Get(bucket, id, &token); errors.Is(err, store.ErrNotFound) {
+		return Token{}, ErrInvalid
+	} else if err != nil {
+		return Token{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hash(secret)), []byte(token.Hash)) != 1 {
+		return Token{}, ErrInvalid
+	}
+
+	if now.Sub(token.LastUsed) >= lastUsedEvery {
+		token.LastUsed = now
+		if err := r.Store.Put(bucket, id, token); err != nil {
+			return Token{}, err
+		}
+	}
+	return token, nil
+}
+
+// List returns a user's tokens, oldest first.
+func (r *Registry) List(userID string) ([]Token, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.listLocked(userID)
+}
+
+func (r *Registry) listLocked(userID string) ([]Token, error) {
+	ids, err := r.Store.Keys(bucket)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []Token
+	for _, id := range ids {
+		var token Token
+		if err := r.Store.Get(bucket, id, &token); err != nil {
+			return nil, err
+		}
+		if token.UserID == userID {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Created.Before(tokens[j].Created) })
+	return tokens, nil
+}
+
+// Revoke deletes one of a user's tokens. It reports false if the user has no token with that ID.
+func (r *Registry) Revoke(userID string, id string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var token Token
+	if err := r.Store.Get(bucket, id, &token); errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if token.UserID != userID {
+		return false, nil
+	}
+	return true, r.Store.Delete(bucket, id)
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomString returns n random bytes, base64url-encoded without underscores
+// so tokens split cleanly on them.
+func randomString(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return strings.ReplaceAll(base64.RawURLEncoding.EncodeToString(b), "_", "-")
+}
+
+// RevokeAll deletes all of a user's tokens and returns how many there were.
+func (r *Registry) RevokeAll(userID string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	tokens, err := r.listLocked(userID)
+	if err != nil {
+		return 0, err
+	}
+	for i, token := range tokens {
+		if err := r.Store.Delete(bucket, token.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(tokens), nil
+}
@@ -0,0 +1,85 @@
+package apitoken
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newRegistry(t *testing.T) *Registry {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	return &Registry{Store: s}
+}
+
+func TestRegistry_CreateAndValidate(t *testing.T) {
+	registry := newRegistry(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	plaintext, token, err := registry.Create("u1", "slug", "daw", now)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(plaintext, "slug_"+token.ID+"_"))
+	require.NotContains(t, token.Hash, strings.TrimPrefix(plaintext, "slug_"+token.ID+"_"))
+
+	found, err := registry.Validate(plaintext, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, "u1", found.UserID)
+	require.Equal(t, "slug", found.Username)
+	require.Equal(t, now.Add(time.Hour), found.LastUsed)
+
+	for _, bad := range []string{"", "slug_", plaintext + "x", "slug_" + token.ID + "_wrong", strings.TrimPrefix(plaintext, "slug_")} {
+		_, err := registry.Validate(bad, now)
+		require.ErrorIs(t, err, ErrInvalid, bad)
+	}
+}
+
+func TestRegistry_ListAndRevoke(t *testing.T) {
+	registry := newRegistry(t)
+	now := time.Now()
+
+	plaintext, first, err := registry.Create("u1", "slug", "first", now)
+	require.NoError(t, err)
+	_, _, err = registry.Create("u1", "slug", "second", now.Add(time.Second))
+	require.NoError(t, err)
+	_, _, err = registry.Create("u2", "snail", "", now)
+	require.NoError(t, err)
+
+	tokens, err := registry.List("u1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"first", "second"}, []string{tokens[0].Name, tokens[1].Name})
+
+	// only the owner can revoke a token
+	revoked, err := registry.Revoke("u2", first.ID)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	revoked, err = registry.Revoke("u1", first.ID)
+	require.NoError(t, err)
+	require.True(t, revoked)
+	_, err = registry.Validate(plaintext, now)
+	require.ErrorIs(t, err, ErrInvalid)
+
+	revokedAll, err := registry.RevokeAll("u1")
+	require.NoError(t, err)
+	require.Equal(t, 1, revokedAll)
+	tokens, err = registry.List("u1")
+	require.NoError(t, err)
+	require.Empty(t, tokens)
+	tokens, err = registry.List("u2")
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+}
+
+func TestRegistry_LimitsTokensPerUser(t *testing.T) {
+	registry := newRegistry(t)
+	for range MaxPerUser {
+		_, _, err := registry.Create("u1", "slug", "", time.Now())
+		require.NoError(t, err)
+	}
+	_, _, err := registry.Create("u1", "slug", "", time.Now())
+	require.ErrorIs(t, err, ErrTooMany)
+}
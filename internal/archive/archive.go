@@ -0,0 +1,173 @@
+// Package archive mirrors a guild's results into a channel or forum its admins
+// choose, so the server keeps a browsable gallery of what it's generated.
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"slugbot/internal/helpers"
+	"slugbot/internal/policy"
+	"slugbot/internal/results"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxThreadName is the most a forum post's title can be, in characters.
+const maxThreadName = 100
+
+// downloadParallelism bounds how many of a result's files download at once.
+const downloadParallelism = 4
+
+// Session is the part of a Discord session archiving uses.
+type Session interface {
+	Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ForumThreadStartComplex(channelID string, threadData *discordgo.ThreadStart, messageData *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+}
+
+// Archiver posts copies of results to their guild's archive channel, per
+// `.sadmin archive`.
+type Archiver struct {
+	Policies *policy.Policies
+}
+
+// Mirror posts a copy of posted, a result in guildID, with its files and what
+// it was generated from, to the guild's archive: as a message in a text
+// channel, or as a post titled with the prompt in a forum. fields without a
+// prompt, like those of a result published from a preview, are shown as the
+// result's own text instead. It does nothing if the guild has no archive or
+// the result was posted in it.
+func (a *Archiver) Mirror(api Session, guildID string, posted *discordgo.Message, fields results.Fields) error {
+	if a == nil || posted == nil || len(posted.Attachments) == 0 {
+		return nil
+	}
+	if guildID == "" {
+		channel, err := api.Channel(posted.ChannelID)
+		if err != nil {
+			return fmt.Errorf("couldn't look up the result's channel: %w", err)
+		}
+		guildID = channel.GuildID
+	}
+	archiveID, err := a.Policies.ResultArchive(guildID)
+	if err != nil || archiveID == "" || archiveID == posted.ChannelID {
+		return err
+	}
+	archive, err := api.Channel(archiveID)
+	if err != nil {
+		return fmt.Errorf("couldn't look up archive channel %s: %w", archiveID, err)
+	}
+
+	files, cleanup, err := download(posted.Attachments)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	send := &discordgo.MessageSend{
+		Content: caption(guildID, posted, fields),
+		Files:   files,
+		// the archive shows who asked for each result without pinging them again
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	}
+
+	if archive.Type == discordgo.ChannelTypeGuildForum || archive.Type == discordgo.ChannelTypeGuildMedia {
+		_, err = api.ForumThreadStartComplex(archiveID, &discordgo.ThreadStart{Name: threadName(fields, posted)}, send)
+	} else {
+		_, err = api.ChannelMessageSendComplex(archiveID, send)
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't post result %s to archive channel %s: %w", posted.ID, archiveID, err)
+	}
+	return nil
+}
+
+// caption describes an archived result: its prompt, model, seed, and
+// submitter, or the text it was posted with, and a link back to it.
+func caption(guildID string, posted *discordgo.Message, fields results.Fields) string {
+	var lines []string
+	if fields.Prompt == "" {
+		lines = append(lines, quote(truncate(posted.Content, results.MaxLength)))
+	} else {
+		lines = append(lines, "**Prompt:** "+truncate(fields.Prompt, results.MaxLength))
+		var details []string
+		if fields.Model != "" {
+			details = append(details, "**Model:** "+fields.Model)
+		}
+		if fields.Seed >= 0 {
+			details = append(details, fmt.Sprintf("**Seed:** %d", fields.Seed))
+		}
+		if fields.Submitter != "" {
+			details = append(details, "**By:** "+fields.Submitter)
+		}
+		lines = append(lines, strings.Join(details, " · "))
+	}
+	lines = append(lines, fmt.Sprintf("-# https://discord.com/channels/%s/%s/%s", guildID, posted.ChannelID, posted.ID))
+	return strings.Join(slices.DeleteFunc(lines, func(line string) bool { return line == "" }), "\n")
+}
+
+// threadName titles a result's forum post with its prompt.
+func threadName(fields results.Fields, posted *discordgo.Message) string {
+	name := strings.Join(strings.Fields(fields.Prompt), " ")
+	if name == "" {
+		name = "Result " + posted.ID
+	}
+	return truncate(name, maxThreadName)
+}
+
+// download fetches a result's attachments, to post them again. cleanup
+// removes the downloaded files.
+func download(attachments []*discordgo.MessageAttachment) ([]*discordgo.File, func(), error) {
+	urls := make([]string, len(attachments))
+	patterns := make([]string, len(attachments))
+	for i, attachment := range attachments {
+		urls[i] = attachment.URL
+		patterns[i] = "archive-*" + filepath.Ext(attachment.Filename)
+	}
+	paths, err := helpers.DownloadFiles(urls, patterns, downloadParallelism)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't download a result's files to archive them: %w", err)
+	}
+
+	var opened []*os.File
+	cleanup := func() {
+		for _, file := range opened {
+			file.Close()
+		}
+		for _, path := range paths {
+			os.Remove(path)
+		}
+	}
+
+	var files []*discordgo.File
+	for i, attachment := range attachments {
+		file, err := os.Open(paths[i])
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		opened = append(opened, file)
+		files = append(files, &discordgo.File{Name: attachment.Filename, ContentType: attachment.ContentType, Reader: file})
+	}
+	return files, cleanup, nil
+}
+
+func quote(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "> " + strings.ReplaceAll(text, "\n", "\n> ")
+}
+
+// truncate cuts text to at most limit characters, ending it with an ellipsis
+// if anything was cut.
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:limit-1]) + "…"
+}
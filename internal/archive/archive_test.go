@@ -0,0 +1,106 @@
+package archive
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"slugbot/internal/policy"
+	"slugbot/internal/results"
+	"slugbot/internal/store"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSession struct {
+	channels map[string]*discordgo.Channel
+	sent     []string // channel IDs posted to
+	threads  []string // titles of forum posts started
+	content  []string
+	files    []string // the contents of every file posted
+}
+
+func (f *fakeSession) Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	return f.channels[channelID], nil
+}
+
+func (f *fakeSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.sent = append(f.sent, channelID)
+	f.record(data)
+	return &discordgo.Message{ChannelID: channelID}, nil
+}
+
+func (f *fakeSession) ForumThreadStartComplex(channelID string, threadData *discordgo.ThreadStart, messageData *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	f.threads = append(f.threads, threadData.Name)
+	f.record(messageData)
+	return &discordgo.Channel{ParentID: channelID}, nil
+}
+
+func (f *fakeSession) record(data *discordgo.MessageSend) {
+	f.content = append(f.content, data.Content)
+	for _, file := range data.Files {
+		body, _ := io.ReadAll(file.Reader)
+		f.files = append(f.files, string(body))
+	}
+}
+
+func newArchiver(t *testing.T) *Archiver {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	return &Archiver{Policies: &policy.Policies{Store: s}}
+}
+
+func TestArchiver_MirrorsResultsIntoTheGuildsArchive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "audio")
+	}))
+	t.Cleanup(server.Close)
+
+	archiver := newArchiver(t)
+	require.NoError(t, archiver.Policies.SetGuildResults("g1", policy.Results{Archive: "archive"}))
+	api := &fakeSession{channels: map[string]*discordgo.Channel{
+		"results": {ID: "results", GuildID: "g1", Type: discordgo.ChannelTypeGuildText},
+		"archive": {ID: "archive", GuildID: "g1", Type: discordgo.ChannelTypeGuildText},
+	}}
+	posted := &discordgo.Message{ID: "m1", ChannelID: "results", Attachments: []*discordgo.MessageAttachment{
+		{Filename: "lofi.wav", URL: server.URL + "/lofi.wav"},
+	}}
+
+	fields := results.Fields{Prompt: "lofi", Seed: 42, Model: "Stable Audio Open 1.0", Submitter: "<@u1>"}
+	require.NoError(t, archiver.Mirror(api, "g1", posted, fields))
+	require.Equal(t, []string{"archive"}, api.sent)
+	require.Equal(t, []string{"audio"}, api.files)
+	require.Equal(t, "**Prompt:** lofi\n**Model:** Stable Audio Open 1.0 · **Seed:** 42 · **By:** <@u1>\n-# https://discord.com/channels/g1/results/m1", api.content[0])
+
+	// a published preview only has its own text, and no guild ID
+	posted.Content = "lofi, by <@u1>"
+	require.NoError(t, archiver.Mirror(api, "", posted, results.Fields{}))
+	require.Equal(t, "> lofi, by <@u1>\n-# https://discord.com/channels/g1/results/m1", api.content[1])
+
+	// another guild has no archive
+	require.NoError(t, archiver.Mirror(api, "g2", posted, fields))
+	require.Len(t, api.sent, 2)
+}
+
+func TestArchiver_StartsAForumPostPerResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "audio")
+	}))
+	t.Cleanup(server.Close)
+
+	archiver := newArchiver(t)
+	require.NoError(t, archiver.Policies.SetGuildResults("g1", policy.Results{Archive: "gallery"}))
+	api := &fakeSession{channels: map[string]*discordgo.Channel{
+		"gallery": {ID: "gallery", GuildID: "g1", Type: discordgo.ChannelTypeGuildForum},
+	}}
+	posted := &discordgo.Message{ID: "m1", ChannelID: "results", Attachments: []*discordgo.MessageAttachment{
+		{Filename: "lofi.wav", URL: server.URL + "/lofi.wav"},
+	}}
+
+	require.NoError(t, archiver.Mirror(api, "g1", posted, results.Fields{Prompt: "lofi\nhip   hop", Seed: -1}))
+	require.Empty(t, api.sent)
+	require.Equal(t, []string{"lofi hip hop"}, api.threads)
+	require.Equal(t, []string{"audio"}, api.files)
+}
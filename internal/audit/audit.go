@@ -0,0 +1,112 @@
+// Package audit keeps a log of privacy-relevant actions, like deleting a
+// user's results, so admins can see who did what and when.
+package audit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"slugbot/internal/store"
+)
+
+const bucket = "audit"
+
+// Entry is one logged action.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	GuildID string    `json:"guild_id"` // empty for actions in DMs
+	ActorID string    `json:"actor_id"` // who did it
+	UserID  string    `json:"user_id"`  // whose data it concerned
+	Action  string    `json:"action"`   // e.g. "delete"
+	Detail  string    `json:"detail"`   // what was affected, without any content
+}
+
+// Log keeps entries in a store.
+type Log struct {
+	Store *store.Store
+
+	mutex sync.Mutex
+}
+
+// Record adds an entry.
+func (l *Log) Record(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	// keys sort by time, and the suffix keeps entries from the same instant apart
+	key := fmt.Sprintf("%020d-%s", entry.Time.UnixNano(), hex.EncodeToString(suffix))
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.Store.Put(bucket, key, entry)
+}
+
+// List returns a guild's entries, newest first, at most limit of them if limit is positive.
+func (l *Log) List(guildID string, limit int) ([]Entry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	keys, err := l.Store.Keys(bucket)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+	var entries []Entry
+	for _, key := range keys {
+		var entry Entry
+		if err := l.Store.Get(bucket, key, &entry); err != nil {
+			return nil, err
+		}
+		if entry.GuildID != guildID {
+			continue
+		}
+		entries = append(entries, entry)
+		if limit > 0 && len(entries) == limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// PurgeGuild deletes every entry of a guild, and returns how many it deleted.
+func (l *Log) PurgeGuild(guildID string) (int, error) {
+	return l.purge(func(entry Entry) bool { return entry.GuildID == guildID })
+}
+
+// Purge deletes every entry about a user or made by them, and returns how many it deleted.
+func (l *Log) Purge(userID string) (int, error) {
+	return l.purge(func(entry Entry) bool { return entry.UserID == userID || entry.ActorID == userID })
+}
+
+// purge deletes the entries matched returns true for.
+func (l *Log) purge(matched func(Entry) bool) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	keys, err := l.Store.Keys(bucket)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, key := range keys {
+		var entry Entry
+		if err := l.Store.Get(bucket, key, &entry); err != nil {
+			return purged, err
+		}
+		if !matched(entry) {
+			continue
+		}
+		if err := l.Store.Delete(bucket, key); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog_ListsGuildEntriesNewestFirst(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	log := &Log{Store: s}
+
+	start := time.Now()
+	for i, guild := range []string{"g1", "g2", "g1", "g1"} {
+		require.NoError(t, log.Record(Entry{Time: start.Add(time.Duration(i) * time.Second), GuildID: guild, Action: "delete", Detail: string(rune('a' + i))}))
+	}
+
+	entries, err := log.List("g1", 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{"d", "c", "a"}, []string{entries[0].Detail, entries[1].Detail, entries[2].Detail})
+
+	entries, err = log.List("g1", 2)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	entries, err = log.List("g3", 0)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestLog_PurgeRemovesEntriesAboutOrByUser(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	log := &Log{Store: s}
+
+	require.NoError(t, log.Record(Entry{GuildID: "g1", ActorID: "u1", UserID: "u1", Action: "delete"}))
+	require.NoError(t, log.Record(Entry{GuildID: "g1", ActorID: "admin", UserID: "u1", Action: "delete"}))
+	require.NoError(t, log.Record(Entry{GuildID: "g1", ActorID: "u1", UserID: "u2", Action: "delete"}))
+	require.NoError(t, log.Record(Entry{GuildID: "g1", ActorID: "admin", UserID: "u2", Action: "delete", Detail: "kept"}))
+
+	purged, err := log.Purge("u1")
+	require.NoError(t, err)
+	require.Equal(t, 3, purged)
+
+	entries, err := log.List("g1", 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "kept", entries[0].Detail)
+}
+
+func TestLog_PurgeGuild(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	log := &Log{Store: s}
+
+	require.NoError(t, log.Record(Entry{GuildID: "g1", ActorID: "u1", UserID: "u1", Action: "delete"}))
+	require.NoError(t, log.Record(Entry{GuildID: "g1", ActorID: "admin", UserID: "u2", Action: "delete"}))
+	require.NoError(t, log.Record(Entry{GuildID: "g2", ActorID: "u1", UserID: "u1", Action: "delete"}))
+
+	purged, err := log.PurgeGuild("g1")
+	require.NoError(t, err)
+	require.Equal(t, 2, purged)
+
+	entries, err := log.List("g1", 0)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+	entries, err = log.List("g2", 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
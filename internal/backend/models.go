@@ -0,0 +1,137 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"slugbot/internal/io/slog"
+	"slugbot/internal/store"
+	"slugbot/internal/tools"
+	"slugbot/internal/utils"
+)
+
+const (
+	bucket    = "backend"
+	activeKey = "model"
+)
+
+// modelNameRegex keeps model names to a single directory under the models dir.
+var modelNameRegex = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Models tracks which checkpoint sag uses for full-size generations. sag loads
+// the checkpoint on every run, so switching only changes the `--model_dir` that
+// later jobs are started with.
+type Models struct {
+	Dir   string       // directory holding one subdirectory per checkpoint, relative to the project root
+	Store *store.Store // optional; remembers the active model across restarts
+
+	mutex  sync.Mutex
+	active string // "" means sag's default checkpoint
+}
+
+// Load restores the active model from the store.
+func (m *Models) Load() error {
+	if m.Store == nil {
+		return nil
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var active string
+	if err := m.Store.Get(bucket, activeKey, &active); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("couldn't load active model: %w", err)
+	}
+	m.active = active
+	return nil
+}
+
+// Active returns the name of the checkpoint full-size jobs use, or "" for sag's default.
+func (m *Models) Active() string {
+	if m == nil {
+		return ""
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.active
+}
+
+// Args returns the sag arguments that select the active checkpoint.
+func (m *Models) Args() []string {
+	if active := m.Active(); active != "" {
+		return []string{"--model_dir", m.Path(active)}
+	}
+	return nil
+}
+
+// Validate checks that a checkpoint with the given name is installed.
+func (m *Models) Validate(name string) error {
+	if !modelNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid model name `%s`", name)
+	}
+	for _, file := range []string{"model_config.json", "model.ckpt"} {
+		if _, err := os.Stat(filepath.Join(m.Path(name), file)); err != nil {
+			return fmt.Errorf("model `%s` is missing %s", name, file)
+		}
+	}
+	return nil
+}
+
+// SmokeTest runs a tiny generation with a checkpoint to make sure it loads and produces audio.
+func (m *Models) SmokeTest(ctx context.Context, name string) error {
+	dir, err := os.MkdirTemp(utils.TempDir(), "slugbot-smoke-*")
+	if err != nil {
+		return fmt.Errorf("couldn't create smoke test dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "smoke.wav")
+	command := exec.CommandContext(ctx, tools.Path(tools.Sag),
+		"--prompt", "smoke test: short sine tone",
+		"--negative_prompt", "",
+		"--output", output,
+		"--length", "1",
+		"--seed", "1",
+		"--steps", "4",
+		"--model_dir", m.Path(name),
+	)
+	if out, err := command.CombinedOutput(); err != nil {
+		return fmt.Errorf("smoke generation failed: %w\nOutput: %s", err, tail(string(out), 1500))
+	}
+	if info, err := os.Stat(output); err != nil || info.Size() == 0 {
+		return fmt.Errorf("smoke generation didn't produce any audio")
+	}
+	return nil
+}
+
+// Switch makes a checkpoint the active one for later jobs.
+func (m *Models) Switch(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.Store != nil {
+		if err := m.Store.Put(bucket, activeKey, name); err != nil {
+			return fmt.Errorf("couldn't save active model: %w", err)
+		}
+	}
+	slog.Info("switched active model from '", m.active, "' to '", name, "'")
+	m.active = name
+	return nil
+}
+
+// Path returns where a checkpoint with the given name is installed.
+func (m *Models) Path(name string) string {
+	return filepath.Join(m.Dir, name)
+}
+
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "..." + s[len(s)-n:]
+}
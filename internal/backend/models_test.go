@@ -0,0 +1,44 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestModels_Validate(t *testing.T) {
+	dir := t.TempDir()
+	m := &Models{Dir: dir}
+
+	require.Error(t, m.Validate("../etc"))
+	require.Error(t, m.Validate("missing"))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "tuned"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tuned", "model_config.json"), []byte("{}"), 0o644))
+	require.ErrorContains(t, m.Validate("tuned"), "model.ckpt")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tuned", "model.ckpt"), []byte("x"), 0o644))
+	require.NoError(t, m.Validate("tuned"))
+}
+
+func TestModels_SwitchPersists(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+
+	m := &Models{Dir: "models", Store: s}
+	require.Nil(t, m.Args())
+	require.NoError(t, m.Switch("tuned"))
+	require.Equal(t, []string{"--model_dir", filepath.Join("models", "tuned")}, m.Args())
+
+	reloaded := &Models{Dir: "models", Store: s}
+	require.NoError(t, reloaded.Load())
+	require.Equal(t, "tuned", reloaded.Active())
+
+	var none *Models
+	require.Empty(t, none.Active())
+	require.Nil(t, none.Args())
+}
@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"slugbot/internal/utils"
+)
+
+// Cache stores downloaded files by content hash so repeated processing of the
+// same attachment (e.g. iterating on `.sim` parameters) doesn't re-download it.
+// Callers always receive their own temp copy, which they're free to delete.
+type Cache struct {
+	Dir string
+	TTL time.Duration
+
+	mutex sync.Mutex
+	index map[string]entry // normalized URL -> cached content
+}
+
+type entry struct {
+	hash    string
+	fetched time.Time
+}
+
+// New creates the cache directory if needed.
+func New(dir string, ttl time.Duration) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("couldn't create cache directory: %w", err)
+	}
+	return &Cache{Dir: dir, TTL: ttl, index: map[string]entry{}}, nil
+}
+
+// Fetch returns a path to a fresh temp copy of the content at rawURL, named per
+// os.CreateTemp's pattern rules. The content comes from the cache when a
+// non-expired copy exists; otherwise it's downloaded and added.
+func (c *Cache) Fetch(rawURL string, pattern string) (string, error) {
+	key := normalizeURL(rawURL)
+
+	c.mutex.Lock()
+	cached, ok := c.index[key]
+	c.mutex.Unlock()
+
+	if ok && time.Since(cached.fetched) < c.TTL {
+		if path, err := c.copyOut(cached.hash, pattern); err == nil {
+			return path, nil
+		}
+		// fall through and re-download if the cached file went missing
+	}
+
+	hash, err := c.download(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	c.mutex.Lock()
+	c.index[key] = entry{hash: hash, fetched: time.Now()}
+	c.mutex.Unlock()
+
+	return c.copyOut(hash, pattern)
+}
+
+// Sweep removes cached content older than the TTL and returns how many files were deleted.
+func (c *Cache) Sweep() int {
+	c.mutex.Lock()
+	for key, e := range c.index {
+		if time.Since(e.fetched) >= c.TTL {
+			delete(c.index, key)
+		}
+	}
+	c.mutex.Unlock()
+
+	return removeOlderThan(c.Dir, "*", c.TTL)
+}
+
+// download streams rawURL into the cache directory, naming the file by its SHA-256.
+func (c *Cache) download(rawURL string) (string, error) {
+	resp, err := http.Get(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download file: server returned %s", resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(c.Dir, "partial-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hasher), resp.Body); err != nil {
+		return "", fmt.Errorf("failed to save downloaded file: %w", err)
+	}
+	tmp.Close()
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if err := os.Rename(tmp.Name(), filepath.Join(c.Dir, hash)); err != nil {
+		return "", fmt.Errorf("failed to store downloaded file: %w", err)
+	}
+	return hash, nil
+}
+
+// copyOut gives the caller a private copy of cached content. It's always a
+// copy, never a link, so that nothing the caller does to its file can change
+// the cached content.
+func (c *Cache) copyOut(hash string, pattern string) (string, error) {
+	src := filepath.Join(c.Dir, hash)
+	// refresh the mtime so the janitor measures age from last use
+	now := time.Now()
+	if err := os.Chtimes(src, now, now); err != nil {
+		return "", err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(utils.TempDir(), pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("failed to copy cached file: %w", err)
+	}
+	return out.Name(), nil
+}
+
+// normalizeURL drops the expiring signature query Discord adds to CDN links, so
+// the same attachment maps to one key no matter when its link was generated.
+func normalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if strings.HasSuffix(u.Host, "discordapp.com") || strings.HasSuffix(u.Host, "discordapp.net") {
+		u.RawQuery = ""
+	}
+	return u.String()
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"slugbot/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_FetchServesRepeatsFromDisk(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte("image-bytes"))
+	}))
+	defer server.Close()
+
+	c, err := New(t.TempDir(), time.Hour)
+	require.NoError(t, err)
+
+	first, err := c.Fetch(server.URL+"/a.png", "in-*.png")
+	require.NoError(t, err)
+	defer os.Remove(first)
+	second, err := c.Fetch(server.URL+"/a.png", "in-*.png")
+	require.NoError(t, err)
+	defer os.Remove(second)
+
+	require.Equal(t, 1, hits)
+	require.NotEqual(t, first, second)
+
+	// callers own their copies, so removing one mustn't affect the other or the cache
+	require.NoError(t, os.Remove(first))
+	data, err := os.ReadFile(second)
+	require.NoError(t, err)
+	require.Equal(t, "image-bytes", string(data))
+
+	// nor may writing to one
+	require.NoError(t, os.WriteFile(second, []byte("edited"), 0o644))
+	third, err := c.Fetch(server.URL+"/a.png", "in-*.png")
+	require.NoError(t, err)
+	defer os.Remove(third)
+	data, err = os.ReadFile(third)
+	require.NoError(t, err)
+	require.Equal(t, "image-bytes", string(data))
+}
+
+func TestJanitor_OnlySweepsTheBotsOwnTempDir(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	old := time.Now().Add(-2 * time.Hour)
+	others := filepath.Join(os.TempDir(), "in-someone-elses")
+	ours := filepath.Join(utils.TempDir(), "in-abandoned")
+	for _, path := range []string{others, ours} {
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+		require.NoError(t, os.Chtimes(path, old, old))
+	}
+
+	(&Janitor{MaxTempAge: time.Hour}).Sweep()
+	require.FileExists(t, others)
+	require.NoFileExists(t, ours)
+}
+
+func TestCache_ExpiredEntriesAreRefetched(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte("image-bytes"))
+	}))
+	defer server.Close()
+
+	c, err := New(t.TempDir(), time.Nanosecond)
+	require.NoError(t, err)
+
+	for range 2 {
+		path, err := c.Fetch(server.URL+"/a.png", "in-*.png")
+		require.NoError(t, err)
+		os.Remove(path)
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, 2, hits)
+	require.Equal(t, 1, c.Sweep())
+}
+
+func TestNormalizeURL_DropsDiscordSignatures(t *testing.T) {
+	a := normalizeURL("https://cdn.discordapp.com/attachments/1/2/x.png?ex=1&is=2&hm=3")
+	b := normalizeURL("https://cdn.discordapp.com/attachments/1/2/x.png?ex=4&is=5&hm=6")
+	require.Equal(t, a, b)
+	require.NotEqual(t, normalizeURL("https://example.com/x.png?v=1"), normalizeURL("https://example.com/x.png?v=2"))
+}
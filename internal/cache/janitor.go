@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"slugbot/internal/io/slog"
+	"slugbot/internal/utils"
+)
+
+// TempFilePatterns match the temp files and directories the bot creates in
+// its temp dir, utils.TempDir. Commands remove their own files, but a crash or
+// a killed subprocess can leave them behind.
+var TempFilePatterns = []string{
+	"in-*",
+	"out-*",
+	"palette-*",
+	"animate-*",
+	"pollable-*",
+	"saudio-init-*",
+	"slugbot-*",
+}
+
+// Janitor periodically expires cached downloads and removes stale temp files.
+type Janitor struct {
+	Cache      *Cache        // may be nil if caching is disabled
+	Interval   time.Duration // how often to sweep
+	MaxTempAge time.Duration // temp files older than this are assumed abandoned
+}
+
+// Start sweeps every Interval until done is closed.
+func (j *Janitor) Start(done <-chan struct{}) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			j.Sweep()
+		}
+	}
+}
+
+// Sweep runs a single cleanup pass.
+func (j *Janitor) Sweep() {
+	removed := 0
+	if j.Cache != nil {
+		removed += j.Cache.Sweep()
+	}
+	for _, pattern := range TempFilePatterns {
+		removed += removeOlderThan(utils.TempDir(), pattern, j.MaxTempAge)
+	}
+	if removed > 0 {
+		slog.Info("janitor removed ", removed, " stale files")
+	}
+}
+
+// removeOlderThan deletes entries in dir matching pattern whose mtime is older than maxAge.
+func removeOlderThan(dir string, pattern string, maxAge time.Duration) int {
+	matches, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return 0
+	}
+
+	removed := 0
+	cutoff := time.Now().Add(-maxAge)
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			slog.Warn("janitor couldn't remove ", path, ": ", err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
@@ -0,0 +1,133 @@
+// Package chaos injects synthetic failures into a guild's next generation,
+// so operators can check that retries, the watchdog, and error reporting
+// work in production without waiting for a real failure.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Fault is a set of synthetic failures.
+type Fault int
+
+const (
+	Exit   Fault = 1 << iota // the generation subprocess exits nonzero
+	Stall                    // progress stops, until the watchdog or MaxStall steps in
+	Upload                   // every try at uploading the result fails transiently
+)
+
+// Faults maps the names faults are armed with to them.
+var Faults = map[string]Fault{
+	"exit":   Exit,
+	"stall":  Stall,
+	"upload": Upload,
+}
+
+func (f Fault) String() string {
+	var names []string
+	for _, name := range []string{"exit", "stall", "upload"} {
+		if f&Faults[name] != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// ErrInjected is the failure an injected fault causes.
+var ErrInjected = errors.New("injected failure")
+
+// MaxStall bounds how long a stalled job waits for something to interrupt it.
+var MaxStall = 15 * time.Minute
+
+// armed holds the faults waiting for each guild's next generation.
+var armed = struct {
+	sync.Mutex
+	faults map[string]Fault
+}{faults: map[string]Fault{}}
+
+// Arm adds faults to a guild's next generation.
+func Arm(guildID string, fault Fault) {
+	armed.Lock()
+	defer armed.Unlock()
+	armed.faults[guildID] |= fault
+}
+
+// Disarm clears a guild's waiting faults.
+func Disarm(guildID string) {
+	armed.Lock()
+	defer armed.Unlock()
+	delete(armed.faults, guildID)
+}
+
+// Armed returns the faults waiting for a guild's next generation.
+func Armed(guildID string) Fault {
+	armed.Lock()
+	defer armed.Unlock()
+	return armed.faults[guildID]
+}
+
+// Claim takes the faults waiting for a guild, for the generation that's
+// about to run there.
+func Claim(guildID string) Fault {
+	armed.Lock()
+	defer armed.Unlock()
+	fault := armed.faults[guildID]
+	delete(armed.faults, guildID)
+	return fault
+}
+
+// Run stands in for a subprocess's Run with whatever faults f holds: Stall
+// blocks until ctx is done or MaxStall passes, and Exit fails in place of
+// running it. Without either, it calls run.
+func (f Fault) Run(ctx context.Context, run func() error) error {
+	if f&Stall != 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: stalled", ErrInjected)
+		case <-time.After(MaxStall):
+		}
+	}
+	if f&Exit != 0 {
+		return fmt.Errorf("%w: exit status 1", ErrInjected)
+	}
+	return run()
+}
+
+// ComplexSender sends messages with files; *discordgo.Session is one.
+type ComplexSender interface {
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+}
+
+// Sender makes api fail every message with files as a Discord outage would, if
+// f holds Upload. Other messages, e.g. the notice about an undelivered
+// result, go through.
+func (f Fault) Sender(api ComplexSender) ComplexSender {
+	if f&Upload == 0 {
+		return api
+	}
+	return failingSender{api}
+}
+
+type failingSender struct {
+	ComplexSender
+}
+
+func (s failingSender) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if len(data.Files) == 0 {
+		return s.ComplexSender.ChannelMessageSendComplex(channelID, data, options...)
+	}
+	return nil, &discordgo.RESTError{
+		Response: &http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable (injected)"},
+	}
+}
@@ -0,0 +1,66 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"slugbot/internal/discord"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaim_TakesArmedFaultsOnce(t *testing.T) {
+	Arm("g1", Exit)
+	Arm("g1", Upload)
+	require.Equal(t, "exit, upload", Armed("g1").String())
+	require.Zero(t, Armed("g2"))
+
+	require.Equal(t, Exit|Upload, Claim("g1"))
+	require.Zero(t, Claim("g1"))
+
+	Arm("g1", Stall)
+	Disarm("g1")
+	require.Zero(t, Claim("g1"))
+}
+
+func TestFault_Run(t *testing.T) {
+	ran := false
+	run := func() error { ran = true; return nil }
+
+	require.NoError(t, Fault(0).Run(context.Background(), run))
+	require.True(t, ran)
+
+	ran = false
+	require.ErrorIs(t, Exit.Run(context.Background(), run), ErrInjected)
+	require.False(t, ran)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errors.New("stalled"))
+	require.ErrorIs(t, Stall.Run(ctx, run), ErrInjected)
+	require.False(t, ran)
+}
+
+type recordingSender struct {
+	sent int
+}
+
+func (s *recordingSender) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.sent++
+	return &discordgo.Message{ID: "m1"}, nil
+}
+
+func TestFault_SenderFailsUploadsTransiently(t *testing.T) {
+	api := &recordingSender{}
+	require.Same(t, api, Exit.Sender(api))
+
+	sender := Upload.Sender(api)
+	_, err := sender.ChannelMessageSendComplex("c1", &discordgo.MessageSend{Files: []*discordgo.File{{Name: "out.wav"}}})
+	require.True(t, discord.IsTransient(err))
+	require.Zero(t, api.sent)
+
+	_, err = sender.ChannelMessageSendComplex("c1", &discordgo.MessageSend{Content: "notice"})
+	require.NoError(t, err)
+	require.Equal(t, 1, api.sent)
+}
@@ -0,0 +1,121 @@
+// Package cmdline builds the arguments of the external tools the bot runs
+// (sag, magick, ffmpeg, the Python DSP scripts). The arguments are handed
+// straight to exec, never to a shell, and content from users only ever lands
+// where a tool reads a value, so it can't be taken for an option of its own.
+package cmdline
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Builder accumulates a command's arguments.
+type Builder struct {
+	args []string
+}
+
+// New starts a command line with fixed arguments chosen by the bot.
+func New(trusted ...string) *Builder {
+	return &Builder{args: append([]string(nil), trusted...)}
+}
+
+// Trusted appends fixed arguments chosen by the bot, such as flags or
+// operators from a validated whitelist.
+func (b *Builder) Trusted(args ...string) *Builder {
+	b.args = append(b.args, args...)
+	return b
+}
+
+// Option appends a long option as one "--name=value" argument. Tools parsing
+// options like GNU getopt or Python's argparse then take the whole value
+// literally, even if it starts with a dash.
+func (b *Builder) Option(name string, value string) *Builder {
+	b.args = append(b.args, name+"="+value)
+	return b
+}
+
+// Pair appends an option and its value as two arguments, for tools like
+// magick and ffmpeg whose options always consume the next argument.
+func (b *Builder) Pair(name string, value string) *Builder {
+	b.args = append(b.args, name, value)
+	return b
+}
+
+// Positional appends an operand with its leading dashes stripped, so it
+// can't be read as an option.
+func (b *Builder) Positional(value string) *Builder {
+	b.args = append(b.args, Positional(value))
+	return b
+}
+
+// Path appends a file path operand; see Path.
+func (b *Builder) Path(path string) *Builder {
+	b.args = append(b.args, Path(path))
+	return b
+}
+
+// Args returns the arguments built so far.
+func (b *Builder) Args() []string {
+	return append([]string(nil), b.args...)
+}
+
+// Positional strips the leading dashes from an operand.
+func Positional(value string) string {
+	return strings.TrimLeft(value, "-")
+}
+
+// Path makes a relative path that starts with a dash explicit, as in
+// "./-name.wav", so it can't be read as an option.
+func Path(path string) string {
+	if strings.HasPrefix(path, "-") {
+		return "." + string(filepath.Separator) + path
+	}
+	return path
+}
+
+// magickCoders are the input formats magick is told to decode by extension,
+// so that the file's contents can't select another coder (such as MSL or
+// MVG, which read and write other files).
+var magickCoders = map[string]string{
+	".png":  "png",
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".gif":  "gif",
+	".webp": "webp",
+	".bmp":  "bmp",
+	".heic": "heic",
+	".avif": "avif",
+	".tiff": "tiff",
+}
+
+// MagickInput names an input image for magick with its coder spelled out,
+// e.g. "png:/tmp/in-1.png", for the formats in magickCoders. Other paths are
+// returned as Path returns them.
+func MagickInput(path string) string {
+	if coder, ok := magickCoders[strings.ToLower(filepath.Ext(path))]; ok {
+		return coder + ":" + path
+	}
+	return Path(path)
+}
+
+// CheckMagickValue reports an error if a value passed to a magick operator
+// could make it read or write a file: "@file" arguments, "coder:filename"
+// references, pipes, or paths.
+func CheckMagickValue(value string) error {
+	if strings.HasPrefix(value, "@") || strings.HasPrefix(value, "|") || strings.ContainsAny(value, `:/\`) {
+		return fmt.Errorf("'%s' could refer to a file", value)
+	}
+	return nil
+}
+
+// MagickText escapes text for magick to draw as it is, with -annotate or as a
+// label: image: a % would start an escape, like %[exif:*], and a leading @
+// would have magick read the text from a file.
+func MagickText(text string) string {
+	text = strings.ReplaceAll(text, "%", "%%")
+	if strings.HasPrefix(text, "@") {
+		text = `\` + text
+	}
+	return text
+}
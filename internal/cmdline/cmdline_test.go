@@ -0,0 +1,68 @@
+package cmdline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder(t *testing.T) {
+	args := New("py/limiter.py").
+		Option("--input", "-in.wav").
+		Pair("-distort", "Barrel").
+		Positional("--small").
+		Path("-out.wav").
+		Trusted("--verbose").
+		Args()
+	require.Equal(t, []string{"py/limiter.py", "--input=-in.wav", "-distort", "Barrel", "small", "./-out.wav", "--verbose"}, args)
+}
+
+func TestMagickInput(t *testing.T) {
+	require.Equal(t, "png:/tmp/in-1.png", MagickInput("/tmp/in-1.png"))
+	require.Equal(t, "jpeg:/tmp/in-1.JPG", MagickInput("/tmp/in-1.JPG"))
+	require.Equal(t, "/tmp/in-1.mp4", MagickInput("/tmp/in-1.mp4"))
+}
+
+func TestCheckMagickValue(t *testing.T) {
+	for _, ok := range []string{"0x6", "120,140", "12.5%", "-90", "#ff8800", "rgb(10,20,30)", "Barrel", "0.2 0.0 0.0 1.0"} {
+		require.NoError(t, CheckMagickValue(ok), ok)
+	}
+	for _, bad := range []string{"@file.txt", "text:/etc/passwd", "msl:x", "/tmp/x.png", `C:\x`, "|ls"} {
+		require.Error(t, CheckMagickValue(bad), bad)
+	}
+}
+
+func TestMagickText(t *testing.T) {
+	require.Equal(t, "100%% slug", MagickText("100% slug"))
+	require.Equal(t, `\@list`, MagickText("@list"))
+	require.Equal(t, "mail me@home", MagickText("mail me@home"))
+}
+
+func FuzzPositional_NeverAnOption(f *testing.F) {
+	f.Add("--help")
+	f.Add("-")
+	f.Add("rainy jazz")
+	f.Fuzz(func(t *testing.T, value string) {
+		positional := Positional(value)
+		require.False(t, strings.HasPrefix(positional, "-"))
+		require.True(t, strings.HasSuffix(value, positional))
+	})
+}
+
+func FuzzPath_NeverAnOption(f *testing.F) {
+	f.Add("-rf")
+	f.Add("out.wav")
+	f.Fuzz(func(t *testing.T, path string) {
+		require.False(t, strings.HasPrefix(Path(path), "-"))
+	})
+}
+
+func FuzzOption_KeepsValueWhole(f *testing.F) {
+	f.Add("--prompt", "--small")
+	f.Fuzz(func(t *testing.T, name string, value string) {
+		args := New().Option(name, value).Args()
+		require.Len(t, args, 1)
+		require.Equal(t, value, strings.TrimPrefix(args[0], name+"="))
+	})
+}
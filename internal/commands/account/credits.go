@@ -0,0 +1,44 @@
+package account
+
+import (
+	"fmt"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/credits"
+)
+
+// CreditsCommand tells the author how many credits they have left.
+type CreditsCommand struct {
+	commands.Command
+	Ledger    *credits.Ledger // nil when credits are off
+	PerMinute float64         // credits charged per minute of estimated GPU time
+}
+
+func (c *CreditsCommand) Usage() string {
+	return "Usage: `.scredits`"
+}
+
+func (c *CreditsCommand) Validate() error {
+	if c.Session == nil || c.Message == nil || c.Message.Author == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	return nil
+}
+
+func (c *CreditsCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	reply := "Credits are off here, so jobs are free."
+	if c.Ledger != nil {
+		account, err := c.Ledger.Account(c.Message.Author.ID)
+		if err != nil {
+			return err
+		}
+		reply = fmt.Sprintf("You have %d credits. Jobs cost %g per minute of GPU time they're expected to take, and you've spent %d so far.",
+			account.Balance, c.PerMinute, account.Spent)
+	}
+	_, err := c.Session.ChannelMessageSendReply(c.Message.ChannelID, reply, c.Message.Reference())
+	return err
+}
@@ -0,0 +1,153 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"slugbot/internal/audit"
+	"slugbot/internal/commands"
+	"slugbot/internal/exec"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// DeleteCommand deletes one of the bot's results, along with the output files
+// and history of the jobs behind it. Only the person who asked for the result
+// or an admin may delete it, and every deletion is logged for the admins.
+type DeleteCommand struct {
+	commands.Command
+	Queue *exec.TaskQueue
+	Audit *audit.Log // optional
+}
+
+func (c *DeleteCommand) Usage() string {
+	return "Usage: reply to one of my results with `.sdelete`"
+}
+
+func (c *DeleteCommand) Validate() error {
+	if c.Session == nil || c.Message == nil || c.Message.Author == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Message.MessageReference == nil || c.Message.MessageReference.MessageID == "" {
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+func (c *DeleteCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	result, err := c.Session.ChannelMessage(c.Message.ChannelID, c.Message.MessageReference.MessageID)
+	if err != nil {
+		return fmt.Errorf("couldn't find the message you replied to")
+	}
+	if result.Author == nil || c.Session.State == nil || c.Session.State.User == nil || result.Author.ID != c.Session.State.User.ID {
+		return fmt.Errorf("that isn't one of my messages")
+	}
+
+	jobs := c.jobsBehind(result)
+	submitter := c.submitter(result, jobs)
+	if submitter != c.Message.Author.ID && !commands.IsAdmin(c.Session, c.Message) {
+		return fmt.Errorf("only the person who asked for that result or an admin can delete it")
+	}
+
+	var ids []string
+	files := 0
+	for _, info := range jobs {
+		if producing, ok := info.Task.(exec.Producing); ok {
+			for _, output := range producing.Outputs() {
+				if err := os.Remove(output); err == nil {
+					files++
+				} else if !errors.Is(err, os.ErrNotExist) {
+					c.Log().Warn("couldn't remove ", output, ": ", err)
+				}
+			}
+		}
+		if c.Queue.Forget(info.ID) {
+			ids = append(ids, info.ID)
+		}
+	}
+	if err := c.Session.ChannelMessageDelete(result.ChannelID, result.ID); err != nil {
+		return fmt.Errorf("couldn't delete the message: %w", err)
+	}
+	c.Log().Info("user ", c.Message.Author.ID, " deleted result ", result.ID, " of user ", submitter, " with ", len(ids), " job(s) and ", files, " file(s)")
+
+	detail := fmt.Sprintf("result message %s in <#%s>; %d job(s)", result.ID, result.ChannelID, len(ids))
+	if len(ids) > 0 {
+		detail += " (" + strings.Join(ids, ", ") + ")"
+	}
+	detail += fmt.Sprintf(", %d file(s)", files)
+	if c.Audit != nil {
+		if err := c.Audit.Record(audit.Entry{
+			GuildID: c.Message.GuildID,
+			ActorID: c.Message.Author.ID,
+			UserID:  submitter,
+			Action:  "delete",
+			Detail:  detail,
+		}); err != nil {
+			c.Log().Error("couldn't record deletion in the audit log: ", err)
+		}
+	}
+
+	_, err = c.Session.ChannelMessageSendReply(c.Message.ChannelID, "Deleted that result and the files and history behind it.", c.Message.Reference())
+	return err
+}
+
+// jobsBehind returns the finished jobs whose outputs a result message holds.
+// Results reply to the message that asked for them; if none of that
+// message's jobs wrote a file attached to the result, all of them count.
+func (c *DeleteCommand) jobsBehind(result *discordgo.Message) []exec.TaskInfo {
+	if result.MessageReference == nil {
+		return nil
+	}
+	var attached []string
+	for _, attachment := range result.Attachments {
+		attached = append(attached, attachment.Filename)
+	}
+
+	var all, matched []exec.TaskInfo
+	for _, info := range c.Queue.History() {
+		triggered, ok := info.Task.(exec.Triggered)
+		if !ok || triggered.MessageID() != result.MessageReference.MessageID {
+			continue
+		}
+		all = append(all, info)
+		if producing, ok := info.Task.(exec.Producing); ok {
+			for _, output := range producing.Outputs() {
+				if slices.Contains(attached, filepath.Base(output)) {
+					matched = append(matched, info)
+					break
+				}
+			}
+		}
+	}
+	if len(matched) > 0 {
+		return matched
+	}
+	return all
+}
+
+// submitter returns the ID of the user who asked for a result.
+func (c *DeleteCommand) submitter(result *discordgo.Message, jobs []exec.TaskInfo) string {
+	for _, info := range jobs {
+		if triggered, ok := info.Task.(interface {
+			TriggerMessage() *discordgo.MessageCreate
+		}); ok && triggered.TriggerMessage() != nil && triggered.TriggerMessage().Author != nil {
+			return triggered.TriggerMessage().Author.ID
+		}
+	}
+	if result.MessageReference == nil {
+		return ""
+	}
+	trigger, err := c.Session.ChannelMessage(result.ChannelID, result.MessageReference.MessageID)
+	if err != nil || trigger.Author == nil {
+		return ""
+	}
+	return trigger.Author.ID
+}
@@ -0,0 +1,168 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"slugbot/internal/apitoken"
+	"slugbot/internal/audit"
+	"slugbot/internal/commands"
+	"slugbot/internal/credits"
+	"slugbot/internal/discord"
+	"slugbot/internal/event"
+	"slugbot/internal/exec"
+	"slugbot/internal/prefs"
+	"slugbot/internal/ratings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ForgetCommand purges everything the bot keeps about its author: finished
+// jobs and their output files, undelivered results and previews waiting for
+// approval, API tokens, prompt-of-the-day entries, audit entries, and
+// preferences. It runs once the author has confirmed, and replies with what
+// was removed. Credit balances are kept so that forgetting can't be used to
+// reset them.
+type ForgetCommand struct {
+	commands.Command
+	Queue  *exec.TaskQueue
+	Tokens *apitoken.Registry // optional
+	Events *event.Store       // optional
+	Votes  *ratings.Store     // optional
+	Audit  *audit.Log         // optional
+	Ledger *credits.Ledger    // optional; only reported, never purged
+	Prefs  *prefs.Registry    // optional
+}
+
+func (c *ForgetCommand) Usage() string {
+	return "Usage: `.sforgetme`; deletes your job history, results, undelivered and unpublished results, API tokens, event entries, votes on your results, audit entries, and preferences after you confirm"
+}
+
+func (c *ForgetCommand) Validate() error {
+	if c.Session == nil || c.Message == nil || c.Message.Author == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if strings.TrimSpace(c.Message.Content) != ".sforgetme" {
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+func (c *ForgetCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	userID := c.Message.Author.ID
+
+	var report []string
+	var failed []string
+	jobs, files := c.forgetJobs(userID)
+	report = append(report, fmt.Sprintf("%d finished job(s) and %d output file(s)", jobs, files))
+	if n, err := discord.ForgetSubmitter(userID); err != nil {
+		c.Log().Error("couldn't remove saved results of ", userID, ": ", err)
+		failed = append(failed, "undelivered and unpublished results")
+	} else {
+		report = append(report, fmt.Sprintf("%d undelivered or unpublished result(s)", n))
+	}
+
+	if c.Tokens != nil {
+		if n, err := c.Tokens.RevokeAll(userID); err != nil {
+			c.Log().Error("couldn't revoke API tokens of ", userID, ": ", err)
+			failed = append(failed, "API tokens")
+		} else {
+			report = append(report, fmt.Sprintf("%d API token(s)", n))
+		}
+	}
+	if c.Events != nil {
+		if n, err := c.Events.RemoveUser(userID); err != nil {
+			c.Log().Error("couldn't remove event entries of ", userID, ": ", err)
+			failed = append(failed, "event entries")
+		} else {
+			report = append(report, fmt.Sprintf("%d prompt-of-the-day entry(ies)", n))
+		}
+	}
+	if c.Votes != nil {
+		if n, err := c.Votes.RemoveUser(userID); err != nil {
+			c.Log().Error("couldn't remove votes on results of ", userID, ": ", err)
+			failed = append(failed, "votes on your results")
+		} else {
+			report = append(report, fmt.Sprintf("the votes on %d result(s)", n))
+		}
+	}
+	if c.Audit != nil {
+		if n, err := c.Audit.Purge(userID); err != nil {
+			c.Log().Error("couldn't purge audit entries of ", userID, ": ", err)
+			failed = append(failed, "audit entries")
+		} else {
+			report = append(report, fmt.Sprintf("%d audit entry(ies)", n))
+		}
+	}
+	if c.Prefs != nil {
+		if err := c.Prefs.Forget(userID); err != nil {
+			c.Log().Error("couldn't forget preferences of ", userID, ": ", err)
+			failed = append(failed, "preferences")
+		} else {
+			report = append(report, "your preferences")
+		}
+	}
+	c.Log().Info("forgot user ", userID, ": ", strings.Join(report, ", "))
+
+	reply := "Done. I deleted:\n- " + strings.Join(report, "\n- ")
+	if len(failed) > 0 {
+		reply += "\nI couldn't delete your " + strings.Join(failed, ", ") + "; try `.sforgetme` again or ask an admin."
+	}
+	if c.Ledger != nil {
+		if account, err := c.Ledger.Account(userID); err == nil {
+			reply += fmt.Sprintf("\nYour credit balance (%d) is kept so it can't be reset.", account.Balance)
+		}
+	}
+	if c.hasPendingJobs(userID) {
+		reply += "\nJobs of yours that are still queued or running aren't affected; run `.sforgetme` again once they finish."
+	}
+	_, err := c.Session.ChannelMessageSendReply(c.Message.ChannelID, reply, c.Message.Reference())
+	return err
+}
+
+// forgetJobs removes the author's finished jobs and their output files, and
+// returns how many of each it removed.
+func (c *ForgetCommand) forgetJobs(userID string) (int, int) {
+	jobs, files := 0, 0
+	for _, info := range c.Queue.History() {
+		if !ownedBy(info.Task, userID) {
+			continue
+		}
+		if producing, ok := info.Task.(exec.Producing); ok {
+			for _, output := range producing.Outputs() {
+				if err := os.Remove(output); err == nil {
+					files++
+				} else if !errors.Is(err, os.ErrNotExist) {
+					c.Log().Warn("couldn't remove ", output, ": ", err)
+				}
+			}
+		}
+		if c.Queue.Forget(info.ID) {
+			jobs++
+		}
+	}
+	return jobs, files
+}
+
+// hasPendingJobs reports whether any of the author's jobs are still queued or running.
+func (c *ForgetCommand) hasPendingJobs(userID string) bool {
+	current, waiting := c.Queue.Snapshot()
+	if current != nil && ownedBy(current, userID) {
+		return true
+	}
+	return slices.ContainsFunc(waiting, func(task exec.Task) bool { return ownedBy(task, userID) })
+}
+
+// ownedBy reports whether a task was asked for by a user.
+func ownedBy(task exec.Task, userID string) bool {
+	triggered, ok := task.(interface {
+		TriggerMessage() *discordgo.MessageCreate
+	})
+	return ok && triggered.TriggerMessage() != nil && triggered.TriggerMessage().Author != nil && triggered.TriggerMessage().Author.ID == userID
+}
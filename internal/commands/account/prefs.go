@@ -0,0 +1,95 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/notify"
+	"slugbot/internal/prefs"
+)
+
+// PrefsCommand shows or changes the author's preferences.
+type PrefsCommand struct {
+	commands.Command
+	Prefs *prefs.Registry
+	Email bool // whether email notifications are set up
+}
+
+func (c *PrefsCommand) Usage() string {
+	return "Usage: `.sprefs` to see your preferences, `.sprefs quiet <on|off>` to only get your results, without progress messages, queue notices, or reactions, " +
+		"or `.sprefs notify <off|dm|mention|webhook <https url>|email <address>>` to be told when a long job finishes"
+}
+
+func (c *PrefsCommand) Validate() error {
+	if c.Session == nil || c.Message == nil || c.Message.Author == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	args := strings.Fields(c.Message.Content)
+	if len(args) == 1 || (len(args) == 3 && args[1] == "quiet" && (args[2] == "on" || args[2] == "off")) {
+		return nil
+	}
+	if len(args) >= 3 && args[1] == "notify" {
+		switch args[2] {
+		case "off", prefs.NotifyDM, prefs.NotifyMention:
+			if len(args) == 3 {
+				return nil
+			}
+		case prefs.NotifyWebhook, prefs.NotifyEmail:
+			if len(args) == 4 {
+				return nil
+			}
+		}
+	}
+	return errors.New(c.Usage())
+}
+
+func (c *PrefsCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	userID := c.Message.Author.ID
+
+	current, err := c.Prefs.Get(userID)
+	if err != nil {
+		return err
+	}
+	if args := strings.Fields(c.Message.Content); len(args) == 3 && args[1] == "quiet" {
+		current.Quiet = args[2] == "on"
+		if err := c.Prefs.Set(userID, current); err != nil {
+			return err
+		}
+		c.Log().Info("set quiet mode ", args[2], " for user ", userID)
+	} else if len(args) >= 3 && args[1] == "notify" {
+		current.Notify, current.NotifyTo = args[2], ""
+		if current.Notify == "off" {
+			current.Notify = ""
+		}
+		if len(args) == 4 {
+			current.NotifyTo = args[3]
+		}
+		if current.Notify == prefs.NotifyEmail && !c.Email {
+			return errors.New("email notifications aren't set up on this bot")
+		}
+		if err := notify.ValidateTarget(current.Notify, current.NotifyTo); err != nil {
+			return err
+		}
+		if err := c.Prefs.Set(userID, current); err != nil {
+			return err
+		}
+		c.Log().Info("set notifications to ", args[2], " for user ", userID)
+	}
+
+	quiet := "off"
+	if current.Quiet {
+		quiet = "on; you'll only get your results"
+	}
+	// the webhook or address isn't repeated, since the reply may be public
+	notifications := "off"
+	if current.Notify != "" {
+		notifications = "by " + current.Notify + " when a long job finishes"
+	}
+	_, err = c.Session.ChannelMessageSendReply(c.Message.ChannelID, "Quiet mode: "+quiet+".\nNotifications: "+notifications+".", c.Message.Reference())
+	return err
+}
@@ -0,0 +1,154 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"slugbot/internal/apitoken"
+	"slugbot/internal/commands"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var tokenNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// TokenCommand manages the author's personal API tokens. Jobs submitted with
+// a token through webhooks or the gRPC API run as the token's owner, so they
+// count against the owner's quota and show up under their name.
+type TokenCommand struct {
+	commands.Command
+	Tokens *apitoken.Registry
+}
+
+func (c *TokenCommand) Usage() string {
+	return "Usage: `.stoken create [name]`, `.stoken list`, or `.stoken revoke <id>`\n" +
+		"Tokens are sent to you in a DM; send them as `Authorization: Bearer <token>` with webhook or API requests."
+}
+
+func (c *TokenCommand) Validate() error {
+	if c.Session == nil || c.Message == nil || c.Message.Author == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Tokens == nil {
+		return fmt.Errorf("API tokens aren't available")
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if len(args) < 2 {
+		return errors.New(c.Usage())
+	}
+	switch args[1] {
+	case "create":
+		if len(args) > 3 || (len(args) == 3 && !tokenNameRegex.MatchString(args[2])) {
+			return errors.New(c.Usage())
+		}
+	case "list":
+		if len(args) != 2 {
+			return errors.New(c.Usage())
+		}
+	case "revoke":
+		if len(args) != 3 {
+			return errors.New(c.Usage())
+		}
+	default:
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+func (c *TokenCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	args := strings.Fields(c.Message.Content)
+	switch args[1] {
+	case "list":
+		return c.list()
+	case "revoke":
+		revoked, err := c.Tokens.Revoke(c.Message.Author.ID, args[2])
+		if err != nil {
+			return err
+		}
+		if !revoked {
+			return fmt.Errorf("you have no token `%s`; see `.stoken list`", args[2])
+		}
+		c.Log().Info("revoked API token ", args[2], " of user ", c.Message.Author.ID)
+		_, err = c.Session.ChannelMessageSendReply(c.Message.ChannelID, fmt.Sprintf("Revoked token `%s`.", args[2]), c.Message.Reference())
+		return err
+	}
+
+	name := ""
+	if len(args) == 3 {
+		name = args[2]
+	}
+	return c.create(name)
+}
+
+// create issues a token and DMs it to the author, so it never shows up in a channel.
+func (c *TokenCommand) create(name string) error {
+	dm, err := c.Session.UserChannelCreate(c.Message.Author.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't open a DM to send your token; allow DMs from server members and try again")
+	}
+
+	plaintext, token, err := c.Tokens.Create(c.Message.Author.ID, c.Message.Author.Username, name, time.Now())
+	if err != nil {
+		return err
+	}
+	_, err = c.Session.ChannelMessageSend(dm.ID, fmt.Sprintf(
+		"Your API token `%s`:\n||%s||\nSend it as `Authorization: Bearer <token>`. Jobs you submit with it count against your quota. "+
+			"It won't be shown again; revoke it with `.stoken revoke %s` if it leaks.", token.ID, plaintext, token.ID))
+	if err != nil {
+		// nobody has seen the token, so it mustn't stay active or count
+		// against the limit
+		if _, revokeErr := c.Tokens.Revoke(token.UserID, token.ID); revokeErr != nil {
+			c.Log().Error("couldn't revoke undelivered API token ", token.ID, " of user ", token.UserID, ": ", revokeErr)
+		}
+		return fmt.Errorf("couldn't DM you the token; allow DMs from server members and try again")
+	}
+	c.Log().Info("created API token ", token.ID, " for user ", token.UserID)
+
+	if c.Message.GuildID == "" {
+		return nil
+	}
+	_, err = c.Session.ChannelMessageSendReply(c.Message.ChannelID, fmt.Sprintf("Sent you token `%s` in a DM.", token.ID), c.Message.Reference())
+	return err
+}
+
+func (c *TokenCommand) list() error {
+	tokens, err := c.Tokens.List(c.Message.Author.ID)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		_, err := c.Session.ChannelMessageSendReply(c.Message.ChannelID, "You have no API tokens. Create one with `.stoken create [name]`.", c.Message.Reference())
+		return err
+	}
+
+	lines := []string{fmt.Sprintf("Your API tokens (%d/%d):", len(tokens), apitoken.MaxPerUser)}
+	for _, token := range tokens {
+		lines = append(lines, describe(token))
+	}
+	_, err = c.Session.ChannelMessageSendComplex(c.Message.ChannelID, &discordgo.MessageSend{
+		Content:         strings.Join(lines, "\n"),
+		Reference:       c.Message.Reference(),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	return err
+}
+
+func describe(token apitoken.Token) string {
+	line := "`" + token.ID + "`"
+	if token.Name != "" {
+		line += " " + token.Name
+	}
+	line += fmt.Sprintf(", created <t:%d:d>", token.Created.Unix())
+	if token.LastUsed.IsZero() {
+		return line + ", never used"
+	}
+	return line + fmt.Sprintf(", last used <t:%d:R>", token.LastUsed.Unix())
+}
@@ -0,0 +1,90 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/policy"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ArchiveCommand chooses the channel or forum a guild's results are also
+// posted to, with what they were generated from, as a gallery of them.
+type ArchiveCommand struct {
+	commands.Command
+	Policies *policy.Policies
+}
+
+func (c *ArchiveCommand) Usage() string {
+	return "Usage: `.sadmin archive <show|off|#channel>`"
+}
+
+func (c *ArchiveCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Message.GuildID == "" {
+		return fmt.Errorf("the result archive can only be managed inside a server")
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if len(args) == 3 && (args[2] == "show" || args[2] == "off" || channelMention.MatchString(args[2])) {
+		return nil
+	}
+	return errors.New(c.Usage())
+}
+
+func (c *ArchiveCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if args[2] != "show" {
+		guild, err := c.Policies.GuildResults(c.Message.GuildID)
+		if err != nil {
+			return err
+		}
+		guild.Archive = ""
+		if m := channelMention.FindStringSubmatch(args[2]); m != nil {
+			if problem := c.channelProblem(m[1]); problem != "" {
+				_, err = c.Session.ChannelMessageSend(c.Message.ChannelID, problem)
+				return err
+			}
+			guild.Archive = m[1]
+		}
+		if err := c.Policies.SetGuildResults(c.Message.GuildID, guild); err != nil {
+			return err
+		}
+		c.Log().Info("set result archive to ", args[2], " for guild ", c.Message.GuildID)
+	}
+
+	archive, err := c.Policies.ResultArchive(c.Message.GuildID)
+	if err != nil {
+		return err
+	}
+	reply := "Result archive: off."
+	if archive != "" {
+		reply = fmt.Sprintf("Result archive: <#%s>; every result is also posted there, with its prompt, model, seed, and who asked for it.", archive)
+	}
+	_, err = c.Session.ChannelMessageSend(c.Message.ChannelID, reply)
+	return err
+}
+
+// channelProblem tells the admin why results can't be archived in a channel,
+// such as its being in another server or not a text channel or forum, or
+// returns "" if they can.
+func (c *ArchiveCommand) channelProblem(channelID string) string {
+	channel, err := c.Session.Channel(channelID)
+	if err != nil || channel.GuildID != c.Message.GuildID {
+		return fmt.Sprintf("<#%s> isn't a channel in this server.", channelID)
+	}
+	switch channel.Type {
+	case discordgo.ChannelTypeGuildText, discordgo.ChannelTypeGuildForum, discordgo.ChannelTypeGuildMedia:
+		return ""
+	}
+	return fmt.Sprintf("<#%s> isn't a text channel or forum, so results can't be archived there.", channelID)
+}
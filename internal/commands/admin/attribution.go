@@ -0,0 +1,84 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/policy"
+)
+
+// AttributionCommand turns a guild's AI-generated footer and watermark on or off.
+type AttributionCommand struct {
+	commands.Command
+	Policies *policy.Policies
+}
+
+func (c *AttributionCommand) Usage() string {
+	return "Usage: `.sadmin attribution show` or `.sadmin attribution <footer|watermark> <on|off|default>`"
+}
+
+func (c *AttributionCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Message.GuildID == "" {
+		return fmt.Errorf("attribution can only be managed inside a server")
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if len(args) == 3 && args[2] == "show" {
+		return nil
+	}
+	if len(args) != 4 || (args[2] != "footer" && args[2] != "watermark") {
+		return errors.New(c.Usage())
+	}
+	switch args[3] {
+	case "on", "off", "default":
+		return nil
+	}
+	return errors.New(c.Usage())
+}
+
+func (c *AttributionCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if args[2] != "show" {
+		guild, err := c.Policies.GuildAttribution(c.Message.GuildID)
+		if err != nil {
+			return err
+		}
+		var setting *bool
+		if args[3] != "default" {
+			on := args[3] == "on"
+			setting = &on
+		}
+		if args[2] == "footer" {
+			guild.Footer = setting
+		} else {
+			guild.Watermark = setting
+		}
+		if err := c.Policies.SetGuildAttribution(c.Message.GuildID, guild); err != nil {
+			return err
+		}
+		c.Log().Info("set attribution ", args[2], " to ", args[3], " for guild ", c.Message.GuildID)
+	}
+
+	footer, watermark, err := c.Policies.Attribution(c.Message.GuildID)
+	if err != nil {
+		return err
+	}
+	_, err = c.Session.ChannelMessageSend(c.Message.ChannelID, fmt.Sprintf("AI-generated footer: %s\nWatermark: %s", onOff(footer), onOff(watermark)))
+	return err
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"slugbot/internal/audit"
+	"slugbot/internal/commands"
+	"slugbot/internal/discord"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const defaultAuditEntries = 50
+
+// AuditCommand lists the guild's audit log, newest first.
+type AuditCommand struct {
+	commands.Command
+	Audit *audit.Log
+	Pages *discord.Paginator // optional; lists entries a page at a time
+}
+
+func (c *AuditCommand) Usage() string {
+	return fmt.Sprintf("Usage: `.sadmin audit [count]`; shows the latest %d entries by default", defaultAuditEntries)
+}
+
+func (c *AuditCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Audit == nil {
+		return fmt.Errorf("no audit log to show")
+	}
+	_, err := c.count()
+	return err
+}
+
+func (c *AuditCommand) count() (int, error) {
+	args := strings.Fields(c.Message.Content)
+	switch len(args) {
+	case 2:
+		return defaultAuditEntries, nil
+	case 3:
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 1 {
+			return 0, errors.New(c.Usage())
+		}
+		return n, nil
+	}
+	return 0, errors.New(c.Usage())
+}
+
+func (c *AuditCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	n, _ := c.count()
+	entries, err := c.Audit.List(c.Message.GuildID, n)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		_, err := c.Session.ChannelMessageSend(c.Message.ChannelID, "The audit log is empty.")
+		return err
+	}
+
+	var lines []string
+	for _, entry := range entries {
+		line := fmt.Sprintf("<t:%d:f> <@%s> %s", entry.Time.Unix(), entry.ActorID, entry.Action)
+		if entry.UserID != "" && entry.UserID != entry.ActorID {
+			line += fmt.Sprintf(" for <@%s>", entry.UserID)
+		}
+		if entry.Detail != "" {
+			line += ": " + entry.Detail
+		}
+		lines = append(lines, line)
+	}
+	if c.Pages != nil {
+		_, err = c.Pages.Send(c.Session, c.Message.ChannelID, c.Message.Reference(), "Audit log", lines)
+		return err
+	}
+	_, err = c.Session.ChannelMessageSendComplex(c.Message.ChannelID, &discordgo.MessageSend{
+		Content:         strings.Join(lines, "\n"),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	return err
+}
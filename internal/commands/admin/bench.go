@@ -0,0 +1,180 @@
+package admin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/commands/traits"
+	"slugbot/internal/format"
+	"slugbot/internal/helpers"
+	"slugbot/internal/store"
+	"slugbot/internal/tools"
+	"slugbot/internal/utils"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const benchBucket = "bench"
+
+// regressionThreshold is how much slower than the baseline a stage can get before it's flagged.
+const regressionThreshold = 1.2
+
+// BenchResult is one recorded benchmark run.
+type BenchResult struct {
+	Timestamp time.Time                `json:"timestamp"`
+	Timings   map[string]time.Duration `json:"timings"`
+}
+
+// benchStage is a single timed operation. Setup runs untimed before Run.
+type benchStage struct {
+	Name  string
+	Setup func(dir string) error
+	Run   func(dir string) error
+}
+
+var benchStages = []benchStage{
+	{
+		Name: "audio-small",
+		Run: func(dir string) error {
+			return runQuiet(tools.Path(tools.Sag),
+				"--prompt", "benchmark: steady warm synth pad",
+				"--negative_prompt", "",
+				"--output", filepath.Join(dir, "bench.wav"),
+				"--length", "5",
+				"--seed", "1",
+				"--steps", "8",
+				"--small",
+			)
+		},
+	},
+	{
+		Name: "image-barrel",
+		Setup: func(dir string) error {
+			return runQuiet(tools.Path(tools.Magick), helpers.MagickArgs("", "-seed", "1", "-size", "1024x1024", "plasma:fractal", filepath.Join(dir, "bench-in.png"))...)
+		},
+		Run: func(dir string) error {
+			return runQuiet(tools.Path(tools.Magick), helpers.MagickArgs("", filepath.Join(dir, "bench-in.png"), "-distort", "Barrel", "0.2 0.0 0.0 1.0", filepath.Join(dir, "bench-out.png"))...)
+		},
+	},
+}
+
+// BenchCommand runs a fixed, small audio generation and image operation and
+// compares their timings against the previously recorded run.
+type BenchCommand struct {
+	commands.Command
+	traits.Promptable
+	Store *store.Store
+}
+
+func (c *BenchCommand) Usage() string {
+	return "Usage: `.sadmin bench`"
+}
+
+func (c *BenchCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Store == nil {
+		return fmt.Errorf("benchmarks need a configured store")
+	}
+	return nil
+}
+
+func (c *BenchCommand) Apply() error {
+	log := c.Log()
+
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	dir, err := os.MkdirTemp(utils.TempDir(), "slugbot-bench-*")
+	if err != nil {
+		return fmt.Errorf("couldn't create benchmark directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	result := BenchResult{Timestamp: time.Now(), Timings: map[string]time.Duration{}}
+	for _, stage := range benchStages {
+		if stage.Setup != nil {
+			if err := stage.Setup(dir); err != nil {
+				return fmt.Errorf("benchmark stage %s setup failed: %w", stage.Name, err)
+			}
+		}
+		start := time.Now()
+		if err := stage.Run(dir); err != nil {
+			return fmt.Errorf("benchmark stage %s failed: %w", stage.Name, err)
+		}
+		result.Timings[stage.Name] = time.Since(start)
+		log.Info("benchmark stage ", stage.Name, " took ", result.Timings[stage.Name])
+	}
+
+	baseline, err := c.latestResult()
+	if err != nil {
+		return err
+	}
+	if err := c.Store.Put(benchBucket, fmt.Sprintf("%020d", result.Timestamp.UnixNano()), result); err != nil {
+		return fmt.Errorf("couldn't save benchmark result: %w", err)
+	}
+
+	reference := &discordgo.MessageReference{MessageID: c.Message.ID, ChannelID: c.Message.ChannelID}
+	_, err = c.Session.ChannelMessageSendReply(c.Message.ChannelID, formatBench(result, baseline), reference)
+	return err
+}
+
+// latestResult returns the most recent stored run, or nil if there are none yet.
+func (c *BenchCommand) latestResult() (*BenchResult, error) {
+	keys, err := c.Store.Keys(benchBucket)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list benchmark results: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	var result BenchResult
+	if err := c.Store.Get(benchBucket, keys[len(keys)-1], &result); err != nil {
+		return nil, fmt.Errorf("couldn't load benchmark baseline: %w", err)
+	}
+	return &result, nil
+}
+
+func formatBench(result BenchResult, baseline *BenchResult) string {
+	lines := []string{"```", fmt.Sprintf("%-14s %10s %10s %8s", "stage", "now", "baseline", "change")}
+	for _, stage := range benchStages {
+		now := result.Timings[stage.Name]
+		if baseline == nil {
+			lines = append(lines, fmt.Sprintf("%-14s %10s %10s %8s", stage.Name, format.Duration(now), "-", "-"))
+			continue
+		}
+		before, ok := baseline.Timings[stage.Name]
+		if !ok || before <= 0 {
+			lines = append(lines, fmt.Sprintf("%-14s %10s %10s %8s", stage.Name, format.Duration(now), "-", "-"))
+			continue
+		}
+		ratio := float64(now) / float64(before)
+		flag := ""
+		if ratio > regressionThreshold {
+			flag = "  <- regression"
+		}
+		lines = append(lines, fmt.Sprintf("%-14s %10s %10s %8s%s",
+			stage.Name, format.Duration(now), format.Duration(before), format.SignedPercent(ratio-1), flag))
+	}
+	lines = append(lines, "```")
+	if baseline == nil {
+		lines = append(lines, "No previous baseline; this run is now the baseline.")
+	} else {
+		lines = append(lines, "Compared against run from "+baseline.Timestamp.Format(time.DateTime)+".")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func runQuiet(name string, args ...string) error {
+	if out, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w\nOutput: %s", name, err, string(out))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/credits"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var userMention = regexp.MustCompile(`^<@!?(\d+)>$`)
+
+// CreditsCommand shows and adjusts users' credit balances.
+type CreditsCommand struct {
+	commands.Command
+	Ledger *credits.Ledger // nil when credits are off
+}
+
+func (c *CreditsCommand) Usage() string {
+	return "Usage: `.sadmin credits show @user` or `.sadmin credits grant @user <amount>` (a negative amount takes credits away)"
+}
+
+func (c *CreditsCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Ledger == nil {
+		return fmt.Errorf("credits are off; set `[credits] enabled = true` in the bot's config")
+	}
+	_, _, err := c.parse()
+	return err
+}
+
+// parse returns the mentioned user and, for grants, the amount.
+func (c *CreditsCommand) parse() (userID string, amount int64, err error) {
+	args := strings.Fields(c.Message.Content)
+	if len(args) < 4 {
+		return "", 0, errors.New(c.Usage())
+	}
+	m := userMention.FindStringSubmatch(args[3])
+	if m == nil {
+		return "", 0, errors.New(c.Usage())
+	}
+	switch {
+	case args[2] == "show" && len(args) == 4:
+		return m[1], 0, nil
+	case args[2] == "grant" && len(args) == 5:
+		amount, err := strconv.ParseInt(args[4], 10, 64)
+		if err != nil || amount == 0 {
+			return "", 0, fmt.Errorf("amount must be a nonzero whole number, not `%s`", args[4])
+		}
+		return m[1], amount, nil
+	}
+	return "", 0, errors.New(c.Usage())
+}
+
+func (c *CreditsCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	userID, amount, err := c.parse()
+	if err != nil {
+		return err
+	}
+
+	var reply string
+	if amount == 0 {
+		account, err := c.Ledger.Account(userID)
+		if err != nil {
+			return err
+		}
+		reply = fmt.Sprintf("<@%s> has %d credits (%d spent, %d granted in total).", userID, account.Balance, account.Spent, account.Granted)
+	} else {
+		balance, err := c.Ledger.Grant(userID, amount, time.Now())
+		if err != nil {
+			return err
+		}
+		c.Log().Info("granted ", amount, " credits to user ", userID, " on behalf of ", c.Message.Author.ID)
+		reply = fmt.Sprintf("Granted %+d credits to <@%s>; they now have %d.", amount, userID, balance)
+	}
+
+	_, err = c.Session.ChannelMessageSendComplex(c.Message.ChannelID, &discordgo.MessageSend{
+		Content:         reply,
+		Reference:       c.Message.Reference(),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	return err
+}
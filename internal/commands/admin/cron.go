@@ -0,0 +1,160 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/discord"
+	"slugbot/internal/recurring"
+)
+
+var (
+	cronJobNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+	channelMention   = regexp.MustCompile(`^<#(\d+)>$`)
+)
+
+// CronCommand manages a guild's recurring jobs, which run a command through
+// the normal queue on a cron schedule.
+type CronCommand struct {
+	commands.Command
+	Jobs     *recurring.Runner
+	Commands []string           // top-level commands a job may run, e.g. ".saudio"
+	Pages    *discord.Paginator // optional; lists jobs a page at a time
+}
+
+func (c *CronCommand) Usage() string {
+	return "Usage: `.sadmin cron add <name> [#channel] <minute> <hour> <day> <month> <weekday> <command...>`, `.sadmin cron list`, or `.sadmin cron remove <name>`\n" +
+		"e.g. `.sadmin cron add lofi #lofi 0 8 * * * .saudio lofi morning ambience --length 60`"
+}
+
+func (c *CronCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Message.GuildID == "" {
+		return fmt.Errorf("recurring jobs can only be managed inside a server")
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if len(args) < 3 {
+		return errors.New(c.Usage())
+	}
+	switch args[2] {
+	case "list":
+		if len(args) != 3 {
+			return errors.New(c.Usage())
+		}
+	case "remove":
+		if len(args) != 4 {
+			return errors.New(c.Usage())
+		}
+	case "add":
+		if _, err := c.parseAdd(args); err != nil {
+			return err
+		}
+	default:
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+// parseAdd builds the job described by `.sadmin cron add ...`.
+func (c *CronCommand) parseAdd(args []string) (recurring.Job, error) {
+	if len(args) < 4 || !cronJobNameRegex.MatchString(args[3]) {
+		return recurring.Job{}, errors.New(c.Usage())
+	}
+	job := recurring.Job{
+		Name:      args[3],
+		GuildID:   c.Message.GuildID,
+		ChannelID: c.Message.ChannelID,
+		CreatedBy: c.Message.Author.ID,
+		Created:   time.Now(),
+	}
+
+	rest := args[4:]
+	if len(rest) > 0 {
+		if m := channelMention.FindStringSubmatch(rest[0]); m != nil {
+			job.ChannelID = m[1]
+			rest = rest[1:]
+		}
+	}
+	if len(rest) < 6 {
+		return recurring.Job{}, errors.New(c.Usage())
+	}
+	job.Cron = strings.Join(rest[:5], " ")
+	job.Command = strings.Join(rest[5:], " ")
+	if !slices.Contains(c.Commands, rest[5]) {
+		return recurring.Job{}, fmt.Errorf("recurring jobs can run %s, not `%s`", strings.Join(c.Commands, ", "), rest[5])
+	}
+	return job, nil
+}
+
+func (c *CronCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	args := strings.Fields(c.Message.Content)
+	switch args[2] {
+	case "list":
+		return c.list()
+	case "remove":
+		removed, err := c.Jobs.Remove(c.Message.GuildID, args[3])
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return fmt.Errorf("no recurring job named `%s`", args[3])
+		}
+		c.Log().Info("removed recurring job ", args[3], " from guild ", c.Message.GuildID)
+		_, err = c.Session.ChannelMessageSend(c.Message.ChannelID, fmt.Sprintf("Removed recurring job `%s`.", args[3]))
+		return err
+	}
+
+	job, err := c.parseAdd(args)
+	if err != nil {
+		return err
+	}
+	if err := c.Jobs.Add(job); err != nil {
+		return err
+	}
+	c.Log().Info("added recurring job ", job.Name, " to guild ", job.GuildID, ": ", job.Cron, " ", job.Command)
+	_, err = c.Session.ChannelMessageSend(c.Message.ChannelID, fmt.Sprintf("Recurring job `%s` saved. %s", job.Name, c.describe(job)))
+	return err
+}
+
+func (c *CronCommand) list() error {
+	jobs, err := c.Jobs.List(c.Message.GuildID)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		_, err := c.Session.ChannelMessageSend(c.Message.ChannelID, "No recurring jobs. Add one with `.sadmin cron add`.")
+		return err
+	}
+
+	var lines []string
+	for _, job := range jobs {
+		lines = append(lines, fmt.Sprintf("`%s`: %s", job.Name, c.describe(job)))
+	}
+	if c.Pages != nil {
+		_, err = c.Pages.Send(c.Session, c.Message.ChannelID, c.Message.Reference(), "Recurring jobs", lines)
+		return err
+	}
+	_, err = c.Session.ChannelMessageSend(c.Message.ChannelID, strings.Join(lines, "\n"))
+	return err
+}
+
+// describe summarizes when and where a job runs.
+func (c *CronCommand) describe(job recurring.Job) string {
+	text := fmt.Sprintf("`%s` in <#%s> on `%s`", job.Command, job.ChannelID, job.Cron)
+	if next, ok := c.Jobs.Next(job); ok && !next.IsZero() {
+		text += fmt.Sprintf(", next <t:%d:R>", next.Unix())
+	}
+	return text
+}
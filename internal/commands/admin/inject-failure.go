@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"slugbot/internal/chaos"
+	"slugbot/internal/commands"
+)
+
+// InjectFailureCommand arms synthetic failures for the guild's next
+// generation, so operators can check how retries, the watchdog, and error
+// reports behave. It isn't listed among the admin commands.
+type InjectFailureCommand struct {
+	commands.Command
+}
+
+func (c *InjectFailureCommand) Usage() string {
+	return "Usage: `.sadmin inject-failure <exit|stall|upload>...`, `.sadmin inject-failure show`, or `.sadmin inject-failure clear`\n" +
+		"`exit` fails the next generation as if it exited nonzero, `stall` stops its progress until the watchdog steps in, and `upload` fails every try at uploading its result."
+}
+
+func (c *InjectFailureCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Message.GuildID == "" {
+		return fmt.Errorf("failures can only be injected inside a server")
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if len(args) == 3 && (args[2] == "show" || args[2] == "clear") {
+		return nil
+	}
+	if len(args) < 3 {
+		return errors.New(c.Usage())
+	}
+	for _, name := range args[2:] {
+		if _, ok := chaos.Faults[name]; !ok {
+			return errors.New(c.Usage())
+		}
+	}
+	return nil
+}
+
+func (c *InjectFailureCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	args := strings.Fields(c.Message.Content)
+	switch args[2] {
+	case "show":
+	case "clear":
+		chaos.Disarm(c.Message.GuildID)
+		c.Log().Info("cleared injected failures for guild ", c.Message.GuildID)
+	default:
+		var fault chaos.Fault
+		for _, name := range args[2:] {
+			fault |= chaos.Faults[name]
+		}
+		chaos.Arm(c.Message.GuildID, fault)
+		c.Log().Warn("armed injected failures ", fault, " for guild ", c.Message.GuildID, " by user ", c.Message.Author.ID)
+	}
+
+	armed := chaos.Armed(c.Message.GuildID)
+	reply := "No failures are waiting to be injected."
+	if armed != 0 {
+		reply = fmt.Sprintf("The next generation in this server will fail with: %s.", armed)
+	}
+	_, err := c.Session.ChannelMessageSend(c.Message.ChannelID, reply)
+	return err
+}
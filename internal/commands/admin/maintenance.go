@@ -0,0 +1,90 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/exec"
+)
+
+// MaintenanceCommand holds or resumes the generation queue so the operator can
+// swap models or restart GPUs without losing queued work.
+type MaintenanceCommand struct {
+	commands.Command
+	Queue    *exec.TaskQueue
+	Channels []string // channels to post the notice in, besides the one the command came from
+}
+
+func (c *MaintenanceCommand) Usage() string {
+	return "Usage: `.sadmin maintenance <on|off|status>`"
+}
+
+func (c *MaintenanceCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Queue == nil {
+		return fmt.Errorf("no queue to manage")
+	}
+	args := strings.Fields(c.Message.Content)
+	if len(args) != 3 || (args[2] != "on" && args[2] != "off" && args[2] != "status") {
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+func (c *MaintenanceCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	var notice string
+	switch strings.Fields(c.Message.Content)[2] {
+	case "on":
+		c.Queue.Pause()
+		_, busy, waiting := c.Queue.Status()
+		c.Log().Info("maintenance mode on; holding ", waiting, " job(s)")
+		notice = fmt.Sprintf("🔧 Maintenance: new generations are being held (%d queued) and will run once it's over.", waiting)
+		if busy {
+			notice += " The job that's already running will finish first."
+		}
+
+	case "off":
+		c.Queue.Resume()
+		_, _, waiting := c.Queue.Status()
+		c.Log().Info("maintenance mode off; resuming ", waiting, " job(s)")
+		notice = fmt.Sprintf("✅ Maintenance is over; resuming %d queued job(s).", waiting)
+
+	case "status":
+		paused, busy, waiting := c.Queue.Status()
+		state := "off"
+		if paused {
+			state = "on"
+		}
+		_, err := c.Session.ChannelMessageSend(c.Message.ChannelID,
+			fmt.Sprintf("Maintenance is %s; %d job(s) queued, a job is %srunning.", state, waiting, map[bool]string{true: "", false: "not "}[busy]))
+		return err
+	}
+
+	return c.announce(notice)
+}
+
+// announce posts a notice where the command was run and in every configured channel.
+func (c *MaintenanceCommand) announce(notice string) error {
+	channels := []string{c.Message.ChannelID}
+	for _, channel := range c.Channels {
+		if channel != c.Message.ChannelID {
+			channels = append(channels, channel)
+		}
+	}
+
+	var errs []error
+	for _, channel := range channels {
+		if _, err := c.Session.ChannelMessageSend(channel, notice); err != nil {
+			errs = append(errs, fmt.Errorf("couldn't post maintenance notice in %s: %w", channel, err))
+		}
+	}
+	return errors.Join(errs...)
+}
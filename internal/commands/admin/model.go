@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"slugbot/internal/backend"
+	"slugbot/internal/commands"
+	"slugbot/internal/exec"
+)
+
+// smokeTestTimeout bounds how long a new checkpoint gets to load and generate.
+const smokeTestTimeout = 10 * time.Minute
+
+// ModelCommand switches the checkpoint used for full-size generations. Jobs
+// are held while the new checkpoint is smoke tested, and the old one stays
+// active if the test fails.
+type ModelCommand struct {
+	commands.Command
+	Models *backend.Models
+	Queue  *exec.TaskQueue
+}
+
+func (c *ModelCommand) Usage() string {
+	return "Usage: `.sadmin model reload <name>` or `.sadmin model status`"
+}
+
+func (c *ModelCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Models == nil || c.Queue == nil {
+		return fmt.Errorf("no audio backend to manage")
+	}
+	args := strings.Fields(c.Message.Content)
+	switch {
+	case len(args) == 3 && args[2] == "status":
+	case len(args) == 4 && args[2] == "reload":
+	default:
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+func (c *ModelCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if args[2] == "status" {
+		active := c.Models.Active()
+		if active == "" {
+			active = "default"
+		}
+		_, err := c.Session.ChannelMessageSend(c.Message.ChannelID, "Full-size generations use the `"+active+"` checkpoint.")
+		return err
+	}
+
+	name := args[3]
+	if err := c.Models.Validate(name); err != nil {
+		return err
+	}
+
+	// hold new jobs so the smoke test has the GPU to itself, unless an
+	// operator already did so for maintenance
+	wasPaused, _, _ := c.Queue.Status()
+	c.Queue.Pause()
+	if !wasPaused {
+		defer c.Queue.Resume()
+	}
+
+	c.Session.ChannelMessageSend(c.Message.ChannelID, "Holding the queue and testing `"+name+"`; this waits for the running job first.")
+	for {
+		if _, busy, _ := c.Queue.Status(); !busy {
+			break
+		}
+		time.Sleep(2 * time.Second)
+	}
+
+	c.Log().Info("smoke testing model ", name)
+	ctx, cancel := context.WithTimeout(c.TraceContext(), smokeTestTimeout)
+	defer cancel()
+	if err := c.Models.SmokeTest(ctx, name); err != nil {
+		return fmt.Errorf("`%s` failed its smoke test, so the current checkpoint stays active: %w", name, err)
+	}
+
+	if err := c.Models.Switch(name); err != nil {
+		return err
+	}
+	_, err := c.Session.ChannelMessageSend(c.Message.ChannelID, "Switched to `"+name+"`; queued jobs will use it.")
+	return err
+}
@@ -0,0 +1,104 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/policy"
+)
+
+// NSFWCommand manages which commands and prompt terms a guild only allows in age-restricted channels.
+type NSFWCommand struct {
+	commands.Command
+	Policies *policy.Policies
+}
+
+func (c *NSFWCommand) Usage() string {
+	return "Usage: `.sadmin nsfw show`, `.sadmin nsfw add <command|term> <value>`, or `.sadmin nsfw remove <command|term> <value>`\n" +
+		"e.g. `.sadmin nsfw add command .sim animate` or `.sadmin nsfw add term gore`"
+}
+
+func (c *NSFWCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Message.GuildID == "" {
+		return fmt.Errorf("NSFW policies can only be managed inside a server")
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if len(args) == 3 && args[2] == "show" {
+		return nil
+	}
+	if len(args) < 5 || (args[2] != "add" && args[2] != "remove") || (args[3] != "command" && args[3] != "term") {
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+func (c *NSFWCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	args := strings.Fields(c.Message.Content)
+	guild, err := c.Policies.GuildNSFW(c.Message.GuildID)
+	if err != nil {
+		return err
+	}
+	if args[2] == "show" {
+		return c.show(guild)
+	}
+
+	value := strings.Join(args[4:], " ")
+	list := &guild.Terms
+	if args[3] == "command" {
+		list = &guild.Commands
+	} else {
+		value = strings.ToLower(value)
+	}
+
+	if args[2] == "add" {
+		if slices.Contains(*list, value) {
+			return fmt.Errorf("`%s` is already restricted", value)
+		}
+		*list = append(*list, value)
+	} else {
+		i := slices.Index(*list, value)
+		if i < 0 {
+			return fmt.Errorf("`%s` isn't restricted by this server (restrictions from the config file can't be removed)", value)
+		}
+		*list = slices.Delete(*list, i, i+1)
+	}
+
+	if err := c.Policies.SetGuildNSFW(c.Message.GuildID, guild); err != nil {
+		return err
+	}
+	c.Log().Info("updated NSFW policy for guild ", c.Message.GuildID, ": ", args[2], " ", args[3], " ", value)
+	return c.show(guild)
+}
+
+func (c *NSFWCommand) show(guild policy.NSFW) error {
+	effective, err := c.Policies.NSFW(c.Message.GuildID)
+	if err != nil {
+		return err
+	}
+	lines := []string{"Only allowed in age-restricted channels:"}
+	lines = append(lines, "Commands: "+listOrNone(effective.Commands))
+	lines = append(lines, "Prompt terms: "+listOrNone(effective.Terms))
+	if len(guild.Commands)+len(guild.Terms) < len(effective.Commands)+len(effective.Terms) {
+		lines = append(lines, "-# Some of these come from the bot's config file and can't be removed here.")
+	}
+	_, err = c.Session.ChannelMessageSend(c.Message.ChannelID, strings.Join(lines, "\n"))
+	return err
+}
+
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return "`" + strings.Join(values, "`, `") + "`"
+}
@@ -0,0 +1,109 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/discord"
+	"slugbot/internal/policy"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxNicknameLength is the longest nickname Discord allows.
+const maxNicknameLength = 32
+
+// PersonaCommand sets the bot's nickname and embed color in a guild.
+type PersonaCommand struct {
+	commands.Command
+	Policies *policy.Policies
+}
+
+func (c *PersonaCommand) Usage() string {
+	return "Usage: `.sadmin persona show`, `.sadmin persona nickname <name...|off|default>`, or `.sadmin persona color <#rrggbb|off|default>`"
+}
+
+func (c *PersonaCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Message.GuildID == "" {
+		return fmt.Errorf("the persona can only be managed inside a server")
+	}
+
+	args := strings.Fields(c.Message.Content)
+	switch {
+	case len(args) == 3 && args[2] == "show":
+		return nil
+	case len(args) >= 4 && args[2] == "nickname":
+		if nickname := strings.Join(args[3:], " "); len([]rune(nickname)) > maxNicknameLength {
+			return fmt.Errorf("nicknames can be at most %d characters", maxNicknameLength)
+		}
+		return nil
+	case len(args) == 4 && args[2] == "color":
+		if args[3] == "off" || args[3] == "default" {
+			return nil
+		}
+		_, err := discord.ParseColor(args[3])
+		return err
+	}
+	return errors.New(c.Usage())
+}
+
+func (c *PersonaCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if args[2] != "show" {
+		guild, err := c.Policies.GuildPersona(c.Message.GuildID)
+		if err != nil {
+			return err
+		}
+		value := strings.Join(args[3:], " ")
+		var setting *string
+		switch value {
+		case "default":
+		case "off":
+			setting = new(string)
+		default:
+			setting = &value
+		}
+		if args[2] == "nickname" {
+			guild.Nickname = setting
+		} else {
+			guild.Color = setting
+		}
+		if err := c.Policies.SetGuildPersona(c.Message.GuildID, guild); err != nil {
+			return err
+		}
+		c.Log().Info("set persona ", args[2], " to ", value, " for guild ", c.Message.GuildID)
+	}
+
+	persona, err := c.Policies.Persona(c.Message.GuildID)
+	if err != nil {
+		return err
+	}
+	if args[2] == "nickname" {
+		// an empty nickname resets it to the bot's own name
+		if err := c.Session.GuildMemberNickname(c.Message.GuildID, "@me", persona.Nickname); err != nil {
+			return fmt.Errorf("saved, but couldn't change my nickname; do I have the Change Nickname permission? %w", err)
+		}
+	}
+
+	nickname, color := persona.Nickname, persona.Color
+	if nickname == "" {
+		nickname = "(my own name)"
+	}
+	if color == "" {
+		color = "(Discord's default)"
+	}
+	_, err = c.Session.ChannelMessageSendComplex(c.Message.ChannelID, &discordgo.MessageSend{
+		Content:         fmt.Sprintf("Nickname: %s\nEmbed color: %s", nickname, color),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	return err
+}
@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/presets"
+)
+
+// PresetCommand manages a guild's image presets.
+type PresetCommand struct {
+	commands.Command
+	Presets *presets.Catalog
+}
+
+func (c *PresetCommand) Usage() string {
+	return "Usage: `.sadmin preset set <name> [--format <png|jpg|gif|webp>] <magick operators...>` or `.sadmin preset remove <name>`"
+}
+
+func (c *PresetCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Message.GuildID == "" {
+		return fmt.Errorf("presets can only be managed inside a server")
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if len(args) < 4 {
+		return errors.New(c.Usage())
+	}
+	switch args[2] {
+	case "set":
+		if len(args) < 5 {
+			return errors.New(c.Usage())
+		}
+	case "remove":
+		if len(args) != 4 {
+			return errors.New(c.Usage())
+		}
+	default:
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+func (c *PresetCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	args := strings.Fields(c.Message.Content)
+	name := args[3]
+
+	if args[2] == "remove" {
+		if err := c.Presets.RemoveImage(c.Message.GuildID, name); err != nil {
+			return err
+		}
+		_, err := c.Session.ChannelMessageSend(c.Message.ChannelID, "Removed preset `"+name+"`.")
+		return err
+	}
+
+	preset := presets.ImagePreset{Name: name, Args: args[4:]}
+	if len(preset.Args) >= 2 && preset.Args[0] == "--format" {
+		preset.Format = preset.Args[1]
+		preset.Args = preset.Args[2:]
+	}
+	if err := c.Presets.SetImage(c.Message.GuildID, preset); err != nil {
+		return err
+	}
+
+	c.Log().Info("saved image preset ", name, " for guild ", c.Message.GuildID)
+	_, err := c.Session.ChannelMessageSend(c.Message.ChannelID, "Saved preset `"+name+"`; use it with `.sim preset "+name+"`.")
+	return err
+}
@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/policy"
+)
+
+// PreviewCommand turns on or off sending a guild's results to their submitter
+// to publish or discard before they're posted.
+type PreviewCommand struct {
+	commands.Command
+	Policies *policy.Policies
+}
+
+func (c *PreviewCommand) Usage() string {
+	return "Usage: `.sadmin preview <show|on|off|default>`"
+}
+
+func (c *PreviewCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Message.GuildID == "" {
+		return fmt.Errorf("result previews can only be managed inside a server")
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if len(args) == 3 && (args[2] == "show" || args[2] == "on" || args[2] == "off" || args[2] == "default") {
+		return nil
+	}
+	return errors.New(c.Usage())
+}
+
+func (c *PreviewCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if args[2] != "show" {
+		guild, err := c.Policies.GuildResults(c.Message.GuildID)
+		if err != nil {
+			return err
+		}
+		switch args[2] {
+		case "on", "off":
+			on := args[2] == "on"
+			guild.Preview = &on
+		case "default":
+			guild.Preview = nil
+		}
+		if err := c.Policies.SetGuildResults(c.Message.GuildID, guild); err != nil {
+			return err
+		}
+		c.Log().Info("set result previews ", args[2], " for guild ", c.Message.GuildID)
+	}
+
+	preview, err := c.Policies.ResultPreview(c.Message.GuildID)
+	if err != nil {
+		return err
+	}
+	reply := "Result previews: off; results are posted as soon as they're ready."
+	if preview {
+		reply = "Result previews: on; results are DMed to whoever asked for them to publish or discard."
+	}
+	_, err = c.Session.ChannelMessageSend(c.Message.ChannelID, reply)
+	return err
+}
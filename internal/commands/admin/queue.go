@@ -0,0 +1,146 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/exec"
+	"slugbot/internal/helpers"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxRestoreFailures is how many jobs a restore lists as not requeued before
+// summing up the rest, to stay within a message.
+const maxRestoreFailures = 10
+
+// QueueCommand writes the generation queue's state to a JSON attachment, for
+// working out how it got stuck, and queues the unfinished jobs of such a dump
+// again, for recovering from losing the queue.
+type QueueCommand struct {
+	commands.Command
+	Queue *exec.TaskQueue
+	// Restore queues a dumped job again by dispatching the message that
+	// triggered it, or returns why it can't be.
+	Restore func(entry exec.JournalEntry) string
+}
+
+func (c *QueueCommand) Usage() string {
+	return "Usage: `.sadmin queue dump` to attach the queue's state, or `.sadmin queue restore` with a dump attached to queue its unfinished jobs again"
+}
+
+func (c *QueueCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	args := strings.Fields(c.Message.Content)
+	if len(args) != 3 || (args[2] != "dump" && args[2] != "restore") {
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+func (c *QueueCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if strings.Fields(c.Message.Content)[2] == "dump" {
+		return c.dump()
+	}
+	return c.restore()
+}
+
+func (c *QueueCommand) dump() error {
+	dump := c.Queue.Dump()
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return err
+	}
+	paused := ""
+	if dump.Paused {
+		paused = "; the queue is paused"
+	}
+	_, err = c.Session.ChannelMessageSendComplex(c.Message.ChannelID, &discordgo.MessageSend{
+		Content:   fmt.Sprintf("%d job(s), %d of them unfinished%s.", len(dump.Jobs), len(dump.Unfinished()), paused),
+		Reference: c.Message.Reference(),
+		Files: []*discordgo.File{{
+			Name:        fmt.Sprintf("queue-%s.json", dump.Taken.UTC().Format("20060102-150405")),
+			ContentType: "application/json",
+			Reader:      bytes.NewReader(data),
+		}},
+	})
+	return err
+}
+
+func (c *QueueCommand) restore() error {
+	if c.Restore == nil {
+		return fmt.Errorf("no way to restore jobs")
+	}
+	dump, err := c.attachedDump()
+	if err != nil {
+		return c.reply(fmt.Sprintf("Couldn't read the dump: %v", err))
+	}
+
+	var lines []string
+	requeued := 0
+	for _, entry := range dump.Unfinished() {
+		if reason := c.Restore(entry); reason != "" {
+			c.Log().Warn("couldn't restore job ", entry.JobID, ": ", reason)
+			lines = append(lines, fmt.Sprintf("- `%s` in <#%s>: %s", entry.JobID, entry.ChannelID, reason))
+			continue
+		}
+		c.Log().Info("restored job ", entry.JobID, " from a dump")
+		requeued++
+	}
+	report := fmt.Sprintf("Requeued %d job(s) from the dump taken <t:%d:f>.", requeued, dump.Taken.Unix())
+	if len(lines) > 0 {
+		report += fmt.Sprintf("\n**Not requeued** (%d):", len(lines))
+		for i, line := range lines {
+			if i == maxRestoreFailures {
+				report += fmt.Sprintf("\n- ... and %d more", len(lines)-i)
+				break
+			}
+			report += "\n" + line
+		}
+	}
+	return c.reply(report)
+}
+
+// attachedDump downloads and parses the JSON file attached to the message.
+func (c *QueueCommand) attachedDump() (exec.Dump, error) {
+	var url string
+	for _, attachment := range c.Message.Attachments {
+		if strings.EqualFold(path.Ext(attachment.Filename), ".json") {
+			url = attachment.URL
+			break
+		}
+	}
+	if url == "" {
+		return exec.Dump{}, errors.New("attach the `.json` file from `.sadmin queue dump`")
+	}
+	file, err := helpers.DownloadFile(url, "queue-dump-*.json")
+	if err != nil {
+		return exec.Dump{}, err
+	}
+	defer os.Remove(file)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return exec.Dump{}, err
+	}
+	return exec.ParseDump(data)
+}
+
+func (c *QueueCommand) reply(content string) error {
+	_, err := c.Session.ChannelMessageSendComplex(c.Message.ChannelID, &discordgo.MessageSend{
+		Content:         content,
+		Reference:       c.Message.Reference(),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	return err
+}
@@ -0,0 +1,117 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/policy"
+)
+
+// customEmojiRegex matches a custom emoji as it's typed in a message, e.g. "<:party:123>".
+var customEmojiRegex = regexp.MustCompile(`^<a?:(\w+):(\d+)>$`)
+
+// ReactionsCommand turns a guild's acknowledgement reactions on or off and picks their emoji.
+type ReactionsCommand struct {
+	commands.Command
+	Policies *policy.Policies
+}
+
+func (c *ReactionsCommand) Usage() string {
+	return "Usage: `.sadmin reactions show`, `.sadmin reactions <on|off|default>`, or `.sadmin reactions <queued|succeeded|failed> <emoji|default>`"
+}
+
+func (c *ReactionsCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Message.GuildID == "" {
+		return fmt.Errorf("reactions can only be managed inside a server")
+	}
+
+	args := strings.Fields(c.Message.Content)
+	switch {
+	case len(args) == 3 && (args[2] == "show" || args[2] == "on" || args[2] == "off" || args[2] == "default"):
+		return nil
+	case len(args) == 4 && (args[2] == "queued" || args[2] == "succeeded" || args[2] == "failed"):
+		if args[3] == "default" {
+			return nil
+		}
+		if _, err := reactionEmoji(args[3]); err != nil {
+			return err
+		}
+		return nil
+	}
+	return errors.New(c.Usage())
+}
+
+func (c *ReactionsCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if args[2] != "show" {
+		guild, err := c.Policies.GuildReactions(c.Message.GuildID)
+		if err != nil {
+			return err
+		}
+		switch args[2] {
+		case "on", "off":
+			on := args[2] == "on"
+			guild.Enabled = &on
+		case "default":
+			guild.Enabled = nil
+		default:
+			emoji := ""
+			if args[3] != "default" {
+				emoji, _ = reactionEmoji(args[3])
+			}
+			switch args[2] {
+			case "queued":
+				guild.Queued = emoji
+			case "succeeded":
+				guild.Succeeded = emoji
+			case "failed":
+				guild.Failed = emoji
+			}
+		}
+		if err := c.Policies.SetGuildReactions(c.Message.GuildID, guild); err != nil {
+			return err
+		}
+		c.Log().Info("set reactions ", strings.Join(args[2:], " "), " for guild ", c.Message.GuildID)
+	}
+
+	reactions, err := c.Policies.Reactions(c.Message.GuildID)
+	if err != nil {
+		return err
+	}
+	_, err = c.Session.ChannelMessageSend(c.Message.ChannelID, fmt.Sprintf("Reactions: %s\nQueued: %s\nSucceeded: %s\nFailed: %s",
+		onOff(reactions.Enabled), displayEmoji(reactions.Queued), displayEmoji(reactions.Succeeded), displayEmoji(reactions.Failed)))
+	return err
+}
+
+// reactionEmoji turns an emoji typed in a message into the form reactions
+// take: unicode emoji as they are, custom ones as "name:id".
+func reactionEmoji(typed string) (string, error) {
+	if match := customEmojiRegex.FindStringSubmatch(typed); match != nil {
+		return match[1] + ":" + match[2], nil
+	}
+	for _, r := range typed {
+		if r > unicode.MaxASCII {
+			return typed, nil
+		}
+	}
+	return "", fmt.Errorf("`%s` isn't an emoji", typed)
+}
+
+// displayEmoji shows a reaction emoji the way it's typed in a message.
+func displayEmoji(emoji string) string {
+	if strings.Contains(emoji, ":") {
+		return "<:" + emoji + ">"
+	}
+	return emoji
+}
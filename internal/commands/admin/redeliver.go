@@ -0,0 +1,108 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"slugbot/internal/archive"
+	"slugbot/internal/commands"
+	"slugbot/internal/discord"
+	"slugbot/internal/format"
+	"slugbot/internal/results"
+	"slugbot/internal/store"
+)
+
+// RedeliverCommand lists the results whose upload failed and resends or drops them.
+type RedeliverCommand struct {
+	commands.Command
+	Archive *archive.Archiver // optional; mirrors resent results into their guild's archive channel
+}
+
+func (c *RedeliverCommand) Usage() string {
+	return "Usage: `.sadmin redeliver` to list undelivered results, `.sadmin redeliver <id>` to resend one, or `.sadmin redeliver drop <id>` to delete it"
+}
+
+func (c *RedeliverCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	args := strings.Fields(c.Message.Content)
+	switch {
+	case len(args) == 2, len(args) == 3 && args[2] != "drop", len(args) == 4 && args[2] == "drop":
+		return nil
+	}
+	return errors.New(c.Usage())
+}
+
+func (c *RedeliverCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	args := strings.Fields(c.Message.Content)
+
+	switch len(args) {
+	case 2:
+		return c.list()
+	case 3:
+		msg, entry, err := discord.Redeliver(c.Session, args[2])
+		if errors.Is(err, store.ErrNotFound) {
+			return c.reply(fmt.Sprintf("There's no undelivered result `%s`.", args[2]))
+		} else if err != nil {
+			return c.reply(fmt.Sprintf("Resending `%s` failed again: %v", args[2], err))
+		}
+		c.Log().Info("redelivered result ", entry.ID, " as message ", msg.ID)
+		c.clearNotice(entry)
+		if err := c.Archive.Mirror(c.Session, "", msg, results.Fields{}); err != nil {
+			c.Log().Warn("couldn't archive result ", entry.ID, ": ", err)
+		}
+		return c.reply(fmt.Sprintf("Delivered `%s` to <#%s>.", entry.ID, entry.ChannelID))
+	default:
+		entry, lookupErr := discord.LookupUndelivered(args[3])
+		if err := discord.ForgetUndelivered(args[3]); errors.Is(err, store.ErrNotFound) {
+			return c.reply(fmt.Sprintf("There's no undelivered result `%s`.", args[3]))
+		} else if err != nil {
+			return err
+		}
+		if lookupErr == nil {
+			c.clearNotice(entry)
+		}
+		return c.reply(fmt.Sprintf("Deleted undelivered result `%s`.", args[3]))
+	}
+}
+
+func (c *RedeliverCommand) list() error {
+	entries, err := discord.UndeliveredResults()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return c.reply("Every result has been delivered.")
+	}
+	lines := []string{fmt.Sprintf("%d undelivered result(s):", len(entries))}
+	for _, entry := range entries {
+		var names []string
+		for _, file := range entry.Files {
+			names = append(names, file.Name)
+		}
+		lines = append(lines, fmt.Sprintf("`%s` in <#%s>, %s ago: %s", entry.ID, entry.ChannelID,
+			format.Duration(time.Since(entry.Saved).Round(time.Second)), strings.Join(names, ", ")))
+	}
+	return c.reply(strings.Join(lines, "\n"))
+}
+
+// clearNotice takes the retry button off a result's notice once it's been dealt with.
+func (c *RedeliverCommand) clearNotice(entry discord.Undelivered) {
+	if entry.NoticeID == "" {
+		return
+	}
+	if _, err := c.Session.ChannelMessageEdit(entry.ChannelID, entry.NoticeID, "An admin has taken care of this result's delivery."); err != nil {
+		c.Log().Warn("couldn't update the notice of result ", entry.ID, ": ", err)
+	}
+}
+
+func (c *RedeliverCommand) reply(content string) error {
+	_, err := c.Session.ChannelMessageSendReply(c.Message.ChannelID, content, c.Message.Reference())
+	return err
+}
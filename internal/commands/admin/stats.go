@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"fmt"
+	"os"
+
+	"slugbot/internal/analytics"
+	"slugbot/internal/commands"
+	"slugbot/internal/utils"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// StatsCommand posts a summary of the guild's anonymized usage, with a chart when gnuplot is available.
+type StatsCommand struct {
+	commands.Command
+	Analytics *analytics.Collector
+}
+
+func (c *StatsCommand) Usage() string {
+	return "Usage: `.sadmin stats`"
+}
+
+func (c *StatsCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Analytics == nil {
+		return fmt.Errorf("usage analytics aren't enabled; set `[analytics] enabled = true` in the bot's config")
+	}
+	return nil
+}
+
+func (c *StatsCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	summary, err := c.Analytics.Summary(c.Message.GuildID)
+	if err != nil {
+		return err
+	}
+
+	message := &discordgo.MessageSend{
+		Content:   "```\n" + summary.Text() + "\n```",
+		Reference: c.Message.Reference(),
+	}
+
+	if len(summary.Commands) > 0 {
+		chart, err := os.CreateTemp(utils.TempDir(), "slugbot-stats-*.png")
+		if err != nil {
+			return fmt.Errorf("couldn't create chart file: %w", err)
+		}
+		chart.Close()
+		defer os.Remove(chart.Name())
+
+		if err := summary.RenderChart("slugbot usage", chart.Name()); err != nil {
+			// the table is still useful on its own
+			c.Log().Warn("couldn't render usage chart: ", err)
+		} else if file, err := os.Open(chart.Name()); err == nil {
+			defer file.Close()
+			message.Files = []*discordgo.File{{Name: "usage.png", ContentType: "image/png", Reader: file}}
+		}
+	}
+
+	// Discord's limit is in characters, and a byte cut could split one
+	if runes := []rune(message.Content); len(runes) > 2000 {
+		message.Content = string(runes[:1990]) + "...\n```"
+	}
+	_, err = c.Session.ChannelMessageSendComplex(c.Message.ChannelID, message)
+	return err
+}
@@ -0,0 +1,109 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/policy"
+	"slugbot/internal/results"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// setTemplateRegex captures everything after `.sadmin template set`, newlines included.
+var setTemplateRegex = regexp.MustCompile(`(?s)^\S+\s+template\s+set\s+(.+)$`)
+
+// TemplateCommand sets the template a guild's results are posted with.
+type TemplateCommand struct {
+	commands.Command
+	Policies *policy.Policies
+}
+
+func (c *TemplateCommand) Usage() string {
+	return "Usage: `.sadmin template show`, `.sadmin template <off|default>`, or `.sadmin template set <template>`\n" +
+		"Templates are Go text/templates over `{{.Prompt}}`, `{{.Seed}}` (-1 if random), `{{.Duration}}`, `{{.Model}}`, and `{{.Submitter}}`, " +
+		"e.g. `.sadmin template set {{.Submitter}} asked for **{{.Prompt}}** ({{.Model}}, {{.Duration}})`"
+}
+
+func (c *TemplateCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Message.GuildID == "" {
+		return fmt.Errorf("result templates can only be managed inside a server")
+	}
+
+	args := strings.Fields(c.Message.Content)
+	switch {
+	case len(args) == 3 && (args[2] == "show" || args[2] == "off" || args[2] == "default"):
+		return nil
+	case len(args) >= 4 && args[2] == "set":
+		return results.Check(templateText(c.Message.Content))
+	}
+	return errors.New(c.Usage())
+}
+
+func (c *TemplateCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if args[2] != "show" {
+		guild, err := c.Policies.GuildResults(c.Message.GuildID)
+		if err != nil {
+			return err
+		}
+		switch args[2] {
+		case "off":
+			guild.Template = new(string)
+		case "set":
+			text := templateText(c.Message.Content)
+			guild.Template = &text
+		case "default":
+			guild.Template = nil
+		}
+		if err := c.Policies.SetGuildResults(c.Message.GuildID, guild); err != nil {
+			return err
+		}
+		c.Log().Info("set result template ", args[2], " for guild ", c.Message.GuildID)
+	}
+
+	text, err := c.Policies.ResultTemplate(c.Message.GuildID)
+	if err != nil {
+		return err
+	}
+	reply := "Results are posted without text."
+	if text != "" {
+		reply = "Result template:\n```\n" + text + "\n```"
+		sample := results.Sample
+		sample.Submitter = c.Message.Author.Mention()
+		if example, err := results.Render(text, sample); err == nil {
+			reply += "\nFor example:\n" + example
+		}
+	}
+	_, err = c.Session.ChannelMessageSendComplex(c.Message.ChannelID, &discordgo.MessageSend{
+		Content:         reply,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	return err
+}
+
+// templateText returns the template given to `.sadmin template set`, taken
+// out of a code block if it's in one.
+func templateText(content string) string {
+	match := setTemplateRegex.FindStringSubmatch(strings.TrimSpace(content))
+	if match == nil {
+		return ""
+	}
+	text := strings.TrimSpace(match[1])
+	if strings.HasPrefix(text, "```") && strings.HasSuffix(text, "```") && len(text) >= 6 {
+		text = strings.TrimSpace(text[3 : len(text)-3])
+	} else if strings.HasPrefix(text, "`") && strings.HasSuffix(text, "`") && len(text) >= 2 {
+		text = strings.TrimSpace(text[1 : len(text)-1])
+	}
+	return text
+}
@@ -0,0 +1,138 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+
+	"slugbot/internal/backend"
+	"slugbot/internal/commands"
+	"slugbot/internal/commands/traits"
+	"slugbot/internal/exec"
+	"slugbot/internal/provenance"
+	"slugbot/internal/quota"
+)
+
+// compareVotes are the reactions added to a comparison for people to vote with, one per model.
+var compareVotes = []string{"🅰️", "🅱️"}
+
+// CompareCommand generates one prompt with the same seed on two models and
+// posts both results together with a reaction poll.
+type CompareCommand struct {
+	commands.Command
+	traits.Promptable
+	Queue  *exec.TaskQueue
+	Models []string // the two models to compare; see modelParams
+	Audio  *backend.Models
+	Quota  *quota.Tracker
+	Labels *provenance.Labeler
+
+	ahead int
+}
+
+func (c *CompareCommand) Usage() string {
+	return "Usage: `.scompare [--length <s>] [--steps <n>] [--seed <n>] <prompt> [-- <negative words>]`"
+}
+
+func (c *CompareCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Queue == nil {
+		return fmt.Errorf("no queue to schedule comparisons on")
+	}
+	if len(c.Models) != len(compareVotes) {
+		return fmt.Errorf("comparisons need exactly %d models configured, got %d", len(compareVotes), len(c.Models))
+	}
+	args := strings.Fields(c.Message.Content)
+	if len(args) < 2 {
+		return errors.New(c.Usage())
+	}
+	if slices.Contains(args, "--small") || slices.Contains(args, "--enhance") || slices.Contains(args, "--input") {
+		return fmt.Errorf("`.scompare` picks the models itself and doesn't support --small, --enhance, or --input")
+	}
+	return nil
+}
+
+// Apply queues both generations as a group; the results are posted when the second finishes.
+func (c *CompareCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	args := strings.Fields(c.Message.Content)[1:]
+	if !slices.Contains(args, "--seed") {
+		// both models need the same seed for the comparison to mean anything
+		args = append(args, "--seed", strconv.FormatInt(rand.Int64N(1<<31-1), 10))
+	}
+
+	jobs := make([]exec.Task, len(c.Models))
+	group := NewJobGroup(len(c.Models), c.post)
+	for i, model := range c.Models {
+		params, modelArgs, err := modelParams(c.Audio, model, slices.Clone(args))
+		if err != nil {
+			return err
+		}
+		c.SetPrompt(params.Prompt)
+
+		job := &GroupJob{
+			Params:    params,
+			ModelArgs: modelArgs,
+			Label:     fmt.Sprintf("%s (%s)", compareVotes[i], model),
+			Index:     i,
+			Group:     group,
+			Quota:     c.Quota,
+			Labels:    c.Labels,
+		}
+		job.SetContext(c.Session, c.Message)
+		job.SetTraceID(c.TraceID())
+		job.SetTraceContext(c.TraceContext())
+		job.SetPrompt(params.Prompt)
+		jobs[i] = job
+	}
+
+	c.Log().Info("queueing comparison of ", strings.Join(c.Models, " and "))
+	var err error
+	c.ahead, err = c.Queue.EnqueueGroup(jobs)
+	return err
+}
+
+// Ahead returns how many jobs were ahead of the comparison when it was queued.
+func (c *CompareCommand) Ahead() int {
+	return c.ahead
+}
+
+func (c *CompareCommand) post(results []GroupResult) {
+	var seed int64
+	for _, result := range results {
+		if result.Params != nil {
+			seed = result.Params.Seed
+		}
+	}
+
+	var lines []string
+	for _, result := range results {
+		lines = append(lines, result.Label)
+	}
+	content := fmt.Sprintf("Comparison for `%s` (seed %d): %s\nVote for the one you prefer!", c.Prompt(), seed, strings.Join(lines, " vs "))
+
+	sent, err := postGroupFiles(&c.Command, content, results, func(result GroupResult) string {
+		return fmt.Sprintf("%c-%s.wav", 'A'+result.Index, c.Models[result.Index])
+	})
+	if err != nil {
+		c.Log().Error("couldn't post comparison: ", err)
+		c.HandleError(fmt.Errorf("couldn't post comparison: %w", err))
+		return
+	}
+
+	for _, result := range results {
+		if result.Err == nil {
+			if err := c.Session.MessageReactionAdd(sent.ChannelID, sent.ID, compareVotes[result.Index]); err != nil {
+				c.Log().Warn("couldn't add vote reaction: ", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strings"
+
+	"slugbot/internal/backend"
+	"slugbot/internal/commands"
+	"slugbot/internal/commands/traits"
+	"slugbot/internal/exec"
+	"slugbot/internal/helpers"
+	"slugbot/internal/promptspec"
+	"slugbot/internal/provenance"
+	"slugbot/internal/quota"
+
+	"github.com/BurntSushi/toml"
+)
+
+// GridPoint is one combination of settings from a [sweep] table.
+type GridPoint struct {
+	Steps  int64
+	CFG    float64
+	Length float64
+	TOML   string // the block to generate this combination with, minus the [sweep] table
+}
+
+// ExpandSweep expands the [sweep] table of a ```saudio block into one block per
+// combination of its values. Settings the sweep doesn't list keep their [config]
+// value, and every combination uses the same seed: the block's own, or else seed.
+func ExpandSweep(content string, seed int64) (*promptspec.Block, []GridPoint, error) {
+	params, err := promptspec.ParseTOML(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	if params.Sweep.Size() == 0 {
+		return nil, nil, errors.New("the block has no [sweep] values to expand")
+	}
+	if params.Config.Seed < 0 {
+		params.Config.Seed = seed
+	}
+
+	raw := map[string]any{}
+	if _, err := toml.Decode(content, &raw); err != nil {
+		return nil, nil, err
+	}
+	delete(raw, "sweep")
+	config, ok := raw["config"].(map[string]any)
+	if !ok {
+		config = map[string]any{}
+		raw["config"] = config
+	}
+	config["seed"] = params.Config.Seed
+
+	steps := orDefault(params.Sweep.Steps, params.Config.Steps)
+	cfgs := orDefault(params.Sweep.CFG, params.Config.CFG)
+	lengths := orDefault(params.Sweep.Length, params.Config.Length)
+
+	var points []GridPoint
+	for _, s := range steps {
+		for _, cfg := range cfgs {
+			for _, length := range lengths {
+				if s <= 0 || length <= 0 {
+					return nil, nil, fmt.Errorf("sweep steps and lengths need to be positive")
+				}
+				config["steps"] = s
+				config["cfg_scale"] = cfg
+				config["length"] = length
+
+				var buf bytes.Buffer
+				if err := toml.NewEncoder(&buf).Encode(raw); err != nil {
+					return nil, nil, fmt.Errorf("couldn't encode sweep job: %w", err)
+				}
+				points = append(points, GridPoint{Steps: s, CFG: cfg, Length: length, TOML: buf.String()})
+			}
+		}
+	}
+	return params, points, nil
+}
+
+func orDefault[T any](values []T, fallback T) []T {
+	if len(values) == 0 {
+		return []T{fallback}
+	}
+	return values
+}
+
+// promptSummary describes a block's weighted prompts in one line, e.g. "rain (1.00), jazz (0.50)".
+func promptSummary(params *promptspec.Block) string {
+	prompts := make([]string, 0, len(params.Prompts))
+	for prompt := range params.Prompts {
+		prompts = append(prompts, prompt)
+	}
+	sort.Slice(prompts, func(i, j int) bool { return params.Prompts[prompts[i]] > params.Prompts[prompts[j]] })
+
+	parts := make([]string, len(prompts))
+	for i, prompt := range prompts {
+		parts[i] = fmt.Sprintf("%s (%0.2f)", prompt, params.Prompts[prompt])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// GridCommand runs a ```saudio block with a [sweep] table as one queued job per
+// combination of the swept settings, and posts the results with a summary table.
+type GridCommand struct {
+	commands.Command
+	traits.Promptable
+	Queue   *exec.TaskQueue
+	Audio   *backend.Models
+	Quota   *quota.Tracker
+	Labels  *provenance.Labeler
+	MaxJobs int // largest grid one block may expand into
+
+	params *promptspec.Block
+	points []GridPoint
+	ahead  int
+}
+
+// block returns the TOML of the triggering ```saudio message.
+func (c *GridCommand) block() (string, error) {
+	config := &StableAudioWithConfigCommand{}
+	config.SetContext(c.Session, c.Message)
+	if err := config.Validate(); err != nil {
+		return "", err
+	}
+	return config.tomlContent(), nil
+}
+
+// HasSweep reports whether the triggering block is a valid one with a [sweep]
+// table. Blocks that don't parse are left for StableAudioWithConfigCommand to report.
+func (c *GridCommand) HasSweep() bool {
+	content, err := c.block()
+	if err != nil {
+		return false
+	}
+	params, err := promptspec.ParseTOML(content)
+	return err == nil && params.Sweep.Size() > 0
+}
+
+func (c *GridCommand) Usage() string {
+	return fmt.Sprintf("Add a [sweep] table to a ```saudio block to try several settings at once, e.g. `steps = [25, 50, 100]`, `cfg = [5, 7, 9]`, or `length = [10, 30]`; a grid may expand into at most %d jobs.", c.MaxJobs)
+}
+
+func (c *GridCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Queue == nil {
+		return fmt.Errorf("no queue to schedule grids on")
+	}
+	content, err := c.block()
+	if err != nil {
+		return err
+	}
+	params, err := promptspec.ParseTOML(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse toml: %w", err)
+	}
+	if size := params.Sweep.Size(); size < 1 || size > c.MaxJobs {
+		return fmt.Errorf("this sweep expands into %d jobs, but at most %d are allowed", size, c.MaxJobs)
+	}
+	if len(helpers.MessageAudio(c.Message.Message)) > 0 {
+		return fmt.Errorf("sweeps don't support input audio yet")
+	}
+	return nil
+}
+
+// Apply queues one generation per combination as a group; the results are posted when the last finishes.
+func (c *GridCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	content, err := c.block()
+	if err != nil {
+		return err
+	}
+	params, points, err := ExpandSweep(content, rand.Int64N(1<<31-1))
+	if err != nil {
+		return err
+	}
+	c.params, c.points = params, points
+	c.SetPrompt(promptSummary(params))
+
+	var modelArgs []string
+	if !params.Config.Small {
+		modelArgs = c.Audio.Args()
+	}
+
+	jobs := make([]exec.Task, len(points))
+	allParams := make([]*promptspec.Args, len(points))
+	group := NewJobGroup(len(points), c.post)
+	for i, point := range points {
+		allParams[i] = &promptspec.Args{
+			Prompt:   c.Prompt(),
+			Length:   point.Length,
+			Strength: point.CFG,
+			Seed:     params.Config.Seed,
+			Steps:    point.Steps,
+			IsSmall:  params.Config.Small,
+		}
+
+		job := &GroupJob{
+			Params:    allParams[i],
+			ModelArgs: modelArgs,
+			Label:     fmt.Sprintf("#%d (%s)", i+1, pointLabel(point)),
+			Index:     i,
+			Group:     group,
+			Quota:     c.Quota,
+			Labels:    c.Labels,
+			Pattern:   fmt.Sprintf("saudio-grid%d-*.wav", i+1),
+			TOML:      point.TOML,
+		}
+		job.SetContext(c.Session, c.Message)
+		job.SetTraceID(c.TraceID())
+		job.SetTraceContext(c.TraceContext())
+		job.SetPrompt(c.Prompt())
+		jobs[i] = job
+	}
+
+	// every result is held until the last one is done, so they all need to fit at once
+	if err := c.Quota.CheckFits(c.Message.Author.ID, expectedBytes(allParams)); err != nil {
+		return err
+	}
+
+	c.Log().Info("queueing grid of ", len(points), " jobs")
+	c.ahead, err = c.Queue.EnqueueGroup(jobs)
+	return err
+}
+
+// Ahead returns how many jobs were ahead of the grid when it was queued.
+func (c *GridCommand) Ahead() int {
+	return c.ahead
+}
+
+func pointLabel(point GridPoint) string {
+	return fmt.Sprintf("steps %d · cfg %g · %gs", point.Steps, point.CFG, point.Length)
+}
+
+func (c *GridCommand) post(results []GroupResult) {
+	postGroupThread(&c.Command, "grid: "+c.Prompt(), c.summary(results), results, func(result GroupResult) string {
+		return result.Label
+	})
+}
+
+// summary formats the grid's results as a table, one row per combination.
+func (c *GridCommand) summary(results []GroupResult) string {
+	var table strings.Builder
+	fmt.Fprintf(&table, "%-3s %-6s %-6s %-7s %s\n", "#", "steps", "cfg", "length", "result")
+	for _, result := range results {
+		point := c.points[result.Index]
+		status := "ok"
+		if errors.Is(result.Err, ErrCancelled) {
+			status = "cancelled"
+		} else if result.Err != nil {
+			status = "failed"
+		}
+		fmt.Fprintf(&table, "%-3d %-6d %-6g %-7s %s\n", result.Index+1, point.Steps, point.CFG, fmt.Sprintf("%gs", point.Length), status)
+	}
+	return fmt.Sprintf("Grid for `%s` (seed %d):\n```\n%s```", c.Prompt(), c.params.Config.Seed, table.String())
+}
@@ -0,0 +1,43 @@
+package audio
+
+import (
+	"testing"
+
+	"slugbot/internal/promptspec"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandSweep_OneBlockPerCombination(t *testing.T) {
+	content := `
+[prompts]
+"rainy jazz" = 1.0
+
+[config]
+length = 10.0
+
+[sweep]
+steps = [25, 50]
+cfg = [5, 7, 9]
+`
+	params, points, err := ExpandSweep(content, 42)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), params.Config.Seed)
+	require.Len(t, points, 6)
+	require.Equal(t, int64(50), points[5].Steps)
+	require.Equal(t, 9.0, points[5].CFG)
+
+	expanded, err := promptspec.ParseTOML(points[5].TOML)
+	require.NoError(t, err)
+	require.Zero(t, expanded.Sweep.Size())
+	require.Equal(t, promptspec.Config{Length: 10, Steps: 50, CFG: 9, Seed: 42}, expanded.Config)
+	require.Equal(t, 1.0, expanded.Prompts["rainy jazz"])
+}
+
+func TestExpandSweep_KeepsTheBlocksSeed(t *testing.T) {
+	params, points, err := ExpandSweep("[prompts]\nrain = 1.0\n[config]\nseed = 7\n[sweep]\nlength = [5, 10]\n", 42)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), params.Config.Seed)
+	require.Len(t, points, 2)
+	require.Equal(t, int64(100), points[0].Steps)
+}
@@ -0,0 +1,353 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"slugbot/internal/backend"
+	"slugbot/internal/cmdline"
+	"slugbot/internal/commands"
+	"slugbot/internal/commands/traits"
+	"slugbot/internal/discord"
+	"slugbot/internal/eta"
+	"slugbot/internal/prefs"
+	"slugbot/internal/promptspec"
+	"slugbot/internal/provenance"
+	"slugbot/internal/quota"
+	"slugbot/internal/telemetry"
+	"slugbot/internal/tools"
+	"slugbot/internal/triage"
+	"slugbot/internal/utils"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ErrCancelled is the error of a group job that was removed from the queue.
+var ErrCancelled = errors.New("cancelled")
+
+// GroupResult is the outcome of one job in a JobGroup.
+type GroupResult struct {
+	Index   int
+	Label   string
+	Params  *promptspec.Args
+	Path    string // generated file; empty if Err is set
+	Err     error
+	Release func() // stops charging Path to the user's quota
+	Footer  string // attribution line to post with the file; may be empty
+}
+
+// JobGroup collects the outputs of jobs that were queued together, so they can
+// be posted as one set once the last of them finishes.
+type JobGroup struct {
+	OnComplete func(results []GroupResult)
+
+	mutex     sync.Mutex
+	results   []GroupResult
+	remaining int
+}
+
+// NewJobGroup returns a group expecting total results.
+func NewJobGroup(total int, onComplete func(results []GroupResult)) *JobGroup {
+	return &JobGroup{OnComplete: onComplete, remaining: total}
+}
+
+// Done records a job's result, calling OnComplete with every result, in index
+// order, once all of them are in. If every job was cancelled there's nothing to
+// report, so OnComplete isn't called.
+func (g *JobGroup) Done(result GroupResult) {
+	g.mutex.Lock()
+	g.results = append(g.results, result)
+	g.remaining--
+	finished := g.remaining == 0
+	g.mutex.Unlock()
+
+	if !finished || g.OnComplete == nil {
+		return
+	}
+	for _, result := range g.results {
+		if !errors.Is(result.Err, ErrCancelled) {
+			sort.Slice(g.results, func(i, j int) bool { return g.results[i].Index < g.results[j].Index })
+			g.OnComplete(g.results)
+			return
+		}
+	}
+}
+
+// Cancel counts a job that was removed from the queue before running.
+func (g *JobGroup) Cancel(index int, label string) {
+	g.Done(GroupResult{Index: index, Label: label, Err: ErrCancelled})
+}
+
+// GroupJob is one generation of a JobGroup. Unlike StableAudioCommand it
+// doesn't post its output; it hands it to the group.
+type GroupJob struct {
+	commands.Command
+	traits.Promptable
+	traits.Progressable
+	traits.Interruptible
+	Params    *promptspec.Args
+	ModelArgs []string // extra sag arguments selecting the checkpoint
+	Label     string   // shown in the progress message, e.g. "A (small)"
+	Index     int
+	Group     *JobGroup
+	Quota     *quota.Tracker
+	Pattern   string // optional os.CreateTemp pattern for the output file
+	TOML      string // if set, generate from this ```saudio block instead of Params; Params then only describes the job
+	Labels    *provenance.Labeler
+}
+
+// Shape reports the model, steps, and length this job will generate with.
+func (job *GroupJob) Shape() (eta.Shape, bool) {
+	return shapeOf(job.Params.IsSmall, job.Params.Steps, job.Params.Length), true
+}
+
+// Cancelled reports the job to the group when it's removed from the queue.
+func (job *GroupJob) Cancelled() {
+	job.Group.Cancel(job.Index, job.Label)
+}
+
+// HandleError only logs; the group reports failures alongside the other results.
+func (job *GroupJob) HandleError(err error) {
+	job.Log().Error("group job ", job.Label, " failed: ", err)
+}
+
+func (job *GroupJob) Apply() error {
+	path, err := job.generate()
+	if err != nil && job.Retrying() {
+		// the queue runs it again, so the group isn't done with it yet
+		return err
+	}
+	result := GroupResult{Index: job.Index, Label: job.Label, Params: job.Params, Path: path, Err: err, Release: func() {}}
+	if err == nil {
+		if err := job.Labels.Watermark(job.TraceContext(), job.Message.GuildID, path, job.TraceID()); err != nil {
+			job.Log().Warn("couldn't watermark ", job.Label, ": ", err)
+		}
+		result.Footer = job.Labels.Footer(job.Message.GuildID, modelName(job.Params.IsSmall, job.ModelArgs))
+		result.Release = job.Quota.Track(job.Message.Author.ID, path)
+	}
+	job.Group.Done(result)
+	return err
+}
+
+func (job *GroupJob) generate() (string, error) {
+	log := job.Log()
+	ctx := job.TraceContext()
+
+	pattern := job.Pattern
+	if pattern == "" {
+		pattern = "slugbot-group-*.wav"
+	}
+	out, err := os.CreateTemp(utils.TempDir(), pattern)
+	if err != nil {
+		return "", fmt.Errorf("couldn't create output file: %w", err)
+	}
+	out.Close()
+
+	fp, err := newProgressMessage(job.Session, job.Message.ChannelID, job.Message.ID)
+	if err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("failed to init progress poller: %w", err)
+	}
+	fp.Footer = commands.TraceFooter(job.TraceID())
+	fp.OnUpdate = job.SetProgress
+	fp.Quiet = prefs.FromContext(ctx).Quiet
+	if err := fp.Start(fmt.Sprintf("Generating %s: `%s` (seed %d)...", job.Label, job.Params.Prompt, job.Params.Seed)); err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("failed to start progress poller: %w", err)
+	}
+	defer fp.Stop()
+
+	cmdArgs := sagArgs(job.Params, out.Name(), fp.FilePath, "")
+	if job.TOML != "" {
+		cmdArgs = cmdline.New("--toml").Option("--progress_file", fp.FilePath).Option("--output", out.Name()).Args()
+	}
+	cmdArgs = append(cmdArgs, job.ModelArgs...)
+	runCtx, release := job.WithInterrupt(ctx)
+	defer release()
+	command := exec.CommandContext(runCtx, tools.Path(tools.Sag), cmdArgs...)
+	if job.TOML != "" {
+		command.Stdin = strings.NewReader(job.TOML)
+	}
+	command.Stdout = os.Stdout
+	stderr := triage.NewTail(stderrTail)
+	command.Stderr = io.MultiWriter(os.Stderr, stderr)
+
+	log.Info("generating ", job.Label)
+	fp.SetPhase(discord.PhaseDiffusing)
+	started := time.Now()
+	_, runSpan := telemetry.Start(ctx, "subprocess.run")
+	err = job.InterruptCause(runCtx, stderr.Wrap(command.Run()))
+	telemetry.End(runSpan, err)
+	if err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("error during audio generation: %w", err)
+	}
+	if keepsProgress(job.Params.KeepProgress, job.Message) {
+		if err := fp.Finish(job.Label + ": " + progressSummary(time.Since(started), job.Params.Seed)); err != nil {
+			log.Warn("couldn't leave progress summary: ", err)
+		}
+	}
+	return out.Name(), nil
+}
+
+// modelParams parses generation arguments for one of the configured models:
+// "small", "full" (the active checkpoint), or the name of an installed checkpoint.
+// It returns the parsed parameters and the sag arguments selecting the model.
+func modelParams(models *backend.Models, name string, args []string) (*promptspec.Args, []string, error) {
+	var modelArgs []string
+	switch name {
+	case "small":
+		args = append(args, "--small")
+	case "full":
+		modelArgs = models.Args()
+	default:
+		if models == nil {
+			return nil, nil, fmt.Errorf("unknown model `%s`", name)
+		}
+		if err := models.Validate(name); err != nil {
+			return nil, nil, err
+		}
+		modelArgs = []string{"--model_dir", models.Path(name)}
+	}
+
+	params, err := parseArgs(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	return params, modelArgs, nil
+}
+
+// modelName describes the checkpoint a generation used, given whether it was
+// small and the sag arguments selecting the model.
+func modelName(small bool, modelArgs []string) string {
+	if small {
+		return "Stable Audio Open Small"
+	}
+	if i := slices.Index(modelArgs, "--model_dir"); i >= 0 && i+1 < len(modelArgs) {
+		return "checkpoint " + filepath.Base(modelArgs[i+1])
+	}
+	return "Stable Audio Open 1.0"
+}
+
+// WAVBytesPerSecond is the size of sag's output: 44.1 kHz stereo 32-bit float.
+const WAVBytesPerSecond = 44100 * 2 * 4
+
+// expectedBytes estimates how much disk a set of generations will take up.
+func expectedBytes(params []*promptspec.Args) int64 {
+	var total float64
+	for _, p := range params {
+		total += p.Length * WAVBytesPerSecond
+	}
+	return int64(total)
+}
+
+// postGroupFiles uploads a group's successful results in one message, or as
+// many as they need, along with a line per failure, then releases them from
+// the user's quota. It returns the first message.
+func postGroupFiles(c *commands.Command, content string, results []GroupResult, name func(GroupResult) string) (*discordgo.Message, error) {
+	// the trigger may have been deleted to cancel the rest of the group
+	reference := c.Message.Reference()
+	reference.FailIfNotExists = new(bool)
+	message := &discordgo.MessageSend{Content: content, Reference: reference}
+	var footers []string
+	for _, result := range results {
+		if result.Footer != "" && !slices.Contains(footers, result.Footer) {
+			footers = append(footers, result.Footer)
+		}
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			message.Content += fmt.Sprintf("\n%s failed: %s", result.Label, commands.FailureText(c.Session, result.Err, c.TraceID()))
+			continue
+		}
+		file, err := os.Open(result.Path)
+		if err != nil {
+			message.Content += fmt.Sprintf("\n%s failed: %s", result.Label, commands.FailureText(c.Session, err, c.TraceID()))
+			continue
+		}
+		defer file.Close()
+		message.Files = append(message.Files, &discordgo.File{Name: name(result), ContentType: "audio/wav", Reader: file})
+	}
+	if len(footers) > 0 {
+		message.Content += "\n" + strings.Join(footers, "\n")
+	}
+
+	sent, err := discord.SendParts(c.Session, c.Message.ChannelID, message)
+	for _, result := range results {
+		if result.Err == nil {
+			os.Remove(result.Path)
+			result.Release()
+		}
+	}
+	if len(sent) == 0 {
+		return nil, err
+	}
+	return sent[0], err
+}
+
+// groupUploadParallelism bounds how many results of a group upload at once.
+const groupUploadParallelism = 2
+
+// postGroupThread posts a summary and then each of a group's results, one
+// message per file, into a thread on the triggering message, or into the
+// channel if a thread can't be started (e.g. in DMs). Files are removed and
+// released from the user's quota afterwards.
+func postGroupThread(c *commands.Command, threadName string, summary string, results []GroupResult, caption func(GroupResult) string) {
+	log := c.Log()
+
+	channelID := c.Message.ChannelID
+	replyToID := c.Message.ID
+	if thread, err := c.Session.MessageThreadStart(c.Message.ChannelID, c.Message.ID, truncate(threadName, 100), 24*60); err == nil {
+		channelID, replyToID = thread.ID, ""
+		c.Session.ChannelMessageSend(thread.ID, summary)
+	} else {
+		log.Warn("couldn't start a thread for group results: ", err)
+		c.Session.ChannelMessageSendReply(c.Message.ChannelID, summary, c.Message.Reference())
+	}
+
+	delivery, err := discord.NewDeliveryCoordinator(discord.ConcreteSession{Session: c.Session}, channelID, replyToID, len(results), groupUploadParallelism)
+	if err != nil {
+		c.HandleError(err)
+		return
+	}
+	delivery.Caption = func(index int) string {
+		if footer := results[index].Footer; footer != "" {
+			return caption(results[index]) + "\n" + footer
+		}
+		return caption(results[index])
+	}
+
+	var failures []string
+	for _, result := range results {
+		if result.Err != nil {
+			failures = append(failures, fmt.Sprintf("%s failed: %s", result.Label, commands.FailureText(c.Session, result.Err, c.TraceID())))
+			continue
+		}
+		delivery.Deliver(result.Index, result.Path)
+	}
+
+	start := time.Now()
+	_, err = delivery.Wait()
+	log.Info("delivered group results in ", time.Since(start).Round(time.Millisecond))
+	for _, result := range results {
+		if result.Err == nil {
+			os.Remove(result.Path)
+			result.Release()
+		}
+	}
+	if err != nil {
+		failures = append(failures, "Some results couldn't be posted: "+commands.FailureText(c.Session, err, c.TraceID()))
+	}
+	if len(failures) > 0 {
+		c.Session.ChannelMessageSend(channelID, strings.Join(failures, "\n")+commands.TraceFooter(c.TraceID()))
+	}
+}
@@ -0,0 +1,30 @@
+package audio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobGroup_CompletesInIndexOrder(t *testing.T) {
+	var got []GroupResult
+	group := NewJobGroup(3, func(results []GroupResult) { got = results })
+
+	group.Done(GroupResult{Index: 2, Label: "c"})
+	group.Cancel(1, "b")
+	require.Nil(t, got)
+
+	group.Done(GroupResult{Index: 0, Label: "a", Err: errors.New("boom")})
+	require.Len(t, got, 3)
+	require.Equal(t, []string{"a", "b", "c"}, []string{got[0].Label, got[1].Label, got[2].Label})
+	require.ErrorIs(t, got[1].Err, ErrCancelled)
+}
+
+func TestJobGroup_AllCancelledSkipsCompletion(t *testing.T) {
+	called := false
+	group := NewJobGroup(2, func([]GroupResult) { called = true })
+	group.Cancel(0, "a")
+	group.Cancel(1, "b")
+	require.False(t, called)
+}
@@ -0,0 +1,135 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/exec"
+	"slugbot/internal/format"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// JobCommand shows the status of a queued, running, or recently finished job by its ID.
+type JobCommand struct {
+	commands.Command
+	Queue *exec.TaskQueue
+}
+
+func (c *JobCommand) Usage() string {
+	return "Usage: `.sjob <id>`, with the ID from the queue reply, e.g. `.sjob A7F3`, or `.sjob swap <their job> [your job]` to offer to trade places in the queue"
+}
+
+func (c *JobCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Queue == nil {
+		return fmt.Errorf("no queue to look jobs up in")
+	}
+	if len(strings.Fields(c.Message.Content)) != 2 {
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+func (c *JobCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	id := strings.Fields(c.Message.Content)[1]
+	info, ok := c.Queue.Info(id)
+	if !ok || !JobVisibleTo(info, c.Message) {
+		_, err := c.Session.ChannelMessageSendReply(c.Message.ChannelID, fmt.Sprintf("No job `%s`; finished jobs are only kept for a while.", strings.ToUpper(id)), c.Message.Reference())
+		return err
+	}
+	_, err := c.Session.ChannelMessageSendReply(c.Message.ChannelID, c.describe(info), c.Message.Reference())
+	return err
+}
+
+// describe formats a job's status, progress, position, settings, and links.
+func (c *JobCommand) describe(info exec.TaskInfo) string {
+	lines := []string{fmt.Sprintf("**Job `%s`** · %s", info.ID, jobState(info))}
+
+	if prompt := info.Task.Prompt(); prompt != "" {
+		lines = append(lines, "Prompt: `"+truncate(prompt, 200)+"`")
+	}
+	if info.State == exec.StateRunning {
+		if progressing, ok := info.Task.(exec.Progressing); ok && progressing.Progress() != "" {
+			lines = append(lines, "Progress: "+progressing.Progress())
+		}
+	}
+	if info.State == exec.StateWaiting {
+		position := fmt.Sprintf("Position: %d job(s) ahead", info.Position)
+		if wait, ok := c.Queue.EstimateWait(info.Position); ok {
+			position += "; estimated start in ~" + format.Duration(wait)
+		}
+		if paused, _, _ := c.Queue.Status(); paused {
+			position += " (the queue is paused for maintenance)"
+		}
+		lines = append(lines, position)
+	}
+	if estimable, ok := info.Task.(exec.Estimable); ok {
+		if shape, ok := estimable.Shape(); ok {
+			lines = append(lines, fmt.Sprintf("Settings: %s model · %d steps · %s", shape.Model, shape.Steps, format.Duration(time.Duration(shape.Length*float64(time.Second)))))
+		}
+	}
+	if info.Err != nil {
+		lines = append(lines, "Error: "+info.Err.Error())
+	}
+	if trigger := jobTrigger(info); trigger != nil {
+		lines = append(lines, "Requested in "+messageLink(trigger.Message))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// JobVisibleTo reports whether a job can be shown in reply to a message: it
+// has to have been asked for in the same server, or be the asker's own. Jobs
+// from DMs, or that weren't asked for by anyone, are only shown to their owner.
+func JobVisibleTo(info exec.TaskInfo, message *discordgo.MessageCreate) bool {
+	trigger := jobTrigger(info)
+	if trigger == nil {
+		return false
+	}
+	if trigger.Author != nil && message.Author != nil && trigger.Author.ID == message.Author.ID {
+		return true
+	}
+	return trigger.GuildID != "" && trigger.GuildID == message.GuildID
+}
+
+// jobTrigger returns the message that asked for a job, or nil if there wasn't one.
+func jobTrigger(info exec.TaskInfo) *discordgo.MessageCreate {
+	triggered, ok := info.Task.(interface {
+		TriggerMessage() *discordgo.MessageCreate
+	})
+	if !ok {
+		return nil
+	}
+	return triggered.TriggerMessage()
+}
+
+func jobState(info exec.TaskInfo) string {
+	switch info.State {
+	case exec.StateWaiting:
+		return "waiting for " + format.Duration(time.Since(info.Enqueued))
+	case exec.StateRunning:
+		return "running for " + format.Duration(time.Since(info.Started))
+	case exec.StateCancelled:
+		return "cancelled " + format.Duration(time.Since(info.Finished)) + " ago"
+	default:
+		return fmt.Sprintf("%s %s ago, after %s", info.State, format.Duration(time.Since(info.Finished)), format.Duration(info.Finished.Sub(info.Started)))
+	}
+}
+
+// messageLink returns a jump link to a message, in a guild or a DM.
+func messageLink(m *discordgo.Message) string {
+	guild := m.GuildID
+	if guild == "" {
+		guild = "@me"
+	}
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guild, m.ChannelID, m.ID)
+}
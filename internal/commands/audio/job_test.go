@@ -0,0 +1,34 @@
+package audio
+
+import (
+	"testing"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/exec"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+func askedFor(guildID string, authorID string) *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{Message: &discordgo.Message{GuildID: guildID, Author: &discordgo.User{ID: authorID}}}
+}
+
+func TestJobVisibleTo_OnlyTheSameServerOrTheOwner(t *testing.T) {
+	job := func(trigger *discordgo.MessageCreate) exec.TaskInfo {
+		return exec.TaskInfo{Task: &StableAudioCommand{Command: commands.Command{Message: trigger}}}
+	}
+
+	inGuild := job(askedFor("g1", "u1"))
+	require.True(t, JobVisibleTo(inGuild, askedFor("g1", "u2")))
+	require.False(t, JobVisibleTo(inGuild, askedFor("g2", "u2")))
+	require.False(t, JobVisibleTo(inGuild, askedFor("", "u2")))
+	require.True(t, JobVisibleTo(inGuild, askedFor("", "u1")))
+
+	inDMs := job(askedFor("", "u1"))
+	require.True(t, JobVisibleTo(inDMs, askedFor("", "u1")))
+	require.False(t, JobVisibleTo(inDMs, askedFor("", "u2")))
+	require.False(t, JobVisibleTo(inDMs, askedFor("g1", "u2")))
+
+	require.False(t, JobVisibleTo(job(nil), askedFor("g1", "u1")))
+}
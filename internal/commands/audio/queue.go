@@ -0,0 +1,69 @@
+package audio
+
+import (
+	"fmt"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/discord"
+	"slugbot/internal/exec"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// QueueCommand lists the running and waiting generation jobs.
+type QueueCommand struct {
+	commands.Command
+	Queue *exec.TaskQueue
+	Pages *discord.Paginator
+}
+
+func (c *QueueCommand) Usage() string {
+	return "Usage: `.squeue`"
+}
+
+func (c *QueueCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Queue == nil || c.Pages == nil {
+		return fmt.Errorf("no queue to list")
+	}
+	return nil
+}
+
+func (c *QueueCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	current, waiting := c.Queue.Snapshot()
+	var lines []string
+	if current != nil {
+		lines = append(lines, "**now** · "+queueLine(current))
+	}
+	for i, task := range waiting {
+		lines = append(lines, fmt.Sprintf("**%d.** %s", i+1, queueLine(task)))
+	}
+
+	title := fmt.Sprintf("Queue (%d waiting)", len(waiting))
+	if paused, _, _ := c.Queue.Status(); paused {
+		title += " · paused for maintenance"
+	}
+	_, err := c.Pages.Send(c.Session, c.Message.ChannelID, c.Message.Reference(), title, lines)
+	return err
+}
+
+// queueLine describes a queued task by its prompt and who asked for it.
+func queueLine(task exec.Task) string {
+	prompt := task.Prompt()
+	if prompt == "" {
+		prompt = "config block"
+	}
+	line := "`" + truncate(prompt, 80) + "`"
+	if triggered, ok := task.(interface {
+		TriggerMessage() *discordgo.MessageCreate
+	}); ok && triggered.TriggerMessage() != nil && triggered.TriggerMessage().Author != nil {
+		line += " · <@" + triggered.TriggerMessage().Author.ID + ">"
+	}
+	return line
+}
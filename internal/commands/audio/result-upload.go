@@ -0,0 +1,110 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"slugbot/internal/cmdline"
+	"slugbot/internal/config"
+	"slugbot/internal/discord"
+	"slugbot/internal/features"
+	"slugbot/internal/format"
+	"slugbot/internal/helpers"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/tools"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// clipArgs builds the ffmpeg arguments that cut the first length of a result
+// into a small MP3.
+func clipArgs(inFile string, length time.Duration, outFile string) []string {
+	return cmdline.New("-y", "-hide_banner", "-loglevel", "error", "-t", fmt.Sprintf("%.3f", length.Seconds()), "-i").
+		Path(inFile).
+		Trusted("-vn", "-c:a", "libmp3lame", "-q:a", "4").
+		Path(outFile).
+		Args()
+}
+
+// quickArgs builds the ffmpeg arguments that encode a whole result as a
+// small, low-bitrate mono MP3, which uploads quickly.
+func quickArgs(inFile string, outFile string) []string {
+	return cmdline.New("-y", "-hide_banner", "-loglevel", "error", "-i").
+		Path(inFile).
+		Trusted("-vn", "-ac", "1", "-c:a", "libmp3lame", "-b:a", "48k").
+		Path(outFile).
+		Args()
+}
+
+// uploadable returns the file to post for a result: the result itself, or,
+// if it's over Discord's upload limit, a clip of its first
+// [results] clip_length, with a note saying so to go with it. cleanup
+// removes the clip; the result itself is left where it is.
+func uploadable(guildID string, outFile string) (path string, note string, cleanup func(), err error) {
+	info, err := os.Stat(outFile)
+	if err != nil {
+		return "", "", nil, err
+	}
+	length := config.Get().Results.ClipLength
+	if info.Size() <= discord.MaxMessageBytes || length <= 0 {
+		return outFile, "", func() {}, nil
+	}
+
+	clip := strings.TrimSuffix(outFile, filepath.Ext(outFile)) + "-clip.mp3"
+	command := exec.Command(tools.Path(tools.FFmpeg), helpers.FFmpegArgs(guildID, clipArgs(outFile, length, clip)...)...)
+	slog.Trace("Running command: ", strings.Join(command.Args, " "))
+	if out, err := command.CombinedOutput(); err != nil {
+		os.Remove(clip)
+		return "", "", nil, fmt.Errorf("couldn't clip a result too big to upload: %w\nOutput: %s", err, string(out))
+	}
+
+	note = fmt.Sprintf("The full result is %s, over Discord's upload limit, so this is its first %s.", format.Bytes(info.Size()), format.Duration(length))
+	if url := dashboardURL(); url != "" {
+		note += " The full file is on the dashboard: " + url
+	}
+	return clip, note, func() { os.Remove(clip) }, nil
+}
+
+// dashboardURL returns where the dashboard, which serves every job's full
+// results, is reached, or "" if it isn't running.
+func dashboardURL() string {
+	cfg := config.Get().Dashboard
+	if !features.Enabled(features.Dashboard) || cfg.Listen == "" || cfg.RedirectURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(cfg.RedirectURL, "callback")
+}
+
+// sendResult posts a result message whose only file is the result at path.
+// A result over [results] quick_over bytes is sent with SendInTwoPhases, a
+// low-bitrate MP3 of it standing in while it uploads, if api can edit
+// messages; anything else is sent with SendFiles.
+func sendResult(api discord.ComplexSender, guildID string, channelID string, send *discordgo.MessageSend, path string) (*discordgo.Message, error) {
+	threshold := config.Get().Results.QuickOver
+	twoPhase, ok := api.(discord.TwoPhaseSender)
+	info, err := os.Stat(path)
+	if !ok || threshold <= 0 || err != nil || info.Size() <= threshold {
+		return discord.SendFiles(api, channelID, send)
+	}
+
+	quick := strings.TrimSuffix(path, filepath.Ext(path)) + "-quick.mp3"
+	defer os.Remove(quick)
+	command := exec.Command(tools.Path(tools.FFmpeg), helpers.FFmpegArgs(guildID, quickArgs(path, quick)...)...)
+	slog.Trace("Running command: ", strings.Join(command.Args, " "))
+	if out, err := command.CombinedOutput(); err != nil {
+		slog.Warn("couldn't make a quick version of ", path, "; sending it whole: ", err, "\nOutput: ", string(out))
+		return discord.SendFiles(api, channelID, send)
+	}
+	file, err := os.Open(quick)
+	if err != nil {
+		return discord.SendFiles(api, channelID, send)
+	}
+	defer file.Close()
+
+	quickFiles := []*discordgo.File{{Name: filepath.Base(quick), ContentType: "audio/mpeg", Reader: file}}
+	return discord.SendInTwoPhases(twoPhase, channelID, send, quickFiles, "-# A quick, low-quality version; the full file is on its way.")
+}
@@ -0,0 +1,61 @@
+package audio
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"slugbot/internal/config"
+	"slugbot/internal/discord"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClipAndQuickArgs(t *testing.T) {
+	require.Equal(t,
+		[]string{"-y", "-hide_banner", "-loglevel", "error", "-t", "30.000", "-i", "./-odd.wav", "-vn", "-c:a", "libmp3lame", "-q:a", "4", "out-clip.mp3"},
+		clipArgs("-odd.wav", 30*time.Second, "out-clip.mp3"))
+	require.Equal(t,
+		[]string{"-y", "-hide_banner", "-loglevel", "error", "-i", "out.wav", "-vn", "-ac", "1", "-c:a", "libmp3lame", "-b:a", "48k", "out-quick.mp3"},
+		quickArgs("out.wav", "out-quick.mp3"))
+}
+
+func TestUploadable_ClipsOnlyWhatsTooBig(t *testing.T) {
+	defer config.Set(config.Get())
+	defer func(limit int64) { discord.MaxMessageBytes = limit }(discord.MaxMessageBytes)
+	discord.MaxMessageBytes = 10
+
+	dir := t.TempDir()
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	// copies the input to the output, the last argument
+	require.NoError(t, os.WriteFile(ffmpeg, []byte("#!/bin/sh\nfor a; do [ \"$prev\" = -i ] && in=$a; prev=$a; out=$a; done\ncp \"$in\" \"$out\"\n"), 0o755))
+	cfg := config.Default()
+	cfg.Tools.FFmpeg = ffmpeg
+	config.Set(cfg)
+
+	small := filepath.Join(dir, "small.wav")
+	require.NoError(t, os.WriteFile(small, []byte("tiny"), 0o644))
+	path, note, cleanup, err := uploadable("", small)
+	require.NoError(t, err)
+	cleanup()
+	require.Equal(t, small, path)
+	require.Empty(t, note)
+
+	big := filepath.Join(dir, "big.wav")
+	require.NoError(t, os.WriteFile(big, []byte(strings.Repeat("x", 20)), 0o644))
+	path, note, cleanup, err = uploadable("", big)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "big-clip.mp3"), path)
+	require.Contains(t, note, "first 30s")
+	require.FileExists(t, path)
+	cleanup()
+	require.NoFileExists(t, path)
+	require.FileExists(t, big)
+
+	cfg.Results.ClipLength = 0
+	path, _, _, err = uploadable("", big)
+	require.NoError(t, err)
+	require.Equal(t, big, path)
+}
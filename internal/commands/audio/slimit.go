@@ -1,15 +1,18 @@
 package audio
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 	"time"
 
+	"slugbot/internal/cmdline"
 	"slugbot/internal/commands"
-	"slugbot/internal/io/slog"
+	"slugbot/internal/discord"
+	"slugbot/internal/helpers"
+	"slugbot/internal/tools"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -21,7 +24,7 @@ type LimitCommand struct {
 
 // Usage shows basic help for .slimit
 func (c *LimitCommand) Usage() string {
-	return "Usage: `.slimit` (reply to or attach a .wav file)"
+	return "Usage: `.slimit [--input <n|filename>]` (reply to or attach a .wav file)"
 }
 
 func (c *LimitCommand) Validate() error {
@@ -33,6 +36,8 @@ func (c *LimitCommand) Validate() error {
 }
 
 func (c *LimitCommand) Apply() error {
+	log := c.Log()
+
 	if err := c.Validate(); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
@@ -42,48 +47,33 @@ func (c *LimitCommand) Apply() error {
 		ChannelID: c.Message.ChannelID,
 	}
 
-	// 1) find source WAV URL
-	var srcURL string
-	for _, att := range c.Message.Attachments {
-		if strings.HasSuffix(att.Filename, ".wav") {
-			srcURL = att.URL
-			break
-		}
-	}
-	if srcURL == "" && c.Message.MessageReference != nil {
-		ref, err := c.Session.ChannelMessage(
-			c.Message.ChannelID,
-			c.Message.MessageReference.MessageID,
-		)
-		if err == nil {
-			for _, att := range ref.Attachments {
-				if strings.HasSuffix(att.Filename, ".wav") {
-					srcURL = att.URL
-					break
-				}
-			}
-		}
-	}
-	if srcURL == "" {
+	// 1) find source WAV URL, attached or in the message being replied to
+	src, err := helpers.ResolveInput(c.Session, c.Message, helpers.MessageAudio, c.Input)
+	if errors.Is(err, helpers.ErrNoInput) {
 		c.Session.ChannelMessageSendReply(c.Message.ChannelID,
-			"No WAV found to limit", triggering)
+			"No WAV found to limit; attach one or reply to one with `.slimit`", triggering)
 		return nil
 	}
+	if err != nil {
+		c.Session.ChannelMessageSendReply(c.Message.ChannelID, err.Error(), triggering)
+		return nil
+	}
+	srcURL := src.URL
 
 	// 2) download to temp file
-	tmpIn, err := downloadAndSave(srcURL)
+	tmpIn, err := downloadAndSave(srcURL, c.Message)
 	if err != nil {
 		return fmt.Errorf("download failed: %w", err)
 	}
 	defer os.Remove(tmpIn)
 
 	// 3) run limiter script
-	py_path := filepath.Join(".conda", "general-dsp", "bin", "python")
+	py_path := tools.Path(tools.Python)
 	outFile := fmt.Sprintf("slimit-%d.wav", time.Now().Unix())
-	cmd := exec.Command(
-		py_path, "py/limiter.py",
-		"--input", tmpIn,
-		"--output", outFile,
+	cmd := exec.Command(py_path, cmdline.New(filepath.Join("py", "limiter.py")).
+		Option("--input", tmpIn).
+		Option("--output", outFile).
+		Args()...,
 	)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -106,12 +96,13 @@ func (c *LimitCommand) Apply() error {
 		}},
 		Reference: triggering,
 	}
-	if _, err := c.Session.ChannelMessageSendComplex(
-		c.Message.ChannelID, msg,
-	); err != nil {
+	if _, err := discord.SendFiles(c.Session, c.Message.ChannelID, msg); errors.Is(err, discord.ErrUndelivered) {
+		log.Warn("couldn't deliver limited file: ", err)
+		return nil
+	} else if err != nil {
 		return fmt.Errorf("send failed: %w", err)
 	}
 
-	slog.Info("Delivered limited file:", outFile)
+	log.Info("Delivered limited file:", outFile)
 	return nil
 }
@@ -4,54 +4,52 @@ package audio
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
 	"time"
 
+	"slugbot/internal/archive"
+	"slugbot/internal/backend"
+	"slugbot/internal/chaos"
+	"slugbot/internal/cmdline"
 	"slugbot/internal/commands"
 	"slugbot/internal/commands/traits"
 	"slugbot/internal/discord"
+	"slugbot/internal/eta"
+	"slugbot/internal/format"
 	"slugbot/internal/io/slog"
+	"slugbot/internal/prefs"
+	"slugbot/internal/promptspec"
+	"slugbot/internal/provenance"
+	"slugbot/internal/quota"
+	"slugbot/internal/ratings"
+	"slugbot/internal/results"
+	"slugbot/internal/telemetry"
+	"slugbot/internal/tools"
+	"slugbot/internal/triage"
 
-	"github.com/BurntSushi/toml"
 	"github.com/bwmarrin/discordgo"
 )
 
-func normalizeTOML(s string) string {
-	return unicodeQuoteReplacer.Replace(s)
-}
-
-var unicodeQuoteReplacer = strings.NewReplacer(
-	// curved quotes
-	"\u201C", `"`, "\u201D", `"`,
-	"\u2018", `'`, "\u2019", `'`,
-	// angle
-	"\u00AB", `"`, "\u00BB", `"`,
-	"\u2039", `'`, "\u203A", `'`,
-	// low-9 / other
-	"\u201A", `"`, "\u201E", `"`, "\u201B", `'`,
-	// fullwidth
-	"\uFF02", `"`, "\uFF07", `'`,
-	// fancy primes
-	"\u2032", `"`, "\u2033", `"`,
-	// BOM / zero-width
-	"\uFEFF", "", "\u200B", "",
-	// NBSP → space
-	"\u00A0", " ",
-)
-
 type StableAudioWithConfigCommand struct {
 	commands.Command
 	traits.Promptable
+	traits.Progressable
+	traits.Interruptible
+	Estimator *eta.Estimator      // optional; used to show how long generation usually takes
+	Quota     *quota.Tracker      // optional; charges downloaded and generated files to the requesting user
+	Models    *backend.Models     // optional; selects the checkpoint for full-size generations
+	Labels    *provenance.Labeler // optional; marks results as AI-generated
+	Results   *results.Formatter  // optional; the text results are posted with
+	Archive   *archive.Archiver   // optional; mirrors results into their guild's archive channel
+	Votes     *ratings.Store      // optional; tracks the 👍 and 👎 votes on results for `.stop10`
+
+	output string // the generated file, once there is one
 }
 
-type StableAudioWithConfigParams struct {
-	Prompts         map[string]float64 `toml:"prompts"`
-	NegativePrompts map[string]float64 `toml:"neg_prompts"`
-}
-
-func (c *StableAudioWithConfigCommand) makeFilename(params *StableAudioWithConfigParams, timestamp int64) string {
+func (c *StableAudioWithConfigCommand) makeFilename(params *promptspec.Block, timestamp int64) string {
 	combinedStr := ""
 	for prompt, weight := range params.Prompts {
 		combinedStr += fmt.Sprintf("%s %0.2f ", prompt, weight)
@@ -63,7 +61,7 @@ func (c *StableAudioWithConfigCommand) makeFilename(params *StableAudioWithConfi
 		combinedStr += fmt.Sprintf("%s %0.2f", prompt, weight)
 	}
 	baseString := whitespaceRegex.ReplaceAllString(combinedStr, "-")
-	baseString = forwardSlashRegex.ReplaceAllString(baseString, "")
+	baseString = unsafeFilenameRegex.ReplaceAllString(baseString, "")
 
 	return fmt.Sprintf("saudio-%s-%d.wav", baseString, timestamp)
 }
@@ -103,28 +101,59 @@ func (c *StableAudioWithConfigCommand) Validate() error {
 	return nil
 }
 
-func ParseTOML(content string) (*StableAudioWithConfigParams, error) {
-	params := StableAudioWithConfigParams{
-		Prompts:         map[string]float64{},
-		NegativePrompts: map[string]float64{},
+// tomlContent returns the normalized TOML between the opening and closing fences.
+func (cmd *StableAudioWithConfigCommand) tomlContent() string {
+	return promptspec.NormalizeTOML(cmd.Message.Content[9 : len(cmd.Message.Content)-3])
+}
+
+// Edit points a queued command at the edited text of its triggering message,
+// leaving it unchanged if the new text isn't a valid TOML block.
+func (cmd *StableAudioWithConfigCommand) Edit(content string) error {
+	edited := &StableAudioWithConfigCommand{}
+	edited.SetContext(cmd.Session, commands.EditedMessage(cmd.Message, content))
+	if err := edited.Validate(); err != nil {
+		return err
 	}
-	if _, err := toml.Decode(content, &params); err != nil {
-		return nil, err
+	if _, err := promptspec.ParseTOML(edited.tomlContent()); err != nil {
+		return commands.UserErrorf("couldn't parse the config block: %v", err)
 	}
-	return &params, nil
+
+	cmd.SetContext(cmd.Session, edited.Message)
+	cmd.Log().Info("edited queued config")
+	return nil
+}
+
+// Shape reports the model, steps, and length this command will generate with.
+func (cmd *StableAudioWithConfigCommand) Shape() (eta.Shape, bool) {
+	if cmd.Validate() != nil {
+		return eta.Shape{}, false
+	}
+	params, err := promptspec.ParseTOML(cmd.tomlContent())
+	if err != nil {
+		return eta.Shape{}, false
+	}
+	return shapeOf(params.Config.Small, params.Config.Steps, params.Config.Length), true
 }
 
 func (cmd *StableAudioWithConfigCommand) Apply() error {
+	log := cmd.Log()
+	ctx := cmd.TraceContext()
+
 	if err := cmd.Validate(); err != nil {
 		return err
 	}
 
-	content := cmd.Message.Content[9 : len(cmd.Message.Content)-3]
+	// typing shows activity until the progress message does, or throughout
+	// for quiet users, who don't get one
+	stopTyping := discord.Typing(discord.ConcreteSession{Session: cmd.Session}, cmd.Message.ChannelID)
+	defer stopTyping()
 
-	content = normalizeTOML(content)
-	params, err := ParseTOML(content)
+	content := cmd.tomlContent()
+	_, parseSpan := telemetry.Start(ctx, "parse")
+	params, err := promptspec.ParseTOML(content)
+	telemetry.End(parseSpan, err)
 	if err != nil {
-		return fmt.Errorf("failed to parse toml: %w", err)
+		return commands.UserErrorf("couldn't parse the config block: %v", err)
 	}
 
 	triggeringMessage := &discordgo.MessageReference{
@@ -134,91 +163,93 @@ func (cmd *StableAudioWithConfigCommand) Apply() error {
 
 	toml := content
 
-	fp, err := discord.NewFilePollMessage(
-		discord.ConcreteSession{Session: cmd.Session},
-		triggeringMessage.ChannelID,
-		triggeringMessage.MessageID,
-		1*time.Second,
-	)
+	fp, err := newProgressMessage(cmd.Session, triggeringMessage.ChannelID, triggeringMessage.MessageID)
 	if err != nil {
 		return fmt.Errorf("failed to init progress poller: %w", err)
 	}
+	fp.Footer = commands.TraceFooter(cmd.TraceID())
+	fp.OnUpdate = cmd.SetProgress
+	fp.Quiet = prefs.FromContext(ctx).Quiet
 
 	timestamp := time.Now().Unix()
 	outFile := cmd.makeFilename(params, timestamp)
 
 	initMsgString := fmt.Sprintf("Generating audio for file %s...", outFile)
-	slog.Info(initMsgString)
+	log.Info(initMsgString)
+	initMsgString += estimateLine(cmd.Estimator, shapeOf(params.Config.Small, params.Config.Steps, params.Config.Length))
 	if err := fp.Start(initMsgString); err != nil {
 		return fmt.Errorf("failed to start progress poller: %w", err)
 	}
+	if !fp.Quiet {
+		stopTyping()
+	}
 	defer fp.Stop()
 
 	progressFile := fp.FilePath
-	slog.Info("Using progressFile: ", fp.FilePath)
+	log.Info("Using progressFile: ", fp.FilePath)
 
-	// if an uploaded wav is attached, use it as the input audio
+	// use an attached wav as the input audio, or else one attached to the
+	// message being replied to
 	var initAudioPath string
-	for _, att := range cmd.Message.Attachments {
-		if strings.HasSuffix(att.Filename, ".wav") {
-			initAudioPath, err = downloadAndSave(att.URL)
-			if err != nil {
-				slog.Error("failed to download init audio: %v", err)
-				return fmt.Errorf("failed to download audio input")
-			}
-
-			slog.Trace("Downloaded data into file: ", initAudioPath)
-			break
-		}
-	}
-
-	// if no input audio so far, and the message replies to a message with attached
-	// audio, then use that as the input audio
-	if initAudioPath == "" && cmd.Message.MessageReference != nil {
-		refMsg, err := cmd.Session.ChannelMessage(
-			cmd.Message.ChannelID,
-			cmd.Message.MessageReference.MessageID,
-		)
+	if initAudioURL, err := findInitAudio(cmd.Session, cmd.Message, cmd.Input); err != nil {
+		return err
+	} else if initAudioURL != "" {
+		fp.SetPhase(discord.PhaseDownloading)
+		initAudioPath, err = downloadAndSave(initAudioURL, cmd.Message)
 		if err != nil {
-			slog.Warn("could not fetch referenced message: ", err)
-		} else {
-			for _, att := range refMsg.Attachments {
-				if strings.HasSuffix(att.Filename, ".wav") {
-					initAudioPath, err = downloadAndSave(att.URL)
-					if err != nil {
-						slog.Error("failed to download init audio: %v", err)
-						return fmt.Errorf("failed to download audio input from reply")
-					}
-					break
-				}
-			}
+			log.Error("failed to download init audio: ", err)
+			return err
 		}
+		log.Trace("Downloaded data into file: ", initAudioPath)
 	}
 
-	cmdArgs := []string{
-		"--toml",
-		"--progress_file", progressFile,
-		"--output", outFile,
-	}
+	// the input is only needed while generating; the janitor removes it later
+	defer cmd.Quota.Track(cmd.Message.Author.ID, initAudioPath)()
+
+	sag := cmdline.New("--toml").
+		Option("--progress_file", progressFile).
+		Option("--output", outFile)
 	if initAudioPath != "" {
-		slog.Info("Using input audio file: ", initAudioPath)
-		cmdArgs = append(cmdArgs, "--init_audio", initAudioPath)
+		log.Info("Using input audio file: ", initAudioPath)
+		sag.Option("--init_audio", initAudioPath)
 	} else {
-		slog.Info("No input audio detected; proceeding with text only")
+		log.Info("No input audio detected; proceeding with text only")
 	}
 
+	// sag ignores the model dir for [config] small = true
+	cmdArgs := sag.Trusted(cmd.Models.Args()...).Args()
+
 	// 4) Invoke sag, piping TOML to stdin
-	command := exec.Command("./stable-audio/sag", cmdArgs...)
+	runCtx, release := cmd.WithInterrupt(ctx)
+	defer release()
+	command := exec.CommandContext(runCtx, tools.Path(tools.Sag), cmdArgs...)
 	command.Stdin = strings.NewReader(toml)
 	command.Stdout = os.Stdout
-	command.Stderr = os.Stderr
+	stderr := triage.NewTail(stderrTail)
+	command.Stderr = io.MultiWriter(os.Stderr, stderr)
+
+	fp.SetPhase(discord.PhaseDiffusing)
+
+	// an operator may have injected failures, to see how they're handled
+	fault := chaos.Claim(cmd.Message.GuildID)
+	if fault != 0 {
+		log.Warn("injecting failures: ", fault)
+	}
 
-	if err := command.Run(); err != nil {
+	started := time.Now()
+	_, runSpan := telemetry.Start(ctx, "subprocess.run")
+	err = cmd.InterruptCause(runCtx, stderr.Wrap(fault.Run(runCtx, command.Run)))
+	telemetry.End(runSpan, err)
+	if err != nil {
 		err = fmt.Errorf("error during audio generation: %w", err)
 		if stopErr := fp.Stop(); stopErr != nil {
 			err = fmt.Errorf("%w; during handling, another error occurred: %w", err, stopErr)
 		}
-		slog.Error(err.Error())
+		log.Error(err.Error())
+		if cmd.Retrying() {
+			// the queue runs it again, so there's nothing to tell the user yet
+			return err
+		}
 
 		errorMessage, createMessageErr := discord.NewMessage(discord.ConcreteSession{Session: cmd.Session}, cmd.Message.ChannelID)
 		if createMessageErr != nil {
@@ -226,33 +257,107 @@ func (cmd *StableAudioWithConfigCommand) Apply() error {
 			return err
 		}
 
-		if sendMessageErr := errorMessage.Create(err.Error()); sendMessageErr != nil {
+		if sendMessageErr := errorMessage.Create(commands.FailureText(cmd.Session, err, cmd.TraceID()) + commands.TraceFooter(cmd.TraceID())); sendMessageErr != nil {
 			err = fmt.Errorf("%w; when sending the error message to discord, another error occurred: %w", err, sendMessageErr)
 		}
 
 		return err
 	}
+	elapsed := time.Since(started)
+	fp.SetPhase(discord.PhasePostProcessing)
+	if keepsProgress(false, cmd.Message) {
+		if err := fp.Finish(progressSummary(elapsed, params.Config.Seed)); err != nil {
+			log.Warn("couldn't leave progress summary: ", err)
+		}
+	}
+
+	if err := cmd.Labels.Watermark(ctx, cmd.Message.GuildID, outFile, cmd.TraceID()); err != nil {
+		log.Warn("couldn't watermark output: ", err)
+	}
+	cmd.output = outFile
+
+	// the output counts against the user until it's been delivered
+	releaseOutput := cmd.Quota.Track(cmd.Message.Author.ID, outFile)
 
-	// Send the resulting audio file back to the Discord channel
-	file, err := os.Open(outFile)
+	// Send the resulting audio file back to the Discord channel, or a clip of
+	// it if it's too big
+	upload, uploadNote, removeClip, err := uploadable(cmd.Message.GuildID, outFile)
 	if err != nil {
-		cmd.Session.ChannelMessageSendReply(cmd.Message.ChannelID, "Failed to open output file: "+err.Error(), triggeringMessage)
+		cmd.Session.ChannelMessageSendReply(cmd.Message.ChannelID, "Failed to prepare output file: "+commands.FailureText(cmd.Session, err, cmd.TraceID()), triggeringMessage)
+		return err
+	}
+	defer removeClip()
+	file, err := os.Open(upload)
+	if err != nil {
+		cmd.Session.ChannelMessageSendReply(cmd.Message.ChannelID, "Failed to open output file: "+commands.FailureText(cmd.Session, err, cmd.TraceID()), triggeringMessage)
 		return err
 	}
 	defer file.Close()
 
+	model := modelName(params.Config.Small, cmdArgs)
+	fields := results.Fields{
+		Prompt:    params.Describe(),
+		Seed:      params.Config.Seed,
+		Duration:  format.Duration(elapsed),
+		Model:     model,
+		Submitter: cmd.Message.Author.Mention(),
+	}
 	finalMessage := &discordgo.MessageSend{
 		Files: []*discordgo.File{{
-			Name:   outFile,
+			Name:   upload,
 			Reader: file,
 		}},
+		Content: joinLines(
+			cmd.Results.Content(cmd.Message.GuildID, fields),
+			cmd.Labels.Footer(cmd.Message.GuildID, model),
+			uploadNote,
+		),
 		Reference: triggeringMessage,
+		// the template can show the prompt, which shouldn't ping anyone
+		AllowedMentions: &discordgo.MessageAllowedMentions{RepliedUser: true},
 	}
 
-	if _, err := cmd.Session.ChannelMessageSendComplex(cmd.Message.ChannelID, finalMessage); err != nil {
-		cmd.Session.ChannelMessageSend(cmd.Message.ChannelID, "Failed to send file: "+err.Error())
+	fp.SetPhase(discord.PhaseUploading)
+	_, uploadSpan := telemetry.Start(ctx, "upload")
+	posted, err := sendResult(fault.Sender(cmd.Session), cmd.Message.GuildID, cmd.Message.ChannelID, finalMessage, upload)
+	telemetry.End(uploadSpan, err)
+	if errors.Is(err, discord.ErrUndelivered) {
+		// the user has a button to retry it, so the job itself is done
+		cmd.Log().Warn("couldn't deliver output: ", err)
+		releaseOutput()
+		return nil
+	}
+	if err != nil {
+		cmd.Session.ChannelMessageSend(cmd.Message.ChannelID, "Failed to send file: "+commands.FailureText(cmd.Session, err, cmd.TraceID()))
 		return err
 	}
+	releaseOutput()
+
+	// a result DMed to its submitter for approval is archived, and voted on,
+	// once it's published
+	if posted.ChannelID == cmd.Message.ChannelID {
+		if err := cmd.Archive.Mirror(cmd.Session, cmd.Message.GuildID, posted, fields); err != nil {
+			cmd.Log().Warn("couldn't archive output: ", err)
+		}
+		if err := cmd.Votes.Add(ratings.Result{
+			GuildID:   cmd.Message.GuildID,
+			ChannelID: posted.ChannelID,
+			MessageID: posted.ID,
+			UserID:    cmd.Message.Author.ID,
+			Prompt:    params.Describe(),
+			Posted:    time.Now(),
+		}); err != nil {
+			cmd.Log().Warn("couldn't track votes on output: ", err)
+		}
+	}
 
 	return nil
 }
+
+// Outputs returns the generated file, once generation has finished.
+func (cmd *StableAudioWithConfigCommand) Outputs() []string {
+	if cmd.output == "" {
+		return nil
+	}
+	return []string{cmd.output}
+}
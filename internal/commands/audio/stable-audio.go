@@ -4,18 +4,35 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"regexp"
-	"strconv"
+	"slices"
 	"strings"
 	"time"
 
+	"slugbot/internal/archive"
+	"slugbot/internal/backend"
+	"slugbot/internal/chaos"
+	"slugbot/internal/cmdline"
 	"slugbot/internal/commands"
 	"slugbot/internal/commands/traits"
+	"slugbot/internal/config"
 	"slugbot/internal/discord"
+	"slugbot/internal/eta"
+	"slugbot/internal/format"
+	"slugbot/internal/helpers"
 	"slugbot/internal/io/slog"
+	"slugbot/internal/llm"
+	"slugbot/internal/prefs"
+	"slugbot/internal/promptspec"
+	"slugbot/internal/provenance"
+	"slugbot/internal/quota"
+	"slugbot/internal/ratings"
+	"slugbot/internal/results"
+	"slugbot/internal/telemetry"
+	"slugbot/internal/tools"
+	"slugbot/internal/triage"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -23,20 +40,24 @@ import (
 type StableAudioCommand struct {
 	commands.Command
 	traits.Promptable
-}
-
-type StableAudioParams struct {
-	Length         float64
-	Strength       float64
-	Prompt         string
-	NegativePrompt string
-	Seed           int64
-	Steps          int64
-	IsSmall        bool
+	traits.Progressable
+	traits.Interruptible
+	Estimator *eta.Estimator      // optional; used to show how long generation usually takes
+	Quota     *quota.Tracker      // optional; charges downloaded and generated files to the requesting user
+	Models    *backend.Models     // optional; selects the checkpoint for full-size generations
+	LLM       *llm.Client         // optional; required for --enhance
+	Labels    *provenance.Labeler // optional; marks results as AI-generated
+	Results   *results.Formatter  // optional; the text results are posted with
+	Archive   *archive.Archiver   // optional; mirrors results into their guild's archive channel
+	Votes     *ratings.Store      // optional; tracks the 👍 and 👎 votes on results for `.stop10`
+
+	output string // the generated file, once there is one
 }
 
 var whitespaceRegex = regexp.MustCompile(`\s+`)
-var forwardSlashRegex = regexp.MustCompile(`/`)
+
+// unsafeFilenameRegex matches what can't go in a file name on Windows, macOS, or Linux.
+var unsafeFilenameRegex = regexp.MustCompile(`[/\\:*?"<>|\x00-\x1f]`)
 
 // SetContext captures Discord context and extracts the prompt text.
 func (c *StableAudioCommand) SetContext(s *discordgo.Session, m *discordgo.MessageCreate) {
@@ -71,107 +92,13 @@ func (c *StableAudioCommand) Validate() error {
 	return nil
 }
 
-func ParseArgs(args []string) (*StableAudioParams, error) {
-	params := &StableAudioParams{
-		Length:         30.0,
-		Strength:       7.0,
-		Prompt:         "",
-		NegativePrompt: "",
-		Seed:           -1,
-		Steps:          100,
-		IsSmall:        false,
-	}
-
-	// parse params; TODO: make this more general/abstracted
-	i := 0
-	prompt := []string{}
-	negativePrompt := []string{}
-	collectNegative := false
-	stepsSet := false
-	for i < len(args) {
-		switch args[i] {
-		case "--length":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for --length")
-			}
-			length, err := strconv.ParseFloat(args[i+1], 64)
-			if err != nil || length <= 0.0 {
-				return nil, fmt.Errorf("invalid length: %v", args[i+1])
-			}
-			params.Length = length
-			i += 2
-
-		case "--strength":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for --strength")
-			}
-			strength, err := strconv.ParseFloat(args[i+1], 64)
-			if err != nil {
-				return nil, fmt.Errorf("invalid strength: %v", args[i+1])
-			}
-			params.Strength = strength
-			i += 2
-
-		case "--seed":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for --seed")
-			}
-			seed, err := strconv.ParseInt(args[i+1], 10, 64)
-			if err != nil || seed < 0 {
-				return nil, fmt.Errorf("invalid seed '%q' (needs to be a positive integer): %w", params.Seed, err)
-			}
-			params.Seed = seed
-			i += 2
-
-		case "--steps":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for --steps")
-			}
-			steps, err := strconv.ParseInt(args[i+1], 10, 64)
-			if err != nil || steps < 0 {
-				return nil, fmt.Errorf("invalid steps '%q' (needs to be >0): %w", params.Steps, err)
-			}
-			params.Steps = steps
-			i += 2
-			stepsSet = true
-
-		case "--negative":
-			collectNegative = true
-			i++
-
-		case "--small":
-			params.IsSmall = true
-			i++
-
-		default:
-			if !collectNegative {
-				prompt = append(prompt, args[i])
-			} else {
-				negativePrompt = append(negativePrompt, args[i])
-			}
-			i++
-		}
-	}
-
-	if !stepsSet && params.IsSmall {
-		params.Steps = 8
-	}
-
-	params.Prompt = strings.Join(prompt, " ")
-	params.NegativePrompt = strings.Join(negativePrompt, " ")
-
-	slog.Info("Got prompt:          ", params.Prompt)
-	slog.Info("Got negative prompt: ", params.NegativePrompt)
-	slog.Info("    strength:        ", params.Strength)
-	slog.Info("    length:          ", params.Length)
-	slog.Info("    seed:            ", params.Seed)
-	slog.Info("    steps:           ", params.Steps)
-	slog.Info("    small?           ", params.IsSmall)
-
-	if params.Prompt == "" {
-		return nil, fmt.Errorf("prompt is empty")
+// parseArgs parses a generation's prompt and flags. What's wrong with them is
+// for the user to fix, so it's told to them as it is.
+func parseArgs(args []string) (*promptspec.Args, error) {
+	params, err := promptspec.ParseArgs(args)
+	if err != nil {
+		return nil, commands.UserErrorf("%v", err)
 	}
-
 	return params, nil
 }
 
@@ -183,7 +110,15 @@ func truncate(s string, max int) string {
 	return s
 }
 
-func makeFilename(params *StableAudioParams, timestamp int64) string {
+// stderrTail is how much of a generation's stderr is kept to explain its failure.
+const stderrTail = 8 << 10
+
+// joinLines joins the non-empty lines of a message.
+func joinLines(lines ...string) string {
+	return strings.Join(slices.DeleteFunc(lines, func(line string) bool { return line == "" }), "\n")
+}
+
+func makeFilename(params *promptspec.Args, timestamp int64) string {
 	combinedStr := ""
 	if params.Prompt != "" {
 		combinedStr += truncate(params.Prompt, 100)
@@ -195,153 +130,363 @@ func makeFilename(params *StableAudioParams, timestamp int64) string {
 		combinedStr += truncate(params.NegativePrompt, 100)
 	}
 	baseString := whitespaceRegex.ReplaceAllString(combinedStr, "-")
-	baseString = forwardSlashRegex.ReplaceAllString(baseString, "")
+	baseString = unsafeFilenameRegex.ReplaceAllString(baseString, "")
 
 	return fmt.Sprintf("saudio-%s-%d.wav", baseString, timestamp)
 }
 
-func downloadAndSave(url string) (string, error) {
+// sagArgs builds the sag command line for a prompt-based generation. Every
+// value is joined to its option, so a prompt that starts with a dash is still
+// read as the prompt.
+func sagArgs(params *promptspec.Args, outFile string, progressFile string, initAudioPath string) []string {
+	args := cmdline.New().
+		Option("--prompt", params.Prompt).
+		Option("--negative_prompt", params.NegativePrompt).
+		Option("--output", outFile).
+		Option("--progress_file", progressFile).
+		Option("--cfg_scale", fmt.Sprintf("%0.2f", params.Strength)).
+		Option("--length", fmt.Sprintf("%0.2f", params.Length)).
+		Option("--seed", fmt.Sprintf("%d", params.Seed)).
+		Option("--steps", fmt.Sprintf("%d", params.Steps))
+	if initAudioPath != "" {
+		args.Option("--init_audio", initAudioPath)
+	}
+	if params.IsSmall {
+		args.Trusted("--small")
+	}
+	return args.Args()
+}
+
+// findInitAudio picks the input wav for a generation from the triggering
+// message's attachments, or else from the message it replies to (see
+// helpers.ResolveInput). It returns "" if neither has a wav.
+func findInitAudio(session *discordgo.Session, message *discordgo.MessageCreate, selector string) (string, error) {
+	wav, err := helpers.ResolveInput(session, message, helpers.MessageAudio, selector)
+	if errors.Is(err, helpers.ErrNoInput) {
+		if selector != "" {
+			return "", commands.UserErrorf("`--input %s` was given, but no wav attachments were found", selector)
+		}
+		return "", nil
+	}
+	if errors.Is(err, helpers.ErrNoReply) {
+		return "", err
+	}
+	if err != nil {
+		// the selector didn't pick one of the wavs
+		return "", &commands.UserError{Message: err.Error()}
+	}
+	return wav.URL, nil
+}
+
+// downloadInitAudio downloads the input audio, if there is any, calling
+// downloading first.
+func (cmd *StableAudioCommand) downloadInitAudio(selector string, downloading func()) (string, error) {
+	url, err := findInitAudio(cmd.Session, cmd.Message, selector)
+	if err != nil || url == "" {
+		return "", err
+	}
+	downloading()
+	path, err := downloadAndSave(url, cmd.Message)
+	if err != nil {
+		return "", err
+	}
+	cmd.Log().Trace("Downloaded data into file: ", path)
+	return path, nil
+}
+
+// downloadAndSave downloads the input audio at url, which message sent, and
+// checks it with the input scanner.
+func downloadAndSave(url string, message *discordgo.MessageCreate) (string, error) {
 	slog.Trace("Trying to download audio from: ", url)
 
-	resp, err := http.Get(url)
+	path, err := helpers.DownloadFile(url, "saudio-init-*.wav")
 	if err != nil {
 		slog.Error("failed to download init audio:", err)
-		return "", fmt.Errorf("failed to download audio input")
+		return "", commands.NewUserError("couldn't download the input audio; check that its link still works", err)
 	}
-	defer resp.Body.Close()
 
-	tmpf, err := os.CreateTemp("", "saudio-init-*.wav")
+	if err := helpers.ScanInput(path, message); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	slog.Trace("Created temporary file for input: ", path)
+	return path, nil
+}
+
+// messageArgs returns the arguments after the command word, adding --small for `.saudiosm`.
+func (cmd *StableAudioCommand) messageArgs() []string {
+	content := strings.TrimSpace(cmd.Message.Content)
+	parts := strings.Split(content, " ")
+	if string(parts[0]) == ".saudiosm" {
+		parts = append(parts, "--small")
+	}
+	return parts[1:]
+}
+
+// Edit points a queued command at the edited text of its triggering message,
+// leaving it unchanged if the new text isn't a valid `.saudio` command.
+func (cmd *StableAudioCommand) Edit(content string) error {
+	edited := &StableAudioCommand{}
+	edited.SetContext(cmd.Session, commands.EditedMessage(cmd.Message, content))
+	if err := edited.Validate(); err != nil {
+		return err
+	}
+	if top := strings.Fields(content)[0]; top != ".saudio" && top != ".saudiosm" {
+		return fmt.Errorf("can't change a queued job into `%s`", top)
+	}
+	if _, err := parseArgs(edited.messageArgs()); err != nil {
+		return err
+	}
+
+	cmd.SetContext(cmd.Session, edited.Message)
+	cmd.Log().Info("edited queued prompt: ", cmd.Prompt())
+	return nil
+}
+
+// Shape reports the model, steps, and length this command will generate with.
+func (cmd *StableAudioCommand) Shape() (eta.Shape, bool) {
+	if cmd.Message == nil {
+		return eta.Shape{}, false
+	}
+	params, err := parseArgs(cmd.messageArgs())
+	if err != nil {
+		return eta.Shape{}, false
+	}
+	return shapeOf(params.IsSmall, params.Steps, params.Length), true
+}
+
+// Summary describes what this command will generate, as its flags resolve, on
+// one line, e.g. "`rainy jazz`, without `drums` · 100 steps · 30s · random
+// seed · Stable Audio Open 1.0". It's shown before a long job is queued, so a
+// mistyped flag is caught before it costs anything.
+func (cmd *StableAudioCommand) Summary() (string, error) {
+	params, err := parseArgs(cmd.messageArgs())
 	if err != nil {
-		slog.Error("failed to create temp file:", err)
-		return "", fmt.Errorf("failed to download audio input")
+		return "", err
 	}
-	defer tmpf.Close()
+	return paramsSummary(params, modelName(params.IsSmall, cmd.Models.Args())), nil
+}
 
-	slog.Trace("Created temporary file for input: ", tmpf.Name())
+func paramsSummary(params *promptspec.Args, model string) string {
+	prompt := fmt.Sprintf("`%s`", params.Prompt)
+	if params.NegativePrompt != "" {
+		prompt += fmt.Sprintf(", without `%s`", params.NegativePrompt)
+	}
+	seed := "random seed"
+	if params.Seed >= 0 {
+		seed = fmt.Sprintf("seed %d", params.Seed)
+	}
+	return strings.Join([]string{
+		prompt,
+		fmt.Sprintf("%d steps", params.Steps),
+		fmt.Sprintf("%gs", params.Length),
+		seed,
+		model,
+	}, " · ")
+}
 
-	if _, err := io.Copy(tmpf, resp.Body); err != nil {
-		slog.Error("failed to save init audio:", err)
-		return "", fmt.Errorf("failed to download audio input")
+func shapeOf(isSmall bool, steps int64, length float64) eta.Shape {
+	model := "full"
+	if isSmall {
+		model = "small"
 	}
-	return tmpf.Name(), nil
+	return eta.Shape{Model: model, Steps: steps, Length: length}
+}
+
+// enhancePrompt replaces the prompt with an LLM-expanded version.
+func (cmd *StableAudioCommand) enhancePrompt(params *promptspec.Args) error {
+	if cmd.LLM == nil {
+		return commands.UserErrorf("--enhance isn't available on this bot (no LLM endpoint is configured)")
+	}
+
+	ctx, span := telemetry.Start(cmd.TraceContext(), "enhance")
+	enhanced, err := cmd.LLM.EnhancePrompt(ctx, params.Prompt)
+	telemetry.End(span, err)
+	if err != nil {
+		return commands.NewUserError("couldn't enhance the prompt; try again, or without --enhance", err)
+	}
+
+	cmd.Log().Info("Enhanced prompt:     ", enhanced)
+	params.Prompt = enhanced
+	return nil
+}
+
+// estimateLine formats the expected runtime for a progress message, or "" without history.
+func estimateLine(estimator *eta.Estimator, shape eta.Shape) string {
+	estimate, ok := estimator.Estimate(shape)
+	if !ok {
+		return ""
+	}
+	return "\r\nusually takes ~" + format.Duration(estimate)
+}
+
+// keepsProgress reports whether a job's progress message is left as a summary
+// instead of being deleted: when the job asked for it, or when the config keeps
+// progress messages for the job's guild or user.
+func keepsProgress(requested bool, message *discordgo.MessageCreate) bool {
+	cfg := config.Get().Progress
+	if requested || cfg.Keep {
+		return true
+	}
+	if slices.Contains(cfg.Guilds, message.GuildID) && message.GuildID != "" {
+		return true
+	}
+	return message.Author != nil && slices.Contains(cfg.Users, message.Author.ID)
+}
+
+// newProgressMessage makes a job's progress message, replying to replyTo. It's
+// updated as configured: quickly while the job changes phase or is nearly
+// done, and less often while it's only counting steps, and tells the watchdog
+// once it stops changing.
+func newProgressMessage(session *discordgo.Session, channelID string, replyTo string) (*discord.FilePollMessage, error) {
+	cfg := config.Get().Progress
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	fp, err := discord.NewFilePollMessage(discord.ConcreteSession{Session: session}, channelID, replyTo, interval)
+	if err != nil {
+		return nil, err
+	}
+	fp.Render = discord.RenderProgress
+	fp.PolledFile.MaxInterval = cfg.MaxInterval
+	fp.PolledFile.Hurry = discord.HurryProgress
+	watchdog.Watch(fp.PolledFile)
+	return fp, nil
+}
+
+// progressSummary is what a kept progress message is edited into, e.g.
+// "generated in 3m 12s, seed 1234". Random seeds aren't known, so they're left out.
+func progressSummary(elapsed time.Duration, seed int64) string {
+	summary := "generated in " + format.Duration(elapsed)
+	if seed >= 0 {
+		summary += fmt.Sprintf(", seed %d", seed)
+	}
+	return summary
 }
 
 func (cmd *StableAudioCommand) Apply() error {
+	log := cmd.Log()
+	ctx := cmd.TraceContext()
+
 	if err := cmd.Validate(); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
+	// enhancing the prompt can take a while; typing shows activity until the
+	// progress message does, or throughout for quiet users, who don't get one
+	stopTyping := discord.Typing(discord.ConcreteSession{Session: cmd.Session}, cmd.Message.ChannelID)
+	defer stopTyping()
+
 	triggeringMessage := &discordgo.MessageReference{
 		MessageID: cmd.Message.ID,
 		ChannelID: cmd.Message.ChannelID,
 	}
 
-	content := strings.TrimSpace(cmd.Message.Content)
-	parts := strings.Split(content, " ")
-	if string(parts[0]) == ".saudiosm" {
-		parts = append(parts, "--small")
-	}
-	if len(parts) < 2 {
+	args := cmd.messageArgs()
+	if len(args) < 1 {
 		cmd.Session.ChannelMessageSendReply(cmd.Message.ChannelID, "Usage: .saudio <prompt>", triggeringMessage)
 		return nil
 	}
-	params, err := ParseArgs(parts[1:])
+	_, parseSpan := telemetry.Start(ctx, "parse")
+	params, err := parseArgs(args)
+	telemetry.End(parseSpan, err)
 	if err != nil {
-		slog.Error("failed to parse args: %v", err)
+		log.Error("failed to parse args: ", err)
 		return err
 	}
 
+	log.Info("Got prompt:          ", params.Prompt)
+	log.Info("Got negative prompt: ", params.NegativePrompt)
+	log.Info("    strength:        ", params.Strength)
+	log.Info("    length:          ", params.Length)
+	log.Info("    seed:            ", params.Seed)
+	log.Info("    steps:           ", params.Steps)
+	log.Info("    small?           ", params.IsSmall)
+
 	timestamp := time.Now().Unix()
 	outFile := makeFilename(params, timestamp)
 
-	fp, err := discord.NewFilePollMessage(
-		discord.ConcreteSession{Session: cmd.Session},
-		cmd.Message.ChannelID,
-		triggeringMessage.MessageID,
-		1*time.Second,
-	)
+	originalPrompt := params.Prompt
+	if params.Enhance {
+		if err := cmd.enhancePrompt(params); err != nil {
+			return err
+		}
+	}
+
+	fp, err := newProgressMessage(cmd.Session, cmd.Message.ChannelID, triggeringMessage.MessageID)
 	if err != nil {
 		return fmt.Errorf("failed to init progress poller: %w", err)
 	}
+	fp.Footer = commands.TraceFooter(cmd.TraceID())
+	fp.OnUpdate = cmd.SetProgress
+	fp.Quiet = prefs.FromContext(ctx).Quiet
 
 	initMsgString := fmt.Sprintf("Generating audio for prompt: `%s`...\r\nnegative prompt: `%s`", params.Prompt, params.NegativePrompt)
+	initMsgString += estimateLine(cmd.Estimator, shapeOf(params.IsSmall, params.Steps, params.Length))
 	if err := fp.Start(initMsgString); err != nil {
 		return fmt.Errorf("failed to start progress poller: %w", err)
 	}
 	defer fp.Stop()
+	if !fp.Quiet {
+		stopTyping()
+	}
 
 	progressFile := fp.FilePath
 
-	// if an uploaded wav is attached, use it as the input audio
-	var initAudioPath string
-	for _, att := range cmd.Message.Attachments {
-		if strings.HasSuffix(att.Filename, ".wav") {
-			initAudioPath, err = downloadAndSave(att.URL)
-			if err != nil {
-				slog.Error("failed to download init audio: %v", err)
-				return fmt.Errorf("failed to download audio input")
-			}
-
-			slog.Trace("Downloaded data into file: ", initAudioPath)
-			break
-		}
+	// use an attached wav as the input audio, or else one attached to the
+	// message being replied to
+	initAudioPath, err := cmd.downloadInitAudio(params.Input, func() { fp.SetPhase(discord.PhaseDownloading) })
+	if err != nil {
+		log.Error("failed to download init audio: ", err)
+		return err
 	}
 
-	// if no input audio so far, and the message replies to a message with attached
-	// audio, then use that as the input audio
-	if initAudioPath == "" && cmd.Message.MessageReference != nil {
-		refMsg, err := cmd.Session.ChannelMessage(
-			cmd.Message.ChannelID,
-			cmd.Message.MessageReference.MessageID,
-		)
-		if err != nil {
-			slog.Warn("could not fetch referenced message: ", err)
-		} else {
-			for _, att := range refMsg.Attachments {
-				if strings.HasSuffix(att.Filename, ".wav") {
-					initAudioPath, err = downloadAndSave(att.URL)
-					if err != nil {
-						slog.Error("failed to download init audio: %v", err)
-						return fmt.Errorf("failed to download audio input from reply")
-					}
-					break
-				}
-			}
-		}
-	}
+	// the input is only needed while generating; the janitor removes it later
+	defer cmd.Quota.Track(cmd.Message.Author.ID, initAudioPath)()
 
-	cmdArgs := []string{
-		"--prompt", params.Prompt,
-		"--negative_prompt", params.NegativePrompt,
-		"--output", outFile,
-		"--progress_file", progressFile,
-		"--cfg_scale", fmt.Sprintf("%0.2f", params.Strength),
-		"--length", fmt.Sprintf("%0.2f", params.Length),
-		"--seed", fmt.Sprintf("%d", params.Seed),
-		"--steps", fmt.Sprintf("%d", params.Steps),
-	}
+	cmdArgs := sagArgs(params, outFile, progressFile, initAudioPath)
 	if initAudioPath != "" {
-		slog.Info("Using input audio file: ", initAudioPath)
-		cmdArgs = append(cmdArgs, "--init_audio", initAudioPath)
+		log.Info("Using input audio file: ", initAudioPath)
 	} else {
-		slog.Info("No input audio detected; proceeding with text only")
+		log.Info("No input audio detected; proceeding with text only")
 	}
 	if params.IsSmall {
-		slog.Info("Using small model")
-		cmdArgs = append(cmdArgs, "--small")
+		log.Info("Using small model")
+	} else {
+		cmdArgs = append(cmdArgs, cmd.Models.Args()...)
 	}
-	command := exec.Command("./stable-audio/sag", cmdArgs...)
+	runCtx, release := cmd.WithInterrupt(ctx)
+	defer release()
+	command := exec.CommandContext(runCtx, tools.Path(tools.Sag), cmdArgs...)
 
 	command.Stdout = os.Stdout
-	command.Stderr = os.Stderr
+	stderr := triage.NewTail(stderrTail)
+	command.Stderr = io.MultiWriter(os.Stderr, stderr)
+
+	fp.SetPhase(discord.PhaseDiffusing)
+
+	// an operator may have injected failures, to see how they're handled
+	fault := chaos.Claim(cmd.Message.GuildID)
+	if fault != 0 {
+		log.Warn("injecting failures: ", fault)
+	}
 
-	// Run the command
-	if err := command.Run(); err != nil {
+	started := time.Now()
+	_, runSpan := telemetry.Start(ctx, "subprocess.run")
+	err = cmd.InterruptCause(runCtx, stderr.Wrap(fault.Run(runCtx, command.Run)))
+	telemetry.End(runSpan, err)
+	if err != nil {
 		err = fmt.Errorf("error during audio generation: %w", err)
 		if stopErr := fp.Stop(); stopErr != nil {
 			err = fmt.Errorf("%w; during handling, another error occurred: %w", err, stopErr)
 		}
-		slog.Error(err.Error())
+		log.Error(err.Error())
+		if cmd.Retrying() {
+			// the queue runs it again, so there's nothing to tell the user yet
+			return err
+		}
 
 		errorMessage, createMessageErr := discord.NewMessage(discord.ConcreteSession{Session: cmd.Session}, cmd.Message.ChannelID)
 		if createMessageErr != nil {
@@ -349,33 +494,115 @@ func (cmd *StableAudioCommand) Apply() error {
 			return err
 		}
 
-		if sendMessageErr := errorMessage.Create(err.Error()); sendMessageErr != nil {
+		if sendMessageErr := errorMessage.Create(commands.FailureText(cmd.Session, err, cmd.TraceID()) + commands.TraceFooter(cmd.TraceID())); sendMessageErr != nil {
 			err = fmt.Errorf("%w; when sending the error message to discord, another error occurred: %w", err, sendMessageErr)
 		}
 
 		return err
 	}
+	elapsed := time.Since(started)
+	fp.SetPhase(discord.PhasePostProcessing)
+	if keepsProgress(params.KeepProgress, cmd.Message) {
+		if err := fp.Finish(progressSummary(elapsed, params.Seed)); err != nil {
+			log.Warn("couldn't leave progress summary: ", err)
+		}
+	}
+
+	if err := cmd.Labels.Watermark(ctx, cmd.Message.GuildID, outFile, cmd.TraceID()); err != nil {
+		log.Warn("couldn't watermark output: ", err)
+	}
+	cmd.output = outFile
+
+	// the output counts against the user until it's been delivered
+	releaseOutput := cmd.Quota.Track(cmd.Message.Author.ID, outFile)
 
-	// Send the resulting audio file back to the Discord channel
-	file, err := os.Open(outFile)
+	// Send the resulting audio file back to the Discord channel, or a clip of
+	// it if it's too big
+	upload, uploadNote, removeClip, err := uploadable(cmd.Message.GuildID, outFile)
 	if err != nil {
-		cmd.Session.ChannelMessageSendReply(cmd.Message.ChannelID, "Failed to open output file: "+err.Error(), triggeringMessage)
+		cmd.Session.ChannelMessageSendReply(cmd.Message.ChannelID, "Failed to prepare output file: "+commands.FailureText(cmd.Session, err, cmd.TraceID()), triggeringMessage)
+		return err
+	}
+	defer removeClip()
+	file, err := os.Open(upload)
+	if err != nil {
+		cmd.Session.ChannelMessageSendReply(cmd.Message.ChannelID, "Failed to open output file: "+commands.FailureText(cmd.Session, err, cmd.TraceID()), triggeringMessage)
 		return err
 	}
 	defer file.Close()
 
+	model := modelName(params.IsSmall, cmdArgs)
+	fields := results.Fields{
+		Prompt:    params.Prompt,
+		Seed:      params.Seed,
+		Duration:  format.Duration(elapsed),
+		Model:     model,
+		Submitter: cmd.Message.Author.Mention(),
+	}
 	finalMessage := &discordgo.MessageSend{
 		Files: []*discordgo.File{{
-			Name:   outFile,
+			Name:   upload,
 			Reader: file,
 		}},
+		Content: joinLines(
+			cmd.Results.Content(cmd.Message.GuildID, fields),
+			cmd.Labels.Footer(cmd.Message.GuildID, model),
+			uploadNote,
+		),
 		Reference: triggeringMessage,
+		// the template can show the prompt, which shouldn't ping anyone
+		AllowedMentions: &discordgo.MessageAllowedMentions{RepliedUser: true},
+	}
+	if params.Prompt != originalPrompt {
+		finalMessage.Embeds = []*discordgo.MessageEmbed{{
+			Fields: []*discordgo.MessageEmbedField{
+				{Name: "Original prompt", Value: truncate(originalPrompt, 1024)},
+				{Name: "Enhanced prompt", Value: truncate(params.Prompt, 1024)},
+			},
+		}}
 	}
 
-	if _, err := cmd.Session.ChannelMessageSendComplex(cmd.Message.ChannelID, finalMessage); err != nil {
-		cmd.Session.ChannelMessageSend(cmd.Message.ChannelID, "Failed to send file: "+err.Error())
+	fp.SetPhase(discord.PhaseUploading)
+	_, uploadSpan := telemetry.Start(ctx, "upload")
+	posted, err := sendResult(fault.Sender(cmd.Session), cmd.Message.GuildID, cmd.Message.ChannelID, finalMessage, upload)
+	telemetry.End(uploadSpan, err)
+	if errors.Is(err, discord.ErrUndelivered) {
+		// the user has a button to retry it, so the job itself is done
+		log.Warn("couldn't deliver output: ", err)
+		releaseOutput()
+		return nil
+	}
+	if err != nil {
+		cmd.Session.ChannelMessageSend(cmd.Message.ChannelID, "Failed to send file: "+commands.FailureText(cmd.Session, err, cmd.TraceID()))
 		return err
 	}
+	releaseOutput()
+
+	// a result DMed to its submitter for approval is archived, and voted on,
+	// once it's published
+	if posted.ChannelID == cmd.Message.ChannelID {
+		if err := cmd.Archive.Mirror(cmd.Session, cmd.Message.GuildID, posted, fields); err != nil {
+			log.Warn("couldn't archive output: ", err)
+		}
+		if err := cmd.Votes.Add(ratings.Result{
+			GuildID:   cmd.Message.GuildID,
+			ChannelID: posted.ChannelID,
+			MessageID: posted.ID,
+			UserID:    cmd.Message.Author.ID,
+			Prompt:    params.Prompt,
+			Posted:    time.Now(),
+		}); err != nil {
+			log.Warn("couldn't track votes on output: ", err)
+		}
+	}
 
 	return nil
 }
+
+// Outputs returns the generated file, once generation has finished.
+func (cmd *StableAudioCommand) Outputs() []string {
+	if cmd.output == "" {
+		return nil
+	}
+	return []string{cmd.output}
+}
@@ -0,0 +1,71 @@
+package audio
+
+import (
+	"testing"
+	"time"
+
+	"slugbot/internal/config"
+	"slugbot/internal/promptspec"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeepsProgress_ByFlagGuildOrUser(t *testing.T) {
+	defer config.Set(config.Get())
+	cfg := config.Default()
+	cfg.Progress.Guilds = []string{"g1"}
+	cfg.Progress.Users = []string{"u1"}
+	config.Set(cfg)
+
+	message := func(guildID string, userID string) *discordgo.MessageCreate {
+		return &discordgo.MessageCreate{Message: &discordgo.Message{GuildID: guildID, Author: &discordgo.User{ID: userID}}}
+	}
+	require.True(t, keepsProgress(false, message("g1", "u2")))
+	require.True(t, keepsProgress(false, message("g2", "u1")))
+	require.True(t, keepsProgress(true, message("g2", "u2")))
+	require.False(t, keepsProgress(false, message("g2", "u2")))
+	require.False(t, keepsProgress(false, message("", "u2")))
+
+	params, err := parseArgs([]string{"rainy", "jazz", "--keep-progress"})
+	require.NoError(t, err)
+	require.True(t, params.KeepProgress)
+	require.Equal(t, "rainy jazz", params.Prompt)
+}
+
+func TestProgressSummary(t *testing.T) {
+	require.Equal(t, "generated in 3m 12s, seed 1234", progressSummary(3*time.Minute+12*time.Second, 1234))
+	require.Equal(t, "generated in 45s", progressSummary(45*time.Second, -1))
+}
+
+func TestParamsSummary(t *testing.T) {
+	params, err := parseArgs([]string{"rainy", "jazz", "--length", "90", "--steps", "500", "--", "drums"})
+	require.NoError(t, err)
+	require.Equal(t, "`rainy jazz`, without `drums` · 500 steps · 90s · random seed · Stable Audio Open 1.0",
+		paramsSummary(params, modelName(false, nil)))
+
+	params, err = parseArgs([]string{"rain", "--seed", "7", "--small", "--length", "12.5"})
+	require.NoError(t, err)
+	require.Equal(t, "`rain` · 8 steps · 12.5s · seed 7 · Stable Audio Open Small", paramsSummary(params, modelName(true, nil)))
+}
+
+func TestJoinLines_SkipsEmptyLines(t *testing.T) {
+	require.Equal(t, "a\nb", joinLines("", "a", "", "b"))
+	require.Empty(t, joinLines("", ""))
+}
+
+func FuzzSagArgs_UserTextStaysInItsOption(f *testing.F) {
+	f.Add("rainy jazz", "")
+	f.Add("--small", "--output=/etc/passwd")
+	f.Add("-", "-h")
+	f.Fuzz(func(t *testing.T, prompt string, negative string) {
+		params := &promptspec.Args{Prompt: prompt, NegativePrompt: negative, Length: 10, Steps: 50, Strength: 7}
+		args := sagArgs(params, "out.wav", "progress.txt", "")
+
+		require.Len(t, args, 8)
+		require.Equal(t, "--prompt="+prompt, args[0])
+		require.Equal(t, "--negative_prompt="+negative, args[1])
+		require.Equal(t, "--output=out.wav", args[2])
+		require.Equal(t, "--progress_file=progress.txt", args[3])
+	})
+}
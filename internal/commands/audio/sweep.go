@@ -0,0 +1,159 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+
+	"slugbot/internal/backend"
+	"slugbot/internal/commands"
+	"slugbot/internal/commands/traits"
+	"slugbot/internal/exec"
+	"slugbot/internal/promptspec"
+	"slugbot/internal/provenance"
+	"slugbot/internal/quota"
+)
+
+// SweepCommand generates one prompt with several consecutive seeds and posts
+// the results together in a thread.
+type SweepCommand struct {
+	commands.Command
+	traits.Promptable
+	Queue   *exec.TaskQueue
+	Audio   *backend.Models
+	Quota   *quota.Tracker
+	Labels  *provenance.Labeler
+	MaxJobs int // largest number of seeds one sweep may queue
+
+	seeds []int64
+	ahead int
+}
+
+func (c *SweepCommand) Usage() string {
+	return fmt.Sprintf("Usage: `.ssweep --seeds <2-%d> [--seed <first>] [--small] [--length <s>] [--steps <n>] <prompt> [-- <negative words>]`", c.MaxJobs)
+}
+
+func (c *SweepCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Queue == nil {
+		return fmt.Errorf("no queue to schedule sweeps on")
+	}
+	if _, _, err := c.parseArgs(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseArgs splits out --seeds and --seed and returns the seeds to generate
+// with and the remaining generation arguments.
+func (c *SweepCommand) parseArgs() (seeds []int64, args []string, err error) {
+	fields := strings.Fields(c.Message.Content)
+	if len(fields) < 2 {
+		return nil, nil, errors.New(c.Usage())
+	}
+
+	count := 0
+	first := rand.Int64N(1<<31 - 1)
+	for i := 1; i < len(fields); i++ {
+		switch fields[i] {
+		case "--seeds", "--seed":
+			if i+1 >= len(fields) {
+				return nil, nil, fmt.Errorf("missing value for %s", fields[i])
+			}
+			n, err := strconv.ParseInt(fields[i+1], 10, 64)
+			if err != nil || n < 0 {
+				return nil, nil, fmt.Errorf("invalid value for %s: %s", fields[i], fields[i+1])
+			}
+			if fields[i] == "--seeds" {
+				count = int(n)
+			} else {
+				first = n
+			}
+			i++
+		case "--enhance", "--input":
+			return nil, nil, fmt.Errorf("`.ssweep` doesn't support %s", fields[i])
+		default:
+			args = append(args, fields[i])
+		}
+	}
+
+	if count < 2 || count > c.MaxJobs {
+		return nil, nil, errors.New(c.Usage())
+	}
+	for i := range count {
+		seeds = append(seeds, first+int64(i))
+	}
+	return seeds, args, nil
+}
+
+// Apply queues one generation per seed as a group; the results are posted when the last finishes.
+func (c *SweepCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	seeds, args, err := c.parseArgs()
+	if err != nil {
+		return err
+	}
+
+	model := "full"
+	if slices.Contains(args, "--small") {
+		model = "small"
+		args = slices.DeleteFunc(args, func(arg string) bool { return arg == "--small" })
+	}
+
+	jobs := make([]exec.Task, len(seeds))
+	allParams := make([]*promptspec.Args, len(seeds))
+	group := NewJobGroup(len(seeds), c.post)
+	for i, seed := range seeds {
+		params, modelArgs, err := modelParams(c.Audio, model, append(slices.Clone(args), "--seed", strconv.FormatInt(seed, 10)))
+		if err != nil {
+			return err
+		}
+		c.SetPrompt(params.Prompt)
+		allParams[i] = params
+
+		job := &GroupJob{
+			Params:    params,
+			ModelArgs: modelArgs,
+			Label:     fmt.Sprintf("seed %d (%d/%d)", seed, i+1, len(seeds)),
+			Index:     i,
+			Group:     group,
+			Quota:     c.Quota,
+			Labels:    c.Labels,
+			Pattern:   fmt.Sprintf("saudio-seed%d-*.wav", seed),
+		}
+		job.SetContext(c.Session, c.Message)
+		job.SetTraceID(c.TraceID())
+		job.SetTraceContext(c.TraceContext())
+		job.SetPrompt(params.Prompt)
+		jobs[i] = job
+	}
+
+	// every result is held until the last one is done, so they all need to fit at once
+	if err := c.Quota.CheckFits(c.Message.Author.ID, expectedBytes(allParams)); err != nil {
+		return err
+	}
+
+	c.seeds = seeds
+	c.Log().Info("queueing sweep over ", len(seeds), " seeds")
+	c.ahead, err = c.Queue.EnqueueGroup(jobs)
+	return err
+}
+
+// Ahead returns how many jobs were ahead of the sweep when it was queued.
+func (c *SweepCommand) Ahead() int {
+	return c.ahead
+}
+
+func (c *SweepCommand) post(results []GroupResult) {
+	summary := fmt.Sprintf("Seed sweep for `%s`: seeds %d–%d", c.Prompt(), c.seeds[0], c.seeds[len(c.seeds)-1])
+	postGroupThread(&c.Command, "sweep: "+c.Prompt(), summary, results, func(result GroupResult) string {
+		return fmt.Sprintf("seed %d (%d/%d)", result.Params.Seed, result.Index+1, len(results))
+	})
+}
@@ -0,0 +1,22 @@
+package audio
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSweepCommand_ParseArgsUsesSequentialSeeds(t *testing.T) {
+	cmd := &SweepCommand{MaxJobs: 8}
+	cmd.Message = &discordgo.MessageCreate{Message: &discordgo.Message{Content: ".ssweep --seeds 3 --seed 40 rainy jazz --length 5"}}
+
+	seeds, args, err := cmd.parseArgs()
+	require.NoError(t, err)
+	require.Equal(t, []int64{40, 41, 42}, seeds)
+	require.Equal(t, []string{"rainy", "jazz", "--length", "5"}, args)
+
+	cmd.Message.Content = ".ssweep --seeds 9 rainy jazz"
+	_, _, err = cmd.parseArgs()
+	require.Error(t, err)
+}
@@ -0,0 +1,101 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/discord"
+	"slugbot/internal/ratings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	topResults = 10
+	topUsers   = 5
+	topWindow  = 7 * 24 * time.Hour
+)
+
+// topLinkText keeps a prompt from closing the markdown link it's shown in.
+var topLinkText = strings.NewReplacer("[", "(", "]", ")", "\n", " ")
+
+// TopCommand shows the guild's best-liked results of the past week, voted on
+// with 👍 and 👎 reactions, and whose results were liked most.
+type TopCommand struct {
+	commands.Command
+	Votes *ratings.Store
+}
+
+func (c *TopCommand) Usage() string {
+	return "Usage: `.stop10`; shows this server's top-voted results of the week"
+}
+
+func (c *TopCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Message.GuildID == "" {
+		return fmt.Errorf("the leaderboard is only kept inside a server")
+	}
+	return nil
+}
+
+func (c *TopCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	since := time.Now().Add(-topWindow)
+	top, err := c.Votes.Top(c.Message.GuildID, since, topResults)
+	if err != nil {
+		return err
+	}
+	if len(top) == 0 {
+		_, err := c.Session.ChannelMessageSendReply(c.Message.ChannelID,
+			fmt.Sprintf("Nothing's been voted up this week yet. React to a result with %s or %s to vote on it.", ratings.Like, ratings.Dislike),
+			c.Message.Reference())
+		return err
+	}
+	users, err := c.Votes.MostLiked(c.Message.GuildID, since, topUsers)
+	if err != nil {
+		return err
+	}
+
+	embeds := []*discordgo.MessageEmbed{{
+		Title:       "Top results this week",
+		Description: strings.Join(topLines(top), "\n"),
+		Fields:      []*discordgo.MessageEmbedField{{Name: "Most liked", Value: strings.Join(mostLikedLines(users), "\n")}},
+		Footer:      &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Vote on results with %s and %s", ratings.Like, ratings.Dislike)},
+	}}
+	discord.Brand(c.Message.ChannelID, embeds)
+	_, err = c.Session.ChannelMessageSendComplex(c.Message.ChannelID, &discordgo.MessageSend{
+		Embeds:    embeds,
+		Reference: c.Message.Reference(),
+	})
+	return err
+}
+
+// topLines lists results with their votes, each linking to the result.
+func topLines(top []ratings.Result) []string {
+	var lines []string
+	for i, r := range top {
+		prompt := r.Prompt
+		if prompt == "" {
+			prompt = "result"
+		}
+		lines = append(lines, fmt.Sprintf("%d. [%s](%s) by <@%s> · %s %d %s %d",
+			i+1, truncate(topLinkText.Replace(prompt), 80), r.Link(), r.UserID, ratings.Like, r.Likes, ratings.Dislike, r.Dislikes))
+	}
+	return lines
+}
+
+// mostLikedLines lists users with the likes their results got.
+func mostLikedLines(users []ratings.UserLikes) []string {
+	var lines []string
+	for i, user := range users {
+		lines = append(lines, fmt.Sprintf("%d. <@%s>: %s %d on %d result(s)", i+1, user.UserID, ratings.Like, user.Likes, user.Results))
+	}
+	return lines
+}
@@ -0,0 +1,12 @@
+package audio
+
+import "slugbot/internal/exec"
+
+// watchdog is told about jobs whose progress stops changing when set.
+var watchdog *exec.Watchdog
+
+// SetWatchdog has every job's progress file report to w once it stops
+// changing, or with nil, stops it.
+func SetWatchdog(w *exec.Watchdog) {
+	watchdog = w
+}
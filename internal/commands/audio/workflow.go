@@ -0,0 +1,565 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"slugbot/internal/backend"
+	"slugbot/internal/cmdline"
+	"slugbot/internal/commands"
+	"slugbot/internal/commands/traits"
+	"slugbot/internal/discord"
+	"slugbot/internal/eta"
+	execqueue "slugbot/internal/exec"
+	"slugbot/internal/helpers"
+	"slugbot/internal/prefs"
+	"slugbot/internal/promptspec"
+	"slugbot/internal/provenance"
+	"slugbot/internal/quota"
+	"slugbot/internal/telemetry"
+	"slugbot/internal/tools"
+	"slugbot/internal/triage"
+	"slugbot/internal/utils"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxWorkflowSteps bounds how many stages one ```sflow block may define.
+const maxWorkflowSteps = 8
+
+// maxLoops bounds how many times the loop stages repeat the audio altogether,
+// so a short clip can't be turned into an enormous upload.
+const maxLoops = 8
+
+// sfxFilters are the ffmpeg audio filters behind `sfx <name>`.
+var sfxFilters = map[string]string{
+	"echo":      "aecho=0.8:0.88:60:0.4",
+	"reverb":    "aecho=0.8:0.9:1000|1800:0.3|0.25",
+	"lofi":      "aresample=11025,aresample=44100,lowpass=f=3500",
+	"telephone": "highpass=f=300,lowpass=f=3400",
+	"reverse":   "areverse",
+	"slow":      "asetrate=44100*0.8,aresample=44100",
+	"fast":      "atempo=1.25",
+}
+
+// WorkflowStep is one line of a ```sflow block, e.g. "sfx reverb".
+type WorkflowStep struct {
+	Op   string   // generate, sfx, loop, limit, or spectrogram
+	Args []string // everything after the op
+}
+
+func (s WorkflowStep) String() string {
+	if s.Op == "generate" || len(s.Args) == 0 {
+		return s.Op
+	}
+	return s.Op + " " + strings.Join(s.Args, " ")
+}
+
+// Workflow is a parsed ```sflow block.
+type Workflow struct {
+	Steps    []WorkflowStep
+	Params   *promptspec.Args // what the generate step asks for
+	PostEach bool             // post every stage's output, not just the last
+}
+
+// ParseWorkflow parses the body of a ```sflow block: one stage per line,
+// starting with `generate <.saudio arguments>`, followed by any of `sfx
+// <effect>`, `loop <n>`, `limit`, and finally, optionally, `spectrogram`. A
+// `--each` line posts every stage's output instead of only the last one.
+// Blank lines and lines starting with # are ignored.
+func ParseWorkflow(body string) (*Workflow, error) {
+	workflow := &Workflow{}
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] == "--each" && len(fields) == 1 {
+			workflow.PostEach = true
+			continue
+		}
+		step := WorkflowStep{Op: strings.ToLower(fields[0]), Args: fields[1:]}
+		if err := workflow.add(step); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(workflow.Steps) == 0 {
+		return nil, fmt.Errorf("the workflow has no stages")
+	}
+	if len(workflow.Steps) > maxWorkflowSteps {
+		return nil, fmt.Errorf("the workflow has %d stages, but at most %d are allowed", len(workflow.Steps), maxWorkflowSteps)
+	}
+	if loops := workflow.loops(); loops > maxLoops {
+		return nil, fmt.Errorf("the workflow loops the audio %d times, but at most %d are allowed", loops, maxLoops)
+	}
+	return workflow, nil
+}
+
+// add checks a step against the ones before it and appends it.
+func (w *Workflow) add(step WorkflowStep) error {
+	if len(w.Steps) == 0 && step.Op != "generate" {
+		return fmt.Errorf("a workflow has to start with `generate <prompt>`, not `%s`", step.Op)
+	}
+	if len(w.Steps) > 0 && w.Steps[len(w.Steps)-1].Op == "spectrogram" {
+		return fmt.Errorf("`spectrogram` makes an image, so it has to be the last stage")
+	}
+
+	switch step.Op {
+	case "generate":
+		if len(w.Steps) > 0 {
+			return fmt.Errorf("a workflow can only `generate` once, as its first stage")
+		}
+		params, err := parseArgs(step.Args)
+		if err != nil {
+			return err
+		}
+		if params.Prompt == "" {
+			return fmt.Errorf("`generate` needs a prompt")
+		}
+		w.Params = params
+	case "sfx":
+		if len(step.Args) != 1 || sfxFilters[step.Args[0]] == "" {
+			return fmt.Errorf("`sfx` takes one of: %s", strings.Join(sfxNames(), ", "))
+		}
+	case "loop":
+		if len(step.Args) != 1 {
+			return fmt.Errorf("`loop` takes how many times to play the audio, e.g. `loop 3`")
+		}
+		n, err := strconv.Atoi(step.Args[0])
+		if err != nil || n < 2 || n > maxLoops {
+			return fmt.Errorf("`loop` takes a count from 2 to %d, not `%s`", maxLoops, step.Args[0])
+		}
+	case "limit", "spectrogram":
+		if len(step.Args) > 0 {
+			return fmt.Errorf("`%s` doesn't take any arguments", step.Op)
+		}
+	default:
+		return fmt.Errorf("unknown stage `%s`; stages are generate, sfx, loop, limit, and spectrogram", step.Op)
+	}
+	w.Steps = append(w.Steps, step)
+	return nil
+}
+
+// loops returns how many times the generated audio plays by the last stage.
+func (w *Workflow) loops() int {
+	loops := 1
+	for _, step := range w.Steps {
+		if step.Op == "loop" {
+			n, _ := strconv.Atoi(step.Args[0])
+			loops *= n
+		}
+	}
+	return loops
+}
+
+func sfxNames() []string {
+	names := make([]string, 0, len(sfxFilters))
+	for name := range sfxFilters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WorkflowCommand expands a ```sflow block into a chain of queued stages, each
+// working on the output of the one before it.
+type WorkflowCommand struct {
+	commands.Command
+	traits.Promptable
+	Queue     *execqueue.TaskQueue
+	Models    *backend.Models
+	Quota     *quota.Tracker
+	Labels    *provenance.Labeler
+	Estimator *eta.Estimator // optional; used for the progress message's overall estimate
+
+	ahead int
+}
+
+func (c *WorkflowCommand) Usage() string {
+	return "Usage: a ```sflow block with one stage per line, e.g.\n" +
+		"```\ngenerate rainy jazz piano --length 20\nsfx reverb\nloop 3\nlimit\nspectrogram\n```\n" +
+		"Stages: `generate <.saudio arguments>` (first), `sfx <" + strings.Join(sfxNames(), "|") + ">`, `loop <2-" + strconv.Itoa(maxLoops) + ">`, `limit`, and `spectrogram` (last). " +
+		"Only the last stage's output is posted unless a line says `--each`."
+}
+
+// block returns the text between the ```sflow fence and the closing ```.
+func (c *WorkflowCommand) block() (string, error) {
+	content := strings.TrimSpace(c.Message.Content)
+	if !strings.HasPrefix(content, "```sflow") || !strings.HasSuffix(content, "```") || len(content) < len("```sflow```") {
+		return "", errors.New("a workflow has to be a ```sflow block closed with ```")
+	}
+	return content[len("```sflow") : len(content)-len("```")], nil
+}
+
+func (c *WorkflowCommand) Validate() error {
+	if c.Session == nil || c.Message == nil {
+		return fmt.Errorf("invalid session or message")
+	}
+	if c.Queue == nil {
+		return fmt.Errorf("no queue to schedule workflows on")
+	}
+	body, err := c.block()
+	if err != nil {
+		return err
+	}
+	_, err = ParseWorkflow(body)
+	return err
+}
+
+// Apply queues the workflow's stages as a chain.
+func (c *WorkflowCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	body, err := c.block()
+	if err != nil {
+		return err
+	}
+	workflow, err := ParseWorkflow(body)
+	if err != nil {
+		return err
+	}
+	c.SetPrompt(workflow.Params.Prompt)
+
+	var modelArgs []string
+	if !workflow.Params.IsSmall {
+		modelArgs = c.Models.Args()
+	}
+
+	// the stages share one progress message, sent when the first of them starts
+	names := make([]string, len(workflow.Steps))
+	for i, step := range workflow.Steps {
+		names[i] = step.String()
+	}
+	progress, err := discord.NewStageProgress(discord.ConcreteSession{Session: c.Session}, c.Message.ChannelID, c.Message.ID,
+		"Workflow: `"+truncate(workflow.Params.Prompt, 200)+"`", names)
+	if err != nil {
+		return fmt.Errorf("failed to init progress message: %w", err)
+	}
+	progress.Footer = commands.TraceFooter(c.TraceID())
+	progress.Quiet = prefs.FromContext(c.TraceContext()).Quiet
+	progress.Estimates = make([]time.Duration, len(names))
+	if estimate, ok := c.Estimator.Estimate(shapeOf(workflow.Params.IsSmall, workflow.Params.Steps, workflow.Params.Length)); ok {
+		progress.Estimates[0] = estimate
+	}
+
+	stages := make([]execqueue.Task, len(workflow.Steps))
+	for i, step := range workflow.Steps {
+		stage := &WorkflowStage{
+			Step:      step,
+			Index:     i,
+			Total:     len(workflow.Steps),
+			Post:      workflow.PostEach || i == len(workflow.Steps)-1,
+			PostEach:  workflow.PostEach,
+			Params:    workflow.Params,
+			ModelArgs: modelArgs,
+			Quota:     c.Quota,
+			Labels:    c.Labels,
+			Progress:  progress,
+		}
+		stage.SetContext(c.Session, c.Message)
+		stage.SetTraceID(c.TraceID())
+		stage.SetTraceContext(c.TraceContext())
+		stage.SetPrompt(fmt.Sprintf("%s [%d/%d %s]", workflow.Params.Prompt, i+1, len(workflow.Steps), step))
+		stages[i] = stage
+	}
+
+	// a stage's output is only ever as long as the generated audio times its loops
+	longest := *workflow.Params
+	longest.Length *= float64(workflow.loops())
+	if err := c.Quota.CheckFits(c.Message.Author.ID, expectedBytes([]*promptspec.Args{&longest})); err != nil {
+		return err
+	}
+
+	c.Log().Info("queueing workflow of ", len(stages), " stages")
+	c.ahead, err = c.Queue.EnqueueChain(stages)
+	return err
+}
+
+// Ahead returns how many jobs were ahead of the workflow when it was queued.
+func (c *WorkflowCommand) Ahead() int {
+	return c.ahead
+}
+
+// WorkflowStage is one queued stage of a ```sflow workflow. The queue hands it
+// the previous stage's output before it runs.
+type WorkflowStage struct {
+	commands.Command
+	traits.Promptable
+	traits.Progressable
+	traits.Interruptible
+	Step      WorkflowStep
+	Index     int
+	Total     int
+	Post      bool // upload this stage's output
+	PostEach  bool // every stage's output is uploaded, so inputs are kept
+	Params    *promptspec.Args
+	ModelArgs []string
+	Quota     *quota.Tracker
+	Labels    *provenance.Labeler
+	Progress  *discord.StageProgress // shared by every stage of the workflow
+
+	inputs []string
+	output string
+}
+
+// SetInputs receives the previous stage's output.
+func (stage *WorkflowStage) SetInputs(paths []string) {
+	stage.inputs = paths
+}
+
+// Outputs returns the file this stage wrote, once it has run.
+func (stage *WorkflowStage) Outputs() []string {
+	if stage.output == "" {
+		return nil
+	}
+	return []string{stage.output}
+}
+
+// Shape reports the generation's shape; the other stages are quick and aren't estimated.
+func (stage *WorkflowStage) Shape() (eta.Shape, bool) {
+	if stage.Step.Op != "generate" {
+		return eta.Shape{}, false
+	}
+	return shapeOf(stage.Params.IsSmall, stage.Params.Steps, stage.Params.Length), true
+}
+
+// label names the stage in messages, e.g. "Stage 2/4 (sfx reverb)".
+func (stage *WorkflowStage) label() string {
+	return fmt.Sprintf("Stage %d/%d (%s)", stage.Index+1, stage.Total, stage.Step)
+}
+
+func (stage *WorkflowStage) HandleError(err error) {
+	if errors.Is(err, execqueue.ErrDependencyFailed) {
+		return
+	}
+	stage.Log().Error("workflow ", stage.label(), " failed: ", err)
+	if err := stage.Progress.Fail(stage.Index); err != nil {
+		stage.Log().Warn("couldn't mark the failed stage: ", err)
+	}
+	stage.Session.ChannelMessageSendReply(stage.Message.ChannelID,
+		stage.label()+" failed, so the workflow stopped: "+commands.FailureText(stage.Session, err, stage.TraceID())+commands.TraceFooter(stage.TraceID()), stage.Message.Reference())
+}
+
+// Cancelled takes down the progress message when the workflow is removed from the queue.
+func (stage *WorkflowStage) Cancelled() {
+	if err := stage.Progress.Stop(); err != nil {
+		stage.Log().Warn("couldn't delete workflow progress: ", err)
+	}
+}
+
+func (stage *WorkflowStage) Apply() error {
+	if stage.Step.Op != "generate" && len(stage.inputs) == 0 {
+		return fmt.Errorf("%s has no input", stage.label())
+	}
+	if err := stage.Progress.Begin(stage.Index); err != nil {
+		stage.Log().Warn("couldn't update workflow progress: ", err)
+	}
+
+	var err error
+	switch stage.Step.Op {
+	case "generate":
+		err = stage.generate()
+	case "sfx":
+		err = stage.ffmpeg(".wav", "-i", stage.inputs[0], "-af", sfxFilters[stage.Step.Args[0]])
+	case "loop":
+		n, _ := strconv.Atoi(stage.Step.Args[0])
+		err = stage.ffmpeg(".wav", "-stream_loop", strconv.Itoa(n-1), "-i", stage.inputs[0], "-c", "copy")
+	case "limit":
+		err = stage.limit()
+	case "spectrogram":
+		err = stage.ffmpeg(".png", "-i", stage.inputs[0], "-lavfi", "showspectrumpic=s=1024x512")
+	default:
+		err = fmt.Errorf("unknown stage `%s`", stage.Step.Op)
+	}
+	if err != nil {
+		return err
+	}
+
+	// intermediate files that were never posted aren't needed once they've been used
+	if !stage.PostEach {
+		for _, input := range stage.inputs {
+			os.Remove(input)
+		}
+	}
+	if stage.Post {
+		if err := stage.post(); err != nil {
+			return err
+		}
+	}
+	if stage.Index == stage.Total-1 {
+		stage.finish()
+	}
+	return nil
+}
+
+// finish takes down the progress message once the last stage is done, or
+// leaves it as a summary if progress is kept.
+func (stage *WorkflowStage) finish() {
+	var err error
+	if keepsProgress(stage.Params.KeepProgress, stage.Message) {
+		err = stage.Progress.Finish(progressSummary(stage.Progress.Elapsed(), stage.Params.Seed))
+	} else {
+		err = stage.Progress.Stop()
+	}
+	if err != nil {
+		stage.Log().Warn("couldn't finish workflow progress: ", err)
+	}
+}
+
+// createOutput makes the stage's output file in the temp dir, where the janitor
+// cleans up after workflows that never finish.
+func (stage *WorkflowStage) createOutput(ext string) (string, error) {
+	out, err := os.CreateTemp(utils.TempDir(), fmt.Sprintf("slugbot-flow%d-*%s", stage.Index+1, ext))
+	if err != nil {
+		return "", fmt.Errorf("couldn't create output file: %w", err)
+	}
+	out.Close()
+	return out.Name(), nil
+}
+
+func (stage *WorkflowStage) generate() error {
+	log := stage.Log()
+	ctx := stage.TraceContext()
+
+	out, err := stage.createOutput(".wav")
+	if err != nil {
+		return err
+	}
+
+	pf, err := utils.NewPollableFile(1*time.Second, func(text string) {
+		stage.SetProgress(discord.RenderTQDM(text))
+		if err := stage.Progress.Update(stage.Index, text); err != nil {
+			log.Warn("couldn't update workflow progress: ", err)
+		}
+	})
+	if err != nil {
+		os.Remove(out)
+		return fmt.Errorf("failed to init progress poller: %w", err)
+	}
+	defer pf.Remove()
+	pf.Watch = true
+	watchdog.Watch(pf)
+	done, polling := make(chan struct{}), make(chan struct{})
+	go func() {
+		pf.Start(done)
+		close(polling)
+	}()
+	defer func() {
+		close(done)
+		<-polling
+	}()
+
+	cmdArgs := append(sagArgs(stage.Params, out, pf.File, ""), stage.ModelArgs...)
+	runCtx, release := stage.WithInterrupt(ctx)
+	defer release()
+	command := exec.CommandContext(runCtx, tools.Path(tools.Sag), cmdArgs...)
+	command.Stdout = os.Stdout
+	stderr := triage.NewTail(stderrTail)
+	command.Stderr = io.MultiWriter(os.Stderr, stderr)
+
+	_, runSpan := telemetry.Start(ctx, "subprocess.run")
+	err = stage.InterruptCause(runCtx, stderr.Wrap(command.Run()))
+	telemetry.End(runSpan, err)
+	if err != nil {
+		os.Remove(out)
+		return fmt.Errorf("error during audio generation: %w", err)
+	}
+
+	// watermark the generated audio, so every stage derived from it carries the label
+	if err := stage.Labels.Watermark(ctx, stage.Message.GuildID, out, stage.TraceID()); err != nil {
+		log.Warn("couldn't watermark workflow audio: ", err)
+	}
+	stage.output = out
+	return nil
+}
+
+// ffmpeg runs ffmpeg with args followed by a new output file with extension ext.
+func (stage *WorkflowStage) ffmpeg(ext string, args ...string) error {
+	out, err := stage.createOutput(ext)
+	if err != nil {
+		return err
+	}
+	stage.SetProgress(stage.label() + "...")
+	args = append([]string{"-y", "-hide_banner", "-loglevel", "error"}, args...)
+	if err := stage.run(tools.Path(tools.FFmpeg), helpers.FFmpegArgs(stage.Message.GuildID, append(args, out)...)...); err != nil {
+		os.Remove(out)
+		return fmt.Errorf("%s failed: %w", stage.Step.Op, err)
+	}
+	stage.output = out
+	return nil
+}
+
+// limit runs the Python limiter, like .slimit.
+func (stage *WorkflowStage) limit() error {
+	out, err := stage.createOutput(".wav")
+	if err != nil {
+		return err
+	}
+	stage.SetProgress(stage.label() + "...")
+	python := tools.Path(tools.Python)
+	if err := stage.run(python, cmdline.New(filepath.Join("py", "limiter.py")).Option("--input", stage.inputs[0]).Option("--output", out).Args()...); err != nil {
+		os.Remove(out)
+		return fmt.Errorf("limiter failed: %w", err)
+	}
+	stage.output = out
+	return nil
+}
+
+func (stage *WorkflowStage) run(name string, args ...string) error {
+	runCtx, release := stage.WithInterrupt(stage.TraceContext())
+	defer release()
+	command := exec.CommandContext(runCtx, name, args...)
+	command.Stdout = os.Stdout
+	stderr := triage.NewTail(stderrTail)
+	command.Stderr = io.MultiWriter(os.Stderr, stderr)
+
+	_, runSpan := telemetry.Start(stage.TraceContext(), "subprocess.run")
+	err := stage.InterruptCause(runCtx, stderr.Wrap(command.Run()))
+	telemetry.End(runSpan, err)
+	return err
+}
+
+// post uploads the stage's output as a reply to the workflow's block.
+func (stage *WorkflowStage) post() error {
+	file, err := os.Open(stage.output)
+	if err != nil {
+		return fmt.Errorf("couldn't open output: %w", err)
+	}
+	defer file.Close()
+
+	// the output counts against the user until it's been delivered
+	defer stage.Quota.Track(stage.Message.Author.ID, stage.output)()
+
+	contentType, ext := "audio/wav", ".wav"
+	if filepath.Ext(stage.output) == ".png" {
+		contentType, ext = "image/png", ".png"
+	}
+	content := stage.label()
+	if footer := stage.Labels.Footer(stage.Message.GuildID, modelName(stage.Params.IsSmall, stage.ModelArgs)); footer != "" {
+		content += "\n" + footer
+	}
+
+	// the trigger may have been deleted to cancel the rest of the workflow
+	reference := stage.Message.Reference()
+	reference.FailIfNotExists = new(bool)
+	_, err = discord.SendFiles(stage.Session, stage.Message.ChannelID, &discordgo.MessageSend{
+		Content:   content,
+		Reference: reference,
+		Files: []*discordgo.File{{
+			Name:        fmt.Sprintf("sflow-%d-%s%s", stage.Index+1, strings.ReplaceAll(stage.Step.String(), " ", "-"), ext),
+			ContentType: contentType,
+			Reader:      file,
+		}},
+	})
+	return err
+}
@@ -0,0 +1,60 @@
+package audio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWorkflow_StagesInOrder(t *testing.T) {
+	workflow, err := ParseWorkflow(`
+# a rainy loop
+generate rainy jazz piano --length 20 --seed 7
+sfx reverb
+loop 3
+
+limit
+spectrogram
+`)
+	require.NoError(t, err)
+	require.False(t, workflow.PostEach)
+	require.Equal(t, "rainy jazz piano", workflow.Params.Prompt)
+	require.Equal(t, 20.0, workflow.Params.Length)
+	require.Equal(t, int64(7), workflow.Params.Seed)
+
+	var stages []string
+	for _, step := range workflow.Steps {
+		stages = append(stages, step.String())
+	}
+	require.Equal(t, []string{"generate", "sfx reverb", "loop 3", "limit", "spectrogram"}, stages)
+	require.Equal(t, 3, workflow.loops())
+}
+
+func TestParseWorkflow_EachPostsEveryStage(t *testing.T) {
+	workflow, err := ParseWorkflow("--each\ngenerate rain\nsfx lofi\n")
+	require.NoError(t, err)
+	require.True(t, workflow.PostEach)
+	require.Len(t, workflow.Steps, 2)
+}
+
+func TestParseWorkflow_RejectsBadWorkflows(t *testing.T) {
+	for name, body := range map[string]string{
+		"empty":                "\n# nothing here\n",
+		"no generate":          "sfx reverb\n",
+		"generate twice":       "generate rain\ngenerate snow\n",
+		"no prompt":            "generate --length 10\n",
+		"unknown effect":       "generate rain\nsfx wobble\n",
+		"unknown stage":        "generate rain\nstretch 2\n",
+		"loop once":            "generate rain\nloop 1\n",
+		"too many loops":       "generate rain\nloop 4\nloop 4\n",
+		"after spectrogram":    "generate rain\nspectrogram\nlimit\n",
+		"arguments to limit":   "generate rain\nlimit hard\n",
+		"too many stages":      "generate rain\nsfx echo\nsfx echo\nsfx echo\nsfx echo\nsfx echo\nsfx echo\nsfx echo\nsfx echo\n",
+		"bad generate options": "generate rain --length soon\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseWorkflow(body)
+			require.Error(t, err)
+		})
+	}
+}
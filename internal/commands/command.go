@@ -1,12 +1,23 @@
 package commands
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
+	"slugbot/internal/alert"
+	"slugbot/internal/commands/traits"
+	"slugbot/internal/config"
+	"slugbot/internal/triage"
+
 	"github.com/bwmarrin/discordgo"
 )
 
 type Command struct {
+	traits.Traceable
 	Session *discordgo.Session
 	Message *discordgo.MessageCreate
+	Input   string // picks among several input attachments; see helpers.SelectInput
 }
 
 func (c *Command) SetContext(s *discordgo.Session, m *discordgo.MessageCreate) {
@@ -14,12 +25,94 @@ func (c *Command) SetContext(s *discordgo.Session, m *discordgo.MessageCreate) {
 	c.Message = m
 }
 
+// SetInput sets which attachment to use when the source message has several.
+func (c *Command) SetInput(selector string) {
+	c.Input = selector
+}
+
+// Content returns the text of the message the command runs with, which an
+// edit to a queued command replaces.
+func (c *Command) Content() string {
+	if c.Message == nil {
+		return ""
+	}
+	return c.Message.Content
+}
+
+// MessageID returns the ID of the message that triggered the command.
+func (c *Command) MessageID() string {
+	if c.Message == nil {
+		return ""
+	}
+	return c.Message.ID
+}
+
+// TriggerMessage returns the message that triggered the command.
+func (c *Command) TriggerMessage() *discordgo.MessageCreate {
+	return c.Message
+}
+
+// EditedMessage returns a copy of m with new content, leaving m itself untouched.
+func EditedMessage(m *discordgo.MessageCreate, content string) *discordgo.MessageCreate {
+	message := *m.Message
+	message.Content = content
+	return &discordgo.MessageCreate{Message: &message}
+}
+
+// HandleError logs why a command failed and tells its channel, in the words
+// FailureText picks. Tasks that report failures differently override it.
 func (c *Command) HandleError(err error) {
-	c.Session.ChannelMessageSend(c.Message.ChannelID, "Error occurred while processing: "+err.Error())
+	c.Log().Error("command failed: ", err)
+	c.Session.ChannelMessageSend(c.Message.ChannelID, FailureText(c.Session, err, c.TraceID())+TraceFooter(c.TraceID()))
+}
+
+// internalFailureText is what a user is told about a failure that isn't
+// meant for them and isn't a common one.
+const internalFailureText = "Something went wrong on the bot's side, so this didn't work. The admins have the details; mention the trace ID if you ask them about it."
+
+// missingBinaryAlerts keeps a missing tool from alerting the admins on every job.
+var missingBinaryAlerts = &alert.Threshold{Count: 1, Window: time.Hour}
+
+// internalFailureAlerts keeps a run of unexplained failures, e.g. while a
+// backend is down, from flooding the alert channels.
+var internalFailureAlerts = &alert.Threshold{Count: 1, Window: time.Minute}
+
+// FailureText returns what to tell a user about a failed job: a UserError's
+// own message, a plain explanation and fix if the failure is a common one, or
+// else a generic summary, since the error itself can give away file paths and
+// other internals. A generic failure is posted with its raw error and trace ID
+// to the alert channels, and so is a missing tool, which only an admin can fix.
+func FailureText(session *discordgo.Session, err error, traceID string) string {
+	var userErr *UserError
+	if errors.As(err, &userErr) {
+		return userErr.Message
+	}
+	switch triage.Classify(err) {
+	case triage.MissingBinary:
+		if session != nil && missingBinaryAlerts.Hit() {
+			alert.Send(session, config.Get().Admin.AlertChannels, fmt.Sprintf("A job failed because a tool is missing (trace `%s`): %v", traceID, err))
+		}
+	case triage.Unknown:
+		if session != nil && internalFailureAlerts.Hit() {
+			alert.Send(session, config.Get().Admin.AlertChannels, fmt.Sprintf("A job failed unexpectedly (trace `%s`): %v", traceID, err))
+		}
+		return internalFailureText
+	}
+	return triage.Explain(err)
+}
+
+// TraceFooter formats a trace ID for appending to user-facing messages.
+func TraceFooter(traceID string) string {
+	if traceID == "" {
+		return ""
+	}
+	return "\n-# trace: `" + traceID + "`"
 }
 
 type CommandHandler interface {
+	traits.TraceHandler
 	SetContext(s *discordgo.Session, m *discordgo.MessageCreate)
+	SetInput(selector string)
 	Usage() string
 	Validate() error
 	Apply() error
@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailureText(t *testing.T) {
+	// a user error is shown in its own words, however deeply it's wrapped
+	userErr := NewUserError("couldn't download the input audio", errors.New("GET /tmp/cache/x.wav: 403"))
+	require.Equal(t, "couldn't download the input audio", FailureText(nil, fmt.Errorf("job: %w", userErr), "t1"))
+	require.ErrorContains(t, userErr, "403")
+
+	// a common failure is explained
+	require.Contains(t, FailureText(nil, errors.New("CUDA out of memory"), "t1"), "GPU ran out of memory")
+
+	// anything else keeps its details to itself
+	text := FailureText(nil, errors.New("open /srv/slugbot/data/out.wav: permission denied"), "t1")
+	require.Equal(t, internalFailureText, text)
+	require.NotContains(t, text, "/srv")
+}
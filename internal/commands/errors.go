@@ -0,0 +1,32 @@
+package commands
+
+import "fmt"
+
+// UserError is an error meant for the person who ran a command: its Message
+// is shown to them as it is, while its cause, if it has one, only goes to the
+// logs. Any other error a job fails with is summed up without its details.
+type UserError struct {
+	Message string
+	Err     error // optional
+}
+
+func (e *UserError) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *UserError) Unwrap() error {
+	return e.Err
+}
+
+// NewUserError returns an error that tells the user message, caused by err.
+func NewUserError(message string, err error) error {
+	return &UserError{Message: message, Err: err}
+}
+
+// UserErrorf returns an error that tells the user a formatted message.
+func UserErrorf(format string, args ...any) error {
+	return &UserError{Message: fmt.Sprintf(format, args...)}
+}
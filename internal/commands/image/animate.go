@@ -0,0 +1,213 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"slugbot/internal/cmdline"
+	"slugbot/internal/commands"
+	"slugbot/internal/helpers"
+	"slugbot/internal/tools"
+	"slugbot/internal/utils"
+)
+
+const (
+	maxAnimateFrames   = 120
+	defaultAnimateFPS  = 15
+	maxAnimateWorkers  = 8
+	animateFramePrefix = "frame-"
+)
+
+// animatedDistortion describes how an interpolated value maps onto a magick -distort call.
+type animatedDistortion struct {
+	Method string
+	Args   func(t float64) string
+}
+
+// animatedDistortions lists the `.sim` operations that `.sim animate` can sweep.
+// Barrel-style distortions sweep the C coefficient and keep A+B+C+D=1 so the
+// image doesn't change scale between frames.
+var animatedDistortions = map[string]animatedDistortion{
+	"arc":     {Method: "Arc", Args: func(t float64) string { return fmt.Sprintf("%f", t) }},
+	"barrel":  {Method: "Barrel", Args: func(t float64) string { return fmt.Sprintf("0 0 %f %f", t, 1-t) }},
+	"ibarrel": {Method: "BarrelInverse", Args: func(t float64) string { return fmt.Sprintf("0 0 %f %f", t, 1-t) }},
+	"polar":   {Method: "Polar", Args: func(t float64) string { return fmt.Sprintf("%f", t) }},
+	"ipolar":  {Method: "DePolar", Args: func(t float64) string { return fmt.Sprintf("%f", t) }},
+}
+
+// AnimateCommand renders a GIF where a distortion's parameter is interpolated across frames.
+type AnimateCommand struct {
+	commands.Command
+}
+
+type animateParams struct {
+	Distortion animatedDistortion
+	From       float64
+	To         float64
+	Frames     int
+	Format     string // one of helpers.AnimFormats
+}
+
+func (c *AnimateCommand) Usage() string {
+	var names []string
+	for name := range animatedDistortions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("Usage: `.sim animate <%s> <from> <to> <frames> [--anim-format %s]` (up to %d frames; default format: gif)",
+		strings.Join(names, "|"), strings.Join(helpers.AnimFormats, "|"), maxAnimateFrames)
+}
+
+func (c *AnimateCommand) parseArgs() (*animateParams, error) {
+	args, format, err := helpers.SplitAnimFormatFlag(strings.Fields(c.Message.Content))
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 6 || args[1] != "animate" {
+		return nil, errors.New(c.Usage())
+	}
+	distortion, ok := animatedDistortions[args[2]]
+	if !ok {
+		return nil, errors.New(c.Usage())
+	}
+	from, err := strconv.ParseFloat(args[3], 64)
+	if err != nil {
+		return nil, errors.New(c.Usage())
+	}
+	to, err := strconv.ParseFloat(args[4], 64)
+	if err != nil {
+		return nil, errors.New(c.Usage())
+	}
+	frames, err := strconv.Atoi(args[5])
+	if err != nil || frames < 2 || frames > maxAnimateFrames {
+		return nil, errors.New(c.Usage())
+	}
+	return &animateParams{Distortion: distortion, From: from, To: to, Frames: frames, Format: format}, nil
+}
+
+func (c *AnimateCommand) Validate() error {
+	if c.Session == nil {
+		return fmt.Errorf("invalid session reference")
+	}
+	if c.Message == nil {
+		return fmt.Errorf("invalid message reference")
+	}
+
+	_, err := c.parseArgs()
+	return err
+}
+
+func (cmd *AnimateCommand) Apply() error {
+	log := cmd.Log()
+
+	if err := cmd.Validate(); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	params, _ := cmd.parseArgs()
+
+	imageURL, err := helpers.GetImageReference(cmd.Session, cmd.Message, cmd.Input)
+	if err != nil {
+		return fmt.Errorf("error getting image reference: %w", err)
+	}
+	inFile, err := helpers.DownloadImage(imageURL)
+	if err != nil {
+		return fmt.Errorf("error downloading image: %w", err)
+	}
+	defer os.Remove(inFile)
+	if err := helpers.ScanInput(inFile, cmd.Message); err != nil {
+		return err
+	}
+
+	frameDir, err := os.MkdirTemp(utils.TempDir(), "animate-*")
+	if err != nil {
+		return fmt.Errorf("error creating frame directory: %w", err)
+	}
+	defer os.RemoveAll(frameDir)
+
+	log.Info(fmt.Sprintf("Rendering %d frames of %s from %f to %f...", params.Frames, params.Distortion.Method, params.From, params.To))
+
+	watermark, err := helpers.WatermarkOps(cmd.TraceContext(), cmd.Message.GuildID)
+	if err != nil {
+		return err
+	}
+	if err := renderFrames(cmd.Message.GuildID, inFile, frameDir, params, watermark); err != nil {
+		return err
+	}
+
+	format, encoder := helpers.AnimEncoder(params.Format)
+	outFile := filepath.Join(frameDir, "animate."+helpers.AnimExt(format))
+	if err := helpers.AssembleAnimation(cmd.Message.GuildID, filepath.Join(frameDir, animateFramePrefix+"%04d.png"), defaultAnimateFPS, format, encoder, outFile); err != nil {
+		return err
+	}
+	noteAnimFallback(&cmd.Command, params.Format, format)
+
+	log.Trace("Finished assembling animation; uploading image.")
+
+	if err = helpers.UploadImage(cmd.Session, cmd.Message.ChannelID, outFile); err != nil {
+		return fmt.Errorf("error uploading image: %w", err)
+	}
+	return nil
+}
+
+// noteAnimFallback tells the user their animation is a GIF after all, when
+// ffmpeg couldn't make the format they asked for.
+func noteAnimFallback(cmd *commands.Command, requested string, format string) {
+	if format == requested {
+		return
+	}
+	cmd.Session.ChannelMessageSendReply(cmd.Message.ChannelID,
+		fmt.Sprintf("The bot's ffmpeg can't make %s animations, so that's a GIF instead.", requested), cmd.Message.Reference())
+}
+
+// renderFrames runs one magick invocation per frame across a bounded worker
+// pool, each in a render slot.
+// Each invocation gets the guild's resource limits, and stamps the frame with
+// the watermark operators after distorting it. The first failure is returned
+// once all in-flight workers have finished.
+func renderFrames(guildID string, inFile string, frameDir string, params *animateParams, watermark []string) error {
+	workers := min(runtime.NumCPU(), maxAnimateWorkers)
+
+	frames := make(chan int)
+	errs := make(chan error, params.Frames)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range frames {
+				t := params.From + (params.To-params.From)*float64(i)/float64(params.Frames-1)
+				args := []string{
+					cmdline.MagickInput(inFile) + "[0]",
+					"-distort",
+					params.Distortion.Method,
+					params.Distortion.Args(t),
+				}
+				args = append(append(args, watermark...), filepath.Join(frameDir, fmt.Sprintf("%s%04d.png", animateFramePrefix, i)))
+				command := exec.Command(tools.Path(tools.Magick), helpers.MagickArgs(guildID, args...)...)
+				release := helpers.RenderSlot()
+				out, err := command.CombinedOutput()
+				release()
+				if err != nil {
+					errs <- fmt.Errorf("failed to render frame %d: %w\nOutput: %s", i, err, string(out))
+				}
+			}
+		}()
+	}
+
+	for i := range params.Frames {
+		frames <- i
+	}
+	close(frames)
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
@@ -0,0 +1,64 @@
+package image
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"slugbot/internal/cmdline"
+	"slugbot/internal/helpers"
+	"slugbot/internal/presets"
+)
+
+// distortArgs builds the magick arguments that apply one -distort to an image,
+// within the guild's resource limits.
+func distortArgs(guildID string, inFile string, method string, values []float64, outFile string) []string {
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = fmt.Sprintf("%f", value)
+	}
+	return helpers.MagickArgs(guildID, cmdline.New(cmdline.MagickInput(inFile)).
+		Pair("-distort", method).
+		Trusted(strings.Join(formatted, " ")).
+		Path(outFile).
+		Args()...)
+}
+
+// presetArgs builds the magick arguments that run a preset's operators on an
+// image, within the guild's resource limits. Presets are validated when
+// they're saved, but config presets aren't, so no value that could refer to
+// a file gets through here either.
+func presetArgs(guildID string, inFile string, preset *presets.ImagePreset, outFile string) ([]string, error) {
+	args := cmdline.New(cmdline.MagickInput(inFile))
+	for _, arg := range preset.Args {
+		if err := cmdline.CheckMagickValue(arg); err != nil {
+			return nil, fmt.Errorf("preset '%s': %w", preset.Name, err)
+		}
+		args.Trusted(arg)
+	}
+	return helpers.MagickArgs(guildID, args.Path(outFile).Args()...), nil
+}
+
+// colorRegex matches the colours commands take: "#rgb" to "#rrggbbaa" hex, or
+// a name such as "teal". Nothing else gets to magick, so a colour can't name
+// a file.
+var colorRegex = regexp.MustCompile(`^(#([0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})|[a-zA-Z]{3,20})$`)
+
+// checkColor returns an error for the user if color isn't one colorRegex matches.
+func checkColor(color string) error {
+	if !colorRegex.MatchString(color) {
+		return fmt.Errorf("'%s' isn't a colour; use a name like `teal` or hex like `#ff8800`", color)
+	}
+	return nil
+}
+
+// commandText returns what follows the command word of a message, e.g. the
+// text of `.sqr some text`, with its spacing kept.
+func commandText(content string) string {
+	content = strings.TrimSpace(content)
+	if i := strings.IndexFunc(content, unicode.IsSpace); i >= 0 {
+		return strings.TrimSpace(content[i:])
+	}
+	return ""
+}
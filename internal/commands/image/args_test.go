@@ -0,0 +1,111 @@
+package image
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"slugbot/internal/config"
+	"slugbot/internal/presets"
+
+	"github.com/stretchr/testify/require"
+)
+
+func noLimits(t *testing.T) {
+	cfg := config.Default()
+	cfg.Limits = config.Limits{}
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+}
+
+func FuzzDistortArgs_ValuesStayInPlace(f *testing.F) {
+	f.Add(0.2, -1.0)
+	f.Add(math.Inf(-1), math.NaN())
+	f.Fuzz(func(t *testing.T, a float64, b float64) {
+		noLimits(t)
+		args := distortArgs("", "/tmp/in-1.png", "Barrel", []float64{a, b}, "/tmp/out-1.png")
+
+		require.Equal(t, []string{"png:/tmp/in-1.png", "-distort", "Barrel"}, args[:3])
+		require.Len(t, strings.Fields(args[3]), 2)
+		require.Equal(t, "/tmp/out-1.png", args[4])
+		require.Len(t, args, 5)
+	})
+}
+
+func FuzzPresetArgs_NoValueNamesAFile(f *testing.F) {
+	f.Add("-blur", "0x6")
+	f.Add("-fill", "text:/etc/passwd")
+	f.Add("-write", "@list")
+	f.Fuzz(func(t *testing.T, operator string, value string) {
+		noLimits(t)
+		preset := &presets.ImagePreset{Name: "fuzz", Args: []string{operator, value}}
+		args, err := presetArgs("", "/tmp/in-1.png", preset, "/tmp/out-1.png")
+		if err != nil {
+			return
+		}
+
+		require.Equal(t, "png:/tmp/in-1.png", args[0])
+		require.Equal(t, "/tmp/out-1.png", args[len(args)-1])
+		for _, arg := range args[1 : len(args)-1] {
+			require.False(t, strings.HasPrefix(arg, "@") || strings.ContainsAny(arg, `:/\`), arg)
+		}
+	})
+}
+
+func TestFilterLooks_KeepValuesInPlace(t *testing.T) {
+	noLimits(t)
+	for name, look := range filterLooks {
+		args := make([]string, len(look.Params))
+		for i := range args {
+			args[i] = "teal"
+		}
+		ops, err := look.Ops(args)
+		require.NoError(t, err, name)
+		got := filterArgs("", "/tmp/in-1.png", ops, "/tmp/out-1.png")
+		require.Equal(t, "png:/tmp/in-1.png", got[0], name)
+		require.Equal(t, "/tmp/out-1.png", got[len(got)-1], name)
+	}
+
+	ops, err := filterLooks["duotone"].Ops([]string{"#102040", "Orange"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"-colorspace", "Gray", "+level-colors", "#102040,Orange"}, ops)
+	for _, color := range []string{"text:/etc/passwd", "@list", "red,blue", "#12345", "rgb(1,2,3)"} {
+		_, err := filterLooks["duotone"].Ops([]string{color, "white"})
+		require.Error(t, err, color)
+	}
+}
+
+func TestTileArgs(t *testing.T) {
+	noLimits(t)
+	require.Equal(t,
+		[]string{"png:/tmp/in-1.png[0]", "-resize", "1024x1024>", "-virtual-pixel", "tile", "-fx", tileBlend, "/tmp/tile.png"},
+		tileArgs("", "/tmp/in-1.png", "/tmp/tile.png"))
+	require.Equal(t,
+		[]string{"png:/tmp/tile.png", "+clone", "+append", "+clone", "-append", "/tmp/tile-preview.png"},
+		tilePreviewArgs("", "/tmp/tile.png", "/tmp/tile-preview.png"))
+}
+
+func TestParseTextArgs(t *testing.T) {
+	params, err := parseTextArgs(strings.Fields("big --color teal news --size 96 --bg none today"))
+	require.NoError(t, err)
+	require.Equal(t, &textParams{Text: "big news today", Color: "teal", Background: "none", Size: 96}, params)
+
+	for _, bad := range []string{"--color", "hi --font /etc/fonts/x.ttf", "hi --color red,blue", "hi --size 9000", "hi --shadow on", "--size 20"} {
+		_, err := parseTextArgs(strings.Fields(bad))
+		require.Error(t, err, bad)
+	}
+}
+
+func TestTextArgs_TextStaysText(t *testing.T) {
+	noLimits(t)
+	params := &textParams{Text: "@/etc/passwd 100%", Font: "DejaVu-Sans", Color: "black", Background: "white", Size: 48}
+	require.Equal(t,
+		[]string{"-background", "white", "-fill", "black", "-pointsize", "48", "-font", "DejaVu-Sans", `label:\@/etc/passwd 100%%`, "-bordercolor", "white", "-border", "16", "/tmp/text-1.png"},
+		textArgs("", params, "/tmp/text-1.png"))
+}
+
+func TestCommandText(t *testing.T) {
+	require.Equal(t, "https://example.com/a  b", commandText(".sqr   https://example.com/a  b "))
+	require.Equal(t, "", commandText(".sqr"))
+	require.Equal(t, []string{"-s", "8", "-m", "2", "-l", "M", "-o", "/tmp/qr-1.png"}, qrArgs("/tmp/qr-1.png"))
+}
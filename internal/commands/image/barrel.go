@@ -3,12 +3,10 @@ package image
 import (
 	"errors"
 	"fmt"
-	"os/exec"
 	"strconv"
 	"strings"
 
 	"slugbot/internal/commands"
-	"slugbot/internal/helpers"
 )
 
 type BarrelDistortCommand struct {
@@ -47,8 +45,16 @@ func (c *BarrelDistortCommand) Validate() error {
 }
 
 func (cmd *BarrelDistortCommand) Apply() error {
+	op, err := cmd.Operation()
+	if err != nil {
+		return err
+	}
+	return applyOperation(&cmd.Command, op)
+}
+
+func (cmd *BarrelDistortCommand) Operation() (Operation, error) {
 	if err := cmd.Validate(); err != nil {
-		return fmt.Errorf("validation failed: %w", err)
+		return Operation{}, fmt.Errorf("validation failed: %w", err)
 	}
 
 	args := strings.Fields(cmd.Message.Content)
@@ -57,28 +63,5 @@ func (cmd *BarrelDistortCommand) Apply() error {
 	c, _ := strconv.ParseFloat(args[4], 64)
 	d, _ := strconv.ParseFloat(args[5], 64)
 
-	inFile, outFile, cleanup, err := helpers.PrepareImageFiles(cmd.Session, cmd.Message)
-	if err != nil {
-		return err
-	}
-	defer cleanup()
-
-	command := exec.Command(
-		"magick",
-		inFile,
-		"-distort",
-		"Barrel",
-		fmt.Sprintf("%f %f %f %f", a, b, c, d),
-		outFile,
-	)
-	fmt.Println("Running command:", strings.Join(command.Args, " "))
-	if out, err := command.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to run command on image: %w\nOutput: %s", err, string(out))
-	}
-
-	if err = helpers.UploadImage(cmd.Session, cmd.Message.ChannelID, outFile); err != nil {
-		return fmt.Errorf("error uploading image: %w", err)
-	}
-
-	return nil
+	return distortOperation(cmd.Message.GuildID, "Barrel", []float64{a, b, c, d}), nil
 }
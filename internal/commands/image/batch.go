@@ -0,0 +1,207 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/helpers"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/tools"
+	"slugbot/internal/utils"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Operation renders one image into another.
+type Operation struct {
+	Render func(inFile, outFile string) error
+	Format string // the output's extension, e.g. "png"; "" keeps the input's
+}
+
+// Batchable commands do one Operation per image, so `--batch` can run them
+// over every image in a zip.
+type Batchable interface {
+	commands.CommandHandler
+	// Operation validates the command and returns what it does to an image.
+	Operation() (Operation, error)
+}
+
+// magickOperation renders an image by running magick with the arguments args
+// builds for it.
+func magickOperation(args func(inFile, outFile string) ([]string, error)) func(inFile, outFile string) error {
+	return func(inFile, outFile string) error {
+		commandArgs, err := args(inFile, outFile)
+		if err != nil {
+			return err
+		}
+		command := exec.Command(tools.Path(tools.Magick), commandArgs...)
+		slog.Trace("Running command: ", strings.Join(command.Args, " "))
+		defer helpers.RenderSlot()()
+		if out, err := command.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to run command on image: %w\nOutput: %s", err, string(out))
+		}
+		return nil
+	}
+}
+
+// distortOperation renders an image with one -distort; see distortArgs.
+func distortOperation(guildID string, method string, values []float64) Operation {
+	return Operation{Render: magickOperation(func(inFile, outFile string) ([]string, error) {
+		return distortArgs(guildID, inFile, method, values, outFile), nil
+	})}
+}
+
+// applyOperation runs op on the command's input image and uploads the result,
+// watermarked.
+func applyOperation(cmd *commands.Command, op Operation) error {
+	inFile, outFile, cleanup, err := helpers.PrepareImageFiles(cmd.Session, cmd.Message, cmd.Input)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if op.Format != "" {
+		outTmp, err := os.CreateTemp(utils.TempDir(), "out-*."+op.Format)
+		if err != nil {
+			return fmt.Errorf("error creating output file: %w", err)
+		}
+		outTmp.Close()
+		outFile = outTmp.Name()
+		defer os.Remove(outFile)
+	}
+
+	if err := op.Render(inFile, outFile); err != nil {
+		return err
+	}
+	if err := helpers.Watermark(cmd.TraceContext(), cmd.Message.GuildID, outFile); err != nil {
+		return err
+	}
+	if err = helpers.UploadImage(cmd.Session, cmd.Message.ChannelID, outFile); err != nil {
+		return fmt.Errorf("error uploading image: %w", err)
+	}
+	return nil
+}
+
+// BatchCommand runs an image operation over every image in an attached zip,
+// e.g. `.sim polar 30 --batch`, and replies with a zip of the results. Like
+// the operation on its own, it runs right away, rather than in the queue, but
+// each image waits for a render slot like any other render.
+type BatchCommand struct {
+	commands.Command
+	Op     Batchable
+	Limits helpers.ArchiveLimits
+}
+
+func (c *BatchCommand) Usage() string {
+	return "Usage: `.sim <operation> <arguments> --batch`, with a .zip of images attached or in the message replied to; each image gets the operation, and the results come back as a .zip"
+}
+
+func (c *BatchCommand) Validate() error {
+	if c.Session == nil {
+		return fmt.Errorf("invalid session reference")
+	}
+	if c.Message == nil {
+		return fmt.Errorf("invalid message reference")
+	}
+	if c.Op == nil {
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+func (c *BatchCommand) Apply() error {
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	op, err := c.Op.Operation()
+	if err != nil {
+		return err
+	}
+
+	input, err := helpers.ResolveInput(c.Session, c.Message, helpers.MessageArchives, c.Input)
+	if errors.Is(err, helpers.ErrNoInput) {
+		return commands.NewUserError("`--batch` needs a .zip of images attached, or in the message you reply to", err)
+	}
+	if err != nil {
+		return err
+	}
+	zipPath, err := helpers.DownloadFile(input.URL, "batch-*.zip")
+	if err != nil {
+		return commands.NewUserError("couldn't download the .zip; check that its link still works", err)
+	}
+	defer os.Remove(zipPath)
+	if err := helpers.ScanInput(zipPath, c.Message); err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp(utils.TempDir(), "batch-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	images, err := helpers.ExtractImages(zipPath, filepath.Join(dir, "in"), c.Limits)
+	if errors.Is(err, helpers.ErrArchiveTooBig) || errors.Is(err, helpers.ErrUnsafeArchive) {
+		return commands.UserErrorf("Sorry, couldn't use `%s`: %v.", input.Name, err)
+	}
+	if err != nil {
+		return commands.NewUserError(fmt.Sprintf("couldn't read `%s` as a .zip", input.Name), err)
+	}
+	if len(images) == 0 {
+		return commands.UserErrorf("There are no images in `%s`.", input.Name)
+	}
+
+	outDir := filepath.Join(dir, "out")
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+	var results, failed []string
+	for _, image := range images {
+		name := filepath.Base(image)
+		if op.Format != "" {
+			name = strings.TrimSuffix(name, filepath.Ext(name)) + "." + op.Format
+		}
+		outFile := filepath.Join(outDir, name)
+		err := op.Render(image, outFile)
+		if err == nil {
+			err = helpers.Watermark(c.TraceContext(), c.Message.GuildID, outFile)
+		}
+		if err != nil {
+			c.Log().Warn("couldn't process ", filepath.Base(image), " in a batch: ", err)
+			failed = append(failed, "`"+filepath.Base(image)+"`")
+			continue
+		}
+		results = append(results, outFile)
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("none of the %d images in the batch could be processed", len(images))
+	}
+
+	resultsPath := filepath.Join(dir, strings.TrimSuffix(input.Name, filepath.Ext(input.Name))+"-results.zip")
+	if err := helpers.WriteZip(resultsPath, results); err != nil {
+		return fmt.Errorf("couldn't package the results: %w", err)
+	}
+	file, err := os.Open(resultsPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reply := fmt.Sprintf("Processed %d image(s).", len(results))
+	if len(failed) > 0 {
+		reply = fmt.Sprintf("Processed %d of %d images; couldn't process %s.", len(results), len(images), strings.Join(failed, ", "))
+	}
+	_, err = c.Session.ChannelMessageSendComplex(c.Message.ChannelID, &discordgo.MessageSend{
+		Content:   reply,
+		Files:     []*discordgo.File{{Name: filepath.Base(resultsPath), ContentType: "application/zip", Reader: file}},
+		Reference: c.Message.Reference(),
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading results: %w", err)
+	}
+	return nil
+}
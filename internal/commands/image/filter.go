@@ -0,0 +1,128 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"slugbot/internal/cmdline"
+	"slugbot/internal/commands"
+	"slugbot/internal/helpers"
+)
+
+// filterLook is a named colour grade: a magick operator chain, built from the
+// look's arguments, if it takes any.
+type filterLook struct {
+	Params []string // names of the arguments, for the usage
+	Ops    func(args []string) ([]string, error)
+	Format string // the output's extension, as in Operation
+}
+
+// filterLooks lists the looks `.sim filter` applies.
+var filterLooks = map[string]filterLook{
+	"sepia": {Ops: fixedOps("-sepia-tone", "80%")},
+	"vhs": {Ops: fixedOps(
+		"-blur", "0x0.8",
+		"-modulate", "105,140,100",
+		"-attenuate", "0.4", "+noise", "Gaussian",
+		"-fill", "#102040", "-colorize", "8%",
+	)},
+	// the saturation and sharpening are cranked up, then a terrible JPEG does the rest
+	"deepfry": {Ops: fixedOps(
+		"-modulate", "120,250,100",
+		"-sigmoidal-contrast", "6x50%",
+		"-unsharp", "0x4+2+0",
+		"-quality", "6",
+	), Format: "jpg"},
+	"posterize": {Ops: fixedOps("-posterize", "4")},
+	"invert":    {Ops: fixedOps("-negate")},
+	// shadows take the first colour and highlights the second
+	"duotone": {Params: []string{"<color1>", "<color2>"}, Ops: func(args []string) ([]string, error) {
+		for _, color := range args {
+			if err := checkColor(color); err != nil {
+				return nil, err
+			}
+		}
+		return []string{"-colorspace", "Gray", "+level-colors", args[0] + "," + args[1]}, nil
+	}},
+}
+
+func fixedOps(ops ...string) func([]string) ([]string, error) {
+	return func([]string) ([]string, error) { return ops, nil }
+}
+
+// filterUsages lists the looks with their arguments, e.g. "duotone <color1> <color2>".
+func filterUsages() string {
+	names := make([]string, 0, len(filterLooks))
+	for name, look := range filterLooks {
+		names = append(names, strings.Join(append([]string{name}, look.Params...), " "))
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+// filterArgs builds the magick arguments that grade an image with a look,
+// within the guild's resource limits.
+func filterArgs(guildID string, inFile string, ops []string, outFile string) []string {
+	return helpers.MagickArgs(guildID, cmdline.New(cmdline.MagickInput(inFile)).
+		Trusted(ops...).
+		Path(outFile).
+		Args()...)
+}
+
+// FilterCommand colour-grades an image with one of the filterLooks.
+type FilterCommand struct {
+	commands.Command
+}
+
+func (c *FilterCommand) Usage() string {
+	return "Usage: `.sim filter <look>`; looks: " + filterUsages()
+}
+
+func (c *FilterCommand) Validate() error {
+	if c.Session == nil {
+		return fmt.Errorf("invalid session reference")
+	}
+	if c.Message == nil {
+		return fmt.Errorf("invalid message reference")
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if len(args) < 3 || args[1] != "filter" {
+		return errors.New(c.Usage())
+	}
+	look, ok := filterLooks[strings.ToLower(args[2])]
+	if !ok || len(args) != 3+len(look.Params) {
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+func (cmd *FilterCommand) Apply() error {
+	op, err := cmd.Operation()
+	if err != nil {
+		return err
+	}
+	return applyOperation(&cmd.Command, op)
+}
+
+func (cmd *FilterCommand) Operation() (Operation, error) {
+	if err := cmd.Validate(); err != nil {
+		return Operation{}, fmt.Errorf("validation failed: %w", err)
+	}
+
+	args := strings.Fields(cmd.Message.Content)
+	look := filterLooks[strings.ToLower(args[2])]
+	ops, err := look.Ops(args[3:])
+	if err != nil {
+		return Operation{}, commands.UserErrorf("%v.", err)
+	}
+
+	return Operation{
+		Render: magickOperation(func(inFile, outFile string) ([]string, error) {
+			return filterArgs(cmd.Message.GuildID, inFile, ops, outFile), nil
+		}),
+		Format: look.Format,
+	}, nil
+}
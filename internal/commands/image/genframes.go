@@ -10,7 +10,13 @@ import (
 
 	"slugbot/internal/commands"
 	"slugbot/internal/helpers"
-	"slugbot/internal/io/slog"
+	"slugbot/internal/tools"
+	"slugbot/internal/utils"
+)
+
+const (
+	defaultGenFramesFPS = 10
+	maxGenFramesFPS     = 50 // GIF frame delays are in centiseconds, so anything faster gets clamped by viewers
 )
 
 // GenFramesCommand creates an animation where each frame is the input image.
@@ -19,7 +25,39 @@ type GenFramesCommand struct {
 }
 
 func (c *GenFramesCommand) Usage() string {
-	return "Usage: `.sim genframes <num_frames>`"
+	return fmt.Sprintf("Usage: `.sim genframes <num_frames> [--fps <1-%d>] [--anim-format %s]` (default fps: %d; default format: gif)",
+		maxGenFramesFPS, strings.Join(helpers.AnimFormats, "|"), defaultGenFramesFPS)
+}
+
+// parseArgs reads the frame count and the optional --fps and --anim-format
+// flags from `.sim genframes ...`.
+func (c *GenFramesCommand) parseArgs() (frameCount int, fps int, format string, err error) {
+	args, format, err := helpers.SplitAnimFormatFlag(strings.Fields(c.Message.Content))
+	if err != nil {
+		return 0, 0, "", err
+	}
+	if len(args) != 3 && len(args) != 5 {
+		return 0, 0, "", errors.New(c.Usage())
+	}
+	if args[1] != "genframes" {
+		return 0, 0, "", errors.New(c.Usage())
+	}
+	frameCount, err = strconv.Atoi(args[2])
+	if err != nil || frameCount < 1 {
+		return 0, 0, "", errors.New(c.Usage())
+	}
+
+	fps = defaultGenFramesFPS
+	if len(args) == 5 {
+		if args[3] != "--fps" {
+			return 0, 0, "", errors.New(c.Usage())
+		}
+		fps, err = strconv.Atoi(args[4])
+		if err != nil || fps < 1 || fps > maxGenFramesFPS {
+			return 0, 0, "", errors.New(c.Usage())
+		}
+	}
+	return frameCount, fps, format, nil
 }
 
 func (c *GenFramesCommand) Validate() error {
@@ -30,29 +68,20 @@ func (c *GenFramesCommand) Validate() error {
 		return fmt.Errorf("invalid message reference")
 	}
 
-	args := strings.Fields(c.Message.Content)
-	if len(args) != 3 {
-		return errors.New(c.Usage())
-	}
-	if args[1] != "genframes" {
-		return errors.New(c.Usage())
-	}
-	n, err := strconv.Atoi(args[2])
-	if err != nil || n < 1 {
-		return errors.New(c.Usage())
-	}
-	return nil
+	_, _, _, err := c.parseArgs()
+	return err
 }
 
 func (cmd *GenFramesCommand) Apply() error {
+	log := cmd.Log()
+
 	if err := cmd.Validate(); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
-	args := strings.Fields(cmd.Message.Content)
-	frameCount, _ := strconv.Atoi(args[2])
+	frameCount, fps, requested, _ := cmd.parseArgs()
 
-	imageURL, err := helpers.GetImageReference(cmd.Session, cmd.Message)
+	imageURL, err := helpers.GetImageReference(cmd.Session, cmd.Message, cmd.Input)
 	if err != nil {
 		return fmt.Errorf("error getting image reference: %w", err)
 	}
@@ -61,19 +90,21 @@ func (cmd *GenFramesCommand) Apply() error {
 	if err != nil {
 		return fmt.Errorf("error downloading image: %w", err)
 	}
+	if err := helpers.ScanInput(inFile, cmd.Message); err != nil {
+		os.Remove(inFile)
+		return err
+	}
 
-	paletteTmp, err := os.CreateTemp("", "palette-*.png")
-	if err != nil {
+	// every frame is the input, so it's watermarked once, up front
+	if err := helpers.Watermark(cmd.TraceContext(), cmd.Message.GuildID, inFile); err != nil {
 		os.Remove(inFile)
-		return fmt.Errorf("error creating palette file: %w", err)
+		return err
 	}
-	paletteTmp.Close()
-	paletteFile := paletteTmp.Name()
 
-	outTmp, err := os.CreateTemp("", "out-*.gif")
+	format, encoder := helpers.AnimEncoder(requested)
+	outTmp, err := os.CreateTemp(utils.TempDir(), "out-*."+helpers.AnimExt(format))
 	if err != nil {
 		os.Remove(inFile)
-		os.Remove(paletteFile)
 		return fmt.Errorf("error creating output file: %w", err)
 	}
 	outTmp.Close()
@@ -81,52 +112,35 @@ func (cmd *GenFramesCommand) Apply() error {
 
 	cleanup := func() {
 		os.Remove(inFile)
-		os.Remove(paletteFile)
 		os.Remove(outFile)
 	}
 	defer cleanup()
 
-	slog.Info("Running palette generation for the input file...")
+	log.Info(fmt.Sprintf("Duplicating image %s for %d frames at %d fps...", inFile, frameCount, fps))
 
-	paletteGenCommand := exec.Command(
-		"ffmpeg",
-		"-i", inFile,
-		"-vf", "palettegen",
-		"-y", paletteFile,
-	)
+	// the trim gives palettegen an end-of-stream to wait for despite the endless loop input
+	args := []string{"-stream_loop", "-1", "-i", inFile}
+	args = append(args, helpers.AnimArgs(format, encoder, fmt.Sprintf("fps=%d,trim=end_frame=%d", fps, frameCount))...)
+	args = append(args, "-frames:v", fmt.Sprintf("%d", frameCount), "-y", outFile)
+	command := exec.Command(tools.Path(tools.FFmpeg), helpers.FFmpegArgs(cmd.Message.GuildID, args...)...)
 
-	slog.Trace(fmt.Sprintf("Running command: %s", strings.Join(paletteGenCommand.Args, " ")))
+	log.Trace(fmt.Sprintf("Running command: %s", strings.Join(command.Args, " ")))
 
-	if out, err := paletteGenCommand.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to generate palette on image: %w\nOutput: %s", err, string(out))
-	}
-
-	slog.Info(fmt.Sprintf("Duplicating image %s for %d frames...", inFile, frameCount))
-
-	command := exec.Command(
-		"ffmpeg",
-		"-stream_loop", "-1",
-		"-i", inFile,
-		"-i", paletteFile,
-		"-frames:v", fmt.Sprintf("%d", frameCount),
-		"-filter_complex", fmt.Sprintf("[0:v]fps=%d[x];[x][1:v]paletteuse=dither=floyd_steinberg", frameCount),
-		"-loop", "0",
-		"-y", outFile,
-	)
-
-	slog.Trace(fmt.Sprintf("Running command: %s", strings.Join(command.Args, " ")))
-
-	if out, err := command.CombinedOutput(); err != nil {
+	release := helpers.RenderSlot()
+	out, err := command.CombinedOutput()
+	release()
+	if err != nil {
 		return fmt.Errorf("failed to run command on image: %w\nOutput: %s", err, string(out))
 	}
 
-	slog.Trace("Finished running command; uploading image.")
+	log.Trace("Finished running command; uploading image.")
 
 	if err = helpers.UploadImage(cmd.Session, cmd.Message.ChannelID, outFile); err != nil {
 		return fmt.Errorf("error uploading image: %w", err)
 	}
 
-	slog.Trace("Finished uploading image.")
+	log.Trace("Finished uploading image.")
+	noteAnimFallback(&cmd.Command, requested, format)
 
 	return nil
 }
@@ -3,12 +3,10 @@ package image
 import (
 	"errors"
 	"fmt"
-	"os/exec"
 	"strconv"
 	"strings"
 
 	"slugbot/internal/commands"
-	"slugbot/internal/helpers"
 )
 
 type InversePolarDistortCommand struct {
@@ -45,35 +43,20 @@ func (c *InversePolarDistortCommand) Validate() error {
 }
 
 func (cmd *InversePolarDistortCommand) Apply() error {
-	if err := cmd.Validate(); err != nil {
-		return fmt.Errorf("validation failed: %w", err)
-	}
-
-	args := strings.Fields(cmd.Message.Content)
-	theta, _ := strconv.ParseFloat(args[2], 64)
-
-	inFile, outFile, cleanup, err := helpers.PrepareImageFiles(cmd.Session, cmd.Message)
+	op, err := cmd.Operation()
 	if err != nil {
 		return err
 	}
-	defer cleanup()
+	return applyOperation(&cmd.Command, op)
+}
 
-	command := exec.Command(
-		"magick",
-		inFile,
-		"-distort",
-		"DePolar",
-		fmt.Sprintf("%f", theta),
-		outFile,
-	)
-	fmt.Println("Running command:", strings.Join(command.Args, " "))
-	if out, err := command.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to run command on image: %w\nOutput: %s", err, string(out))
+func (cmd *InversePolarDistortCommand) Operation() (Operation, error) {
+	if err := cmd.Validate(); err != nil {
+		return Operation{}, fmt.Errorf("validation failed: %w", err)
 	}
 
-	if err = helpers.UploadImage(cmd.Session, cmd.Message.ChannelID, outFile); err != nil {
-		return fmt.Errorf("error uploading image: %w", err)
-	}
+	args := strings.Fields(cmd.Message.Content)
+	theta, _ := strconv.ParseFloat(args[2], 64)
 
-	return nil
+	return distortOperation(cmd.Message.GuildID, "DePolar", []float64{theta}), nil
 }
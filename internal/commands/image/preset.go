@@ -0,0 +1,96 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/discord"
+	"slugbot/internal/presets"
+)
+
+// PresetCommand applies a named image pipeline (built-in, from config, or guild-defined).
+type PresetCommand struct {
+	commands.Command
+	Presets *presets.Catalog
+	Pages   *discord.Paginator // optional; lists presets a page at a time
+}
+
+func (c *PresetCommand) Usage() string {
+	return "Usage: `.sim preset <name>` (or `.sim preset` to list presets)"
+}
+
+func (c *PresetCommand) Validate() error {
+	if c.Session == nil {
+		return fmt.Errorf("invalid session reference")
+	}
+	if c.Message == nil {
+		return fmt.Errorf("invalid message reference")
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if len(args) < 2 || len(args) > 3 || args[1] != "preset" {
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+func (cmd *PresetCommand) Apply() error {
+	if err := cmd.Validate(); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	args := strings.Fields(cmd.Message.Content)
+	if len(args) == 2 {
+		names, err := cmd.Presets.ImageNames(cmd.Message.GuildID)
+		if err != nil {
+			return err
+		}
+		if cmd.Pages != nil {
+			lines := make([]string, len(names))
+			for i, name := range names {
+				lines[i] = "`" + name + "`"
+			}
+			_, err = cmd.Pages.Send(cmd.Session, cmd.Message.ChannelID, cmd.Message.Reference(), "Available presets", lines)
+			return err
+		}
+		_, err = cmd.Session.ChannelMessageSend(cmd.Message.ChannelID, "Available presets: `"+strings.Join(names, "`, `")+"`")
+		return err
+	}
+
+	op, err := cmd.Operation()
+	if err != nil {
+		return err
+	}
+	return applyOperation(&cmd.Command, op)
+}
+
+// Operation runs the named preset; it needs a preset name, unlike a bare
+// `.sim preset`, which lists them.
+func (cmd *PresetCommand) Operation() (Operation, error) {
+	if err := cmd.Validate(); err != nil {
+		return Operation{}, fmt.Errorf("validation failed: %w", err)
+	}
+	args := strings.Fields(cmd.Message.Content)
+	if len(args) < 3 {
+		return Operation{}, errors.New(cmd.Usage())
+	}
+
+	preset, err := cmd.Presets.Image(cmd.Message.GuildID, args[2])
+	if err != nil {
+		return Operation{}, err
+	}
+	render := magickOperation(func(inFile, outFile string) ([]string, error) {
+		return presetArgs(cmd.Message.GuildID, inFile, preset, outFile)
+	})
+	return Operation{
+		Render: func(inFile, outFile string) error {
+			if err := render(inFile, outFile); err != nil {
+				return fmt.Errorf("preset '%s': %w", preset.Name, err)
+			}
+			return nil
+		},
+		Format: preset.Format,
+	}, nil
+}
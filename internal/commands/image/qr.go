@@ -0,0 +1,81 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+
+	"slugbot/internal/cmdline"
+	"slugbot/internal/commands"
+	"slugbot/internal/helpers"
+	"slugbot/internal/tools"
+	"slugbot/internal/utils"
+)
+
+// maxQRText caps what `.sqr` encodes; a code holding much more is too dense
+// to scan off a screen.
+const maxQRText = 1000
+
+// qrArgs builds the qrencode arguments that draw the text it reads from stdin
+// as a PNG QR code, with medium error correction and a narrow quiet zone.
+func qrArgs(outFile string) []string {
+	return cmdline.New("-s", "8", "-m", "2", "-l", "M", "-o").Path(outFile).Args()
+}
+
+// QRCommand draws text, such as a link, as a QR code.
+type QRCommand struct {
+	commands.Command
+}
+
+func (c *QRCommand) Usage() string {
+	return "Usage: `.sqr <text>`, e.g. `.sqr https://example.com`"
+}
+
+func (c *QRCommand) Validate() error {
+	if c.Session == nil {
+		return fmt.Errorf("invalid session reference")
+	}
+	if c.Message == nil {
+		return fmt.Errorf("invalid message reference")
+	}
+	if commandText(c.Message.Content) == "" {
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+func (cmd *QRCommand) Apply() error {
+	if err := cmd.Validate(); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	text := commandText(cmd.Message.Content)
+	if utf8.RuneCountInString(text) > maxQRText {
+		return commands.UserErrorf("Sorry, a QR code can hold at most %d characters here.", maxQRText)
+	}
+
+	outTmp, err := os.CreateTemp(utils.TempDir(), "qr-*.png")
+	if err != nil {
+		return fmt.Errorf("error creating output file: %w", err)
+	}
+	outTmp.Close()
+	outFile := outTmp.Name()
+	defer os.Remove(outFile)
+
+	// the text goes in on stdin, so nothing in it can be read as an option
+	command := exec.Command(tools.Path(tools.QREncode), qrArgs(outFile)...)
+	command.Stdin = strings.NewReader(text)
+	cmd.Log().Trace("Running command: ", strings.Join(command.Args, " "))
+	if out, err := command.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to draw QR code: %w\nOutput: %s", err, string(out))
+	}
+
+	// unlike other results, a QR code isn't watermarked: a logo over its
+	// corner could keep it from scanning
+	if err = helpers.UploadImage(cmd.Session, cmd.Message.ChannelID, outFile); err != nil {
+		return fmt.Errorf("error uploading image: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"slugbot/internal/cmdline"
+	"slugbot/internal/commands"
+	"slugbot/internal/helpers"
+	"slugbot/internal/utils"
+)
+
+const (
+	maxTextLength = 500
+	minTextSize   = 8
+	maxTextSize   = 200
+)
+
+// fontNameRegex matches the font names `.stext --font` takes, e.g.
+// "DejaVu-Sans-Bold", as magick lists them with `-list font`; a path
+// wouldn't match, so no file can be named.
+var fontNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]{0,63}$`)
+
+// textParams are how `.stext` draws its text.
+type textParams struct {
+	Text       string
+	Font       string // "" for magick's default
+	Color      string
+	Background string
+	Size       int // in points
+}
+
+// parseTextArgs parses the words after `.stext`: the text, with --font,
+// --color, --bg, or --size flags anywhere among them.
+func parseTextArgs(words []string) (*textParams, error) {
+	params := &textParams{Color: "black", Background: "white", Size: 48}
+	var text []string
+	for i := 0; i < len(words); i++ {
+		flag := words[i]
+		if !strings.HasPrefix(flag, "--") {
+			text = append(text, flag)
+			continue
+		}
+		if i+1 >= len(words) {
+			return nil, fmt.Errorf("`%s` needs a value", flag)
+		}
+		i++
+		value := words[i]
+		switch flag {
+		case "--font":
+			if !fontNameRegex.MatchString(value) {
+				return nil, fmt.Errorf("'%s' isn't a font name, like `DejaVu-Sans-Bold`", value)
+			}
+			params.Font = value
+		case "--color":
+			if err := checkColor(value); err != nil {
+				return nil, err
+			}
+			params.Color = value
+		case "--bg":
+			if err := checkColor(value); err != nil {
+				return nil, err
+			}
+			params.Background = value
+		case "--size":
+			size, err := strconv.Atoi(value)
+			if err != nil || size < minTextSize || size > maxTextSize {
+				return nil, fmt.Errorf("`--size` must be a whole number from %d to %d", minTextSize, maxTextSize)
+			}
+			params.Size = size
+		default:
+			return nil, fmt.Errorf("unknown flag `%s`", flag)
+		}
+	}
+
+	params.Text = strings.Join(text, " ")
+	if params.Text == "" {
+		return nil, errors.New("there's no text to draw")
+	}
+	if utf8.RuneCountInString(params.Text) > maxTextLength {
+		return nil, fmt.Errorf("the text can be at most %d characters", maxTextLength)
+	}
+	return params, nil
+}
+
+// textArgs builds the magick arguments that draw text as a label with a
+// margin around it, within the guild's resource limits.
+func textArgs(guildID string, params *textParams, outFile string) []string {
+	args := cmdline.New().
+		Pair("-background", params.Background).
+		Pair("-fill", params.Color).
+		Pair("-pointsize", strconv.Itoa(params.Size))
+	if params.Font != "" {
+		args.Pair("-font", params.Font)
+	}
+	return helpers.MagickArgs(guildID, args.
+		Trusted("label:"+cmdline.MagickText(params.Text)).
+		Pair("-bordercolor", params.Background).
+		Pair("-border", strconv.Itoa(params.Size/3)).
+		Path(outFile).
+		Args()...)
+}
+
+// TextCommand draws text as an image, in the font and colours it's given.
+type TextCommand struct {
+	commands.Command
+}
+
+func (c *TextCommand) Usage() string {
+	return fmt.Sprintf("Usage: `.stext <text> [--font <name>] [--color <color>] [--bg <color>] [--size <%d-%d>]`, e.g. `.stext hello --color teal --bg none`", minTextSize, maxTextSize)
+}
+
+func (c *TextCommand) Validate() error {
+	if c.Session == nil {
+		return fmt.Errorf("invalid session reference")
+	}
+	if c.Message == nil {
+		return fmt.Errorf("invalid message reference")
+	}
+	if len(strings.Fields(c.Message.Content)) < 2 {
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+func (cmd *TextCommand) Apply() error {
+	if err := cmd.Validate(); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	params, err := parseTextArgs(strings.Fields(cmd.Message.Content)[1:])
+	if err != nil {
+		return commands.UserErrorf("Sorry, %v.", err)
+	}
+
+	outTmp, err := os.CreateTemp(utils.TempDir(), "text-*.png")
+	if err != nil {
+		return fmt.Errorf("error creating output file: %w", err)
+	}
+	outTmp.Close()
+	outFile := outTmp.Name()
+	defer os.Remove(outFile)
+
+	render := magickOperation(func(_, outFile string) ([]string, error) {
+		return textArgs(cmd.Message.GuildID, params, outFile), nil
+	})
+	if err := render("", outFile); err != nil {
+		return err
+	}
+	if err := helpers.Watermark(cmd.TraceContext(), cmd.Message.GuildID, outFile); err != nil {
+		return err
+	}
+	if err = helpers.UploadImage(cmd.Session, cmd.Message.ChannelID, outFile); err != nil {
+		return fmt.Errorf("error uploading image: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"slugbot/internal/cmdline"
+	"slugbot/internal/commands"
+	"slugbot/internal/helpers"
+	"slugbot/internal/utils"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxTileSize caps a tile's sides, in pixels; -fx visits every pixel in
+// magick's interpreter, so a photo straight off a phone would take a while.
+const maxTileSize = 1024
+
+// tileBlend is the -fx expression that makes an image tile seamlessly: each
+// pixel is blended with the one half the image away, which, with tiled
+// virtual pixels, is the image offset so its edges meet in the middle. The
+// offset copy takes over towards the edges, where its pixels wrap around
+// continuously, and the original is kept in the middle, where the offset
+// copy's seams are.
+const tileBlend = "m=min(1,4*min(min(i,w-1-i)/w,min(j,h-1-j)/h)); m*u+(1-m)*u.p{i+w/2,j+h/2}"
+
+// tileArgs builds the magick arguments that make a seamless tile of an image,
+// within the guild's resource limits.
+func tileArgs(guildID string, inFile string, outFile string) []string {
+	return helpers.MagickArgs(guildID, cmdline.New(cmdline.MagickInput(inFile)+"[0]").
+		Pair("-resize", fmt.Sprintf("%dx%d>", maxTileSize, maxTileSize)).
+		Pair("-virtual-pixel", "tile").
+		Pair("-fx", tileBlend).
+		Path(outFile).
+		Args()...)
+}
+
+// tilePreviewArgs builds the magick arguments that lay a tile out 2×2, to show
+// how it repeats.
+func tilePreviewArgs(guildID string, tileFile string, outFile string) []string {
+	return helpers.MagickArgs(guildID, cmdline.New(cmdline.MagickInput(tileFile)).
+		Trusted("+clone", "+append", "+clone", "-append").
+		Path(outFile).
+		Args()...)
+}
+
+// TileCommand turns an image into a seamlessly tiling texture, and posts it
+// with a 2×2 preview of it tiled.
+type TileCommand struct {
+	commands.Command
+}
+
+func (c *TileCommand) Usage() string {
+	return "Usage: `.sim tile`; makes the image a seamless texture, and shows it tiled 2×2"
+}
+
+func (c *TileCommand) Validate() error {
+	if c.Session == nil {
+		return fmt.Errorf("invalid session reference")
+	}
+	if c.Message == nil {
+		return fmt.Errorf("invalid message reference")
+	}
+
+	args := strings.Fields(c.Message.Content)
+	if len(args) != 2 || args[1] != "tile" {
+		return errors.New(c.Usage())
+	}
+	return nil
+}
+
+func (cmd *TileCommand) Apply() error {
+	if err := cmd.Validate(); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	inFile, _, cleanup, err := helpers.PrepareImageFiles(cmd.Session, cmd.Message, cmd.Input)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	dir, err := os.MkdirTemp(utils.TempDir(), "tile-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	tileFile, previewFile := filepath.Join(dir, "tile.png"), filepath.Join(dir, "tile-preview.png")
+
+	render := magickOperation(func(inFile, outFile string) ([]string, error) {
+		return tileArgs(cmd.Message.GuildID, inFile, outFile), nil
+	})
+	if err := render(inFile, tileFile); err != nil {
+		return err
+	}
+	preview := magickOperation(func(inFile, outFile string) ([]string, error) {
+		return tilePreviewArgs(cmd.Message.GuildID, inFile, outFile), nil
+	})
+	if err := preview(tileFile, previewFile); err != nil {
+		return err
+	}
+	// a watermark on the tile itself would repeat across whatever it's tiled
+	// over, so only the preview gets one
+	if err := helpers.Watermark(cmd.TraceContext(), cmd.Message.GuildID, previewFile); err != nil {
+		return err
+	}
+
+	var files []*discordgo.File
+	for _, path := range []string{tileFile, previewFile} {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		files = append(files, &discordgo.File{Name: filepath.Base(path), ContentType: "image/png", Reader: file})
+	}
+	_, err = cmd.Session.ChannelMessageSendComplex(cmd.Message.ChannelID, &discordgo.MessageSend{
+		Content:   "Here's the tile, and how it looks tiled 2×2:",
+		Files:     files,
+		Reference: cmd.Message.Reference(),
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading image: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"slices"
+
+	"slugbot/internal/config"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// IsAdmin reports whether the message author may run admin commands: either
+// they're listed in the config, or they have Administrator in the channel.
+func IsAdmin(s *discordgo.Session, m *discordgo.MessageCreate) bool {
+	if IsBotAdmin(m) {
+		return true
+	}
+	if m == nil || m.Message == nil || m.Author == nil || s == nil {
+		return false
+	}
+	perms, err := s.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	if err != nil {
+		return false
+	}
+	return perms&discordgo.PermissionAdministrator != 0
+}
+
+// IsBotAdmin reports whether the message author is one of the bot's own
+// admins, listed in the config, who alone may run commands that affect every
+// guild, like pausing the shared queue. A guild's Administrators aren't.
+func IsBotAdmin(m *discordgo.MessageCreate) bool {
+	return m != nil && m.Message != nil && m.Author != nil && slices.Contains(config.Get().Admin.Users, m.Author.ID)
+}
+
+// IsNSFWChannel reports whether a channel is age-restricted. Threads follow
+// their parent channel, and DMs count as restricted since they're private.
+func IsNSFWChannel(s *discordgo.Session, channelID string) bool {
+	channel, err := LookupChannel(s, channelID)
+	if err != nil {
+		return false
+	}
+	if channel.Type == discordgo.ChannelTypeDM || channel.Type == discordgo.ChannelTypeGroupDM {
+		return true
+	}
+	if channel.IsThread() && channel.ParentID != "" {
+		if parent, err := LookupChannel(s, channel.ParentID); err == nil {
+			return parent.NSFW
+		}
+		return false
+	}
+	return channel.NSFW
+}
+
+// LookupChannel finds a channel in the session state, falling back to the API.
+func LookupChannel(s *discordgo.Session, channelID string) (*discordgo.Channel, error) {
+	if s.State != nil {
+		if channel, err := s.State.Channel(channelID); err == nil {
+			return channel, nil
+		}
+	}
+	return s.Channel(channelID)
+}
@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"testing"
+
+	"slugbot/internal/config"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsBotAdmin_OnlyCountsConfiguredUsers(t *testing.T) {
+	cfg := config.Default()
+	cfg.Admin.Users = []string{"owner"}
+	config.Set(cfg)
+	defer config.Set(config.Default())
+
+	message := func(authorID string) *discordgo.MessageCreate {
+		return &discordgo.MessageCreate{Message: &discordgo.Message{Author: &discordgo.User{ID: authorID}}}
+	}
+	require.True(t, IsBotAdmin(message("owner")))
+	require.True(t, IsAdmin(nil, message("owner")))
+	require.False(t, IsBotAdmin(message("guild-admin")))
+	require.False(t, IsBotAdmin(&discordgo.MessageCreate{Message: &discordgo.Message{}}))
+	require.False(t, IsBotAdmin(&discordgo.MessageCreate{}))
+	require.False(t, IsBotAdmin(nil))
+}
@@ -0,0 +1,66 @@
+package traits
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Interruptible is a helper you can embed to let a running command's
+// subprocess be stopped from outside, e.g. by the stalled-job watchdog.
+type Interruptible struct {
+	mutex    sync.Mutex
+	cancel   context.CancelCauseFunc
+	retrying bool
+	retried  bool
+}
+
+// WithInterrupt returns a context that Interrupt cancels, and a function that
+// releases it once the interruptible work is done.
+func (h *Interruptible) WithInterrupt(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	h.mutex.Lock()
+	h.cancel = cancel
+	h.retrying = false
+	h.mutex.Unlock()
+
+	return ctx, func() {
+		h.mutex.Lock()
+		h.cancel = nil
+		h.mutex.Unlock()
+		cancel(nil)
+	}
+}
+
+// Interrupt stops the running work with reason as its cause. If retry is set
+// and the command hasn't been retried before, it asks to be run again.
+// It returns false if nothing was running.
+func (h *Interruptible) Interrupt(reason error, retry bool) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.cancel == nil {
+		return false
+	}
+	if retry && !h.retried {
+		h.retrying, h.retried = true, true
+	}
+	h.cancel(reason)
+	return true
+}
+
+// Retrying reports whether the last interruption asked for the command to run again.
+func (h *Interruptible) Retrying() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.retrying
+}
+
+// InterruptCause explains err with the reason the work was interrupted, if it was.
+func (h *Interruptible) InterruptCause(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); cause != nil && cause != context.Canceled && err != nil {
+		return fmt.Errorf("%w (%v)", cause, err)
+	}
+	return err
+}
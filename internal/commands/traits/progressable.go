@@ -0,0 +1,24 @@
+package traits
+
+import (
+	"sync"
+)
+
+// Progressable is a helper you can embed to remember a running command's
+// latest progress text, e.g. for status lookups.
+type Progressable struct {
+	mutex    sync.Mutex
+	progress string
+}
+
+func (h *Progressable) Progress() string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.progress
+}
+
+func (h *Progressable) SetProgress(text string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.progress = text
+}
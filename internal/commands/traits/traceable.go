@@ -0,0 +1,57 @@
+package traits
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"slugbot/internal/io/slog"
+)
+
+// Traceable is a helper you can embed to implement TraceHandler.
+type Traceable struct {
+	traceID string
+	ctx     context.Context
+}
+
+// TraceHandler represents work that can be correlated across logs and replies by a trace ID.
+type TraceHandler interface {
+	TraceID() string
+	SetTraceID(id string)
+	TraceContext() context.Context
+	SetTraceContext(ctx context.Context)
+}
+
+// NewTraceID returns a short random identifier suitable for showing to users.
+func NewTraceID() string {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return "00000000"
+	}
+	return hex.EncodeToString(buf)
+}
+
+func (h *Traceable) TraceID() string {
+	return h.traceID
+}
+
+func (h *Traceable) SetTraceID(id string) {
+	h.traceID = id
+}
+
+// TraceContext returns the context carrying the current tracing span.
+func (h *Traceable) TraceContext() context.Context {
+	if h.ctx == nil {
+		return context.Background()
+	}
+	return h.ctx
+}
+
+func (h *Traceable) SetTraceContext(ctx context.Context) {
+	h.ctx = ctx
+}
+
+// Log returns a logger that tags every line with the trace ID.
+func (h *Traceable) Log() *slog.Logger {
+	return slog.With("trace", h.traceID)
+}
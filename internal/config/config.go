@@ -0,0 +1,655 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config is the top-level bot configuration, loaded from a TOML file at startup.
+type Config struct {
+	Admin        Admin                  `toml:"admin"`
+	API          API                    `toml:"api"`
+	Analytics    Analytics              `toml:"analytics"`
+	Attribution  Attribution            `toml:"attribution"`
+	Branding     Branding               `toml:"branding"`
+	Cache        Cache                  `toml:"cache"`
+	Compare      Compare                `toml:"compare"`
+	Confirm      Confirm                `toml:"confirm"`
+	Credits      Credits                `toml:"credits"`
+	Dashboard    Dashboard              `toml:"dashboard"`
+	Features     Features               `toml:"features"`
+	Forum        Forum                  `toml:"forum"`
+	Handoff      Handoff                `toml:"handoff"`
+	ImagePresets map[string]ImagePreset `toml:"image_presets"`
+	Interactions Interactions           `toml:"interactions"`
+	Limits       Limits                 `toml:"limits"`
+	LLM          LLM                    `toml:"llm"`
+	Log          Log                    `toml:"log"`
+	Maintenance  Maintenance            `toml:"maintenance"`
+	Mirror       Mirror                 `toml:"mirror"`
+	NaturalLang  NaturalLang            `toml:"natural_language"`
+	Notify       Notify                 `toml:"notify"`
+	NSFW         NSFW                   `toml:"nsfw"`
+	Persona      Persona                `toml:"persona"`
+	Progress     Progress               `toml:"progress"`
+	PromptOfDay  PromptOfTheDay         `toml:"prompt_of_the_day"`
+	Prune        Prune                  `toml:"prune"`
+	Queue        Queue                  `toml:"queue"`
+	QueueView    QueueView              `toml:"queue_view"`
+	Quota        Quota                  `toml:"quota"`
+	Reactions    Reactions              `toml:"reactions"`
+	Recurring    Recurring              `toml:"recurring"`
+	Results      Results                `toml:"results"`
+	Scan         Scan                   `toml:"scan"`
+	Store        Store                  `toml:"store"`
+	Sweep        Sweep                  `toml:"sweep"`
+	Tools        Tools                  `toml:"tools"`
+	Tracing      Tracing                `toml:"tracing"`
+	Watchdog     Watchdog               `toml:"watchdog"`
+	Webhooks     Webhooks               `toml:"webhooks"`
+}
+
+// Admin lists who may run `.sadmin` commands, in addition to server
+// administrators, and where operational alerts go.
+type Admin struct {
+	Users         []string `toml:"users"`          // Discord user IDs
+	AlertChannels []string `toml:"alert_channels"` // channel IDs that get alerts, e.g. about a full queue
+}
+
+// API serves the gRPC control API, authenticated with the api_token secret or
+// a user's personal token.
+type API struct {
+	Listen   string   `toml:"listen"`   // address to listen on, e.g. "127.0.0.1:9090"; empty disables the API
+	Channels []string `toml:"channels"` // channel IDs jobs may be submitted to
+}
+
+// Analytics controls the opt-in collection of anonymized usage counts for `.sadmin stats`.
+type Analytics struct {
+	Enabled       bool          `toml:"enabled"`
+	FlushInterval time.Duration `toml:"flush_interval"` // how often counts are written to the store
+}
+
+// Attribution labels generated audio as AI-made. Guild admins can override
+// Footer and Watermark with `.sadmin attribution`.
+type Attribution struct {
+	Footer     bool   `toml:"footer"`     // note the model and that the audio is AI-generated under each result
+	Text       string `toml:"text"`       // optional extra footer text, e.g. a link to usage terms
+	Watermark  bool   `toml:"watermark"`  // embed an inaudible watermark in each file
+	Audiowmark string `toml:"audiowmark"` // path to the audiowmark binary
+	KeyFile    string `toml:"key_file"`   // optional audiowmark key, so only its holders can read the watermark
+}
+
+// Cache controls the download cache and the janitor that cleans up after it.
+type Cache struct {
+	Enabled         bool          `toml:"enabled"`
+	Dir             string        `toml:"dir"`
+	TTL             time.Duration `toml:"ttl"`              // how long an unused download stays cached
+	JanitorInterval time.Duration `toml:"janitor_interval"` // how often to sweep the cache and temp files
+	MaxTempAge      time.Duration `toml:"max_temp_age"`     // temp files older than this are deleted
+}
+
+// Compare picks the two models `.scompare` generates with: "small", "full"
+// (the active checkpoint), or the name of a checkpoint under models/.
+type Compare struct {
+	Models []string `toml:"models"`
+}
+
+// Confirm asks users to check a `.saudio` job's resolved parameters before it's
+// queued, when the job is expected to run long enough that a mistyped flag
+// would waste real GPU time.
+type Confirm struct {
+	Above   time.Duration `toml:"above"`   // estimated GPU time past which jobs need confirming; 0 disables
+	Timeout time.Duration `toml:"timeout"` // how long an unconfirmed job waits before it's dropped
+}
+
+// Credits charges generation jobs against per-user balances, in proportion to
+// their estimated GPU time. Admins and jobs submitted with the shared webhook
+// or API secrets aren't charged.
+type Credits struct {
+	Enabled      bool          `toml:"enabled"`
+	Starting     int64         `toml:"starting"`       // balance a user starts with
+	PerGPUMinute float64       `toml:"per_gpu_minute"` // credits charged per minute of estimated GPU time
+	UnknownJob   time.Duration `toml:"unknown_job"`    // GPU time assumed for job shapes with no runtime history yet
+}
+
+// Dashboard serves the web dashboard. Visitors sign in with Discord, using
+// the OAuth2 client secret stored as dashboard_client_secret.
+type Dashboard struct {
+	Listen      string        `toml:"listen"`       // address to listen on, e.g. "127.0.0.1:8080"; empty disables the dashboard
+	ClientID    string        `toml:"client_id"`    // the Discord application's OAuth2 client ID
+	RedirectURL string        `toml:"redirect_url"` // the dashboard's public /callback URL, registered as a redirect for the application
+	SessionTTL  time.Duration `toml:"session_ttl"`  // how long a sign-in lasts
+	NvidiaSMI   string        `toml:"nvidia_smi"`   // nvidia-smi binary used for GPU stats; empty hides them
+}
+
+// Features turns the bot's optional subsystems on or off. A subsystem left
+// out of the build with its no<feature> tag stays off whatever it's set to
+// here; the API and dashboard also need their listen addresses.
+type Features struct {
+	Audio     bool `toml:"audio"`     // generation commands and the job queue
+	Image     bool `toml:"image"`     // .sim image operations
+	API       bool `toml:"api"`       // the gRPC control API and .stoken
+	Dashboard bool `toml:"dashboard"` // the web dashboard
+}
+
+// Forum lists forum channels whose posts are generation requests: the title
+// is the prompt and the body holds flags or a ```saudio block.
+type Forum struct {
+	Channels []string `toml:"channels"` // forum channel IDs
+}
+
+// Handoff lets a new bot process take over from the one that's running, e.g.
+// during a deploy: started with -handoff, it asks the running process to
+// hand over, and waits. The running process stops starting jobs, lets the
+// running one finish, disconnects, and exits; the new one connects and picks
+// up the queue. Processes sharing a store coordinate through a lease in it.
+type Handoff struct {
+	Drain    time.Duration `toml:"drain"`     // how long the running job may take to finish before it's stopped and handed over too
+	LeaseTTL time.Duration `toml:"lease_ttl"` // how long a process that stops renewing its lease, e.g. because it crashed, keeps others from starting
+}
+
+// ImagePreset is a named chain of magick operators usable as `.sim preset <name>` in every guild.
+type ImagePreset struct {
+	Args   []string `toml:"args"`
+	Format string   `toml:"format"` // optional output extension, e.g. "jpg"
+}
+
+// Interactions controls the bot's application commands. Context-menu commands
+// are always registered; with Only set, every command is also a slash command,
+// and the bot doesn't ask for the privileged message content intent and
+// ignores prefix commands such as `.saudio`, for deployments that haven't been
+// granted the intent.
+type Interactions struct {
+	Only   bool     `toml:"only"`
+	Guilds []string `toml:"guilds"` // guild IDs to register the commands in, which is immediate; empty registers them globally
+	Avatar string   `toml:"avatar"` // the `.sim` operation "Distort avatar" runs on a user's avatar, e.g. "preset glow"
+}
+
+// Branding stamps a small watermark, a logo or a line of text, in a corner of
+// image and animation results before they're uploaded. Guilds can be given
+// their own; admins can leave it off a result with `--no-watermark`.
+type Branding struct {
+	ImageWatermark
+	Guilds map[string]ImageWatermark `toml:"guilds"` // guild ID -> the watermark settings it overrides there
+}
+
+// ImageWatermark is one watermark. With neither a logo nor text, there's none.
+type ImageWatermark struct {
+	Text   string `toml:"text"`   // e.g. "made with slugbot"
+	Logo   string `toml:"logo"`   // path to a small image, e.g. a PNG with transparency; used instead of Text
+	Corner string `toml:"corner"` // "northwest", "northeast", "southwest", or "southeast"
+	Size   int    `toml:"size"`   // the text's height, or the logo's longest side, in pixels
+	Off    bool   `toml:"off"`    // for a guild: no watermark, whatever the defaults
+}
+
+// For returns the watermark in a guild: the defaults, with whatever the guild overrides.
+func (b Branding) For(guildID string) ImageWatermark {
+	watermark := b.ImageWatermark
+	override, ok := b.Guilds[guildID]
+	if !ok {
+		return watermark
+	}
+	if override.Text != "" || override.Logo != "" {
+		// a guild's own text or logo replaces both of the defaults
+		watermark.Text, watermark.Logo = override.Text, override.Logo
+	}
+	if override.Corner != "" {
+		watermark.Corner = override.Corner
+	}
+	if override.Size != 0 {
+		watermark.Size = override.Size
+	}
+	watermark.Off = override.Off
+	return watermark
+}
+
+// Limits caps the resources of every magick and ffmpeg run, so that one huge
+// image can't exhaust the host, how many image renders run at once, and the
+// zips `.sim <op> --batch` takes. Trusted guilds can be given their own tool limits.
+type Limits struct {
+	ToolLimits
+	Guilds      map[string]ToolLimits `toml:"guilds"`       // guild ID -> the limits it overrides there
+	Renders     int                   `toml:"renders"`      // most image renders at once, across every command; 0 uses the number of CPUs
+	BatchImages int                   `toml:"batch_images"` // most images in one batch; 0 disables the limit
+	BatchBytes  int64                 `toml:"batch_bytes"`  // most bytes a batch's images may add up to, uncompressed; 0 disables the limit
+}
+
+// ToolLimits are the resource limits of one magick or ffmpeg run. Empty or
+// zero settings leave the tool's own default.
+type ToolLimits struct {
+	MagickMemory  string `toml:"magick_memory"`  // `-limit memory`, e.g. "256MiB"
+	MagickMap     string `toml:"magick_map"`     // `-limit map`, memory-mapped pixel cache
+	MagickDisk    string `toml:"magick_disk"`    // `-limit disk`, pixel cache on disk
+	FFmpegThreads int    `toml:"ffmpeg_threads"` // threads per codec and filter graph
+}
+
+// For returns the limits in a guild: the defaults, with whatever the guild overrides.
+func (l Limits) For(guildID string) ToolLimits {
+	limits := l.ToolLimits
+	override, ok := l.Guilds[guildID]
+	if !ok {
+		return limits
+	}
+	if override.MagickMemory != "" {
+		limits.MagickMemory = override.MagickMemory
+	}
+	if override.MagickMap != "" {
+		limits.MagickMap = override.MagickMap
+	}
+	if override.MagickDisk != "" {
+		limits.MagickDisk = override.MagickDisk
+	}
+	if override.FFmpegThreads != 0 {
+		limits.FFmpegThreads = override.FFmpegThreads
+	}
+	return limits
+}
+
+// LLM points at an optional OpenAI-compatible chat completions endpoint.
+type LLM struct {
+	Endpoint  string        `toml:"endpoint"` // e.g. "http://localhost:11434/v1/chat/completions"; empty disables LLM features
+	Model     string        `toml:"model"`
+	APIKeyEnv string        `toml:"api_key_env"` // environment variable holding the API key; if empty, the llm_api_key secret is used
+	Timeout   time.Duration `toml:"timeout"`
+}
+
+// Log keeps the log in a file as well as on stderr. The file is rotated when
+// it reaches MaxSize or has been written to for MaxAge; rotated files are
+// gzipped if Compress is set, and deleted past Keep of them or after Retain.
+type Log struct {
+	File     string        `toml:"file"`     // e.g. "logs/slugbot.log"; empty logs to stderr only
+	MaxSize  int64         `toml:"max_size"` // bytes; 0 disables
+	MaxAge   time.Duration `toml:"max_age"`  // 0 disables
+	Compress bool          `toml:"compress"`
+	Keep     int           `toml:"keep"`   // rotated files to keep; 0 keeps them all
+	Retain   time.Duration `toml:"retain"` // 0 keeps them however old
+}
+
+// Maintenance configures `.sadmin maintenance`.
+type Maintenance struct {
+	Channels []string `toml:"channels"` // channel IDs that get maintenance notices
+}
+
+// Mirror configures `slugbot mirror`, a staging bot that runs the commands
+// it sees in the real bot's guilds without sending anything to Discord or
+// running generations on the GPU.
+type Mirror struct {
+	StepTime time.Duration `toml:"step_time"` // how long each step of a fake generation takes
+}
+
+// NaturalLang controls answering plain mentions like "@slugbot make me 20 seconds of rainy jazz".
+type NaturalLang struct {
+	Enabled bool `toml:"enabled"`
+	UseLLM  bool `toml:"use_llm"` // interpret with the [llm] endpoint instead of the built-in rules
+}
+
+// Persona is how the bot presents itself: its nickname in each server and
+// the color of its embeds. Guild admins can override these with
+// `.sadmin persona`, e.g. when one instance serves several communities.
+type Persona struct {
+	Nickname string `toml:"nickname"` // empty leaves the bot's own name
+	Color    string `toml:"color"`    // "#rrggbb"; empty leaves Discord's default
+}
+
+// Prune deletes what the bot stored about a guild once it's been removed from
+// it for Grace: the guild's settings, presets, recurring jobs, prompt-of-the-day
+// rounds, audit entries, usage analytics, and finished jobs and their output
+// files. Adding the bot back within Grace keeps all of it.
+type Prune struct {
+	Grace time.Duration `toml:"grace"` // 0 keeps everything forever
+}
+
+// Progress controls how often a job's progress message is updated, and what
+// happens to it once the job is done: it's deleted unless it's kept for the
+// guild or user, in which case it's edited into a short summary above the
+// result. `.saudio --keep-progress` keeps it for one job.
+type Progress struct {
+	Keep        bool          `toml:"keep"`         // keep it everywhere
+	Guilds      []string      `toml:"guilds"`       // guild IDs where it's kept
+	Users       []string      `toml:"users"`        // user IDs whose progress messages are kept
+	Interval    time.Duration `toml:"interval"`     // how often it's updated while the job changes phase or is nearly done
+	MaxInterval time.Duration `toml:"max_interval"` // how far updates slow down while the job only counts steps or is idle
+}
+
+// PromptOfTheDay posts a theme on a schedule and enters `.saudio` replies to
+// it in a round that ends with a recap and a vote.
+type PromptOfTheDay struct {
+	Channel  string        `toml:"channel"`  // channel ID; empty disables the feature
+	Schedule string        `toml:"schedule"` // cron expression in local time, e.g. "0 17 * * *"
+	Duration time.Duration `toml:"duration"` // how long each round takes entries
+	Themes   []string      `toml:"themes"`   // one is picked at random each round
+}
+
+// Queue limits how many generation jobs may wait at once, in all and for
+// each user, and what happens to the jobs a crash or restart cuts off.
+type Queue struct {
+	MaxDepth          int           `toml:"max_depth"`   // 0 disables the limit
+	AlertAfter        int           `toml:"alert_after"` // alert admins after this many rejections within AlertWindow; 0 disables
+	AlertWindow       time.Duration `toml:"alert_window"`
+	Resume            int           `toml:"resume"`              // how many times a job cut off by a restart is queued again at startup; 0 only reports it
+	MaxUserWaiting    int           `toml:"max_user_waiting"`    // 0 disables the limit
+	MaxWebhookWaiting int           `toml:"max_webhook_waiting"` // for jobs submitted with the shared webhook secret; 0 disables the limit
+	UncappedRoles     []string      `toml:"uncapped_roles"`      // role IDs whose members aren't held to the per-user limits
+}
+
+// QueueView shows the queue in a message that's kept up to date in each of
+// its channels. Placement picks the channels: "channels" uses Channels, and
+// with JobChannels every channel a job is started from; "busiest" follows the
+// one channel that started the most jobs within BusiestWindow; "thread" shows
+// it in a thread under a pinned message in each of Channels. A restart with a
+// different placement moves the view.
+type QueueView struct {
+	Placement     string        `toml:"placement"`
+	Channels      []string      `toml:"channels"`       // channel IDs that always show it, e.g. a #bot-status channel
+	JobChannels   bool          `toml:"job_channels"`   // also show it in every channel a job is started from
+	BusiestWindow time.Duration `toml:"busiest_window"` // how far back "busiest" counts each channel's jobs
+	Pin           bool          `toml:"pin"`            // pin the messages and edit them in place instead of reposting them at the bottom
+	Interval      time.Duration `toml:"interval"`       // how often the messages are updated while jobs come and go or one is nearly done
+	MaxInterval   time.Duration `toml:"max_interval"`   // how far updates slow down while the queue doesn't change
+}
+
+// Notify tells users their jobs finished, in the way each picked with
+// `.sprefs notify`. Email is sent through an SMTP server, signing in with the
+// smtp_password secret.
+type Notify struct {
+	After    time.Duration `toml:"after"`     // only jobs that took at least this long since they were asked for
+	SMTPHost string        `toml:"smtp_host"` // host:port; empty disables email
+	SMTPUser string        `toml:"smtp_user"` // empty sends without signing in
+	From     string        `toml:"from"`      // the sender address
+}
+
+// NSFW lists commands and prompt terms that every guild only allows in
+// age-restricted channels; guild admins can add more with `.sadmin nsfw`.
+type NSFW struct {
+	Commands []string `toml:"commands"` // e.g. ".saudio" or ".sim animate"
+	Terms    []string `toml:"terms"`    // whole words or phrases, case-insensitive
+}
+
+// Quota limits how much disk each user's undelivered job files may take up.
+type Quota struct {
+	MaxUserBytes int64 `toml:"max_user_bytes"` // 0 disables the limit
+}
+
+// Reactions acknowledges generation commands with reactions on the message
+// that triggered them: one as soon as the job is queued, replaced by another
+// when it succeeds or fails. Guild admins can override these with
+// `.sadmin reactions`. Custom emoji are written "name:id".
+type Reactions struct {
+	Enabled   bool   `toml:"enabled"`
+	Queued    string `toml:"queued"`
+	Succeeded string `toml:"succeeded"`
+	Failed    string `toml:"failed"`
+}
+
+// Recurring controls admin-defined jobs that run on a cron schedule.
+type Recurring struct {
+	CatchUpWithin time.Duration `toml:"catch_up_within"` // run a job once on startup if its latest missed run is this recent; 0 skips missed runs
+}
+
+// Results formats the message each generation is posted with, as a Go
+// text/template over .Prompt, .Seed, .Duration, .Model, and .Submitter. Guild
+// admins can override it with `.sadmin template`. Empty posts results without
+// text, apart from any attribution footer. Preview DMs each result to its
+// submitter with buttons to publish it in the channel or discard it; guild
+// admins can override it with `.sadmin preview`.
+type Results struct {
+	Preview    bool          `toml:"preview"`
+	Template   string        `toml:"template"`
+	ClipLength time.Duration `toml:"clip_length"` // how much of a result too big to upload is posted as a clip instead; 0 posts nothing
+	QuickOver  int64         `toml:"quick_over"`  // results bigger than this many bytes are posted as a low-bitrate MP3 first; 0 never does
+}
+
+// Scan checks the files users send, like init audio and images, with a virus
+// or abuse scanner before they're processed, and refuses the ones it flags.
+// Clamd, or else URL, picks the scanner; with neither, files aren't scanned.
+type Scan struct {
+	Clamd    string        `toml:"clamd"` // clamd's unix socket path, or its host:port
+	URL      string        `toml:"url"`   // an HTTP scanner that answers {"flagged": bool, "reason": "..."}
+	Timeout  time.Duration `toml:"timeout"`
+	FailOpen bool          `toml:"fail_open"` // process files anyway when the scanner fails
+}
+
+// Store controls where persistent bot state is kept.
+type Store struct {
+	Dir string `toml:"dir"`
+}
+
+// Sweep limits how many generations one `.ssweep`, or one ```saudio block with
+// a [sweep] table, may queue.
+type Sweep struct {
+	MaxJobs int `toml:"max_jobs"`
+}
+
+// Tools sets where the external programs the bot runs are. A bare name is
+// looked up on PATH; a relative path is from the working directory. On
+// Windows, ".exe" may be left off. Empty uses the default: sag at
+// stable-audio/sag, python in the .conda/general-dsp environment, and magick
+// and ffmpeg from PATH.
+type Tools struct {
+	Sag      string `toml:"sag"`
+	Python   string `toml:"python"` // runs the py/ scripts, e.g. the limiter
+	Magick   string `toml:"magick"`
+	FFmpeg   string `toml:"ffmpeg"`
+	QREncode string `toml:"qrencode"` // draws the codes `.sqr` makes
+}
+
+// Tracing controls OpenTelemetry span export.
+type Tracing struct {
+	Endpoint    string  `toml:"endpoint"`     // OTLP/HTTP collector host:port; empty disables tracing
+	Insecure    bool    `toml:"insecure"`     // use plain HTTP instead of HTTPS
+	ServiceName string  `toml:"service_name"` // reported as service.name
+	SampleRatio float64 `toml:"sample_ratio"` // fraction of dispatches to trace, 0..1
+}
+
+// Watchdog looks for running jobs whose progress has stopped changing.
+type Watchdog struct {
+	StallAfter time.Duration `toml:"stall_after"` // 0 disables the watchdog
+	Kill       bool          `toml:"kill"`        // stop stalled jobs instead of only reporting them
+	Requeue    bool          `toml:"requeue"`     // run a stopped job once more
+}
+
+// Webhooks accepts job submissions over HTTP from external tools, signed with
+// the webhook_secret secret or carrying a user's personal token.
+type Webhooks struct {
+	Listen   string        `toml:"listen"`   // address to listen on, e.g. ":8090"; empty disables webhooks
+	Channels []string      `toml:"channels"` // channel IDs submissions may post to
+	MaxSkew  time.Duration `toml:"max_skew"` // how far a request's timestamp may be from the bot's clock
+}
+
+var current atomic.Pointer[Config]
+
+// Default returns a Config with every optional feature turned off.
+func Default() *Config {
+	return &Config{
+		Analytics: Analytics{
+			FlushInterval: 5 * time.Minute,
+		},
+		Attribution: Attribution{
+			Audiowmark: "audiowmark",
+		},
+		Branding: Branding{
+			ImageWatermark: ImageWatermark{
+				Corner: "southeast",
+				Size:   24,
+			},
+		},
+		Cache: Cache{
+			Enabled:         true,
+			Dir:             "data/cache",
+			TTL:             time.Hour,
+			JanitorInterval: 10 * time.Minute,
+			MaxTempAge:      6 * time.Hour,
+		},
+		Compare: Compare{
+			Models: []string{"small", "full"},
+		},
+		Confirm: Confirm{
+			Above:   15 * time.Minute,
+			Timeout: 10 * time.Minute,
+		},
+		Credits: Credits{
+			Starting:     100,
+			PerGPUMinute: 10,
+			UnknownJob:   time.Minute,
+		},
+		Features: Features{
+			Audio:     true,
+			Image:     true,
+			API:       true,
+			Dashboard: true,
+		},
+		Dashboard: Dashboard{
+			SessionTTL: 24 * time.Hour,
+			NvidiaSMI:  "nvidia-smi",
+		},
+		Handoff: Handoff{
+			Drain:    15 * time.Minute,
+			LeaseTTL: 30 * time.Second,
+		},
+		Interactions: Interactions{
+			Avatar: "polar 0",
+		},
+		Limits: Limits{
+			ToolLimits: ToolLimits{
+				MagickMemory: "256MiB",
+				MagickMap:    "512MiB",
+				MagickDisk:   "2GiB",
+			},
+			BatchImages: 50,
+			BatchBytes:  200 << 20,
+		},
+		LLM: LLM{
+			Timeout: 30 * time.Second,
+		},
+		Log: Log{
+			MaxSize:  100 << 20,
+			MaxAge:   24 * time.Hour,
+			Compress: true,
+			Keep:     30,
+			Retain:   30 * 24 * time.Hour,
+		},
+		Mirror: Mirror{
+			StepTime: 50 * time.Millisecond,
+		},
+		Notify: Notify{
+			After: 10 * time.Minute,
+		},
+		Progress: Progress{
+			Interval:    time.Second,
+			MaxInterval: 10 * time.Second,
+		},
+		PromptOfDay: PromptOfTheDay{
+			Schedule: "0 17 * * *",
+			Duration: 23 * time.Hour,
+			Themes: []string{
+				"rainy city at night",
+				"haunted carnival",
+				"deep sea",
+				"8-bit boss fight",
+				"sunrise over the desert",
+				"clockwork machinery",
+				"lost in a jungle",
+			},
+		},
+		Prune: Prune{
+			Grace: 30 * 24 * time.Hour,
+		},
+		Queue: Queue{
+			MaxDepth:          50,
+			AlertAfter:        5,
+			AlertWindow:       10 * time.Minute,
+			Resume:            1,
+			MaxUserWaiting:    2,
+			MaxWebhookWaiting: 10,
+		},
+		QueueView: QueueView{
+			Placement:     "channels",
+			BusiestWindow: time.Hour,
+			Interval:      2 * time.Second,
+			MaxInterval:   30 * time.Second,
+		},
+		Quota: Quota{
+			MaxUserBytes: 1 << 30,
+		},
+		Reactions: Reactions{
+			Queued:    "⏳",
+			Succeeded: "✅",
+			Failed:    "❌",
+		},
+		Recurring: Recurring{
+			CatchUpWithin: time.Hour,
+		},
+		Results: Results{
+			ClipLength: 30 * time.Second,
+		},
+		Scan: Scan{
+			Timeout: 30 * time.Second,
+		},
+		Store: Store{
+			Dir: "data",
+		},
+		Sweep: Sweep{
+			MaxJobs: 8,
+		},
+		Tracing: Tracing{
+			ServiceName: "slugbot",
+			SampleRatio: 1.0,
+		},
+		Watchdog: Watchdog{
+			StallAfter: 10 * time.Minute,
+		},
+		Webhooks: Webhooks{
+			MaxSkew: 5 * time.Minute,
+		},
+	}
+}
+
+// Load reads a config file on top of the defaults. A missing file is not an error.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("couldn't load config '%s': %w", path, err)
+	}
+	return cfg, nil
+}
+
+// LoadStrict reads a config file on top of the defaults like Load, but the
+// file has to exist and every key in it has to be one the bot knows, so a
+// typo doesn't silently leave a setting at its default.
+func LoadStrict(path string) (*Config, error) {
+	cfg := Default()
+	meta, err := toml.DecodeFile(path, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load config '%s': %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return nil, fmt.Errorf("unknown keys in config '%s': %s", path, strings.Join(keys, ", "))
+	}
+	return cfg, nil
+}
+
+// Get returns the active configuration, or the defaults if none was set.
+func Get() *Config {
+	if cfg := current.Load(); cfg != nil {
+		return cfg
+	}
+	return Default()
+}
+
+// Set replaces the active configuration.
+func Set(cfg *Config) {
+	current.Store(cfg)
+}
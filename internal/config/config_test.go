@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, text string) string {
+	path := filepath.Join(t.TempDir(), "slugbot.toml")
+	require.NoError(t, os.WriteFile(path, []byte(text), 0o600))
+	return path
+}
+
+func TestLoadStrict_ReadsOverDefaults(t *testing.T) {
+	cfg, err := LoadStrict(writeConfig(t, "[queue]\nmax_depth = 3\n"))
+	require.NoError(t, err)
+	require.Equal(t, 3, cfg.Queue.MaxDepth)
+	require.Equal(t, time.Hour, cfg.Cache.TTL)
+}
+
+func TestLoadStrict_RejectsUnknownKeys(t *testing.T) {
+	path := writeConfig(t, "[queue]\nmax_dpeth = 3\n\n[voice]\nenabled = true\n")
+
+	_, err := LoadStrict(path)
+	require.ErrorContains(t, err, "queue.max_dpeth")
+	require.ErrorContains(t, err, "voice")
+
+	_, err = Load(path)
+	require.NoError(t, err)
+}
+
+func TestLoadStrict_RequiresTheFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.toml")
+
+	_, err := LoadStrict(path)
+	require.Error(t, err)
+
+	_, err = Load(path)
+	require.NoError(t, err)
+}
@@ -0,0 +1,107 @@
+// Package credits keeps per-user credit balances that generation jobs are
+// charged against, in proportion to how much GPU time they're expected to take.
+package credits
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"slugbot/internal/store"
+)
+
+const bucket = "credits"
+
+// ErrInsufficient is returned by Check when a user can't afford a job.
+var ErrInsufficient = errors.New("not enough credits")
+
+// Account is one user's balance and its history in totals.
+type Account struct {
+	Balance int64     `json:"balance"`
+	Spent   int64     `json:"spent"`   // charged for jobs over all time
+	Granted int64     `json:"granted"` // added or removed by admins over all time
+	Updated time.Time `json:"updated"`
+}
+
+// Ledger keeps accounts in a store. Users start with Starting credits the first
+// time they're seen.
+type Ledger struct {
+	Store    *store.Store
+	Starting int64
+
+	mutex sync.Mutex
+}
+
+// Account returns a user's account.
+func (l *Ledger) Account(userID string) (Account, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.accountLocked(userID)
+}
+
+func (l *Ledger) accountLocked(userID string) (Account, error) {
+	var account Account
+	err := l.Store.Get(bucket, userID, &account)
+	if errors.Is(err, store.ErrNotFound) {
+		return Account{Balance: l.Starting}, nil
+	}
+	return account, err
+}
+
+// Check returns an error wrapping ErrInsufficient if a user's balance doesn't cover cost.
+func (l *Ledger) Check(userID string, cost int64) error {
+	account, err := l.Account(userID)
+	if err != nil {
+		return err
+	}
+	if account.Balance < cost {
+		return fmt.Errorf("%w: this needs %d and you have %d", ErrInsufficient, cost, account.Balance)
+	}
+	return nil
+}
+
+// Charge takes cost credits from a user for a job and returns the new
+// balance. Jobs are charged after they've run, so the balance may go negative
+// if one cost more than was checked for.
+func (l *Ledger) Charge(userID string, cost int64, now time.Time) (int64, error) {
+	return l.update(userID, now, func(account *Account) {
+		account.Balance -= cost
+		account.Spent += cost
+	})
+}
+
+// Grant adds amount credits to a user, or removes them if it's negative, and
+// returns the new balance.
+func (l *Ledger) Grant(userID string, amount int64, now time.Time) (int64, error) {
+	return l.update(userID, now, func(account *Account) {
+		account.Balance += amount
+		account.Granted += amount
+	})
+}
+
+func (l *Ledger) update(userID string, now time.Time, change func(*Account)) (int64, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	account, err := l.accountLocked(userID)
+	if err != nil {
+		return 0, err
+	}
+	change(&account)
+	account.Updated = now
+	if err := l.Store.Put(bucket, userID, account); err != nil {
+		return 0, err
+	}
+	return account.Balance, nil
+}
+
+// Cost prices gpuTime at perMinute credits per minute, rounding up so every
+// job that uses the GPU costs at least one credit.
+func Cost(gpuTime time.Duration, perMinute float64) int64 {
+	if gpuTime <= 0 || perMinute <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(gpuTime.Minutes() * perMinute))
+}
@@ -0,0 +1,54 @@
+package credits
+
+import (
+	"testing"
+	"time"
+
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newLedger(t *testing.T, starting int64) *Ledger {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	return &Ledger{Store: s, Starting: starting}
+}
+
+func TestLedger_ChargeAndGrant(t *testing.T) {
+	ledger := newLedger(t, 10)
+	now := time.Now()
+
+	account, err := ledger.Account("u1")
+	require.NoError(t, err)
+	require.Equal(t, int64(10), account.Balance)
+
+	require.NoError(t, ledger.Check("u1", 10))
+	require.ErrorIs(t, ledger.Check("u1", 11), ErrInsufficient)
+
+	balance, err := ledger.Charge("u1", 12, now)
+	require.NoError(t, err)
+	require.Equal(t, int64(-2), balance)
+	require.ErrorIs(t, ledger.Check("u1", 1), ErrInsufficient)
+
+	balance, err = ledger.Grant("u1", 50, now)
+	require.NoError(t, err)
+	require.Equal(t, int64(48), balance)
+
+	account, err = ledger.Account("u1")
+	require.NoError(t, err)
+	require.Equal(t, Account{Balance: 48, Spent: 12, Granted: 50, Updated: account.Updated}, account)
+
+	// other users are untouched
+	account, err = ledger.Account("u2")
+	require.NoError(t, err)
+	require.Equal(t, int64(10), account.Balance)
+}
+
+func TestCost(t *testing.T) {
+	require.Equal(t, int64(0), Cost(0, 10))
+	require.Equal(t, int64(0), Cost(time.Minute, 0))
+	require.Equal(t, int64(1), Cost(time.Second, 10))
+	require.Equal(t, int64(10), Cost(time.Minute, 10))
+	require.Equal(t, int64(25), Cost(150*time.Second, 10))
+}
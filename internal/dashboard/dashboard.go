@@ -0,0 +1,353 @@
+// Package dashboard serves a web page showing the generation queue, recent
+// jobs with their audio, and GPU load, plus admin actions to cancel jobs and
+// pause the queue.
+//
+// Visitors sign in with Discord. What they can see and do mirrors their roles
+// in the guilds they share with the bot: members see their guilds' jobs, and
+// guild admins can also cancel those jobs. Since the queue is shared by every
+// guild, only the bot's own admins can pause it.
+package dashboard
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/exec"
+	"slugbot/internal/io/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//go:embed static
+var static embed.FS
+
+// Cookie names.
+const (
+	sessionCookie = "slugbot_session"
+	stateCookie   = "slugbot_oauth_state"
+)
+
+// ActionHeader must be set on requests that change anything. Browsers won't
+// send custom headers cross-site without a CORS preflight the dashboard never
+// answers, so another site can't make a signed-in visitor act.
+const ActionHeader = "X-Slugbot-Action"
+
+// Access describes what a signed-in user may see and do.
+type Access struct {
+	Guilds    map[string]bool // guilds the user is in, and whether they're an admin there
+	Superuser bool            // sees and manages every job, e.g. users listed in [admin]
+}
+
+func (a Access) canSee(guildID string) bool {
+	_, member := a.Guilds[guildID]
+	return a.Superuser || (guildID != "" && member)
+}
+
+func (a Access) canManage(guildID string) bool {
+	return a.Superuser || (guildID != "" && a.Guilds[guildID])
+}
+
+// Server serves the dashboard.
+type Server struct {
+	Queue      *exec.TaskQueue
+	OAuth      *OAuth
+	Access     func(userID string) Access
+	GPUs       func(ctx context.Context) ([]GPU, error) // optional; the GPU panel is hidden without it
+	Paused     func(paused bool, user User)             // optional; called after an admin pauses or resumes the queue
+	Cancelled  func(info exec.TaskInfo, user User)      // optional; called after an admin cancels a job
+	SessionTTL time.Duration
+
+	sessions *sessions
+	accesses accessCache
+}
+
+// Handler returns the dashboard's routes.
+func (s *Server) Handler() http.Handler {
+	s.sessions = &sessions{ttl: s.SessionTTL}
+
+	mux := http.NewServeMux()
+	page, _ := fs.Sub(static, "static")
+	mux.Handle("GET /", http.FileServerFS(page))
+	mux.HandleFunc("GET /login", s.login)
+	mux.HandleFunc("GET /callback", s.callback)
+	mux.HandleFunc("POST /logout", s.action(s.logout))
+	mux.HandleFunc("GET /api/me", s.signedIn(s.me))
+	mux.HandleFunc("GET /api/queue", s.signedIn(s.queue))
+	mux.HandleFunc("GET /api/history", s.signedIn(s.history))
+	mux.HandleFunc("GET /api/gpus", s.signedIn(s.gpus))
+	mux.HandleFunc("GET /audio/{id}/{n}", s.signedIn(s.audio))
+	mux.HandleFunc("POST /api/jobs/{id}/cancel", s.action(s.signedIn(s.cancel)))
+	mux.HandleFunc("POST /api/queue/pause", s.action(s.signedIn(s.pause(true))))
+	mux.HandleFunc("POST /api/queue/resume", s.action(s.signedIn(s.pause(false))))
+	return mux
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	state := randomToken()
+	http.SetCookie(w, &http.Cookie{
+		Name: stateCookie, Value: state, Path: "/", MaxAge: 600,
+		HttpOnly: true, Secure: r.TLS != nil || isHTTPS(s.OAuth.RedirectURL), SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, s.OAuth.AuthURL(state), http.StatusFound)
+}
+
+func (s *Server) callback(w http.ResponseWriter, r *http.Request) {
+	state, err := r.Cookie(stateCookie)
+	if err != nil || state.Value == "" || r.URL.Query().Get("state") != state.Value {
+		http.Error(w, "sign-in expired or was started elsewhere; try again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/", MaxAge: -1})
+
+	user, err := s.OAuth.Exchange(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		slog.Warn("dashboard sign-in failed: ", err)
+		http.Error(w, "couldn't sign in with Discord", http.StatusBadGateway)
+		return
+	}
+	slog.Info("dashboard sign-in by ", user.Username, " (", user.ID, ")")
+
+	http.SetCookie(w, &http.Cookie{
+		Name: sessionCookie, Value: s.sessions.create(user, time.Now()), Path: "/", MaxAge: int(s.SessionTTL.Seconds()),
+		HttpOnly: true, Secure: r.TLS != nil || isHTTPS(s.OAuth.RedirectURL), SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		s.sessions.remove(cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type userHandler func(w http.ResponseWriter, r *http.Request, user User, access Access)
+
+// signedIn looks up the request's session and the user's access before calling next.
+func (s *Server) signedIn(next userHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil {
+			http.Error(w, "not signed in", http.StatusUnauthorized)
+			return
+		}
+		user, ok := s.sessions.get(cookie.Value, time.Now())
+		if !ok {
+			http.Error(w, "not signed in", http.StatusUnauthorized)
+			return
+		}
+		next(w, r, user, s.accesses.get(user.ID, s.Access, time.Now()))
+	}
+}
+
+// action rejects requests without ActionHeader.
+func (s *Server) action(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(ActionHeader) == "" {
+			http.Error(w, "missing "+ActionHeader+" header", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) me(w http.ResponseWriter, r *http.Request, user User, access Access) {
+	writeJSON(w, map[string]any{
+		"user":  user,
+		"admin": access.Superuser,
+		"gpus":  s.GPUs != nil,
+	})
+}
+
+func (s *Server) queue(w http.ResponseWriter, r *http.Request, user User, access Access) {
+	paused, _, waiting := s.Queue.Status()
+	writeJSON(w, map[string]any{
+		"paused":  paused,
+		"waiting": waiting, // includes jobs from guilds the user can't see
+		"jobs":    s.visible(s.Queue.Jobs(), access),
+	})
+}
+
+func (s *Server) history(w http.ResponseWriter, r *http.Request, user User, access Access) {
+	writeJSON(w, map[string]any{"jobs": s.visible(s.Queue.History(), access)})
+}
+
+func (s *Server) gpus(w http.ResponseWriter, r *http.Request, user User, access Access) {
+	if s.GPUs == nil {
+		http.Error(w, "GPU stats aren't available", http.StatusNotFound)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	gpus, err := s.GPUs(ctx)
+	if err != nil {
+		slog.Warn("couldn't read GPU stats: ", err)
+		http.Error(w, "couldn't read GPU stats", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, map[string]any{"gpus": gpus})
+}
+
+func (s *Server) audio(w http.ResponseWriter, r *http.Request, user User, access Access) {
+	info, ok := s.Queue.Info(r.PathValue("id"))
+	n, err := strconv.Atoi(r.PathValue("n"))
+	if !ok || err != nil || !access.canSee(jobOf(info).GuildID) {
+		http.NotFound(w, r)
+		return
+	}
+	outputs := outputsOf(info)
+	if n < 0 || n >= len(outputs) {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, outputs[n])
+}
+
+func (s *Server) cancel(w http.ResponseWriter, r *http.Request, user User, access Access) {
+	info, ok := s.Queue.Info(r.PathValue("id"))
+	if !ok || !access.canSee(jobOf(info).GuildID) {
+		http.Error(w, "no such job", http.StatusNotFound)
+		return
+	}
+	if !access.canManage(jobOf(info).GuildID) {
+		http.Error(w, "only admins of the job's server can cancel it", http.StatusForbidden)
+		return
+	}
+	if !s.Queue.CancelJob(info.ID) {
+		http.Error(w, "the job has finished or can't be interrupted", http.StatusConflict)
+		return
+	}
+	slog.Info("dashboard: ", user.Username, " (", user.ID, ") cancelled job ", info.ID)
+	if s.Cancelled != nil {
+		s.Cancelled(info, user)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) pause(paused bool) userHandler {
+	return func(w http.ResponseWriter, r *http.Request, user User, access Access) {
+		if !access.Superuser {
+			http.Error(w, "only the bot's admins can pause the queue", http.StatusForbidden)
+			return
+		}
+		if paused {
+			s.Queue.Pause()
+		} else {
+			s.Queue.Resume()
+		}
+		slog.Info("dashboard: ", user.Username, " (", user.ID, ") set queue paused=", paused)
+		if s.Paused != nil {
+			s.Paused(paused, user)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Job is a job as the dashboard shows it.
+type Job struct {
+	ID        string     `json:"id"`
+	State     string     `json:"state"`
+	Position  int        `json:"position"`
+	Prompt    string     `json:"prompt"`
+	Progress  string     `json:"progress,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	GuildID   string     `json:"guild_id,omitempty"`
+	ChannelID string     `json:"channel_id,omitempty"`
+	MessageID string     `json:"message_id,omitempty"`
+	Submitter string     `json:"submitter,omitempty"`
+	Link      string     `json:"link,omitempty"` // the triggering message in Discord
+	Audio     []string   `json:"audio,omitempty"`
+	Enqueued  time.Time  `json:"enqueued"`
+	Started   *time.Time `json:"started,omitempty"`
+	Finished  *time.Time `json:"finished,omitempty"`
+	CanCancel bool       `json:"can_cancel"`
+}
+
+// triggered is implemented by commands, which keep the Discord message that started them.
+type triggered interface {
+	TriggerMessage() *discordgo.MessageCreate
+}
+
+func (s *Server) visible(infos []exec.TaskInfo, access Access) []Job {
+	jobs := []Job{}
+	for _, info := range infos {
+		job := jobOf(info)
+		if !access.canSee(job.GuildID) {
+			continue
+		}
+		job.CanCancel = access.canManage(job.GuildID) && (info.State == exec.StateWaiting || info.State == exec.StateRunning)
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+func jobOf(info exec.TaskInfo) Job {
+	job := Job{
+		ID:       info.ID,
+		State:    string(info.State),
+		Position: info.Position,
+		Prompt:   info.Task.Prompt(),
+		Enqueued: info.Enqueued,
+		Started:  optionalTime(info.Started),
+		Finished: optionalTime(info.Finished),
+	}
+	if t, ok := info.Task.(triggered); ok {
+		if m := t.TriggerMessage(); m != nil && m.Message != nil {
+			job.GuildID, job.ChannelID, job.MessageID = m.GuildID, m.ChannelID, m.ID
+			if m.Author != nil {
+				job.Submitter = m.Author.Username
+			}
+			guild := m.GuildID
+			if guild == "" {
+				guild = "@me"
+			}
+			job.Link = "https://discord.com/channels/" + guild + "/" + m.ChannelID + "/" + m.ID
+		}
+	}
+	if progressing, ok := info.Task.(exec.Progressing); ok && info.State == exec.StateRunning {
+		job.Progress = progressing.Progress()
+	}
+	if info.Err != nil {
+		// only what the submitter was told; the raw error is in the logs and alerts
+		job.Error = commands.FailureText(nil, info.Err, "")
+	}
+	for i := range outputsOf(info) {
+		job.Audio = append(job.Audio, "/audio/"+info.ID+"/"+strconv.Itoa(i))
+	}
+	return job
+}
+
+func outputsOf(info exec.TaskInfo) []string {
+	if info.State != exec.StateDone {
+		return nil
+	}
+	if producing, ok := info.Task.(exec.Producing); ok {
+		return producing.Outputs()
+	}
+	return nil
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(v)
+}
+
+func isHTTPS(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "https://")
+}
@@ -0,0 +1,198 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"slugbot/internal/commands"
+	"slugbot/internal/exec"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTask struct {
+	message *discordgo.MessageCreate
+	release chan struct{}
+}
+
+func newFakeTask(guildID string, messageID string) *fakeTask {
+	return &fakeTask{
+		message: &discordgo.MessageCreate{Message: &discordgo.Message{
+			ID: messageID, GuildID: guildID, ChannelID: "channel", Author: &discordgo.User{Username: "slug"},
+		}},
+		release: make(chan struct{}),
+	}
+}
+
+func (t *fakeTask) Apply() error                             { <-t.release; return nil }
+func (t *fakeTask) HandleError(error)                        {}
+func (t *fakeTask) Prompt() string                           { return "prompt " + t.message.ID }
+func (t *fakeTask) TraceID() string                          { return "" }
+func (t *fakeTask) TraceContext() context.Context            { return context.Background() }
+func (t *fakeTask) SetTraceContext(context.Context)          {}
+func (t *fakeTask) TriggerMessage() *discordgo.MessageCreate { return t.message }
+
+func TestParseGPUs(t *testing.T) {
+	gpus, err := parseGPUs("NVIDIA GeForce RTX 4090, 87, 20311, 24564, 71, 402.16\nTesla T4, 0, 3, 15360, 40, [N/A]\n")
+	require.NoError(t, err)
+	require.Equal(t, []GPU{
+		{Name: "NVIDIA GeForce RTX 4090", Utilization: 87, MemoryUsedMiB: 20311, MemoryMiB: 24564, TemperatureC: 71, PowerW: 402.16},
+		{Name: "Tesla T4", MemoryUsedMiB: 3, MemoryMiB: 15360, TemperatureC: 40},
+	}, gpus)
+
+	_, err = parseGPUs("not, csv")
+	require.Error(t, err)
+}
+
+func TestSignIn(t *testing.T) {
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/oauth2/token":
+			r.ParseForm()
+			if r.Form.Get("code") != "good-code" || r.Form.Get("client_secret") != "secret" {
+				http.Error(w, "bad code", http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "token", "token_type": "Bearer"})
+		case "/api/users/@me":
+			require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+			json.NewEncoder(w).Encode(User{ID: "42", Username: "slug"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer discord.Close()
+
+	server := &Server{
+		Queue:      exec.NewTaskQueue(),
+		Access:     func(string) Access { return Access{} },
+		SessionTTL: time.Hour,
+		OAuth: &OAuth{
+			ClientID:     "client",
+			ClientSecret: func() string { return "secret" },
+			RedirectURL:  "http://dashboard/callback",
+			Endpoint:     discord.URL,
+		},
+	}
+	dashboard := httptest.NewServer(server.Handler())
+	defer dashboard.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	resp, err := client.Get(dashboard.URL + "/api/me")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = client.Get(dashboard.URL + "/login")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	authURL, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, "/oauth2/authorize", authURL.Path)
+	require.Equal(t, "identify", authURL.Query().Get("scope"))
+	state := authURL.Query().Get("state")
+	require.NotEmpty(t, state)
+
+	// a callback that doesn't carry the state we handed out is refused
+	resp, err = client.Get(dashboard.URL + "/callback?code=good-code&state=forged")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = client.Get(dashboard.URL + "/callback?code=good-code&state=" + state)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	resp, err = client.Get(dashboard.URL + "/api/me")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var me struct {
+		User  User `json:"user"`
+		Admin bool `json:"admin"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&me))
+	require.Equal(t, User{ID: "42", Username: "slug"}, me.User)
+	require.False(t, me.Admin)
+}
+
+func TestQueueFollowsGuildRoles(t *testing.T) {
+	queue := exec.NewTaskQueue()
+	running := newFakeTask("g1", "m1")
+	defer close(running.release)
+	queue.Enqueue(running)
+	require.Eventually(t, func() bool { _, ok := queue.Running(); return ok }, time.Second, time.Millisecond)
+	queue.Enqueue(newFakeTask("g1", "m2"))
+	queue.Enqueue(newFakeTask("g2", "m3"))
+
+	access := map[string]Access{
+		"member": {Guilds: map[string]bool{"g1": false}},
+		"admin":  {Guilds: map[string]bool{"g1": true}},
+		"owner":  {Superuser: true},
+	}
+	server := &Server{
+		Queue:      queue,
+		OAuth:      &OAuth{},
+		Access:     func(userID string) Access { return access[userID] },
+		SessionTTL: time.Hour,
+	}
+	handler := server.Handler()
+
+	request := func(userID string, method string, path string, action bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: server.sessions.create(User{ID: userID}, time.Now())})
+		if action {
+			req.Header.Set(ActionHeader, "1")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("member", http.MethodGet, "/api/queue", false)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var queued struct {
+		Waiting int   `json:"waiting"`
+		Jobs    []Job `json:"jobs"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &queued))
+	require.Equal(t, 2, queued.Waiting)
+	require.Len(t, queued.Jobs, 2) // the g2 job is hidden
+	require.Equal(t, "https://discord.com/channels/g1/channel/m2", queued.Jobs[1].Link)
+	require.False(t, queued.Jobs[1].CanCancel)
+
+	waiting := queued.Jobs[1].ID
+	require.Equal(t, http.StatusForbidden, request("member", http.MethodPost, "/api/jobs/"+waiting+"/cancel", true).Code)
+	require.Equal(t, http.StatusForbidden, request("member", http.MethodPost, "/api/queue/pause", true).Code)
+	require.Equal(t, http.StatusForbidden, request("admin", http.MethodPost, "/api/jobs/"+waiting+"/cancel", false).Code)
+	require.Equal(t, http.StatusNoContent, request("admin", http.MethodPost, "/api/jobs/"+waiting+"/cancel", true).Code)
+
+	info, _ := queue.Info(waiting)
+	require.Equal(t, exec.StateCancelled, info.State)
+
+	// the queue is every guild's, so a guild admin can't pause it
+	require.Equal(t, http.StatusForbidden, request("admin", http.MethodPost, "/api/queue/pause", true).Code)
+	require.Equal(t, http.StatusNoContent, request("owner", http.MethodPost, "/api/queue/pause", true).Code)
+	paused, _, _ := queue.Status()
+	require.True(t, paused)
+}
+
+func TestJobOf_ShowsOnlyWhatTheSubmitterWasTold(t *testing.T) {
+	task := newFakeTask("guild", "m1")
+
+	told := jobOf(exec.TaskInfo{ID: "A", State: exec.StateFailed, Task: task,
+		Err: commands.NewUserError("Sorry, that prompt is too long.", errors.New("prompt has 9000 runes"))})
+	require.Equal(t, "Sorry, that prompt is too long.", told.Error)
+
+	internal := jobOf(exec.TaskInfo{ID: "B", State: exec.StateFailed, Task: task,
+		Err: errors.New("open /srv/slugbot/models/secret.ckpt: permission denied")})
+	require.NotEmpty(t, internal.Error)
+	require.NotContains(t, internal.Error, "/srv/slugbot")
+}
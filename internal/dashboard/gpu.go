@@ -0,0 +1,59 @@
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// GPU is one GPU's current load.
+type GPU struct {
+	Name          string  `json:"name"`
+	Utilization   int     `json:"utilization"` // percent
+	MemoryUsedMiB int     `json:"memory_used_mib"`
+	MemoryMiB     int     `json:"memory_mib"`
+	TemperatureC  int     `json:"temperature_c"`
+	PowerW        float64 `json:"power_w"`
+}
+
+var gpuQuery = []string{"name", "utilization.gpu", "memory.used", "memory.total", "temperature.gpu", "power.draw"}
+
+// NvidiaSMI returns a function that reads GPU stats with the nvidia-smi binary at path.
+func NvidiaSMI(path string) func(ctx context.Context) ([]GPU, error) {
+	return func(ctx context.Context) ([]GPU, error) {
+		out, err := exec.CommandContext(ctx, path,
+			"--query-gpu="+strings.Join(gpuQuery, ","), "--format=csv,noheader,nounits").Output()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't run %s: %w", path, err)
+		}
+		return parseGPUs(string(out))
+	}
+}
+
+// parseGPUs reads nvidia-smi's CSV output, one GPU per line. Fields a GPU
+// doesn't report ("[N/A]") are left at zero.
+func parseGPUs(out string) ([]GPU, error) {
+	var gpus []GPU
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != len(gpuQuery) {
+			return nil, fmt.Errorf("unexpected nvidia-smi output %q", line)
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		gpu := GPU{Name: fields[0]}
+		gpu.Utilization, _ = strconv.Atoi(fields[1])
+		gpu.MemoryUsedMiB, _ = strconv.Atoi(fields[2])
+		gpu.MemoryMiB, _ = strconv.Atoi(fields[3])
+		gpu.TemperatureC, _ = strconv.Atoi(fields[4])
+		gpu.PowerW, _ = strconv.ParseFloat(fields[5], 64)
+		gpus = append(gpus, gpu)
+	}
+	return gpus, nil
+}
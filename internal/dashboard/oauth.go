@@ -0,0 +1,197 @@
+package dashboard
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiscordEndpoint is where Discord's OAuth2 authorization and API live.
+const DiscordEndpoint = "https://discord.com"
+
+// User is a signed-in Discord user.
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// OAuth signs users in with Discord's authorization code flow. Only the
+// identify scope is requested: what a user may see and do is worked out from
+// their roles in the guilds the bot shares with them, not from their token.
+type OAuth struct {
+	ClientID     string
+	ClientSecret func() string // looked up per sign-in so it can be rotated
+	RedirectURL  string        // must match a redirect registered for the application, e.g. "https://slugbot.example.com/callback"
+	Endpoint     string        // defaults to DiscordEndpoint
+	Client       *http.Client  // defaults to http.DefaultClient
+}
+
+func (o *OAuth) endpoint() string {
+	if o.Endpoint == "" {
+		return DiscordEndpoint
+	}
+	return strings.TrimSuffix(o.Endpoint, "/")
+}
+
+func (o *OAuth) client() *http.Client {
+	if o.Client == nil {
+		return http.DefaultClient
+	}
+	return o.Client
+}
+
+// AuthURL returns the Discord page that asks the user to sign in, which then
+// redirects back to RedirectURL with state and an authorization code.
+func (o *OAuth) AuthURL(state string) string {
+	query := url.Values{
+		"client_id":     {o.ClientID},
+		"redirect_uri":  {o.RedirectURL},
+		"response_type": {"code"},
+		"scope":         {"identify"},
+		"state":         {state},
+		"prompt":        {"none"},
+	}
+	return o.endpoint() + "/oauth2/authorize?" + query.Encode()
+}
+
+// Exchange trades an authorization code for an access token and looks up who it belongs to.
+func (o *OAuth) Exchange(ctx context.Context, code string) (User, error) {
+	form := url.Values{
+		"client_id":     {o.ClientID},
+		"client_secret": {o.ClientSecret()},
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {o.RedirectURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint()+"/api/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return User{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+	}
+	if err := o.do(req, &token); err != nil {
+		return User{}, fmt.Errorf("couldn't exchange code: %w", err)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, o.endpoint()+"/api/users/@me", nil)
+	if err != nil {
+		return User{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	var user User
+	if err := o.do(req, &user); err != nil {
+		return User{}, fmt.Errorf("couldn't look up user: %w", err)
+	}
+	if user.ID == "" {
+		return User{}, fmt.Errorf("couldn't look up user: no ID in response")
+	}
+	return user, nil
+}
+
+func (o *OAuth) do(req *http.Request, out any) error {
+	resp, err := o.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", req.URL.Path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// sessions remembers signed-in users by a random cookie value. Sessions live
+// in memory, so everyone signs in again after a restart.
+type sessions struct {
+	ttl time.Duration
+
+	mutex sync.Mutex
+	users map[string]session
+}
+
+type session struct {
+	user    User
+	expires time.Time
+}
+
+func (s *sessions) create(user User, now time.Time) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.users == nil {
+		s.users = map[string]session{}
+	}
+	for id, existing := range s.users {
+		if now.After(existing.expires) {
+			delete(s.users, id)
+		}
+	}
+	id := randomToken()
+	s.users[id] = session{user: user, expires: now.Add(s.ttl)}
+	return id
+}
+
+func (s *sessions) get(id string, now time.Time) (User, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	existing, ok := s.users[id]
+	if !ok || now.After(existing.expires) {
+		return User{}, false
+	}
+	return existing.user, true
+}
+
+func (s *sessions) remove(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.users, id)
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// accessTTL is how long a user's access is reused before their roles are
+// looked up again. The page polls every few seconds, and looking roles up can
+// take an API call per guild.
+const accessTTL = time.Minute
+
+type accessCache struct {
+	mutex   sync.Mutex
+	entries map[string]cachedAccess
+}
+
+type cachedAccess struct {
+	access  Access
+	checked time.Time
+}
+
+func (c *accessCache) get(userID string, lookup func(userID string) Access, now time.Time) Access {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if cached, ok := c.entries[userID]; ok && now.Sub(cached.checked) < accessTTL {
+		return cached.access
+	}
+	if c.entries == nil {
+		c.entries = map[string]cachedAccess{}
+	}
+	access := lookup(userID)
+	c.entries[userID] = cachedAccess{access: access, checked: now}
+	return access
+}
@@ -0,0 +1,94 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"slugbot/internal/io/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ComponentHandler handles a button press, select-menu choice, or modal
+// submission. arg is the part of the component's custom ID after the prefix.
+type ComponentHandler func(s *discordgo.Session, i *discordgo.InteractionCreate, arg string) error
+
+// ComponentRouter dispatches message-component and modal-submit interactions by
+// the prefix of their custom ID, which is formatted "<prefix>:<arg>" by ComponentID.
+type ComponentRouter struct {
+	mutex    sync.RWMutex
+	handlers map[string]ComponentHandler
+}
+
+// NewComponentRouter returns an empty router.
+func NewComponentRouter() *ComponentRouter {
+	return &ComponentRouter{handlers: map[string]ComponentHandler{}}
+}
+
+// ComponentID builds a custom ID that routes to the handler registered for prefix.
+func ComponentID(prefix string, arg string) string {
+	return prefix + ":" + arg
+}
+
+// Handle registers a handler for every component whose custom ID starts with "<prefix>:".
+func (r *ComponentRouter) Handle(prefix string, handler ComponentHandler) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.handlers[prefix] = handler
+}
+
+// Route dispatches a component or modal interaction, ignoring interactions of
+// other types. Handler errors are logged and shown to the user ephemerally.
+func (r *ComponentRouter) Route(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var customID string
+	switch i.Type {
+	case discordgo.InteractionMessageComponent:
+		customID = i.MessageComponentData().CustomID
+	case discordgo.InteractionModalSubmit:
+		customID = i.ModalSubmitData().CustomID
+	default:
+		return
+	}
+
+	prefix, arg, _ := strings.Cut(customID, ":")
+	r.mutex.RLock()
+	handler, ok := r.handlers[prefix]
+	r.mutex.RUnlock()
+	if !ok {
+		slog.Warn("received component interaction with unknown prefix: ", prefix)
+		return
+	}
+
+	if err := handler(s, i, arg); err != nil {
+		slog.Error("component handler '", prefix, "' failed: ", err)
+		RespondEphemeral(s, i, fmt.Sprintf("Something went wrong: %v", err))
+	}
+}
+
+// RespondEphemeral replies to an interaction with a message only the user who triggered it can see.
+func RespondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) error {
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// InteractionUser returns the user who triggered an interaction, in a guild or a DM.
+func InteractionUser(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// InteractionUserID returns the ID of the user who triggered an interaction, in a guild or a DM.
+func InteractionUserID(i *discordgo.InteractionCreate) string {
+	if user := InteractionUser(i); user != nil {
+		return user.ID
+	}
+	return ""
+}
@@ -0,0 +1,38 @@
+package discord
+
+import (
+	"sync"
+	"time"
+)
+
+// Deduper remembers recently seen IDs, so events Discord redelivers after a
+// reconnect can be told apart from new ones.
+type Deduper struct {
+	TTL time.Duration // how long an ID is remembered
+
+	mutex sync.Mutex
+	seen  map[string]time.Time
+}
+
+// NewDeduper returns a deduper remembering IDs for ttl.
+func NewDeduper(ttl time.Duration) *Deduper {
+	return &Deduper{TTL: ttl, seen: map[string]time.Time{}}
+}
+
+// First records id and reports whether it's the first time it was seen within the TTL.
+func (d *Deduper) First(id string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+	for key, at := range d.seen {
+		if now.Sub(at) > d.TTL {
+			delete(d.seen, key)
+		}
+	}
+	if _, ok := d.seen[id]; ok {
+		return false
+	}
+	d.seen[id] = now
+	return true
+}
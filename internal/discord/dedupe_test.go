@@ -0,0 +1,18 @@
+package discord
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduper_FirstOnlyOncePerTTL(t *testing.T) {
+	d := NewDeduper(20 * time.Millisecond)
+	require.True(t, d.First("a"))
+	require.False(t, d.First("a"))
+	require.True(t, d.First("b"))
+
+	time.Sleep(30 * time.Millisecond)
+	require.True(t, d.First("a"))
+}
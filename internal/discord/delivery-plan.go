@@ -0,0 +1,96 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// MaxAttachments is how many files Discord accepts on one message.
+const MaxAttachments = 10
+
+// MaxMessageBytes is how much Discord accepts in all of one message's files.
+var MaxMessageBytes int64 = 25 << 20
+
+// PlanDelivery splits a message whose files don't fit in one message into
+// parts that each do, keeping the files in order. With more than one part,
+// each part's content ends with "(n/total)", the first part keeps the
+// message's content and components, and every part keeps its reference. Files
+// whose size can't be found (their reader isn't an io.Seeker) count as empty.
+func PlanDelivery(send *discordgo.MessageSend) []*discordgo.MessageSend {
+	var groups [][]*discordgo.File
+	var group []*discordgo.File
+	var groupBytes int64
+	for _, file := range send.Files {
+		size := fileSize(file)
+		if len(group) > 0 && (len(group) >= MaxAttachments || groupBytes+size > MaxMessageBytes) {
+			groups = append(groups, group)
+			group, groupBytes = nil, 0
+		}
+		group = append(group, file)
+		groupBytes += size
+	}
+	if len(groups) == 0 {
+		return []*discordgo.MessageSend{send}
+	}
+	groups = append(groups, group)
+
+	parts := make([]*discordgo.MessageSend, len(groups))
+	for i, files := range groups {
+		numbering := fmt.Sprintf("(%d/%d)", i+1, len(groups))
+		part := &discordgo.MessageSend{Content: numbering, Files: files, Reference: send.Reference, AllowedMentions: send.AllowedMentions}
+		if i == 0 {
+			part.Components = send.Components
+			if send.Content != "" {
+				part.Content = send.Content + "\n" + numbering
+			}
+		}
+		parts[i] = part
+	}
+	return parts
+}
+
+// fileSize returns how many bytes are left in a file's reader, or 0 if that
+// can't be found without reading it.
+func fileSize(file *discordgo.File) int64 {
+	seeker, ok := file.Reader.(io.Seeker)
+	if !ok {
+		return 0
+	}
+	at, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0
+	}
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if _, seekErr := seeker.Seek(at, io.SeekStart); err != nil || seekErr != nil {
+		return 0
+	}
+	return end - at
+}
+
+// SendParts sends a message with files like SendFiles, split into as many
+// replies as PlanDelivery needs. It returns the parts that were sent, in
+// order. A part that couldn't be delivered but was saved for later (see
+// ErrUndelivered) doesn't stop the rest; any other failure does.
+func SendParts(api ComplexSender, channelID string, send *discordgo.MessageSend) ([]*discordgo.Message, error) {
+	var sent []*discordgo.Message
+	var errs []error
+	parts := PlanDelivery(send)
+	for i, part := range parts {
+		msg, err := SendFiles(api, channelID, part)
+		if err != nil {
+			if len(parts) > 1 {
+				err = fmt.Errorf("part %d of %d: %w", i+1, len(parts), err)
+			}
+			errs = append(errs, err)
+			if !errors.Is(err, ErrUndelivered) {
+				break
+			}
+			continue
+		}
+		sent = append(sent, msg)
+	}
+	return sent, errors.Join(errs...)
+}
@@ -0,0 +1,76 @@
+package discord
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+func testFiles(count int, size int) []*discordgo.File {
+	files := make([]*discordgo.File, count)
+	for i := range files {
+		files[i] = &discordgo.File{Name: fmt.Sprintf("%d.wav", i), Reader: bytes.NewReader(make([]byte, size))}
+	}
+	return files
+}
+
+func names(files []*discordgo.File) []string {
+	var out []string
+	for _, file := range files {
+		out = append(out, file.Name)
+	}
+	return out
+}
+
+func TestPlanDelivery_FitsInOneMessage(t *testing.T) {
+	send := &discordgo.MessageSend{Content: "stems", Files: testFiles(MaxAttachments, 1)}
+	parts := PlanDelivery(send)
+	require.Equal(t, []*discordgo.MessageSend{send}, parts)
+}
+
+func TestPlanDelivery_SplitsByCountWithNumbering(t *testing.T) {
+	reference := &discordgo.MessageReference{MessageID: "trigger"}
+	components := []discordgo.MessageComponent{discordgo.ActionsRow{}}
+	send := &discordgo.MessageSend{Content: "batch", Reference: reference, Components: components, Files: testFiles(23, 1)}
+
+	parts := PlanDelivery(send)
+	require.Len(t, parts, 3)
+	require.Equal(t, "batch\n(1/3)", parts[0].Content)
+	require.Equal(t, "(2/3)", parts[1].Content)
+	require.Equal(t, "(3/3)", parts[2].Content)
+	require.Equal(t, components, parts[0].Components)
+	require.Nil(t, parts[1].Components)
+	require.Len(t, parts[0].Files, 10)
+	require.Len(t, parts[1].Files, 10)
+	require.Equal(t, []string{"20.wav", "21.wav", "22.wav"}, names(parts[2].Files))
+	for _, part := range parts {
+		require.Same(t, reference, part.Reference)
+	}
+}
+
+func TestPlanDelivery_SplitsBySize(t *testing.T) {
+	defer func(limit int64) { MaxMessageBytes = limit }(MaxMessageBytes)
+	MaxMessageBytes = 100
+
+	parts := PlanDelivery(&discordgo.MessageSend{Files: testFiles(5, 40)})
+	require.Len(t, parts, 3)
+	require.Equal(t, []string{"0.wav", "1.wav"}, names(parts[0].Files))
+	require.Equal(t, []string{"4.wav"}, names(parts[2].Files))
+	require.Equal(t, "(1/3)", parts[0].Content)
+
+	// the sizes were found without consuming the readers
+	require.Equal(t, 40, parts[0].Files[0].Reader.(*bytes.Reader).Len())
+}
+
+func TestSendParts_SendsEveryPartInOrder(t *testing.T) {
+	api := &flakySender{}
+	sent, err := SendParts(api, "c1", &discordgo.MessageSend{Content: "frames", Files: testFiles(12, 1)})
+	require.NoError(t, err)
+	require.Len(t, sent, 2)
+	require.True(t, strings.HasSuffix(api.sent[0].Content, "(1/2)"))
+	require.Equal(t, []string{"10.wav", "11.wav"}, names(api.sent[1].Files))
+}
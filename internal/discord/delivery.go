@@ -0,0 +1,115 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"slugbot/internal/io/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Delivery records one uploaded result of a job group.
+type Delivery struct {
+	Index     int
+	Path      string
+	MessageID string
+}
+
+// DeliveryCoordinator uploads the outputs of a multi-file job (a batch, a set
+// of stems, ...) as soon as each one is ready, with bounded concurrency, so early
+// results reach the channel while later ones are still rendering.
+type DeliveryCoordinator struct {
+	API       FileSender
+	ChannelID string
+	ReplyToID string
+	Total     int                    // expected number of outputs, used for "(n/total)" captions; 0 if unknown
+	Caption   func(index int) string // optional; replaces the "(n/total)" caption
+
+	slots     chan struct{}
+	wg        sync.WaitGroup
+	mutex     sync.Mutex
+	delivered []Delivery
+	errs      []error
+}
+
+// NewDeliveryCoordinator creates a coordinator uploading at most parallelism files at once.
+func NewDeliveryCoordinator(api FileSender, channelID string, replyToID string, total int, parallelism int) (*DeliveryCoordinator, error) {
+	if api == nil {
+		return nil, fmt.Errorf("NewDeliveryCoordinator: received nil API")
+	}
+	if channelID == "" {
+		return nil, fmt.Errorf("NewDeliveryCoordinator: received empty channelID string")
+	}
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	return &DeliveryCoordinator{
+		API:       api,
+		ChannelID: channelID,
+		ReplyToID: replyToID,
+		Total:     total,
+		slots:     make(chan struct{}, parallelism),
+	}, nil
+}
+
+// Deliver starts uploading the output at path in the background. index is the
+// output's 0-based position in the group and is used for captions and ordering.
+func (d *DeliveryCoordinator) Deliver(index int, path string) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.slots <- struct{}{}
+		defer func() { <-d.slots }()
+
+		messageID, err := d.upload(index, path)
+
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		if err != nil {
+			slog.Error("failed to deliver ", path, ": ", err)
+			d.errs = append(d.errs, fmt.Errorf("output %d: %w", index+1, err))
+			return
+		}
+		d.delivered = append(d.delivered, Delivery{Index: index, Path: path, MessageID: messageID})
+	}()
+}
+
+// Wait blocks until every started upload has finished. It returns the
+// deliveries sorted by index and the joined errors of any failed uploads.
+func (d *DeliveryCoordinator) Wait() ([]Delivery, error) {
+	d.wg.Wait()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delivered := append([]Delivery(nil), d.delivered...)
+	sort.Slice(delivered, func(i, j int) bool { return delivered[i].Index < delivered[j].Index })
+	return delivered, errors.Join(d.errs...)
+}
+
+func (d *DeliveryCoordinator) upload(index int, path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file for uploading: %w", err)
+	}
+	defer file.Close()
+
+	caption := ""
+	if d.Caption != nil {
+		caption = d.Caption(index)
+	} else if d.Total > 1 {
+		caption = fmt.Sprintf("(%d/%d)", index+1, d.Total)
+	}
+	msg, err := d.API.ChannelMessageSendFiles(d.ChannelID, caption, d.ReplyToID, []*discordgo.File{{
+		Name:   filepath.Base(path),
+		Reader: file,
+	}})
+	if err != nil {
+		return "", err
+	}
+	return msg.ID, nil
+}
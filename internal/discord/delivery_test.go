@@ -0,0 +1,93 @@
+package discord
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeFileSender struct {
+	mutex     sync.Mutex
+	inFlight  int
+	maxFlight int
+	captions  []string
+	failNames map[string]bool
+}
+
+func (f *fakeFileSender) ChannelMessageSendFiles(channelID, content, replyToID string, files []*discordgo.File) (ConcreteMessage, error) {
+	f.mutex.Lock()
+	f.inFlight++
+	f.maxFlight = max(f.maxFlight, f.inFlight)
+	f.captions = append(f.captions, content)
+	f.mutex.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.inFlight--
+	if f.failNames[files[0].Name] {
+		return ConcreteMessage{}, errors.New("upload failed")
+	}
+	return ConcreteMessage{ID: "msg-" + files[0].Name}, nil
+}
+
+func writeOutputs(t *testing.T, n int) []string {
+	dir := t.TempDir()
+	var paths []string
+	for i := range n {
+		path := filepath.Join(dir, string(rune('a'+i))+".wav")
+		require.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestDeliveryCoordinator_BoundsConcurrencyAndOrdersResults(t *testing.T) {
+	api := &fakeFileSender{}
+	paths := writeOutputs(t, 6)
+
+	d, err := NewDeliveryCoordinator(api, "chan", "reply", len(paths), 2)
+	require.NoError(t, err)
+	for i := len(paths) - 1; i >= 0; i-- {
+		d.Deliver(i, paths[i])
+	}
+
+	delivered, err := d.Wait()
+	require.NoError(t, err)
+	require.Len(t, delivered, 6)
+	for i, delivery := range delivered {
+		require.Equal(t, i, delivery.Index)
+		require.Equal(t, "msg-"+filepath.Base(paths[i]), delivery.MessageID)
+	}
+	require.LessOrEqual(t, api.maxFlight, 2)
+	require.Contains(t, api.captions, "(1/6)")
+}
+
+func TestDeliveryCoordinator_CollectsFailures(t *testing.T) {
+	paths := writeOutputs(t, 3)
+	api := &fakeFileSender{failNames: map[string]bool{filepath.Base(paths[1]): true}}
+
+	d, err := NewDeliveryCoordinator(api, "chan", "", len(paths), 3)
+	require.NoError(t, err)
+	for i, path := range paths {
+		d.Deliver(i, path)
+	}
+
+	delivered, err := d.Wait()
+	require.Error(t, err)
+	require.Len(t, delivered, 2)
+}
+
+func TestNewDeliveryCoordinator_Validation(t *testing.T) {
+	_, err := NewDeliveryCoordinator(nil, "chan", "", 1, 1)
+	require.Error(t, err)
+	_, err = NewDeliveryCoordinator(&fakeFileSender{}, "", "", 1, 1)
+	require.Error(t, err)
+}
@@ -0,0 +1,129 @@
+package discord
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"slugbot/internal/io/slog"
+	"slugbot/internal/store"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// inFlightBucket remembers the uploads SendFiles has started, keyed by ID,
+// so the results a crash interrupts can be found on disk and delivered later.
+const inFlightBucket = "in_flight"
+
+// named is a file reader that knows its path, like *os.File.
+type named interface {
+	Name() string
+}
+
+// trackUpload remembers an upload until it's untracked, if its files are all
+// on disk where a later run can find them again. It returns the upload's ID,
+// or "" if it isn't tracked.
+func trackUpload(channelID string, send *discordgo.MessageSend) string {
+	if undelivered.store == nil || len(send.Files) == 0 {
+		return ""
+	}
+	idBytes := make([]byte, 6)
+	if _, err := rand.Read(idBytes); err != nil {
+		return ""
+	}
+	entry := Undelivered{ID: hex.EncodeToString(idBytes), ChannelID: channelID, Content: send.Content, Saved: time.Now()}
+	if send.Reference != nil {
+		entry.ReplyToID = send.Reference.MessageID
+	}
+	entry.SubmitterID = submitterOf(channelID, entry.ReplyToID)
+	for _, file := range send.Files {
+		reader, ok := file.Reader.(named)
+		if !ok {
+			return ""
+		}
+		entry.Files = append(entry.Files, UndeliveredFile{Name: file.Name, ContentType: file.ContentType, Path: reader.Name()})
+	}
+	if err := undelivered.store.Put(inFlightBucket, entry.ID, entry); err != nil {
+		slog.Warn("couldn't remember upload to ", channelID, ": ", err)
+		return ""
+	}
+	return entry.ID
+}
+
+func untrackUpload(id string) {
+	if id == "" || undelivered.store == nil {
+		return
+	}
+	if err := undelivered.store.Delete(inFlightBucket, id); err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.Warn("couldn't forget upload ", id, ": ", err)
+	}
+}
+
+// RecoverUploads saves the results whose upload an earlier run started but
+// never finished as undelivered, so Redeliver can send them, and returns
+// them. Uploads whose files are gone from disk are just forgotten, and so are
+// those that fail to be saved, which are reported in the error.
+func RecoverUploads() ([]Undelivered, error) {
+	if undelivered.store == nil {
+		return nil, nil
+	}
+	keys, err := undelivered.store.Keys(inFlightBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	var recovered []Undelivered
+	var errs []error
+	for _, key := range keys {
+		var upload Undelivered
+		if err := undelivered.store.Get(inFlightBucket, key, &upload); err != nil {
+			errs = append(errs, fmt.Errorf("couldn't load upload %s: %w", key, err))
+			untrackUpload(key)
+			continue
+		}
+		entry, err := saveUpload(upload)
+		untrackUpload(key)
+		if errors.Is(err, os.ErrNotExist) {
+			slog.Info("forgetting interrupted upload ", key, " whose files are gone")
+			continue
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("couldn't save upload %s: %w", key, err))
+			continue
+		}
+		recovered = append(recovered, entry)
+	}
+	return recovered, errors.Join(errs...)
+}
+
+// saveUpload copies the files of an interrupted upload into a new undelivered result.
+func saveUpload(upload Undelivered) (Undelivered, error) {
+	send := &discordgo.MessageSend{Content: upload.Content}
+	if upload.ReplyToID != "" {
+		send.Reference = &discordgo.MessageReference{MessageID: upload.ReplyToID, ChannelID: upload.ChannelID}
+	}
+	for _, saved := range upload.Files {
+		file, err := os.Open(saved.Path)
+		if err != nil {
+			return Undelivered{}, err
+		}
+		defer file.Close()
+		send.Files = append(send.Files, &discordgo.File{Name: saved.Name, ContentType: saved.ContentType, Reader: file})
+	}
+
+	entry, err := saveMessage(upload.ChannelID, send)
+	if err != nil {
+		return Undelivered{}, err
+	}
+	// who asked for it was recorded while the last run still knew
+	entry.SubmitterID = upload.SubmitterID
+	if err := undelivered.store.Put(undeliveredBucket, entry.ID, entry); err != nil {
+		os.RemoveAll(filepath.Join(undelivered.dir, entry.ID))
+		return Undelivered{}, err
+	}
+	slog.Warn("saved interrupted upload ", upload.ID, " as undelivered result ", entry.ID, " for channel ", entry.ChannelID)
+	return entry, nil
+}
@@ -0,0 +1,75 @@
+package discord
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"slugbot/internal/store"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverUploads_SavesInterruptedUploadsAsUndelivered(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	KeepUndelivered(s, t.TempDir())
+	defer KeepUndelivered(nil, "")
+
+	outputs := t.TempDir()
+	kept := filepath.Join(outputs, "kept.wav")
+	gone := filepath.Join(outputs, "gone.wav")
+	require.NoError(t, os.WriteFile(kept, []byte("audio"), 0o644))
+	require.NoError(t, os.WriteFile(gone, []byte("audio"), 0o644))
+
+	// two uploads the "crash" cut off, one of whose files is cleaned up since
+	for _, path := range []string{kept, gone} {
+		file, err := os.Open(path)
+		require.NoError(t, err)
+		defer file.Close()
+		require.NotEmpty(t, trackUpload("c1", &discordgo.MessageSend{
+			Content:   "here you go",
+			Reference: &discordgo.MessageReference{MessageID: "trigger"},
+			Files:     []*discordgo.File{{Name: filepath.Base(path), Reader: file}},
+		}))
+	}
+	require.NoError(t, os.Remove(gone))
+
+	recovered, err := RecoverUploads()
+	require.NoError(t, err)
+	require.Len(t, recovered, 1)
+	require.Equal(t, "trigger", recovered[0].ReplyToID)
+
+	entries, err := UndeliveredResults()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	retry := &flakySender{}
+	_, _, err = Redeliver(retry, entries[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"audio"}, retry.reads)
+	require.Equal(t, "kept.wav", retry.sent[0].Files[0].Name)
+
+	recovered, err = RecoverUploads()
+	require.NoError(t, err)
+	require.Empty(t, recovered)
+}
+
+func TestSendFiles_ForgetsFinishedUploads(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	KeepUndelivered(s, t.TempDir())
+	defer KeepUndelivered(nil, "")
+
+	path := filepath.Join(t.TempDir(), "out.wav")
+	require.NoError(t, os.WriteFile(path, []byte("audio"), 0o644))
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	_, err = SendFiles(&flakySender{}, "c1", &discordgo.MessageSend{Files: []*discordgo.File{{Name: "out.wav", Reader: file}}})
+	require.NoError(t, err)
+	recovered, err := RecoverUploads()
+	require.NoError(t, err)
+	require.Empty(t, recovered)
+}
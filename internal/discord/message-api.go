@@ -83,6 +83,11 @@ func (api ConcreteSession) ChannelMessageDelete(channelID string, messageID stri
 	return nil
 }
 
+// shows the typing indicator in the channel for a few seconds. Errors are passed through directly.
+func (api ConcreteSession) ChannelTyping(channelID string) error {
+	return api.Session.ChannelTyping(channelID)
+}
+
 // captures the methods used for Discord messaging so they can be mocked.
 // ErrUnknownMessage should be used by tests to simulate a 404/UnknownMessage case.
 type SessionAPI interface {
@@ -92,6 +97,7 @@ type SessionAPI interface {
 	ChannelMessageSendReply(channelID string, content string, replyToID string) (ConcreteMessage, error)
 	ChannelMessageEdit(channelID string, messageID, content string) error
 	ChannelMessageDelete(channelID string, messageID string) error
+	ChannelTyping(channelID string) error
 }
 
 // helper to get a message using only its string id
@@ -108,3 +114,23 @@ func getMessage(session *discordgo.Session, channelID string, messageID string)
 	}
 	return msg, nil
 }
+
+// sends a message with attached files, optionally replying to another message, split into
+// several messages if they don't all fit in one; the first is returned. Errors are passed through directly.
+func (api ConcreteSession) ChannelMessageSendFiles(channelID string, content string, replyToID string, files []*discordgo.File) (ConcreteMessage, error) {
+	send := &discordgo.MessageSend{Content: content, Files: files}
+	if replyToID != "" {
+		send.Reference = &discordgo.MessageReference{MessageID: replyToID, ChannelID: channelID}
+	}
+	sent, err := SendParts(api.Session, channelID, send)
+	if err != nil {
+		return ConcreteMessage{}, err
+	}
+	return ConcreteMessage{ID: sent[0].ID}, nil
+}
+
+// FileSender captures the file-upload method separately from SessionAPI so
+// existing mocks needn't implement it.
+type FileSender interface {
+	ChannelMessageSendFiles(channelID string, content string, replyToID string, files []*discordgo.File) (ConcreteMessage, error)
+}
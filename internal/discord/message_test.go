@@ -46,6 +46,10 @@ func (f *fakeAPI) ChannelMessageDelete(channelID string, messageID string) error
 	return f.DeleteError
 }
 
+func (f *fakeAPI) ChannelTyping(channelID string) error {
+	return nil
+}
+
 // NewMessage tests
 func TestNewMessage_Success(t *testing.T) {
 	api := &fakeAPI{CheckError: nil}
@@ -0,0 +1,153 @@
+package discord
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"slugbot/internal/commands/traits"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	pageMaxChars = 1800 // well under the 4096 an embed description allows, to keep pages readable
+	pageMaxLines = 15
+	pageTTL      = time.Hour // how long a listing's buttons keep working
+	pagePrefix   = "page"
+)
+
+// Paginate groups lines into pages of at most maxLines lines and maxChars
+// characters. A single line longer than maxChars is cut to fit.
+func Paginate(lines []string, maxChars int, maxLines int) []string {
+	var pages []string
+	var page []string
+	size := 0
+	for _, line := range lines {
+		if r := []rune(line); len(r) > maxChars {
+			line = string(r[:maxChars-1]) + "…"
+		}
+		if len(page) > 0 && (len(page) >= maxLines || size+1+len(line) > maxChars) {
+			pages = append(pages, strings.Join(page, "\n"))
+			page, size = nil, 0
+		}
+		page = append(page, line)
+		size += len(line) + 1
+	}
+	if len(page) > 0 {
+		pages = append(pages, strings.Join(page, "\n"))
+	}
+	return pages
+}
+
+// listing is a paginated message whose buttons are still live.
+type listing struct {
+	title   string
+	pages   []string
+	expires time.Time
+}
+
+// Paginator sends long listings as an embed with ◀/▶ buttons that flip
+// between pages. Listings are kept in memory, so their buttons stop working
+// after an hour or a restart.
+type Paginator struct {
+	mutex    sync.Mutex
+	listings map[string]*listing
+}
+
+// NewPaginator returns a paginator whose buttons are routed by router.
+func NewPaginator(router *ComponentRouter) *Paginator {
+	p := &Paginator{listings: map[string]*listing{}}
+	router.Handle(pagePrefix, p.handle)
+	return p
+}
+
+// Send posts lines as a paginated listing in reply to reference, which may be nil.
+// Listings that fit on one page are sent without buttons.
+func (p *Paginator) Send(s *discordgo.Session, channelID string, reference *discordgo.MessageReference, title string, lines []string) (*discordgo.Message, error) {
+	pages := Paginate(lines, pageMaxChars, pageMaxLines)
+	if len(pages) == 0 {
+		pages = []string{"*Nothing to show.*"}
+	}
+
+	token := ""
+	if len(pages) > 1 {
+		token = traits.NewTraceID()
+		p.mutex.Lock()
+		for key, l := range p.listings {
+			if time.Now().After(l.expires) {
+				delete(p.listings, key)
+			}
+		}
+		p.listings[token] = &listing{title: title, pages: pages, expires: time.Now().Add(pageTTL)}
+		p.mutex.Unlock()
+	}
+
+	embeds := []*discordgo.MessageEmbed{pageEmbed(title, pages, 0)}
+	Brand(channelID, embeds)
+	return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embeds:     embeds,
+		Components: pageComponents(token, 0, len(pages)),
+		Reference:  reference,
+	})
+}
+
+// handle flips a listing to the page named in arg, formatted "<token>:<page>".
+func (p *Paginator) handle(s *discordgo.Session, i *discordgo.InteractionCreate, arg string) error {
+	token, pageStr, _ := strings.Cut(arg, ":")
+	page, err := strconv.Atoi(pageStr)
+	if err != nil {
+		return fmt.Errorf("invalid page %q", pageStr)
+	}
+
+	p.mutex.Lock()
+	l, ok := p.listings[token]
+	p.mutex.Unlock()
+	if !ok || time.Now().After(l.expires) {
+		return RespondEphemeral(s, i, "This listing has expired; run the command again to see it.")
+	}
+	page = max(0, min(page, len(l.pages)-1))
+
+	embeds := []*discordgo.MessageEmbed{pageEmbed(l.title, l.pages, page)}
+	Brand(i.ChannelID, embeds)
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     embeds,
+			Components: pageComponents(token, page, len(l.pages)),
+		},
+	})
+}
+
+func pageEmbed(title string, pages []string, page int) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{Title: title, Description: pages[page]}
+	if len(pages) > 1 {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Page %d/%d", page+1, len(pages))}
+	}
+	return embed
+}
+
+// pageComponents returns the ◀/▶ buttons for a page, or none for a single-page listing.
+func pageComponents(token string, page int, total int) []discordgo.MessageComponent {
+	if total <= 1 {
+		return nil
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "◀",
+				Style:    discordgo.SecondaryButton,
+				CustomID: ComponentID(pagePrefix, fmt.Sprintf("%s:%d", token, page-1)),
+				Disabled: page == 0,
+			},
+			discordgo.Button{
+				Label:    "▶",
+				Style:    discordgo.SecondaryButton,
+				CustomID: ComponentID(pagePrefix, fmt.Sprintf("%s:%d", token, page+1)),
+				Disabled: page == total-1,
+			},
+		}},
+	}
+}
@@ -0,0 +1,38 @@
+package discord
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaginate_SplitsByLinesAndSize(t *testing.T) {
+	lines := []string{"a", "b", "c", "d", "e"}
+	require.Equal(t, []string{"a\nb", "c\nd", "e"}, Paginate(lines, 100, 2))
+	require.Equal(t, []string{"a\nb\nc", "d\ne"}, Paginate(lines, 6, 10))
+	require.Nil(t, Paginate(nil, 100, 2))
+
+	long := Paginate([]string{strings.Repeat("x", 20)}, 10, 2)
+	require.Len(t, long, 1)
+	require.Equal(t, strings.Repeat("x", 9)+"…", long[0])
+}
+
+func TestPageComponents_DisablesButtonsAtTheEnds(t *testing.T) {
+	require.Nil(t, pageComponents("token", 0, 1))
+
+	buttons := func(page int) (discordgo.Button, discordgo.Button) {
+		row := pageComponents("token", page, 3)[0].(discordgo.ActionsRow)
+		return row.Components[0].(discordgo.Button), row.Components[1].(discordgo.Button)
+	}
+	prev, next := buttons(0)
+	require.True(t, prev.Disabled)
+	require.False(t, next.Disabled)
+	require.Equal(t, "page:token:1", next.CustomID)
+
+	prev, next = buttons(2)
+	require.False(t, prev.Disabled)
+	require.True(t, next.Disabled)
+	require.Equal(t, "page:token:1", prev.CustomID)
+}
@@ -0,0 +1,72 @@
+package discord
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Persona is how the bot presents itself in a guild.
+type Persona struct {
+	Nickname string // "" leaves the bot's own name
+	Color    int    // embed color; 0 leaves Discord's default
+}
+
+// personas looks up the persona messages are styled with, when set.
+var personas struct {
+	guildOf func(channelID string) string
+	lookup  func(guildID string) Persona
+}
+
+// UsePersonas styles messages for the guild they're sent to: guildOf finds the
+// guild a channel is in, and lookup that guild's persona. With nil functions,
+// messages aren't styled.
+func UsePersonas(guildOf func(channelID string) string, lookup func(guildID string) Persona) {
+	personas.guildOf, personas.lookup = guildOf, lookup
+}
+
+// PersonaFor returns a guild's persona, or the zero Persona if there's none.
+func PersonaFor(guildID string) Persona {
+	if personas.lookup == nil || guildID == "" {
+		return Persona{}
+	}
+	return personas.lookup(guildID)
+}
+
+// Brand colors the embeds of a message going to a channel with its guild's
+// persona, leaving ones that already have a color.
+func Brand(channelID string, embeds []*discordgo.MessageEmbed) {
+	if personas.guildOf == nil || len(embeds) == 0 {
+		return
+	}
+	color := PersonaFor(personas.guildOf(channelID)).Color
+	for _, embed := range embeds {
+		if embed != nil && embed.Color == 0 {
+			embed.Color = color
+		}
+	}
+}
+
+// ApplyNickname sets the bot's nickname in a guild to its persona's, if it has one.
+func ApplyNickname(s *discordgo.Session, guildID string) error {
+	nickname := PersonaFor(guildID).Nickname
+	if nickname == "" {
+		return nil
+	}
+	return s.GuildMemberNickname(guildID, "@me", nickname)
+}
+
+// ParseColor parses an embed color written "#rrggbb".
+func ParseColor(text string) (int, error) {
+	hex, ok := strings.CutPrefix(text, "#")
+	if !ok || len(hex) != 6 {
+		return 0, fmt.Errorf("`%s` isn't a color; write it like `#5865f2`", text)
+	}
+	color, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("`%s` isn't a color; write it like `#5865f2`", text)
+	}
+	return int(color), nil
+}
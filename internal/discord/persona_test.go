@@ -0,0 +1,43 @@
+package discord
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrand_ColorsUncoloredEmbeds(t *testing.T) {
+	UsePersonas(
+		func(channelID string) string { return map[string]string{"c1": "g1"}[channelID] },
+		func(guildID string) Persona { return map[string]Persona{"g1": {Color: 0x5865f2}}[guildID] },
+	)
+	t.Cleanup(func() { UsePersonas(nil, nil) })
+
+	embeds := []*discordgo.MessageEmbed{{Title: "plain"}, {Title: "colored", Color: 0xff0000}}
+	Brand("c1", embeds)
+	require.Equal(t, 0x5865f2, embeds[0].Color)
+	require.Equal(t, 0xff0000, embeds[1].Color)
+
+	other := []*discordgo.MessageEmbed{{Title: "plain"}}
+	Brand("c2", other)
+	require.Zero(t, other[0].Color)
+}
+
+func TestBrand_WithoutPersonas(t *testing.T) {
+	embeds := []*discordgo.MessageEmbed{{Title: "plain"}}
+	Brand("c1", embeds)
+	require.Zero(t, embeds[0].Color)
+	require.Equal(t, Persona{}, PersonaFor("g1"))
+}
+
+func TestParseColor(t *testing.T) {
+	color, err := ParseColor("#5865f2")
+	require.NoError(t, err)
+	require.Equal(t, 0x5865f2, color)
+
+	for _, bad := range []string{"5865f2", "#5865f", "#zzzzzz", "blue"} {
+		_, err := ParseColor(bad)
+		require.Error(t, err, bad)
+	}
+}
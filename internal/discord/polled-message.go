@@ -1,18 +1,47 @@
 package discord
 
 import (
+	"errors"
+	"sync"
 	"time"
 
 	"slugbot/internal/io/slog"
 	"slugbot/internal/utils"
 )
 
+// ErrNotStarted is returned when stopping a FilePollMessage that was never started.
+var ErrNotStarted = errors.New("poll message was never started")
+
+// ErrAlreadyStarted is returned when starting a FilePollMessage twice.
+var ErrAlreadyStarted = errors.New("poll message was already started")
+
+// ErrStopped is returned when starting a FilePollMessage after it was stopped.
+var ErrStopped = errors.New("poll message was already stopped")
+
+// pollState is where a FilePollMessage is in its life: New, then Started,
+// then Stopped. It never goes back.
+type pollState int
+
+const (
+	pollNew pollState = iota
+	pollStarted
+	pollStopped
+)
+
 // FilePollMessage ties a Discord message to a polled‐file.
 type FilePollMessage struct {
 	Message    *Message
 	PolledFile *utils.PollableFile
-	done       chan struct{}
 	FilePath   string
+	Footer     string              // appended to every version of the message, e.g. a trace ID
+	Render     func(string) string // optional; rewrites the polled file's text before it's shown
+	OnUpdate   func(string)        // optional; called with each rendered update, before the footer is added
+	Quiet      bool                // poll and call OnUpdate, but never send the message
+
+	mutex   sync.Mutex
+	state   pollState
+	done    chan struct{} // closed to halt polling
+	polling chan struct{} // closed once polling has halted
 }
 
 // NewFilePollMessage constructs the object.  interval is your polling interval.
@@ -22,38 +51,130 @@ func NewFilePollMessage(api SessionAPI, channelID string, replyToMessageID strin
 		return nil, err
 	}
 
-	done := make(chan struct{})
+	fpm := &FilePollMessage{
+		Message: msg,
+		done:    make(chan struct{}),
+		polling: make(chan struct{}),
+	}
 
 	pf, err := utils.NewPollableFile(interval, func(text string) {
-		err = msg.Update(text)
+		if fpm.Render != nil {
+			text = fpm.Render(text)
+		}
+		if fpm.OnUpdate != nil {
+			fpm.OnUpdate(text)
+		}
+		if fpm.Quiet {
+			return
+		}
+		err := msg.Update(fpm.withFooter(text))
 		if err != nil {
-			slog.Error("Failed to update message: %w", err)
+			slog.Error("failed to update message: ", err)
 		}
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &FilePollMessage{
-		Message:    msg,
-		PolledFile: pf,
-		done:       done,
-		FilePath:   pf.File,
-	}, nil
+	// show progress as soon as it's written; polling is only the fallback
+	pf.Watch = true
+	fpm.PolledFile = pf
+	fpm.FilePath = pf.File
+	return fpm, nil
 }
 
-// Start sends the first message with initialText, then begins polling.
+// Start sends the first message with initialText, unless it's Quiet, then begins polling.
 // After Start returns, an external process can write to fp.FilePath to drive updates to the message.
+// It returns ErrAlreadyStarted or ErrStopped if the message isn't new; if
+// sending fails, the message stays new and Start can be tried again.
 func (fpm *FilePollMessage) Start(initialText string) error {
-	if err := fpm.Message.Create(initialText); err != nil {
-		return err
+	fpm.mutex.Lock()
+	defer fpm.mutex.Unlock()
+
+	switch fpm.state {
+	case pollStarted:
+		return ErrAlreadyStarted
+	case pollStopped:
+		return ErrStopped
 	}
-	go fpm.PolledFile.Start(fpm.done)
+	if !fpm.Quiet {
+		if err := fpm.Message.Create(fpm.withFooter(initialText)); err != nil {
+			return err
+		}
+		trackProgress(fpm.Message)
+	}
+	fpm.state = pollStarted
+	go func() {
+		fpm.PolledFile.Start(fpm.done)
+		close(fpm.polling)
+	}()
 	return nil
 }
 
-// Stop halts polling and deletes the Discord message.
+// Stop halts polling and deletes the Discord message. Calling it again does
+// nothing; calling it before Start returns ErrNotStarted, and the message can't
+// be started afterwards.
 func (fpm *FilePollMessage) Stop() error {
+	return fpm.stop(fpm.Message.Delete)
+}
+
+// Finish halts polling and replaces the Discord message with summary, leaving
+// it in place. Like Stop, calling Stop or Finish afterwards does nothing.
+func (fpm *FilePollMessage) Finish(summary string) error {
+	return fpm.stop(func() error { return fpm.Message.Update(summary) })
+}
+
+// stop moves the message to Stopped, then runs last on it once polling has
+// halted, so a late progress update can't race with it.
+func (fpm *FilePollMessage) stop(last func() error) error {
+	fpm.mutex.Lock()
+	defer fpm.mutex.Unlock()
+
+	switch fpm.state {
+	case pollNew:
+		fpm.state = pollStopped
+		fpm.removePolledFile()
+		return ErrNotStarted
+	case pollStopped:
+		return nil
+	}
+	fpm.state = pollStopped
 	close(fpm.done)
-	return fpm.Message.Delete()
+	<-fpm.polling
+	fpm.removePolledFile()
+	if fpm.Quiet {
+		return nil
+	}
+	defer untrackProgress(fpm.Message.MessageID)
+	return last()
+}
+
+// removePolledFile deletes the polled file once nothing writes or reads it.
+func (fpm *FilePollMessage) removePolledFile() {
+	if fpm.PolledFile == nil {
+		return
+	}
+	if err := fpm.PolledFile.Remove(); err != nil {
+		slog.Warn("couldn't remove progress file ", fpm.FilePath, ": ", err)
+	}
+}
+
+// SetPhase shows the job moving on to phase by writing it to the polled
+// file (see WritePhase), once the message has started and until it stops.
+func (fpm *FilePollMessage) SetPhase(phase Phase) {
+	fpm.mutex.Lock()
+	defer fpm.mutex.Unlock()
+	if fpm.state != pollStarted {
+		return
+	}
+	if err := WritePhase(fpm.FilePath, phase); err != nil {
+		slog.Warn("couldn't write progress phase: ", err)
+	}
+}
+
+func (fpm *FilePollMessage) withFooter(text string) string {
+	if fpm.Footer == "" {
+		return text
+	}
+	return text + fpm.Footer
 }
@@ -3,6 +3,8 @@ package discord
 import (
 	"errors"
 	"os"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,12 +12,17 @@ import (
 )
 
 type mockSessionAPI struct {
-	CheckError       error
-	CreateError      error
-	EditError        error
-	DeleteError      error
-	CreatedMessageID string
-	data             receivedAPIData
+	CheckError        error
+	CreateError       error
+	EditError         error
+	DeleteError       error
+	CreatedMessageID  string
+	UnknownMessageIDs []string // ChannelMessage reports these as deleted
+	TypingErrors      []error  // ChannelTyping fails with these in turn, then succeeds
+	data              receivedAPIData
+
+	typingMutex sync.Mutex // ChannelTyping is called from its own goroutine
+	typed       int
 }
 
 type receivedAPIData struct {
@@ -27,7 +34,10 @@ func (f *mockSessionAPI) Check() error {
 }
 
 func (f *mockSessionAPI) ChannelMessage(channelID, messageID string) (ConcreteMessage, error) {
-	return ConcreteMessage{}, nil
+	if slices.Contains(f.UnknownMessageIDs, messageID) {
+		return ConcreteMessage{}, ErrUnknownMessage
+	}
+	return ConcreteMessage{ID: messageID}, nil
 }
 
 func (f *mockSessionAPI) ChannelMessageSend(channelID, content string) (ConcreteMessage, error) {
@@ -50,6 +60,24 @@ func (f *mockSessionAPI) ChannelMessageDelete(channelID, messageID string) error
 	return f.DeleteError
 }
 
+func (f *mockSessionAPI) ChannelTyping(channelID string) error {
+	f.typingMutex.Lock()
+	defer f.typingMutex.Unlock()
+	f.typed++
+	if len(f.TypingErrors) > 0 {
+		err := f.TypingErrors[0]
+		f.TypingErrors = f.TypingErrors[1:]
+		return err
+	}
+	return nil
+}
+
+func (f *mockSessionAPI) typedCount() int {
+	f.typingMutex.Lock()
+	defer f.typingMutex.Unlock()
+	return f.typed
+}
+
 // Test constructor
 func TestNewFilePollMessage_Success(t *testing.T) {
 	channelID := "test-channel-id"
@@ -138,3 +166,86 @@ func TestFilePollMessage_SendsFileUpdatesToMessage(t *testing.T) {
 	require.Equal(t, []string{"ChannelMessageEdit", channelID, messageID, string(updatedContent)}, api.data.calls[1])
 	require.Equal(t, []string{"ChannelMessageDelete", channelID, messageID}, api.data.calls[2])
 }
+
+func TestFilePollMessage_QuietOnlyCallsOnUpdate(t *testing.T) {
+	api := &mockSessionAPI{CreatedMessageID: "next-id-123"}
+	interval := 30 * time.Millisecond
+	fpm, _ := NewFilePollMessage(api, "test-channel-id", "test-replied-to-msg-id", interval)
+	fpm.Quiet = true
+	updates := make(chan string, 4)
+	fpm.OnUpdate = func(text string) { updates <- text }
+
+	require.NoError(t, fpm.Start("initial-content"))
+	time.Sleep(interval / 2)
+	require.NoError(t, os.WriteFile(fpm.FilePath, []byte("updated-content"), 0644))
+	require.Equal(t, "updated-content", <-updates)
+
+	require.NoError(t, fpm.Finish("generated in 12s"))
+	require.Empty(t, api.data.calls)
+}
+
+func TestFilePollMessage_FinishLeavesSummary(t *testing.T) {
+	channelID := "test-channel-id"
+	repliedToMessageID := "test-replied-to-msg-id"
+	messageID := "next-id-123"
+	api := &mockSessionAPI{CheckError: nil, CreatedMessageID: messageID}
+	fpm, _ := NewFilePollMessage(api, channelID, repliedToMessageID, time.Millisecond)
+	fpm.Footer = " footer"
+
+	require.NoError(t, fpm.Start("initial-content"))
+	require.NoError(t, fpm.Finish("generated in 12s"))
+	require.NoError(t, fpm.Stop())
+	require.Len(t, api.data.calls, 2)
+	require.Equal(t, []string{"ChannelMessageEdit", channelID, messageID, "generated in 12s"}, api.data.calls[1])
+	require.Equal(t, messageID, fpm.Message.MessageID)
+}
+
+func TestFilePollMessage_StopIsIdempotent(t *testing.T) {
+	api := &mockSessionAPI{CheckError: nil, CreatedMessageID: "next-id-123"}
+	fpm, _ := NewFilePollMessage(api, "test-channel-id", "test-replied-to-msg-id", time.Millisecond)
+
+	require.NoError(t, fpm.Start("initial-content"))
+	require.NoError(t, fpm.Stop())
+	require.NoError(t, fpm.Stop())
+	require.NoError(t, fpm.Finish("summary"))
+	require.Len(t, api.data.calls, 2)
+}
+
+func TestFilePollMessage_StopBeforeStart(t *testing.T) {
+	api := &mockSessionAPI{CheckError: nil, CreatedMessageID: "next-id-123"}
+	fpm, _ := NewFilePollMessage(api, "test-channel-id", "test-replied-to-msg-id", time.Millisecond)
+
+	require.ErrorIs(t, fpm.Stop(), ErrNotStarted)
+	require.NoError(t, fpm.Stop())
+	require.ErrorIs(t, fpm.Start("initial-content"), ErrStopped)
+	require.Empty(t, api.data.calls)
+
+	fpm, _ = NewFilePollMessage(api, "test-channel-id", "test-replied-to-msg-id", time.Millisecond)
+	require.ErrorIs(t, fpm.Finish("summary"), ErrNotStarted)
+	require.Empty(t, api.data.calls)
+}
+
+func TestFilePollMessage_StartTwice(t *testing.T) {
+	api := &mockSessionAPI{CheckError: nil, CreatedMessageID: "next-id-123"}
+	fpm, _ := NewFilePollMessage(api, "test-channel-id", "test-replied-to-msg-id", time.Millisecond)
+
+	require.NoError(t, fpm.Start("initial-content"))
+	require.ErrorIs(t, fpm.Start("initial-content"), ErrAlreadyStarted)
+	require.NoError(t, fpm.Stop())
+	require.ErrorIs(t, fpm.Start("initial-content"), ErrStopped)
+	require.Len(t, api.data.calls, 2)
+}
+
+func TestFilePollMessage_FailedStartCanBeRetried(t *testing.T) {
+	api := &mockSessionAPI{CheckError: nil, CreatedMessageID: "next-id-123", CreateError: errors.New("fail")}
+	fpm, _ := NewFilePollMessage(api, "test-channel-id", "test-replied-to-msg-id", time.Millisecond)
+
+	require.Error(t, fpm.Start("initial-content"))
+	require.ErrorIs(t, fpm.Stop(), ErrNotStarted)
+
+	fpm, _ = NewFilePollMessage(api, "test-channel-id", "test-replied-to-msg-id", time.Millisecond)
+	require.Error(t, fpm.Start("initial-content"))
+	api.CreateError = nil
+	require.NoError(t, fpm.Start("initial-content"))
+	require.NoError(t, fpm.Stop())
+}
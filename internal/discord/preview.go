@@ -0,0 +1,121 @@
+package discord
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"slugbot/internal/io/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// previewBucket holds the results waiting for their submitter's approval, keyed by ID.
+const previewBucket = "previews"
+
+// Component prefixes of the buttons under a preview.
+const (
+	PublishPrefix = "preview-publish"
+	DiscardPrefix = "preview-discard"
+)
+
+// previewSubmitter returns who has to approve a result replying to a message
+// before it's posted, or "" to post it right away; nil posts every result.
+var previewSubmitter func(channelID string, replyToID string) string
+
+// UsePreviews has SendFiles hold back results for approval. submitter returns
+// the user who has to approve a result replying to a message in a channel,
+// or "" if it should be posted right away.
+func UsePreviews(submitter func(channelID string, replyToID string) string) {
+	previewSubmitter = submitter
+}
+
+// DMSender opens DM channels; *discordgo.Session is one.
+type DMSender interface {
+	ComplexSender
+	UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+}
+
+// previewFor returns the user a result has to be approved by, if any.
+func previewFor(api ComplexSender, channelID string, send *discordgo.MessageSend) (DMSender, string) {
+	if previewSubmitter == nil || send.Reference == nil {
+		return nil, ""
+	}
+	dm, ok := api.(DMSender)
+	if !ok {
+		return nil, ""
+	}
+	return dm, previewSubmitter(channelID, send.Reference.MessageID)
+}
+
+// sendPreview saves a result and DMs it to the user who has to approve it,
+// with buttons to publish it in its channel or discard it.
+func sendPreview(api DMSender, userID string, channelID string, send *discordgo.MessageSend) (*discordgo.Message, error) {
+	entry, err := saveMessage(channelID, send)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't save the result for preview: %w", err)
+	}
+	discard := func() { os.RemoveAll(filepath.Join(undelivered.dir, entry.ID)) }
+
+	dm, err := api.UserChannelCreate(userID)
+	if err != nil {
+		discard()
+		return nil, fmt.Errorf("couldn't open a DM for the preview: %w", err)
+	}
+	if err := rewind(send.Files); err != nil {
+		discard()
+		return nil, err
+	}
+	preview := &discordgo.MessageSend{
+		Content:         joinPreview(send.Content, channelID),
+		Files:           send.Files,
+		Embeds:          send.Embeds,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "Publish", Style: discordgo.SuccessButton, CustomID: ComponentID(PublishPrefix, entry.ID)},
+				discordgo.Button{Label: "Discard", Style: discordgo.DangerButton, CustomID: ComponentID(DiscardPrefix, entry.ID)},
+			}},
+		},
+	}
+	msg, err := sendWithRetry(api, dm.ID, preview)
+	if err != nil {
+		discard()
+		return nil, fmt.Errorf("couldn't DM the preview: %w", err)
+	}
+	entry.NoticeID = msg.ID
+	entry.SubmitterID = userID
+
+	if err := undelivered.store.Put(previewBucket, entry.ID, entry); err != nil {
+		discard()
+		return nil, err
+	}
+	slog.Info("sent result ", entry.ID, " for channel ", channelID, " to ", userID, " for approval")
+	return msg, nil
+}
+
+// joinPreview heads a preview's content with where it would be posted.
+func joinPreview(content string, channelID string) string {
+	heading := fmt.Sprintf("Preview of your result for <#%s>; publish it there, or discard it.", channelID)
+	if content == "" {
+		return heading
+	}
+	return heading + "\n" + content
+}
+
+// LookupPreview returns a result waiting for approval by ID, or store.ErrNotFound.
+func LookupPreview(id string) (Undelivered, error) {
+	return lookupSaved(previewBucket, id)
+}
+
+// Publish posts a result waiting for approval in its channel, retrying like
+// SendFiles, then forgets it; if it fails, it stays saved.
+func Publish(api ComplexSender, id string) (*discordgo.Message, Undelivered, error) {
+	return resend(api, previewBucket, id)
+}
+
+// Discard forgets a result waiting for approval and removes its files, or
+// returns store.ErrNotFound if there's no such result.
+func Discard(id string) error {
+	return forgetSaved(previewBucket, id)
+}
@@ -0,0 +1,87 @@
+package discord
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"slugbot/internal/store"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+// dmSender is a flakySender that can open DM channels.
+type dmSender struct {
+	flakySender
+	opened []string
+}
+
+func (d *dmSender) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	d.opened = append(d.opened, recipientID)
+	return &discordgo.Channel{ID: "dm-" + recipientID, Type: discordgo.ChannelTypeDM}, nil
+}
+
+func TestSendFiles_PreviewsResultsAndPublishes(t *testing.T) {
+	noSleep(t)
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	KeepUndelivered(s, t.TempDir())
+	defer KeepUndelivered(nil, "")
+	UsePreviews(func(channelID string, replyToID string) string {
+		if replyToID == "trigger" {
+			return "u1"
+		}
+		return ""
+	})
+	defer UsePreviews(nil)
+
+	api := &dmSender{}
+	msg, err := SendFiles(api, "c1", &discordgo.MessageSend{
+		Content:   "here you go",
+		Reference: &discordgo.MessageReference{MessageID: "trigger", ChannelID: "c1"},
+		Files:     []*discordgo.File{{Name: "out.wav", Reader: bytes.NewReader([]byte("audio"))}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "sent", msg.ID)
+	require.Equal(t, []string{"u1"}, api.opened)
+	require.Len(t, api.sent, 1)
+	require.Contains(t, api.sent[0].Content, "here you go")
+	require.Len(t, api.sent[0].Components, 1)
+	require.Equal(t, []string{"audio"}, api.reads)
+
+	entries, err := UndeliveredResults()
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	publish := api.sent[0].Components[0].(discordgo.ActionsRow).Components[0].(discordgo.Button)
+	prefix, id, _ := strings.Cut(publish.CustomID, ":")
+	require.Equal(t, PublishPrefix, prefix)
+	entry, err := LookupPreview(id)
+	require.NoError(t, err)
+	require.Equal(t, "c1", entry.ChannelID)
+	require.Equal(t, "sent", entry.NoticeID)
+
+	public := &flakySender{}
+	_, _, err = Publish(public, id)
+	require.NoError(t, err)
+	require.Equal(t, "here you go", public.sent[0].Content)
+	require.Equal(t, []string{"audio"}, public.reads)
+
+	require.ErrorIs(t, Discard(id), store.ErrNotFound)
+}
+
+func TestSendFiles_PostsUnpreviewedResults(t *testing.T) {
+	noSleep(t)
+	UsePreviews(func(channelID string, replyToID string) string { return "" })
+	defer UsePreviews(nil)
+
+	api := &dmSender{}
+	_, err := SendFiles(api, "c1", &discordgo.MessageSend{
+		Reference: &discordgo.MessageReference{MessageID: "other", ChannelID: "c1"},
+		Files:     []*discordgo.File{{Name: "out.wav", Reader: bytes.NewReader([]byte("audio"))}},
+	})
+	require.NoError(t, err)
+	require.Empty(t, api.opened)
+	require.Nil(t, api.sent[0].Components)
+}
@@ -0,0 +1,189 @@
+package discord
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"slugbot/internal/format"
+)
+
+// tqdmRegex matches the counters of a tqdm progress bar such as
+// " 37%|███▋      | 37/100 [00:12<00:20,  3.01it/s]".
+var tqdmRegex = regexp.MustCompile(`(\d+)/(\d+)\s*\[([\d:]+)<([\d:]+|\?)`)
+
+// RenderTQDM rewrites a tqdm progress line into a friendlier progress message,
+// e.g. "37.0% (37/100 steps) · 12s elapsed · ~20s left". Text that doesn't look
+// like a tqdm line is returned unchanged.
+func RenderTQDM(text string) string {
+	m := tqdmRegex.FindStringSubmatch(text)
+	if m == nil {
+		return text
+	}
+	done, _ := strconv.Atoi(m[1])
+	total, _ := strconv.Atoi(m[2])
+	if total <= 0 {
+		return text
+	}
+
+	parts := []string{fmt.Sprintf("%s (%d/%d steps)", format.Percent(float64(done)/float64(total)), done, total)}
+	if elapsed, ok := parseClock(m[3]); ok {
+		parts = append(parts, format.Duration(elapsed)+" elapsed")
+	}
+	if remaining, ok := parseClock(m[4]); ok && done > 0 {
+		parts = append(parts, "~"+format.Duration(remaining)+" left")
+	}
+	return strings.Join(parts, " · ")
+}
+
+// tqdmRemaining returns how long a tqdm progress line says is left.
+func tqdmRemaining(text string) (time.Duration, bool) {
+	m := tqdmRegex.FindStringSubmatch(text)
+	if m == nil || m[1] == "0" {
+		return 0, false
+	}
+	return parseClock(m[4])
+}
+
+// parseClock parses tqdm's "MM:SS" or "H:MM:SS" times.
+func parseClock(clock string) (time.Duration, bool) {
+	var total time.Duration
+	for _, field := range strings.Split(clock, ":") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return 0, false
+		}
+		total = total*60 + time.Duration(n)
+	}
+	return total * time.Second, true
+}
+
+// Phase is the part of a job a progress file says it's in.
+type Phase string
+
+const (
+	PhaseDownloading    Phase = "downloading"
+	PhaseDiffusing      Phase = "diffusing"
+	PhasePostProcessing Phase = "post-processing"
+	PhaseUploading      Phase = "uploading"
+)
+
+// phaseIcons and phaseLabels show each phase in a progress line.
+var (
+	phaseIcons = map[Phase]string{
+		PhaseDownloading:    "⬇️",
+		PhaseDiffusing:      "🧠",
+		PhasePostProcessing: "🎛️",
+		PhaseUploading:      "⬆️",
+	}
+	phaseLabels = map[Phase]string{
+		PhaseDownloading:    "Downloading input",
+		PhaseDiffusing:      "Diffusing",
+		PhasePostProcessing: "Post-processing",
+		PhaseUploading:      "Uploading",
+	}
+)
+
+// phaseRegex matches a progress file's phase line, e.g. "phase: uploading" or
+// "phase: post-processing watermark".
+var phaseRegex = regexp.MustCompile(`^phase:\s*([\w-]+)\s*(.*)$`)
+
+// Progress is what a progress file's latest line says: a phase line as
+// written by WritePhase, a tqdm bar while diffusing, or any other text.
+type Progress struct {
+	Phase     Phase         // "" if the line doesn't say
+	Detail    string        // the rest of a phase line, or a line that's neither
+	Done      int           // steps done, for a tqdm bar
+	Total     int           // 0 unless it's a tqdm bar
+	Elapsed   time.Duration // for a tqdm bar
+	Remaining time.Duration // for a tqdm bar, once a step is done; else 0
+}
+
+// ParseProgress reads the latest line of a progress file, so a writer can
+// either replace the file's text or append to it.
+func ParseProgress(text string) Progress {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	line := strings.TrimSpace(lines[len(lines)-1])
+
+	if m := phaseRegex.FindStringSubmatch(line); m != nil {
+		return Progress{Phase: Phase(m[1]), Detail: m[2]}
+	}
+	m := tqdmRegex.FindStringSubmatch(line)
+	if m == nil {
+		return Progress{Detail: line}
+	}
+	progress := Progress{Phase: PhaseDiffusing}
+	progress.Done, _ = strconv.Atoi(m[1])
+	progress.Total, _ = strconv.Atoi(m[2])
+	progress.Elapsed, _ = parseClock(m[3])
+	if remaining, ok := parseClock(m[4]); ok && progress.Done > 0 {
+		progress.Remaining = remaining
+	}
+	return progress
+}
+
+// Line draws progress on one short line that fits a phone screen, e.g.
+// "🧠 Diffusing 37% (37/100) · ~20s left" or "⬆️ Uploading…". Text that isn't
+// in a known phase is shown as it is.
+func (p Progress) Line() string {
+	icon, ok := phaseIcons[p.Phase]
+	if !ok {
+		return strings.Join(strings.Fields(strings.TrimSpace(string(p.Phase)+" "+p.Detail)), " ")
+	}
+	line := icon + " " + phaseLabels[p.Phase]
+	if p.Total <= 0 {
+		if p.Detail != "" {
+			return line + " · " + p.Detail
+		}
+		return line + "…"
+	}
+
+	line += fmt.Sprintf(" %d%% (%d/%d)", p.Done*100/p.Total, p.Done, p.Total)
+	if p.Remaining > 0 {
+		return line + " · ~" + format.Duration(p.Remaining) + " left"
+	}
+	return line + " · " + format.Duration(p.Elapsed) + " in"
+}
+
+// RenderProgress draws a progress file's text as a Progress line; it's a
+// FilePollMessage.Render.
+func RenderProgress(text string) string {
+	return ParseProgress(text).Line()
+}
+
+// nearlyDoneLeft is how little time a diffusion bar can have left for its job
+// to be nearly done, however many steps it has.
+const nearlyDoneLeft = 10 * time.Second
+
+// NearlyDone reports whether a diffusion bar is close to its end: nine tenths
+// of the way there, or within seconds of it.
+func (p Progress) NearlyDone() bool {
+	if p.Total <= 0 {
+		return false
+	}
+	return p.Done*10 >= p.Total*9 || p.Remaining > 0 && p.Remaining <= nearlyDoneLeft
+}
+
+// HurryProgress is a PollableFile.Hurry for progress files: polling speeds up
+// when a job moves on to another phase or is nearly done, and slows down while
+// it's only counting steps or has nothing new to say.
+func HurryProgress(prev, text string) bool {
+	before, now := ParseProgress(prev), ParseProgress(text)
+	if now.Phase != before.Phase {
+		return true
+	}
+	if now.Total == 0 {
+		return now.Detail != before.Detail
+	}
+	return now.NearlyDone()
+}
+
+// WritePhase replaces a progress file's text with a phase line, so its
+// FilePollMessage shows the job moving on, e.g. to uploading once the tool
+// that was writing tqdm bars to it is done.
+func WritePhase(path string, phase Phase) error {
+	return os.WriteFile(path, []byte("phase: "+string(phase)+"\n"), 0o644)
+}
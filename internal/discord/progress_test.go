@@ -0,0 +1,60 @@
+package discord
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTQDM(t *testing.T) {
+	require.Equal(t,
+		"37.0% (37/100 steps) · 12s elapsed · ~1m 20s left",
+		RenderTQDM("` 37%|███▋      | 37/100 [00:12<01:20,  3.01it/s]`"))
+	require.Equal(t,
+		"0.0% (0/100 steps) · 0s elapsed",
+		RenderTQDM("`  0%|          | 0/100 [00:00<?, ?it/s]`"))
+	require.Equal(t,
+		"50.0% (50/100 steps) · 1h 2m 3s elapsed · ~1h 2m 3s left",
+		RenderTQDM("50/100 [1:02:03<1:02:03, 0.01it/s]"))
+	require.Equal(t, "loading model...", RenderTQDM("loading model..."))
+}
+
+func TestRenderProgress(t *testing.T) {
+	require.Equal(t, "🧠 Diffusing 37% (37/100) · ~1m 20s left",
+		RenderProgress("` 37%|███▋      | 37/100 [00:12<01:20,  3.01it/s]`"))
+	require.Equal(t, "🧠 Diffusing 0% (0/100) · 0s in",
+		RenderProgress("`  0%|          | 0/100 [00:00<?, ?it/s]`"))
+	require.Equal(t, "⬇️ Downloading input…", RenderProgress("phase: downloading"))
+	require.Equal(t, "🎛️ Post-processing · watermark", RenderProgress("phase: post-processing watermark"))
+	require.Equal(t, "loading model...", RenderProgress("loading model..."))
+
+	// a writer that appends is read by its latest line
+	require.Equal(t, "⬆️ Uploading…", RenderProgress("50/100 [00:01<00:01, 1it/s]\nphase: uploading\n"))
+}
+
+func TestHurryProgress(t *testing.T) {
+	early := "` 37%|███▋      | 37/100 [00:42<01:20,  1.01it/s]`"
+	later := "` 38%|███▊      | 38/100 [00:43<01:19,  1.01it/s]`"
+	last := "` 95%|█████████▌| 95/100 [02:00<00:05,  1.01it/s]`"
+
+	// counting steps can wait
+	require.False(t, HurryProgress(early, later))
+	require.False(t, HurryProgress(later, later))
+
+	// but a new phase or a nearly done job can't
+	require.True(t, HurryProgress("", early))
+	require.True(t, HurryProgress(later, last))
+	require.True(t, HurryProgress(last, "phase: uploading"))
+	require.True(t, HurryProgress("loading model...", "model loaded"))
+	require.False(t, HurryProgress("phase: uploading", "phase: uploading"))
+}
+
+func TestWritePhase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "progress")
+	require.NoError(t, WritePhase(path, PhaseUploading))
+	text, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, PhaseUploading, ParseProgress(string(text)).Phase)
+}
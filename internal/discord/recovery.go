@@ -0,0 +1,113 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"slugbot/internal/io/slog"
+	"slugbot/internal/store"
+)
+
+// progressBucket remembers the progress messages of running jobs, keyed by
+// message ID, so the ones a restart leaves behind can be found again.
+const progressBucket = "progress_messages"
+
+// The notices that replace the progress message of a job a restart
+// interrupted: RequeuedNotice if the job was queued again, and
+// InterruptedNotice if it was dropped.
+const (
+	RequeuedNotice    = "This job was interrupted when the bot restarted, and has been queued again; there's no need to send it again."
+	InterruptedNotice = "This job was interrupted when the bot restarted; please send it again."
+)
+
+// progressStore keeps track of progress messages when set.
+var progressStore *store.Store
+
+// LeftoverProgress is a progress message that was still showing when it was last saved.
+type LeftoverProgress struct {
+	ChannelID string    `json:"channel_id"`
+	MessageID string    `json:"message_id"`
+	TriggerID string    `json:"trigger_id,omitempty"` // the message that started its job; "" for older entries
+	Started   time.Time `json:"started"`
+}
+
+// TrackProgressMessages remembers every started FilePollMessage in s until
+// it's stopped, or with nil, stops remembering them.
+func TrackProgressMessages(s *store.Store) {
+	progressStore = s
+}
+
+func trackProgress(message *Message) {
+	if progressStore == nil || message.MessageID == "" {
+		return
+	}
+	entry := LeftoverProgress{ChannelID: message.ChannelID, MessageID: message.MessageID, TriggerID: message.RepliedToMessageID, Started: time.Now()}
+	if err := progressStore.Put(progressBucket, message.MessageID, entry); err != nil {
+		slog.Warn("couldn't remember progress message ", message.MessageID, ": ", err)
+	}
+}
+
+func untrackProgress(messageID string) {
+	if progressStore == nil || messageID == "" {
+		return
+	}
+	if err := progressStore.Delete(progressBucket, messageID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.Warn("couldn't forget progress message ", messageID, ": ", err)
+	}
+}
+
+// LeftoverProgressMessages returns the progress messages an earlier run left
+// behind. It has to be read before any job of this run can start, since their
+// progress messages are remembered alongside.
+func LeftoverProgressMessages() ([]LeftoverProgress, error) {
+	if progressStore == nil {
+		return nil, nil
+	}
+	keys, err := progressStore.Keys(progressBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	var left []LeftoverProgress
+	for _, key := range keys {
+		var entry LeftoverProgress
+		if err := progressStore.Get(progressBucket, key, &entry); err != nil {
+			errs = append(errs, fmt.Errorf("couldn't load progress message %s: %w", key, err))
+			continue
+		}
+		left = append(left, entry)
+	}
+	return left, errors.Join(errs...)
+}
+
+// RecoverProgressMessages edits the progress messages an earlier run left
+// behind to say what became of their jobs, RequeuedNotice if requeued reports
+// the job's trigger message was queued again and InterruptedNotice if not,
+// and forgets them. Messages that were deleted in the meantime are just
+// forgotten. It returns how many messages it edited.
+func RecoverProgressMessages(api SessionAPI, left []LeftoverProgress, requeued func(triggerID string) bool) (int, error) {
+	var errs []error
+	edited := 0
+	for _, entry := range left {
+		if _, err := api.ChannelMessage(entry.ChannelID, entry.MessageID); errors.Is(err, ErrUnknownMessage) {
+			untrackProgress(entry.MessageID)
+			continue
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("couldn't find progress message %s: %w", entry.MessageID, err))
+			continue
+		}
+		notice := InterruptedNotice
+		if entry.TriggerID != "" && requeued(entry.TriggerID) {
+			notice = RequeuedNotice
+		}
+		if err := api.ChannelMessageEdit(entry.ChannelID, entry.MessageID, notice); err != nil {
+			errs = append(errs, fmt.Errorf("couldn't edit progress message %s: %w", entry.MessageID, err))
+			continue
+		}
+		untrackProgress(entry.MessageID)
+		edited++
+	}
+	return edited, errors.Join(errs...)
+}
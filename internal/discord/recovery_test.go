@@ -0,0 +1,65 @@
+package discord
+
+import (
+	"testing"
+	"time"
+
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverProgressMessages_EditsLeftoversOnce(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	TrackProgressMessages(s)
+	defer TrackProgressMessages(nil)
+
+	// a stopped message is forgotten; a running one is left behind by the "restart"
+	api := &mockSessionAPI{CreatedMessageID: "stopped"}
+	stopped, _ := NewFilePollMessage(api, "c1", "trigger", time.Millisecond)
+	require.NoError(t, stopped.Start("generating..."))
+	require.NoError(t, stopped.Stop())
+
+	api.CreatedMessageID = "running"
+	running, _ := NewFilePollMessage(api, "c1", "trigger", time.Millisecond)
+	require.NoError(t, running.Start("generating..."))
+	defer running.Stop()
+	trackProgress(&Message{ChannelID: "c1", MessageID: "deleted"})
+
+	left, err := LeftoverProgressMessages()
+	require.NoError(t, err)
+	require.Len(t, left, 2)
+	requeuedNone := func(string) bool { return false }
+
+	restarted := &mockSessionAPI{UnknownMessageIDs: []string{"deleted"}}
+	edited, err := RecoverProgressMessages(restarted, left, requeuedNone)
+	require.NoError(t, err)
+	require.Equal(t, 1, edited)
+	require.Equal(t, [][]string{{"ChannelMessageEdit", "c1", "running", InterruptedNotice}}, restarted.data.calls)
+
+	left, err = LeftoverProgressMessages()
+	require.NoError(t, err)
+	require.Empty(t, left)
+}
+
+func TestRecoverProgressMessages_TellsRequeuedJobsApart(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	TrackProgressMessages(s)
+	defer TrackProgressMessages(nil)
+
+	trackProgress(&Message{ChannelID: "c1", MessageID: "resumed", RepliedToMessageID: "t1"})
+	trackProgress(&Message{ChannelID: "c1", MessageID: "dropped", RepliedToMessageID: "t2"})
+	left, err := LeftoverProgressMessages()
+	require.NoError(t, err)
+
+	restarted := &mockSessionAPI{}
+	edited, err := RecoverProgressMessages(restarted, left, func(triggerID string) bool { return triggerID == "t1" })
+	require.NoError(t, err)
+	require.Equal(t, 2, edited)
+	require.ElementsMatch(t, [][]string{
+		{"ChannelMessageEdit", "c1", "resumed", RequeuedNotice},
+		{"ChannelMessageEdit", "c1", "dropped", InterruptedNotice},
+	}, restarted.data.calls)
+}
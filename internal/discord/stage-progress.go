@@ -0,0 +1,226 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"slugbot/internal/format"
+)
+
+// maxMessageLength is the most characters Discord accepts in one message.
+const maxMessageLength = 2000
+
+// stageStatus is where one stage of a StageProgress is.
+type stageStatus int
+
+const (
+	stagePending stageStatus = iota
+	stageActive
+	stageDone
+	stageFailed
+)
+
+var stageMarks = map[stageStatus]string{
+	stagePending: "⬜",
+	stageActive:  "▶️",
+	stageDone:    "✅",
+	stageFailed:  "❌",
+}
+
+// StageProgress is one progress message for every stage of a multi-stage job,
+// e.g. a workflow of generate → sfx → spectrogram. It lists the stages with a
+// mark for each, shows the running stage's latest progress, rendered with
+// RenderTQDM, and an overall estimate of what's left. The message is sent when
+// the first stage begins, and edited as the stages go.
+type StageProgress struct {
+	Message   *Message
+	Title     string          // first line of the message
+	Footer    string          // appended to every version of the message, e.g. a trace ID
+	Estimates []time.Duration // optional; how long each stage usually takes, where 0 is quick
+	Quiet     bool            // keep track of the stages, but never send the message
+
+	mutex   sync.Mutex
+	stages  []string
+	status  []stageStatus
+	active  int       // the running stage, or -1
+	detail  string    // the running stage's latest progress
+	started time.Time // when the running stage began
+	begun   time.Time // when the first stage began
+	closed  bool
+}
+
+// NewStageProgress prepares a progress message listing stages, replying to replyToMessageID.
+func NewStageProgress(api SessionAPI, channelID string, replyToMessageID string, title string, stages []string) (*StageProgress, error) {
+	msg, err := NewReplyMessage(api, channelID, replyToMessageID)
+	if err != nil {
+		return nil, err
+	}
+	return &StageProgress{
+		Message: msg,
+		Title:   title,
+		stages:  stages,
+		status:  make([]stageStatus, len(stages)),
+		active:  -1,
+	}, nil
+}
+
+// Begin marks a stage as running and the ones before it as done, sending the
+// message if this is the first stage to begin.
+func (sp *StageProgress) Begin(stage int) error {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+	if sp.closed || stage < 0 || stage >= len(sp.stages) {
+		return nil
+	}
+	for i := range stage {
+		sp.status[i] = stageDone
+	}
+	sp.status[stage] = stageActive
+	sp.active, sp.detail, sp.started = stage, "", time.Now()
+
+	if sp.begun.IsZero() {
+		sp.begun = sp.started
+	}
+	if sp.Quiet {
+		return nil
+	}
+	if sp.Message.MessageID == "" {
+		if err := sp.Message.Create(sp.renderLocked()); err != nil {
+			return err
+		}
+		trackProgress(sp.Message)
+		return nil
+	}
+	return sp.Message.Update(sp.renderLocked())
+}
+
+// Update shows text, e.g. a tqdm line, as the progress of a running stage.
+// Updates for any other stage are ignored.
+func (sp *StageProgress) Update(stage int, text string) error {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+	if sp.closed || stage != sp.active || sp.Message.MessageID == "" {
+		return nil
+	}
+	sp.detail = text
+	return sp.Message.Update(sp.renderLocked())
+}
+
+// Elapsed returns how long it's been since the first stage began.
+func (sp *StageProgress) Elapsed() time.Duration {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+	if sp.begun.IsZero() {
+		return 0
+	}
+	return time.Since(sp.begun)
+}
+
+// Fail marks a stage as failed and leaves the message in place, showing where
+// the job stopped. Nothing changes the message afterwards.
+func (sp *StageProgress) Fail(stage int) error {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+	if sp.closed || stage < 0 || stage >= len(sp.stages) {
+		return nil
+	}
+	sp.status[stage] = stageFailed
+	sp.active, sp.detail = -1, ""
+	return sp.closeLocked(func() error { return sp.Message.Update(sp.renderLocked()) })
+}
+
+// Finish marks every stage as done and leaves the message in place with summary
+// underneath.
+func (sp *StageProgress) Finish(summary string) error {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+	if sp.closed {
+		return nil
+	}
+	for i := range sp.status {
+		sp.status[i] = stageDone
+	}
+	sp.active, sp.detail = -1, summary
+	return sp.closeLocked(func() error { return sp.Message.Update(sp.renderLocked()) })
+}
+
+// Stop deletes the message. Like Fail and Finish, calling any of them
+// afterwards does nothing.
+func (sp *StageProgress) Stop() error {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+	if sp.closed {
+		return nil
+	}
+	return sp.closeLocked(sp.Message.Delete)
+}
+
+// closeLocked runs last on the message, if it was sent, and stops it from
+// changing again. The caller must hold the mutex.
+func (sp *StageProgress) closeLocked(last func() error) error {
+	sp.closed = true
+	if sp.Message.MessageID == "" {
+		return nil
+	}
+	defer untrackProgress(sp.Message.MessageID)
+	return last()
+}
+
+// renderLocked draws the message, e.g.
+//
+//	Workflow: `rainy jazz`
+//	✅ 1. generate
+//	▶️ 2. sfx reverb · 37.0% (37/100 steps)
+//	⬜ 3. spectrogram
+//	Overall: ~20s left
+//
+// The caller must hold the mutex.
+func (sp *StageProgress) renderLocked() string {
+	lines := []string{sp.Title}
+	for i, name := range sp.stages {
+		line := fmt.Sprintf("%s %d. %s", stageMarks[sp.status[i]], i+1, name)
+		if i == sp.active && sp.detail != "" {
+			line += " · " + strings.Join(strings.Fields(RenderTQDM(sp.detail)), " ")
+		}
+		lines = append(lines, line)
+	}
+	if sp.active < 0 && sp.detail != "" {
+		lines = append(lines, sp.detail)
+	}
+	if left, ok := sp.remainingLocked(); ok {
+		lines = append(lines, "Overall: ~"+format.Duration(left)+" left")
+	}
+
+	text := []rune(strings.Join(lines, "\n"))
+	if limit := maxMessageLength - len([]rune(sp.Footer)); len(text) > limit {
+		text = append(text[:max(limit-1, 0)], '…')
+	}
+	return string(text) + sp.Footer
+}
+
+// remainingLocked estimates how long the running stage and the ones after it
+// will take: what the running stage's progress says is left, or else its usual
+// time minus how long it's been going, plus the usual time of each later stage.
+// The caller must hold the mutex.
+func (sp *StageProgress) remainingLocked() (time.Duration, bool) {
+	if sp.active < 0 {
+		return 0, false
+	}
+	left, ok := tqdmRemaining(sp.detail)
+	if !ok {
+		left = max(sp.estimate(sp.active)-time.Since(sp.started), 0)
+	}
+	for i := sp.active + 1; i < len(sp.stages); i++ {
+		left += sp.estimate(i)
+	}
+	return left, left > 0
+}
+
+func (sp *StageProgress) estimate(stage int) time.Duration {
+	if stage < len(sp.Estimates) {
+		return sp.Estimates[stage]
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+package discord
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStageProgress(t *testing.T, api *mockSessionAPI) *StageProgress {
+	sp, err := NewStageProgress(api, "channel", "trigger", "Workflow: `rain`", []string{"generate", "sfx reverb", "spectrogram"})
+	require.NoError(t, err)
+	return sp
+}
+
+func TestStageProgress_ListsStagesWithTheActiveOnesProgress(t *testing.T) {
+	api := &mockSessionAPI{CreatedMessageID: "progress"}
+	sp := newTestStageProgress(t, api)
+
+	require.NoError(t, sp.Begin(0))
+	require.Equal(t, []string{"ChannelMessageSendReply", "channel", "Workflow: `rain`\n▶️ 1. generate\n⬜ 2. sfx reverb\n⬜ 3. spectrogram", "trigger"}, api.data.calls[0])
+
+	require.NoError(t, sp.Update(0, " 37%|███▋      | 37/100 [00:12<00:20,  3.01it/s]"))
+	require.Equal(t, "Workflow: `rain`\n▶️ 1. generate · 37.0% (37/100 steps) · 12s elapsed · ~20s left\n⬜ 2. sfx reverb\n⬜ 3. spectrogram\nOverall: ~20s left", api.data.calls[1][3])
+
+	require.NoError(t, sp.Begin(1))
+	require.Equal(t, "Workflow: `rain`\n✅ 1. generate\n▶️ 2. sfx reverb\n⬜ 3. spectrogram", api.data.calls[2][3])
+
+	// a stage that isn't running anymore can't change the message
+	require.NoError(t, sp.Update(0, "100/100 [00:40<00:00]"))
+	require.Len(t, api.data.calls, 3)
+}
+
+func TestStageProgress_OverallEstimateAddsTheLaterStages(t *testing.T) {
+	api := &mockSessionAPI{CreatedMessageID: "progress"}
+	sp := newTestStageProgress(t, api)
+	sp.Estimates = []time.Duration{time.Minute, 0, 30 * time.Second}
+
+	require.NoError(t, sp.Update(0, "ignored before the message is sent"))
+	require.Empty(t, api.data.calls)
+
+	require.NoError(t, sp.Begin(0))
+	require.Contains(t, api.data.calls[0][2], "Overall: ~1m 30s left")
+
+	require.NoError(t, sp.Update(0, "50/100 [00:30<00:10, 1.6it/s]"))
+	require.Contains(t, api.data.calls[1][3], "Overall: ~40s left")
+}
+
+func TestStageProgress_FailLeavesTheMessage(t *testing.T) {
+	api := &mockSessionAPI{CreatedMessageID: "progress"}
+	sp := newTestStageProgress(t, api)
+
+	require.NoError(t, sp.Begin(1))
+	require.NoError(t, sp.Fail(1))
+	require.Equal(t, "Workflow: `rain`\n✅ 1. generate\n❌ 2. sfx reverb\n⬜ 3. spectrogram", api.data.calls[1][3])
+
+	// once it's failed, nothing changes it, not even the later stages being cancelled
+	require.NoError(t, sp.Stop())
+	require.NoError(t, sp.Begin(2))
+	require.Len(t, api.data.calls, 2)
+}
+
+func TestStageProgress_FinishAndStop(t *testing.T) {
+	api := &mockSessionAPI{CreatedMessageID: "progress"}
+	sp := newTestStageProgress(t, api)
+	require.NoError(t, sp.Begin(2))
+	require.NoError(t, sp.Finish("generated in 1m 2s, seed 7"))
+	require.Equal(t, "Workflow: `rain`\n✅ 1. generate\n✅ 2. sfx reverb\n✅ 3. spectrogram\ngenerated in 1m 2s, seed 7", api.data.calls[1][3])
+
+	api = &mockSessionAPI{CreatedMessageID: "progress"}
+	sp = newTestStageProgress(t, api)
+	require.NoError(t, sp.Stop())
+	require.Empty(t, api.data.calls, "a message that was never sent has nothing to delete")
+
+	sp = newTestStageProgress(t, api)
+	require.NoError(t, sp.Begin(0))
+	require.NoError(t, sp.Stop())
+	require.Equal(t, []string{"ChannelMessageDelete", "channel", "progress"}, api.data.calls[1])
+}
+
+func TestStageProgress_StaysUnderDiscordsLimit(t *testing.T) {
+	api := &mockSessionAPI{CreatedMessageID: "progress"}
+	sp := newTestStageProgress(t, api)
+	sp.Footer = "\n-# trace: `abc`"
+	require.NoError(t, sp.Begin(0))
+	require.NoError(t, sp.Update(0, strings.Repeat("é", 3000)))
+
+	text := api.data.calls[1][3]
+	require.Len(t, []rune(text), maxMessageLength)
+	require.True(t, strings.HasSuffix(text, "…"+sp.Footer))
+}
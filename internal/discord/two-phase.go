@@ -0,0 +1,81 @@
+package discord
+
+import (
+	"fmt"
+
+	"slugbot/internal/io/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ComplexEditor edits messages, attachments and all.
+type ComplexEditor interface {
+	ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+}
+
+// TwoPhaseSender sends messages with files, then edits them.
+type TwoPhaseSender interface {
+	ComplexSender
+	ComplexEditor
+}
+
+// ReplaceFiles replaces a sent message's attachments with files, and its
+// content with content, retrying transient failures with UploadBackoff like
+// SendFiles. The files' readers must be io.Seekers for it to retry.
+func ReplaceFiles(api ComplexEditor, msg *discordgo.Message, content string, files []*discordgo.File) (*discordgo.Message, error) {
+	// if the bot dies mid-upload, the next run posts the files as a reply instead
+	defer untrackUpload(trackUpload(msg.ChannelID, &discordgo.MessageSend{Content: content, Files: files, Reference: msg.Reference()}))
+
+	edit := discordgo.NewMessageEdit(msg.ChannelID, msg.ID)
+	edit.Content = &content
+	edit.Files = files
+	// no attachments are kept, so the files take the place of the old ones
+	edit.Attachments = &[]*discordgo.MessageAttachment{}
+
+	var edited *discordgo.Message
+	err := retryUpload(files, func() error {
+		var err error
+		edited, err = api.ChannelMessageEditComplex(edit)
+		return err
+	})
+	return edited, err
+}
+
+// SendInTwoPhases sends a message whose files are slow to upload, such as a
+// long render's full-quality audio, in two steps: first with quick stand-ins
+// for them, like a low-bitrate preview, and pending after its content, then,
+// once the real files have uploaded, edited to have them and the content
+// alone. Users can listen to the stand-ins in the meantime.
+//
+// If the first message can't be sent, the whole one is sent with SendFiles
+// instead. If it can't be edited, the files are sent with SendFiles as a
+// reply to it. It returns the message the files ended up on.
+func SendInTwoPhases(api TwoPhaseSender, channelID string, send *discordgo.MessageSend, quick []*discordgo.File, pending string) (*discordgo.Message, error) {
+	first := *send
+	first.Files = quick
+	first.Content = send.Content + "\n" + pending
+	if send.Content == "" {
+		first.Content = pending
+	}
+	msg, err := SendFiles(api, channelID, &first)
+	if err != nil {
+		slog.Warn("couldn't send the quick version of a result; sending it whole: ", err)
+		return SendFiles(api, channelID, send)
+	}
+
+	edited, err := ReplaceFiles(api, msg, send.Content, send.Files)
+	if err == nil {
+		return edited, nil
+	}
+	slog.Warn("couldn't put the full result on ", msg.ID, "; replying with it instead: ", err)
+	reply, err := SendFiles(api, msg.ChannelID, &discordgo.MessageSend{
+		Content:         "Here's the full-quality version.",
+		Files:           send.Files,
+		Reference:       msg.Reference(),
+		AllowedMentions: send.AllowedMentions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't send the full result after its quick version: %w", err)
+	}
+	return reply, nil
+}
@@ -0,0 +1,65 @@
+package discord
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+// editingSender sends like flakySender, and records edits, failing them with editErr.
+type editingSender struct {
+	flakySender
+	editErr error
+	edits   []*discordgo.MessageEdit
+	read    []string
+}
+
+func (e *editingSender) ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	e.edits = append(e.edits, m)
+	for _, file := range m.Files {
+		read, _ := io.ReadAll(file.Reader)
+		e.read = append(e.read, string(read))
+	}
+	if e.editErr != nil {
+		return nil, e.editErr
+	}
+	return &discordgo.Message{ID: m.ID, ChannelID: m.Channel}, nil
+}
+
+func TestSendInTwoPhases_ReplacesTheQuickFiles(t *testing.T) {
+	noSleep(t)
+	api := &editingSender{}
+	send := &discordgo.MessageSend{
+		Content: "rainy jazz",
+		Files:   []*discordgo.File{{Name: "out.wav", Reader: bytes.NewReader([]byte("full"))}},
+	}
+	quick := []*discordgo.File{{Name: "out-quick.mp3", Reader: bytes.NewReader([]byte("quick"))}}
+
+	msg, err := SendInTwoPhases(api, "c1", send, quick, "on its way")
+	require.NoError(t, err)
+	require.Equal(t, "sent", msg.ID)
+	require.Equal(t, []string{"quick"}, api.reads)
+	require.Equal(t, "rainy jazz\non its way", api.sent[0].Content)
+
+	require.Len(t, api.edits, 1)
+	require.Equal(t, "rainy jazz", *api.edits[0].Content)
+	require.Empty(t, *api.edits[0].Attachments)
+	require.Equal(t, []string{"full"}, api.read)
+}
+
+func TestSendInTwoPhases_RepliesIfTheEditFails(t *testing.T) {
+	noSleep(t)
+	api := &editingSender{editErr: &discordgo.RESTError{Response: nil, Message: &discordgo.APIErrorMessage{Code: 50035}}}
+	send := &discordgo.MessageSend{Files: []*discordgo.File{{Name: "out.wav", Reader: bytes.NewReader([]byte("full"))}}}
+	quick := []*discordgo.File{{Name: "out-quick.mp3", Reader: bytes.NewReader([]byte("quick"))}}
+
+	_, err := SendInTwoPhases(api, "c1", send, quick, "on its way")
+	require.NoError(t, err)
+	require.Equal(t, "on its way", api.sent[0].Content)
+	require.Len(t, api.sent, 2)
+	require.Equal(t, "sent", api.sent[1].Reference.MessageID)
+	require.Equal(t, []string{"quick", "full"}, api.reads)
+}
@@ -0,0 +1,60 @@
+package discord
+
+import (
+	"sync"
+	"time"
+
+	"slugbot/internal/io/slog"
+)
+
+// TypingInterval is how often Typing renews the indicator; Discord shows it
+// for about ten seconds after each request.
+var TypingInterval = 8 * time.Second
+
+// TypingBackoff is how Typing renews the indicator after transient failures,
+// e.g. when Discord rate limits the channel: it waits longer after each one
+// in a row, and gives up after Attempts of them.
+var TypingBackoff = Backoff{Attempts: 4, Initial: 15 * time.Second, Max: time.Minute}
+
+// Typing shows the typing indicator in a channel until the returned function
+// is called, so users see the bot working before it has anything to post,
+// e.g. during an image command or a job's setup. The indicator is only a
+// courtesy, so failures are logged rather than returned; one that isn't
+// transient, such as missing permissions, stops it for good.
+func Typing(api SessionAPI, channelID string) (stop func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	stop = func() { once.Do(func() { close(done) }) }
+	if api == nil || api.Check() != nil || channelID == "" {
+		return stop
+	}
+
+	interval, backoff := TypingInterval, TypingBackoff
+	go func() {
+		failures := 0
+		wait := backoff.Initial
+		for {
+			next := interval
+			if err := api.ChannelTyping(channelID); err == nil {
+				failures, wait = 0, backoff.Initial
+			} else if !IsTransient(err) {
+				slog.Debug("stopped typing in ", channelID, ": ", err)
+				return
+			} else if failures++; failures >= backoff.Attempts {
+				slog.Debug("gave up typing in ", channelID, " after ", failures, " failures: ", err)
+				return
+			} else {
+				next, wait = wait, min(wait*2, backoff.Max)
+			}
+
+			timer := time.NewTimer(next)
+			select {
+			case <-done:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+	return stop
+}
@@ -0,0 +1,51 @@
+package discord
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func fastTyping(t *testing.T) {
+	interval, backoff := TypingInterval, TypingBackoff
+	TypingInterval = time.Millisecond
+	TypingBackoff = Backoff{Attempts: 3, Initial: time.Millisecond, Max: 2 * time.Millisecond}
+	t.Cleanup(func() { TypingInterval, TypingBackoff = interval, backoff })
+}
+
+func TestTyping_RenewsUntilStopped(t *testing.T) {
+	fastTyping(t)
+	api := &mockSessionAPI{}
+
+	stop := Typing(api, "c1")
+	require.Eventually(t, func() bool { return api.typedCount() >= 3 }, time.Second, time.Millisecond)
+	stop()
+	stop()
+
+	typed := api.typedCount()
+	time.Sleep(10 * time.Millisecond)
+	require.LessOrEqual(t, api.typedCount(), typed+1)
+}
+
+func TestTyping_BacksOffTransientFailuresThenGivesUp(t *testing.T) {
+	fastTyping(t)
+	api := &mockSessionAPI{TypingErrors: []error{serverError(), serverError(), serverError(), serverError()}}
+
+	stop := Typing(api, "c1")
+	defer stop()
+	require.Eventually(t, func() bool { return api.typedCount() == 3 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, 3, api.typedCount())
+}
+
+func TestTyping_StopsOnPermanentFailures(t *testing.T) {
+	fastTyping(t)
+	api := &mockSessionAPI{TypingErrors: []error{errors.New("missing permissions")}}
+
+	stop := Typing(api, "c1")
+	defer stop()
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, 1, api.typedCount())
+}
@@ -0,0 +1,284 @@
+package discord
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"slugbot/internal/io/slog"
+	"slugbot/internal/store"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ErrUndelivered is wrapped by SendFiles' error when the upload failed but the
+// files were saved so the delivery can be retried.
+var ErrUndelivered = errors.New("the upload failed, so it was saved to retry later")
+
+// undeliveredBucket holds the results that couldn't be uploaded, keyed by ID.
+const undeliveredBucket = "undelivered"
+
+// RedeliverPrefix is the component prefix of the button that retries a delivery.
+const RedeliverPrefix = "redeliver"
+
+// undelivered keeps results that couldn't be uploaded when set.
+var undelivered struct {
+	store *store.Store
+	dir   string
+}
+
+// UndeliveredFile is one saved file of an undelivered result.
+type UndeliveredFile struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type,omitempty"`
+	Path        string `json:"path"`
+}
+
+// Undelivered is a result message whose upload failed, saved so that it can be
+// sent again with Redeliver.
+type Undelivered struct {
+	ID        string            `json:"id"`
+	ChannelID string            `json:"channel_id"`
+	ReplyToID string            `json:"reply_to_id,omitempty"` // the message the result answers, e.g. the job's trigger
+	Content   string            `json:"content,omitempty"`
+	Files     []UndeliveredFile `json:"files"`
+	NoticeID  string            `json:"notice_id,omitempty"` // the message with the retry button
+	Saved     time.Time         `json:"saved"`
+
+	// SubmitterID is the user who asked for the result, if it's known, so
+	// they can have it deleted.
+	SubmitterID string `json:"submitter_id,omitempty"`
+}
+
+// resultOwner returns who asked for a result replying to a message, or "".
+var resultOwner func(channelID string, replyToID string) string
+
+// AttributeResults has saved results record who asked for them, so that
+// ForgetSubmitter can find them. owner returns the user who sent the message
+// a result replies to, or "" if it isn't known.
+func AttributeResults(owner func(channelID string, replyToID string) string) {
+	resultOwner = owner
+}
+
+// submitterOf returns who asked for a result, if it replies to a message
+// whose sender is known.
+func submitterOf(channelID string, replyToID string) string {
+	if resultOwner == nil || replyToID == "" {
+		return ""
+	}
+	return resultOwner(channelID, replyToID)
+}
+
+// KeepUndelivered saves the files of results SendFiles couldn't upload into
+// dir and remembers them in s, or with a nil s, stops keeping them.
+func KeepUndelivered(s *store.Store, dir string) {
+	undelivered.store, undelivered.dir = s, dir
+}
+
+// saveUndelivered saves the files of a message that couldn't be sent and posts
+// a notice with a button to retry it.
+func saveUndelivered(api ComplexSender, channelID string, send *discordgo.MessageSend) error {
+	entry, err := saveMessage(channelID, send)
+	if err != nil {
+		return err
+	}
+
+	notice := &discordgo.MessageSend{
+		Content: "I couldn't upload this result, even after retrying. It's been saved; press the button to try again.",
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "Retry delivery", Style: discordgo.PrimaryButton, CustomID: ComponentID(RedeliverPrefix, entry.ID)},
+			}},
+		},
+	}
+	if entry.ReplyToID != "" {
+		notice.Reference = &discordgo.MessageReference{MessageID: entry.ReplyToID, ChannelID: channelID, FailIfNotExists: new(bool)}
+	}
+	// the notice is small, so it has a better chance than the upload did
+	if msg, err := api.ChannelMessageSendComplex(channelID, notice); err != nil {
+		slog.Warn("couldn't post the retry notice for undelivered result ", entry.ID, ": ", err)
+	} else {
+		entry.NoticeID = msg.ID
+	}
+
+	if err := undelivered.store.Put(undeliveredBucket, entry.ID, entry); err != nil {
+		os.RemoveAll(filepath.Join(undelivered.dir, entry.ID))
+		return err
+	}
+	slog.Warn("saved undelivered result ", entry.ID, " for channel ", channelID)
+	return nil
+}
+
+// saveMessage saves the files of a message meant for a channel under a new
+// ID, so it can be sent later. The caller stores the returned entry.
+func saveMessage(channelID string, send *discordgo.MessageSend) (Undelivered, error) {
+	if undelivered.store == nil {
+		return Undelivered{}, errors.New("undelivered results aren't being kept")
+	}
+
+	idBytes := make([]byte, 6)
+	if _, err := rand.Read(idBytes); err != nil {
+		return Undelivered{}, err
+	}
+	entry := Undelivered{ID: hex.EncodeToString(idBytes), ChannelID: channelID, Content: send.Content, Saved: time.Now()}
+	if send.Reference != nil {
+		entry.ReplyToID = send.Reference.MessageID
+	}
+	entry.SubmitterID = submitterOf(channelID, entry.ReplyToID)
+
+	dir := filepath.Join(undelivered.dir, entry.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Undelivered{}, fmt.Errorf("couldn't create %s: %w", dir, err)
+	}
+	if err := rewind(send.Files); err != nil {
+		os.RemoveAll(dir)
+		return Undelivered{}, err
+	}
+	for i, file := range send.Files {
+		path := filepath.Join(dir, fmt.Sprintf("%d-%s", i, filepath.Base(file.Name)))
+		if err := saveFile(path, file.Reader); err != nil {
+			os.RemoveAll(dir)
+			return Undelivered{}, fmt.Errorf("couldn't save %s: %w", file.Name, err)
+		}
+		entry.Files = append(entry.Files, UndeliveredFile{Name: file.Name, ContentType: file.ContentType, Path: path})
+	}
+	return entry, nil
+}
+
+func saveFile(path string, r io.Reader) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// UndeliveredResults lists the saved results that haven't been delivered yet, oldest first.
+func UndeliveredResults() ([]Undelivered, error) {
+	if undelivered.store == nil {
+		return nil, nil
+	}
+	keys, err := undelivered.store.Keys(undeliveredBucket)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Undelivered, 0, len(keys))
+	for _, key := range keys {
+		var entry Undelivered
+		if err := undelivered.store.Get(undeliveredBucket, key, &entry); err != nil {
+			return nil, fmt.Errorf("couldn't load undelivered result %s: %w", key, err)
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Saved.Before(entries[j].Saved) })
+	return entries, nil
+}
+
+// LookupUndelivered returns a saved result by ID, or store.ErrNotFound.
+func LookupUndelivered(id string) (Undelivered, error) {
+	return lookupSaved(undeliveredBucket, id)
+}
+
+// Redeliver sends a saved result again, retrying like SendFiles. Once it's
+// delivered, its files are removed and it's forgotten; if it fails again, it
+// stays saved.
+func Redeliver(api ComplexSender, id string) (*discordgo.Message, Undelivered, error) {
+	return resend(api, undeliveredBucket, id)
+}
+
+// ForgetUndelivered removes a saved result and its files without sending it,
+// or returns store.ErrNotFound if there's no such result.
+func ForgetUndelivered(id string) error {
+	return forgetSaved(undeliveredBucket, id)
+}
+
+// ForgetSubmitter removes the undelivered results and the previews waiting
+// for approval that a user asked for, with their files, and returns how many
+// it removed.
+func ForgetSubmitter(userID string) (int, error) {
+	if undelivered.store == nil || userID == "" {
+		return 0, nil
+	}
+	removed := 0
+	for _, bucket := range []string{undeliveredBucket, previewBucket} {
+		keys, err := undelivered.store.Keys(bucket)
+		if err != nil {
+			return removed, err
+		}
+		for _, key := range keys {
+			var entry Undelivered
+			if err := undelivered.store.Get(bucket, key, &entry); err != nil {
+				return removed, fmt.Errorf("couldn't load saved result %s: %w", key, err)
+			}
+			if entry.SubmitterID != userID {
+				continue
+			}
+			if err := forgetSaved(bucket, key); err != nil {
+				return removed, fmt.Errorf("couldn't remove saved result %s: %w", key, err)
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func lookupSaved(bucket string, id string) (Undelivered, error) {
+	var entry Undelivered
+	if undelivered.store == nil {
+		return entry, store.ErrNotFound
+	}
+	err := undelivered.store.Get(bucket, id, &entry)
+	return entry, err
+}
+
+// resend sends a message saved in bucket to its channel, then forgets it.
+func resend(api ComplexSender, bucket string, id string) (*discordgo.Message, Undelivered, error) {
+	entry, err := lookupSaved(bucket, id)
+	if err != nil {
+		return nil, entry, err
+	}
+
+	// the content can quote a prompt, which shouldn't ping anyone
+	send := &discordgo.MessageSend{Content: entry.Content, AllowedMentions: &discordgo.MessageAllowedMentions{RepliedUser: true}}
+	if entry.ReplyToID != "" {
+		send.Reference = &discordgo.MessageReference{MessageID: entry.ReplyToID, ChannelID: entry.ChannelID, FailIfNotExists: new(bool)}
+	}
+	for _, saved := range entry.Files {
+		file, err := os.Open(saved.Path)
+		if err != nil {
+			return nil, entry, fmt.Errorf("couldn't open saved file %s: %w", saved.Name, err)
+		}
+		defer file.Close()
+		send.Files = append(send.Files, &discordgo.File{Name: saved.Name, ContentType: saved.ContentType, Reader: file})
+	}
+
+	msg, err := sendWithRetry(api, entry.ChannelID, send)
+	if err != nil {
+		return nil, entry, err
+	}
+	if err := forgetSaved(bucket, id); err != nil {
+		slog.Warn("couldn't forget resent result ", id, ": ", err)
+	}
+	return msg, entry, nil
+}
+
+func forgetSaved(bucket string, id string) error {
+	// only IDs that were saved name a directory to remove
+	if _, err := lookupSaved(bucket, id); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(undelivered.dir, id)); err != nil {
+		return err
+	}
+	return undelivered.store.Delete(bucket, id)
+}
@@ -0,0 +1,135 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"slugbot/internal/io/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ComplexSender sends messages with files or components; *discordgo.Session is one.
+type ComplexSender interface {
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+}
+
+// Backoff spaces out the retries of something that failed transiently.
+type Backoff struct {
+	Attempts int           // tries in all, including the first
+	Initial  time.Duration // wait before the second try; it doubles after each one
+	Max      time.Duration // longest wait between tries
+}
+
+// UploadBackoff is how SendFiles retries uploads.
+var UploadBackoff = Backoff{Attempts: 4, Initial: 2 * time.Second, Max: 30 * time.Second}
+
+// sleep waits between retries; tests replace it.
+var sleep = time.Sleep
+
+// Retry calls attempt until it succeeds, fails with an error that isn't
+// transient (see IsTransient), or has been tried Attempts times, and returns
+// its last error.
+func (b Backoff) Retry(attempt func() error) error {
+	wait := b.Initial
+	for try := 1; ; try++ {
+		err := attempt()
+		if err == nil || !IsTransient(err) || try >= b.Attempts {
+			return err
+		}
+		slog.Warn("try ", try, " of ", b.Attempts, " failed, retrying in ", wait, ": ", err)
+		sleep(wait)
+		wait = min(wait*2, b.Max)
+	}
+}
+
+// IsTransient reports whether an error from Discord is worth retrying: a
+// server error, a rate limit discordgo gave up on, or a dropped connection.
+func IsTransient(err error) bool {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) {
+		return restErr.Response != nil &&
+			(restErr.Response.StatusCode >= 500 || restErr.Response.StatusCode == http.StatusTooManyRequests)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// SendFiles sends a message with files, such as a job's results, retrying
+// transient failures with UploadBackoff. Before each retry, the files are
+// rewound, so their readers must be io.Seekers (os.File, bytes.Reader, ...)
+// for it to retry at all. If every try fails and undelivered results are kept
+// (see KeepUndelivered), the files are saved and a notice with a button to
+// retry the delivery is posted in their place; the error then wraps
+// ErrUndelivered. A result that has to be approved first (see UsePreviews) is
+// DMed to its submitter instead, and that DM is returned. Uploads of files
+// on disk are remembered while they run, so RecoverUploads can find the ones
+// a crash interrupted.
+func SendFiles(api ComplexSender, channelID string, send *discordgo.MessageSend) (*discordgo.Message, error) {
+	Brand(channelID, send.Embeds)
+	if dm, userID := previewFor(api, channelID, send); userID != "" {
+		return sendPreview(dm, userID, channelID, send)
+	}
+	// if the bot dies mid-upload, the next run finds the files again
+	defer untrackUpload(trackUpload(channelID, send))
+	msg, err := sendWithRetry(api, channelID, send)
+	if err == nil || !IsTransient(err) {
+		return msg, err
+	}
+	if saveErr := saveUndelivered(api, channelID, send); saveErr != nil {
+		return nil, fmt.Errorf("%w; couldn't save it for later either: %w", err, saveErr)
+	}
+	return nil, fmt.Errorf("%w: %w", ErrUndelivered, err)
+}
+
+func sendWithRetry(api ComplexSender, channelID string, send *discordgo.MessageSend) (*discordgo.Message, error) {
+	var msg *discordgo.Message
+	err := retryUpload(send.Files, func() error {
+		var err error
+		msg, err = api.ChannelMessageSendComplex(channelID, send)
+		return err
+	})
+	return msg, err
+}
+
+// retryUpload runs an upload of files, retrying transient failures with
+// UploadBackoff if every file can be rewound for another try.
+func retryUpload(files []*discordgo.File, upload func() error) error {
+	rewindable := true
+	for _, file := range files {
+		if _, ok := file.Reader.(io.Seeker); !ok {
+			rewindable = false
+		}
+	}
+
+	attempt := func() error {
+		if err := rewind(files); err != nil {
+			return err
+		}
+		return upload()
+	}
+	if !rewindable {
+		return attempt()
+	}
+	return UploadBackoff.Retry(attempt)
+}
+
+// rewind seeks every file's reader back to its start, where it can.
+func rewind(files []*discordgo.File) error {
+	for _, file := range files {
+		if seeker, ok := file.Reader.(io.Seeker); ok {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("couldn't rewind %s: %w", file.Name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package discord
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"slugbot/internal/store"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+// flakySender fails its first uploads with the given errors, then succeeds,
+// recording what each try read from the files.
+type flakySender struct {
+	errs  []error
+	reads []string
+	sent  []*discordgo.MessageSend
+}
+
+func (f *flakySender) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.sent = append(f.sent, data)
+	for _, file := range data.Files {
+		read, _ := io.ReadAll(file.Reader)
+		f.reads = append(f.reads, string(read))
+	}
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	return &discordgo.Message{ID: "sent", ChannelID: channelID}, nil
+}
+
+func serverError() error {
+	return &discordgo.RESTError{Response: &http.Response{StatusCode: http.StatusBadGateway}}
+}
+
+func noSleep(t *testing.T) *[]time.Duration {
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	t.Cleanup(func() { sleep = time.Sleep })
+	return &waits
+}
+
+func TestSendFiles_RetriesTransientFailuresWithBackoff(t *testing.T) {
+	waits := noSleep(t)
+	api := &flakySender{errs: []error{serverError(), io.ErrUnexpectedEOF}}
+
+	msg, err := SendFiles(api, "c1", &discordgo.MessageSend{
+		Files: []*discordgo.File{{Name: "out.wav", Reader: bytes.NewReader([]byte("audio"))}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "sent", msg.ID)
+	require.Equal(t, []string{"audio", "audio", "audio"}, api.reads)
+	require.Equal(t, []time.Duration{UploadBackoff.Initial, 2 * UploadBackoff.Initial}, *waits)
+}
+
+func TestSendFiles_DoesNotRetryClientErrors(t *testing.T) {
+	noSleep(t)
+	api := &flakySender{errs: []error{&discordgo.RESTError{Response: &http.Response{StatusCode: http.StatusRequestEntityTooLarge}}}}
+
+	_, err := SendFiles(api, "c1", &discordgo.MessageSend{
+		Files: []*discordgo.File{{Name: "out.wav", Reader: bytes.NewReader([]byte("audio"))}},
+	})
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrUndelivered)
+	require.Len(t, api.sent, 1)
+}
+
+func TestSendFiles_SavesUndeliveredAndRedelivers(t *testing.T) {
+	noSleep(t)
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	dir := t.TempDir()
+	KeepUndelivered(s, dir)
+	defer KeepUndelivered(nil, "")
+
+	failures := make([]error, UploadBackoff.Attempts)
+	for i := range failures {
+		failures[i] = serverError()
+	}
+	api := &flakySender{errs: failures}
+	_, err = SendFiles(api, "c1", &discordgo.MessageSend{
+		Content:   "here you go",
+		Reference: &discordgo.MessageReference{MessageID: "trigger", ChannelID: "c1"},
+		Files:     []*discordgo.File{{Name: "out.wav", Reader: bytes.NewReader([]byte("audio"))}},
+	})
+	require.ErrorIs(t, err, ErrUndelivered)
+
+	// the last send is the notice with the retry button
+	notice := api.sent[len(api.sent)-1]
+	require.Len(t, notice.Components, 1)
+	entries, err := UndeliveredResults()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	entry := entries[0]
+	require.Equal(t, "trigger", entry.ReplyToID)
+	require.Equal(t, "sent", entry.NoticeID)
+
+	retry := &flakySender{}
+	msg, _, err := Redeliver(retry, entry.ID)
+	require.NoError(t, err)
+	require.Equal(t, "sent", msg.ID)
+	require.Equal(t, []string{"audio"}, retry.reads)
+	require.Equal(t, "here you go", retry.sent[0].Content)
+
+	_, err = LookupUndelivered(entry.ID)
+	require.ErrorIs(t, err, store.ErrNotFound)
+	_, err = os.Stat(filepath.Join(dir, entry.ID))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestForgetSubmitter_RemovesOnlyTheirSavedResults(t *testing.T) {
+	noSleep(t)
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	dir := t.TempDir()
+	KeepUndelivered(s, dir)
+	defer KeepUndelivered(nil, "")
+	AttributeResults(func(channelID string, replyToID string) string {
+		return map[string]string{"mine": "u1", "theirs": "u2"}[replyToID]
+	})
+	defer AttributeResults(nil)
+
+	for _, trigger := range []string{"mine", "theirs"} {
+		failures := make([]error, UploadBackoff.Attempts)
+		for i := range failures {
+			failures[i] = serverError()
+		}
+		_, err = SendFiles(&flakySender{errs: failures}, "c1", &discordgo.MessageSend{
+			Reference: &discordgo.MessageReference{MessageID: trigger, ChannelID: "c1"},
+			Files:     []*discordgo.File{{Name: "out.wav", Reader: bytes.NewReader([]byte("audio"))}},
+		})
+		require.ErrorIs(t, err, ErrUndelivered)
+	}
+	entries, err := UndeliveredResults()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	n, err := ForgetSubmitter("u1")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	left, err := UndeliveredResults()
+	require.NoError(t, err)
+	require.Len(t, left, 1)
+	require.Equal(t, "u2", left[0].SubmitterID)
+	for _, entry := range entries {
+		_, err := os.Stat(filepath.Join(dir, entry.ID))
+		require.Equal(t, entry.SubmitterID == "u2", err == nil)
+	}
+}
@@ -0,0 +1,114 @@
+package eta
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"slugbot/internal/io/slog"
+	"slugbot/internal/store"
+)
+
+const bucket = "runtimes"
+
+// smoothing is the weight given to each new observation, so estimates follow
+// driver/model changes within a handful of jobs.
+const smoothing = 0.25
+
+// Shape describes the properties of a job that determine how long it takes.
+type Shape struct {
+	Model  string  // e.g. "small" or "full"
+	Steps  int64   // diffusion steps
+	Length float64 // seconds of audio
+}
+
+// key groups shapes into buckets: steps by power of two, length by 15 seconds.
+func (s Shape) key() string {
+	stepsBucket := int64(1)
+	for stepsBucket < s.Steps {
+		stepsBucket *= 2
+	}
+	lengthBucket := int(math.Ceil(s.Length/15)) * 15
+	return fmt.Sprintf("%s/%d/%d", s.Model, stepsBucket, lengthBucket)
+}
+
+// work is a rough cost unit used to extrapolate from one bucket to another.
+func (s Shape) work() float64 {
+	return float64(max(s.Steps, 1)) * max(s.Length, 1)
+}
+
+// Sample is a smoothed runtime observation.
+type Sample struct {
+	Count   int     `json:"count"`
+	Seconds float64 `json:"seconds"`
+}
+
+// Estimator predicts job runtimes from completed-job durations.
+type Estimator struct {
+	Store *store.Store
+
+	mutex sync.Mutex
+}
+
+// Record adds a completed job's runtime to its bucket and to the model's per-unit cost.
+func (e *Estimator) Record(shape Shape, took time.Duration) {
+	if e == nil || e.Store == nil || took <= 0 {
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	seconds := took.Seconds()
+	if err := e.update(shape.key(), seconds); err != nil {
+		slog.Warn("couldn't record job runtime: ", err)
+	}
+	if err := e.update(unitKey(shape.Model), seconds/shape.work()); err != nil {
+		slog.Warn("couldn't record job runtime: ", err)
+	}
+}
+
+// Estimate predicts how long a job of the given shape will take. It prefers the
+// shape's own bucket, then extrapolates from the model's average per-unit cost.
+// ok is false when there's no history for the model at all.
+func (e *Estimator) Estimate(shape Shape) (estimate time.Duration, ok bool) {
+	if e == nil || e.Store == nil {
+		return 0, false
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var sample Sample
+	if err := e.Store.Get(bucket, shape.key(), &sample); err == nil && sample.Count > 0 {
+		return seconds(sample.Seconds), true
+	}
+	if err := e.Store.Get(bucket, unitKey(shape.Model), &sample); err == nil && sample.Count > 0 {
+		return seconds(sample.Seconds * shape.work()), true
+	}
+	return 0, false
+}
+
+func (e *Estimator) update(key string, value float64) error {
+	var sample Sample
+	if err := e.Store.Get(bucket, key, &sample); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	if sample.Count == 0 {
+		sample.Seconds = value
+	} else {
+		sample.Seconds = smoothing*value + (1-smoothing)*sample.Seconds
+	}
+	sample.Count++
+	return e.Store.Put(bucket, key, sample)
+}
+
+func unitKey(model string) string {
+	return model + "/per-unit"
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
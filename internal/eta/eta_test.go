@@ -0,0 +1,54 @@
+package eta
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"slugbot/internal/store"
+)
+
+func TestEstimator_NoHistory(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	e := &Estimator{Store: s}
+
+	_, ok := e.Estimate(Shape{Model: "full", Steps: 100, Length: 30})
+	require.False(t, ok)
+}
+
+func TestEstimator_UsesBucketThenExtrapolates(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	e := &Estimator{Store: s}
+
+	shape := Shape{Model: "full", Steps: 100, Length: 30}
+	e.Record(shape, 60*time.Second)
+
+	got, ok := e.Estimate(shape)
+	require.True(t, ok)
+	require.Equal(t, 60*time.Second, got)
+
+	// same model, twice the work, no bucket history yet
+	got, ok = e.Estimate(Shape{Model: "full", Steps: 200, Length: 30})
+	require.True(t, ok)
+	require.Equal(t, 120*time.Second, got)
+
+	_, ok = e.Estimate(Shape{Model: "small", Steps: 8, Length: 30})
+	require.False(t, ok)
+}
+
+func TestEstimator_SmoothsObservations(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	e := &Estimator{Store: s}
+
+	shape := Shape{Model: "small", Steps: 8, Length: 10}
+	e.Record(shape, 10*time.Second)
+	e.Record(shape, 20*time.Second)
+
+	got, ok := e.Estimate(shape)
+	require.True(t, ok)
+	require.Equal(t, 12500*time.Millisecond, got)
+}
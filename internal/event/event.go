@@ -0,0 +1,166 @@
+// Package event keeps track of prompt-of-the-day rounds and the entries
+// submitted to them.
+package event
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"slugbot/internal/store"
+)
+
+const bucket = "events"
+
+// ErrClosed is returned when adding an entry to an event that's over.
+var ErrClosed = errors.New("event is closed")
+
+// Entry is one submission to an event.
+type Entry struct {
+	UserID    string    `json:"user_id"`
+	MessageID string    `json:"message_id"` // the `.saudio` message; its result is posted in reply
+	Prompt    string    `json:"prompt"`
+	At        time.Time `json:"at"`
+}
+
+// Event is one round: a theme announced in a channel, open for entries until Closes.
+type Event struct {
+	ID        string    `json:"id"` // the announcement message's ID; entries reply to it
+	GuildID   string    `json:"guild_id"`
+	ChannelID string    `json:"channel_id"`
+	Theme     string    `json:"theme"`
+	Opened    time.Time `json:"opened"`
+	Closes    time.Time `json:"closes"`
+	Closed    bool      `json:"closed"`
+	Entries   []Entry   `json:"entries"`
+}
+
+// Store persists events.
+type Store struct {
+	Store *store.Store
+
+	mutex sync.Mutex
+}
+
+// Open saves a new event.
+func (s *Store) Open(e Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.Store.Put(bucket, e.ID, e)
+}
+
+// Get returns an event by its announcement message ID.
+func (s *Store) Get(id string) (Event, error) {
+	var e Event
+	err := s.Store.Get(bucket, id, &e)
+	return e, err
+}
+
+// AddEntry adds a submission to an open event. A message is only entered once.
+func (s *Store) AddEntry(id string, entry Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if e.Closed || !entry.At.Before(e.Closes) {
+		return ErrClosed
+	}
+	if slices.ContainsFunc(e.Entries, func(existing Entry) bool { return existing.MessageID == entry.MessageID }) {
+		return nil
+	}
+	e.Entries = append(e.Entries, entry)
+	return s.Store.Put(bucket, id, e)
+}
+
+// Due returns the open events whose entries closed at or before now, including
+// any that came due while the bot was down.
+func (s *Store) Due(now time.Time) ([]Event, error) {
+	keys, err := s.Store.Keys(bucket)
+	if err != nil {
+		return nil, err
+	}
+	var due []Event
+	for _, key := range keys {
+		e, err := s.Get(key)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't load event %s: %w", key, err)
+		}
+		if !e.Closed && !now.Before(e.Closes) {
+			due = append(due, e)
+		}
+	}
+	return due, nil
+}
+
+// Close marks an event as over and returns it with all of its entries.
+func (s *Store) Close(id string) (Event, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, err := s.Get(id)
+	if err != nil {
+		return e, err
+	}
+	e.Closed = true
+	return e, s.Store.Put(bucket, id, e)
+}
+
+// RemoveGuild deletes every event of a guild, entries and all, and returns how
+// many it deleted.
+func (s *Store) RemoveGuild(guildID string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keys, err := s.Store.Keys(bucket)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, key := range keys {
+		e, err := s.Get(key)
+		if err != nil {
+			return removed, fmt.Errorf("couldn't load event %s: %w", key, err)
+		}
+		if e.GuildID != guildID {
+			continue
+		}
+		if err := s.Store.Delete(bucket, key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// RemoveUser deletes a user's entries from every event and returns how many it deleted.
+func (s *Store) RemoveUser(userID string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keys, err := s.Store.Keys(bucket)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, key := range keys {
+		e, err := s.Get(key)
+		if err != nil {
+			return removed, fmt.Errorf("couldn't load event %s: %w", key, err)
+		}
+		kept := slices.DeleteFunc(slices.Clone(e.Entries), func(entry Entry) bool { return entry.UserID == userID })
+		if len(kept) == len(e.Entries) {
+			continue
+		}
+		removed += len(e.Entries) - len(kept)
+		e.Entries = kept
+		if err := s.Store.Put(bucket, key, e); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
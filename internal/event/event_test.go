@@ -0,0 +1,86 @@
+package event
+
+import (
+	"testing"
+	"time"
+
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_EntriesUntilClose(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	events := &Store{Store: s}
+
+	opened := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, events.Open(Event{ID: "m1", ChannelID: "c1", Theme: "deep sea", Opened: opened, Closes: opened.Add(time.Hour)}))
+
+	entry := Entry{UserID: "u1", MessageID: "e1", Prompt: "whale song", At: opened.Add(time.Minute)}
+	require.NoError(t, events.AddEntry("m1", entry))
+	require.NoError(t, events.AddEntry("m1", entry))
+	require.ErrorIs(t, events.AddEntry("m1", Entry{MessageID: "e2", At: opened.Add(2 * time.Hour)}), ErrClosed)
+
+	due, err := events.Due(opened.Add(30 * time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, due)
+
+	due, err = events.Due(opened.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, []Entry{entry}, due[0].Entries)
+
+	closed, err := events.Close("m1")
+	require.NoError(t, err)
+	require.True(t, closed.Closed)
+
+	due, err = events.Due(opened.Add(2 * time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, due)
+}
+
+func TestStore_RemoveUser(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	events := &Store{Store: s}
+
+	opened := time.Now().UTC().Truncate(time.Second)
+	for _, id := range []string{"m1", "m2"} {
+		require.NoError(t, events.Open(Event{ID: id, Opened: opened, Closes: opened.Add(time.Hour)}))
+		require.NoError(t, events.AddEntry(id, Entry{UserID: "u1", MessageID: id + "-u1", At: opened}))
+	}
+	require.NoError(t, events.AddEntry("m1", Entry{UserID: "u2", MessageID: "m1-u2", At: opened}))
+
+	removed, err := events.RemoveUser("u1")
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+
+	e, err := events.Get("m1")
+	require.NoError(t, err)
+	require.Len(t, e.Entries, 1)
+	require.Equal(t, "u2", e.Entries[0].UserID)
+	e, err = events.Get("m2")
+	require.NoError(t, err)
+	require.Empty(t, e.Entries)
+}
+
+func TestStore_RemoveGuild(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	events := &Store{Store: s}
+
+	opened := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, events.Open(Event{ID: "m1", GuildID: "g1", Opened: opened, Closes: opened.Add(time.Hour)}))
+	require.NoError(t, events.Open(Event{ID: "m2", GuildID: "g1", Opened: opened, Closes: opened.Add(time.Hour)}))
+	require.NoError(t, events.Open(Event{ID: "m3", GuildID: "g2", Opened: opened, Closes: opened.Add(time.Hour)}))
+
+	removed, err := events.RemoveGuild("g1")
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+
+	_, err = events.Get("m1")
+	require.ErrorIs(t, err, store.ErrNotFound)
+	_, err = events.Get("m3")
+	require.NoError(t, err)
+}
@@ -0,0 +1,108 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"slugbot/internal/io/slog"
+	"slugbot/internal/telemetry"
+)
+
+// ErrDependencyFailed is the error recorded for the later stages of a chain
+// when an earlier stage fails or is cancelled, so they never run.
+var ErrDependencyFailed = errors.New("an earlier stage didn't finish")
+
+// Consuming tasks take the files an earlier stage of their chain produced,
+// e.g. a limiter taking the generated audio, before they run.
+type Consuming interface {
+	SetInputs(paths []string)
+}
+
+// chain links the stages queued together by EnqueueChain.
+type chain struct {
+	ids     []string // job IDs of the stages, in order
+	outputs []string // what the latest finished stage produced
+}
+
+// EnqueueChain adds tasks to the queue, at their turn as EnqueueGroup's would
+// be, as the stages of one workflow, e.g. generate → limit → spectrogram, and
+// returns how many tasks are ahead of the first. Each stage runs only after
+// the one before it finished successfully, and a Consuming stage is first
+// given the files the stage before it produced. If a stage fails or is cancelled, the stages after
+// it are cancelled with an error wrapping ErrDependencyFailed. Like
+// EnqueueGroup, either all of the stages are added or none are.
+func (q *TaskQueue) EnqueueChain(tasks []Task) (int, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(tasks) > 0 {
+		if ahead, ok := q.duplicateLocked(tasks[0]); ok {
+			return ahead, nil
+		}
+		if err := q.checkOwnerLocked(tasks[0]); err != nil {
+			return 0, err
+		}
+	}
+	if err := q.checkDepthLocked(len(tasks)); err != nil {
+		return 0, err
+	}
+	if err := q.admitLocked(tasks, nil); err != nil {
+		return 0, err
+	}
+
+	at, finish := q.placeLocked(tasks)
+	ahead := at
+	if q.current != nil {
+		ahead++
+	}
+
+	c := &chain{}
+	stages := make([]queuedTask, 0, len(tasks))
+	for i, task := range tasks {
+		_, wait := telemetry.Start(task.TraceContext(), "queue.wait", telemetry.TraceIDAttr(task.TraceID()))
+		id := q.registerLocked(task)
+		c.ids = append(c.ids, id)
+		stages = append(stages, queuedTask{id: id, task: task, wait: wait, chain: c, stage: i, batch: c.ids[0], finish: finish})
+	}
+	q.queue = slices.Insert(q.queue, at, stages...)
+	if len(tasks) > 0 {
+		slog.With("trace", tasks[0].TraceID()).Info("enqueued chain of ", len(tasks), " stages ending at position ", at+len(tasks))
+	}
+	q.startLocked()
+	return ahead, nil
+}
+
+// dropChainLocked takes the stages after queued out of the queue and records
+// them as cancelled because of cause. It returns them so the caller can tell
+// them once the mutex is released. The caller must hold the mutex.
+func (q *TaskQueue) dropChainLocked(queued queuedTask, cause error) []queuedTask {
+	if queued.chain == nil {
+		return nil
+	}
+	later := queued.chain.ids[queued.stage+1:]
+	var dropped []queuedTask
+	q.queue = slices.DeleteFunc(q.queue, func(waiting queuedTask) bool {
+		if waiting.chain != queued.chain || !slices.Contains(later, waiting.id) {
+			return false
+		}
+		dropped = append(dropped, waiting)
+		return true
+	})
+	for _, stage := range dropped {
+		q.finishLocked(stage.id, StateCancelled, fmt.Errorf("%w: %w", ErrDependencyFailed, cause))
+	}
+	return dropped
+}
+
+// notifyCancelled ends the waiting spans of cancelled tasks and tells them they won't run.
+// The caller must not hold the mutex.
+func notifyCancelled(cancelled []queuedTask) {
+	for _, queued := range cancelled {
+		queued.wait.End()
+		slog.With("trace", queued.task.TraceID()).Info("cancelled queued task")
+		if c, ok := queued.task.(Cancellable); ok {
+			c.Cancelled()
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// stageTask is one stage of a chain: it records the inputs it was given and
+// produces a file named after itself, or fails with err.
+type stageTask struct {
+	name string
+	err  error
+	gate chan struct{} // optional; the stage waits for it before finishing
+
+	mutex     sync.Mutex
+	inputs    []string
+	ran       bool
+	cancelled bool
+}
+
+func (t *stageTask) Apply() error {
+	if t.gate != nil {
+		<-t.gate
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.ran = true
+	return t.err
+}
+func (t *stageTask) HandleError(error)                   {}
+func (t *stageTask) Prompt() string                      { return t.name }
+func (t *stageTask) TraceID() string                     { return t.name }
+func (t *stageTask) TraceContext() context.Context       { return context.Background() }
+func (t *stageTask) SetTraceContext(ctx context.Context) {}
+func (t *stageTask) Outputs() []string                   { return []string{t.name + ".wav"} }
+
+func (t *stageTask) SetInputs(paths []string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.inputs = paths
+}
+
+func (t *stageTask) Cancelled() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.cancelled = true
+}
+
+func (t *stageTask) state() (inputs []string, ran bool, cancelled bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.inputs, t.ran, t.cancelled
+}
+
+// chainFinished waits until every job in the queue has finished.
+func chainFinished(t *testing.T, q *TaskQueue) {
+	require.Eventually(t, func() bool { return len(q.Jobs()) == 0 }, time.Second, time.Millisecond)
+}
+
+func TestTaskQueue_ChainPassesOutputsToTheNextStage(t *testing.T) {
+	q := NewTaskQueue()
+	generate, limit, spectrogram := &stageTask{name: "generate"}, &stageTask{name: "limit"}, &stageTask{name: "spectrogram"}
+
+	_, err := q.EnqueueChain([]Task{generate, limit, spectrogram})
+	require.NoError(t, err)
+	chainFinished(t, q)
+
+	inputs, ran, _ := generate.state()
+	require.True(t, ran)
+	require.Empty(t, inputs)
+	inputs, _, _ = limit.state()
+	require.Equal(t, []string{"generate.wav"}, inputs)
+	inputs, _, _ = spectrogram.state()
+	require.Equal(t, []string{"limit.wav"}, inputs)
+}
+
+func TestTaskQueue_ChainStopsAtAFailedStage(t *testing.T) {
+	q := NewTaskQueue()
+	boom := errors.New("boom")
+	generate, limit, spectrogram := &stageTask{name: "generate"}, &stageTask{name: "limit", err: boom}, &stageTask{name: "spectrogram"}
+	other := &stageTask{name: "other"}
+
+	_, err := q.EnqueueChain([]Task{generate, limit, spectrogram})
+	require.NoError(t, err)
+	_, err = q.Enqueue(other)
+	require.NoError(t, err)
+	chainFinished(t, q)
+
+	_, ran, cancelled := spectrogram.state()
+	require.False(t, ran)
+	require.True(t, cancelled)
+	_, ran, _ = other.state()
+	require.True(t, ran, "tasks outside the chain still run")
+
+	history := q.History()
+	states := map[string]TaskInfo{}
+	for _, info := range history {
+		states[info.Task.Prompt()] = info
+	}
+	require.Equal(t, StateFailed, states["limit"].State)
+	require.Equal(t, StateCancelled, states["spectrogram"].State)
+	require.ErrorIs(t, states["spectrogram"].Err, ErrDependencyFailed)
+	require.ErrorIs(t, states["spectrogram"].Err, boom)
+}
+
+func TestTaskQueue_CancellingAStageCancelsTheRest(t *testing.T) {
+	q := NewTaskQueue()
+	gate := make(chan struct{})
+	generate := &stageTask{name: "generate", gate: gate}
+	stages := []*stageTask{generate, {name: "limit"}, {name: "spectrogram"}}
+
+	_, err := q.EnqueueChain([]Task{stages[0], stages[1], stages[2]})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { _, ok := q.Running(); return ok }, time.Second, time.Millisecond)
+
+	jobs := q.Jobs()
+	require.Len(t, jobs, 3)
+	require.True(t, q.CancelJob(jobs[1].ID))
+	close(gate)
+	chainFinished(t, q)
+
+	for _, stage := range stages[1:] {
+		_, ran, cancelled := stage.state()
+		require.False(t, ran, stage.name)
+		require.True(t, cancelled, stage.name)
+	}
+	info, ok := q.Info(jobs[2].ID)
+	require.True(t, ok)
+	require.ErrorIs(t, info.Err, ErrDependencyFailed)
+	require.Contains(t, info.Err.Error(), fmt.Sprintf("stage %d was cancelled", 2))
+}
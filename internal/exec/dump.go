@@ -0,0 +1,104 @@
+package exec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Dump is the whole state of a queue at one moment: every job it knows of,
+// what each is doing, and the message that started it. It's for working out
+// how a queue got stuck, and for queueing its jobs again after losing it.
+type Dump struct {
+	Taken  time.Time   `json:"taken"`
+	Paused bool        `json:"paused"`
+	Jobs   []DumpedJob `json:"jobs"` // running, then waiting in the order they'll run, then finished, latest first
+}
+
+// DumpedJob is a TaskInfo as it's written to a dump.
+type DumpedJob struct {
+	ID       string        `json:"id"`
+	Type     string        `json:"type"` // the task's Go type, e.g. "*audio.StableAudioCommand"
+	State    TaskState     `json:"state"`
+	Position int           `json:"position"`
+	Prompt   string        `json:"prompt"`
+	Progress string        `json:"progress,omitempty"`
+	Outputs  []string      `json:"outputs,omitempty"`
+	Enqueued time.Time     `json:"enqueued"`
+	Started  *time.Time    `json:"started,omitempty"`
+	Finished *time.Time    `json:"finished,omitempty"`
+	Err      string        `json:"error,omitempty"`
+	Trigger  *JournalEntry `json:"trigger,omitempty"` // how to start the job again; missing if it wasn't started by a message
+}
+
+// Dump takes a snapshot of every job in the queue.
+func (q *TaskQueue) Dump() Dump {
+	paused, _, _ := q.Status()
+	dump := Dump{Taken: time.Now(), Paused: paused, Jobs: []DumpedJob{}}
+	for _, info := range append(q.Jobs(), q.History()...) {
+		dump.Jobs = append(dump.Jobs, dumpJob(info))
+	}
+	return dump
+}
+
+func dumpJob(info TaskInfo) DumpedJob {
+	job := DumpedJob{
+		ID:       info.ID,
+		Type:     fmt.Sprintf("%T", info.Task),
+		State:    info.State,
+		Position: info.Position,
+		Prompt:   info.Task.Prompt(),
+		Enqueued: info.Enqueued,
+	}
+	if progressing, ok := info.Task.(Progressing); ok {
+		job.Progress = progressing.Progress()
+	}
+	if producing, ok := info.Task.(Producing); ok && info.State == StateDone {
+		job.Outputs = producing.Outputs()
+	}
+	if !info.Started.IsZero() {
+		job.Started = &info.Started
+	}
+	if !info.Finished.IsZero() {
+		job.Finished = &info.Finished
+	}
+	if info.Err != nil {
+		job.Err = info.Err.Error()
+	}
+	if entry, ok := journalEntry(&info); ok {
+		job.Trigger = &entry
+	}
+	return job
+}
+
+// ParseDump reads a dump written by Dump, marshalled as JSON.
+func ParseDump(data []byte) (Dump, error) {
+	var dump Dump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return Dump{}, fmt.Errorf("not a queue dump: %w", err)
+	}
+	if dump.Taken.IsZero() || dump.Jobs == nil {
+		return Dump{}, errors.New("not a queue dump: it's missing when it was taken, or its jobs")
+	}
+	return dump, nil
+}
+
+// Unfinished returns the triggers of the dump's waiting and running jobs, in
+// the order they'd have run. The stages of a chain share their trigger, which
+// is only returned once, since they run again together.
+func (d Dump) Unfinished() []JournalEntry {
+	var entries []JournalEntry
+	seen := map[string]bool{}
+	for _, job := range d.Jobs {
+		if job.State != StateWaiting && job.State != StateRunning {
+			continue
+		}
+		if job.Trigger == nil || seen[job.Trigger.MessageID] {
+			continue
+		}
+		seen[job.Trigger.MessageID] = true
+		entries = append(entries, *job.Trigger)
+	}
+	return entries
+}
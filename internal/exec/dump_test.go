@@ -0,0 +1,59 @@
+package exec
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDump_RoundTripsUnfinishedJobs(t *testing.T) {
+	q := NewTaskQueue()
+	finished := journaledTask{newFakeTask("finished")}
+	running := journaledTask{newFakeTask("running")}
+	waiting := journaledTask{newFakeTask("waiting")}
+	anonymous := newFakeTask("anonymous") // not started by a message, so it can't be restored
+	q.Enqueue(finished)
+	<-finished.started
+	q.Enqueue(running)
+	q.Enqueue(anonymous)
+	q.Enqueue(waiting)
+	close(finished.release)
+	<-running.started
+
+	data, err := json.Marshal(q.Dump())
+	require.NoError(t, err)
+	dump, err := ParseDump(data)
+	require.NoError(t, err)
+
+	require.Len(t, dump.Jobs, 4)
+	require.Equal(t, StateRunning, dump.Jobs[0].State)
+	require.NotNil(t, dump.Jobs[0].Started)
+	require.Equal(t, StateWaiting, dump.Jobs[1].State)
+	require.Nil(t, dump.Jobs[1].Trigger)
+	require.Equal(t, 2, dump.Jobs[2].Position)
+	require.Equal(t, StateDone, dump.Jobs[3].State)
+	require.NotNil(t, dump.Jobs[3].Finished)
+	require.Equal(t, "exec.journaledTask", dump.Jobs[3].Type)
+
+	unfinished := dump.Unfinished()
+	require.Len(t, unfinished, 2)
+	require.Equal(t, "running", unfinished[0].MessageID)
+	require.Equal(t, ".saudio waiting", unfinished[1].Trigger().Content)
+
+	close(running.release)
+	close(anonymous.release)
+	close(waiting.release)
+	require.Eventually(t, func() bool {
+		_, busy, left := q.Status()
+		return !busy && left == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestParseDump_RejectsOtherJSON(t *testing.T) {
+	for _, data := range []string{``, `[]`, `{}`, `{"taken": "2026-01-02T03:04:05Z"}`, `{"jobs": []}`} {
+		_, err := ParseDump([]byte(data))
+		require.Error(t, err, data)
+	}
+}
@@ -0,0 +1,149 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/io/slog"
+	"slugbot/internal/store"
+)
+
+// journalBucket keeps the jobs that are waiting or running, keyed by job ID,
+// so the ones a crash loses can be found at the next startup.
+const journalBucket = "journal"
+
+// messageTriggered tasks can say which message started them, and so how to
+// start them again.
+type messageTriggered interface {
+	TriggerMessage() *discordgo.MessageCreate
+}
+
+// JournalEntry is a job that hadn't finished when it was last saved.
+type JournalEntry struct {
+	JobID       string                         `json:"job_id"`
+	Prompt      string                         `json:"prompt"`
+	State       TaskState                      `json:"state"` // waiting or running
+	Enqueued    time.Time                      `json:"enqueued"`
+	Resumed     int                            `json:"resumed,omitempty"` // how many earlier runs it was lost from
+	MessageID   string                         `json:"message_id"`
+	ChannelID   string                         `json:"channel_id"`
+	GuildID     string                         `json:"guild_id,omitempty"`
+	Author      *discordgo.User                `json:"author,omitempty"`
+	Content     string                         `json:"content"`
+	Attachments []*discordgo.MessageAttachment `json:"attachments,omitempty"`
+	Embeds      []*discordgo.MessageEmbed      `json:"embeds,omitempty"`
+	Reference   *discordgo.MessageReference    `json:"reference,omitempty"`
+}
+
+// Trigger rebuilds the message that started the job, so it can be dispatched again.
+func (e JournalEntry) Trigger() *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:               e.MessageID,
+		ChannelID:        e.ChannelID,
+		GuildID:          e.GuildID,
+		Author:           e.Author,
+		Content:          e.Content,
+		Attachments:      e.Attachments,
+		Embeds:           e.Embeds,
+		MessageReference: e.Reference,
+	}}
+}
+
+// Journal saves the queue's unfinished jobs to Store as they come and go.
+// Only jobs started by a message are kept, since only those can be run again.
+type Journal struct {
+	Store *store.Store
+
+	mutex   sync.Mutex
+	resumed map[string]int // by trigger message ID: how many runs the job was lost from
+}
+
+// Recover returns the jobs an earlier run left unfinished, oldest first, and
+// forgets them. If a job triggered by the same message is queued again, it's
+// counted as resumed, so a job that keeps crashing the bot can be given up on.
+func (j *Journal) Recover() ([]JournalEntry, error) {
+	if j == nil || j.Store == nil {
+		return nil, nil
+	}
+	keys, err := j.Store.Keys(journalBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.resumed == nil {
+		j.resumed = map[string]int{}
+	}
+	var entries []JournalEntry
+	var errs []error
+	for _, key := range keys {
+		var entry JournalEntry
+		if err := j.Store.Get(journalBucket, key, &entry); err != nil {
+			errs = append(errs, fmt.Errorf("couldn't load job %s: %w", key, err))
+		} else {
+			entries = append(entries, entry)
+			j.resumed[entry.MessageID] = max(j.resumed[entry.MessageID], entry.Resumed+1)
+		}
+		j.forget(key)
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].Enqueued.Before(entries[b].Enqueued) })
+	return entries, errors.Join(errs...)
+}
+
+// record saves a job in a new state.
+func (j *Journal) record(info *TaskInfo) {
+	if j == nil || j.Store == nil || info == nil {
+		return
+	}
+	entry, ok := journalEntry(info)
+	if !ok {
+		return
+	}
+
+	j.mutex.Lock()
+	entry.Resumed = j.resumed[entry.MessageID]
+	j.mutex.Unlock()
+	if err := j.Store.Put(journalBucket, info.ID, entry); err != nil {
+		slog.Warn("couldn't save job ", info.ID, " to the journal: ", err)
+	}
+}
+
+// journalEntry describes a job by the message that started it. ok is false if
+// it wasn't started by a message.
+func journalEntry(info *TaskInfo) (entry JournalEntry, ok bool) {
+	triggered, ok := info.Task.(messageTriggered)
+	if !ok || triggered.TriggerMessage() == nil || triggered.TriggerMessage().Message == nil {
+		return JournalEntry{}, false
+	}
+	message := triggered.TriggerMessage().Message
+	return JournalEntry{
+		JobID:       info.ID,
+		Prompt:      info.Task.Prompt(),
+		State:       info.State,
+		Enqueued:    info.Enqueued,
+		MessageID:   message.ID,
+		ChannelID:   message.ChannelID,
+		GuildID:     message.GuildID,
+		Author:      message.Author,
+		Content:     message.Content,
+		Attachments: message.Attachments,
+		Embeds:      message.Embeds,
+		Reference:   message.MessageReference,
+	}, true
+}
+
+// forget removes a finished job.
+func (j *Journal) forget(id string) {
+	if j == nil || j.Store == nil {
+		return
+	}
+	if err := j.Store.Delete(journalBucket, id); err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.Warn("couldn't remove job ", id, " from the journal: ", err)
+	}
+}
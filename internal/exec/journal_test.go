@@ -0,0 +1,78 @@
+package exec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+
+	"slugbot/internal/store"
+)
+
+// journaledTask is a fakeTask that knows the message that started it.
+type journaledTask struct {
+	*fakeTask
+}
+
+func (t journaledTask) TriggerMessage() *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID: t.messageID, ChannelID: "c1", Content: ".saudio " + t.messageID, Author: &discordgo.User{ID: "u1"},
+	}}
+}
+
+func TestJournal_KeepsUnfinishedJobsForTheNextRun(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	q := NewTaskQueue()
+	q.Journal = &Journal{Store: s}
+
+	finished := journaledTask{newFakeTask("finished")}
+	running := journaledTask{newFakeTask("running")}
+	waiting := journaledTask{newFakeTask("waiting")}
+	q.Enqueue(finished)
+	<-finished.started
+	q.Enqueue(running)
+	q.Enqueue(waiting)
+	close(finished.release)
+	<-running.started
+
+	// the "crash": a new run finds what the old one left
+	restarted := &Journal{Store: s}
+	left, err := restarted.Recover()
+	require.NoError(t, err)
+	require.Len(t, left, 2)
+	require.Equal(t, "running", left[0].MessageID)
+	require.Equal(t, StateRunning, left[0].State)
+	require.Equal(t, "waiting", left[1].MessageID)
+	require.Equal(t, StateWaiting, left[1].State)
+	require.Equal(t, ".saudio waiting", left[1].Trigger().Content)
+	require.Equal(t, "u1", left[1].Trigger().Author.ID)
+
+	// a job queued again counts as resumed, and is only kept until it finishes
+	resumed := NewTaskQueue()
+	resumed.Journal = restarted
+	again := journaledTask{newFakeTask("waiting")}
+	resumed.Enqueue(again)
+	<-again.started
+	var entry JournalEntry
+	keys, err := s.Keys(journalBucket)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.NoError(t, s.Get(journalBucket, keys[0], &entry))
+	require.Equal(t, 1, entry.Resumed)
+
+	close(again.release)
+	require.Eventually(t, func() bool {
+		keys, _ := s.Keys(journalBucket)
+		return len(keys) == 0
+	}, time.Second, 5*time.Millisecond)
+
+	// let the old queue wind down before its store goes away
+	close(running.release)
+	close(waiting.release)
+	require.Eventually(t, func() bool {
+		_, busy, left := q.Status()
+		return !busy && left == 0
+	}, time.Second, 5*time.Millisecond)
+}
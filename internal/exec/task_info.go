@@ -0,0 +1,212 @@
+package exec
+
+import (
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TaskState is where a task is in its life in the queue.
+type TaskState string
+
+const (
+	StateWaiting   TaskState = "waiting"
+	StateRunning   TaskState = "running"
+	StateDone      TaskState = "done"
+	StateFailed    TaskState = "failed"
+	StateCancelled TaskState = "cancelled"
+)
+
+// Progressing tasks report their latest progress text while they run.
+type Progressing interface {
+	Progress() string
+}
+
+// Producing tasks report the files they wrote, once they've run.
+type Producing interface {
+	Outputs() []string
+}
+
+// TaskInfo describes one job for status lookups.
+type TaskInfo struct {
+	ID       string // short, human-friendly, e.g. "A7F3"
+	Task     Task
+	State    TaskState
+	Position int // how many tasks are ahead of it, while it's waiting
+	Enqueued time.Time
+	Started  time.Time
+	Finished time.Time
+	Err      error
+
+	seq int // enqueue order, for listing a message's jobs in order
+}
+
+const (
+	jobIDLength = 4
+	// jobIDAlphabet leaves out characters that are easy to mix up (0/O, 1/I)
+	jobIDAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	// how many finished jobs stay available for lookups
+	maxFinishedJobs = 200
+)
+
+// registerLocked assigns a task a new job ID and starts tracking it as waiting.
+// The caller must hold the mutex.
+func (q *TaskQueue) registerLocked(task Task) string {
+	if q.jobs == nil {
+		q.jobs = map[string]*TaskInfo{}
+	}
+	id := newJobID()
+	for q.jobs[id] != nil {
+		id = newJobID()
+	}
+	q.seq++
+	q.jobs[id] = &TaskInfo{ID: id, Task: task, State: StateWaiting, Enqueued: time.Now(), seq: q.seq}
+	q.Journal.record(q.jobs[id])
+	return id
+}
+
+// finishLocked records how a job ended and forgets the oldest finished jobs
+// beyond maxFinishedJobs. The caller must hold the mutex.
+func (q *TaskQueue) finishLocked(id string, state TaskState, err error) {
+	info := q.jobs[id]
+	if info == nil {
+		return
+	}
+	info.State, info.Err, info.Finished = state, err, time.Now()
+	q.Journal.forget(id)
+
+	q.finished = append(q.finished, id)
+	if len(q.finished) > maxFinishedJobs {
+		delete(q.jobs, q.finished[0])
+		q.finished = q.finished[1:]
+	}
+}
+
+// Info looks up a job by its ID, ignoring case.
+func (q *TaskQueue) Info(id string) (TaskInfo, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	info, ok := q.jobs[strings.ToUpper(id)]
+	if !ok {
+		return TaskInfo{}, false
+	}
+	snapshot := *info
+	if snapshot.State == StateWaiting {
+		for i, queued := range q.queue {
+			if queued.id == snapshot.ID {
+				snapshot.Position = i
+				if q.current != nil {
+					snapshot.Position++
+				}
+			}
+		}
+	}
+	return snapshot, true
+}
+
+// Running returns the job that's currently running, if any.
+func (q *TaskQueue) Running() (TaskInfo, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, info := range q.jobs {
+		if info.State == StateRunning {
+			return *info, true
+		}
+	}
+	return TaskInfo{}, false
+}
+
+// Jobs returns the running job, if any, followed by the waiting jobs in the order they'll run.
+func (q *TaskQueue) Jobs() []TaskInfo {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.jobsLocked()
+}
+
+// jobsLocked is Jobs for a caller that holds the mutex.
+func (q *TaskQueue) jobsLocked() []TaskInfo {
+	var jobs []TaskInfo
+	for _, info := range q.jobs {
+		if info.State == StateRunning {
+			jobs = append(jobs, *info)
+		}
+	}
+	offset := len(jobs)
+	for i, queued := range q.queue {
+		if info := q.jobs[queued.id]; info != nil {
+			snapshot := *info
+			snapshot.Position = i + offset
+			jobs = append(jobs, snapshot)
+		}
+	}
+	return jobs
+}
+
+// History returns the finished jobs still available for lookups, most recently finished first.
+func (q *TaskQueue) History() []TaskInfo {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	jobs := make([]TaskInfo, 0, len(q.finished))
+	for i := len(q.finished) - 1; i >= 0; i-- {
+		if info := q.jobs[q.finished[i]]; info != nil {
+			jobs = append(jobs, *info)
+		}
+	}
+	return jobs
+}
+
+// Forget drops a finished job from the history, e.g. when its results are
+// deleted. It reports false if there's no such finished job.
+func (q *TaskQueue) Forget(id string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	id = strings.ToUpper(id)
+	info := q.jobs[id]
+	if info == nil || info.State == StateWaiting || info.State == StateRunning {
+		return false
+	}
+	delete(q.jobs, id)
+	for i, finished := range q.finished {
+		if finished == id {
+			q.finished = append(q.finished[:i], q.finished[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// JobIDs returns the IDs of the waiting and running jobs triggered by messageID, in the order they were queued.
+func (q *TaskQueue) JobIDs(messageID string) []string {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var infos []*TaskInfo
+	for _, info := range q.jobs {
+		if info.State != StateWaiting && info.State != StateRunning {
+			continue
+		}
+		if triggered, ok := info.Task.(Triggered); ok && triggered.MessageID() == messageID {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].seq < infos[j].seq })
+
+	ids := make([]string, len(infos))
+	for i, info := range infos {
+		ids[i] = info.ID
+	}
+	return ids
+}
+
+func newJobID() string {
+	id := make([]byte, jobIDLength)
+	for i := range id {
+		id[i] = jobIDAlphabet[rand.IntN(len(jobIDAlphabet))]
+	}
+	return string(id)
+}
@@ -1,53 +1,691 @@
 package exec
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"slugbot/internal/eta"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/telemetry"
 )
 
 type Task interface {
 	Apply() error
 	HandleError(error)
 	Prompt() string
+	TraceID() string
+	TraceContext() context.Context
+	SetTraceContext(ctx context.Context)
+}
+
+// Estimable tasks report their shape so their runtime can be predicted and recorded.
+type Estimable interface {
+	Shape() (eta.Shape, bool)
+}
+
+// Triggered tasks were started by a Discord message, and can be found by its ID.
+type Triggered interface {
+	MessageID() string
+}
+
+// Editable tasks can take new arguments while they're still waiting to run.
+// Content returns the arguments they have now, so a refused edit can be undone.
+type Editable interface {
+	Edit(content string) error
+	Content() string
+}
+
+// Cancellable tasks are told when they're removed from the queue without running.
+type Cancellable interface {
+	Cancelled()
+}
+
+// Interruptible tasks can be stopped while they run. A task that asks to be
+// retried goes back to the front of the queue instead of reporting its error.
+type Interruptible interface {
+	Interrupt(reason error, retry bool) bool
+	Retrying() bool
 }
 
+// queuedTask pairs a task with the span measuring how long it waited in the queue.
+type queuedTask struct {
+	id   string
+	task Task
+	wait trace.Span
+
+	chain  *chain        // set for the stages of an EnqueueChain
+	stage  int           // index in chain
+	batch  string        // job ID of the first task enqueued with it, so a group counts as one job per owner
+	finish time.Duration // fair-queueing tag the queue is kept in order of; see placeLocked
+}
+
+// ErrQueueFull is returned when MaxDepth tasks are already waiting.
+var ErrQueueFull = errors.New("the queue is full")
+
+// ErrOwnerBusy is returned when a task's owner already has as many jobs
+// waiting as MaxOwnerWaiting allows.
+var ErrOwnerBusy = errors.New("too many of your jobs are already queued")
+
+// ErrCancelled is the interruption reason of a running job stopped with CancelJob.
+var ErrCancelled = errors.New("the job was cancelled")
+
+// ErrNotSwappable is returned when two jobs can't trade places with Swap.
+var ErrNotSwappable = errors.New("those jobs can't swap places")
+
 type TaskQueue struct {
-	queue   []Task
-	mutex   sync.Mutex
-	running bool
+	Estimator *eta.Estimator                              // optional; enables runtime history and wait estimates
+	OnFinish  func(task Task, err error)                  // optional; called after each task runs
+	Admit     func(tasks []Task, queued []TaskInfo) error // optional; may refuse tasks before they're queued, e.g. for lack of credits, given the jobs already waiting or running
+	MaxDepth  int                                         // most tasks that may wait at once; 0 for no limit
+	Journal   *Journal                                    // optional; saves unfinished jobs so a restart can tell which were lost
+
+	// Owner, if set, names who submitted a task, so that no one owner can
+	// take over the queue: owners take turns by the estimated runtime of
+	// their jobs, and at most MaxOwnerWaiting of their jobs wait, where the
+	// tasks of one EnqueueGroup or EnqueueChain count as one job. 0 for no
+	// limit. MaxWaitingFor, if set, overrides MaxOwnerWaiting for the owners
+	// it returns true for, e.g. a shared account that needs a cap of its own.
+	// Tasks with no owner, or that Uncapped lets through, aren't limited, and
+	// go to the back.
+	Owner           func(task Task) string
+	Uncapped        func(task Task) bool
+	MaxOwnerWaiting int
+	MaxWaitingFor   func(owner string) (int, bool)
+
+	queue        []queuedTask
+	mutex        sync.Mutex
+	running      bool
+	paused       bool
+	current      Task
+	currentStart time.Time
+
+	virtual    time.Duration            // finish tag of the latest task to start
+	lastFinish map[string]time.Duration // by owner: finish tag of their latest waiting job
+
+	jobs     map[string]*TaskInfo // by job ID: waiting, running, and recently finished jobs
+	finished []string             // IDs of finished jobs, oldest first
+	seq      int
 }
 
 func NewTaskQueue() *TaskQueue {
 	return &TaskQueue{
-		queue:   make([]Task, 0),
+		queue:   make([]queuedTask, 0),
 		running: false,
 	}
 }
 
-func (q *TaskQueue) Enqueue(task Task) {
+// Enqueue adds a task to the queue, at its turn per placeLocked, and returns
+// how many tasks are ahead of it, including the one currently running. A task triggered by the
+// same message as a waiting or running one isn't added again. If the queue is
+// full, the task isn't added and the error wraps ErrQueueFull, or ErrOwnerBusy
+// if its owner already has MaxOwnerWaiting jobs waiting. If Admit
+// refuses the task, its error is returned.
+func (q *TaskQueue) Enqueue(task Task) (int, error) {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 
-	q.queue = append(q.queue, task)
-	if !q.running {
+	if ahead, ok := q.duplicateLocked(task); ok {
+		return ahead, nil
+	}
+	if err := q.checkOwnerLocked(task); err != nil {
+		return 0, err
+	}
+	if err := q.checkDepthLocked(1); err != nil {
+		return 0, err
+	}
+	if err := q.admitLocked([]Task{task}, nil); err != nil {
+		return 0, err
+	}
+
+	at, finish := q.placeLocked([]Task{task})
+	ahead := at
+	if q.current != nil {
+		ahead++
+	}
+
+	_, wait := telemetry.Start(task.TraceContext(), "queue.wait", telemetry.TraceIDAttr(task.TraceID()))
+	id := q.registerLocked(task)
+	q.queue = slices.Insert(q.queue, at, queuedTask{id: id, task: task, wait: wait, batch: id, finish: finish})
+	slog.With("trace", task.TraceID()).Info("enqueued task at position ", at+1)
+	q.startLocked()
+	return ahead, nil
+}
+
+// EnqueueGroup adds tasks to the queue, at their owner's turn as if they were
+// one task, so they run back to back, and returns how many tasks are ahead of the first of them. Either all of the
+// tasks are added or, if they don't fit or Admit refuses them, none are.
+func (q *TaskQueue) EnqueueGroup(tasks []Task) (int, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(tasks) > 0 {
+		if ahead, ok := q.duplicateLocked(tasks[0]); ok {
+			return ahead, nil
+		}
+		if err := q.checkOwnerLocked(tasks[0]); err != nil {
+			return 0, err
+		}
+	}
+	if err := q.checkDepthLocked(len(tasks)); err != nil {
+		return 0, err
+	}
+	if err := q.admitLocked(tasks, nil); err != nil {
+		return 0, err
+	}
+
+	at, finish := q.placeLocked(tasks)
+	ahead := at
+	if q.current != nil {
+		ahead++
+	}
+
+	batch := ""
+	group := make([]queuedTask, 0, len(tasks))
+	for _, task := range tasks {
+		_, wait := telemetry.Start(task.TraceContext(), "queue.wait", telemetry.TraceIDAttr(task.TraceID()))
+		id := q.registerLocked(task)
+		if batch == "" {
+			batch = id
+		}
+		group = append(group, queuedTask{id: id, task: task, wait: wait, batch: batch, finish: finish})
+	}
+	q.queue = slices.Insert(q.queue, at, group...)
+	if len(tasks) > 0 {
+		slog.With("trace", tasks[0].TraceID()).Info("enqueued group of ", len(tasks), " tasks ending at position ", at+len(tasks))
+	}
+	q.startLocked()
+	return ahead, nil
+}
+
+// checkDepthLocked returns an error wrapping ErrQueueFull if adding n tasks
+// would exceed MaxDepth. The caller must hold the mutex.
+func (q *TaskQueue) checkDepthLocked(n int) error {
+	if q.MaxDepth <= 0 || len(q.queue)+n <= q.MaxDepth {
+		return nil
+	}
+	slog.Warn("turning away ", n, " task(s); ", len(q.queue), " of ", q.MaxDepth, " slots are taken")
+	if free := q.MaxDepth - len(q.queue); n > 1 && free > 0 {
+		return fmt.Errorf("%w: %d jobs are waiting, and only %d of the %d jobs this needs fit", ErrQueueFull, len(q.queue), free, n)
+	}
+	return fmt.Errorf("%w: %d jobs are waiting", ErrQueueFull, len(q.queue))
+}
+
+// admitLocked asks Admit whether tasks may be queued, given the jobs waiting
+// or running other than replaced, e.g. a task being edited, which tasks would
+// take the place of. The caller must hold the mutex.
+func (q *TaskQueue) admitLocked(tasks []Task, replaced Task) error {
+	if q.Admit == nil {
+		return nil
+	}
+	queued := slices.DeleteFunc(q.jobsLocked(), func(info TaskInfo) bool { return replaced != nil && info.Task == replaced })
+	return q.Admit(tasks, queued)
+}
+
+// checkOwnerLocked returns an error wrapping ErrOwnerBusy if task's owner
+// already has as many jobs waiting as they may. The caller must hold the mutex.
+func (q *TaskQueue) checkOwnerLocked(task Task) error {
+	owner := q.ownerLocked(task)
+	if owner == "" {
+		return nil
+	}
+	limit := q.MaxOwnerWaiting
+	if q.MaxWaitingFor != nil {
+		if ownLimit, ok := q.MaxWaitingFor(owner); ok {
+			limit = ownLimit
+		}
+	}
+	if limit <= 0 {
+		return nil
+	}
+	waiting := map[string]bool{}
+	for _, queued := range q.queue {
+		if q.ownerLocked(queued.task) == owner {
+			waiting[queued.batch] = true
+		}
+	}
+	if len(waiting) < limit {
+		return nil
+	}
+	slog.With("trace", task.TraceID()).Warn("turning away a job from ", owner, ", who has ", len(waiting), " waiting")
+	return fmt.Errorf("%w: you have %d waiting, and may have %d at once", ErrOwnerBusy, len(waiting), limit)
+}
+
+// ownerLocked returns who a task counts against for the owner limits, or ""
+// if it isn't limited. The caller must hold the mutex.
+func (q *TaskQueue) ownerLocked(task Task) string {
+	if q.Owner == nil || (q.Uncapped != nil && q.Uncapped(task)) {
+		return ""
+	}
+	return q.Owner(task)
+}
+
+// unestimatedCost is what a task counts for in placeLocked when its runtime
+// can't be estimated.
+const unestimatedCost = time.Minute
+
+// placeLocked decides where tasks enqueued together go, by start-time fair
+// queueing: each owner's jobs are tagged to finish their estimated runtime
+// after their previous job's tag, or after the latest task to start if they
+// have none waiting, and the queue is kept in order of those tags. An owner
+// who has queued a lot, or queued long jobs, waits behind the others' shorter
+// jobs, while an owner with nothing waiting is soon served. Tasks that have
+// no owner go to the back. It returns the index to insert the tasks at and
+// their tag. The caller must hold the mutex.
+func (q *TaskQueue) placeLocked(tasks []Task) (int, time.Duration) {
+	last := q.virtual
+	if len(q.queue) > 0 {
+		last = max(last, q.queue[len(q.queue)-1].finish)
+	}
+	if len(tasks) == 0 {
+		return len(q.queue), last
+	}
+	owner := q.ownerLocked(tasks[0])
+	if owner == "" {
+		return len(q.queue), last
+	}
+
+	var cost time.Duration
+	for _, task := range tasks {
+		cost += q.cost(task)
+	}
+	if q.lastFinish == nil {
+		q.lastFinish = map[string]time.Duration{}
+	}
+	finish := max(q.virtual, q.lastFinish[owner]) + cost
+	q.lastFinish[owner] = finish
+
+	// after any tasks with the same tag, so ties keep the order they came in
+	at, _ := slices.BinarySearchFunc(q.queue, finish+1, func(queued queuedTask, target time.Duration) int {
+		return cmp.Compare(queued.finish, target)
+	})
+	return at, finish
+}
+
+// cost is how long a task counts for in placeLocked.
+func (q *TaskQueue) cost(task Task) time.Duration {
+	if estimate, ok := q.estimate(task); ok {
+		return estimate
+	}
+	return unestimatedCost
+}
+
+// duplicateLocked reports whether a job triggered by the same message as task
+// is already waiting or running, e.g. because Discord delivered the message
+// twice, and if so how many tasks are ahead of it. The caller must hold the mutex.
+func (q *TaskQueue) duplicateLocked(task Task) (ahead int, ok bool) {
+	triggered, isTriggered := task.(Triggered)
+	if !isTriggered || triggered.MessageID() == "" {
+		return 0, false
+	}
+	if current, isTriggered := q.current.(Triggered); isTriggered && current.MessageID() == triggered.MessageID() {
+		ahead = 0
+	} else if i := q.indexOf(triggered.MessageID()); i >= 0 {
+		ahead = i
+		if q.current != nil {
+			ahead++
+		}
+	} else {
+		return 0, false
+	}
+	slog.With("trace", task.TraceID()).Warn("ignoring duplicate job for message ", triggered.MessageID())
+	return ahead, true
+}
+
+// startLocked starts the run loop if there's work and nothing holding it back.
+// The caller must hold the mutex.
+func (q *TaskQueue) startLocked() {
+	if !q.running && !q.paused && len(q.queue) > 0 {
 		q.running = true
 		go q.runLoop()
 	}
 }
 
+// Pause holds queued tasks without dropping them. A task that's already
+// running is allowed to finish.
+func (q *TaskQueue) Pause() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.paused = true
+}
+
+// Resume starts running held tasks again.
+func (q *TaskQueue) Resume() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.paused = false
+	q.startLocked()
+}
+
+// Status reports whether the queue is paused, whether a task is still running,
+// and how many tasks are waiting.
+func (q *TaskQueue) Status() (paused bool, busy bool, waiting int) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.paused, q.current != nil, len(q.queue)
+}
+
+// Snapshot returns the running task, if any, and the waiting tasks in order.
+func (q *TaskQueue) Snapshot() (current Task, waiting []Task) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	waiting = make([]Task, len(q.queue))
+	for i, queued := range q.queue {
+		waiting[i] = queued.task
+	}
+	return q.current, waiting
+}
+
+// EstimateWait predicts how long until a task with `ahead` tasks in front of it
+// starts: the remainder of the running task plus the queued tasks before it.
+// ok is false if any of those tasks can't be estimated.
+func (q *TaskQueue) EstimateWait(ahead int) (wait time.Duration, ok bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if ahead == 0 {
+		return 0, true
+	}
+
+	tasks := []Task{}
+	if q.current != nil {
+		tasks = append(tasks, q.current)
+	}
+	for _, queued := range q.queue {
+		tasks = append(tasks, queued.task)
+	}
+	if ahead > len(tasks) {
+		ahead = len(tasks)
+	}
+
+	for i, task := range tasks[:ahead] {
+		estimate, ok := q.estimate(task)
+		if !ok {
+			return 0, false
+		}
+		if i == 0 && task == q.current {
+			estimate = max(estimate-time.Since(q.currentStart), 0)
+		}
+		wait += estimate
+	}
+	return wait, true
+}
+
+// Estimate predicts how long a task will take to run.
+func (q *TaskQueue) Estimate(task Task) (time.Duration, bool) {
+	return q.estimate(task)
+}
+
+func (q *TaskQueue) estimate(task Task) (time.Duration, bool) {
+	estimable, ok := task.(Estimable)
+	if !ok {
+		return 0, false
+	}
+	shape, ok := estimable.Shape()
+	if !ok {
+		return 0, false
+	}
+	return q.Estimator.Estimate(shape)
+}
+
+// Edit passes new message content to the waiting task triggered by messageID.
+// found is false if no such task is waiting, e.g. because it has already
+// started. If Admit refuses the task as edited, the edit is undone and its
+// error is returned. An edit that changes how long the task is expected to
+// take moves its owner's turns by as much.
+func (q *TaskQueue) Edit(messageID string, content string) (found bool, err error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	i := q.indexOf(messageID)
+	if i < 0 {
+		return false, nil
+	}
+	queued := q.queue[i]
+	editable, ok := queued.task.(Editable)
+	if !ok {
+		return true, fmt.Errorf("this job can't be edited")
+	}
+	previous, before := editable.Content(), q.cost(queued.task)
+	if err := editable.Edit(content); err != nil {
+		return true, err
+	}
+	if err := q.admitLocked([]Task{queued.task}, queued.task); err != nil {
+		if undoErr := editable.Edit(previous); undoErr != nil {
+			slog.With("trace", queued.task.TraceID()).Error("couldn't undo a refused edit: ", undoErr)
+		}
+		return true, err
+	}
+	q.reweighLocked(queued, q.cost(queued.task)-before)
+	return true, nil
+}
+
+// reweighLocked moves the turns of queued, and of its owner's jobs after it,
+// by delta, e.g. after an edit changed how long it's expected to take. The
+// caller must hold the mutex.
+func (q *TaskQueue) reweighLocked(queued queuedTask, delta time.Duration) {
+	owner := q.ownerLocked(queued.task)
+	if owner == "" || delta == 0 {
+		return
+	}
+	for i := range q.queue {
+		if q.queue[i].finish >= queued.finish && q.ownerLocked(q.queue[i].task) == owner {
+			q.queue[i].finish += delta
+		}
+	}
+	if _, ok := q.lastFinish[owner]; ok {
+		q.lastFinish[owner] += delta
+	}
+	slices.SortStableFunc(q.queue, func(a, b queuedTask) int { return cmp.Compare(a.finish, b.finish) })
+}
+
+// Cancel removes the waiting task triggered by messageID. ok is false if no
+// such task is waiting, e.g. because it has already started.
+func (q *TaskQueue) Cancel(messageID string) (task Task, ok bool) {
+	q.mutex.Lock()
+	i := q.indexOf(messageID)
+	if i < 0 {
+		q.mutex.Unlock()
+		return nil, false
+	}
+	return q.removeLocked(i), true
+}
+
+// CancelJob stops a job by its ID: a waiting job is removed from the queue,
+// and a running one is interrupted if it supports that. It reports whether
+// the job was stopped.
+func (q *TaskQueue) CancelJob(id string) bool {
+	id = strings.ToUpper(id)
+	q.mutex.Lock()
+	if i := q.jobIndexLocked(id); i >= 0 {
+		q.removeLocked(i)
+		return true
+	}
+	var running Task
+	if info := q.jobs[id]; info != nil && info.State == StateRunning {
+		running = info.Task
+	}
+	q.mutex.Unlock()
+
+	if interruptible, ok := running.(Interruptible); ok {
+		return interruptible.Interrupt(ErrCancelled, false)
+	}
+	return false
+}
+
+// Swap has two waiting jobs trade places in the queue, by their IDs, e.g.
+// when their owners agree to. The stages of a workflow can't be swapped, since
+// they have to run in order, and neither can the tasks of a group still
+// waiting together, which would split it. The error wraps ErrNotSwappable if the jobs
+// can't be swapped.
+func (q *TaskQueue) Swap(a string, b string) error {
+	a, b = strings.ToUpper(a), strings.ToUpper(b)
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	i, j := q.jobIndexLocked(a), q.jobIndexLocked(b)
+	switch {
+	case a == b:
+		return fmt.Errorf("%w: a job can't swap places with itself", ErrNotSwappable)
+	case i < 0:
+		return fmt.Errorf("%w: job %s isn't waiting", ErrNotSwappable, a)
+	case j < 0:
+		return fmt.Errorf("%w: job %s isn't waiting", ErrNotSwappable, b)
+	case q.queue[i].chain != nil || q.queue[j].chain != nil:
+		return fmt.Errorf("%w: the stages of a workflow have to run in order", ErrNotSwappable)
+	case q.groupedLocked(i) || q.groupedLocked(j):
+		return fmt.Errorf("%w: jobs queued together have to run back to back", ErrNotSwappable)
+	}
+	// the tags stay where they are, so the queue stays in their order
+	q.queue[i].finish, q.queue[j].finish = q.queue[j].finish, q.queue[i].finish
+	q.queue[i], q.queue[j] = q.queue[j], q.queue[i]
+	slog.Info("swapped jobs ", a, " and ", b, ", which were waiting at ", i+1, " and ", j+1)
+	return nil
+}
+
+// groupedLocked reports whether the waiting task at index i was enqueued in a
+// group with other tasks that are still waiting. The caller must hold the mutex.
+func (q *TaskQueue) groupedLocked(i int) bool {
+	for k, queued := range q.queue {
+		if k != i && queued.batch == q.queue[i].batch {
+			return true
+		}
+	}
+	return false
+}
+
+// jobIndexLocked finds the waiting task with a job ID. The caller must hold the mutex.
+func (q *TaskQueue) jobIndexLocked(id string) int {
+	for i, queued := range q.queue {
+		if queued.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeLocked takes the waiting task at index i out of the queue, along with
+// any later stages of its chain, and tells them they were cancelled. The
+// caller must hold the mutex, which is released.
+func (q *TaskQueue) removeLocked(i int) Task {
+	cancelled := q.queue[i]
+	q.queue = append(q.queue[:i], q.queue[i+1:]...)
+	q.finishLocked(cancelled.id, StateCancelled, nil)
+	// the stages after it in a chain can't run without it
+	dropped := q.dropChainLocked(cancelled, fmt.Errorf("stage %d was cancelled", cancelled.stage+1))
+	q.mutex.Unlock()
+
+	notifyCancelled(append([]queuedTask{cancelled}, dropped...))
+	return cancelled.task
+}
+
+// indexOf finds the waiting task triggered by messageID. The caller must hold the mutex.
+func (q *TaskQueue) indexOf(messageID string) int {
+	for i, queued := range q.queue {
+		if triggered, ok := queued.task.(Triggered); ok && triggered.MessageID() == messageID {
+			return i
+		}
+	}
+	return -1
+}
+
 func (q *TaskQueue) runLoop() {
 	for {
 		q.mutex.Lock()
-		if len(q.queue) == 0 {
+		if len(q.queue) == 0 || q.paused {
 			q.running = false
 			q.mutex.Unlock()
 			return
 		}
-		task := q.queue[0]
+		next := q.queue[0]
 		q.queue = q.queue[1:]
+		q.current = next.task
+		q.currentStart = time.Now()
+		q.virtual = max(q.virtual, next.finish)
+		// owners whose jobs have all had their turn start again from q.virtual
+		maps.DeleteFunc(q.lastFinish, func(owner string, finish time.Duration) bool { return finish <= q.virtual })
+		if info := q.jobs[next.id]; info != nil {
+			info.State, info.Started = StateRunning, q.currentStart
+			q.Journal.record(info)
+		}
+		var inputs []string
+		if next.chain != nil && next.stage > 0 {
+			inputs = slices.Clone(next.chain.outputs)
+		}
 		q.mutex.Unlock()
 
-		if err := task.Apply(); err != nil {
-			task.HandleError(err)
+		next.wait.End()
+		if consuming, ok := next.task.(Consuming); ok && next.chain != nil && next.stage > 0 {
+			consuming.SetInputs(inputs)
+		}
+		err := q.run(next.task)
+		var outputs []string
+		if producing, ok := next.task.(Producing); ok && err == nil {
+			outputs = producing.Outputs()
+		}
+
+		q.mutex.Lock()
+		q.current = nil
+		var dropped []queuedTask
+		if interruptible, ok := next.task.(Interruptible); ok && err != nil && interruptible.Retrying() {
+			_, wait := telemetry.Start(next.task.TraceContext(), "queue.wait", telemetry.TraceIDAttr(next.task.TraceID()))
+			q.queue = append([]queuedTask{{id: next.id, task: next.task, wait: wait, chain: next.chain, stage: next.stage, batch: next.batch, finish: q.virtual}}, q.queue...)
+			if info := q.jobs[next.id]; info != nil {
+				info.State = StateWaiting
+				q.Journal.record(info)
+			}
+			slog.With("trace", next.task.TraceID()).Info("requeued interrupted task at the front of the queue")
+		} else if err != nil {
+			q.finishLocked(next.id, StateFailed, err)
+			dropped = q.dropChainLocked(next, fmt.Errorf("stage %d failed: %w", next.stage+1, err))
+		} else {
+			q.finishLocked(next.id, StateDone, nil)
+			if next.chain != nil {
+				next.chain.outputs = outputs
+			}
+		}
+		q.mutex.Unlock()
+		notifyCancelled(dropped)
+	}
+}
+
+func (q *TaskQueue) run(task Task) error {
+	ctx, span := telemetry.Start(task.TraceContext(), "job.run", telemetry.TraceIDAttr(task.TraceID()))
+	task.SetTraceContext(ctx)
+
+	log := slog.With("trace", task.TraceID())
+	log.Info("starting task")
+	start := time.Now()
+	err := task.Apply()
+	took := time.Since(start)
+	telemetry.End(span, err)
+	if interruptible, ok := task.(Interruptible); ok && err != nil && interruptible.Retrying() {
+		log.Warn("task was interrupted and will be retried: ", err)
+		return err
+	}
+	if q.OnFinish != nil {
+		q.OnFinish(task, err)
+	}
+	if err != nil {
+		log.Error("task failed: ", err)
+		task.HandleError(err)
+		return err
+	}
+	log.Info("finished task in ", took.Round(time.Millisecond))
+
+	if estimable, ok := task.(Estimable); ok {
+		if shape, ok := estimable.Shape(); ok {
+			q.Estimator.Record(shape, took)
 		}
 	}
+	return nil
 }
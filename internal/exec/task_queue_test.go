@@ -0,0 +1,400 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTask struct {
+	messageID string
+	content   string
+	started   chan struct{}
+	release   chan struct{}
+}
+
+func newFakeTask(messageID string) *fakeTask {
+	return &fakeTask{messageID: messageID, started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (t *fakeTask) Apply() error {
+	close(t.started)
+	<-t.release
+	return nil
+}
+func (t *fakeTask) HandleError(error)                   {}
+func (t *fakeTask) Prompt() string                      { return t.content }
+func (t *fakeTask) TraceID() string                     { return t.messageID }
+func (t *fakeTask) TraceContext() context.Context       { return context.Background() }
+func (t *fakeTask) SetTraceContext(ctx context.Context) {}
+func (t *fakeTask) MessageID() string                   { return t.messageID }
+
+func (t *fakeTask) Content() string { return t.content }
+
+func (t *fakeTask) Edit(content string) error {
+	if content == "" {
+		return errors.New("empty")
+	}
+	t.content = content
+	return nil
+}
+
+// enqueued fails the test if enqueueing returned an error, and otherwise returns how many tasks were ahead.
+func enqueued(t *testing.T) func(int, error) int {
+	return func(ahead int, err error) int {
+		t.Helper()
+		require.NoError(t, err)
+		return ahead
+	}
+}
+
+func TestTaskQueue_EditAndCancelOnlyAffectWaitingTasks(t *testing.T) {
+	q := NewTaskQueue()
+	running := newFakeTask("running")
+	waiting := newFakeTask("waiting")
+	defer close(waiting.release)
+
+	q.Enqueue(running)
+	<-running.started
+	require.Equal(t, 1, enqueued(t)(q.Enqueue(waiting)))
+
+	found, err := q.Edit("running", "new")
+	require.False(t, found)
+	require.NoError(t, err)
+
+	found, err = q.Edit("waiting", "")
+	require.True(t, found)
+	require.Error(t, err)
+
+	found, err = q.Edit("waiting", "new")
+	require.True(t, found)
+	require.NoError(t, err)
+	require.Equal(t, "new", waiting.content)
+
+	_, ok := q.Cancel("running")
+	require.False(t, ok)
+
+	current, waitingTasks := q.Snapshot()
+	require.Equal(t, running, current)
+	require.Equal(t, []Task{waiting}, waitingTasks)
+
+	cancelled, ok := q.Cancel("waiting")
+	require.True(t, ok)
+	require.Equal(t, waiting, cancelled)
+
+	_, ok = q.Cancel("waiting")
+	require.False(t, ok)
+	close(running.release)
+}
+
+func TestTaskQueue_PauseHoldsQueuedTasksUntilResume(t *testing.T) {
+	q := NewTaskQueue()
+	running := newFakeTask("running")
+	held := newFakeTask("held")
+	defer close(held.release)
+
+	q.Enqueue(running)
+	<-running.started
+	q.Pause()
+	q.Enqueue(held)
+
+	close(running.release)
+	require.Eventually(t, func() bool {
+		_, busy, _ := q.Status()
+		return !busy
+	}, time.Second, 5*time.Millisecond)
+
+	paused, _, waiting := q.Status()
+	require.True(t, paused)
+	require.Equal(t, 1, waiting)
+	select {
+	case <-held.started:
+		t.Fatal("held task started while the queue was paused")
+	default:
+	}
+
+	q.Resume()
+	select {
+	case <-held.started:
+	case <-time.After(time.Second):
+		t.Fatal("held task didn't start after resuming")
+	}
+}
+
+func TestTaskQueue_InfoFollowsJobsThroughTheirStates(t *testing.T) {
+	q := NewTaskQueue()
+	running := newFakeTask("running")
+	waiting := newFakeTask("waiting")
+
+	q.Enqueue(running)
+	<-running.started
+	q.Enqueue(waiting)
+
+	ids := q.JobIDs("waiting")
+	require.Len(t, ids, 1)
+	require.Len(t, ids[0], jobIDLength)
+
+	info, ok := q.Info(strings.ToLower(ids[0]))
+	require.True(t, ok)
+	require.Equal(t, StateWaiting, info.State)
+	require.Equal(t, 1, info.Position)
+	require.Equal(t, waiting, info.Task)
+
+	runningID := q.JobIDs("running")[0]
+	info, _ = q.Info(runningID)
+	require.Equal(t, StateRunning, info.State)
+
+	close(running.release)
+	require.Eventually(t, func() bool {
+		info, _ := q.Info(runningID)
+		return info.State == StateDone
+	}, time.Second, 5*time.Millisecond)
+
+	<-waiting.started
+	close(waiting.release)
+	require.Eventually(t, func() bool {
+		info, _ := q.Info(ids[0])
+		return info.State == StateDone
+	}, time.Second, 5*time.Millisecond)
+	require.Empty(t, q.JobIDs("waiting"))
+
+	_, ok = q.Info("0000")
+	require.False(t, ok)
+}
+
+func TestTaskQueue_IgnoresDuplicateTrigger(t *testing.T) {
+	q := NewTaskQueue()
+	running := newFakeTask("running")
+	waiting := newFakeTask("waiting")
+	defer close(waiting.release)
+
+	q.Enqueue(running)
+	<-running.started
+	require.Equal(t, 1, enqueued(t)(q.Enqueue(waiting)))
+
+	require.Equal(t, 0, enqueued(t)(q.Enqueue(newFakeTask("running"))))
+	require.Equal(t, 1, enqueued(t)(q.Enqueue(newFakeTask("waiting"))))
+	require.Equal(t, 1, enqueued(t)(q.EnqueueGroup([]Task{newFakeTask("waiting"), newFakeTask("waiting")})))
+
+	_, _, queued := q.Status()
+	require.Equal(t, 1, queued)
+	close(running.release)
+}
+
+func TestTaskQueue_MaxDepthTurnsAwayTasks(t *testing.T) {
+	q := NewTaskQueue()
+	q.MaxDepth = 2
+	q.Pause()
+
+	require.Equal(t, 0, enqueued(t)(q.Enqueue(newFakeTask("a"))))
+	_, err := q.EnqueueGroup([]Task{newFakeTask("b"), newFakeTask("b")})
+	require.ErrorIs(t, err, ErrQueueFull)
+
+	require.Equal(t, 1, enqueued(t)(q.Enqueue(newFakeTask("c"))))
+	_, err = q.Enqueue(newFakeTask("d"))
+	require.ErrorIs(t, err, ErrQueueFull)
+
+	_, _, waiting := q.Status()
+	require.Equal(t, 2, waiting)
+}
+
+func TestTaskQueue_EditIsAdmittedLikeANewTask(t *testing.T) {
+	q := NewTaskQueue()
+	refused := errors.New("no credits")
+	var admitted [][]TaskInfo
+	q.Admit = func(tasks []Task, queued []TaskInfo) error {
+		admitted = append(admitted, queued)
+		if tasks[0].Prompt() == "expensive" {
+			return refused
+		}
+		return nil
+	}
+	q.Pause()
+	task := newFakeTask("a")
+	task.content = "cheap"
+	enqueued(t)(q.Enqueue(task))
+
+	found, err := q.Edit("a", "expensive")
+	require.True(t, found)
+	require.ErrorIs(t, err, refused)
+	require.Equal(t, "cheap", task.content)
+	// the job being edited isn't counted twice
+	require.Empty(t, admitted[len(admitted)-1])
+
+	found, err = q.Edit("a", "also cheap")
+	require.True(t, found)
+	require.NoError(t, err)
+	require.Equal(t, "also cheap", task.content)
+}
+
+func TestTaskQueue_MaxOwnerWaitingTurnsAwayTasks(t *testing.T) {
+	q := NewTaskQueue()
+	// a task's owner is the first letter of its message ID
+	q.Owner = func(task Task) string { return task.(*fakeTask).messageID[:1] }
+	q.Uncapped = func(task Task) bool { return q.Owner(task) == "z" }
+	q.MaxOwnerWaiting = 2
+	q.Pause()
+
+	enqueued(t)(q.Enqueue(newFakeTask("a1")))
+	// a group counts as one job
+	enqueued(t)(q.EnqueueGroup([]Task{newFakeTask("a2"), newFakeTask("a2")}))
+	_, err := q.Enqueue(newFakeTask("a3"))
+	require.ErrorIs(t, err, ErrOwnerBusy)
+	_, err = q.EnqueueChain([]Task{newFakeTask("a4"), newFakeTask("a4")})
+	require.ErrorIs(t, err, ErrOwnerBusy)
+
+	// other owners, and uncapped ones, aren't held back by it
+	enqueued(t)(q.Enqueue(newFakeTask("b1")))
+	for _, id := range []string{"z1", "z2", "z3"} {
+		enqueued(t)(q.Enqueue(newFakeTask(id)))
+	}
+
+	// once one of theirs is gone, there's room again
+	_, ok := q.Cancel("a1")
+	require.True(t, ok)
+	enqueued(t)(q.Enqueue(newFakeTask("a3")))
+}
+
+func TestTaskQueue_MaxWaitingForOverridesTheLimit(t *testing.T) {
+	q := NewTaskQueue()
+	q.Owner = func(task Task) string { return task.(*fakeTask).messageID[:1] }
+	q.MaxOwnerWaiting = 1
+	q.MaxWaitingFor = func(owner string) (int, bool) { return 2, owner == "w" }
+	q.Pause()
+
+	enqueued(t)(q.Enqueue(newFakeTask("a1")))
+	_, err := q.Enqueue(newFakeTask("a2"))
+	require.ErrorIs(t, err, ErrOwnerBusy)
+
+	enqueued(t)(q.Enqueue(newFakeTask("w1")))
+	enqueued(t)(q.Enqueue(newFakeTask("w2")))
+	_, err = q.Enqueue(newFakeTask("w3"))
+	require.ErrorIs(t, err, ErrOwnerBusy)
+}
+
+func TestTaskQueue_OwnersTakeTurns(t *testing.T) {
+	q := NewTaskQueue()
+	// a task's owner is the first letter of its message ID
+	q.Owner = func(task Task) string { return task.(*fakeTask).messageID[:1] }
+	q.Uncapped = func(task Task) bool { return q.Owner(task) == "z" }
+	q.Pause()
+
+	for _, id := range []string{"a1", "a2", "a3"} {
+		enqueued(t)(q.Enqueue(newFakeTask(id)))
+	}
+	// b has nothing waiting, so it goes after a's first job rather than all three
+	require.Equal(t, 1, enqueued(t)(q.Enqueue(newFakeTask("b1"))))
+	// a group is weighed by all of its tasks
+	require.Equal(t, 3, enqueued(t)(q.EnqueueGroup([]Task{newFakeTask("c1"), newFakeTask("c2")})))
+	// uncapped tasks go to the back
+	require.Equal(t, 6, enqueued(t)(q.Enqueue(newFakeTask("z1"))))
+
+	_, waiting := q.Snapshot()
+	var order []string
+	for _, task := range waiting {
+		order = append(order, task.(*fakeTask).messageID)
+	}
+	require.Equal(t, []string{"a1", "b1", "a2", "c1", "c2", "a3", "z1"}, order)
+
+	// swapping keeps the queue in turn order for later jobs
+	require.NoError(t, q.Swap(q.Jobs()[0].ID, q.Jobs()[5].ID))
+	require.Equal(t, 2, enqueued(t)(q.Enqueue(newFakeTask("d1"))))
+}
+
+func TestTaskQueue_JobsAndCancelJob(t *testing.T) {
+	q := NewTaskQueue()
+	running := newFakeTask("running")
+	defer close(running.release)
+
+	q.Enqueue(running)
+	<-running.started
+	enqueued(t)(q.Enqueue(newFakeTask("a")))
+	enqueued(t)(q.Enqueue(newFakeTask("b")))
+
+	jobs := q.Jobs()
+	require.Len(t, jobs, 3)
+	require.Equal(t, StateRunning, jobs[0].State)
+	require.Equal(t, []int{1, 2}, []int{jobs[1].Position, jobs[2].Position})
+
+	// fakeTask can't be interrupted, so only the waiting job can be cancelled
+	require.False(t, q.CancelJob(jobs[0].ID))
+	require.True(t, q.CancelJob(strings.ToLower(jobs[1].ID)))
+	require.False(t, q.CancelJob(jobs[1].ID))
+
+	info, _ := q.Info(jobs[1].ID)
+	require.Equal(t, StateCancelled, info.State)
+	require.Len(t, q.Jobs(), 2)
+}
+
+func TestTaskQueue_SwapTradesWaitingJobsPlaces(t *testing.T) {
+	q := NewTaskQueue()
+	running := newFakeTask("running")
+	defer close(running.release)
+
+	q.Enqueue(running)
+	<-running.started
+	enqueued(t)(q.Enqueue(newFakeTask("a")))
+	enqueued(t)(q.Enqueue(newFakeTask("b")))
+	enqueued(t)(q.EnqueueChain([]Task{newFakeTask("stage1"), newFakeTask("stage2")}))
+	enqueued(t)(q.EnqueueGroup([]Task{newFakeTask("take1"), newFakeTask("take2")}))
+	jobs := q.Jobs()
+	runningID, a, b, stage, take := jobs[0].ID, jobs[1].ID, jobs[2].ID, jobs[3].ID, jobs[5].ID
+
+	require.NoError(t, q.Swap(strings.ToLower(a), b))
+	infoA, _ := q.Info(a)
+	infoB, _ := q.Info(b)
+	require.Equal(t, []int{2, 1}, []int{infoA.Position, infoB.Position})
+
+	for _, pair := range [][2]string{{a, a}, {a, runningID}, {a, stage}, {take, a}, {a, "NOPE"}} {
+		require.ErrorIs(t, q.Swap(pair[0], pair[1]), ErrNotSwappable)
+	}
+}
+
+func TestTaskQueue_History(t *testing.T) {
+	q := NewTaskQueue()
+	first := newFakeTask("first")
+
+	q.Enqueue(first)
+	<-first.started
+	enqueued(t)(q.Enqueue(newFakeTask("second")))
+	jobs := q.Jobs()
+	require.Empty(t, q.History())
+
+	require.True(t, q.CancelJob(jobs[1].ID))
+	close(first.release)
+	require.Eventually(t, func() bool { return len(q.History()) == 2 }, time.Second, time.Millisecond)
+
+	history := q.History()
+	require.Equal(t, []string{jobs[0].ID, jobs[1].ID}, []string{history[0].ID, history[1].ID})
+	require.Equal(t, StateDone, history[0].State)
+	require.Equal(t, StateCancelled, history[1].State)
+	require.False(t, q.Forget(strings.ToLower("ZZZZ")))
+	require.True(t, q.Forget(strings.ToLower(jobs[1].ID)))
+	require.Len(t, q.History(), 1)
+	_, ok := q.Info(jobs[1].ID)
+	require.False(t, ok)
+}
+
+func TestTaskQueue_AdmitCanRefuseTasks(t *testing.T) {
+	q := NewTaskQueue()
+	refused := errors.New("no credits")
+	q.Admit = func(tasks []Task, queued []TaskInfo) error {
+		if len(tasks) > 1 {
+			return refused
+		}
+		return nil
+	}
+
+	_, err := q.EnqueueGroup([]Task{newFakeTask("a"), newFakeTask("b")})
+	require.ErrorIs(t, err, refused)
+	require.Empty(t, q.Jobs())
+
+	task := newFakeTask("c")
+	defer close(task.release)
+	_, err = q.Enqueue(task)
+	require.NoError(t, err)
+}
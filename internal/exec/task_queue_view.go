@@ -1,9 +1,19 @@
 package exec
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/format"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/store"
 )
 
 const MAX_JOBS_IN_VIEW = 5
@@ -14,53 +24,220 @@ const (
 	promptCellWidth = promptMaxLen + 3 // total chars per row including '...'
 )
 
+// viewBucket maps each channel showing the queue view to its message there.
+const viewBucket = "queue_view"
+
+// emptyQueueBody is what a pinned view shows while nothing is queued.
+const emptyQueueBody = "The queue is empty."
+
+// viewMessage is the queue view's message in one channel.
+type viewMessage struct {
+	MessageID string `json:"message_id"` // empty until it's been sent
+	body      string // what it last showed
+}
+
+// TaskQueueView mirrors the queue into a message in each of its channels.
+// Every message is rendered from the same body on each Refresh. Channels and
+// their messages are kept in Store, when there is one, so a restart edits the
+// same messages instead of posting new ones.
 type TaskQueueView struct {
-	Queue     *TaskQueue
-	Session   *discordgo.Session
-	ChannelID string
-	MessageID string
+	Queue   *TaskQueue
+	Session *discordgo.Session
+	Store   *store.Store // optional
+	Pin     bool         // pin each message and edit it in place, instead of reposting it at the bottom of the channel
+
+	mutex      sync.Mutex // guards channels and jobs
+	channels   map[string]*viewMessage
+	jobs       string     // the queue's jobs when Hurry last looked
+	refreshing sync.Mutex // held while messages are being updated, so it doesn't block AddChannel
+}
+
+func NewTaskQueueView(q *TaskQueue, sess *discordgo.Session, s *store.Store, pin bool) *TaskQueueView {
+	return &TaskQueueView{Queue: q, Session: sess, Store: s, Pin: pin, channels: map[string]*viewMessage{}}
+}
+
+// Load restores the channels and messages saved by an earlier run.
+func (v *TaskQueueView) Load() error {
+	if v.Store == nil {
+		return nil
+	}
+	keys, err := v.Store.Keys(viewBucket)
+	if err != nil {
+		return err
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	for _, channelID := range keys {
+		var saved viewMessage
+		if err := v.Store.Get(viewBucket, channelID, &saved); err != nil {
+			return fmt.Errorf("couldn't load queue view for channel %s: %w", channelID, err)
+		}
+		v.channels[channelID] = &saved
+	}
+	return nil
+}
+
+// AddChannel starts mirroring the view into a channel. Adding a channel twice does nothing.
+func (v *TaskQueueView) AddChannel(channelID string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if channelID == "" || v.channels[channelID] != nil {
+		return
+	}
+	v.channels[channelID] = &viewMessage{}
+	v.save(channelID, v.channels[channelID])
 }
 
-func NewTaskQueueView(q *TaskQueue, sess *discordgo.Session, channelID string) *TaskQueueView {
-	return &TaskQueueView{Queue: q, Session: sess, ChannelID: channelID}
+// RemoveChannel stops mirroring the view into a channel and deletes its message there.
+func (v *TaskQueueView) RemoveChannel(channelID string) {
+	v.refreshing.Lock()
+	defer v.refreshing.Unlock()
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	message := v.channels[channelID]
+	if message == nil {
+		return
+	}
+	if message.MessageID != "" && v.Session != nil {
+		_ = v.Session.ChannelMessageDelete(channelID, message.MessageID)
+	}
+	delete(v.channels, channelID)
+	if v.Store != nil {
+		if err := v.Store.Delete(viewBucket, channelID); err != nil {
+			slog.Warn("couldn't forget queue view for channel ", channelID, ": ", err)
+		}
+	}
 }
 
+// Channels returns the IDs of the channels the view is mirrored into, sorted.
+func (v *TaskQueueView) Channels() []string {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	ids := make([]string, 0, len(v.channels))
+	for id := range v.channels {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Refresh renders the queue once and brings every channel's message up to date.
 func (v *TaskQueueView) Refresh() error {
-	// body := v.renderBody()
-
-	// // if body is empty, then queue is empty, so just clean up and return
-	// if body == "" {
-	// 	if v.MessageID != "" {
-	// 		_ = v.Session.ChannelMessageDelete(v.ChannelID, v.MessageID)
-	// 		v.MessageID = ""
-	// 	}
-	// 	return nil
-	// }
-
-	// // fetch the most recent message in the channel
-	// msgs, err := v.Session.ChannelMessages(v.ChannelID, 1, "", "", "")
-	// if err != nil {
-	// 	return fmt.Errorf("failed to fetch messages: %w", err)
-	// }
-
-	// // if the stored message is still the most recent one, then edit it
-	// if len(msgs) > 0 && msgs[0].ID == v.MessageID {
-	// 	_, err = v.Session.ChannelMessageEdit(v.ChannelID, v.MessageID, body)
-	// 	return err
-	// }
-
-	// // otherwise, delete the old message and send a new one
-	// if v.MessageID != "" {
-	// 	_ = v.Session.ChannelMessageDelete(v.ChannelID, v.MessageID)
-	// }
-	// msg, err := v.Session.ChannelMessageSend(v.ChannelID, body)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to send new queue view message: %w", err)
-	// }
-	// v.MessageID = msg.ID
+	v.refreshing.Lock()
+	defer v.refreshing.Unlock()
+
+	body := v.renderBody()
+	v.mutex.Lock()
+	channels := make(map[string]*viewMessage, len(v.channels))
+	for channelID, message := range v.channels {
+		channels[channelID] = message
+	}
+	v.mutex.Unlock()
+
+	var errs []error
+	for channelID, message := range channels {
+		if err := v.refreshChannel(channelID, message, body); err != nil {
+			errs = append(errs, fmt.Errorf("channel %s: %w", channelID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (v *TaskQueueView) refreshChannel(channelID string, message *viewMessage, body string) error {
+	if v.Pin {
+		// a pinned message is easy to find, so it stays put and says when the queue is empty
+		if body == "" {
+			body = emptyQueueBody
+		}
+		if message.MessageID != "" {
+			if message.body == body {
+				return nil
+			}
+			_, err := v.Session.ChannelMessageEdit(channelID, message.MessageID, body)
+			if err == nil {
+				message.body = body
+				return nil
+			}
+			if !isUnknownMessage(err) {
+				return err
+			}
+		}
+		return v.send(channelID, message, body)
+	}
+
+	// if body is empty, then queue is empty, so just clean up and return
+	if body == "" {
+		if message.MessageID != "" {
+			_ = v.Session.ChannelMessageDelete(channelID, message.MessageID)
+			message.MessageID, message.body = "", ""
+			v.save(channelID, message)
+		}
+		return nil
+	}
+
+	// fetch the most recent message in the channel
+	msgs, err := v.Session.ChannelMessages(channelID, 1, "", "", "")
+	if err != nil {
+		return fmt.Errorf("failed to fetch messages: %w", err)
+	}
+
+	// if the stored message is still the most recent one, then edit it
+	if message.MessageID != "" && len(msgs) > 0 && msgs[0].ID == message.MessageID {
+		if message.body == body {
+			return nil
+		}
+		if _, err := v.Session.ChannelMessageEdit(channelID, message.MessageID, body); err != nil {
+			return err
+		}
+		message.body = body
+		return nil
+	}
+
+	// otherwise, delete the old message and send a new one
+	if message.MessageID != "" {
+		_ = v.Session.ChannelMessageDelete(channelID, message.MessageID)
+	}
+	return v.send(channelID, message, body)
+}
+
+// send posts a new view message in a channel, pinning it if the view is pinned.
+func (v *TaskQueueView) send(channelID string, message *viewMessage, body string) error {
+	msg, err := v.Session.ChannelMessageSend(channelID, body)
+	if err != nil {
+		return fmt.Errorf("failed to send new queue view message: %w", err)
+	}
+	message.MessageID, message.body = msg.ID, body
+	v.save(channelID, message)
+	if v.Pin {
+		if err := v.Session.ChannelMessagePin(channelID, msg.ID); err != nil {
+			return fmt.Errorf("failed to pin queue view message: %w", err)
+		}
+	}
 	return nil
 }
 
+func (v *TaskQueueView) save(channelID string, message *viewMessage) {
+	if v.Store == nil {
+		return
+	}
+	if err := v.Store.Put(viewBucket, channelID, message); err != nil {
+		slog.Warn("couldn't save queue view for channel ", channelID, ": ", err)
+	}
+}
+
+// isUnknownMessage reports whether Discord said a message doesn't exist, e.g.
+// because someone deleted it.
+func isUnknownMessage(err error) bool {
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) {
+		return false
+	}
+	return (restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeUnknownMessage) ||
+		(restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound)
+}
+
 func formatCell(s string) string {
 	rs := []rune(s)
 	if len(rs) > promptCellWidth {
@@ -70,38 +247,80 @@ func formatCell(s string) string {
 	return s + strings.Repeat(" ", pad)
 }
 
+// runningLine describes the running job with its live progress, e.g.
+// "37.0% (37/100 steps) · 12s elapsed · ~20s left", or with how long it
+// usually has left until it reports any.
+func (v *TaskQueueView) runningLine(info TaskInfo) string {
+	line := "Now generating: `" + strings.ReplaceAll(info.Task.Prompt(), "`", "'") + "`"
+	if progressing, ok := info.Task.(Progressing); ok && progressing.Progress() != "" {
+		return line + " · " + strings.Join(strings.Fields(progressing.Progress()), " ")
+	}
+	if estimate, ok := v.Queue.Estimate(info.Task); ok {
+		return line + " · ~" + format.Duration(max(estimate-time.Since(info.Started), 0)) + " left"
+	}
+	return line
+}
+
+// Hurry reports whether the view should be refreshed soon: jobs came or went
+// since it was last asked, or the running job is nearly done. Otherwise, e.g.
+// while a long job is only moving along, refreshes can wait.
+func (v *TaskQueueView) Hurry() bool {
+	var ids []string
+	nearlyDone := false
+	for _, info := range v.Queue.Jobs() {
+		ids = append(ids, info.ID+":"+string(info.State))
+		if info.State == StateRunning {
+			estimate, ok := v.Queue.Estimate(info.Task)
+			nearlyDone = ok && estimate-time.Since(info.Started) <= estimate/10
+		}
+	}
+	jobs := strings.Join(ids, ",")
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	changed := jobs != v.jobs
+	v.jobs = jobs
+	return changed || nearlyDone
+}
+
+// renderBody draws the running job and the first waiting ones as a table, or
+// returns "" if nothing is queued.
 func (v *TaskQueueView) renderBody() string {
-	v.Queue.mutex.Lock()
-	defer v.Queue.mutex.Unlock()
-
-	// jobs := v.Queue.queue
-	// numJobs := len(jobs)
-	// var lines []string
-
-	// lines = append(lines,
-	// 	"```",
-	// 	fmt.Sprintf("╔═══╤═%s═╗", strings.Repeat("═", promptCellWidth)),
-	// 	fmt.Sprintf("║ # │ %s ║", formatCell("Prompt")),
-	// 	fmt.Sprintf("╟───┼─%s─╢", strings.Repeat("─", promptCellWidth)),
-	// )
-
-	// for i := 0; i < maxRows && i < numJobs; i++ {
-	// 	prompt := formatCell(jobs[i].Prompt())
-	// 	lines = append(lines, fmt.Sprintf("║ %d │ %s ║", i+1, prompt))
-	// }
-
-	// if numJobs > maxRows {
-	// 	missing := fmt.Sprintf("... and %d more ...", numJobs-maxRows)
-	// 	lines = append(lines, fmt.Sprintf("║   │ %s ║", formatCell(missing)))
-	// } else {
-	// 	lines = append(lines, fmt.Sprintf("║   │ %s ║", strings.Repeat(" ", promptCellWidth)))
-	// }
-
-	// lines = append(lines,
-	// 	fmt.Sprintf("╚═══╧═%s═╝", strings.Repeat("═", promptCellWidth)),
-	// 	"```",
-	// )
-
-	// return strings.Join(lines, "\n")
-	return ""
+	current, jobs := v.Queue.Snapshot()
+	if current == nil && len(jobs) == 0 {
+		return ""
+	}
+	numJobs := len(jobs)
+	var lines []string
+
+	if running, ok := v.Queue.Running(); ok {
+		lines = append(lines, v.runningLine(running))
+	} else if current != nil {
+		lines = append(lines, "Now generating: `"+strings.ReplaceAll(current.Prompt(), "`", "'")+"`")
+	}
+	lines = append(lines,
+		"```",
+		fmt.Sprintf("╔═══╤═%s═╗", strings.Repeat("═", promptCellWidth)),
+		fmt.Sprintf("║ # │ %s ║", formatCell("Prompt")),
+		fmt.Sprintf("╟───┼─%s─╢", strings.Repeat("─", promptCellWidth)),
+	)
+
+	for i := 0; i < maxRows && i < numJobs; i++ {
+		prompt := formatCell(jobs[i].Prompt())
+		lines = append(lines, fmt.Sprintf("║ %d │ %s ║", i+1, prompt))
+	}
+
+	if numJobs > maxRows {
+		missing := fmt.Sprintf("... and %d more ...", numJobs-maxRows)
+		lines = append(lines, fmt.Sprintf("║   │ %s ║", formatCell(missing)))
+	} else {
+		lines = append(lines, fmt.Sprintf("║   │ %s ║", strings.Repeat(" ", promptCellWidth)))
+	}
+
+	lines = append(lines,
+		fmt.Sprintf("╚═══╧═%s═╝", strings.Repeat("═", promptCellWidth)),
+		"```",
+	)
+
+	return strings.Join(lines, "\n")
 }
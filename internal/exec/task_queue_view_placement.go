@@ -0,0 +1,297 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/io/slog"
+	"slugbot/internal/store"
+)
+
+// Where a queue view can be placed.
+const (
+	PlaceChannels = "channels" // in each configured channel, and optionally every channel a job is started from
+	PlaceBusiest  = "busiest"  // in the one channel that started the most jobs recently
+	PlaceThread   = "thread"   // in a thread under a pinned message in each configured channel
+)
+
+// placementBucket keeps where the view was placed, so a restart under a
+// different placement can move it.
+const (
+	placementBucket = "queue_view_placement"
+	placementKey    = "state"
+)
+
+// threadArchiveMinutes is how long a view thread may go without messages
+// before Discord archives it: a week, the most it allows.
+const threadArchiveMinutes = 7 * 24 * 60
+
+// threadReopenInterval is how often the view threads are checked for having
+// been archived anyway, as jobs start.
+const threadReopenInterval = 24 * time.Hour
+
+type placementState struct {
+	Placement string                `json:"placement"`
+	Busiest   string                `json:"busiest,omitempty"`
+	Threads   map[string]viewThread `json:"threads,omitempty"` // by parent channel ID
+}
+
+// viewThread is a thread the view is shown in, and the pinned message it hangs off.
+type viewThread struct {
+	AnchorID string `json:"anchor_id"`
+	ThreadID string `json:"thread_id"`
+}
+
+// ViewPlacement decides which channels a queue view is shown in, and moves it
+// when the placement changes between runs.
+type ViewPlacement struct {
+	View        *TaskQueueView
+	Placement   string        // one of the Place constants; empty is PlaceChannels
+	Channels    []string      // the view's channels, or with PlaceThread, the channels its threads are in
+	JobChannels bool          // with PlaceChannels, also show the view in every channel a job is started from
+	Window      time.Duration // with PlaceBusiest, how far back jobs are counted
+
+	mutex    sync.Mutex
+	state    placementState
+	started  map[string][]time.Time // by channel ID: when its recent jobs were started
+	reopened time.Time
+}
+
+// Place moves the view to where its placement puts it, taking it down from
+// wherever an earlier run under another placement left it. Call it after the
+// view is loaded.
+func (p *ViewPlacement) Place() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.load()
+	placement := p.placement()
+
+	var errs []error
+	wanted := map[string]bool{}
+	switch placement {
+	case PlaceBusiest:
+		// the view stays where it was until another channel gets busier
+		if p.state.Placement != PlaceBusiest {
+			p.state.Busiest = ""
+		}
+		if p.state.Busiest != "" {
+			wanted[p.state.Busiest] = true
+		}
+	case PlaceThread:
+		for _, parentID := range p.Channels {
+			thread, err := p.thread(parentID)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("channel %s: %w", parentID, err))
+				continue
+			}
+			wanted[thread.ThreadID] = true
+		}
+	default:
+		for _, channelID := range p.Channels {
+			wanted[channelID] = true
+		}
+	}
+
+	for _, channelID := range p.View.Channels() {
+		// channels only remembered from jobs stay, as long as jobs still add them
+		keep := placement == PlaceChannels && p.JobChannels && !p.isThread(channelID)
+		if !wanted[channelID] && !keep {
+			p.View.RemoveChannel(channelID)
+		}
+	}
+	for parentID, thread := range p.state.Threads {
+		if placement != PlaceThread || !slices.Contains(p.Channels, parentID) {
+			p.dropThread(parentID, thread)
+		}
+	}
+	for channelID := range wanted {
+		p.View.AddChannel(channelID)
+	}
+
+	if p.state.Placement != "" && p.state.Placement != placement {
+		slog.Info("moved the queue view from ", p.state.Placement, " placement to ", placement)
+	}
+	p.state.Placement = placement
+	p.save()
+	return errors.Join(errs...)
+}
+
+// JobStarted tells the placement a job was started from a channel.
+func (p *ViewPlacement) JobStarted(channelID string) {
+	if p == nil || channelID == "" {
+		return
+	}
+	switch p.placement() {
+	case PlaceBusiest:
+		p.follow(channelID, time.Now())
+	case PlaceThread:
+		p.reopenThreads()
+	default:
+		if p.JobChannels {
+			p.View.AddChannel(channelID)
+		}
+	}
+}
+
+func (p *ViewPlacement) placement() string {
+	if p.Placement == "" {
+		return PlaceChannels
+	}
+	return p.Placement
+}
+
+// follow counts a job started at now, and moves the view to the busiest
+// channel if that's changed. Ties keep the view where it is.
+func (p *ViewPlacement) follow(channelID string, now time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.started == nil {
+		p.started = map[string][]time.Time{}
+	}
+	p.started[channelID] = append(p.started[channelID], now)
+
+	for id, times := range p.started {
+		recent := slices.DeleteFunc(times, func(started time.Time) bool { return now.Sub(started) > p.Window })
+		if len(recent) == 0 {
+			delete(p.started, id)
+		} else {
+			p.started[id] = recent
+		}
+	}
+	busiest := p.state.Busiest
+	most := len(p.started[busiest])
+	for id, times := range p.started {
+		if len(times) > most {
+			busiest, most = id, len(times)
+		}
+	}
+	if busiest == p.state.Busiest {
+		return
+	}
+
+	if p.state.Busiest != "" {
+		p.View.RemoveChannel(p.state.Busiest)
+	}
+	p.View.AddChannel(busiest)
+	p.state.Busiest = busiest
+	p.save()
+	slog.Info("moved the queue view to channel ", busiest, ", which started the most jobs recently")
+}
+
+// thread returns the view's thread in a channel, starting one under a new
+// pinned message if it doesn't have one yet, or its thread was deleted.
+func (p *ViewPlacement) thread(parentID string) (viewThread, error) {
+	session := p.View.Session
+	if thread, ok := p.state.Threads[parentID]; ok {
+		channel, err := session.Channel(thread.ThreadID)
+		if err == nil {
+			if channel.ThreadMetadata != nil && channel.ThreadMetadata.Archived {
+				p.reopen(thread)
+			}
+			return thread, nil
+		}
+		if !isNotFound(err) {
+			// Discord may just be unavailable; the thread is likely still there
+			slog.Warn("couldn't check queue view thread ", thread.ThreadID, ": ", err)
+			return thread, nil
+		}
+		p.dropThread(parentID, thread)
+	}
+
+	anchor, err := session.ChannelMessageSend(parentID, "📊 **Queue** · live in the thread below")
+	if err != nil {
+		return viewThread{}, fmt.Errorf("couldn't post the queue view's message: %w", err)
+	}
+	if err := session.ChannelMessagePin(parentID, anchor.ID); err != nil {
+		slog.Warn("couldn't pin the queue view's message in channel ", parentID, ": ", err)
+	}
+	channel, err := session.MessageThreadStart(parentID, anchor.ID, "Queue", threadArchiveMinutes)
+	if err != nil {
+		_ = session.ChannelMessageDelete(parentID, anchor.ID)
+		return viewThread{}, fmt.Errorf("couldn't start the queue view's thread: %w", err)
+	}
+	thread := viewThread{AnchorID: anchor.ID, ThreadID: channel.ID}
+	if p.state.Threads == nil {
+		p.state.Threads = map[string]viewThread{}
+	}
+	p.state.Threads[parentID] = thread
+	p.save()
+	return thread, nil
+}
+
+// dropThread takes the view down from a thread, and deletes the thread and its message.
+func (p *ViewPlacement) dropThread(parentID string, thread viewThread) {
+	p.View.RemoveChannel(thread.ThreadID)
+	if session := p.View.Session; session != nil {
+		if _, err := session.ChannelDelete(thread.ThreadID); err != nil && !isNotFound(err) {
+			slog.Warn("couldn't delete queue view thread ", thread.ThreadID, ": ", err)
+		}
+		if err := session.ChannelMessageDelete(parentID, thread.AnchorID); err != nil && !isNotFound(err) {
+			slog.Warn("couldn't delete the queue view's message in channel ", parentID, ": ", err)
+		}
+	}
+	delete(p.state.Threads, parentID)
+}
+
+// reopenThreads unarchives the view's threads now and then, since the view
+// can't be edited in an archived thread.
+func (p *ViewPlacement) reopenThreads() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if time.Since(p.reopened) < threadReopenInterval {
+		return
+	}
+	p.reopened = time.Now()
+	for _, thread := range p.state.Threads {
+		p.reopen(thread)
+	}
+}
+
+func (p *ViewPlacement) reopen(thread viewThread) {
+	if p.View.Session == nil {
+		return
+	}
+	archived := false
+	if _, err := p.View.Session.ChannelEdit(thread.ThreadID, &discordgo.ChannelEdit{Archived: &archived}); err != nil {
+		slog.Warn("couldn't unarchive queue view thread ", thread.ThreadID, ": ", err)
+	}
+}
+
+func (p *ViewPlacement) isThread(channelID string) bool {
+	for _, thread := range p.state.Threads {
+		if thread.ThreadID == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *ViewPlacement) load() {
+	if p.View.Store == nil {
+		return
+	}
+	if err := p.View.Store.Get(placementBucket, placementKey, &p.state); err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.Warn("couldn't load where the queue view was placed: ", err)
+	}
+}
+
+func (p *ViewPlacement) save() {
+	if p.View.Store == nil {
+		return
+	}
+	if err := p.View.Store.Put(placementBucket, placementKey, p.state); err != nil {
+		slog.Warn("couldn't save where the queue view is placed: ", err)
+	}
+}
+
+// isNotFound reports whether Discord said something doesn't exist.
+func isNotFound(err error) bool {
+	var restErr *discordgo.RESTError
+	return errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound
+}
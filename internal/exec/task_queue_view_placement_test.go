@@ -0,0 +1,65 @@
+package exec
+
+import (
+	"testing"
+	"time"
+
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestViewPlacement_BusiestFollowsRecentJobs(t *testing.T) {
+	view := NewTaskQueueView(NewTaskQueue(), nil, nil, false)
+	p := &ViewPlacement{View: view, Placement: PlaceBusiest, Window: time.Hour}
+	require.NoError(t, p.Place())
+	require.Empty(t, view.Channels())
+
+	now := time.Now()
+	p.follow("a", now)
+	require.Equal(t, []string{"a"}, view.Channels())
+
+	// a tie keeps the view where it is
+	p.follow("b", now)
+	require.Equal(t, []string{"a"}, view.Channels())
+	p.follow("b", now)
+	require.Equal(t, []string{"b"}, view.Channels())
+
+	// jobs older than the window stop counting
+	later := now.Add(2 * time.Hour)
+	p.follow("a", later)
+	require.Equal(t, []string{"a"}, view.Channels())
+}
+
+func TestViewPlacement_MovesWhenPlacementChanges(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	restart := func(p *ViewPlacement) *TaskQueueView {
+		p.View = NewTaskQueueView(NewTaskQueue(), nil, s, false)
+		require.NoError(t, p.View.Load())
+		require.NoError(t, p.Place())
+		return p.View
+	}
+
+	channels := &ViewPlacement{Channels: []string{"status"}, JobChannels: true}
+	view := restart(channels)
+	channels.JobStarted("jobs")
+	require.Equal(t, []string{"jobs", "status"}, view.Channels())
+
+	// job channels are kept across restarts while they're asked for
+	view = restart(&ViewPlacement{Channels: []string{"status"}, JobChannels: true})
+	require.Equal(t, []string{"jobs", "status"}, view.Channels())
+
+	busiest := &ViewPlacement{Placement: PlaceBusiest, Window: time.Hour}
+	view = restart(busiest)
+	require.Empty(t, view.Channels())
+	busiest.JobStarted("jobs")
+	require.Equal(t, []string{"jobs"}, view.Channels())
+
+	// the busiest channel is kept until another gets busier
+	view = restart(&ViewPlacement{Placement: PlaceBusiest, Window: time.Hour})
+	require.Equal(t, []string{"jobs"}, view.Channels())
+
+	view = restart(&ViewPlacement{Placement: PlaceChannels, Channels: []string{"status"}})
+	require.Equal(t, []string{"status"}, view.Channels())
+}
@@ -0,0 +1,98 @@
+package exec
+
+import (
+	"testing"
+
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskQueueView_RendersRunningAndWaitingJobs(t *testing.T) {
+	q := NewTaskQueue()
+	view := NewTaskQueueView(q, nil, nil, false)
+	require.Empty(t, view.renderBody())
+
+	running := newFakeTask("running")
+	running.content = "rainy jazz"
+	q.Enqueue(running)
+	<-running.started
+	defer close(running.release)
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		task := newFakeTask(id)
+		task.content = "prompt " + id
+		defer close(task.release)
+		q.Enqueue(task)
+	}
+
+	body := view.renderBody()
+	require.Contains(t, body, "Now generating: `rainy jazz`\n")
+	require.Contains(t, body, "prompt c")
+	require.NotContains(t, body, "prompt d")
+	require.Contains(t, body, "... and 2 more ...")
+}
+
+func TestTaskQueueView_ChannelsPersist(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+
+	view := NewTaskQueueView(NewTaskQueue(), nil, s, true)
+	view.AddChannel("status")
+	view.AddChannel("jobs")
+	view.AddChannel("jobs")
+	view.AddChannel("")
+	require.Equal(t, []string{"jobs", "status"}, view.Channels())
+
+	restored := NewTaskQueueView(NewTaskQueue(), nil, s, true)
+	require.NoError(t, restored.Load())
+	require.Equal(t, []string{"jobs", "status"}, restored.Channels())
+
+	restored.RemoveChannel("jobs")
+	again := NewTaskQueueView(NewTaskQueue(), nil, s, true)
+	require.NoError(t, again.Load())
+	require.Equal(t, []string{"status"}, again.Channels())
+}
+
+type progressingTask struct {
+	*fakeTask
+	progress string
+}
+
+func (t *progressingTask) Progress() string { return t.progress }
+
+func TestTaskQueueView_ShowsRunningJobsProgress(t *testing.T) {
+	q := NewTaskQueue()
+	view := NewTaskQueueView(q, nil, nil, false)
+
+	running := &progressingTask{fakeTask: newFakeTask("running"), progress: "37.0% (37/100 steps) · 12s elapsed · ~20s left"}
+	running.content = "rainy jazz"
+	q.Enqueue(running)
+	<-running.started
+	defer close(running.release)
+
+	require.Contains(t, view.renderBody(), "Now generating: `rainy jazz` · 37.0% (37/100 steps) · 12s elapsed · ~20s left\n")
+}
+
+func TestTaskQueueView_HurriesWhenJobsComeAndGo(t *testing.T) {
+	q := NewTaskQueue()
+	view := NewTaskQueueView(q, nil, nil, false)
+	require.False(t, view.Hurry())
+
+	running := newFakeTask("running")
+	q.Enqueue(running)
+	<-running.started
+	require.True(t, view.Hurry())
+
+	// a job that's only running along can wait
+	require.False(t, view.Hurry())
+
+	waiting := newFakeTask("waiting")
+	q.Enqueue(waiting)
+	require.True(t, view.Hurry())
+	require.False(t, view.Hurry())
+
+	close(running.release)
+	<-waiting.started
+	defer close(waiting.release)
+	require.True(t, view.Hurry())
+}
@@ -0,0 +1,75 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"slugbot/internal/io/slog"
+	"slugbot/internal/utils"
+)
+
+// ErrStalled is the cause given to a job the watchdog interrupts.
+var ErrStalled = errors.New("stopped because it made no progress")
+
+// Watchdog hears about a running job whose progress hasn't changed for a
+// while, e.g. sag hanging on very high step counts, reports it, and optionally
+// stops it and runs it once more. Jobs' progress files tell it, through Watch,
+// so it keeps no timer of its own.
+type Watchdog struct {
+	Queue      *TaskQueue
+	StallAfter time.Duration // how long without progress before a job counts as stalled
+	Kill       bool          // interrupt stalled jobs that support it
+	Requeue    bool          // run an interrupted job once more, at the front of the queue
+
+	// OnStall is called once per stalled job, with what the watchdog did about it.
+	OnStall func(info TaskInfo, idle time.Duration, action string)
+
+	mutex   sync.Mutex
+	flagged string // ID of the last job reported, so each stall is only reported once
+}
+
+// Watch has a running job's progress file call Stalled once it's gone
+// StallAfter without changing. It does nothing on a nil or disabled watchdog.
+func (w *Watchdog) Watch(pf *utils.PollableFile) {
+	if w == nil || w.StallAfter <= 0 || pf.Interval <= 0 {
+		return
+	}
+	pf.StaleAfter = int((w.StallAfter + pf.Interval - 1) / pf.Interval)
+	pf.OnStale = w.Stalled
+}
+
+// Stalled handles the running job, whose progress hasn't changed for idle.
+func (w *Watchdog) Stalled(idle time.Duration) {
+	info, ok := w.Queue.Running()
+	if !ok {
+		return
+	}
+
+	// a retried job keeps its ID, so it's told apart by when it started
+	run := fmt.Sprintf("%s@%d", info.ID, info.Started.UnixNano())
+	w.mutex.Lock()
+	if w.flagged == run {
+		w.mutex.Unlock()
+		return
+	}
+	w.flagged = run
+	w.mutex.Unlock()
+
+	action := "left running"
+	if interruptible, ok := info.Task.(Interruptible); ok && w.Kill {
+		reason := fmt.Errorf("%w for %s", ErrStalled, idle.Round(time.Second))
+		if interruptible.Interrupt(reason, w.Requeue) {
+			action = "stopped"
+			if interruptible.Retrying() {
+				action = "stopped and requeued"
+			}
+		}
+	}
+
+	slog.With("trace", info.Task.TraceID()).Warn("job ", info.ID, " made no progress for ", idle.Round(time.Second), "; ", action)
+	if w.OnStall != nil {
+		w.OnStall(info, idle, action)
+	}
+}
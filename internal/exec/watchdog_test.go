@@ -0,0 +1,94 @@
+package exec
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"slugbot/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+// stallTask hangs until it's interrupted, the first time it runs.
+type stallTask struct {
+	*fakeTask
+	runs        atomic.Int32
+	interrupted chan error
+	retrying    atomic.Bool
+}
+
+func newStallTask(messageID string) *stallTask {
+	return &stallTask{fakeTask: newFakeTask(messageID), interrupted: make(chan error, 1)}
+}
+
+func (t *stallTask) Apply() error {
+	if t.runs.Add(1) > 1 {
+		t.retrying.Store(false)
+		return nil
+	}
+	close(t.started)
+	return <-t.interrupted
+}
+
+func (t *stallTask) Interrupt(reason error, retry bool) bool {
+	t.retrying.Store(retry)
+	t.interrupted <- reason
+	return true
+}
+
+func (t *stallTask) Retrying() bool { return t.retrying.Load() }
+
+func TestWatchdog_InterruptsAndRequeuesStalledJob(t *testing.T) {
+	q := NewTaskQueue()
+	task := newStallTask("stuck")
+	q.Enqueue(task)
+	<-task.started
+	id := q.JobIDs("stuck")[0]
+
+	var actions []string
+	w := &Watchdog{Queue: q, StallAfter: time.Nanosecond, Kill: true, Requeue: true,
+		OnStall: func(info TaskInfo, idle time.Duration, action string) { actions = append(actions, action) }}
+	w.Stalled(time.Minute)
+	w.Stalled(time.Minute)
+	require.Equal(t, []string{"stopped and requeued"}, actions)
+
+	require.Eventually(t, func() bool { return task.runs.Load() == 2 }, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool {
+		info, _ := q.Info(id)
+		return info.State == StateDone
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestWatchdog_HearsFromStaleProgressFiles(t *testing.T) {
+	q := NewTaskQueue()
+	task := newStallTask("stuck")
+	q.Enqueue(task)
+	<-task.started
+	defer task.Interrupt(errors.New("done"), false)
+
+	stalls := make(chan time.Duration, 1)
+	w := &Watchdog{Queue: q, StallAfter: 20 * time.Millisecond, OnStall: func(info TaskInfo, idle time.Duration, action string) { stalls <- idle }}
+	pf, err := utils.NewPollableFile(5*time.Millisecond, func(string) {})
+	require.NoError(t, err)
+	defer pf.Remove()
+	w.Watch(pf)
+	require.Equal(t, 4, pf.StaleAfter)
+
+	done := make(chan struct{})
+	defer close(done)
+	go pf.Start(done)
+	select {
+	case idle := <-stalls:
+		require.GreaterOrEqual(t, idle, 20*time.Millisecond)
+	case <-time.After(time.Second):
+		t.Fatal("the watchdog wasn't told about the stale progress file")
+	}
+
+	// a disabled watchdog leaves progress files alone
+	pf = &utils.PollableFile{Interval: time.Millisecond}
+	(&Watchdog{Queue: q}).Watch(pf)
+	(*Watchdog)(nil).Watch(pf)
+	require.Zero(t, pf.StaleAfter)
+}
@@ -0,0 +1,168 @@
+// Package fakesag stands in for sag where generations shouldn't touch a GPU,
+// e.g. in a staging bot mirroring real traffic: it takes sag's arguments,
+// writes tqdm progress for its steps, and writes silence as long as was asked
+// for, so everything around a generation runs as it would for a real one.
+package fakesag
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"slugbot/internal/promptspec"
+)
+
+// EnvVar, when set to a step time like "50ms", makes the bot's binary run as
+// fake sag instead of as the bot; see Run.
+const EnvVar = "SLUGBOT_FAKE_SAG"
+
+// The output's format, as sag writes it.
+const (
+	sampleRate = 44100
+	channels   = 2
+	sampleBits = 16
+)
+
+// job is what a fake generation needs from sag's arguments.
+type job struct {
+	output   string
+	progress string
+	steps    int64
+	length   float64
+}
+
+// Run fakes one sag run: args are sag's, and stdin holds the TOML of a --toml
+// run. Each step takes stepTime.
+func Run(args []string, stdin io.Reader, stepTime time.Duration) error {
+	job, err := parseArgs(args, stdin)
+	if err != nil {
+		return err
+	}
+	started := time.Now()
+	for step := int64(0); step <= job.steps; step++ {
+		if step > 0 {
+			time.Sleep(stepTime)
+		}
+		if job.progress == "" {
+			continue
+		}
+		remaining := time.Duration(job.steps-step) * stepTime
+		if err := os.WriteFile(job.progress, []byte(tqdmLine(step, job.steps, time.Since(started), remaining)), 0o644); err != nil {
+			return fmt.Errorf("couldn't write progress: %w", err)
+		}
+	}
+	return writeSilence(job.output, job.length)
+}
+
+// parseArgs reads the options a fake run needs, as "--name=value" or
+// "--name value", and ignores the rest.
+func parseArgs(args []string, stdin io.Reader) (job, error) {
+	j := job{steps: 100, length: 30}
+	values := map[string]string{}
+	fromTOML := false
+	for i := 0; i < len(args); i++ {
+		name, value, ok := strings.Cut(args[i], "=")
+		if name == "--toml" {
+			fromTOML = true
+			continue
+		}
+		if !ok && i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			i++
+			value = args[i]
+		}
+		values[name] = value
+	}
+
+	if fromTOML {
+		content, err := io.ReadAll(stdin)
+		if err != nil {
+			return j, fmt.Errorf("couldn't read TOML: %w", err)
+		}
+		block, err := promptspec.ParseTOML(promptspec.NormalizeTOML(string(content)))
+		if err != nil {
+			return j, fmt.Errorf("couldn't parse TOML: %w", err)
+		}
+		j.steps, j.length = block.Config.Steps, block.Config.Length
+	}
+	if value, ok := values["--steps"]; ok {
+		steps, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return j, fmt.Errorf("invalid --steps: %w", err)
+		}
+		j.steps = steps
+	}
+	if value, ok := values["--length"]; ok {
+		length, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return j, fmt.Errorf("invalid --length: %w", err)
+		}
+		j.length = length
+	}
+	j.output, j.progress = values["--output"], values["--progress_file"]
+	if j.output == "" {
+		return j, fmt.Errorf("no --output given")
+	}
+	j.steps, j.length = max(j.steps, 0), max(j.length, 0)
+	return j, nil
+}
+
+// tqdmLine draws a progress bar the way tqdm does, e.g.
+// " 37%|███▋      | 37/100 [00:12<00:20,  3.01it/s]".
+func tqdmLine(step, steps int64, elapsed, remaining time.Duration) string {
+	fraction := 1.0
+	if steps > 0 {
+		fraction = float64(step) / float64(steps)
+	}
+	filled := int(fraction * 10)
+	bar := strings.Repeat("█", filled) + strings.Repeat(" ", 10-filled)
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(step) / elapsed.Seconds()
+	}
+	return fmt.Sprintf("%3d%%|%s| %d/%d [%s<%s, %5.2fit/s]\n", int(fraction*100), bar, step, steps, clock(elapsed), clock(remaining), rate)
+}
+
+// clock writes a duration as tqdm does, e.g. "01:20".
+func clock(d time.Duration) string {
+	seconds := int(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+// writeSilence writes a wav of length seconds of silence to path.
+func writeSilence(path string, length float64) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("couldn't create output: %w", err)
+	}
+	defer file.Close()
+
+	frameSize := channels * sampleBits / 8
+	dataSize := uint32(int64(length*sampleRate) * int64(frameSize))
+	header := []any{
+		[4]byte{'R', 'I', 'F', 'F'}, 36 + dataSize, [4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '}, uint32(16), uint16(1), uint16(channels), uint32(sampleRate),
+		uint32(sampleRate * frameSize), uint16(frameSize), uint16(sampleBits),
+		[4]byte{'d', 'a', 't', 'a'}, dataSize,
+	}
+	for _, field := range header {
+		if err := binary.Write(file, binary.LittleEndian, field); err != nil {
+			return fmt.Errorf("couldn't write output: %w", err)
+		}
+	}
+	if _, err := io.CopyN(file, zeros{}, int64(dataSize)); err != nil {
+		return fmt.Errorf("couldn't write output: %w", err)
+	}
+	return file.Close()
+}
+
+// zeros reads as an endless run of zero bytes.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
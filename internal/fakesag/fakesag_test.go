@@ -0,0 +1,43 @@
+package fakesag
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"slugbot/internal/discord"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_WritesProgressAndSilence(t *testing.T) {
+	dir := t.TempDir()
+	out, progress := filepath.Join(dir, "out.wav"), filepath.Join(dir, "progress")
+	require.NoError(t, Run([]string{"--prompt=rain", "--output=" + out, "--progress_file", progress, "--length=0.5", "--steps=4", "--small"}, nil, 0))
+
+	text, err := os.ReadFile(progress)
+	require.NoError(t, err)
+	p := discord.ParseProgress(string(text))
+	require.Equal(t, discord.PhaseDiffusing, p.Phase)
+	require.Equal(t, 4, p.Done)
+	require.Equal(t, 4, p.Total)
+
+	info, err := os.Stat(out)
+	require.NoError(t, err)
+	require.Equal(t, int64(44+sampleRate/2*4), info.Size())
+}
+
+func TestRun_ReadsTOML(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.wav")
+	toml := "[prompts]\n\"rain\" = 1.0\n[config]\nlength = 1\nsteps = 2\n"
+	require.NoError(t, Run([]string{"--toml", "--output=" + out}, strings.NewReader(toml), 0))
+
+	info, err := os.Stat(out)
+	require.NoError(t, err)
+	require.Equal(t, int64(44+sampleRate*4), info.Size())
+
+	require.Error(t, Run([]string{"--steps=1"}, nil, 0))
+	require.Error(t, Run([]string{"--output=" + out, "--steps=ten"}, nil, 0))
+}
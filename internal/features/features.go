@@ -0,0 +1,69 @@
+// Package features tracks which of the bot's subsystems a deployment runs.
+// A subsystem can be left out of the build with its no<feature> build tag,
+// e.g. `go build -tags noimage,nodashboard ./cmd/slugbot`, or turned off at
+// runtime under [features] in the config.
+package features
+
+import (
+	"strings"
+
+	"slugbot/internal/config"
+)
+
+// Feature is one of the bot's optional subsystems.
+type Feature string
+
+const (
+	Audio     Feature = "audio"     // generation commands and the job queue; it can't be left out of the build
+	Image     Feature = "image"     // `.sim` image operations
+	API       Feature = "api"       // the gRPC control API and `.stoken`
+	Dashboard Feature = "dashboard" // the web dashboard
+)
+
+// All lists every feature, in the order they're reported.
+var All = []Feature{Audio, Image, API, Dashboard}
+
+// omitted holds the features left out of this build; each no<feature> build
+// tag adds its own.
+var omitted = map[Feature]bool{}
+
+// Compiled reports whether a feature is part of this build.
+func Compiled(f Feature) bool {
+	return !omitted[f]
+}
+
+// Enabled reports whether a feature is part of this build and turned on in
+// the config.
+func Enabled(f Feature) bool {
+	if !Compiled(f) {
+		return false
+	}
+	cfg := config.Get().Features
+	switch f {
+	case Audio:
+		return cfg.Audio
+	case Image:
+		return cfg.Image
+	case API:
+		return cfg.API
+	case Dashboard:
+		return cfg.Dashboard
+	}
+	return false
+}
+
+// Summary describes what's enabled, e.g. "audio on, image off (not built), api on, dashboard off".
+func Summary() string {
+	var parts []string
+	for _, f := range All {
+		switch {
+		case !Compiled(f):
+			parts = append(parts, string(f)+" off (not built)")
+		case Enabled(f):
+			parts = append(parts, string(f)+" on")
+		default:
+			parts = append(parts, string(f)+" off")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
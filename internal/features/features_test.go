@@ -0,0 +1,27 @@
+package features
+
+import (
+	"testing"
+
+	"slugbot/internal/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnabled_FollowsConfigAndBuild(t *testing.T) {
+	cfg := config.Default()
+	cfg.Features.Dashboard = false
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	require.True(t, Enabled(Audio))
+	require.False(t, Enabled(Dashboard))
+
+	if Compiled(Image) {
+		omitted[Image] = true
+		t.Cleanup(func() { delete(omitted, Image) })
+	}
+	require.False(t, Enabled(Image))
+	require.Contains(t, Summary(), "image off (not built)")
+	require.Contains(t, Summary(), "audio on")
+}
@@ -0,0 +1,7 @@
+//go:build noapi
+
+package features
+
+func init() {
+	omitted[API] = true
+}
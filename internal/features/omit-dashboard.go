@@ -0,0 +1,7 @@
+//go:build nodashboard
+
+package features
+
+func init() {
+	omitted[Dashboard] = true
+}
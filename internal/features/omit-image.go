@@ -0,0 +1,7 @@
+//go:build noimage
+
+package features
+
+func init() {
+	omitted[Image] = true
+}
@@ -0,0 +1,67 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Duration renders a duration for people, e.g. "1h 23m 45s", "2m 5s", "3.4s", or "850ms".
+func Duration(d time.Duration) string {
+	if d < 0 {
+		return "-" + Duration(-d)
+	}
+	if d == 0 {
+		return "0s"
+	}
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	if d < 10*time.Second {
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+
+	d = d.Round(time.Second)
+	hours := d / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	seconds := (d % time.Minute) / time.Second
+
+	var parts []string
+	if hours > 0 {
+		parts = append(parts, fmt.Sprintf("%dh", hours))
+	}
+	if minutes > 0 {
+		parts = append(parts, fmt.Sprintf("%dm", minutes))
+	}
+	if seconds > 0 || len(parts) == 0 {
+		parts = append(parts, fmt.Sprintf("%ds", seconds))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Bytes renders a size in decimal units, e.g. "512 B" or "12.3 MB".
+func Bytes(n int64) string {
+	const unit = 1000
+	if n < 0 {
+		return "-" + Bytes(-n)
+	}
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 5; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+// Percent renders a fraction as a percentage, e.g. 0.123 as "12.3%".
+func Percent(fraction float64) string {
+	return fmt.Sprintf("%.1f%%", fraction*100)
+}
+
+// SignedPercent renders a relative change with its sign, e.g. 0.05 as "+5.0%".
+func SignedPercent(fraction float64) string {
+	return fmt.Sprintf("%+.1f%%", fraction*100)
+}
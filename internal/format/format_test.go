@@ -0,0 +1,31 @@
+package format
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuration(t *testing.T) {
+	require.Equal(t, "850ms", Duration(850*time.Millisecond))
+	require.Equal(t, "3.4s", Duration(3400*time.Millisecond))
+	require.Equal(t, "45s", Duration(45*time.Second))
+	require.Equal(t, "2m 5s", Duration(2*time.Minute+5*time.Second))
+	require.Equal(t, "2m", Duration(2*time.Minute))
+	require.Equal(t, "1h 23m 45s", Duration(time.Hour+23*time.Minute+45*time.Second+300*time.Millisecond))
+	require.Equal(t, "-45s", Duration(-45*time.Second))
+}
+
+func TestBytes(t *testing.T) {
+	require.Equal(t, "512 B", Bytes(512))
+	require.Equal(t, "1.5 kB", Bytes(1500))
+	require.Equal(t, "12.3 MB", Bytes(12_300_000))
+	require.Equal(t, "1.1 GB", Bytes(1<<30))
+}
+
+func TestPercent(t *testing.T) {
+	require.Equal(t, "12.3%", Percent(0.123))
+	require.Equal(t, "+5.0%", SignedPercent(0.05))
+	require.Equal(t, "-20.0%", SignedPercent(-0.2))
+}
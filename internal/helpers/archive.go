@@ -0,0 +1,152 @@
+package helpers
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"slugbot/internal/format"
+)
+
+// ErrArchiveTooBig is returned by ExtractImages for an archive over its limits.
+var ErrArchiveTooBig = errors.New("the archive is too big")
+
+// ErrUnsafeArchive is returned by ExtractImages for an archive with an entry
+// that would be written outside the directory it's extracted to.
+var ErrUnsafeArchive = errors.New("the archive has a file outside of it")
+
+// ArchiveLimits caps what ExtractImages takes out of an archive, whatever its
+// headers claim. Zero settings don't limit.
+type ArchiveLimits struct {
+	MaxFiles int   // most images
+	MaxBytes int64 // most bytes of images, uncompressed
+}
+
+// ExtractImages extracts the images in the zip at zipPath into dir and
+// returns their paths, in the archive's order. Other files, folders, and
+// symlinks are skipped, and the images are all put straight into dir, so
+// nothing can be written outside it. The images are normalized as
+// DownloadImage's are.
+func ExtractImages(zipPath string, dir string, limits ArchiveLimits) ([]string, error) {
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open the archive: %w", err)
+	}
+	defer archive.Close()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	var total int64
+	for _, file := range archive.File {
+		name := file.Name
+		if !filepath.IsLocal(filepath.FromSlash(name)) || strings.Contains(name, `\`) {
+			return nil, fmt.Errorf("%w: %q", ErrUnsafeArchive, name)
+		}
+		base := path.Base(name)
+		if !file.Mode().IsRegular() || strings.HasPrefix(base, ".") || strings.HasPrefix(name, "__MACOSX/") {
+			continue
+		}
+		if !strings.HasPrefix(ContentTypeFromFilename(base), "image/") {
+			continue
+		}
+		if limits.MaxFiles > 0 && len(paths) >= limits.MaxFiles {
+			return nil, fmt.Errorf("%w: it has more than %d images", ErrArchiveTooBig, limits.MaxFiles)
+		}
+
+		// numbered, so images with the same name in different folders don't collide
+		target := filepath.Join(dir, fmt.Sprintf("%03d-%s", len(paths)+1, base))
+		written, err := extractFile(file, target, limits.MaxBytes-total, limits.MaxBytes > 0)
+		if errors.Is(err, ErrArchiveTooBig) {
+			return nil, fmt.Errorf("%w: its images add up to more than %s", ErrArchiveTooBig, format.Bytes(limits.MaxBytes))
+		}
+		if err != nil {
+			return nil, err
+		}
+		total += written
+
+		normalized, err := normalizeImage(target)
+		if err != nil {
+			os.Remove(target)
+			return nil, fmt.Errorf("couldn't prepare %s: %w", base, err)
+		}
+		if normalized != target {
+			// converted to PNG, under a name of its own
+			target = strings.TrimSuffix(target, filepath.Ext(target)) + ".png"
+			if err := os.Rename(normalized, target); err != nil {
+				os.Remove(normalized)
+				return nil, err
+			}
+		}
+		paths = append(paths, target)
+	}
+	return paths, nil
+}
+
+// extractFile writes one archive entry to target, failing with
+// ErrArchiveTooBig if it's more than remaining bytes when limited.
+func extractFile(file *zip.File, target string, remaining int64, limited bool) (int64, error) {
+	in, err := file.Open()
+	if err != nil {
+		return 0, fmt.Errorf("couldn't read %s from the archive: %w", file.Name, err)
+	}
+	defer in.Close()
+	out, err := os.Create(target)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	var reader io.Reader = in
+	if limited {
+		reader = io.LimitReader(in, remaining+1)
+	}
+	written, err := io.Copy(out, reader)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't read %s from the archive: %w", file.Name, err)
+	}
+	if limited && written > remaining {
+		return 0, ErrArchiveTooBig
+	}
+	return written, out.Close()
+}
+
+// WriteZip packages files into a new zip at zipPath, each under its own name.
+func WriteZip(zipPath string, files []string) error {
+	out, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	archive := zip.NewWriter(out)
+	for _, file := range files {
+		if err := addToZip(archive, file); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func addToZip(archive *zip.Writer, file string) error {
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	w, err := archive.Create(filepath.Base(file))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, in)
+	return err
+}
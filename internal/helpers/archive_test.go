@@ -0,0 +1,90 @@
+package helpers
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// makeZip writes a zip with the given entries, by name, into dir.
+func makeZip(t *testing.T, dir string, entries map[string]string, order []string) string {
+	path := filepath.Join(dir, "in.zip")
+	out, err := os.Create(path)
+	require.NoError(t, err)
+	archive := zip.NewWriter(out)
+	for _, name := range order {
+		w, err := archive.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(entries[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+	require.NoError(t, out.Close())
+	return path
+}
+
+func TestExtractImages_TakesOnlyImages(t *testing.T) {
+	fakeMagick(t)
+	dir := t.TempDir()
+	entries := map[string]string{
+		"a.png":            "first",
+		"notes.txt":        "skipped",
+		"sub/a.png":        "second",
+		"sub/":             "",
+		"__MACOSX/._a.png": "skipped",
+		"c.JPG":            "third",
+	}
+	zipPath := makeZip(t, dir, entries, []string{"a.png", "notes.txt", "sub/", "sub/a.png", "__MACOSX/._a.png", "c.JPG"})
+
+	paths, err := ExtractImages(zipPath, filepath.Join(dir, "out"), ArchiveLimits{})
+	require.NoError(t, err)
+	require.Len(t, paths, 3)
+	for i, want := range []string{"first", "second", "third"} {
+		require.Equal(t, filepath.Join(dir, "out"), filepath.Dir(paths[i]))
+		content, err := os.ReadFile(paths[i])
+		require.NoError(t, err)
+		require.Equal(t, want, string(content))
+	}
+}
+
+func TestExtractImages_RefusesUnsafeAndOversizedArchives(t *testing.T) {
+	fakeMagick(t)
+	for _, name := range []string{"../evil.png", "/abs/evil.png", `..\evil.png`} {
+		dir := t.TempDir()
+		zipPath := makeZip(t, dir, map[string]string{name: "x"}, []string{name})
+		_, err := ExtractImages(zipPath, filepath.Join(dir, "out"), ArchiveLimits{})
+		require.ErrorIs(t, err, ErrUnsafeArchive, name)
+	}
+
+	dir := t.TempDir()
+	zipPath := makeZip(t, dir, map[string]string{"a.png": "x", "b.png": "y", "c.png": "z"}, []string{"a.png", "b.png", "c.png"})
+	_, err := ExtractImages(zipPath, filepath.Join(dir, "out"), ArchiveLimits{MaxFiles: 2})
+	require.ErrorIs(t, err, ErrArchiveTooBig)
+
+	// the sizes in the headers aren't trusted, so a zip bomb stops at the limit
+	dir = t.TempDir()
+	zipPath = makeZip(t, dir, map[string]string{"a.png": strings.Repeat("0", 1000), "b.png": strings.Repeat("0", 1000)}, []string{"a.png", "b.png"})
+	_, err = ExtractImages(zipPath, filepath.Join(dir, "out"), ArchiveLimits{MaxBytes: 1500})
+	require.ErrorIs(t, err, ErrArchiveTooBig)
+	_, err = ExtractImages(zipPath, filepath.Join(dir, "out"), ArchiveLimits{MaxBytes: 2000})
+	require.NoError(t, err)
+}
+
+func TestWriteZip(t *testing.T) {
+	fakeMagick(t)
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.png"), filepath.Join(dir, "b.png")
+	require.NoError(t, os.WriteFile(a, []byte("one"), 0o644))
+	require.NoError(t, os.WriteFile(b, []byte("two"), 0o644))
+
+	zipPath := filepath.Join(dir, "out.zip")
+	require.NoError(t, WriteZip(zipPath, []string{a, b}))
+	paths, err := ExtractImages(zipPath, filepath.Join(dir, "back"), ArchiveLimits{})
+	require.NoError(t, err)
+	require.Len(t, paths, 2)
+	require.Equal(t, "001-a.png", filepath.Base(paths[0]))
+}
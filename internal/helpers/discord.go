@@ -1,18 +1,31 @@
 package helpers
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slugbot/internal/discord"
 	"slugbot/internal/io/slog"
 	"strings"
 
 	"github.com/bwmarrin/discordgo"
 )
 
+// IsImageAttachment reports whether an attachment is an image, going by its
+// file name when its content type is missing or generic.
 func IsImageAttachment(attachment discordgo.MessageAttachment) bool {
-	return strings.HasPrefix(attachment.ContentType, "image/")
+	return strings.HasPrefix(attachmentContentType(attachment), "image/")
+}
+
+// attachmentContentType is an attachment's content type, or the one its file
+// name suggests if Discord didn't give a useful one.
+func attachmentContentType(attachment discordgo.MessageAttachment) string {
+	if !IsGenericContentType(attachment.ContentType) {
+		return NormalizeContentType(attachment.ContentType)
+	}
+	return ContentTypeFromFilename(attachment.Filename)
 }
 
 func GetEmbedImageURL(embed *discordgo.MessageEmbed) string {
@@ -50,60 +63,48 @@ func GetMessageImageURL(message *discordgo.Message) string {
 	return ""
 }
 
-func GetImageFromReferencedMessage(session *discordgo.Session, message *discordgo.MessageCreate) (string, error) {
-	if message.MessageReference == nil {
-		return "", fmt.Errorf("message is not a reply")
-	}
-
-	replyMessage, err := session.ChannelMessage(message.ChannelID, message.MessageReference.MessageID)
-	if err != nil {
-		return "", fmt.Errorf("failed to fetch message that was replied to")
-	}
-
-	if imageURL := GetMessageImageURL(replyMessage); imageURL != "" {
-		return url.QueryUnescape(imageURL)
-	}
-
-	return "", fmt.Errorf("no image attachment found in message that was replied to")
-}
-
-func GetImageFromRecentChatHistory(session *discordgo.Session, message *discordgo.MessageCreate) (string, error) {
+func GetImageFromRecentChatHistory(session *discordgo.Session, message *discordgo.MessageCreate, selector string) (string, error) {
 	messages, err := session.ChannelMessages(message.ChannelID, 50, "", "", "")
 	if err != nil {
 		return "", fmt.Errorf("failed to search recent messages for images")
 	}
 
 	for _, msg := range messages {
-		for _, attachment := range msg.Attachments {
-			if IsImageAttachment(*attachment) {
-				return attachment.URL, nil
-			}
-		}
-		for _, embed := range msg.Embeds {
-			if imageURL := GetEmbedImageURL(embed); imageURL != "" {
-				return imageURL, nil
+		if images := MessageImages(msg); len(images) > 0 {
+			image, err := SelectInput(images, selector)
+			if err != nil {
+				return "", err
 			}
+			return image.URL, nil
 		}
 	}
 
 	return "", fmt.Errorf("no image found in recent chat history")
 }
-func GetImageReference(session *discordgo.Session, message *discordgo.MessageCreate) (string, error) {
+
+// GetImageReference finds the image a command should work on: attached to the
+// command's message or the message it replies to (see ResolveInput), or else,
+// if it isn't a reply, in the most recent message with images. When that
+// message has several images, selector picks one (see SelectInput).
+func GetImageReference(session *discordgo.Session, message *discordgo.MessageCreate, selector string) (string, error) {
 	if message.Author.Bot {
 		return "", fmt.Errorf("no image found")
 	}
 
-	// If message is a reply, get the image from the message being replied to
-	if message.MessageReference != nil {
-		return GetImageFromReferencedMessage(session, message)
+	image, err := ResolveInput(session, message, MessageImages, selector)
+	if err == nil {
+		return url.QueryUnescape(image.URL)
+	}
+	if !errors.Is(err, ErrNoInput) || message.MessageReference != nil {
+		return "", err
 	}
 
 	// Otherwise, get it from the recent chat history
-	if imageURL, err := GetImageFromRecentChatHistory(session, message); err == nil {
-		return url.QueryUnescape(imageURL)
+	imageURL, err := GetImageFromRecentChatHistory(session, message, selector)
+	if err != nil {
+		return "", err
 	}
-
-	return "", fmt.Errorf("couldn't any images in recent chat history")
+	return url.QueryUnescape(imageURL)
 }
 
 func UploadImage(session *discordgo.Session, channelID, pathToImage string) error {
@@ -137,7 +138,11 @@ func UploadImage(session *discordgo.Session, channelID, pathToImage string) erro
 		},
 	}
 
-	_, err = session.ChannelMessageSendComplex(channelID, messageSend)
+	_, err = discord.SendFiles(session, channelID, messageSend)
+	if errors.Is(err, discord.ErrUndelivered) {
+		slog.Warn(fmt.Sprintf("Couldn't upload image %s: %v", pathToImage, err))
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to send file to discord: %w", err)
 	}
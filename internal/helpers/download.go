@@ -0,0 +1,110 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"slugbot/internal/cache"
+	"slugbot/internal/utils"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// downloadCache serves repeated downloads of the same file from disk when set.
+var downloadCache *cache.Cache
+
+// SetDownloadCache enables (or, with nil, disables) caching for DownloadFile.
+func SetDownloadCache(c *cache.Cache) {
+	downloadCache = c
+}
+
+// inputScanner checks the files users send before they're processed when set.
+var inputScanner func(path string, message *discordgo.MessageCreate) error
+
+// SetInputScanner enables (or, with nil, disables) checking inputs with ScanInput.
+func SetInputScanner(scanner func(path string, message *discordgo.MessageCreate) error) {
+	inputScanner = scanner
+}
+
+// ScanInput checks a file downloaded for message, e.g. its init audio, and
+// returns an error if it shouldn't be processed.
+func ScanInput(path string, message *discordgo.MessageCreate) error {
+	if inputScanner == nil {
+		return nil
+	}
+	return inputScanner(path, message)
+}
+
+// DownloadFile saves the content at url into a new temp file named per
+// os.CreateTemp's pattern rules, and returns its path. The caller owns the file.
+func DownloadFile(url string, pattern string) (string, error) {
+	if downloadCache != nil {
+		return downloadCache.Fetch(url, pattern)
+	}
+
+	tmpFile, err := os.CreateTemp(utils.TempDir(), pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer tmpFile.Close()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to download file: server returned %s", resp.Status)
+	}
+
+	if _, err = io.Copy(tmpFile, resp.Body); err != nil {
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to copy file content: %w", err)
+	}
+
+	return tmpFile.Name(), nil
+}
+
+// DownloadFiles downloads several files with at most parallelism requests in
+// flight. Paths are returned in the same order as urls. If any download fails,
+// the files that did succeed are removed and the first error is returned.
+func DownloadFiles(urls []string, patterns []string, parallelism int) ([]string, error) {
+	if len(urls) != len(patterns) {
+		return nil, fmt.Errorf("DownloadFiles: got %d urls but %d patterns", len(urls), len(patterns))
+	}
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	paths := make([]string, len(urls))
+	errs := make([]error, len(urls))
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			paths[i], errs[i] = DownloadFile(urls[i], patterns[i])
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			for _, path := range paths {
+				if path != "" {
+					os.Remove(path)
+				}
+			}
+			return nil, err
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,31 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadFile_FailsOnAnErrorResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone.wav" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("audio"))
+	}))
+	defer server.Close()
+
+	_, err := DownloadFile(server.URL+"/gone.wav", "in-*.wav")
+	require.ErrorContains(t, err, "404")
+
+	path, err := DownloadFile(server.URL+"/kept.wav", "in-*.wav")
+	require.NoError(t, err)
+	defer os.Remove(path)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "audio", string(data))
+}
@@ -0,0 +1,162 @@
+package helpers
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"slugbot/internal/io/slog"
+	"slugbot/internal/tools"
+)
+
+// GIFPaletteFilter is appended to a video filter chain to produce a GIF with a
+// per-animation palette: the stream is split so palettegen and paletteuse see
+// the same frames without decoding the input twice.
+const GIFPaletteFilter = "split[a][b];[a]palettegen[p];[b][p]paletteuse=dither=floyd_steinberg"
+
+// The formats animations can be made in. WebP and APNG compress far better
+// than GIF and aren't limited to 256 colours, but need ffmpeg built with
+// their encoders.
+const (
+	AnimGIF  = "gif"
+	AnimWebP = "webp"
+	AnimAPNG = "apng"
+)
+
+// AnimFormats lists the animation formats, for usage strings.
+var AnimFormats = []string{AnimGIF, AnimWebP, AnimAPNG}
+
+// animEncoders are the ffmpeg encoders each format can use, best first.
+var animEncoders = map[string][]string{
+	AnimGIF:  {"gif"},
+	AnimWebP: {"libwebp_anim", "libwebp"},
+	AnimAPNG: {"apng"},
+}
+
+// SplitAnimFormatFlag removes `--anim-format <format>` from a command's
+// arguments and returns the rest and the format, which is AnimGIF if the flag
+// wasn't given.
+func SplitAnimFormatFlag(args []string) (rest []string, format string, err error) {
+	for i, arg := range args {
+		if arg != "--anim-format" {
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, "", fmt.Errorf("`--anim-format` needs one of %s", strings.Join(AnimFormats, ", "))
+		}
+		format = strings.ToLower(args[i+1])
+		if animEncoders[format] == nil {
+			return nil, "", fmt.Errorf("`--anim-format` must be one of %s", strings.Join(AnimFormats, ", "))
+		}
+		rest = append(append(rest, args[:i]...), args[i+2:]...)
+		return rest, format, nil
+	}
+	return args, AnimGIF, nil
+}
+
+// AnimExt returns the file extension of an animation format; APNGs are .png,
+// so they're shown as images.
+func AnimExt(format string) string {
+	if format == AnimAPNG {
+		return "png"
+	}
+	return format
+}
+
+// ffmpegEncoders caches the encoders each ffmpeg binary has, by its path.
+var ffmpegEncoders = struct {
+	sync.Mutex
+	byPath map[string]map[string]bool
+}{byPath: map[string]map[string]bool{}}
+
+// hasEncoder reports whether the configured ffmpeg has an encoder. If ffmpeg
+// can't be asked, only the GIF encoder every build has is assumed.
+func hasEncoder(name string) bool {
+	path := tools.Path(tools.FFmpeg)
+	ffmpegEncoders.Lock()
+	defer ffmpegEncoders.Unlock()
+
+	encoders, ok := ffmpegEncoders.byPath[path]
+	if !ok {
+		encoders = map[string]bool{"gif": true}
+		out, err := exec.Command(path, "-hide_banner", "-encoders").Output()
+		if err != nil {
+			slog.Warn("couldn't list ffmpeg's encoders: ", err)
+		}
+		for name := range parseEncoders(string(out)) {
+			encoders[name] = true
+		}
+		ffmpegEncoders.byPath[path] = encoders
+	}
+	return encoders[name]
+}
+
+// parseEncoders reads the names from `ffmpeg -encoders`, whose lines look
+// like " V....D libwebp_anim         libwebp WebP image (codec webp)".
+func parseEncoders(out string) map[string]bool {
+	names := map[string]bool{}
+	listing := false
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if !listing {
+			listing = len(fields) > 0 && strings.HasPrefix(fields[0], "---")
+			continue
+		}
+		if len(fields) >= 2 {
+			names[fields[1]] = true
+		}
+	}
+	return names
+}
+
+// AnimEncoder picks the ffmpeg encoder for an animation format. If ffmpeg
+// can't make that format, it falls back to a GIF, and format is AnimGIF.
+func AnimEncoder(requested string) (format string, encoder string) {
+	for _, encoder := range animEncoders[requested] {
+		if hasEncoder(encoder) {
+			return requested, encoder
+		}
+	}
+	if requested != AnimGIF {
+		slog.Warn("ffmpeg has no encoder for ", requested, " animations; making a GIF instead")
+	}
+	return AnimGIF, "gif"
+}
+
+// AnimArgs returns the ffmpeg arguments that encode the video stream [0:v],
+// after the filters (e.g. "fps=10", or "" for none), as a looping animation
+// with encoder, as picked by AnimEncoder. The output file comes after them.
+func AnimArgs(format string, encoder string, filters string) []string {
+	chain := "[0:v]"
+	if filters != "" {
+		chain += filters + ","
+	}
+	switch format {
+	case AnimWebP:
+		return []string{"-filter_complex", chain + "format=yuva420p", "-c:v", encoder, "-quality", "80", "-loop", "0"}
+	case AnimAPNG:
+		return []string{"-filter_complex", chain + "format=rgba", "-c:v", encoder, "-plays", "0", "-f", "apng"}
+	default:
+		return []string{"-filter_complex", chain + GIFPaletteFilter, "-loop", "0"}
+	}
+}
+
+// AssembleAnimation encodes a numbered sequence of frames (an ffmpeg pattern
+// such as "dir/frame-%04d.png") into a looping animation at the given frame
+// rate, within the guild's resource limits. format is the one AnimEncoder
+// picked, with its encoder, and outFile should have its AnimExt. It waits for
+// a render slot.
+func AssembleAnimation(guildID string, framePattern string, fps int, format string, encoder string, outFile string) error {
+	args := []string{"-framerate", fmt.Sprintf("%d", fps), "-i", framePattern}
+	args = append(args, AnimArgs(format, encoder, "")...)
+	command := exec.Command(tools.Path(tools.FFmpeg), FFmpegArgs(guildID, append(args, "-y", outFile)...)...)
+
+	slog.Trace(fmt.Sprintf("Running command: %s", strings.Join(command.Args, " ")))
+
+	defer RenderSlot()()
+	if out, err := command.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to assemble %s: %w\nOutput: %s", format, err, string(out))
+	}
+	return nil
+}
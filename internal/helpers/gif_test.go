@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitAnimFormatFlag(t *testing.T) {
+	rest, format, err := SplitAnimFormatFlag([]string{".sim", "genframes", "10", "--anim-format", "WebP", "--fps", "5"})
+	require.NoError(t, err)
+	require.Equal(t, []string{".sim", "genframes", "10", "--fps", "5"}, rest)
+	require.Equal(t, AnimWebP, format)
+
+	rest, format, err = SplitAnimFormatFlag([]string{".sim", "genframes", "10"})
+	require.NoError(t, err)
+	require.Equal(t, []string{".sim", "genframes", "10"}, rest)
+	require.Equal(t, AnimGIF, format)
+
+	_, _, err = SplitAnimFormatFlag([]string{".sim", "genframes", "10", "--anim-format", "mp4"})
+	require.Error(t, err)
+	_, _, err = SplitAnimFormatFlag([]string{".sim", "genframes", "10", "--anim-format"})
+	require.Error(t, err)
+}
+
+func TestParseEncoders(t *testing.T) {
+	out := `Encoders:
+ V..... = Video
+ ------
+ V....D apng                 APNG (Animated Portable Network Graphics) image
+ V....D gif                  GIF (Graphics Interchange Format)
+ V....D libwebp_anim         libwebp WebP image (codec webp)
+ A....D aac                  AAC (Advanced Audio Coding)
+`
+	names := parseEncoders(out)
+	require.True(t, names["apng"])
+	require.True(t, names["libwebp_anim"])
+	require.True(t, names["aac"])
+	require.False(t, names["Video"])
+	require.False(t, names["libwebp"])
+}
+
+func TestAnimArgs(t *testing.T) {
+	require.Equal(t, []string{"-filter_complex", "[0:v]fps=10," + GIFPaletteFilter, "-loop", "0"}, AnimArgs(AnimGIF, "gif", "fps=10"))
+	require.Equal(t, []string{"-filter_complex", "[0:v]format=rgba", "-c:v", "apng", "-plays", "0", "-f", "apng"}, AnimArgs(AnimAPNG, "apng", ""))
+	require.Contains(t, AnimArgs(AnimWebP, "libwebp", ""), "libwebp")
+	require.Equal(t, "png", AnimExt(AnimAPNG))
+	require.Equal(t, "webp", AnimExt(AnimWebP))
+}
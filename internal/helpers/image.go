@@ -2,13 +2,23 @@ package helpers
 
 import (
 	"fmt"
-	"io"
 	"net/http"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"slugbot/internal/cmdline"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/tools"
+	"slugbot/internal/utils"
 
 	"github.com/bwmarrin/discordgo"
 )
 
+// GetMimeTypeFromURL returns the MIME type of the content at url. When the
+// server's Content-Type is missing or generic, the type is sniffed from the
+// content's first bytes instead.
 func GetMimeTypeFromURL(url string) (string, error) {
 	resp, err := http.Head(url)
 	if err != nil {
@@ -16,15 +26,24 @@ func GetMimeTypeFromURL(url string) (string, error) {
 	}
 	defer resp.Body.Close()
 
-	contentType := resp.Header.Get("Content-Type")
-	if contentType == "" {
+	contentType := NormalizeContentType(resp.Header.Get("Content-Type"))
+	if !IsGenericContentType(contentType) {
+		return contentType, nil
+	}
+
+	sniffed, err := sniffURL(url)
+	if err != nil {
+		return "", fmt.Errorf("no MIME type found for URL %s, and couldn't sniff one: %w", url, err)
+	}
+	if IsGenericContentType(sniffed) {
 		return "", fmt.Errorf("no MIME type found for URL: %s", url)
 	}
-	return contentType, nil
+	slog.Trace(fmt.Sprintf("Sniffed MIME type %s for %s (server said %q)", sniffed, url, contentType))
+	return sniffed, nil
 }
 
 func GetFileExtensionFromMimeType(mimeType string) (string, error) {
-	switch mimeType {
+	switch NormalizeContentType(mimeType) {
 	case "image/gif":
 		return "gif", nil
 	case "image/jpeg":
@@ -35,6 +54,12 @@ func GetFileExtensionFromMimeType(mimeType string) (string, error) {
 		return "webp", nil
 	case "image/bmp":
 		return "bmp", nil
+	case "image/heic", "image/heif":
+		return "heic", nil
+	case "image/avif":
+		return "avif", nil
+	case "image/tiff":
+		return "tiff", nil
 	case "video/mp4":
 		return "mp4", nil
 	case "video/webm":
@@ -74,34 +99,95 @@ func GetFileExtensionFromURL(imageURL string) (string, error) {
 	return fileExtension, nil
 }
 
+// convertedFormats are image formats that phones and cameras upload but that
+// not every tool handles, so downloads of them are converted to PNG first.
+var convertedFormats = map[string]bool{
+	"heic": true,
+	"avif": true,
+	"tiff": true,
+}
+
+// DownloadImage downloads an image into a temp file and returns its path. The
+// image is normalized (see normalizeImage), so callers only see upright images
+// without metadata, in formats every pipeline can read.
 func DownloadImage(imageURL string) (string, error) {
 	fileExtension, err := GetFileExtensionFromURL(imageURL)
 	if err != nil {
 		return "", fmt.Errorf("coudn't determine file extension: %w", err)
 	}
 
-	tmpFile, err := os.CreateTemp("", fmt.Sprintf("in-*.%s", fileExtension))
+	path, err := DownloadFile(imageURL, fmt.Sprintf("in-*.%s", fileExtension))
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
+		return "", fmt.Errorf("failed to download image: %w", err)
 	}
-	defer tmpFile.Close()
-
-	resp, err := http.Get(imageURL)
+	normalized, err := normalizeImage(path)
 	if err != nil {
-		return "", fmt.Errorf("failed to download image: %w", err)
+		os.Remove(path)
+		return "", err
+	}
+	return normalized, nil
+}
+
+// normalizeImage readies an image for processing: every image but a GIF is
+// turned upright by its EXIF orientation, so phone photos don't come out
+// rotated, and stripped of its metadata, so nothing like the location a phone
+// recorded ends up in a result. HEIC, AVIF, and TIFF images are converted to
+// PNG, as not every tool handles them. It returns the path of the result,
+// which replaces the file at path, and is in the same directory.
+func normalizeImage(path string) (string, error) {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if ext == "gif" {
+		return path, nil
+	}
+	if convertedFormats[ext] {
+		converted, err := convertToPNG(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to convert %s image: %w", ext, err)
+		}
+		os.Remove(path)
+		return converted, nil
 	}
-	defer resp.Body.Close()
 
-	_, err = io.Copy(tmpFile, resp.Body)
+	out, err := os.CreateTemp(filepath.Dir(path), "normalized-*"+filepath.Ext(path))
 	if err != nil {
-		return "", fmt.Errorf("failed to copy image content: %w", err)
+		return "", fmt.Errorf("error creating normalized file: %w", err)
+	}
+	out.Close()
+	// inputs are normalized before a command sees them, so only the default limits apply
+	command := exec.Command(tools.Path(tools.Magick), MagickArgs("", cmdline.MagickInput(path), "-auto-orient", "-strip", out.Name())...)
+	slog.Trace(fmt.Sprintf("Running command: %s", strings.Join(command.Args, " ")))
+	if output, err := command.CombinedOutput(); err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("failed to normalize image: %w\nOutput: %s", err, string(output))
+	}
+	if err := os.Rename(out.Name(), path); err != nil {
+		os.Remove(out.Name())
+		return "", err
 	}
+	return path, nil
+}
 
-	return tmpFile.Name(), nil
+// convertToPNG converts the first frame or page of an image to a new, upright
+// PNG without metadata, next to it.
+func convertToPNG(path string) (string, error) {
+	out, err := os.CreateTemp(filepath.Dir(path), "in-*.png")
+	if err != nil {
+		return "", fmt.Errorf("error creating converted file: %w", err)
+	}
+	out.Close()
+
+	// inputs are converted before a command sees them, so only the default limits apply
+	command := exec.Command(tools.Path(tools.Magick), MagickArgs("", cmdline.MagickInput(path)+"[0]", "-auto-orient", "-strip", out.Name())...)
+	slog.Trace(fmt.Sprintf("Running command: %s", strings.Join(command.Args, " ")))
+	if output, err := command.CombinedOutput(); err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("%w\nOutput: %s", err, string(output))
+	}
+	return out.Name(), nil
 }
 
-func PrepareImageFiles(session *discordgo.Session, msg *discordgo.MessageCreate) (inputPath string, outputPath string, cleanup func(), err error) {
-	imageURL, err := GetImageReference(session, msg)
+func PrepareImageFiles(session *discordgo.Session, msg *discordgo.MessageCreate, selector string) (inputPath string, outputPath string, cleanup func(), err error) {
+	imageURL, err := GetImageReference(session, msg, selector)
 	if err != nil {
 		return "", "", nil, fmt.Errorf("error getting image reference: %w", err)
 	}
@@ -113,14 +199,16 @@ func PrepareImageFiles(session *discordgo.Session, msg *discordgo.MessageCreate)
 	if err != nil {
 		return "", "", nil, fmt.Errorf("error downloading image: %w", err)
 	}
+	if err := ScanInput(tmpIn, msg); err != nil {
+		os.Remove(tmpIn)
+		return "", "", nil, err
+	}
 	fmt.Println("Created temp infile at: ", tmpIn)
 
-	fileExtension, err := GetFileExtensionFromURL(imageURL)
-	if err != nil {
-		return "", "", nil, fmt.Errorf("coudn't determine file extension: %w", err)
-	}
+	// the output matches the downloaded input, which may have been converted
+	fileExtension := strings.TrimPrefix(filepath.Ext(tmpIn), ".")
 
-	tmpOut, err := os.CreateTemp("", fmt.Sprintf("out-*.%s", fileExtension))
+	tmpOut, err := os.CreateTemp(utils.TempDir(), fmt.Sprintf("out-*.%s", fileExtension))
 	if err != nil {
 		os.Remove(tmpIn)
 		return "", "", nil, fmt.Errorf("error creating output file: %w", err)
@@ -0,0 +1,85 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"slugbot/internal/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMagick stands in for magick: it copies its first input to its output,
+// the last argument, and returns the file it logs its arguments to.
+func fakeMagick(t *testing.T) string {
+	dir := t.TempDir()
+	script, calls := filepath.Join(dir, "magick"), filepath.Join(dir, "calls")
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+echo "$@" >> `+calls+`
+for a; do f=${a#*:}; f=${f%\[0\]}; [ -z "$in" ] && [ -f "$f" ] && in=$f; out=$a; done
+cp "$in" "$out"
+`), 0o755))
+	cfg := config.Default()
+	cfg.Limits = config.Limits{}
+	cfg.Tools.Magick = script
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+	return calls
+}
+
+func TestNormalizeImage_OrientsAndStrips(t *testing.T) {
+	calls := fakeMagick(t)
+	dir := t.TempDir()
+
+	jpeg := filepath.Join(dir, "in-1.jpg")
+	require.NoError(t, os.WriteFile(jpeg, []byte("photo"), 0o644))
+	got, err := normalizeImage(jpeg)
+	require.NoError(t, err)
+	require.Equal(t, jpeg, got)
+
+	heic := filepath.Join(dir, "in-2.heic")
+	require.NoError(t, os.WriteFile(heic, []byte("photo"), 0o644))
+	got, err = normalizeImage(heic)
+	require.NoError(t, err)
+	require.Equal(t, ".png", filepath.Ext(got))
+	require.Equal(t, dir, filepath.Dir(got))
+	require.NoFileExists(t, heic)
+
+	// animations are left alone
+	gif := filepath.Join(dir, "in-3.gif")
+	require.NoError(t, os.WriteFile(gif, []byte("anim"), 0o644))
+	got, err = normalizeImage(gif)
+	require.NoError(t, err)
+	require.Equal(t, gif, got)
+
+	logged, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(logged)), "\n")
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		require.Contains(t, line, "-auto-orient -strip")
+	}
+}
+
+func TestGetFileExtensionFromMimeType_PhoneFormats(t *testing.T) {
+	for mimeType, want := range map[string]string{
+		"image/heic": "heic",
+		"image/heif": "heic",
+		"image/avif": "avif",
+		"image/tiff": "tiff",
+	} {
+		got, err := GetFileExtensionFromMimeType(mimeType)
+		require.NoError(t, err, mimeType)
+		require.Equal(t, want, got, mimeType)
+		require.True(t, convertedFormats[got], "%s should be converted before processing", mimeType)
+	}
+
+	got, err := GetFileExtensionFromMimeType("image/png")
+	require.NoError(t, err)
+	require.False(t, convertedFormats[got])
+
+	_, err = GetFileExtensionFromMimeType("application/octet-stream")
+	require.ErrorContains(t, err, "unsupported MIME type")
+}
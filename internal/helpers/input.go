@@ -0,0 +1,142 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ErrNoInput is returned by ResolveInput when there's no usable file to work on.
+var ErrNoInput = errors.New("no usable attachments found")
+
+// ErrNoReply is returned by ResolveInput when the message that was replied to
+// couldn't be fetched.
+var ErrNoReply = errors.New("couldn't fetch the message that was replied to")
+
+// MessageFetcher looks up a message by ID; *discordgo.Session is one.
+type MessageFetcher interface {
+	ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+}
+
+// InputFile is a file in a message that a command could use as its input.
+type InputFile struct {
+	Name string
+	URL  string
+}
+
+// SplitInputFlag removes `--input <selector>` from a command's text and returns
+// the remaining text and the selector, which is "" if the flag wasn't given.
+func SplitInputFlag(content string) (rest string, selector string, err error) {
+	fields := strings.Fields(content)
+	for i, field := range fields {
+		if field != "--input" {
+			continue
+		}
+		if i+1 >= len(fields) {
+			return "", "", fmt.Errorf("`--input` needs an attachment number or filename")
+		}
+		selector = fields[i+1]
+		fields = append(fields[:i], fields[i+2:]...)
+		return strings.Join(fields, " "), selector, nil
+	}
+	return content, "", nil
+}
+
+// SelectInput picks one of files by selector: "" for the first, a 1-based
+// number, or a filename (case-insensitive).
+func SelectInput(files []InputFile, selector string) (InputFile, error) {
+	if len(files) == 0 {
+		return InputFile{}, fmt.Errorf("no usable attachments found")
+	}
+	if selector == "" {
+		return files[0], nil
+	}
+
+	if n, err := strconv.Atoi(selector); err == nil {
+		if n < 1 || n > len(files) {
+			return InputFile{}, fmt.Errorf("`--input %d` is out of range; the message has %d usable attachment(s)", n, len(files))
+		}
+		return files[n-1], nil
+	}
+
+	var names []string
+	for _, file := range files {
+		if strings.EqualFold(file.Name, selector) {
+			return file, nil
+		}
+		names = append(names, "`"+file.Name+"`")
+	}
+	return InputFile{}, fmt.Errorf("no attachment named `%s`; must be one of %s", selector, strings.Join(names, ", "))
+}
+
+// MessageImages lists the images attached or embedded in a message, in order.
+func MessageImages(message *discordgo.Message) []InputFile {
+	var files []InputFile
+	for _, attachment := range message.Attachments {
+		if IsImageAttachment(*attachment) {
+			files = append(files, InputFile{Name: attachment.Filename, URL: attachment.URL})
+		}
+	}
+	for i, embed := range message.Embeds {
+		if imageURL := GetEmbedImageURL(embed); imageURL != "" {
+			files = append(files, InputFile{Name: fmt.Sprintf("embed-%d", i+1), URL: imageURL})
+		}
+	}
+	return files
+}
+
+// MessageAudio lists the WAV files attached to a message, in order: ones named
+// .wav, or ones whose content type says they're WAVs whatever their name.
+func MessageAudio(message *discordgo.Message) []InputFile {
+	var files []InputFile
+	for _, attachment := range message.Attachments {
+		if strings.HasSuffix(strings.ToLower(attachment.Filename), ".wav") || NormalizeContentType(attachment.ContentType) == "audio/wav" {
+			files = append(files, InputFile{Name: attachment.Filename, URL: attachment.URL})
+		}
+	}
+	return files
+}
+
+// MessageArchives lists the zip files attached to a message, in order.
+func MessageArchives(message *discordgo.Message) []InputFile {
+	var files []InputFile
+	for _, attachment := range message.Attachments {
+		if strings.HasSuffix(strings.ToLower(attachment.Filename), ".zip") || NormalizeContentType(attachment.ContentType) == "application/zip" {
+			files = append(files, InputFile{Name: attachment.Filename, URL: attachment.URL})
+		}
+	}
+	return files
+}
+
+// ResolveInput finds the file a command works on: one attached to the
+// command's own message, or else one attached to the message it replies to,
+// e.g. one of the bot's results, so replying to a result with just a command
+// runs the command on it. list picks a message's usable files, e.g.
+// MessageImages or MessageAudio, and selector chooses among several (see
+// SelectInput). It returns an error wrapping ErrNoInput if neither message has
+// a usable file.
+func ResolveInput(fetcher MessageFetcher, message *discordgo.MessageCreate, list func(*discordgo.Message) []InputFile, selector string) (InputFile, error) {
+	if files := list(message.Message); len(files) > 0 {
+		return SelectInput(files, selector)
+	}
+	if message.MessageReference == nil || message.MessageReference.MessageID == "" {
+		return InputFile{}, ErrNoInput
+	}
+
+	channelID := message.MessageReference.ChannelID
+	if channelID == "" {
+		channelID = message.ChannelID
+	}
+	replied, err := fetcher.ChannelMessage(channelID, message.MessageReference.MessageID)
+	if err != nil {
+		return InputFile{}, fmt.Errorf("%w: %w", ErrNoReply, err)
+	}
+	files := list(replied)
+	if len(files) == 0 {
+		return InputFile{}, fmt.Errorf("%w in the message or the one it replies to", ErrNoInput)
+	}
+	return SelectInput(files, selector)
+}
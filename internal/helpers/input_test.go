@@ -0,0 +1,107 @@
+package helpers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitInputFlag(t *testing.T) {
+	rest, selector, err := SplitInputFlag(".sim barrel --input 2 0 0 1 0")
+	require.NoError(t, err)
+	require.Equal(t, ".sim barrel 0 0 1 0", rest)
+	require.Equal(t, "2", selector)
+
+	rest, selector, err = SplitInputFlag(".sim polar")
+	require.NoError(t, err)
+	require.Equal(t, ".sim polar", rest)
+	require.Empty(t, selector)
+
+	_, _, err = SplitInputFlag(".sim polar --input")
+	require.Error(t, err)
+}
+
+func TestSelectInput(t *testing.T) {
+	files := []InputFile{{Name: "a.png", URL: "u1"}, {Name: "B.png", URL: "u2"}}
+
+	got, err := SelectInput(files, "")
+	require.NoError(t, err)
+	require.Equal(t, "u1", got.URL)
+
+	got, err = SelectInput(files, "2")
+	require.NoError(t, err)
+	require.Equal(t, "u2", got.URL)
+
+	got, err = SelectInput(files, "b.png")
+	require.NoError(t, err)
+	require.Equal(t, "u2", got.URL)
+
+	_, err = SelectInput(files, "3")
+	require.Error(t, err)
+	_, err = SelectInput(files, "c.png")
+	require.ErrorContains(t, err, "`a.png`, `B.png`")
+	_, err = SelectInput(nil, "")
+	require.Error(t, err)
+}
+
+// fakeFetcher serves messages by ID, as if they were in the channel.
+type fakeFetcher map[string]*discordgo.Message
+
+func (f fakeFetcher) ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if message, ok := f[messageID]; ok {
+		return message, nil
+	}
+	return nil, errors.New("unknown message")
+}
+
+func replyTo(messageID string, attachments ...*discordgo.MessageAttachment) *discordgo.MessageCreate {
+	message := &discordgo.Message{ChannelID: "channel", Attachments: attachments}
+	if messageID != "" {
+		message.MessageReference = &discordgo.MessageReference{MessageID: messageID}
+	}
+	return &discordgo.MessageCreate{Message: message}
+}
+
+func TestResolveInput_RepliedToResult(t *testing.T) {
+	fetcher := fakeFetcher{"result": {Attachments: []*discordgo.MessageAttachment{
+		{Filename: "rain.wav", URL: "u1", ContentType: "audio/wav"},
+		{Filename: "rain.png", URL: "u2", ContentType: "image/png"},
+	}}}
+
+	audio, err := ResolveInput(fetcher, replyTo("result"), MessageAudio, "")
+	require.NoError(t, err)
+	require.Equal(t, "u1", audio.URL)
+
+	image, err := ResolveInput(fetcher, replyTo("result"), MessageImages, "")
+	require.NoError(t, err)
+	require.Equal(t, "u2", image.URL)
+}
+
+func TestResolveInput_OwnAttachmentsComeFirst(t *testing.T) {
+	fetcher := fakeFetcher{"result": {Attachments: []*discordgo.MessageAttachment{{Filename: "old.wav", URL: "u1"}}}}
+	message := replyTo("result", &discordgo.MessageAttachment{Filename: "a.wav", URL: "u2"}, &discordgo.MessageAttachment{Filename: "B.WAV", URL: "u3"})
+
+	got, err := ResolveInput(fetcher, message, MessageAudio, "")
+	require.NoError(t, err)
+	require.Equal(t, "u2", got.URL)
+
+	got, err = ResolveInput(fetcher, message, MessageAudio, "b.wav")
+	require.NoError(t, err)
+	require.Equal(t, "u3", got.URL)
+}
+
+func TestResolveInput_NothingToWorkOn(t *testing.T) {
+	fetcher := fakeFetcher{"chat": {Content: "nice"}}
+
+	_, err := ResolveInput(fetcher, replyTo(""), MessageAudio, "")
+	require.ErrorIs(t, err, ErrNoInput)
+
+	_, err = ResolveInput(fetcher, replyTo("chat"), MessageAudio, "")
+	require.ErrorIs(t, err, ErrNoInput)
+
+	_, err = ResolveInput(fetcher, replyTo("deleted"), MessageAudio, "")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrNoInput)
+}
@@ -0,0 +1,67 @@
+package helpers
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+
+	"slugbot/internal/config"
+)
+
+// renders holds a slot for each image render running, sized by the
+// configured limit when the first render starts.
+var renders struct {
+	sync.Mutex
+	slots chan struct{}
+}
+
+// RenderSlot waits until fewer than the configured number of image renders
+// are running, and returns the function that frees the slot it took. Every
+// magick and ffmpeg run of the image commands holds one, so a batch of
+// images can't starve everything else of the CPU.
+func RenderSlot() func() {
+	renders.Lock()
+	if renders.slots == nil {
+		n := config.Get().Limits.Renders
+		if n <= 0 {
+			n = runtime.NumCPU()
+		}
+		renders.slots = make(chan struct{}, n)
+	}
+	slots := renders.slots
+	renders.Unlock()
+
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+// MagickArgs puts the configured resource limits for a guild in front of the
+// arguments of a magick command.
+func MagickArgs(guildID string, args ...string) []string {
+	limits := config.Get().Limits.For(guildID)
+	var out []string
+	for _, limit := range []struct{ resource, value string }{
+		{"memory", limits.MagickMemory},
+		{"map", limits.MagickMap},
+		{"disk", limits.MagickDisk},
+	} {
+		if limit.value != "" {
+			out = append(out, "-limit", limit.resource, limit.value)
+		}
+	}
+	return append(out, args...)
+}
+
+// FFmpegArgs adds the configured thread limit for a guild to the arguments of
+// an ffmpeg command, which must end with its output file: the filter graphs
+// are limited up front and the encoder just before the output.
+func FFmpegArgs(guildID string, args ...string) []string {
+	threads := config.Get().Limits.For(guildID).FFmpegThreads
+	if threads <= 0 || len(args) == 0 {
+		return args
+	}
+	n := strconv.Itoa(threads)
+	out := []string{"-filter_threads", n, "-filter_complex_threads", n}
+	out = append(out, args[:len(args)-1]...)
+	return append(out, "-threads", n, args[len(args)-1])
+}
@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"slugbot/internal/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func setLimits(t *testing.T, limits config.Limits) {
+	cfg := config.Default()
+	cfg.Limits = limits
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+}
+
+func TestMagickArgs_AppliesGuildOverrides(t *testing.T) {
+	setLimits(t, config.Limits{
+		ToolLimits: config.ToolLimits{MagickMemory: "256MiB", MagickDisk: "1GiB"},
+		Guilds:     map[string]config.ToolLimits{"trusted": {MagickMemory: "2GiB"}},
+	})
+
+	require.Equal(t,
+		[]string{"-limit", "memory", "256MiB", "-limit", "disk", "1GiB", "in.png", "out.png"},
+		MagickArgs("other", "in.png", "out.png"))
+	require.Equal(t,
+		[]string{"-limit", "memory", "2GiB", "-limit", "disk", "1GiB", "in.png", "out.png"},
+		MagickArgs("trusted", "in.png", "out.png"))
+}
+
+func TestFFmpegArgs_LimitsThreadsBeforeOutput(t *testing.T) {
+	setLimits(t, config.Limits{Guilds: map[string]config.ToolLimits{"trusted": {FFmpegThreads: 8}}})
+
+	require.Equal(t, []string{"-i", "in.wav", "out.wav"}, FFmpegArgs("other", "-i", "in.wav", "out.wav"))
+	require.Equal(t,
+		[]string{"-filter_threads", "8", "-filter_complex_threads", "8", "-i", "in.wav", "-threads", "8", "out.wav"},
+		FFmpegArgs("trusted", "-i", "in.wav", "out.wav"))
+}
+
+func TestRenderSlot_WaitsForAFreeSlot(t *testing.T) {
+	setLimits(t, config.Limits{Renders: 1})
+	renders.slots = nil
+	t.Cleanup(func() { renders.slots = nil })
+
+	release := RenderSlot()
+	acquired := make(chan func())
+	go func() { acquired <- RenderSlot() }()
+	select {
+	case <-acquired:
+		t.Fatal("a second render started while the only slot was taken")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(time.Second):
+		t.Fatal("the waiting render didn't get the freed slot")
+	}
+}
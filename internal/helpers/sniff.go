@@ -0,0 +1,152 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// sniffLength is how many leading bytes are read to sniff a file's type;
+// http.DetectContentType never looks further.
+const sniffLength = 512
+
+// genericContentTypes are what CDNs send when they don't know what a file is.
+var genericContentTypes = map[string]bool{
+	"":                         true,
+	"application/octet-stream": true,
+	"binary/octet-stream":      true,
+	"application/binary":       true,
+	"application/unknown":      true,
+}
+
+// contentTypeAliases map the other names formats go by onto the ones
+// GetFileExtensionFromMimeType knows.
+var contentTypeAliases = map[string]string{
+	"audio/wave":          "audio/wav",
+	"audio/x-wav":         "audio/wav",
+	"audio/vnd.wave":      "audio/wav",
+	"audio/x-flac":        "audio/flac",
+	"audio/mp3":           "audio/mpeg",
+	"application/ogg":     "audio/ogg",
+	"image/jpg":           "image/jpeg",
+	"image/x-ms-bmp":      "image/bmp",
+	"video/x-msvideo":     "video/avi",
+	"video/x-matroska":    "video/mkv",
+	"image/heic-sequence": "image/heic",
+	"image/heif-sequence": "image/heif",
+}
+
+// magicNumber identifies a format by the bytes at an offset into the file.
+type magicNumber struct {
+	offset   int
+	magic    []byte
+	mimeType string
+}
+
+// magicNumbers cover formats http.DetectContentType doesn't know. ISO media
+// files (HEIC, AVIF, QuickTime) are told apart by the brand after "ftyp".
+var magicNumbers = []magicNumber{
+	{4, []byte("ftypheic"), "image/heic"},
+	{4, []byte("ftypheix"), "image/heic"},
+	{4, []byte("ftyphevc"), "image/heic"},
+	{4, []byte("ftypmif1"), "image/heif"},
+	{4, []byte("ftypmsf1"), "image/heif"},
+	{4, []byte("ftypavif"), "image/avif"},
+	{4, []byte("ftypavis"), "image/avif"},
+	{4, []byte("ftypqt  "), "video/quicktime"},
+	{0, []byte("II*\x00"), "image/tiff"},
+	{0, []byte("MM\x00*"), "image/tiff"},
+	{0, []byte("fLaC"), "audio/flac"},
+}
+
+// NormalizeContentType strips parameters such as "; charset=binary" from a
+// Content-Type and maps aliases like "audio/x-wav" onto one name.
+func NormalizeContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	if alias, ok := contentTypeAliases[mediaType]; ok {
+		return alias
+	}
+	return mediaType
+}
+
+// IsGenericContentType reports whether a Content-Type says nothing about the
+// file, so its type has to be worked out some other way.
+func IsGenericContentType(contentType string) bool {
+	return genericContentTypes[NormalizeContentType(contentType)]
+}
+
+// SniffContentType guesses a file's MIME type from its first bytes, using
+// magicNumbers and then http.DetectContentType. It returns
+// "application/octet-stream" if it can't tell.
+func SniffContentType(head []byte) string {
+	for _, m := range magicNumbers {
+		if len(head) >= m.offset+len(m.magic) && bytes.Equal(head[m.offset:m.offset+len(m.magic)], m.magic) {
+			return m.mimeType
+		}
+	}
+	return NormalizeContentType(http.DetectContentType(head))
+}
+
+// extensionContentTypes map file name extensions onto the MIME types
+// GetFileExtensionFromMimeType knows, for attachments without a useful one.
+var extensionContentTypes = map[string]string{
+	".gif":  "image/gif",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".heic": "image/heic",
+	".heif": "image/heif",
+	".avif": "image/avif",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".ogv":  "video/ogg",
+	".avi":  "video/avi",
+	".mkv":  "video/mkv",
+	".mov":  "video/quicktime",
+	".flv":  "video/x-flv",
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+	".wav":  "audio/wav",
+	".flac": "audio/flac",
+}
+
+// ContentTypeFromFilename guesses a MIME type from a file name's extension, or
+// returns "" if the extension isn't known.
+func ContentTypeFromFilename(name string) string {
+	return extensionContentTypes[strings.ToLower(filepath.Ext(name))]
+}
+
+// sniffURL reads the start of the content at url and sniffs its type.
+func sniffURL(url string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", sniffLength-1))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	// servers that ignore Range send the whole file, so only the start is read
+	head, err := io.ReadAll(io.LimitReader(resp.Body, sniffLength))
+	if err != nil {
+		return "", err
+	}
+	return SniffContentType(head), nil
+}
@@ -0,0 +1,78 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffContentType(t *testing.T) {
+	for name, tc := range map[string]struct {
+		head []byte
+		want string
+	}{
+		"png":  {[]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "image/png"},
+		"wav":  {[]byte("RIFF\x24\x08\x00\x00WAVEfmt "), "audio/wav"},
+		"ogg":  {[]byte("OggS\x00\x02\x00\x00"), "audio/ogg"},
+		"heic": {[]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"), "image/heic"},
+		"avif": {[]byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00"), "image/avif"},
+		"tiff": {[]byte("II*\x00\x08\x00\x00\x00"), "image/tiff"},
+		"flac": {[]byte("fLaC\x00\x00\x00\x22"), "audio/flac"},
+		"junk": {[]byte{0x00, 0x01, 0x02, 0x03}, "application/octet-stream"},
+	} {
+		require.Equal(t, tc.want, SniffContentType(tc.head), name)
+	}
+}
+
+func TestNormalizeContentType(t *testing.T) {
+	require.Equal(t, "audio/wav", NormalizeContentType("audio/x-wav"))
+	require.Equal(t, "image/png", NormalizeContentType("image/PNG; charset=binary"))
+	require.True(t, IsGenericContentType(""))
+	require.True(t, IsGenericContentType("binary/octet-stream"))
+	require.False(t, IsGenericContentType("image/png"))
+}
+
+func TestGetMimeTypeFromURL_SniffsGenericContentTypes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if r.Method == http.MethodGet {
+			w.Write([]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"))
+		}
+	}))
+	defer server.Close()
+
+	mimeType, err := GetMimeTypeFromURL(server.URL)
+	require.NoError(t, err)
+	require.Equal(t, "image/heic", mimeType)
+
+	ext, err := GetFileExtensionFromURL(server.URL)
+	require.NoError(t, err)
+	require.Equal(t, "heic", ext)
+}
+
+func TestGetMimeTypeFromURL_TrustsSpecificContentTypes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodHead, r.Method, "a specific type shouldn't need sniffing")
+		w.Header().Set("Content-Type", "audio/x-wav")
+	}))
+	defer server.Close()
+
+	mimeType, err := GetMimeTypeFromURL(server.URL)
+	require.NoError(t, err)
+	require.Equal(t, "audio/wav", mimeType)
+}
+
+func TestAttachmentsWithoutContentTypesRouteByName(t *testing.T) {
+	message := &discordgo.Message{Attachments: []*discordgo.MessageAttachment{
+		{Filename: "IMG_0001.HEIC", URL: "u1"},
+		{Filename: "loop.wav", URL: "u2", ContentType: "application/octet-stream"},
+		{Filename: "clip", URL: "u3", ContentType: "audio/wave"},
+		{Filename: "notes.txt", URL: "u4"},
+	}}
+
+	require.Equal(t, []InputFile{{Name: "IMG_0001.HEIC", URL: "u1"}}, MessageImages(message))
+	require.Equal(t, []InputFile{{Name: "loop.wav", URL: "u2"}, {Name: "clip", URL: "u3"}}, MessageAudio(message))
+}
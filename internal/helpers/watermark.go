@@ -0,0 +1,120 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"slugbot/internal/cmdline"
+	"slugbot/internal/config"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/tools"
+)
+
+// watermarkGravities are magick's -gravity for each corner a watermark can go in.
+var watermarkGravities = map[string]string{
+	"northwest": "NorthWest",
+	"northeast": "NorthEast",
+	"southwest": "SouthWest",
+	"southeast": "SouthEast",
+}
+
+// watermarkMargin is how far a watermark sits from the edges, as a -geometry offset.
+const watermarkMargin = "+8+8"
+
+type noWatermarkKey struct{}
+
+// WithoutWatermark returns a copy of ctx whose command's results go out
+// without a watermark, for an admin's `--no-watermark`.
+func WithoutWatermark(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noWatermarkKey{}, true)
+}
+
+// watermarkFor returns the watermark the guild's results get, and whether
+// they get one at all.
+func watermarkFor(ctx context.Context, guildID string) (config.ImageWatermark, bool) {
+	if skip, _ := ctx.Value(noWatermarkKey{}).(bool); skip {
+		return config.ImageWatermark{}, false
+	}
+	watermark := config.Get().Branding.For(guildID)
+	return watermark, !watermark.Off && (watermark.Logo != "" || watermark.Text != "")
+}
+
+// WatermarkOps returns the magick operators that stamp the guild's watermark
+// (see config.Branding) on the image before them, to go just before the
+// output file, or nil if its results get none.
+func WatermarkOps(ctx context.Context, guildID string) ([]string, error) {
+	watermark, ok := watermarkFor(ctx, guildID)
+	if !ok {
+		return nil, nil
+	}
+	return watermarkOps(watermark, false)
+}
+
+// watermarkOps returns the operators that stamp watermark on the image before
+// them, or on every frame of an animation that's been coalesced.
+func watermarkOps(watermark config.ImageWatermark, animated bool) ([]string, error) {
+	gravity, ok := watermarkGravities[strings.ToLower(watermark.Corner)]
+	if !ok {
+		return nil, fmt.Errorf("a watermark's corner must be northwest, northeast, southwest, or southeast, not %q", watermark.Corner)
+	}
+	size := fmt.Sprintf("%d", max(watermark.Size, 1))
+
+	if watermark.Logo != "" {
+		logo := []string{"(", cmdline.MagickInput(watermark.Logo), "-resize", size + "x" + size + ">", ")", "-gravity", gravity, "-geometry", watermarkMargin}
+		if animated {
+			// null: separates the frames from the logo they're each composited with
+			return append(append([]string{"null:"}, logo...), "-layers", "composite"), nil
+		}
+		return append(logo, "-composite"), nil
+	}
+
+	return []string{
+		"-gravity", gravity, "-pointsize", size,
+		"-fill", "rgba(255,255,255,0.8)", "-stroke", "rgba(0,0,0,0.6)", "-strokewidth", "1",
+		"-annotate", watermarkMargin, cmdline.MagickText(watermark.Text),
+	}, nil
+}
+
+// Watermark stamps the guild's watermark on the image or GIF at path, in
+// place, unless ctx was made WithoutWatermark or the guild's results get none.
+func Watermark(ctx context.Context, guildID string, path string) error {
+	watermark, ok := watermarkFor(ctx, guildID)
+	if !ok {
+		return nil
+	}
+	animated := strings.EqualFold(filepath.Ext(path), ".gif")
+	ops, err := watermarkOps(watermark, animated)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(path), "watermarked-*"+filepath.Ext(path))
+	if err != nil {
+		return fmt.Errorf("error creating watermarked file: %w", err)
+	}
+	out.Close()
+
+	args := []string{cmdline.MagickInput(path)}
+	if animated {
+		args = append(args, "-coalesce")
+	}
+	args = append(args, ops...)
+	if animated {
+		args = append(args, "-layers", "optimize")
+	}
+	command := exec.Command(tools.Path(tools.Magick), MagickArgs(guildID, append(args, out.Name())...)...)
+	slog.Trace(fmt.Sprintf("Running command: %s", strings.Join(command.Args, " ")))
+	if output, err := command.CombinedOutput(); err != nil {
+		os.Remove(out.Name())
+		return fmt.Errorf("failed to watermark image: %w\nOutput: %s", err, string(output))
+	}
+	if err := os.Rename(out.Name(), path); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	return nil
+}
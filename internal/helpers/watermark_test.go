@@ -0,0 +1,60 @@
+package helpers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"slugbot/internal/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatermarkOps(t *testing.T) {
+	ops, err := watermarkOps(config.ImageWatermark{Text: "@100% slug", Corner: "NorthWest", Size: 20}, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"-gravity", "NorthWest", "-pointsize", "20"}, ops[:4])
+	require.Equal(t, `\@100%% slug`, ops[len(ops)-1])
+
+	ops, err = watermarkOps(config.ImageWatermark{Text: "ignored", Logo: "/srv/logo.png", Corner: "southeast", Size: 32}, true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"null:", "(", "png:/srv/logo.png", "-resize", "32x32>", ")", "-gravity", "SouthEast", "-geometry", "+8+8", "-layers", "composite"}, ops)
+
+	_, err = watermarkOps(config.ImageWatermark{Text: "slug", Corner: "middle"}, false)
+	require.Error(t, err)
+}
+
+func TestWatermark_FollowsGuildAndContext(t *testing.T) {
+	calls := fakeMagick(t)
+	cfg := config.Get()
+	cfg.Branding = config.Branding{
+		ImageWatermark: config.ImageWatermark{Text: "slugbot", Corner: "southeast", Size: 24},
+		Guilds: map[string]config.ImageWatermark{
+			"plain": {Off: true},
+			"own":   {Text: "own text"},
+		},
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.png")
+	require.NoError(t, os.WriteFile(path, []byte("result"), 0o644))
+
+	require.NoError(t, Watermark(context.Background(), "plain", path))
+	require.NoError(t, Watermark(WithoutWatermark(context.Background()), "other", path))
+	_, err := os.Stat(calls)
+	require.True(t, os.IsNotExist(err), "nothing should have been stamped")
+
+	require.NoError(t, Watermark(context.Background(), "own", path))
+	logged, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	require.Contains(t, string(logged), "own text")
+	require.True(t, strings.HasPrefix(string(logged), "png:"+path+" -gravity SouthEast"))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "result", string(content))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
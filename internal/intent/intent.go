@@ -0,0 +1,108 @@
+package intent
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Request is a generation request interpreted from free-form text.
+type Request struct {
+	Prompt   string   `json:"prompt"`
+	Negative []string `json:"negative"`
+	Length   float64  `json:"length"` // seconds; 0 means the command's default
+	Steps    int64    `json:"steps"`  // 0 means the command's default
+	Seed     int64    `json:"seed"`   // 0 means random
+	Small    bool     `json:"small"`
+}
+
+var (
+	mentionRegex  = regexp.MustCompile(`<@!?\d+>`)
+	lengthRegex   = regexp.MustCompile(`(?i)\b(\d+(?:\.\d+)?)\s*(seconds?|secs?|s|minutes?|mins?)\b(\s+of\b)?`)
+	aMinuteRegex  = regexp.MustCompile(`(?i)\b(half\s+a|a)\s+minute\b(\s+of\b)?`)
+	stepsRegex    = regexp.MustCompile(`(?i)\b(?:with\s+|using\s+)?(\d+)\s*(?:diffusion\s+)?steps?\b`)
+	seedRegex     = regexp.MustCompile(`(?i)\b(?:with\s+|using\s+)?seed\s*(?:of|=|:|#)?\s*(\d+)\b`)
+	negativeRegex = regexp.MustCompile(`(?i)\b(?:without|no)\s+(.+?)(?:$|[.;!]|\s+but\b|\s+with\b)`)
+	smallRegex    = regexp.MustCompile(`(?i)\b(quick|quickly|fast|draft|small)\b`)
+	fillerRegex   = regexp.MustCompile(`(?i)^(?:hey\s+|hi\s+)?(?:please\s+)?(?:can|could|would)?\s*(?:you\s+)?(?:please\s+)?(?:make|generate|create|give|produce|compose|render|write)?\s*(?:me|us)?\s*(?:up\s+)?(?:some|an?)?\s+`)
+	listSplit     = regexp.MustCompile(`\s*(?:,|\band\b|\bor\b)\s*`)
+	spaceRegex    = regexp.MustCompile(`\s+`)
+)
+
+// Interpret extracts generation parameters from a message such as
+// "@slugbot make me 20 seconds of rainy jazz without drums".
+func Interpret(text string) (*Request, error) {
+	req := &Request{}
+	text = mentionRegex.ReplaceAllString(text, " ")
+
+	if m := lengthRegex.FindStringSubmatch(text); m != nil {
+		length, _ := strconv.ParseFloat(m[1], 64)
+		if strings.HasPrefix(strings.ToLower(m[2]), "m") {
+			length *= 60
+		}
+		req.Length = length
+		text = strings.Replace(text, m[0], " ", 1)
+	} else if m := aMinuteRegex.FindStringSubmatch(text); m != nil {
+		req.Length = 60
+		if strings.HasPrefix(strings.ToLower(m[1]), "half") {
+			req.Length = 30
+		}
+		text = strings.Replace(text, m[0], " ", 1)
+	}
+
+	if m := stepsRegex.FindStringSubmatch(text); m != nil {
+		req.Steps, _ = strconv.ParseInt(m[1], 10, 64)
+		text = strings.Replace(text, m[0], " ", 1)
+	}
+
+	if m := seedRegex.FindStringSubmatch(text); m != nil {
+		req.Seed, _ = strconv.ParseInt(m[1], 10, 64)
+		text = strings.Replace(text, m[0], " ", 1)
+	}
+
+	if m := negativeRegex.FindStringSubmatch(text); m != nil {
+		for _, item := range listSplit.Split(m[1], -1) {
+			if item = strings.TrimSpace(item); item != "" {
+				req.Negative = append(req.Negative, item)
+			}
+		}
+		text = strings.Replace(text, strings.TrimRight(m[0], ".;!"), " ", 1)
+	}
+
+	if smallRegex.MatchString(text) {
+		req.Small = true
+		text = smallRegex.ReplaceAllString(text, " ")
+	}
+
+	text = strings.TrimSpace(spaceRegex.ReplaceAllString(text, " "))
+	text = strings.TrimSpace(fillerRegex.ReplaceAllString(text+" ", ""))
+	text = strings.Trim(text, " .!?,")
+	if text == "" {
+		return nil, fmt.Errorf("couldn't find anything to generate in that message")
+	}
+	req.Prompt = text
+
+	return req, nil
+}
+
+// Command renders the request as the equivalent `.saudio` command.
+func (r *Request) Command() string {
+	parts := []string{".saudio", r.Prompt}
+	if r.Length > 0 {
+		parts = append(parts, "--length", strconv.FormatFloat(r.Length, 'f', -1, 64))
+	}
+	if r.Steps > 0 {
+		parts = append(parts, "--steps", strconv.FormatInt(r.Steps, 10))
+	}
+	if r.Seed > 0 {
+		parts = append(parts, "--seed", strconv.FormatInt(r.Seed, 10))
+	}
+	if r.Small {
+		parts[0] = ".saudiosm"
+	}
+	if len(r.Negative) > 0 {
+		parts = append(parts, "--negative", strings.Join(r.Negative, " "))
+	}
+	return strings.Join(parts, " ")
+}
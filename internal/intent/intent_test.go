@@ -0,0 +1,33 @@
+package intent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpret(t *testing.T) {
+	cases := []struct {
+		text    string
+		command string
+	}{
+		{"<@123> make me 20 seconds of rainy jazz", ".saudio rainy jazz --length 20"},
+		{"<@!123> can you generate a minute of lofi hip hop without drums or vocals", ".saudio lofi hip hop --length 60 --negative drums vocals"},
+		{"<@123> quick 10s of birdsong, seed 42", ".saudiosm birdsong --length 10 --seed 42"},
+		{"<@123> please compose some ambient drones with 50 steps", ".saudio ambient drones --steps 50"},
+		{"<@123> techno kick loop 128 bpm", ".saudio techno kick loop 128 bpm"},
+		{"<@123> 2 minutes of ocean waves", ".saudio ocean waves --length 120"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.text, func(t *testing.T) {
+			req, err := Interpret(tc.text)
+			require.NoError(t, err)
+			require.Equal(t, tc.command, req.Command())
+		})
+	}
+}
+
+func TestInterpret_NothingToGenerate(t *testing.T) {
+	_, err := Interpret("<@123> make me 20 seconds of")
+	require.Error(t, err)
+}
@@ -0,0 +1,52 @@
+package intent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"slugbot/internal/llm"
+)
+
+const interpretSystemPrompt = `You turn requests for generated audio into JSON for a text-to-audio model.
+Reply with a single JSON object and nothing else, using these fields:
+  "prompt":   string, a concise description of the sound (required)
+  "negative": array of strings, things the user doesn't want to hear
+  "length":   number, seconds of audio (0 if unspecified)
+  "steps":    integer, diffusion steps (0 if unspecified)
+  "seed":     integer (0 if unspecified)
+  "small":    boolean, true if the user wants a quick/draft result`
+
+// InterpretWithLLM asks an LLM to extract the request, falling back to the
+// rule-based Interpret if the endpoint fails or replies with something unusable.
+func InterpretWithLLM(ctx context.Context, client *llm.Client, text string) (*Request, error) {
+	if client == nil {
+		return Interpret(text)
+	}
+
+	reply, err := client.Complete(ctx, interpretSystemPrompt, mentionRegex.ReplaceAllString(text, " "))
+	if err == nil {
+		if req, parseErr := parseLLMReply(reply); parseErr == nil {
+			return req, nil
+		}
+	}
+	return Interpret(text)
+}
+
+func parseLLMReply(reply string) (*Request, error) {
+	// models like to wrap JSON in a fenced block
+	reply = strings.TrimSpace(reply)
+	reply = strings.TrimPrefix(reply, "```json")
+	reply = strings.Trim(reply, "`\n ")
+
+	var req Request
+	if err := json.Unmarshal([]byte(reply), &req); err != nil {
+		return nil, fmt.Errorf("couldn't decode LLM reply: %w", err)
+	}
+	req.Prompt = strings.TrimSpace(req.Prompt)
+	if req.Prompt == "" || req.Length < 0 || req.Steps < 0 || req.Seed < 0 {
+		return nil, fmt.Errorf("LLM reply is missing a prompt or has invalid values")
+	}
+	return &req, nil
+}
@@ -0,0 +1,260 @@
+// Package logfile writes the log to a file that's rotated when it gets too big
+// or too old, with the rotated files gzipped and deleted after a while, so a
+// long-running bot keeps its history without filling the disk.
+package logfile
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTime is how a rotated file's name records when it was rotated, e.g.
+// "slugbot-2026-03-01T12-00-00.000.log". It sorts in time order and is safe on
+// every file system.
+const backupTime = "2006-01-02T15-04-05.000"
+
+// Writer appends to the file at Path, first moving it aside as a backup when
+// it's reached MaxSize or has been written to for MaxAge. Backups are gzipped
+// if Compress is set, and deleted once there are more than Keep of them or
+// they're older than Retain. The zero value of each limit disables it.
+type Writer struct {
+	Path     string
+	MaxSize  int64
+	MaxAge   time.Duration
+	Compress bool
+	Keep     int
+	Retain   time.Duration
+
+	mutex  sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	// backups are compressed and cleaned up in the background, one rotation at a time
+	maintenance sync.WaitGroup
+	maintaining sync.Mutex
+
+	now func() time.Time // for tests
+}
+
+// Open opens the log file, if it isn't already, so a file that can't be
+// written is found out before anything is logged to it.
+func (w *Writer) Open() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file != nil {
+		return nil
+	}
+	return w.openLocked()
+}
+
+// Write appends p to the log file, rotating it first if it's due.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		if err := w.openLocked(); err != nil {
+			return 0, err
+		}
+	}
+	if w.dueLocked(int64(len(p))) {
+		if err := w.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate moves the current log file aside and starts a new one.
+func (w *Writer) Rotate() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.rotateLocked()
+}
+
+// Close closes the log file, after waiting for any backups still being
+// compressed or cleaned up.
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.maintenance.Wait()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// dueLocked reports whether writing n more bytes calls for a new file. A
+// write that's too big for any file goes into an empty one rather than
+// rotating it again.
+func (w *Writer) dueLocked(n int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.MaxSize > 0 && w.size+n > w.MaxSize {
+		return true
+	}
+	return w.MaxAge > 0 && w.clock().Sub(w.opened) >= w.MaxAge
+}
+
+// openLocked opens the log file for appending. A file left from an earlier
+// run counts as opened when it was last written, so restarts don't keep it
+// from rotating.
+func (w *Writer) openLocked() error {
+	if err := os.MkdirAll(filepath.Dir(w.Path), 0o755); err != nil {
+		return fmt.Errorf("couldn't create log directory: %w", err)
+	}
+	file, err := os.OpenFile(w.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("couldn't open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("couldn't open log file: %w", err)
+	}
+	w.file, w.size, w.opened = file, info.Size(), w.clock()
+	if info.Size() > 0 {
+		w.opened = info.ModTime()
+	}
+	return nil
+}
+
+func (w *Writer) rotateLocked() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("couldn't close log file: %w", err)
+		}
+		w.file = nil
+	}
+	backup := w.backupName(w.clock())
+	if err := os.Rename(w.Path, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("couldn't rotate log file: %w", err)
+	}
+	if err := w.openLocked(); err != nil {
+		return err
+	}
+	w.opened = w.clock()
+
+	now := w.clock()
+	w.maintenance.Add(1)
+	go func() {
+		defer w.maintenance.Done()
+		w.maintaining.Lock()
+		defer w.maintaining.Unlock()
+		if w.Compress {
+			if err := compress(backup); err != nil {
+				// the log can't report on itself, so stderr it is
+				fmt.Fprintln(os.Stderr, "couldn't compress rotated log: ", err)
+			}
+		}
+		if err := w.cleanUp(now); err != nil {
+			fmt.Fprintln(os.Stderr, "couldn't clean up rotated logs: ", err)
+		}
+	}()
+	return nil
+}
+
+// backupName names the backup the log file is moved to when it's rotated at t.
+func (w *Writer) backupName(t time.Time) string {
+	ext := filepath.Ext(w.Path)
+	return strings.TrimSuffix(w.Path, ext) + "-" + t.UTC().Format(backupTime) + ext
+}
+
+// backup is a rotated log file, compressed or not.
+type backup struct {
+	path    string
+	rotated time.Time
+}
+
+// backups lists the rotated log files, newest first.
+func (w *Writer) backups() ([]backup, error) {
+	dir := filepath.Dir(w.Path)
+	ext := filepath.Ext(w.Path)
+	prefix := strings.TrimSuffix(filepath.Base(w.Path), ext) + "-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz"), ext)
+		rotated, err := time.Parse(backupTime, stamp)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, name), rotated: rotated})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].rotated.After(backups[j].rotated) })
+	return backups, nil
+}
+
+// cleanUp deletes the backups past Keep or older than Retain.
+func (w *Writer) cleanUp(now time.Time) error {
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, b := range backups {
+		if (w.Keep > 0 && i >= w.Keep) || (w.Retain > 0 && now.Sub(b.rotated) > w.Retain) {
+			if err := os.Remove(b.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compress gzips a file into path.gz and removes the original.
+func compress(path string) error {
+	in, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (w *Writer) clock() time.Time {
+	if w.now != nil {
+		return w.now()
+	}
+	return time.Now()
+}
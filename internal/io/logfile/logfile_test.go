@@ -0,0 +1,106 @@
+package logfile
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newWriter(t *testing.T, configure func(w *Writer)) (*Writer, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	w := &Writer{Path: filepath.Join(t.TempDir(), "logs", "slugbot.log"), now: clock.now}
+	configure(w)
+	t.Cleanup(func() { w.Close() })
+	return w, clock
+}
+
+func write(t *testing.T, w *Writer, line string) {
+	_, err := io.WriteString(w, line)
+	require.NoError(t, err)
+}
+
+func TestWriter_RotatesBySize(t *testing.T) {
+	w, clock := newWriter(t, func(w *Writer) { w.MaxSize = 10 })
+
+	write(t, w, "1234\n")
+	write(t, w, "1234\n")
+	clock.advance(time.Second)
+	write(t, w, "next\n")
+	require.NoError(t, w.Close())
+
+	current, err := os.ReadFile(w.Path)
+	require.NoError(t, err)
+	require.Equal(t, "next\n", string(current))
+	backups, err := w.backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	require.Equal(t, filepath.Join(filepath.Dir(w.Path), "slugbot-2026-03-01T12-00-01.000.log"), backups[0].path)
+	rotated, err := os.ReadFile(backups[0].path)
+	require.NoError(t, err)
+	require.Equal(t, "1234\n1234\n", string(rotated))
+
+	// a line too big for any file still gets written, into a file of its own
+	w.MaxSize = 2
+	write(t, w, "too big\n")
+	current, err = os.ReadFile(w.Path)
+	require.NoError(t, err)
+	require.Equal(t, "too big\n", string(current))
+}
+
+func TestWriter_RotatesByAgeAndCompresses(t *testing.T) {
+	w, clock := newWriter(t, func(w *Writer) {
+		w.MaxAge = time.Hour
+		w.Compress = true
+	})
+
+	write(t, w, "old\n")
+	clock.advance(59 * time.Minute)
+	write(t, w, "still old\n")
+	clock.advance(time.Minute)
+	write(t, w, "new\n")
+	require.NoError(t, w.Close())
+
+	backups, err := w.backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	require.Equal(t, ".gz", filepath.Ext(backups[0].path))
+	file, err := os.Open(backups[0].path)
+	require.NoError(t, err)
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	rotated, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, "old\nstill old\n", string(rotated))
+}
+
+func TestWriter_KeepsOnlyRecentBackups(t *testing.T) {
+	w, clock := newWriter(t, func(w *Writer) {
+		w.Keep = 3
+		w.Retain = 90 * time.Minute
+	})
+
+	for range 5 {
+		write(t, w, "line\n")
+		clock.advance(time.Hour)
+		require.NoError(t, w.Rotate())
+		w.maintenance.Wait()
+	}
+	backups, err := w.backups()
+	require.NoError(t, err)
+	// three would be kept, but only two are young enough
+	require.Len(t, backups, 2)
+	require.Equal(t, clock.t, backups[0].rotated)
+}
@@ -1,6 +1,8 @@
 package slog
 
 import (
+	"fmt"
+	"io"
 	"log"
 	"os"
 )
@@ -25,6 +27,11 @@ func SetLevel(lvl int) {
 	currentLevel = lvl
 }
 
+// AlsoWriteTo sends every line to w as well as stderr, e.g. to keep a log file.
+func AlsoWriteTo(w io.Writer) {
+	log.SetOutput(io.MultiWriter(os.Stderr, w))
+}
+
 func trace(v ...interface{}) {
 	if LevelTrace >= currentLevel {
 		log.SetPrefix("TRACE: ")
@@ -66,3 +73,36 @@ var (
 	Error = errorLog
 	Fatal = fatal
 )
+
+// Logger prefixes every line with a fixed set of key=value fields, so related
+// log lines (e.g. everything belonging to one job) can be grepped together.
+type Logger struct {
+	fields string
+}
+
+// With returns a Logger that tags each line with key=value.
+func With(key string, value interface{}) *Logger {
+	return (&Logger{}).With(key, value)
+}
+
+// With returns a copy of the Logger with an additional key=value field.
+func (l *Logger) With(key string, value interface{}) *Logger {
+	field := fmt.Sprintf("%s=%v", key, value)
+	if l == nil || l.fields == "" {
+		return &Logger{fields: field}
+	}
+	return &Logger{fields: l.fields + " " + field}
+}
+
+func (l *Logger) withFields(v []interface{}) []interface{} {
+	if l == nil || l.fields == "" {
+		return v
+	}
+	return append([]interface{}{"[" + l.fields + "]"}, v...)
+}
+
+func (l *Logger) Trace(v ...interface{}) { trace(l.withFields(v)...) }
+func (l *Logger) Debug(v ...interface{}) { debug(l.withFields(v)...) }
+func (l *Logger) Info(v ...interface{})  { info(l.withFields(v)...) }
+func (l *Logger) Warn(v ...interface{})  { warn(l.withFields(v)...) }
+func (l *Logger) Error(v ...interface{}) { errorLog(l.withFields(v)...) }
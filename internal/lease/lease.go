@@ -0,0 +1,175 @@
+// Package lease makes sure only one bot process at a time runs against a
+// store, and lets a new process ask the one that's running to hand over to
+// it, e.g. during a deploy. The store caches what it reads, so two processes
+// sharing one would overwrite each other's changes.
+package lease
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	leaseFile = "slugbot.lease"
+	lockFile  = "slugbot.lease.lock"
+
+	// staleLock is how old a lock file can get before it's taken to be left
+	// by a process that died while holding it; the lock is only ever held
+	// for one read and write of the lease.
+	staleLock = 10 * time.Second
+)
+
+// ErrHeld is returned by Acquire while another process holds the lease.
+var ErrHeld = errors.New("lease is held by another process")
+
+// ErrLost is returned by Renew once the lease has gone to another process,
+// e.g. because it expired while this one was stuck.
+var ErrLost = errors.New("lease was taken by another process")
+
+// State is what the lease file says.
+type State struct {
+	Owner     string    `json:"owner,omitempty"`     // who holds it; empty once it's released
+	Expires   time.Time `json:"expires"`             // when it lapses if it isn't renewed
+	Handoff   string    `json:"handoff,omitempty"`   // who asked to take over, if anyone
+	Requested time.Time `json:"requested,omitempty"` // when they asked
+}
+
+// Lease is one process's claim on a store directory. The holder renews it
+// well within TTL, so it only lapses if the holder dies.
+type Lease struct {
+	Dir   string        // the store's directory
+	Owner string        // who this process is, e.g. "host:1234"
+	TTL   time.Duration // how long the lease lasts without being renewed
+
+	now func() time.Time // for tests; time.Now if nil
+}
+
+// Acquire takes the lease if it's free: nobody holds it, or its holder let it
+// lapse. A lease that's been handed over to another process stays reserved
+// for it for a TTL. Otherwise Acquire returns an error wrapping ErrHeld that
+// names the holder.
+func (l *Lease) Acquire() error {
+	return l.locked(func(state *State) error {
+		now := l.clock()
+		if state.Owner != "" && state.Owner != l.Owner && now.Before(state.Expires) {
+			return fmt.Errorf("%w: %s", ErrHeld, state.Owner)
+		}
+		if state.Handoff != "" && state.Handoff != l.Owner && now.Sub(state.Requested) < l.TTL {
+			return fmt.Errorf("%w: it's being handed over to %s", ErrHeld, state.Handoff)
+		}
+		*state = State{Owner: l.Owner, Expires: now.Add(l.TTL)}
+		return nil
+	})
+}
+
+// Renew extends the lease by a TTL, and reports whether another process has
+// asked to take over within the last TTL. A process waiting to take over
+// keeps asking, so one that gave up isn't handed over to.
+func (l *Lease) Renew() (handoff bool, err error) {
+	err = l.locked(func(state *State) error {
+		if state.Owner != l.Owner {
+			return ErrLost
+		}
+		now := l.clock()
+		state.Expires = now.Add(l.TTL)
+		handoff = state.Handoff != "" && state.Handoff != l.Owner && now.Sub(state.Requested) < l.TTL
+		return nil
+	})
+	return handoff, err
+}
+
+// Request asks the holder, if there is one, to hand the lease over to this
+// process once it's done. Acquire takes it after that. The request lapses
+// after a TTL unless it's made again.
+func (l *Lease) Request() error {
+	return l.locked(func(state *State) error {
+		state.Handoff, state.Requested = l.Owner, l.clock()
+		return nil
+	})
+}
+
+// Release gives up the lease, if this process holds it, so whoever asked for
+// it, or the next process to start, can take it straight away.
+func (l *Lease) Release() error {
+	return l.locked(func(state *State) error {
+		if state.Owner != l.Owner {
+			return nil
+		}
+		state.Owner, state.Expires = "", time.Time{}
+		return nil
+	})
+}
+
+// locked reads the lease, lets update change it, and writes it back if
+// update succeeds, all while holding the lock file, so processes can't
+// interleave their changes.
+func (l *Lease) locked(update func(state *State) error) error {
+	if err := os.MkdirAll(l.Dir, 0o755); err != nil {
+		return fmt.Errorf("couldn't create lease directory: %w", err)
+	}
+	unlock, err := l.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var state State
+	path := filepath.Join(l.Dir, leaseFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("couldn't read lease: %w", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("couldn't read lease: %w", err)
+	}
+
+	if err := update(&state); err != nil {
+		return err
+	}
+	if data, err = json.Marshal(state); err != nil {
+		return fmt.Errorf("couldn't encode lease: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("couldn't write lease: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("couldn't write lease: %w", err)
+	}
+	return nil
+}
+
+// lock creates the lock file, waiting for another process to remove it, or
+// for it to go stale, first.
+func (l *Lease) lock() (unlock func(), err error) {
+	path := filepath.Join(l.Dir, lockFile)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("couldn't lock lease: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (l *Lease) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,75 @@
+package lease
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLease_OneHolderAtATime(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	clock := func() time.Time { return now }
+	old := &Lease{Dir: dir, Owner: "old", TTL: time.Minute, now: clock}
+	other := &Lease{Dir: dir, Owner: "new", TTL: time.Minute, now: clock}
+
+	require.NoError(t, old.Acquire())
+	require.ErrorIs(t, other.Acquire(), ErrHeld)
+	handoff, err := old.Renew()
+	require.NoError(t, err)
+	require.False(t, handoff)
+
+	// a holder that stops renewing loses it
+	now = now.Add(2 * time.Minute)
+	require.NoError(t, other.Acquire())
+	_, err = old.Renew()
+	require.ErrorIs(t, err, ErrLost)
+
+	// and a released lease is free straight away
+	require.NoError(t, other.Release())
+	require.NoError(t, old.Acquire())
+}
+
+func TestLease_Handoff(t *testing.T) {
+	dir := t.TempDir()
+	old := &Lease{Dir: dir, Owner: "old", TTL: time.Minute}
+	other := &Lease{Dir: dir, Owner: "new", TTL: time.Minute}
+	third := &Lease{Dir: dir, Owner: "third", TTL: time.Minute}
+
+	require.NoError(t, old.Acquire())
+	require.NoError(t, other.Request())
+	handoff, err := old.Renew()
+	require.NoError(t, err)
+	require.True(t, handoff)
+	require.ErrorIs(t, other.Acquire(), ErrHeld)
+
+	// once it's released, it's kept for whoever asked
+	require.NoError(t, old.Release())
+	require.ErrorIs(t, third.Acquire(), ErrHeld)
+	require.NoError(t, other.Acquire())
+	handoff, err = other.Renew()
+	require.NoError(t, err)
+	require.False(t, handoff)
+}
+
+func TestLease_HandoffRequestsLapse(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	clock := func() time.Time { return now }
+	old := &Lease{Dir: dir, Owner: "old", TTL: time.Minute, now: clock}
+	other := &Lease{Dir: dir, Owner: "new", TTL: time.Minute, now: clock}
+
+	require.NoError(t, old.Acquire())
+	require.NoError(t, other.Request())
+
+	// a process that stopped asking isn't waited for
+	now = now.Add(30 * time.Second)
+	handoff, err := old.Renew()
+	require.NoError(t, err)
+	require.True(t, handoff)
+	now = now.Add(time.Minute)
+	handoff, err = old.Renew()
+	require.NoError(t, err)
+	require.False(t, handoff)
+}
@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"slugbot/internal/config"
+	"slugbot/internal/secrets"
+)
+
+// Client talks to an OpenAI-compatible chat completions endpoint (OpenAI,
+// llama.cpp server, Ollama, vLLM, ...).
+type Client struct {
+	Endpoint string // full URL of the chat completions route
+	Model    string
+	APIKey   string
+	HTTP     *http.Client
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// NewClient returns a client for the configured endpoint, or nil if none is configured.
+func NewClient(cfg config.LLM) *Client {
+	if cfg.Endpoint == "" {
+		return nil
+	}
+	// an explicitly named variable wins over the secrets store
+	apiKey := os.Getenv(cfg.APIKeyEnv)
+	if cfg.APIKeyEnv == "" {
+		apiKey, _ = secrets.Get(secrets.LLMAPIKey)
+	}
+	return &Client{
+		Endpoint: cfg.Endpoint,
+		Model:    cfg.Model,
+		APIKey:   apiKey,
+		HTTP:     &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Complete sends a system and user message and returns the model's reply text.
+func (c *Client) Complete(ctx context.Context, system string, user string) (string, error) {
+	if c == nil {
+		return "", fmt.Errorf("no LLM endpoint is configured")
+	}
+
+	body, err := json.Marshal(chatRequest{
+		Model: c.Model,
+		Messages: []chatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
+		},
+		Temperature: 0.7,
+	})
+	if err != nil {
+		return "", fmt.Errorf("couldn't encode LLM request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("couldn't create LLM request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("LLM request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("couldn't read LLM response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("LLM endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var parsed chatResponse
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return "", fmt.Errorf("couldn't decode LLM response: %w", err)
+	}
+	if len(parsed.Choices) == 0 {
+		return "", fmt.Errorf("LLM response had no choices")
+	}
+	return strings.TrimSpace(parsed.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const enhanceSystemPrompt = `You rewrite short requests into richer prompts for a text-to-audio diffusion model.
+Keep the user's intent, genre, and any specifics they gave. Add concrete musical or sonic
+detail: instrumentation, tempo in BPM, mood, texture, recording quality. Reply with only
+the rewritten prompt on a single line, at most 60 words, without quotes.`
+
+// maxEnhancedPromptLength keeps a rambling model from producing an unusable prompt.
+const maxEnhancedPromptLength = 500
+
+// EnhancePrompt asks the model to expand a terse prompt into a more descriptive one.
+func (c *Client) EnhancePrompt(ctx context.Context, prompt string) (string, error) {
+	reply, err := c.Complete(ctx, enhanceSystemPrompt, prompt)
+	if err != nil {
+		return "", err
+	}
+
+	enhanced := strings.Join(strings.Fields(strings.Trim(reply, "\"'`")), " ")
+	if enhanced == "" {
+		return "", fmt.Errorf("LLM returned an empty prompt")
+	}
+	if len([]rune(enhanced)) > maxEnhancedPromptLength {
+		enhanced = string([]rune(enhanced)[:maxEnhancedPromptLength])
+	}
+	return enhanced, nil
+}
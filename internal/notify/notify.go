@@ -0,0 +1,242 @@
+// Package notify tells users their jobs have finished, in whichever way each
+// of them picked with `.sprefs notify`: a DM, a mention in the job's channel,
+// a POST to their own webhook, or an email.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"slugbot/internal/discord"
+	"slugbot/internal/format"
+	"slugbot/internal/prefs"
+)
+
+// Notice describes a finished job.
+type Notice struct {
+	UserID    string
+	GuildID   string
+	ChannelID string
+	MessageID string // the message that started the job
+	Prompt    string
+	Err       error // nil if the job succeeded
+	Took      time.Duration
+}
+
+// Link returns a link to the message that started the job.
+func (n Notice) Link() string {
+	guildID := n.GuildID
+	if guildID == "" {
+		guildID = "@me"
+	}
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildID, n.ChannelID, n.MessageID)
+}
+
+// Text sums the notice up in a line.
+func (n Notice) Text() string {
+	outcome := "finished"
+	if n.Err != nil {
+		outcome = "failed"
+	}
+	prompt := strings.ReplaceAll(n.Prompt, "`", "'")
+	if runes := []rune(prompt); len(runes) > 100 {
+		prompt = string(runes[:99]) + "…"
+	}
+	return fmt.Sprintf("Your job `%s` %s after %s: %s", prompt, outcome, format.Duration(n.Took), n.Link())
+}
+
+// Notifier delivers a notice to its user.
+type Notifier interface {
+	Notify(ctx context.Context, notice Notice) error
+}
+
+// DM sends the notice as a direct message.
+type DM struct {
+	Session discord.DMSender
+}
+
+func (d DM) Notify(ctx context.Context, notice Notice) error {
+	channel, err := d.Session.UserChannelCreate(notice.UserID, discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("couldn't open a DM: %w", err)
+	}
+	_, err = d.Session.ChannelMessageSendComplex(channel.ID, &discordgo.MessageSend{Content: notice.Text()}, discordgo.WithContext(ctx))
+	return err
+}
+
+// Mention posts the notice in the job's channel, mentioning its user.
+type Mention struct {
+	Session discord.ComplexSender
+}
+
+func (m Mention) Notify(ctx context.Context, notice Notice) error {
+	_, err := m.Session.ChannelMessageSendComplex(notice.ChannelID, &discordgo.MessageSend{
+		Content:         "<@" + notice.UserID + "> " + notice.Text(),
+		AllowedMentions: &discordgo.MessageAllowedMentions{Users: []string{notice.UserID}},
+		Reference:       &discordgo.MessageReference{MessageID: notice.MessageID, ChannelID: notice.ChannelID, GuildID: notice.GuildID},
+	}, discordgo.WithContext(ctx))
+	return err
+}
+
+// WebhookPayload is the JSON body a webhook notification is posted with. Its
+// content and text fields make it readable by Discord and Slack incoming
+// webhooks as it is.
+type WebhookPayload struct {
+	Content   string `json:"content"`
+	Text      string `json:"text"`
+	Prompt    string `json:"prompt"`
+	Succeeded bool   `json:"succeeded"`
+	Error     string `json:"error,omitempty"`
+	Seconds   int64  `json:"seconds"`
+	Link      string `json:"link"`
+}
+
+// Webhook posts the notice as JSON to the user's URL.
+type Webhook struct {
+	URL    string
+	Client *http.Client // nil uses a client with a 10 second timeout
+}
+
+func (w Webhook) Notify(ctx context.Context, notice Notice) error {
+	payload := WebhookPayload{
+		Content:   notice.Text(),
+		Text:      notice.Text(),
+		Prompt:    notice.Prompt,
+		Succeeded: notice.Err == nil,
+		Seconds:   int64(notice.Took.Seconds()),
+		Link:      notice.Link(),
+	}
+	if notice.Err != nil {
+		payload.Error = notice.Err.Error()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook answered %s", response.Status)
+	}
+	return nil
+}
+
+// SMTP is the mail server email notices are sent through.
+type SMTP struct {
+	Addr     string // host:port
+	Username string // empty sends without authenticating
+	Password string
+	From     string
+}
+
+// Email mails the notice to the user's address.
+type Email struct {
+	SMTP SMTP
+	To   string
+
+	send func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error // smtp.SendMail, unless testing
+}
+
+func (e Email) Notify(ctx context.Context, notice Notice) error {
+	if e.SMTP.Addr == "" {
+		return errors.New("email isn't set up")
+	}
+	var auth smtp.Auth
+	if e.SMTP.Username != "" {
+		host, _, _ := strings.Cut(e.SMTP.Addr, ":")
+		auth = smtp.PlainAuth("", e.SMTP.Username, e.SMTP.Password, host)
+	}
+	subject := "Your slugbot job finished"
+	if notice.Err != nil {
+		subject = "Your slugbot job failed"
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\n", e.SMTP.From, e.To, subject)
+	fmt.Fprintf(&msg, "Date: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString(notice.Text() + "\r\n")
+	if notice.Err != nil {
+		msg.WriteString("\r\n" + notice.Err.Error() + "\r\n")
+	}
+
+	send := e.send
+	if send == nil {
+		send = smtp.SendMail
+	}
+	// SendMail can't be cancelled, so this only gives up waiting for it
+	done := make(chan error, 1)
+	go func() { done <- send(e.SMTP.Addr, auth, e.SMTP.From, []string{e.To}, msg.Bytes()) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Providers builds the notifier each user picked.
+type Providers struct {
+	Session discord.DMSender
+	SMTP    SMTP
+	Client  *http.Client // for webhooks; nil uses a default
+}
+
+// For returns the notifier a user's preferences pick, or nil if they don't
+// want to be notified, or picked email and it isn't set up.
+func (p Providers) For(userPrefs prefs.Prefs) Notifier {
+	switch userPrefs.Notify {
+	case prefs.NotifyDM:
+		return DM{Session: p.Session}
+	case prefs.NotifyMention:
+		return Mention{Session: p.Session}
+	case prefs.NotifyWebhook:
+		return Webhook{URL: userPrefs.NotifyTo, Client: p.Client}
+	case prefs.NotifyEmail:
+		if p.SMTP.Addr == "" {
+			return nil
+		}
+		return Email{SMTP: p.SMTP, To: userPrefs.NotifyTo}
+	}
+	return nil
+}
+
+// ValidateTarget checks the address a webhook or email notifier is sent to.
+// Webhooks have to be HTTPS, so notices aren't sent in the clear.
+func ValidateTarget(method string, target string) error {
+	switch method {
+	case prefs.NotifyWebhook:
+		parsed, err := url.Parse(target)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return errors.New("the webhook has to be an https:// URL")
+		}
+	case prefs.NotifyEmail:
+		address, err := mail.ParseAddress(target)
+		if err != nil || address.Address != target {
+			return errors.New("that isn't an email address")
+		}
+	}
+	return nil
+}
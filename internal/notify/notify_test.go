@@ -0,0 +1,127 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+
+	"slugbot/internal/prefs"
+)
+
+// session records the messages sent through it.
+type session struct {
+	sent     map[string]*discordgo.MessageSend // by channel ID
+	openedDM []string
+}
+
+func (s *session) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if s.sent == nil {
+		s.sent = map[string]*discordgo.MessageSend{}
+	}
+	s.sent[channelID] = data
+	return &discordgo.Message{ID: "sent", ChannelID: channelID}, nil
+}
+
+func (s *session) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	s.openedDM = append(s.openedDM, recipientID)
+	return &discordgo.Channel{ID: "dm-" + recipientID}, nil
+}
+
+var notice = Notice{UserID: "u1", GuildID: "g1", ChannelID: "c1", MessageID: "m1", Prompt: "rain on a `tin` roof", Took: 3*time.Hour + 5*time.Minute}
+
+func TestNotice_Text(t *testing.T) {
+	require.Equal(t, "Your job `rain on a 'tin' roof` finished after 3h 5m: https://discord.com/channels/g1/c1/m1", notice.Text())
+
+	failed := notice
+	failed.Err, failed.GuildID = errors.New("out of memory"), ""
+	require.Equal(t, "Your job `rain on a 'tin' roof` failed after 3h 5m: https://discord.com/channels/@me/c1/m1", failed.Text())
+}
+
+func TestDMAndMention(t *testing.T) {
+	s := &session{}
+	require.NoError(t, DM{Session: s}.Notify(context.Background(), notice))
+	require.Equal(t, []string{"u1"}, s.openedDM)
+	require.Equal(t, notice.Text(), s.sent["dm-u1"].Content)
+
+	require.NoError(t, Mention{Session: s}.Notify(context.Background(), notice))
+	require.Equal(t, "<@u1> "+notice.Text(), s.sent["c1"].Content)
+	require.Equal(t, []string{"u1"}, s.sent["c1"].AllowedMentions.Users)
+	require.Equal(t, "m1", s.sent["c1"].Reference.MessageID)
+}
+
+func TestWebhook_PostsJSON(t *testing.T) {
+	var got WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	failed := notice
+	failed.Err = errors.New("out of memory")
+	require.NoError(t, Webhook{URL: server.URL}.Notify(context.Background(), failed))
+	require.False(t, got.Succeeded)
+	require.Equal(t, "out of memory", got.Error)
+	require.Equal(t, int64(11100), got.Seconds)
+	require.Equal(t, failed.Text(), got.Content)
+}
+
+func TestWebhook_ReportsRefusal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	require.ErrorContains(t, Webhook{URL: server.URL}.Notify(context.Background(), notice), "404")
+}
+
+func TestEmail_SendsMessage(t *testing.T) {
+	var to []string
+	var body string
+	email := Email{
+		SMTP: SMTP{Addr: "smtp.example.com:587", From: "bot@example.com"},
+		To:   "user@example.com",
+		send: func(addr string, auth smtp.Auth, from string, recipients []string, msg []byte) error {
+			require.Equal(t, "smtp.example.com:587", addr)
+			require.Nil(t, auth)
+			to, body = recipients, string(msg)
+			return nil
+		},
+	}
+	require.NoError(t, email.Notify(context.Background(), notice))
+	require.Equal(t, []string{"user@example.com"}, to)
+	require.Contains(t, body, "Subject: Your slugbot job finished\r\n")
+	require.Contains(t, body, notice.Text())
+}
+
+func TestProviders_For(t *testing.T) {
+	p := Providers{Session: &session{}}
+	require.Nil(t, p.For(prefs.Prefs{}))
+	require.IsType(t, DM{}, p.For(prefs.Prefs{Notify: prefs.NotifyDM}))
+	require.IsType(t, Mention{}, p.For(prefs.Prefs{Notify: prefs.NotifyMention}))
+	require.Equal(t, "https://example.com/hook", p.For(prefs.Prefs{Notify: prefs.NotifyWebhook, NotifyTo: "https://example.com/hook"}).(Webhook).URL)
+
+	// email only works once it's set up
+	emailPrefs := prefs.Prefs{Notify: prefs.NotifyEmail, NotifyTo: "user@example.com"}
+	require.Nil(t, p.For(emailPrefs))
+	p.SMTP.Addr = "smtp.example.com:587"
+	require.Equal(t, "user@example.com", p.For(emailPrefs).(Email).To)
+}
+
+func TestValidateTarget(t *testing.T) {
+	require.NoError(t, ValidateTarget(prefs.NotifyWebhook, "https://example.com/hook"))
+	require.Error(t, ValidateTarget(prefs.NotifyWebhook, "http://example.com/hook"))
+	require.Error(t, ValidateTarget(prefs.NotifyWebhook, "example.com"))
+	require.NoError(t, ValidateTarget(prefs.NotifyEmail, "user@example.com"))
+	require.Error(t, ValidateTarget(prefs.NotifyEmail, "Someone <user@example.com>"))
+	require.Error(t, ValidateTarget(prefs.NotifyEmail, "nope"))
+	require.NoError(t, ValidateTarget(prefs.NotifyDM, ""))
+}
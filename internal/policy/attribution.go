@@ -0,0 +1,55 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	"slugbot/internal/config"
+	"slugbot/internal/store"
+)
+
+// Attribution is a guild's choice of how generated audio is labelled. Unset
+// fields fall back to the config file.
+type Attribution struct {
+	Footer    *bool `json:"footer,omitempty"`
+	Watermark *bool `json:"watermark,omitempty"`
+}
+
+// Attribution reports whether a guild's results get an AI-generated footer
+// and a watermark.
+func (p *Policies) Attribution(guildID string) (footer bool, watermark bool, err error) {
+	cfg := config.Get().Attribution
+	footer, watermark = cfg.Footer, cfg.Watermark
+	guild, err := p.GuildAttribution(guildID)
+	if guild.Footer != nil {
+		footer = *guild.Footer
+	}
+	if guild.Watermark != nil {
+		watermark = *guild.Watermark
+	}
+	return footer, watermark, err
+}
+
+// GuildAttribution returns only what a guild's admins chose.
+func (p *Policies) GuildAttribution(guildID string) (Attribution, error) {
+	var policy Attribution
+	if p == nil || p.Store == nil || guildID == "" {
+		return policy, nil
+	}
+	if err := p.Store.Get(bucket, attributionKey(guildID), &policy); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return Attribution{}, fmt.Errorf("couldn't load attribution policy: %w", err)
+	}
+	return policy, nil
+}
+
+// SetGuildAttribution saves a guild's attribution choices.
+func (p *Policies) SetGuildAttribution(guildID string, policy Attribution) error {
+	if p == nil || p.Store == nil {
+		return fmt.Errorf("policies need a configured store")
+	}
+	return p.Store.Put(bucket, attributionKey(guildID), policy)
+}
+
+func attributionKey(guildID string) string {
+	return guildID + "/attribution"
+}
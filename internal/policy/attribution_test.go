@@ -0,0 +1,34 @@
+package policy
+
+import (
+	"testing"
+
+	"slugbot/internal/config"
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicies_GuildAttributionOverridesConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.Attribution.Footer = true
+	config.Set(cfg)
+	defer config.Set(config.Default())
+
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	policies := &Policies{Store: s}
+
+	off, on := false, true
+	require.NoError(t, policies.SetGuildAttribution("g1", Attribution{Footer: &off, Watermark: &on}))
+
+	footer, watermark, err := policies.Attribution("g1")
+	require.NoError(t, err)
+	require.False(t, footer)
+	require.True(t, watermark)
+
+	footer, watermark, err = policies.Attribution("g2")
+	require.NoError(t, err)
+	require.True(t, footer)
+	require.False(t, watermark)
+}
@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"slugbot/internal/config"
+	"slugbot/internal/store"
+)
+
+const bucket = "policy"
+
+// NSFW lists what a guild only allows in age-restricted channels.
+type NSFW struct {
+	Commands []string `json:"commands"` // command keys, e.g. ".saudio" or ".sim animate"
+	Terms    []string `json:"terms"`    // prompt words or phrases, matched case-insensitively as whole words
+}
+
+// Restricts reports whether a command needs an age-restricted channel under
+// this policy, and if so why.
+func (p NSFW) Restricts(commandKey string, content string) (reason string, restricted bool) {
+	if slices.Contains(p.Commands, commandKey) {
+		return fmt.Sprintf("`%s` is limited to age-restricted channels here", commandKey), true
+	}
+	if terms := termsRegex(p.Terms); terms != nil && terms.MatchString(content) {
+		return "that prompt is limited to age-restricted channels here", true
+	}
+	return "", false
+}
+
+// compiledTerms caches the regex matching each list of terms, since the same
+// few lists are checked against every message.
+var compiledTerms = struct {
+	sync.Mutex
+	byTerms map[string]*regexp.Regexp
+}{byTerms: map[string]*regexp.Regexp{}}
+
+// maxCompiledTerms bounds the cache; it's only cleared once admins have
+// changed their lists this often.
+const maxCompiledTerms = 256
+
+// termsRegex returns a regex matching any of terms as a whole word, or nil
+// if there are none.
+func termsRegex(terms []string) *regexp.Regexp {
+	key := strings.Join(terms, "\x00")
+	compiledTerms.Lock()
+	defer compiledTerms.Unlock()
+	if re, ok := compiledTerms.byTerms[key]; ok {
+		return re
+	}
+
+	var patterns []string
+	for _, term := range terms {
+		if pattern := termPattern(term); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	var re *regexp.Regexp
+	if len(patterns) > 0 {
+		re = regexp.MustCompile(`(?i)` + strings.Join(patterns, "|"))
+	}
+	if len(compiledTerms.byTerms) >= maxCompiledTerms {
+		clear(compiledTerms.byTerms)
+	}
+	compiledTerms.byTerms[key] = re
+	return re
+}
+
+// termPattern matches a term as a whole word: a side of it that's a letter,
+// digit, or underscore can't run on into another one, while a side that's
+// punctuation, as in "c++" or "+18", can be next to anything. \b can't do
+// that, since it never matches between punctuation and a space.
+func termPattern(term string) string {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return ""
+	}
+	const notWord = `[^\pL\pN_]`
+	pattern := regexp.QuoteMeta(term)
+	if first, _ := utf8.DecodeRuneInString(term); isWordRune(first) {
+		pattern = `(?:^|` + notWord + `)` + pattern
+	}
+	if last, _ := utf8.DecodeLastRuneInString(term); isWordRune(last) {
+		pattern += `(?:$|` + notWord + `)`
+	}
+	return `(?:` + pattern + `)`
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// merge combines two policies without duplicates.
+func (p NSFW) merge(other NSFW) NSFW {
+	merged := NSFW{Commands: slices.Clone(p.Commands), Terms: slices.Clone(p.Terms)}
+	for _, c := range other.Commands {
+		if !slices.Contains(merged.Commands, c) {
+			merged.Commands = append(merged.Commands, c)
+		}
+	}
+	for _, t := range other.Terms {
+		if !slices.Contains(merged.Terms, t) {
+			merged.Terms = append(merged.Terms, t)
+		}
+	}
+	return merged
+}
+
+// Policies resolves each guild's policy from the store, on top of the
+// defaults in the config file.
+type Policies struct {
+	Store *store.Store
+}
+
+// ForgetGuild deletes everything a guild's admins set, and returns how many
+// policies it deleted.
+func (p *Policies) ForgetGuild(guildID string) (int, error) {
+	if p == nil || p.Store == nil || guildID == "" {
+		return 0, nil
+	}
+	return p.Store.DeletePrefix(bucket, guildID+"/")
+}
+
+// NSFW returns a guild's effective NSFW policy: the config defaults plus
+// anything its admins added.
+func (p *Policies) NSFW(guildID string) (NSFW, error) {
+	cfg := config.Get().NSFW
+	defaults := NSFW{Commands: cfg.Commands, Terms: cfg.Terms}
+	guild, err := p.GuildNSFW(guildID)
+	if err != nil {
+		return defaults, err
+	}
+	return defaults.merge(guild), nil
+}
+
+// GuildNSFW returns only what a guild's admins added to the NSFW policy.
+func (p *Policies) GuildNSFW(guildID string) (NSFW, error) {
+	var policy NSFW
+	if p == nil || p.Store == nil || guildID == "" {
+		return policy, nil
+	}
+	if err := p.Store.Get(bucket, nsfwKey(guildID), &policy); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return policy, fmt.Errorf("couldn't load NSFW policy: %w", err)
+	}
+	return policy, nil
+}
+
+// SetGuildNSFW saves what a guild's admins added to the NSFW policy.
+func (p *Policies) SetGuildNSFW(guildID string, policy NSFW) error {
+	if p == nil || p.Store == nil {
+		return fmt.Errorf("policies need a configured store")
+	}
+	return p.Store.Put(bucket, nsfwKey(guildID), policy)
+}
+
+func nsfwKey(guildID string) string {
+	return guildID + "/nsfw"
+}
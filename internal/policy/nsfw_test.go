@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"testing"
+
+	"slugbot/internal/config"
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNSFW_RestrictsCommandsAndWholeWordTerms(t *testing.T) {
+	p := NSFW{Commands: []string{".sim animate"}, Terms: []string{"gore", "body horror"}}
+
+	_, restricted := p.Restricts(".sim animate", ".sim animate polar 0 90 10")
+	require.True(t, restricted)
+
+	_, restricted = p.Restricts(".saudio", ".saudio Body Horror ambience")
+	require.True(t, restricted)
+
+	_, restricted = p.Restricts(".saudio", ".saudio gorey synths")
+	require.False(t, restricted)
+}
+
+func TestNSFW_TermsThatStartOrEndWithPunctuation(t *testing.T) {
+	p := NSFW{Terms: []string{"+18", "c++", "r-rated", "  "}}
+
+	for content, want := range map[string]bool{
+		".saudio +18 version":        true,
+		".saudio (+18)":              true,
+		".saudio learn c++ today":    true,
+		".saudio c++, again":         true,
+		".saudio abc++":              false,
+		".saudio an R-rated score":   true,
+		".saudio unr-rated":          false,
+		".saudio rain on a tin roof": false,
+	} {
+		_, restricted := p.Restricts(".saudio", content)
+		require.Equal(t, want, restricted, content)
+	}
+
+	// the same list is only compiled once
+	require.Same(t, termsRegex(p.Terms), termsRegex(p.Terms))
+	require.Nil(t, termsRegex(nil))
+}
+
+func TestPolicies_MergesGuildPolicyOverConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.NSFW.Terms = []string{"gore"}
+	config.Set(cfg)
+	defer config.Set(config.Default())
+
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	policies := &Policies{Store: s}
+
+	require.NoError(t, policies.SetGuildNSFW("g1", NSFW{Commands: []string{".saudio"}, Terms: []string{"gore"}}))
+
+	merged, err := policies.NSFW("g1")
+	require.NoError(t, err)
+	require.Equal(t, NSFW{Commands: []string{".saudio"}, Terms: []string{"gore"}}, merged)
+
+	other, err := policies.NSFW("g2")
+	require.NoError(t, err)
+	require.Equal(t, []string{"gore"}, other.Terms)
+	require.Empty(t, other.Commands)
+}
+
+func TestPolicies_ForgetGuild(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	policies := &Policies{Store: s}
+
+	require.NoError(t, policies.SetGuildNSFW("g1", NSFW{Commands: []string{".saudio"}}))
+	require.NoError(t, policies.SetGuildReactions("g1", Reactions{}))
+	require.NoError(t, policies.SetGuildNSFW("g2", NSFW{Commands: []string{".saudio"}}))
+
+	forgotten, err := policies.ForgetGuild("g1")
+	require.NoError(t, err)
+	require.Equal(t, 2, forgotten)
+
+	guild, err := policies.GuildNSFW("g1")
+	require.NoError(t, err)
+	require.Empty(t, guild.Commands)
+	guild, err = policies.GuildNSFW("g2")
+	require.NoError(t, err)
+	require.Equal(t, []string{".saudio"}, guild.Commands)
+}
@@ -0,0 +1,53 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	"slugbot/internal/config"
+	"slugbot/internal/store"
+)
+
+// Persona is a guild's choice of how the bot presents itself there. Unset
+// fields fall back to the config file.
+type Persona struct {
+	Nickname *string `json:"nickname,omitempty"`
+	Color    *string `json:"color,omitempty"`
+}
+
+// Persona returns how the bot presents itself in a guild.
+func (p *Policies) Persona(guildID string) (config.Persona, error) {
+	persona := config.Get().Persona
+	guild, err := p.GuildPersona(guildID)
+	if guild.Nickname != nil {
+		persona.Nickname = *guild.Nickname
+	}
+	if guild.Color != nil {
+		persona.Color = *guild.Color
+	}
+	return persona, err
+}
+
+// GuildPersona returns only what a guild's admins chose.
+func (p *Policies) GuildPersona(guildID string) (Persona, error) {
+	var policy Persona
+	if p == nil || p.Store == nil || guildID == "" {
+		return policy, nil
+	}
+	if err := p.Store.Get(bucket, personaKey(guildID), &policy); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return Persona{}, fmt.Errorf("couldn't load persona policy: %w", err)
+	}
+	return policy, nil
+}
+
+// SetGuildPersona saves a guild's persona choices.
+func (p *Policies) SetGuildPersona(guildID string, policy Persona) error {
+	if p == nil || p.Store == nil {
+		return fmt.Errorf("policies need a configured store")
+	}
+	return p.Store.Put(bucket, personaKey(guildID), policy)
+}
+
+func personaKey(guildID string) string {
+	return guildID + "/persona"
+}
@@ -0,0 +1,32 @@
+package policy
+
+import (
+	"testing"
+
+	"slugbot/internal/config"
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicies_GuildPersonaOverridesConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.Persona = config.Persona{Nickname: "slugbot", Color: "#5865f2"}
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	policies := &Policies{Store: s}
+
+	nickname := "Loop Goblin"
+	require.NoError(t, policies.SetGuildPersona("g1", Persona{Nickname: &nickname}))
+
+	persona, err := policies.Persona("g1")
+	require.NoError(t, err)
+	require.Equal(t, config.Persona{Nickname: "Loop Goblin", Color: "#5865f2"}, persona)
+
+	persona, err = policies.Persona("g2")
+	require.NoError(t, err)
+	require.Equal(t, cfg.Persona, persona)
+}
@@ -0,0 +1,61 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	"slugbot/internal/config"
+	"slugbot/internal/store"
+)
+
+// Reactions is a guild's choice of acknowledgement reactions. Unset fields
+// fall back to the config file.
+type Reactions struct {
+	Enabled   *bool  `json:"enabled,omitempty"`
+	Queued    string `json:"queued,omitempty"`
+	Succeeded string `json:"succeeded,omitempty"`
+	Failed    string `json:"failed,omitempty"`
+}
+
+// Reactions returns the acknowledgement reactions used in a guild.
+func (p *Policies) Reactions(guildID string) (config.Reactions, error) {
+	reactions := config.Get().Reactions
+	guild, err := p.GuildReactions(guildID)
+	if guild.Enabled != nil {
+		reactions.Enabled = *guild.Enabled
+	}
+	if guild.Queued != "" {
+		reactions.Queued = guild.Queued
+	}
+	if guild.Succeeded != "" {
+		reactions.Succeeded = guild.Succeeded
+	}
+	if guild.Failed != "" {
+		reactions.Failed = guild.Failed
+	}
+	return reactions, err
+}
+
+// GuildReactions returns only what a guild's admins chose.
+func (p *Policies) GuildReactions(guildID string) (Reactions, error) {
+	var policy Reactions
+	if p == nil || p.Store == nil || guildID == "" {
+		return policy, nil
+	}
+	if err := p.Store.Get(bucket, reactionsKey(guildID), &policy); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return Reactions{}, fmt.Errorf("couldn't load reactions policy: %w", err)
+	}
+	return policy, nil
+}
+
+// SetGuildReactions saves a guild's reaction choices.
+func (p *Policies) SetGuildReactions(guildID string, policy Reactions) error {
+	if p == nil || p.Store == nil {
+		return fmt.Errorf("policies need a configured store")
+	}
+	return p.Store.Put(bucket, reactionsKey(guildID), policy)
+}
+
+func reactionsKey(guildID string) string {
+	return guildID + "/reactions"
+}
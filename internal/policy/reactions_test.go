@@ -0,0 +1,27 @@
+package policy
+
+import (
+	"testing"
+
+	"slugbot/internal/config"
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicies_GuildReactionsOverrideConfig(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	policies := &Policies{Store: s}
+
+	on := true
+	require.NoError(t, policies.SetGuildReactions("g1", Reactions{Enabled: &on, Succeeded: "party:123"}))
+
+	reactions, err := policies.Reactions("g1")
+	require.NoError(t, err)
+	require.Equal(t, config.Reactions{Enabled: true, Queued: "⏳", Succeeded: "party:123", Failed: "❌"}, reactions)
+
+	reactions, err = policies.Reactions("g2")
+	require.NoError(t, err)
+	require.Equal(t, config.Default().Reactions, reactions)
+}
@@ -0,0 +1,69 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	"slugbot/internal/config"
+	"slugbot/internal/store"
+)
+
+// Results is a guild's choice of how its results are presented. Unset
+// fields fall back to the config file.
+type Results struct {
+	Template *string `json:"template,omitempty"`
+	Preview  *bool   `json:"preview,omitempty"`
+	Archive  string  `json:"archive,omitempty"` // a channel or forum every result is also posted to; there's no config default
+}
+
+// ResultTemplate returns the template a guild's results are posted with.
+func (p *Policies) ResultTemplate(guildID string) (string, error) {
+	template := config.Get().Results.Template
+	guild, err := p.GuildResults(guildID)
+	if guild.Template != nil {
+		template = *guild.Template
+	}
+	return template, err
+}
+
+// ResultPreview reports whether a guild's results are first sent to their
+// submitter to publish or discard.
+func (p *Policies) ResultPreview(guildID string) (bool, error) {
+	preview := config.Get().Results.Preview
+	guild, err := p.GuildResults(guildID)
+	if guild.Preview != nil {
+		preview = *guild.Preview
+	}
+	return preview, err
+}
+
+// ResultArchive returns the channel a guild's results are archived in, or ""
+// if they aren't.
+func (p *Policies) ResultArchive(guildID string) (string, error) {
+	guild, err := p.GuildResults(guildID)
+	return guild.Archive, err
+}
+
+// GuildResults returns only what a guild's admins chose.
+func (p *Policies) GuildResults(guildID string) (Results, error) {
+	var policy Results
+	if p == nil || p.Store == nil || guildID == "" {
+		return policy, nil
+	}
+	if err := p.Store.Get(bucket, resultsKey(guildID), &policy); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return Results{}, fmt.Errorf("couldn't load results policy: %w", err)
+	}
+	return policy, nil
+}
+
+// SetGuildResults saves a guild's result presentation.
+func (p *Policies) SetGuildResults(guildID string, policy Results) error {
+	if p == nil || p.Store == nil {
+		return fmt.Errorf("policies need a configured store")
+	}
+	return p.Store.Put(bucket, resultsKey(guildID), policy)
+}
+
+func resultsKey(guildID string) string {
+	return guildID + "/results"
+}
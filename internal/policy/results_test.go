@@ -0,0 +1,70 @@
+package policy
+
+import (
+	"testing"
+
+	"slugbot/internal/config"
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicies_GuildResultTemplateOverridesConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.Results.Template = "{{.Prompt}}"
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	policies := &Policies{Store: s}
+
+	off := ""
+	require.NoError(t, policies.SetGuildResults("g1", Results{Template: &off}))
+
+	template, err := policies.ResultTemplate("g1")
+	require.NoError(t, err)
+	require.Empty(t, template)
+
+	template, err = policies.ResultTemplate("g2")
+	require.NoError(t, err)
+	require.Equal(t, "{{.Prompt}}", template)
+}
+
+func TestPolicies_GuildResultPreviewOverridesConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.Results.Preview = true
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	policies := &Policies{Store: s}
+
+	off := false
+	require.NoError(t, policies.SetGuildResults("g1", Results{Preview: &off}))
+
+	preview, err := policies.ResultPreview("g1")
+	require.NoError(t, err)
+	require.False(t, preview)
+
+	preview, err = policies.ResultPreview("g2")
+	require.NoError(t, err)
+	require.True(t, preview)
+}
+
+func TestPolicies_ResultArchiveIsPerGuild(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	policies := &Policies{Store: s}
+
+	require.NoError(t, policies.SetGuildResults("g1", Results{Archive: "c1"}))
+
+	archive, err := policies.ResultArchive("g1")
+	require.NoError(t, err)
+	require.Equal(t, "c1", archive)
+
+	archive, err = policies.ResultArchive("g2")
+	require.NoError(t, err)
+	require.Empty(t, archive)
+}
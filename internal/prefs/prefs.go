@@ -0,0 +1,75 @@
+// Package prefs keeps each user's choices about how the bot treats their jobs,
+// and carries them along with a command's context while its jobs run.
+package prefs
+
+import (
+	"context"
+	"errors"
+
+	"slugbot/internal/store"
+)
+
+const bucket = "prefs"
+
+// Ways a user can be told their jobs finished.
+const (
+	NotifyDM      = "dm"
+	NotifyMention = "mention" // in the job's channel
+	NotifyWebhook = "webhook" // a POST to NotifyTo
+	NotifyEmail   = "email"   // to NotifyTo
+)
+
+// Prefs are one user's preferences. The zero value is the default.
+type Prefs struct {
+	Quiet    bool   `json:"quiet,omitempty"`     // no progress messages, position notices, or reactions; just the result
+	Notify   string `json:"notify,omitempty"`    // how to say a long job finished; empty doesn't
+	NotifyTo string `json:"notify_to,omitempty"` // the webhook URL or email address, for those methods
+}
+
+// Registry keeps users' preferences in a store. A nil Registry, or one
+// without a store, gives everyone the defaults.
+type Registry struct {
+	Store *store.Store
+}
+
+// Get returns a user's preferences.
+func (r *Registry) Get(userID string) (Prefs, error) {
+	var prefs Prefs
+	if r == nil || r.Store == nil || userID == "" {
+		return prefs, nil
+	}
+	if err := r.Store.Get(bucket, userID, &prefs); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return Prefs{}, err
+	}
+	return prefs, nil
+}
+
+// Set saves a user's preferences; saving the defaults forgets them.
+func (r *Registry) Set(userID string, prefs Prefs) error {
+	if prefs == (Prefs{}) {
+		return r.Forget(userID)
+	}
+	return r.Store.Put(bucket, userID, prefs)
+}
+
+// Forget drops a user's preferences, returning them to the defaults.
+func (r *Registry) Forget(userID string) error {
+	return r.Store.Delete(bucket, userID)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying a user's preferences, for the
+// commands and jobs that run on their behalf.
+func NewContext(ctx context.Context, prefs Prefs) context.Context {
+	return context.WithValue(ctx, contextKey{}, prefs)
+}
+
+// FromContext returns the preferences ctx carries, or the defaults.
+func FromContext(ctx context.Context) Prefs {
+	if ctx == nil {
+		return Prefs{}
+	}
+	prefs, _ := ctx.Value(contextKey{}).(Prefs)
+	return prefs
+}
@@ -0,0 +1,42 @@
+package prefs
+
+import (
+	"context"
+	"testing"
+
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_SetGetForget(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	r := &Registry{Store: s}
+
+	got, err := r.Get("u1")
+	require.NoError(t, err)
+	require.Equal(t, Prefs{}, got)
+
+	require.NoError(t, r.Set("u1", Prefs{Quiet: true}))
+	got, err = r.Get("u1")
+	require.NoError(t, err)
+	require.True(t, got.Quiet)
+
+	require.NoError(t, r.Forget("u1"))
+	got, err = r.Get("u1")
+	require.NoError(t, err)
+	require.False(t, got.Quiet)
+}
+
+func TestRegistry_NilGivesDefaults(t *testing.T) {
+	var r *Registry
+	got, err := r.Get("u1")
+	require.NoError(t, err)
+	require.Equal(t, Prefs{}, got)
+}
+
+func TestContext(t *testing.T) {
+	require.Equal(t, Prefs{}, FromContext(context.Background()))
+	require.True(t, FromContext(NewContext(context.Background(), Prefs{Quiet: true})).Quiet)
+}
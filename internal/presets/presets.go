@@ -0,0 +1,180 @@
+package presets
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"slugbot/internal/cmdline"
+	"slugbot/internal/config"
+	"slugbot/internal/store"
+)
+
+// bucket holds every kind of preset; keys are "<guild>/<kind>/<name>" so audio
+// and image presets for a guild sit next to each other.
+const bucket = "presets"
+
+const kindImage = "image"
+
+// ErrUnknownPreset is returned when no preset with the requested name exists.
+var ErrUnknownPreset = errors.New("unknown preset")
+
+var presetNameRegex = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// ImagePreset is a named chain of magick operators applied to an input image.
+type ImagePreset struct {
+	Name   string   `json:"name"`
+	Args   []string `json:"args"`
+	Format string   `json:"format,omitempty"` // output extension override, e.g. "jpg" to keep compression artifacts
+}
+
+// builtinImagePresets are available in every guild unless overridden.
+var builtinImagePresets = map[string]ImagePreset{
+	"deepfry": {
+		Name:   "deepfry",
+		Args:   []string{"-modulate", "110,250", "-contrast-stretch", "2%", "-sharpen", "0x3", "-attenuate", "0.6", "+noise", "Gaussian", "-quality", "6"},
+		Format: "jpg",
+	},
+	"crunch": {
+		Name: "crunch",
+		Args: []string{"-filter", "point", "-resize", "12.5%", "-resize", "800%"},
+	},
+}
+
+// allowedImageOperators limits presets to operators that only transform pixels;
+// anything that reads or writes other files (e.g. -write, -read) is rejected,
+// as are values that could name a file (see cmdline.CheckMagickValue).
+var allowedImageOperators = []string{
+	"-blur", "-brightness-contrast", "-colorize", "-colors", "-contrast", "+contrast",
+	"-contrast-stretch", "-despeckle", "-distort", "-edge", "-emboss", "-equalize",
+	"-fill", "-filter", "-flip", "-flop", "-gamma", "-implode", "-level", "-modulate",
+	"-monochrome", "-motion-blur", "-negate", "-noise", "+noise", "-attenuate", "-normalize",
+	"-paint", "-posterize", "-quality", "-resize", "-rotate", "-sample", "-scale",
+	"-sepia-tone", "-sharpen", "-solarize", "-swirl", "-threshold", "-tint", "-unsharp", "-wave",
+}
+
+// Catalog resolves presets from the store (per guild), the config file, and the built-ins, in that order.
+type Catalog struct {
+	Store *store.Store
+}
+
+// ValidateImagePreset checks the preset's name and that it only uses allowed operators.
+func ValidateImagePreset(p ImagePreset) error {
+	if !presetNameRegex.MatchString(p.Name) {
+		return fmt.Errorf("preset names must be 1-32 characters of a-z, 0-9, '_' or '-'")
+	}
+	if len(p.Args) == 0 {
+		return fmt.Errorf("preset '%s' has no operations", p.Name)
+	}
+	for _, arg := range p.Args {
+		if (strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "+")) && !isNumber(arg) {
+			if !slices.Contains(allowedImageOperators, arg) {
+				return fmt.Errorf("operator '%s' isn't allowed in presets", arg)
+			}
+		} else if err := cmdline.CheckMagickValue(arg); err != nil {
+			return fmt.Errorf("preset '%s': %w", p.Name, err)
+		}
+	}
+	if p.Format != "" && !slices.Contains([]string{"png", "jpg", "gif", "webp"}, p.Format) {
+		return fmt.Errorf("unsupported preset format '%s'", p.Format)
+	}
+	return nil
+}
+
+// Image looks up an image preset by name for a guild.
+func (c *Catalog) Image(guildID string, name string) (*ImagePreset, error) {
+	if c.Store != nil {
+		var p ImagePreset
+		err := c.Store.Get(bucket, key(guildID, kindImage, name), &p)
+		if err == nil {
+			return &p, nil
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			return nil, fmt.Errorf("couldn't load preset '%s': %w", name, err)
+		}
+	}
+	if p, ok := config.Get().ImagePresets[name]; ok {
+		return &ImagePreset{Name: name, Args: p.Args, Format: p.Format}, nil
+	}
+	if p, ok := builtinImagePresets[name]; ok {
+		return &p, nil
+	}
+	return nil, fmt.Errorf("%w: '%s'", ErrUnknownPreset, name)
+}
+
+// ImageNames lists every image preset name usable in a guild.
+func (c *Catalog) ImageNames(guildID string) ([]string, error) {
+	names := map[string]bool{}
+	for name := range builtinImagePresets {
+		names[name] = true
+	}
+	for name := range config.Get().ImagePresets {
+		names[name] = true
+	}
+	if c.Store != nil {
+		keys, err := c.Store.Keys(bucket)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't list presets: %w", err)
+		}
+		prefix := key(guildID, kindImage, "")
+		for _, k := range keys {
+			if strings.HasPrefix(k, prefix) {
+				names[strings.TrimPrefix(k, prefix)] = true
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
+
+// SetImage saves a guild-specific image preset, replacing any existing one with the same name.
+func (c *Catalog) SetImage(guildID string, p ImagePreset) error {
+	if c.Store == nil {
+		return fmt.Errorf("presets need a configured store")
+	}
+	if err := ValidateImagePreset(p); err != nil {
+		return err
+	}
+	return c.Store.Put(bucket, key(guildID, kindImage, p.Name), p)
+}
+
+// RemoveImage deletes a guild-specific image preset. Built-in and config presets can't be removed.
+func (c *Catalog) RemoveImage(guildID string, name string) error {
+	if c.Store == nil {
+		return fmt.Errorf("presets need a configured store")
+	}
+	var p ImagePreset
+	if err := c.Store.Get(bucket, key(guildID, kindImage, name), &p); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("%w: '%s' (only presets created with .sadmin can be removed)", ErrUnknownPreset, name)
+		}
+		return err
+	}
+	return c.Store.Delete(bucket, key(guildID, kindImage, name))
+}
+
+// ForgetGuild deletes every preset a guild created, and returns how many it deleted.
+func (c *Catalog) ForgetGuild(guildID string) (int, error) {
+	if c.Store == nil || guildID == "" {
+		return 0, nil
+	}
+	return c.Store.DeletePrefix(bucket, guildID+"/")
+}
+
+func key(guildID string, kind string, name string) string {
+	return guildID + "/" + kind + "/" + name
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
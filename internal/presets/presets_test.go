@@ -0,0 +1,60 @@
+package presets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"slugbot/internal/store"
+)
+
+func TestValidateImagePreset(t *testing.T) {
+	require.NoError(t, ValidateImagePreset(ImagePreset{Name: "ok", Args: []string{"-swirl", "-90"}}))
+	require.Error(t, ValidateImagePreset(ImagePreset{Name: "Bad Name", Args: []string{"-negate"}}))
+	require.Error(t, ValidateImagePreset(ImagePreset{Name: "empty"}))
+	require.Error(t, ValidateImagePreset(ImagePreset{Name: "writes", Args: []string{"-write", "/tmp/x.png"}}))
+	require.Error(t, ValidateImagePreset(ImagePreset{Name: "fmt", Args: []string{"-negate"}, Format: "exe"}))
+	require.Error(t, ValidateImagePreset(ImagePreset{Name: "reads", Args: []string{"-fill", "text:/etc/passwd"}}))
+	require.Error(t, ValidateImagePreset(ImagePreset{Name: "at", Args: []string{"-tint", "@secrets"}}))
+}
+
+func TestCatalog_GuildPresetsOverrideBuiltins(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	c := &Catalog{Store: s}
+
+	custom := ImagePreset{Name: "crunch", Args: []string{"-scale", "10%"}}
+	require.NoError(t, c.SetImage("guild-a", custom))
+
+	got, err := c.Image("guild-a", "crunch")
+	require.NoError(t, err)
+	require.Equal(t, custom, *got)
+
+	got, err = c.Image("guild-b", "crunch")
+	require.NoError(t, err)
+	require.Equal(t, builtinImagePresets["crunch"], *got)
+
+	require.NoError(t, c.RemoveImage("guild-a", "crunch"))
+	require.Error(t, c.RemoveImage("guild-a", "deepfry"))
+
+	_, err = c.Image("guild-a", "nope")
+	require.ErrorIs(t, err, ErrUnknownPreset)
+}
+
+func TestCatalog_ForgetGuild(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	c := &Catalog{Store: s}
+	require.NoError(t, c.SetImage("guild-a", ImagePreset{Name: "one", Args: []string{"-scale", "10%"}}))
+	require.NoError(t, c.SetImage("guild-a", ImagePreset{Name: "two", Args: []string{"-scale", "20%"}}))
+	require.NoError(t, c.SetImage("guild-b", ImagePreset{Name: "one", Args: []string{"-scale", "10%"}}))
+
+	forgotten, err := c.ForgetGuild("guild-a")
+	require.NoError(t, err)
+	require.Equal(t, 2, forgotten)
+
+	_, err = c.Image("guild-a", "one")
+	require.ErrorIs(t, err, ErrUnknownPreset)
+	_, err = c.Image("guild-b", "one")
+	require.NoError(t, err)
+}
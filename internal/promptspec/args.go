@@ -0,0 +1,189 @@
+// Package promptspec parses what a generation is asked to make: the prompt
+// and flags after a command word, e.g. "rainy jazz --length 20 --negative
+// vocals", or a TOML block of weighted prompts and settings. It knows nothing
+// about Discord or the commands, so any command that takes a prompt can share
+// it. Its errors describe what's wrong with the input, for whoever wrote it.
+package promptspec
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// negativeGroupRegex matches an inline negative prompt, e.g. "[neg: drums
+// vocals]" or "[negative: drums]".
+var negativeGroupRegex = regexp.MustCompile(`(?i)\[\s*neg(?:ative)?\s*:([^\]]*)\]`)
+
+// openNegativeGroupRegex matches the start of an inline negative prompt.
+var openNegativeGroupRegex = regexp.MustCompile(`(?i)\[\s*neg(?:ative)?\s*:`)
+
+// Args are the settings parsed from a prompt and its flags.
+type Args struct {
+	Length         float64
+	Strength       float64
+	Prompt         string
+	NegativePrompt string
+	Seed           int64
+	Steps          int64
+	IsSmall        bool
+	Enhance        bool   // rewrite the prompt with the configured LLM before generating
+	Input          string // which input to use when several are attached; see helpers.SelectInput
+	KeepProgress   bool   // leave the progress message as a summary instead of deleting it
+}
+
+// ParseArgs parses a prompt and its flags. Words that aren't flags make up the
+// prompt, or the negative prompt once --negative, or just --, has been given.
+// Words in a "[neg: ...]" group anywhere are negative too. A negative marker
+// with nothing after it is an error, rather than a silently empty negative prompt.
+func ParseArgs(args []string) (*Args, error) {
+	args, groupNegative, err := splitNegativeGroups(args)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &Args{
+		Length:         30.0,
+		Strength:       7.0,
+		Prompt:         "",
+		NegativePrompt: "",
+		Seed:           -1,
+		Steps:          100,
+		IsSmall:        false,
+	}
+
+	// parse params; TODO: make this more general/abstracted
+	i := 0
+	prompt := []string{}
+	negativePrompt := []string{}
+	collectNegative := false
+	negativeFlag := "" // the last negative marker given, and so the one that needs words after it
+	negativeWords := 0 // how many words came after it
+	stepsSet := false
+	for i < len(args) {
+		switch args[i] {
+		case "--length":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for --length")
+			}
+			length, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil || math.IsNaN(length) || length <= 0.0 || math.IsInf(length, 0) {
+				return nil, fmt.Errorf("invalid length: %v", args[i+1])
+			}
+			params.Length = length
+			i += 2
+
+		case "--strength":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for --strength")
+			}
+			strength, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil || math.IsNaN(strength) || math.IsInf(strength, 0) {
+				return nil, fmt.Errorf("invalid strength: %v", args[i+1])
+			}
+			params.Strength = strength
+			i += 2
+
+		case "--seed":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for --seed")
+			}
+			seed, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || seed < 0 {
+				return nil, fmt.Errorf("invalid seed `%s` (needs to be a positive integer)", args[i+1])
+			}
+			params.Seed = seed
+			i += 2
+
+		case "--steps":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for --steps")
+			}
+			steps, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || steps < 0 {
+				return nil, fmt.Errorf("invalid steps `%s` (needs to be a positive integer)", args[i+1])
+			}
+			params.Steps = steps
+			i += 2
+			stepsSet = true
+
+		case "--negative", "--":
+			collectNegative = true
+			negativeFlag, negativeWords = args[i], 0
+			i++
+
+		case "--small":
+			params.IsSmall = true
+			i++
+
+		case "--enhance":
+			params.Enhance = true
+			i++
+
+		case "--keep-progress":
+			params.KeepProgress = true
+			i++
+
+		case "--input":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for --input")
+			}
+			params.Input = args[i+1]
+			i += 2
+
+		default:
+			if !collectNegative {
+				prompt = append(prompt, args[i])
+			} else {
+				negativePrompt = append(negativePrompt, args[i])
+				negativeWords++
+			}
+			i++
+		}
+	}
+
+	if !stepsSet && params.IsSmall {
+		params.Steps = 8
+	}
+
+	params.Prompt = strings.Join(prompt, " ")
+	if negativeFlag != "" && negativeWords == 0 {
+		return nil, fmt.Errorf("`%s` needs the words to avoid after it, e.g. `rainy jazz %s drums vocals`", negativeFlag, negativeFlag)
+	}
+	params.NegativePrompt = strings.Join(append(negativePrompt, groupNegative...), " ")
+
+	if params.Prompt == "" {
+		return nil, fmt.Errorf("prompt is empty")
+	}
+
+	return params, nil
+}
+
+// splitNegativeGroups takes the "[neg: ...]" groups out of args, returning
+// the rest of args and the groups' words. args are left as they are if there
+// aren't any groups.
+func splitNegativeGroups(args []string) ([]string, []string, error) {
+	text := strings.Join(args, " ")
+	if !openNegativeGroupRegex.MatchString(text) {
+		return args, nil, nil
+	}
+
+	var negative []string
+	var empty bool
+	rest := negativeGroupRegex.ReplaceAllStringFunc(text, func(group string) string {
+		words := strings.Fields(negativeGroupRegex.FindStringSubmatch(group)[1])
+		empty = empty || len(words) == 0
+		negative = append(negative, words...)
+		return " "
+	})
+	if openNegativeGroupRegex.MatchString(rest) {
+		return nil, nil, errors.New("a `[neg:` group needs a closing `]`, e.g. `rainy jazz [neg: drums vocals]`")
+	}
+	if empty {
+		return nil, nil, errors.New("a `[neg: ]` group needs the words to avoid in it, e.g. `rainy jazz [neg: drums vocals]`")
+	}
+	return strings.Fields(rest), negative, nil
+}
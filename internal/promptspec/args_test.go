@@ -0,0 +1,166 @@
+package promptspec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseArgs_Defaults(t *testing.T) {
+	args, err := ParseArgs([]string{"rainy", "jazz"})
+	require.NoError(t, err)
+	require.Equal(t, &Args{Prompt: "rainy jazz", Length: 30, Strength: 7, Seed: -1, Steps: 100}, args)
+}
+
+func TestParseArgs_Flags(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want Args
+	}{
+		{"every value", "rain --length 12.5 --strength 3 --seed 42 --steps 250",
+			Args{Prompt: "rain", Length: 12.5, Strength: 3, Seed: 42, Steps: 250}},
+		{"flags before the prompt", "--seed 7 --length 5 rain on a roof",
+			Args{Prompt: "rain on a roof", Length: 5, Strength: 7, Seed: 7, Steps: 100}},
+		{"small defaults to 8 steps", "rain --small",
+			Args{Prompt: "rain", Length: 30, Strength: 7, Seed: -1, Steps: 8, IsSmall: true}},
+		{"small keeps explicit steps", "--steps 20 rain --small",
+			Args{Prompt: "rain", Length: 30, Strength: 7, Seed: -1, Steps: 20, IsSmall: true}},
+		{"switches", "rain --enhance --keep-progress --input 2",
+			Args{Prompt: "rain", Length: 30, Strength: 7, Seed: -1, Steps: 100, Enhance: true, KeepProgress: true, Input: "2"}},
+		{"later values win", "rain --length 5 --length 9",
+			Args{Prompt: "rain", Length: 9, Strength: 7, Seed: -1, Steps: 100}},
+		{"zero steps", "rain --steps 0",
+			Args{Prompt: "rain", Length: 30, Strength: 7, Seed: -1, Steps: 0}},
+		{"negative strength", "rain --strength -2.5",
+			Args{Prompt: "rain", Length: 30, Strength: -2.5, Seed: -1, Steps: 100}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args, err := ParseArgs(strings.Fields(test.args))
+			require.NoError(t, err)
+			require.Equal(t, test.want, *args)
+		})
+	}
+}
+
+func TestParseArgs_NegativePrompt(t *testing.T) {
+	// flags can come between the words of the negative prompt
+	args, err := ParseArgs(strings.Fields("rainy jazz --negative vocals --length 10 drums --seed 3"))
+	require.NoError(t, err)
+	require.Equal(t, "rainy jazz", args.Prompt)
+	require.Equal(t, "vocals drums", args.NegativePrompt)
+	require.Equal(t, 10.0, args.Length)
+	require.Equal(t, int64(3), args.Seed)
+
+	_, err = ParseArgs(strings.Fields("--negative vocals"))
+	require.EqualError(t, err, "prompt is empty")
+}
+
+func TestParseArgs_NegativeSugar(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     string
+		prompt   string
+		negative string
+	}{
+		{"double dash", "rainy jazz -- drums vocals", "rainy jazz", "drums vocals"},
+		{"double dash with flags", "rainy jazz --length 10 -- drums --seed 3 vocals", "rainy jazz", "drums vocals"},
+		{"group at the end", "rainy jazz [neg: drums vocals]", "rainy jazz", "drums vocals"},
+		{"group in the middle", "rainy [neg: drums] jazz", "rainy jazz", "drums"},
+		{"group spelled out", "rainy jazz [Negative:drums]", "rainy jazz", "drums"},
+		{"every kind at once", "rainy jazz [neg: drums] --negative vocals", "rainy jazz", "vocals drums"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args, err := ParseArgs(strings.Fields(test.args))
+			require.NoError(t, err)
+			require.Equal(t, test.prompt, args.Prompt)
+			require.Equal(t, test.negative, args.NegativePrompt)
+		})
+	}
+
+	// a misplaced marker says so instead of leaving the negative prompt empty
+	for _, text := range []string{"rain --negative", "rain --", "rain -- --length 5", "--negative vocals rain --"} {
+		_, err := ParseArgs(strings.Fields(text))
+		require.ErrorContains(t, err, "needs the words to avoid after it", text)
+	}
+	_, err := ParseArgs(strings.Fields("rain [neg: ]"))
+	require.ErrorContains(t, err, "needs the words to avoid in it")
+	_, err = ParseArgs(strings.Fields("rain [neg: drums"))
+	require.ErrorContains(t, err, "needs a closing `]`")
+}
+
+func TestParseArgs_Unicode(t *testing.T) {
+	args, err := ParseArgs(strings.Fields("雨の ジャズ 🎷 café --negative ボーカル"))
+	require.NoError(t, err)
+	require.Equal(t, "雨の ジャズ 🎷 café", args.Prompt)
+	require.Equal(t, "ボーカル", args.NegativePrompt)
+
+	// a flag is only a flag if it's spelled exactly
+	args, err = ParseArgs([]string{"rain", "—length", "5"})
+	require.NoError(t, err)
+	require.Equal(t, "rain —length 5", args.Prompt)
+}
+
+func TestParseArgs_Invalid(t *testing.T) {
+	tests := []struct {
+		args string
+		err  string
+	}{
+		{"", "prompt is empty"},
+		{"rain --length", "missing value for --length"},
+		{"rain --length abc", "invalid length: abc"},
+		{"rain --length 0", "invalid length: 0"},
+		{"rain --length -3", "invalid length: -3"},
+		{"rain --length Inf", "invalid length: Inf"},
+		{"rain --length NaN", "invalid length: NaN"},
+		{"rain --strength", "missing value for --strength"},
+		{"rain --strength x", "invalid strength: x"},
+		{"rain --strength NaN", "invalid strength: NaN"},
+		{"rain --strength -Inf", "invalid strength: -Inf"},
+		{"rain --seed", "missing value for --seed"},
+		{"rain --seed -1", "invalid seed `-1` (needs to be a positive integer)"},
+		{"rain --seed 1.5", "invalid seed `1.5` (needs to be a positive integer)"},
+		{"rain --seed 99999999999999999999", "invalid seed `99999999999999999999` (needs to be a positive integer)"},
+		{"rain --steps", "missing value for --steps"},
+		{"rain --steps ten", "invalid steps `ten` (needs to be a positive integer)"},
+		{"rain --steps -5", "invalid steps `-5` (needs to be a positive integer)"},
+		{"rain --input", "missing value for --input"},
+	}
+	for _, test := range tests {
+		t.Run(test.args, func(t *testing.T) {
+			_, err := ParseArgs(strings.Fields(test.args))
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func FuzzParseArgs(f *testing.F) {
+	f.Add("rainy jazz --length 20 --negative vocals")
+	f.Add("--small --steps 4 🎷")
+	f.Add("rain --length 1e308 --strength -0")
+	f.Add("--seed --length")
+	f.Add("rain -- drums [neg: vocals]")
+	f.Fuzz(func(t *testing.T, text string) {
+		words := strings.Fields(text)
+		args, err := ParseArgs(words)
+		if err != nil {
+			require.Nil(t, args)
+			return
+		}
+
+		// whatever parses is something sag can be run with
+		require.NotEmpty(t, args.Prompt)
+		require.Greater(t, args.Length, 0.0)
+		require.False(t, args.Length != args.Length || args.Strength != args.Strength, "NaN got through")
+		require.GreaterOrEqual(t, args.Steps, int64(0))
+		require.GreaterOrEqual(t, args.Seed, int64(-1))
+
+		// and no prompt word is made up
+		for _, word := range strings.Fields(args.Prompt + " " + args.NegativePrompt) {
+			require.Contains(t, text, word)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package promptspec
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Block is a TOML block of weighted prompts and generation settings, e.g.
+//
+//	[prompts]
+//	"lofi hip hop" = 1.0
+//	[config]
+//	length = 20
+type Block struct {
+	Prompts         map[string]float64 `toml:"prompts"`
+	NegativePrompts map[string]float64 `toml:"neg_prompts"`
+	Config          Config             `toml:"config"`
+	Sweep           Sweep              `toml:"sweep"`
+}
+
+// Config mirrors the generation settings sag accepts in a [config] table.
+type Config struct {
+	Length float64 `toml:"length"`
+	Steps  int64   `toml:"steps"`
+	Small  bool    `toml:"small"`
+	CFG    float64 `toml:"cfg_scale"`
+	Seed   int64   `toml:"seed"`
+}
+
+// Sweep lists values to try for each setting in a [sweep] table; the block
+// expands into one job per combination.
+type Sweep struct {
+	Steps  []int64   `toml:"steps"`
+	CFG    []float64 `toml:"cfg"`
+	Length []float64 `toml:"length"`
+}
+
+// Size returns how many jobs the sweep expands into, or 0 if it's empty.
+func (s Sweep) Size() int {
+	if len(s.Steps) == 0 && len(s.CFG) == 0 && len(s.Length) == 0 {
+		return 0
+	}
+	return max(len(s.Steps), 1) * max(len(s.CFG), 1) * max(len(s.Length), 1)
+}
+
+// ParseTOML parses a block, filling in the default settings it leaves out.
+// The block should be normalized first; see NormalizeTOML.
+func ParseTOML(content string) (*Block, error) {
+	block := Block{
+		Prompts:         map[string]float64{},
+		NegativePrompts: map[string]float64{},
+		Config: Config{
+			Length: 30.0,
+			Steps:  100,
+			CFG:    7.0,
+			Seed:   -1,
+		},
+	}
+	if _, err := toml.Decode(content, &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// Describe sums up the block's prompts, e.g. "lofi 1.00, rain 0.50 - vocals
+// 1.00", in a stable order.
+func (b *Block) Describe() string {
+	weighted := func(prompts map[string]float64) string {
+		var parts []string
+		for _, prompt := range slices.Sorted(maps.Keys(prompts)) {
+			parts = append(parts, fmt.Sprintf("%s %0.2f", prompt, prompts[prompt]))
+		}
+		return strings.Join(parts, ", ")
+	}
+	prompt := weighted(b.Prompts)
+	if negative := weighted(b.NegativePrompts); negative != "" {
+		prompt += " - " + negative
+	}
+	return strings.TrimSpace(prompt)
+}
+
+// NormalizeTOML replaces the typographic quotes and invisible characters
+// phones and chat apps put into typed text with the plain ones TOML expects.
+func NormalizeTOML(s string) string {
+	return unicodeQuoteReplacer.Replace(s)
+}
+
+var unicodeQuoteReplacer = strings.NewReplacer(
+	// curved quotes
+	"\u201C", `"`, "\u201D", `"`,
+	"\u2018", `'`, "\u2019", `'`,
+	// angle
+	"\u00AB", `"`, "\u00BB", `"`,
+	"\u2039", `'`, "\u203A", `'`,
+	// low-9 / other
+	"\u201A", `"`, "\u201E", `"`, "\u201B", `'`,
+	// fullwidth
+	"\uFF02", `"`, "\uFF07", `'`,
+	// fancy primes
+	"\u2032", `"`, "\u2033", `"`,
+	// BOM / zero-width
+	"\uFEFF", "", "\u200B", "",
+	// NBSP → space
+	"\u00A0", " ",
+)
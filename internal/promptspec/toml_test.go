@@ -0,0 +1,97 @@
+package promptspec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTOML_FillsDefaults(t *testing.T) {
+	block, err := ParseTOML(`
+[prompts]
+"rainy jazz" = 1.0
+[config]
+steps = 50
+`)
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{"rainy jazz": 1}, block.Prompts)
+	require.Empty(t, block.NegativePrompts)
+	require.Equal(t, Config{Length: 30, Steps: 50, CFG: 7, Seed: -1}, block.Config)
+	require.Zero(t, block.Sweep.Size())
+}
+
+func TestParseTOML_EverySection(t *testing.T) {
+	block, err := ParseTOML(`
+[prompts]
+"lofi" = 1.0
+"雨" = 0.5
+[neg_prompts]
+"vocals" = 0.8
+[config]
+length = 12.5
+steps = 8
+small = true
+cfg_scale = 3
+seed = 42
+[sweep]
+steps = [10, 20]
+cfg = [3.0, 5.0, 7.0]
+`)
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{"lofi": 1, "雨": 0.5}, block.Prompts)
+	require.Equal(t, map[string]float64{"vocals": 0.8}, block.NegativePrompts)
+	require.Equal(t, Config{Length: 12.5, Steps: 8, Small: true, CFG: 3, Seed: 42}, block.Config)
+	require.Equal(t, 6, block.Sweep.Size())
+}
+
+func TestParseTOML_Invalid(t *testing.T) {
+	for _, content := range []string{
+		`[prompts`,
+		"[prompts]\n\"rain\" = \"loud\"",
+		"[config]\nsteps = 1.5",
+		"[config]\nlength = \"long\"",
+	} {
+		_, err := ParseTOML(content)
+		require.Error(t, err, content)
+	}
+}
+
+func TestNormalizeTOML(t *testing.T) {
+	// what a phone keyboard makes of a block
+	block, err := ParseTOML(NormalizeTOML("[prompts]\n“rainy jazz” = 1.0\n\ufeff[config]\nseed = 3"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{"rainy jazz": 1}, block.Prompts)
+	require.Equal(t, int64(3), block.Config.Seed)
+}
+
+func TestSweep_Size(t *testing.T) {
+	require.Zero(t, Sweep{}.Size())
+	require.Equal(t, 3, Sweep{Steps: []int64{1, 2, 3}}.Size())
+	require.Equal(t, 12, Sweep{Steps: []int64{1, 2}, CFG: []float64{1, 2, 3}, Length: []float64{1, 2}}.Size())
+}
+
+func TestBlock_Describe(t *testing.T) {
+	block := &Block{
+		Prompts:         map[string]float64{"rain": 0.5, "lofi": 1},
+		NegativePrompts: map[string]float64{"vocals": 1},
+	}
+	require.Equal(t, "lofi 1.00, rain 0.50 - vocals 1.00", block.Describe())
+	require.Empty(t, (&Block{}).Describe())
+}
+
+func FuzzParseTOML(f *testing.F) {
+	f.Add("[prompts]\n\"rain\" = 1.0")
+	f.Add("[config]\nlength = -1\n[sweep]\nsteps = []")
+	f.Add("“” = ″")
+	f.Fuzz(func(t *testing.T, content string) {
+		block, err := ParseTOML(NormalizeTOML(content))
+		if err != nil {
+			return
+		}
+		// a parsed block always has its maps, so callers can range over them
+		require.NotNil(t, block.Prompts)
+		require.NotNil(t, block.NegativePrompts)
+		require.GreaterOrEqual(t, block.Sweep.Size(), 0)
+		block.Describe()
+	})
+}
@@ -0,0 +1,92 @@
+// Package provenance labels generated audio as AI-made: a footer on the
+// message that delivers it and, optionally, an inaudible watermark embedded
+// with audiowmark.
+package provenance
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"slugbot/internal/config"
+	"slugbot/internal/io/slog"
+	"slugbot/internal/policy"
+	"slugbot/internal/tools"
+)
+
+// Labeler applies each guild's attribution policy to generated files. A nil
+// Labeler labels nothing.
+type Labeler struct {
+	Policies *policy.Policies
+}
+
+// Footer returns the line to post under a result generated by model in a
+// guild, or "" if the guild doesn't want one.
+func (l *Labeler) Footer(guildID string, model string) string {
+	if l == nil {
+		return ""
+	}
+	footer, _, err := l.Policies.Attribution(guildID)
+	if err != nil {
+		slog.Warn(err)
+	}
+	if !footer {
+		return ""
+	}
+	return FooterText(model, config.Get().Attribution.Text)
+}
+
+// FooterText formats the attribution footer for a model, followed by any extra text.
+func FooterText(model string, extra string) string {
+	text := "-# AI-generated audio · " + model
+	if extra = strings.TrimSpace(extra); extra != "" {
+		text += " · " + extra
+	}
+	return text
+}
+
+// Watermark embeds a watermark in the wav at path if the guild wants one. It
+// replaces the file in place, leaving it untouched if audiowmark fails.
+func (l *Labeler) Watermark(ctx context.Context, guildID string, path string, traceID string) error {
+	if l == nil {
+		return nil
+	}
+	_, watermark, err := l.Policies.Attribution(guildID)
+	if err != nil {
+		slog.Warn(err)
+	}
+	if !watermark {
+		return nil
+	}
+
+	cfg := config.Get().Attribution
+	marked := filepath.Join(filepath.Dir(path), ".wm-"+filepath.Base(path))
+	args := []string{"add"}
+	if cfg.KeyFile != "" {
+		args = append(args, "--key", cfg.KeyFile)
+	}
+	args = append(args, path, marked, Payload(time.Now(), traceID))
+
+	output, err := exec.CommandContext(ctx, tools.Resolve(cfg.Audiowmark), args...).CombinedOutput()
+	if err != nil {
+		os.Remove(marked)
+		return fmt.Errorf("audiowmark failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return os.Rename(marked, path)
+}
+
+// Payload returns the 128-bit watermark for a file made at a time under a
+// trace ID, in hex as audiowmark expects: the Unix time in the first 64 bits
+// and the trace ID, if it's hex, in the last 64.
+func Payload(at time.Time, traceID string) string {
+	trace := make([]byte, 8)
+	if decoded, err := hex.DecodeString(traceID); err == nil && len(decoded) <= len(trace) {
+		copy(trace[len(trace)-len(decoded):], decoded)
+	}
+	return fmt.Sprintf("%016x%s", at.Unix(), hex.EncodeToString(trace))
+}
@@ -0,0 +1,21 @@
+package provenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayload_HoldsTimeAndTraceID(t *testing.T) {
+	at := time.Unix(0x6700_0000, 0)
+
+	require.Equal(t, "0000000067000000000000001a2b3c4d", Payload(at, "1a2b3c4d"))
+	require.Len(t, Payload(at, "not-hex"), 32)
+	require.Equal(t, "00000000670000000000000000000000", Payload(at, ""))
+}
+
+func TestFooterText_AppendsExtraText(t *testing.T) {
+	require.Equal(t, "-# AI-generated audio · Stable Audio Open 1.0", FooterText("Stable Audio Open 1.0", " "))
+	require.Equal(t, "-# AI-generated audio · small · see /terms", FooterText("small", "see /terms"))
+}
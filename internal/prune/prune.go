@@ -0,0 +1,87 @@
+// Package prune keeps track of the guilds the bot has been removed from, so
+// what it stored about them can be deleted once they've been gone a while,
+// rather than kept for every guild it's ever been in.
+package prune
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"slugbot/internal/store"
+)
+
+const bucket = "guild_departures"
+
+// Departures records when the bot left each guild, keyed by guild ID. A guild
+// that adds the bot back within Grace keeps everything.
+type Departures struct {
+	Store *store.Store
+	Grace time.Duration // how long a guild's data is kept after the bot leaves it
+
+	mutex sync.Mutex
+}
+
+// Left records that the bot was removed from a guild at the given time. If
+// it was already gone, the first departure counts.
+func (d *Departures) Left(guildID string, at time.Time) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var left time.Time
+	if err := d.Store.Get(bucket, guildID, &left); err == nil {
+		return nil
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	return d.Store.Put(bucket, guildID, at)
+}
+
+// Returned cancels the pruning of a guild that added the bot back. It reports
+// whether the guild's data was waiting to be pruned.
+func (d *Departures) Returned(guildID string) (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var left time.Time
+	if err := d.Store.Get(bucket, guildID, &left); errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, d.Store.Delete(bucket, guildID)
+}
+
+// Due returns the guilds that have been gone longer than Grace, and so are
+// ready to be pruned, longest gone first.
+func (d *Departures) Due(now time.Time) ([]string, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	keys, err := d.Store.Keys(bucket)
+	if err != nil {
+		return nil, err
+	}
+	left := map[string]time.Time{}
+	var due []string
+	for _, guildID := range keys {
+		var at time.Time
+		if err := d.Store.Get(bucket, guildID, &at); err != nil {
+			return nil, err
+		}
+		if now.Sub(at) >= d.Grace {
+			left[guildID] = at
+			due = append(due, guildID)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return left[due[i]].Before(left[due[j]]) })
+	return due, nil
+}
+
+// Done forgets a guild once its data has been pruned.
+func (d *Departures) Done(guildID string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.Store.Delete(bucket, guildID)
+}
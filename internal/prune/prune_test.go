@@ -0,0 +1,55 @@
+package prune
+
+import (
+	"testing"
+	"time"
+
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepartures_PrunesAfterTheGracePeriod(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	d := &Departures{Store: s, Grace: 24 * time.Hour}
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, d.Left("g2", start.Add(time.Hour)))
+	require.NoError(t, d.Left("g1", start))
+	// a second removal doesn't push the first back
+	require.NoError(t, d.Left("g1", start.Add(12*time.Hour)))
+
+	due, err := d.Due(start.Add(23 * time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, due)
+	due, err = d.Due(start.Add(26 * time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, []string{"g1", "g2"}, due)
+
+	require.NoError(t, d.Done("g1"))
+	due, err = d.Due(start.Add(26 * time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, []string{"g2"}, due)
+}
+
+func TestDepartures_ReturningKeepsEverything(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	d := &Departures{Store: s, Grace: time.Hour}
+	start := time.Now()
+
+	require.NoError(t, d.Left("g1", start))
+	returned, err := d.Returned("g1")
+	require.NoError(t, err)
+	require.True(t, returned)
+
+	due, err := d.Due(start.Add(2 * time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, due)
+
+	// every guild is "created" when the bot connects, whether or not it ever left
+	returned, err = d.Returned("g2")
+	require.NoError(t, err)
+	require.False(t, returned)
+}
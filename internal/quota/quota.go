@@ -0,0 +1,114 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"slugbot/internal/format"
+)
+
+// ErrOverQuota is returned by Check when a user's live artifacts exceed the limit.
+var ErrOverQuota = errors.New("over disk quota")
+
+// Tracker accounts for the files each user's jobs have left on disk (downloaded
+// inputs, outputs waiting to be delivered) so one user can't fill the disk by
+// submitting jobs faster than they're delivered.
+type Tracker struct {
+	Limit int64 // bytes per user; 0 disables the quota
+
+	mutex sync.Mutex
+	files map[string]map[string]int64 // user ID -> path -> size
+}
+
+// NewTracker returns a tracker allowing each user at most limit bytes.
+func NewTracker(limit int64) *Tracker {
+	return &Tracker{Limit: limit, files: map[string]map[string]int64{}}
+}
+
+// Track charges the file at path to a user. The returned function releases it
+// again and is safe to call more than once. Files that disappear from disk
+// (e.g. removed by the janitor) stop counting even if never released.
+func (t *Tracker) Track(userID string, path string) (release func()) {
+	if t == nil || path == "" {
+		return func() {}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return func() {}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.files == nil {
+		t.files = map[string]map[string]int64{}
+	}
+	if t.files[userID] == nil {
+		t.files[userID] = map[string]int64{}
+	}
+	t.files[userID][path] = info.Size()
+
+	return func() { t.Release(userID, path) }
+}
+
+// Release stops charging the file at path to a user.
+func (t *Tracker) Release(userID string, path string) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.files[userID], path)
+	if len(t.files[userID]) == 0 {
+		delete(t.files, userID)
+	}
+}
+
+// Usage returns the bytes currently charged to a user.
+func (t *Tracker) Usage(userID string) int64 {
+	if t == nil {
+		return 0
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var total int64
+	for path, size := range t.files[userID] {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			delete(t.files[userID], path)
+			continue
+		}
+		total += size
+	}
+	return total
+}
+
+// Check returns an error wrapping ErrOverQuota if the user may not start new jobs.
+func (t *Tracker) Check(userID string) error {
+	if t == nil || t.Limit <= 0 {
+		return nil
+	}
+	if usage := t.Usage(userID); usage >= t.Limit {
+		return fmt.Errorf("%w: %s of %s used by files from your earlier jobs", ErrOverQuota, format.Bytes(usage), format.Bytes(t.Limit))
+	}
+	return nil
+}
+
+// CheckFits is like Check, but also refuses work expected to produce more
+// bytes than the user has left, e.g. a batch whose results are held until
+// the last one finishes.
+func (t *Tracker) CheckFits(userID string, expected int64) error {
+	if t == nil || t.Limit <= 0 {
+		return nil
+	}
+	if usage := t.Usage(userID); usage+expected > t.Limit {
+		return fmt.Errorf("%w: this needs about %s, but only %s of %s is left", ErrOverQuota, format.Bytes(expected), format.Bytes(max(t.Limit-usage, 0)), format.Bytes(t.Limit))
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package quota
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, size int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "artifact")
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0o644))
+	return path
+}
+
+func TestTracker_RefusesOverLimitUntilReleased(t *testing.T) {
+	tracker := NewTracker(100)
+	require.NoError(t, tracker.Check("alice"))
+
+	release := tracker.Track("alice", writeFile(t, 150))
+	require.Equal(t, int64(150), tracker.Usage("alice"))
+	require.ErrorIs(t, tracker.Check("alice"), ErrOverQuota)
+	require.NoError(t, tracker.Check("bob"))
+
+	release()
+	release()
+	require.Zero(t, tracker.Usage("alice"))
+	require.NoError(t, tracker.Check("alice"))
+}
+
+func TestTracker_DeletedFilesStopCounting(t *testing.T) {
+	tracker := NewTracker(100)
+	path := writeFile(t, 150)
+	tracker.Track("alice", path)
+
+	require.NoError(t, os.Remove(path))
+	require.Zero(t, tracker.Usage("alice"))
+	require.NoError(t, tracker.Check("alice"))
+}
+
+func TestTracker_ZeroLimitDisables(t *testing.T) {
+	tracker := NewTracker(0)
+	tracker.Track("alice", writeFile(t, 150))
+	require.NoError(t, tracker.Check("alice"))
+
+	var none *Tracker
+	none.Track("alice", writeFile(t, 1))()
+	require.NoError(t, none.Check("alice"))
+}
+
+func TestTracker_CheckFitsCountsExpectedBytes(t *testing.T) {
+	tracker := NewTracker(100)
+	tracker.Track("alice", writeFile(t, 60))
+
+	require.NoError(t, tracker.CheckFits("alice", 40))
+	require.ErrorIs(t, tracker.CheckFits("alice", 41), ErrOverQuota)
+	require.NoError(t, NewTracker(0).CheckFits("alice", 1<<40))
+}
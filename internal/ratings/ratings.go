@@ -0,0 +1,227 @@
+// Package ratings keeps the 👍 and 👎 votes a guild's results get, for the
+// week's leaderboard.
+package ratings
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"slugbot/internal/store"
+)
+
+const bucket = "ratings"
+
+// The reactions results are voted on with.
+const (
+	Like    = "👍"
+	Dislike = "👎"
+)
+
+// Retention is how long a result's votes are kept; results posted longer ago
+// are dropped the next time a leaderboard is drawn.
+const Retention = 30 * 24 * time.Hour
+
+// Result is one result message and the votes it's had.
+type Result struct {
+	GuildID   string    `json:"guild_id"`
+	ChannelID string    `json:"channel_id"`
+	MessageID string    `json:"message_id"`
+	UserID    string    `json:"user_id"`          // who asked for it
+	Prompt    string    `json:"prompt,omitempty"` // "" if it isn't known, like for a published preview
+	Posted    time.Time `json:"posted"`
+	Likes     int       `json:"likes"`
+	Dislikes  int       `json:"dislikes"`
+}
+
+// Score is how a result ranks: its likes less its dislikes.
+func (r Result) Score() int {
+	return r.Likes - r.Dislikes
+}
+
+// Link jumps to the result message.
+func (r Result) Link() string {
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", r.GuildID, r.ChannelID, r.MessageID)
+}
+
+// UserLikes is how well liked one user's results have been.
+type UserLikes struct {
+	UserID  string
+	Likes   int
+	Results int // how many of their results were liked
+}
+
+// Store persists results and their votes.
+type Store struct {
+	Store *store.Store
+
+	mutex sync.Mutex
+}
+
+// Add starts tracking the votes on a result posted in a guild. It does nothing
+// without a store, or outside a guild.
+func (s *Store) Add(r Result) error {
+	if s == nil || s.Store == nil || r.GuildID == "" {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.Store.Put(bucket, key(r.GuildID, r.MessageID), r)
+}
+
+// Vote counts a reaction added to a message, with delta 1, or taken off it,
+// with -1. Reactions other than Like and Dislike, on messages that aren't
+// tracked results, or by the result's own submitter, aren't counted.
+func (s *Store) Vote(guildID string, messageID string, voterID string, emoji string, delta int) error {
+	if s == nil || s.Store == nil || guildID == "" || (emoji != Like && emoji != Dislike) {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var r Result
+	err := s.Store.Get(bucket, key(guildID, messageID), &r)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if voterID == r.UserID {
+		return nil
+	}
+	if emoji == Like {
+		r.Likes = max(r.Likes+delta, 0)
+	} else {
+		r.Dislikes = max(r.Dislikes+delta, 0)
+	}
+	return s.Store.Put(bucket, key(guildID, messageID), r)
+}
+
+// Top returns up to n of a guild's results posted since since that have at
+// least one like, best first: by score, then by likes, then earliest posted.
+func (s *Store) Top(guildID string, since time.Time, n int) ([]Result, error) {
+	results, err := s.since(guildID, since)
+	if err != nil {
+		return nil, err
+	}
+	results = slices.DeleteFunc(results, func(r Result) bool { return r.Likes == 0 })
+	slices.SortFunc(results, func(a, b Result) int {
+		return cmp.Or(
+			cmp.Compare(b.Score(), a.Score()),
+			cmp.Compare(b.Likes, a.Likes),
+			a.Posted.Compare(b.Posted),
+			strings.Compare(a.MessageID, b.MessageID),
+		)
+	})
+	return results[:min(n, len(results))], nil
+}
+
+// MostLiked returns up to n of the users whose results posted in a guild
+// since since have the most likes between them, most first.
+func (s *Store) MostLiked(guildID string, since time.Time, n int) ([]UserLikes, error) {
+	results, err := s.since(guildID, since)
+	if err != nil {
+		return nil, err
+	}
+	byUser := map[string]*UserLikes{}
+	for _, r := range results {
+		if r.Likes == 0 {
+			continue
+		}
+		user, ok := byUser[r.UserID]
+		if !ok {
+			user = &UserLikes{UserID: r.UserID}
+			byUser[r.UserID] = user
+		}
+		user.Likes += r.Likes
+		user.Results++
+	}
+
+	var users []UserLikes
+	for _, user := range byUser {
+		users = append(users, *user)
+	}
+	slices.SortFunc(users, func(a, b UserLikes) int {
+		return cmp.Or(cmp.Compare(b.Likes, a.Likes), cmp.Compare(a.Results, b.Results), strings.Compare(a.UserID, b.UserID))
+	})
+	return users[:min(n, len(users))], nil
+}
+
+// since returns a guild's results posted since since, dropping any of its
+// results that are older than Retention on the way.
+func (s *Store) since(guildID string, since time.Time) ([]Result, error) {
+	if s == nil || s.Store == nil {
+		return nil, nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keys, err := s.Store.Keys(bucket)
+	if err != nil {
+		return nil, err
+	}
+	expired := time.Now().Add(-Retention)
+	var results []Result
+	for _, k := range keys {
+		if !strings.HasPrefix(k, guildID+"/") {
+			continue
+		}
+		var r Result
+		if err := s.Store.Get(bucket, k, &r); err != nil {
+			return nil, fmt.Errorf("couldn't load rating %s: %w", k, err)
+		}
+		if r.Posted.Before(expired) {
+			if err := s.Store.Delete(bucket, k); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !r.Posted.Before(since) {
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+// RemoveGuild deletes the votes on every result of a guild, and returns how
+// many results it deleted.
+func (s *Store) RemoveGuild(guildID string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.Store.DeletePrefix(bucket, guildID+"/")
+}
+
+// RemoveUser deletes the votes on a user's results, and returns how many
+// results it deleted.
+func (s *Store) RemoveUser(userID string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keys, err := s.Store.Keys(bucket)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, k := range keys {
+		var r Result
+		if err := s.Store.Get(bucket, k, &r); err != nil {
+			return removed, fmt.Errorf("couldn't load rating %s: %w", k, err)
+		}
+		if r.UserID != userID {
+			continue
+		}
+		if err := s.Store.Delete(bucket, k); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func key(guildID string, messageID string) string {
+	return guildID + "/" + messageID
+}
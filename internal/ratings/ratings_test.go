@@ -0,0 +1,81 @@
+package ratings
+
+import (
+	"testing"
+	"time"
+
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newStore(t *testing.T) *Store {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	return &Store{Store: s}
+}
+
+func TestStore_RanksTheWeeksMostLikedResults(t *testing.T) {
+	s := newStore(t)
+	now := time.Now()
+	for _, r := range []Result{
+		{GuildID: "g1", ChannelID: "c", MessageID: "old", UserID: "u1", Posted: now.Add(-8 * 24 * time.Hour)},
+		{GuildID: "g1", ChannelID: "c", MessageID: "a", UserID: "u1", Prompt: "lofi", Posted: now.Add(-2 * time.Hour)},
+		{GuildID: "g1", ChannelID: "c", MessageID: "b", UserID: "u2", Prompt: "jazz", Posted: now.Add(-time.Hour)},
+		{GuildID: "g1", ChannelID: "c", MessageID: "c", UserID: "u1", Prompt: "rain", Posted: now},
+		{GuildID: "g2", ChannelID: "c", MessageID: "d", UserID: "u3", Posted: now},
+	} {
+		require.NoError(t, s.Add(r))
+	}
+	vote := func(messageID string, guildID string, voterID string, emoji string, delta int) {
+		require.NoError(t, s.Vote(guildID, messageID, voterID, emoji, delta))
+	}
+	vote("old", "g1", "v1", Like, 1)
+	vote("a", "g1", "v1", Like, 1)
+	vote("a", "g1", "v2", Like, 1)
+	vote("a", "g1", "v3", Dislike, 1)
+	vote("b", "g1", "v1", Like, 1)
+	vote("b", "g1", "v2", Like, 1)
+	vote("b", "g1", "v2", "🎉", 1)  // not a vote
+	vote("c", "g1", "u1", Like, 1) // its own submitter
+	vote("c", "g1", "v1", Like, 1) // taken back
+	vote("c", "g1", "v1", Like, -1)
+	vote("d", "g2", "v1", Like, 1)       // another guild
+	vote("unknown", "g1", "v1", Like, 1) // not a result
+
+	top, err := s.Top("g1", now.Add(-7*24*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, top, 2)
+	require.Equal(t, "b", top[0].MessageID)
+	require.Equal(t, "a", top[1].MessageID)
+	require.Equal(t, 1, top[1].Score())
+	require.Equal(t, "https://discord.com/channels/g1/c/a", top[1].Link())
+
+	users, err := s.MostLiked("g1", now.Add(-7*24*time.Hour), 5)
+	require.NoError(t, err)
+	require.Equal(t, []UserLikes{{UserID: "u1", Likes: 2, Results: 1}, {UserID: "u2", Likes: 2, Results: 1}}, users)
+}
+
+func TestStore_DropsExpiredAndForgottenResults(t *testing.T) {
+	s := newStore(t)
+	require.NoError(t, s.Add(Result{GuildID: "g1", MessageID: "expired", UserID: "u1", Posted: time.Now().Add(-Retention - time.Hour)}))
+	require.NoError(t, s.Add(Result{GuildID: "g1", MessageID: "a", UserID: "u1", Posted: time.Now()}))
+	require.NoError(t, s.Add(Result{GuildID: "g1", MessageID: "b", UserID: "u2", Posted: time.Now()}))
+	require.NoError(t, s.Add(Result{GuildID: "g2", MessageID: "c", UserID: "u2", Posted: time.Now()}))
+
+	_, err := s.Top("g1", time.Time{}, 10)
+	require.NoError(t, err)
+	keys, err := s.Store.Keys(bucket)
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+
+	n, err := s.RemoveUser("u1")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	n, err = s.RemoveGuild("g2")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	keys, err = s.Store.Keys(bucket)
+	require.NoError(t, err)
+	require.Equal(t, []string{"g1/b"}, keys)
+}
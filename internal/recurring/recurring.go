@@ -0,0 +1,184 @@
+// Package recurring keeps admin-defined jobs that run a command on a cron
+// schedule, and schedules them across restarts.
+package recurring
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"slugbot/internal/io/slog"
+	"slugbot/internal/schedule"
+	"slugbot/internal/store"
+)
+
+const bucket = "recurring"
+
+// maxMissedScan bounds how many missed times are stepped through when
+// looking for a job's latest missed run.
+const maxMissedScan = 100_000
+
+// Job runs Command in a channel whenever Cron comes due.
+type Job struct {
+	Name      string    `json:"name"`
+	GuildID   string    `json:"guild_id"`
+	ChannelID string    `json:"channel_id"`
+	Cron      string    `json:"cron"`
+	Command   string    `json:"command"`    // run as if CreatedBy typed it, e.g. ".saudio lofi ambience --length 60"
+	CreatedBy string    `json:"created_by"` // user ID the runs are attributed to
+	Created   time.Time `json:"created"`
+	LastRun   time.Time `json:"last_run"` // scheduled time of the latest run; zero if it hasn't run
+}
+
+func (j Job) key() string {
+	return j.GuildID + "/" + j.Name
+}
+
+// Runner schedules stored jobs with a Scheduler and records each run.
+type Runner struct {
+	Store     *store.Store
+	Scheduler *schedule.Scheduler
+	Run       func(job Job) // starts one run of a job
+
+	// CatchUpWithin decides what happens to runs missed while the bot was
+	// down: if the latest one was due at most this long ago, the job runs
+	// once on startup; otherwise the missed runs are skipped. 0 always skips.
+	CatchUpWithin time.Duration
+
+	mutex sync.Mutex
+}
+
+// Load schedules every stored job, catching up on missed runs as
+// CatchUpWithin allows. now is the time the bot came up.
+func (r *Runner) Load(now time.Time) error {
+	keys, err := r.Store.Keys(bucket)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		var job Job
+		if err := r.Store.Get(bucket, key, &job); err != nil {
+			return fmt.Errorf("couldn't load recurring job %s: %w", key, err)
+		}
+		cron, err := schedule.ParseCron(job.Cron)
+		if err != nil {
+			slog.Warn("skipping recurring job ", key, ": ", err)
+			continue
+		}
+		r.schedule(job, cron)
+
+		if missed, ok := LatestMissed(cron, job, now); ok {
+			if r.CatchUpWithin > 0 && now.Sub(missed) <= r.CatchUpWithin {
+				slog.Info("recurring job ", key, " missed its run at ", missed.Format(time.DateTime), "; running it now")
+				r.fire(job.GuildID, job.Name, missed)
+			} else {
+				slog.Warn("recurring job ", key, " missed its run at ", missed.Format(time.DateTime), "; skipping it")
+			}
+		}
+	}
+	return nil
+}
+
+// LatestMissed returns the latest time a job was due, at or before now, that
+// it didn't run at.
+func LatestMissed(cron *schedule.Cron, job Job, now time.Time) (time.Time, bool) {
+	from := job.LastRun
+	if from.IsZero() {
+		from = job.Created
+	}
+	var missed time.Time
+	for i, t := 0, cron.Next(from); i < maxMissedScan && !t.IsZero() && !t.After(now); i, t = i+1, cron.Next(t) {
+		missed = t
+	}
+	return missed, !missed.IsZero()
+}
+
+// Add saves a job and schedules it, replacing any job of the same name in its guild.
+func (r *Runner) Add(job Job) error {
+	cron, err := schedule.ParseCron(job.Cron)
+	if err != nil {
+		return err
+	}
+	job.Cron = cron.String()
+	if err := r.Store.Put(bucket, job.key(), job); err != nil {
+		return err
+	}
+	r.schedule(job, cron)
+	return nil
+}
+
+// Remove deletes and unschedules a job. It reports whether the job existed.
+func (r *Runner) Remove(guildID string, name string) (bool, error) {
+	job := Job{GuildID: guildID, Name: name}
+	var existing Job
+	if err := r.Store.Get(bucket, job.key(), &existing); errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if err := r.Store.Delete(bucket, job.key()); err != nil {
+		return false, err
+	}
+	r.Scheduler.Remove(schedulerName(job))
+	return true, nil
+}
+
+// List returns a guild's jobs, sorted by name.
+func (r *Runner) List(guildID string) ([]Job, error) {
+	keys, err := r.Store.Keys(bucket)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []Job
+	for _, key := range keys {
+		if !strings.HasPrefix(key, guildID+"/") {
+			continue
+		}
+		var job Job
+		if err := r.Store.Get(bucket, key, &job); err != nil {
+			return nil, fmt.Errorf("couldn't load recurring job %s: %w", key, err)
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs, nil
+}
+
+// Next returns when a job next runs.
+func (r *Runner) Next(job Job) (time.Time, bool) {
+	return r.Scheduler.Next(schedulerName(job))
+}
+
+func (r *Runner) schedule(job Job, cron *schedule.Cron) {
+	guildID, name := job.GuildID, job.Name
+	r.Scheduler.Add(schedulerName(job), cron, func(at time.Time) {
+		r.fire(guildID, name, at)
+	})
+}
+
+// fire records a run scheduled for at and starts it, using the job as
+// currently stored so edits made since it was scheduled apply.
+func (r *Runner) fire(guildID string, name string, at time.Time) {
+	r.mutex.Lock()
+	job := Job{GuildID: guildID, Name: name}
+	err := r.Store.Get(bucket, job.key(), &job)
+	if err == nil {
+		job.LastRun = at
+		err = r.Store.Put(bucket, job.key(), job)
+	}
+	r.mutex.Unlock()
+	if errors.Is(err, store.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		slog.Error("couldn't record run of recurring job ", job.key(), ": ", err)
+	}
+	r.Run(job)
+}
+
+func schedulerName(job Job) string {
+	return "recurring:" + job.key()
+}
@@ -0,0 +1,73 @@
+package recurring
+
+import (
+	"testing"
+	"time"
+
+	"slugbot/internal/schedule"
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newRunner(t *testing.T, catchUp time.Duration) (*Runner, *[]Job) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	var runs []Job
+	return &Runner{
+		Store:         s,
+		Scheduler:     &schedule.Scheduler{},
+		Run:           func(job Job) { runs = append(runs, job) },
+		CatchUpWithin: catchUp,
+	}, &runs
+}
+
+func TestRunner_CatchesUpOnRecentMissedRun(t *testing.T) {
+	now := time.Now()
+	runner, runs := newRunner(t, 2*time.Hour)
+
+	// due every minute, last run an hour ago: the latest miss is a minute old at most
+	require.NoError(t, runner.Add(Job{Name: "lofi", GuildID: "g1", Cron: "* * * * *", Command: ".saudio lofi", LastRun: now.Add(-time.Hour)}))
+	require.NoError(t, runner.Load(now))
+	require.Len(t, *runs, 1)
+
+	jobs, err := runner.List("g1")
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	require.True(t, jobs[0].LastRun.After(now.Add(-2*time.Minute)))
+}
+
+func TestRunner_SkipsStaleMissedRun(t *testing.T) {
+	now := time.Now()
+	runner, runs := newRunner(t, time.Minute)
+
+	require.NoError(t, runner.Add(Job{Name: "weekly", GuildID: "g1", Cron: "0 0 1 1 *", Created: now.AddDate(-2, 0, 0)}))
+	require.NoError(t, runner.Load(now))
+	require.Empty(t, *runs)
+}
+
+func TestRunner_RemoveAndList(t *testing.T) {
+	runner, _ := newRunner(t, 0)
+	require.NoError(t, runner.Add(Job{Name: "b", GuildID: "g1", Cron: "0 8 * * *", Created: time.Now()}))
+	require.NoError(t, runner.Add(Job{Name: "a", GuildID: "g1", Cron: "0 8 * * *", Created: time.Now()}))
+	require.NoError(t, runner.Add(Job{Name: "a", GuildID: "g2", Cron: "0 8 * * *", Created: time.Now()}))
+	require.Error(t, runner.Add(Job{Name: "bad", GuildID: "g1", Cron: "every day"}))
+
+	jobs, err := runner.List("g1")
+	require.NoError(t, err)
+	require.Equal(t, "a", jobs[0].Name)
+	require.Len(t, jobs, 2)
+
+	_, scheduled := runner.Next(jobs[0])
+	require.True(t, scheduled)
+
+	removed, err := runner.Remove("g1", "a")
+	require.NoError(t, err)
+	require.True(t, removed)
+	_, scheduled = runner.Next(jobs[0])
+	require.False(t, scheduled)
+
+	removed, err = runner.Remove("g1", "a")
+	require.NoError(t, err)
+	require.False(t, removed)
+}
@@ -0,0 +1,96 @@
+// Package results formats the message a generation's result is posted with,
+// from a text/template each guild can choose.
+package results
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"slugbot/internal/io/slog"
+	"slugbot/internal/policy"
+)
+
+// MaxLength bounds a rendered template, leaving room in the message for the
+// attribution footer.
+const MaxLength = 1500
+
+// Fields are what a result template can show.
+type Fields struct {
+	Prompt    string // the prompt that was generated from, after any enhancement
+	Seed      int64  // -1 if the seed was random
+	Duration  string // how long generation took, e.g. "1m 12s"
+	Model     string // e.g. "Stable Audio Open 1.0"
+	Submitter string // a mention of whoever asked for it; it's shown as their name without pinging them
+}
+
+// Sample fills every field, so Check can catch templates that only fail on
+// some results, and shows admins what theirs looks like.
+var Sample = Fields{
+	Prompt:    "lofi hip hop beat",
+	Seed:      1234,
+	Duration:  "42s",
+	Model:     "Stable Audio Open 1.0",
+	Submitter: "<@0>",
+}
+
+// Parse parses a result template.
+func Parse(text string) (*template.Template, error) {
+	tmpl, err := template.New("result").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Check reports whether a template parses and renders for a sample result,
+// e.g. before a guild saves it.
+func Check(text string) error {
+	_, err := Render(text, Sample)
+	return err
+}
+
+// Render fills in a template for a result, cut to MaxLength.
+func Render(text string, fields Fields) (string, error) {
+	tmpl, err := Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, fields); err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	rendered := []rune(strings.TrimSpace(out.String()))
+	if len(rendered) > MaxLength {
+		rendered = append(rendered[:MaxLength-1], '…')
+	}
+	return string(rendered), nil
+}
+
+// Formatter renders each guild's result template. A nil Formatter, or an
+// empty template, leaves results without text.
+type Formatter struct {
+	Policies *policy.Policies
+}
+
+// Content returns the text to post a result with in a guild. A template that
+// fails is logged and leaves the result without text, rather than holding up
+// its delivery.
+func (f *Formatter) Content(guildID string, fields Fields) string {
+	if f == nil {
+		return ""
+	}
+	text, err := f.Policies.ResultTemplate(guildID)
+	if err != nil {
+		slog.Warn(err)
+	}
+	if text == "" {
+		return ""
+	}
+	content, err := Render(text, fields)
+	if err != nil {
+		slog.Warn("couldn't render result template for guild ", guildID, ": ", err)
+		return ""
+	}
+	return content
+}
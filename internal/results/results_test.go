@@ -0,0 +1,49 @@
+package results
+
+import (
+	"strings"
+	"testing"
+
+	"slugbot/internal/policy"
+	"slugbot/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender_FillsFields(t *testing.T) {
+	content, err := Render("{{.Submitter}}: **{{.Prompt}}** (seed {{.Seed}}, {{.Model}}, {{.Duration}})", Sample)
+	require.NoError(t, err)
+	require.Equal(t, "<@0>: **lofi hip hop beat** (seed 1234, Stable Audio Open 1.0, 42s)", content)
+}
+
+func TestRender_CutsLongResults(t *testing.T) {
+	fields := Sample
+	fields.Prompt = strings.Repeat("a", 2*MaxLength)
+	content, err := Render("{{.Prompt}}", fields)
+	require.NoError(t, err)
+	require.Len(t, []rune(content), MaxLength)
+	require.True(t, strings.HasSuffix(content, "…"))
+}
+
+func TestCheck_RejectsBadTemplates(t *testing.T) {
+	require.NoError(t, Check("{{if ge .Seed 0}}seed {{.Seed}}{{end}}"))
+	require.Error(t, Check("{{.Prompt"))
+	require.Error(t, Check("{{.Author}}"))
+}
+
+func TestFormatter_UsesGuildTemplate(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	policies := &policy.Policies{Store: s}
+	formatter := &Formatter{Policies: policies}
+
+	require.Empty(t, formatter.Content("g1", Sample))
+
+	text := "made with {{.Model}}"
+	require.NoError(t, policies.SetGuildResults("g1", policy.Results{Template: &text}))
+	require.Equal(t, "made with Stable Audio Open 1.0", formatter.Content("g1", Sample))
+	require.Empty(t, formatter.Content("g2", Sample))
+
+	var none *Formatter
+	require.Empty(t, none.Content("g1", Sample))
+}
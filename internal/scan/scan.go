@@ -0,0 +1,154 @@
+// Package scan checks files users send the bot, like init audio and images,
+// with a virus or abuse scanner before they're processed: clamd, or an HTTP
+// service that takes the file and says whether to refuse it.
+package scan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrFlagged is returned for a file the scanner flagged.
+var ErrFlagged = errors.New("the file was flagged by the scanner")
+
+// Verdict is what a scanner made of a file.
+type Verdict struct {
+	Flagged bool   `json:"flagged"`
+	Reason  string `json:"reason"` // e.g. the name of the signature that matched
+}
+
+// Scanner checks a file.
+type Scanner interface {
+	Scan(path string) (Verdict, error)
+}
+
+// chunkSize is how much of a file is sent to clamd at a time.
+const chunkSize = 64 * 1024
+
+// Clamd scans files with a clamd daemon's INSTREAM command, so clamd needn't
+// be able to read the bot's files.
+type Clamd struct {
+	Address string        // a unix socket path, e.g. "/run/clamav/clamd.ctl", or "host:port"
+	Timeout time.Duration // for the whole scan; 0 for no limit
+}
+
+func (c *Clamd) Scan(path string) (Verdict, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer file.Close()
+
+	network := "tcp"
+	if strings.HasPrefix(c.Address, "/") {
+		network = "unix"
+	}
+	conn, err := net.DialTimeout(network, c.Address, c.timeout())
+	if err != nil {
+		return Verdict{}, fmt.Errorf("couldn't reach clamd: %w", err)
+	}
+	defer conn.Close()
+	if c.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Verdict{}, fmt.Errorf("couldn't send to clamd: %w", err)
+	}
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			if err := binary.Write(conn, binary.BigEndian, uint32(n)); err != nil {
+				return Verdict{}, fmt.Errorf("couldn't send to clamd: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return Verdict{}, fmt.Errorf("couldn't send to clamd: %w", err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Verdict{}, err
+		}
+	}
+	if err := binary.Write(conn, binary.BigEndian, uint32(0)); err != nil {
+		return Verdict{}, fmt.Errorf("couldn't send to clamd: %w", err)
+	}
+
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("couldn't read clamd's reply: %w", err)
+	}
+	return parseClamdReply(string(reply))
+}
+
+func (c *Clamd) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return 10 * time.Second
+}
+
+// parseClamdReply reads a reply like "stream: OK" or
+// "stream: Eicar-Test-Signature FOUND".
+func parseClamdReply(reply string) (Verdict, error) {
+	reply = strings.TrimRight(reply, "\x00\n")
+	result := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case result == "OK":
+		return Verdict{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return Verdict{Flagged: true, Reason: strings.TrimSuffix(result, " FOUND")}, nil
+	default:
+		return Verdict{}, fmt.Errorf("clamd couldn't scan the file: %s", reply)
+	}
+}
+
+// HTTP scans files by POSTing them to a scanning service, which answers with
+// a Verdict as JSON, e.g. {"flagged": true, "reason": "malware"}.
+type HTTP struct {
+	URL    string
+	Client *http.Client // optional; defaults to http.DefaultClient
+}
+
+func (h *HTTP) Scan(path string) (Verdict, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer file.Close()
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(h.URL, "application/octet-stream", file)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("couldn't reach the scanner: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return Verdict{}, fmt.Errorf("couldn't read the scanner's reply: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Verdict{}, fmt.Errorf("the scanner answered %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	var verdict Verdict
+	if err := json.Unmarshal(body, &verdict); err != nil {
+		return Verdict{}, fmt.Errorf("couldn't parse the scanner's reply: %w", err)
+	}
+	return verdict, nil
+}
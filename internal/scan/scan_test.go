@@ -0,0 +1,109 @@
+package scan
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClamd answers INSTREAM scans, flagging streams that contain "EICAR".
+func fakeClamd(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				command := make([]byte, len("zINSTREAM\x00"))
+				if _, err := io.ReadFull(conn, command); err != nil || string(command) != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var data []byte
+				for {
+					var size uint32
+					if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					chunk := make([]byte, size)
+					if _, err := io.ReadFull(conn, chunk); err != nil {
+						return
+					}
+					data = append(data, chunk...)
+				}
+				if strings.Contains(string(data), "EICAR") {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "in.wav")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestClamd_Scan(t *testing.T) {
+	clamd := &Clamd{Address: fakeClamd(t), Timeout: 5 * time.Second}
+
+	verdict, err := clamd.Scan(writeFile(t, strings.Repeat("x", 3*chunkSize)))
+	require.NoError(t, err)
+	require.False(t, verdict.Flagged)
+
+	verdict, err = clamd.Scan(writeFile(t, strings.Repeat("x", chunkSize-2)+"EICAR"))
+	require.NoError(t, err)
+	require.Equal(t, Verdict{Flagged: true, Reason: "Eicar-Test-Signature"}, verdict)
+
+	_, err = parseClamdReply("stream: Can't allocate memory ERROR\x00")
+	require.Error(t, err)
+}
+
+func TestHTTP_Scan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch string(body) {
+		case "bad":
+			w.Write([]byte(`{"flagged": true, "reason": "malware"}`))
+		case "broken":
+			http.Error(w, "scanner unavailable", http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{"flagged": false}`))
+		}
+	}))
+	defer server.Close()
+	scanner := &HTTP{URL: server.URL}
+
+	verdict, err := scanner.Scan(writeFile(t, "fine"))
+	require.NoError(t, err)
+	require.False(t, verdict.Flagged)
+
+	verdict, err = scanner.Scan(writeFile(t, "bad"))
+	require.NoError(t, err)
+	require.Equal(t, Verdict{Flagged: true, Reason: "malware"}, verdict)
+
+	_, err = scanner.Scan(writeFile(t, "broken"))
+	require.ErrorContains(t, err, "scanner unavailable")
+}
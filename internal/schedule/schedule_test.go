@@ -0,0 +1,67 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func at(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestCron_Next(t *testing.T) {
+	cases := []struct {
+		expr, after, want string
+	}{
+		{"0 17 * * *", "2026-03-04 16:59", "2026-03-04 17:00"},
+		{"0 17 * * *", "2026-03-04 17:00", "2026-03-05 17:00"},
+		{"*/15 * * * *", "2026-03-04 10:07", "2026-03-04 10:15"},
+		{"30 9 * * 1-5", "2026-03-06 10:00", "2026-03-09 09:30"}, // Friday after 9:30 -> Monday
+		{"0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00"},
+		{"0 12 1 * 7", "2026-03-02 00:00", "2026-03-08 12:00"}, // either the 1st or a Sunday
+	}
+	for _, tc := range cases {
+		c, err := ParseCron(tc.expr)
+		require.NoError(t, err, tc.expr)
+		require.Equal(t, at(tc.want), c.Next(at(tc.after)), tc.expr)
+	}
+}
+
+func TestParseCron_RejectsBadExpressions(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "0 0 31 2 *"} {
+		_, err := ParseCron(expr)
+		require.Error(t, err, expr)
+	}
+}
+
+func TestScheduler_RunsDueJobsOnce(t *testing.T) {
+	c, err := ParseCron("* * * * *")
+	require.NoError(t, err)
+
+	var runs []time.Time
+	s := &Scheduler{}
+	s.Add("job", c, func(at time.Time) { runs = append(runs, at) })
+
+	next, ok := s.Next("job")
+	require.True(t, ok)
+
+	s.Tick(next.Add(-time.Second))
+	require.Empty(t, runs)
+
+	// a tick long after several missed minutes runs the job once
+	s.Tick(next.Add(10 * time.Minute))
+	require.Equal(t, []time.Time{next}, runs)
+
+	later, _ := s.Next("job")
+	require.True(t, later.After(next.Add(10*time.Minute)))
+
+	require.True(t, s.Remove("job"))
+	s.Tick(later.Add(time.Hour))
+	require.Len(t, runs, 1)
+}
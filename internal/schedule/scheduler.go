@@ -0,0 +1,100 @@
+package schedule
+
+import (
+	"sync"
+	"time"
+
+	"slugbot/internal/io/slog"
+)
+
+// entry is a job registered with a Scheduler.
+type entry struct {
+	cron *Cron
+	run  func(at time.Time)
+	next time.Time
+}
+
+// Scheduler runs jobs when their cron expressions come due. It checks the
+// time every Interval, so a job runs up to one Interval late, and runs once
+// for all of the times it missed if the process was paused or the clock jumped.
+type Scheduler struct {
+	Interval time.Duration // defaults to 30 seconds
+
+	mutex sync.Mutex
+	jobs  map[string]*entry
+}
+
+// Add registers run to be called with its scheduled time whenever cron comes
+// due, replacing any job with the same name.
+func (s *Scheduler) Add(name string, cron *Cron, run func(at time.Time)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.jobs == nil {
+		s.jobs = map[string]*entry{}
+	}
+	s.jobs[name] = &entry{cron: cron, run: run, next: cron.Next(time.Now())}
+}
+
+// Remove unregisters a job. It reports whether the job existed.
+func (s *Scheduler) Remove(name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, ok := s.jobs[name]
+	delete(s.jobs, name)
+	return ok
+}
+
+// Next returns when a job next runs.
+func (s *Scheduler) Next(name string) (time.Time, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	job, ok := s.jobs[name]
+	if !ok {
+		return time.Time{}, false
+	}
+	return job.next, true
+}
+
+// Start checks for due jobs every Interval until done is closed.
+func (s *Scheduler) Start(done <-chan struct{}) {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			s.Tick(now)
+		}
+	}
+}
+
+// Tick runs every job due at now, one at a time, and schedules each one's next run.
+func (s *Scheduler) Tick(now time.Time) {
+	type due struct {
+		name string
+		at   time.Time
+		run  func(time.Time)
+	}
+	var runs []due
+
+	s.mutex.Lock()
+	for name, job := range s.jobs {
+		if job.next.IsZero() || job.next.After(now) {
+			continue
+		}
+		runs = append(runs, due{name, job.next, job.run})
+		job.next = job.cron.Next(now)
+	}
+	s.mutex.Unlock()
+
+	for _, d := range runs {
+		slog.Info("running scheduled job ", d.name)
+		d.run(d.at)
+	}
+}
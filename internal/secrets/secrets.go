@@ -0,0 +1,116 @@
+// Package secrets keeps the bot's credentials in the OS keyring, which
+// encrypts them at rest. Each secret can also be supplied through an
+// environment variable, for hosts without a keyring.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+// service is the keyring service every secret is stored under.
+const service = "slugbot-production"
+
+// ErrNotFound is returned by Get when a secret isn't set anywhere.
+var ErrNotFound = errors.New("secret not set")
+
+// Names of the secrets the bot knows about.
+const (
+	APIToken        = "api_token"
+	DashboardSecret = "dashboard_client_secret"
+	DiscordToken    = "token"
+	LLMAPIKey       = "llm_api_key"
+	MirrorToken     = "mirror_token"
+	S3SecretKey     = "s3_secret_key"
+	SMTPPassword    = "smtp_password"
+	WebhookSecret   = "webhook_secret"
+)
+
+// Known describes each secret, for `slugbot secrets list`.
+var Known = map[string]string{
+	APIToken:        "bearer token for the gRPC control API",
+	DashboardSecret: "Discord OAuth2 client secret for the web dashboard",
+	DiscordToken:    "Discord bot token",
+	LLMAPIKey:       "API key for the [llm] endpoint",
+	MirrorToken:     "Discord bot token for the staging bot `slugbot mirror` runs",
+	S3SecretKey:     "secret access key for S3 storage",
+	SMTPPassword:    "password for the [notify] SMTP server",
+	WebhookSecret:   "signing secret for webhooks",
+}
+
+// EnvVar returns the environment variable that overrides a secret, e.g.
+// SLUGBOT_LLM_API_KEY.
+func EnvVar(name string) string {
+	return "SLUGBOT_" + strings.ToUpper(name)
+}
+
+// Get returns a secret from its environment variable if that's set, or else
+// from the keyring.
+func Get(name string) (string, error) {
+	if value := os.Getenv(EnvVar(name)); value != "" {
+		return value, nil
+	}
+	value, err := keyring.Get(service, name)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("couldn't read secret %s: %w", name, err)
+	}
+	return value, nil
+}
+
+// Set stores a secret in the keyring.
+func Set(name string, value string) error {
+	if err := validate(name); err != nil {
+		return err
+	}
+	if err := keyring.Set(service, name, value); err != nil {
+		return fmt.Errorf("couldn't save secret %s: %w", name, err)
+	}
+	return nil
+}
+
+// Delete removes a secret from the keyring. Deleting a missing secret is not an error.
+func Delete(name string) error {
+	if err := validate(name); err != nil {
+		return err
+	}
+	if err := keyring.Delete(service, name); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("couldn't delete secret %s: %w", name, err)
+	}
+	return nil
+}
+
+// Status reports where a secret is set: "env", "keyring", or "" if nowhere.
+func Status(name string) string {
+	if os.Getenv(EnvVar(name)) != "" {
+		return "env"
+	}
+	if _, err := keyring.Get(service, name); err == nil {
+		return "keyring"
+	}
+	return ""
+}
+
+// Names returns the known secret names, sorted.
+func Names() []string {
+	names := make([]string, 0, len(Known))
+	for name := range Known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func validate(name string) error {
+	if _, ok := Known[name]; !ok {
+		return fmt.Errorf("unknown secret %q; must be one of %s", name, strings.Join(Names(), ", "))
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
+)
+
+func TestSecrets_KeyringAndEnvOverride(t *testing.T) {
+	keyring.MockInit()
+
+	_, err := Get(LLMAPIKey)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Equal(t, "", Status(LLMAPIKey))
+
+	require.NoError(t, Set(LLMAPIKey, "from-keyring"))
+	value, err := Get(LLMAPIKey)
+	require.NoError(t, err)
+	require.Equal(t, "from-keyring", value)
+	require.Equal(t, "keyring", Status(LLMAPIKey))
+
+	t.Setenv("SLUGBOT_LLM_API_KEY", "from-env")
+	value, err = Get(LLMAPIKey)
+	require.NoError(t, err)
+	require.Equal(t, "from-env", value)
+	require.Equal(t, "env", Status(LLMAPIKey))
+
+	require.NoError(t, Delete(LLMAPIKey))
+	require.NoError(t, Delete(LLMAPIKey))
+	require.Error(t, Set("nope", "x"))
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned by Get when a key doesn't exist in a bucket.
+var ErrNotFound = errors.New("store: not found")
+
+// Store is a small persistent key/value store. Each bucket is kept as one JSON
+// file in the store's directory and rewritten atomically on every change, which
+// is plenty for the bot's write volume (a handful of writes per job).
+type Store struct {
+	dir     string
+	mutex   sync.Mutex
+	buckets map[string]map[string]json.RawMessage
+}
+
+// Open creates the store directory if needed and returns a Store rooted there.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("couldn't create store directory: %w", err)
+	}
+	return &Store{dir: dir, buckets: map[string]map[string]json.RawMessage{}}, nil
+}
+
+// Get decodes the value stored under key into out.
+func (s *Store) Get(bucket, key string, out any) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b, err := s.load(bucket)
+	if err != nil {
+		return err
+	}
+	raw, ok := b[key]
+	if !ok {
+		return ErrNotFound
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// Put stores value under key, replacing any previous value.
+func (s *Store) Put(bucket, key string, value any) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("couldn't encode value for %s/%s: %w", bucket, key, err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b, err := s.load(bucket)
+	if err != nil {
+		return err
+	}
+	b[key] = raw
+	return s.flush(bucket)
+}
+
+// Delete removes key from a bucket. Deleting a missing key is not an error.
+func (s *Store) Delete(bucket, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b, err := s.load(bucket)
+	if err != nil {
+		return err
+	}
+	if _, ok := b[key]; !ok {
+		return nil
+	}
+	delete(b, key)
+	return s.flush(bucket)
+}
+
+// DeletePrefix removes every key in a bucket that starts with prefix, and
+// returns how many it removed.
+func (s *Store) DeletePrefix(bucket, prefix string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b, err := s.load(bucket)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for key := range b {
+		if strings.HasPrefix(key, prefix) {
+			delete(b, key)
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return 0, nil
+	}
+	return deleted, s.flush(bucket)
+}
+
+// Keys returns all keys in a bucket, sorted.
+func (s *Store) Keys(bucket string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b, err := s.load(bucket)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(b))
+	for key := range b {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// load returns the cached bucket, reading it from disk on first use. Callers hold the mutex.
+func (s *Store) load(bucket string) (map[string]json.RawMessage, error) {
+	if b, ok := s.buckets[bucket]; ok {
+		return b, nil
+	}
+
+	b := map[string]json.RawMessage{}
+	data, err := os.ReadFile(s.path(bucket))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("couldn't read bucket '%s': %w", bucket, err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &b); err != nil {
+			return nil, fmt.Errorf("couldn't decode bucket '%s': %w", bucket, err)
+		}
+	}
+	s.buckets[bucket] = b
+	return b, nil
+}
+
+// flush writes a bucket to a temp file and renames it into place. Callers hold the mutex.
+func (s *Store) flush(bucket string) error {
+	data, err := json.MarshalIndent(s.buckets[bucket], "", "  ")
+	if err != nil {
+		return fmt.Errorf("couldn't encode bucket '%s': %w", bucket, err)
+	}
+	tmp, err := os.CreateTemp(s.dir, bucket+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("couldn't write bucket '%s': %w", bucket, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("couldn't write bucket '%s': %w", bucket, err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), s.path(bucket)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("couldn't write bucket '%s': %w", bucket, err)
+	}
+	return nil
+}
+
+func (s *Store) path(bucket string) string {
+	return filepath.Join(s.dir, bucket+".json")
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testValue struct {
+	Name  string
+	Count int
+}
+
+func TestStore_PutGetRoundTrip(t *testing.T) {
+	s, err := Open(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, s.Put("things", "a", testValue{Name: "first", Count: 1}))
+
+	var got testValue
+	require.NoError(t, s.Get("things", "a", &got))
+	require.Equal(t, testValue{Name: "first", Count: 1}, got)
+}
+
+func TestStore_GetMissing(t *testing.T) {
+	s, err := Open(t.TempDir())
+	require.NoError(t, err)
+
+	var got testValue
+	require.ErrorIs(t, s.Get("things", "nope", &got), ErrNotFound)
+}
+
+func TestStore_PersistsAcrossOpen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, s.Put("things", "b", testValue{Name: "second"}))
+	require.NoError(t, s.Put("things", "a", testValue{Name: "first"}))
+
+	reopened, err := Open(dir)
+	require.NoError(t, err)
+	keys, err := reopened.Keys("things")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, keys)
+}
+
+func TestStore_Delete(t *testing.T) {
+	s, err := Open(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, s.Put("things", "a", testValue{}))
+
+	require.NoError(t, s.Delete("things", "a"))
+	require.NoError(t, s.Delete("things", "a"))
+
+	keys, err := s.Keys("things")
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
+
+func TestStore_DeletePrefix(t *testing.T) {
+	s, err := Open(t.TempDir())
+	require.NoError(t, err)
+	for _, key := range []string{"g1/a", "g1/b", "g10/a", "g2/a"} {
+		require.NoError(t, s.Put("things", key, testValue{}))
+	}
+
+	deleted, err := s.DeletePrefix("things", "g1/")
+	require.NoError(t, err)
+	require.Equal(t, 2, deleted)
+	deleted, err = s.DeletePrefix("things", "g1/")
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+
+	keys, err := s.Keys("things")
+	require.NoError(t, err)
+	require.Equal(t, []string{"g10/a", "g2/a"}, keys)
+}
@@ -0,0 +1,117 @@
+// Package tools finds the external programs the bot runs on whatever OS it's
+// on: the path set for a tool under [tools], or its default, looked up on
+// PATH if it's a bare name, with Windows' .exe suffix filled in.
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"slugbot/internal/config"
+)
+
+// The tools the bot runs, as named under [tools].
+const (
+	Sag      = "sag"
+	Python   = "python"
+	Magick   = "magick"
+	FFmpeg   = "ffmpeg"
+	QREncode = "qrencode"
+)
+
+// goos is the OS paths are resolved for; tests replace it.
+var goos = runtime.GOOS
+
+// extraDirs are searched after PATH, for hosts whose services start with a
+// minimal PATH, e.g. macOS launchd missing Homebrew's directories.
+var extraDirs = map[string][]string{
+	"darwin": {"/opt/homebrew/bin", "/usr/local/bin"},
+}
+
+// defaults returns the path of a tool when [tools] doesn't set one.
+func defaults(tool string) string {
+	switch tool {
+	case Sag:
+		return "stable-audio/sag"
+	case Python:
+		// conda puts the interpreter at the top of the environment on Windows
+		if goos == "windows" {
+			return ".conda/general-dsp/python"
+		}
+		return ".conda/general-dsp/bin/python"
+	}
+	return tool
+}
+
+// Path returns the program to run for a tool.
+func Path(tool string) string {
+	cfg := config.Get().Tools
+	path := map[string]string{Sag: cfg.Sag, Python: cfg.Python, Magick: cfg.Magick, FFmpeg: cfg.FFmpeg, QREncode: cfg.QREncode}[tool]
+	if path == "" {
+		path = defaults(tool)
+	}
+	return Resolve(path)
+}
+
+// Resolve turns a program's path into one exec can run on this OS. A bare
+// name is looked up on PATH, then in the OS's extra directories; a path with
+// directories, relative to the working directory or absolute, gets native
+// separators and, on Windows, an .exe suffix if that's what exists. A program
+// that can't be found is returned as given, so running it fails with
+// exec.ErrNotFound or fs.ErrNotExist.
+func Resolve(path string) string {
+	if path == "" {
+		return path
+	}
+	if !containsSeparator(path) {
+		if found, err := exec.LookPath(path); err == nil {
+			return found
+		}
+		for _, dir := range extraDirs[goos] {
+			if found := withExe(filepath.Join(dir, path)); isFile(found) {
+				return found
+			}
+		}
+		return path
+	}
+
+	path = filepath.FromSlash(path)
+	if !filepath.IsAbs(path) && !hasDotPrefix(path) {
+		// exec only runs relative paths that are explicit about it
+		path = "." + string(filepath.Separator) + path
+	}
+	if found := withExe(path); isFile(found) {
+		return found
+	}
+	return path
+}
+
+// withExe adds Windows' .exe suffix to a path without an extension.
+func withExe(path string) string {
+	if goos == "windows" && filepath.Ext(path) == "" {
+		return path + ".exe"
+	}
+	return path
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+func containsSeparator(path string) bool {
+	for _, r := range path {
+		if r == '/' || r == '\\' {
+			return true
+		}
+	}
+	return false
+}
+
+func hasDotPrefix(path string) bool {
+	return path == "." || path == ".." ||
+		len(path) > 1 && path[0] == '.' && os.IsPathSeparator(path[1]) ||
+		len(path) > 2 && path[:2] == ".." && os.IsPathSeparator(path[2])
+}
@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"slugbot/internal/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeExecutable(t *testing.T, path string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755))
+}
+
+func TestResolve_BareNameFromPath(t *testing.T) {
+	dir := t.TempDir()
+	writeExecutable(t, filepath.Join(dir, "slugbot-tool"))
+	t.Setenv("PATH", dir)
+
+	require.Equal(t, filepath.Join(dir, "slugbot-tool"), Resolve("slugbot-tool"))
+	require.Equal(t, "slugbot-missing", Resolve("slugbot-missing"))
+}
+
+func TestResolve_ExtraDirs(t *testing.T) {
+	dir := t.TempDir()
+	writeExecutable(t, filepath.Join(dir, "magick"))
+	t.Setenv("PATH", t.TempDir())
+	extraDirs[goos] = []string{dir}
+	t.Cleanup(func() { delete(extraDirs, goos) })
+
+	require.Equal(t, filepath.Join(dir, "magick"), Resolve("magick"))
+}
+
+func TestResolve_RelativePathsAreExplicit(t *testing.T) {
+	if filepath.Separator != '/' {
+		t.Skip("expects POSIX separators")
+	}
+	t.Chdir(t.TempDir())
+	require.Equal(t, "./stable-audio/sag", Resolve("stable-audio/sag"))
+	require.Equal(t, "./stable-audio/sag", Resolve("./stable-audio/sag"))
+	require.Equal(t, "/opt/sag", Resolve("/opt/sag"))
+}
+
+func TestResolve_WindowsExe(t *testing.T) {
+	if filepath.Separator != '/' {
+		t.Skip("simulates Windows on a POSIX host")
+	}
+	host := goos
+	goos = "windows"
+	t.Cleanup(func() { goos = host })
+	t.Chdir(t.TempDir())
+	writeExecutable(t, filepath.Join(".conda", "general-dsp", "python.exe"))
+
+	require.Equal(t, "./.conda/general-dsp/python.exe", Resolve(defaults(Python)))
+	require.Equal(t, "./stable-audio/sag", Resolve("stable-audio/sag"))
+}
+
+func TestPath_ConfigOverridesDefault(t *testing.T) {
+	cfg := config.Default()
+	cfg.Tools.Magick = "/opt/im/bin/magick"
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	require.Equal(t, "/opt/im/bin/magick", Path(Magick))
+	require.Equal(t, "./stable-audio/sag", Path(Sag))
+}
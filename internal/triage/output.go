@@ -0,0 +1,54 @@
+package triage
+
+import (
+	"sync"
+)
+
+// OutputError is a subprocess failure along with the end of what the
+// process wrote, so Classify can see e.g. a CUDA out-of-memory traceback. Its
+// text is the failure's alone; the output is already in the logs.
+type OutputError struct {
+	Err    error
+	Output string
+}
+
+func (e *OutputError) Error() string { return e.Err.Error() }
+func (e *OutputError) Unwrap() error { return e.Err }
+
+// Tail keeps the last Size bytes written to it, e.g. as a subprocess's
+// stderr alongside os.Stderr.
+type Tail struct {
+	Size int
+
+	mutex sync.Mutex
+	data  []byte
+}
+
+// NewTail returns a Tail that keeps the last size bytes.
+func NewTail(size int) *Tail {
+	return &Tail{Size: size}
+}
+
+func (t *Tail) Write(p []byte) (int, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.data = append(t.data, p...)
+	if extra := len(t.data) - t.Size; extra > 0 {
+		t.data = append(t.data[:0], t.data[extra:]...)
+	}
+	return len(p), nil
+}
+
+func (t *Tail) String() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return string(t.data)
+}
+
+// Wrap attaches what's been written to err, if there's an err.
+func (t *Tail) Wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &OutputError{Err: err, Output: t.String()}
+}
@@ -0,0 +1,116 @@
+// Package triage recognizes the common ways a job fails, so users get a
+// plain explanation and a suggested fix instead of a chain of wrapped errors.
+// The raw error still goes to the logs.
+package triage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	osexec "os/exec"
+	"strings"
+
+	"slugbot/internal/exec"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Kind is a common way for a job to fail.
+type Kind int
+
+const (
+	Unknown       Kind = iota
+	MissingBinary      // a tool the bot runs isn't installed
+	OutOfMemory        // the GPU, or the machine, ran out of memory
+	BadInput           // an input file couldn't be decoded
+	TooLarge           // a result was too big to upload to Discord
+	Timeout            // the job ran too long or stopped making progress
+)
+
+func (k Kind) String() string {
+	switch k {
+	case MissingBinary:
+		return "missing binary"
+	case OutOfMemory:
+		return "out of memory"
+	case BadInput:
+		return "bad input"
+	case TooLarge:
+		return "upload too large"
+	case Timeout:
+		return "timeout"
+	}
+	return "unknown"
+}
+
+// discordEntityTooLarge is Discord's JSON error code for an upload over the size limit.
+const discordEntityTooLarge = 40005
+
+// markers are text that gives a failure away in an error or a tool's output,
+// checked in order; they're lowercase.
+var markers = []struct {
+	kind  Kind
+	texts []string
+}{
+	{MissingBinary, []string{"executable file not found", "command not found"}},
+	{OutOfMemory, []string{"cuda out of memory", "outofmemoryerror", "cublas_status_alloc_failed", "cuda error: out of memory"}},
+	{TooLarge, []string{"request entity too large"}},
+	{BadInput, []string{
+		"no decode delegate", "improper image header", "insufficient image data", "not a jpeg file", "corrupt image",
+		"invalid data found when processing input", "format not recognised", "could not find codec parameters",
+	}},
+	{Timeout, []string{"timed out", "deadline exceeded"}},
+}
+
+// Classify recognizes how err came about, or returns Unknown.
+func Classify(err error) Kind {
+	if err == nil {
+		return Unknown
+	}
+	var pathErr *fs.PathError
+	if errors.Is(err, osexec.ErrNotFound) || (errors.As(err, &pathErr) && pathErr.Op == "fork/exec" && errors.Is(err, fs.ErrNotExist)) {
+		return MissingBinary
+	}
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) &&
+		((restErr.Response != nil && restErr.Response.StatusCode == http.StatusRequestEntityTooLarge) ||
+			(restErr.Message != nil && restErr.Message.Code == discordEntityTooLarge)) {
+		return TooLarge
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, exec.ErrStalled) {
+		return Timeout
+	}
+
+	text := strings.ToLower(err.Error())
+	var outputErr *OutputError
+	if errors.As(err, &outputErr) {
+		text += "\n" + strings.ToLower(outputErr.Output)
+	}
+	for _, marker := range markers {
+		for _, t := range marker.texts {
+			if strings.Contains(text, t) {
+				return marker.kind
+			}
+		}
+	}
+	return Unknown
+}
+
+// explanations say what happened and how to get past it, by Kind.
+var explanations = map[Kind]string{
+	MissingBinary: "A tool this command needs isn't installed on the bot's server, so it can't run right now. An admin needs to install it; the details are in the logs.",
+	OutOfMemory:   "The GPU ran out of memory. Try a shorter `--length`, fewer `--steps`, or `--small`, or try again once the queue is quieter.",
+	BadInput:      "I couldn't read the input file. Check that it's a supported audio or image file (e.g. wav, mp3, flac, png, jpg) and that it isn't corrupted.",
+	TooLarge:      "The result is too big to upload to Discord. Try a shorter `--length`, or fewer results at once.",
+	Timeout:       "The job took too long and was stopped. Try a shorter `--length` or fewer `--steps`.",
+}
+
+// Explain returns what to tell a user about err: a plain explanation and a
+// suggested fix if the failure is recognized, or err's own text if it isn't.
+func Explain(err error) string {
+	if explanation, ok := explanations[Classify(err)]; ok {
+		return explanation
+	}
+	return err.Error()
+}
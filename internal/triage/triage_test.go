@@ -0,0 +1,59 @@
+package triage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	osexec "os/exec"
+	"testing"
+
+	"slugbot/internal/exec"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	missing := osexec.Command("slugbot-no-such-tool").Run()
+	tooLarge := &discordgo.RESTError{Response: &http.Response{StatusCode: http.StatusRequestEntityTooLarge}}
+	oom := NewTail(64)
+	fmt.Fprint(oom, "Traceback...\ntorch.OutOfMemoryError: CUDA out of memory. Tried to allocate 2.00 GiB")
+
+	for _, tc := range []struct {
+		err  error
+		want Kind
+	}{
+		{fmt.Errorf("error during audio generation: %w", missing), MissingBinary},
+		{fmt.Errorf("error during audio generation: %w", oom.Wrap(errors.New("exit status 1"))), OutOfMemory},
+		{errors.New("failed to run command on image: exit status 1\nOutput: magick: no decode delegate for this image format `XYZ'"), BadInput},
+		{fmt.Errorf("failed to send file: %w", tooLarge), TooLarge},
+		{fmt.Errorf("%w for 5m0s (signal: killed)", exec.ErrStalled), Timeout},
+		{context.DeadlineExceeded, Timeout},
+		{errors.New("invalid seed"), Unknown},
+		{nil, Unknown},
+	} {
+		require.Equal(t, tc.want, Classify(tc.err), "%v", tc.err)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	require.Equal(t, "invalid seed", Explain(errors.New("invalid seed")))
+	explained := Explain(fmt.Errorf("error during audio generation: %w", context.DeadlineExceeded))
+	require.Contains(t, explained, "took too long")
+	require.NotContains(t, explained, "error during")
+}
+
+func TestTail_KeepsTheEnd(t *testing.T) {
+	tail := NewTail(5)
+	fmt.Fprint(tail, "abc")
+	fmt.Fprint(tail, "defgh")
+	require.Equal(t, "defgh", tail.String())
+
+	require.NoError(t, tail.Wrap(nil))
+	err := tail.Wrap(errors.New("exit status 1"))
+	require.Equal(t, "exit status 1", err.Error())
+	var outputErr *OutputError
+	require.ErrorAs(t, err, &outputErr)
+	require.Equal(t, "defgh", outputErr.Output)
+}
@@ -0,0 +1,32 @@
+package utils
+
+import "time"
+
+// Backoff paces a polling loop that slows down while there's nothing to
+// hurry for: each Next that isn't in a hurry doubles the interval, up to Max,
+// and one that is brings it back to Min. If Max isn't longer than Min, the
+// interval is always Min.
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+
+	interval time.Duration
+}
+
+// Interval returns how long to wait before the next poll.
+func (b *Backoff) Interval() time.Duration {
+	if b.interval < b.Min {
+		b.interval = b.Min
+	}
+	return b.interval
+}
+
+// Next moves the interval on after a poll, and returns it.
+func (b *Backoff) Next(hurry bool) time.Duration {
+	if hurry || b.interval < b.Min {
+		b.interval = b.Min
+	} else {
+		b.interval = min(2*b.interval, max(b.Max, b.Min))
+	}
+	return b.interval
+}
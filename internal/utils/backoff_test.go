@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff_StretchesUntilHurried(t *testing.T) {
+	b := &Backoff{Min: time.Second, Max: 5 * time.Second}
+	require.Equal(t, time.Second, b.Interval())
+
+	var intervals []time.Duration
+	for range 4 {
+		intervals = append(intervals, b.Next(false))
+	}
+	require.Equal(t, []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, intervals)
+
+	require.Equal(t, time.Second, b.Next(true))
+	require.Equal(t, time.Second, b.Interval())
+}
+
+func TestBackoff_WithoutMaxStaysAtMin(t *testing.T) {
+	b := &Backoff{Min: time.Second}
+	require.Equal(t, time.Second, b.Next(false))
+	require.Equal(t, time.Second, b.Next(false))
+}
@@ -3,8 +3,13 @@ package utils
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
+
+	"slugbot/internal/io/slog"
+
+	"github.com/fsnotify/fsnotify"
 )
 
 type Poller interface {
@@ -14,42 +19,175 @@ type Poller interface {
 // PollableFile watches a file at regular intervals and invokes OnUpdate with the trimmed content.
 type PollableFile struct {
 	File     string            // Path to the file being watched
-	Interval time.Duration     // Polling interval
+	Interval time.Duration     // Polling interval; the shortest one, if MaxInterval is set
 	OnUpdate func(text string) // Callback invoked on each update
+
+	// Watch picks up writes to the file as they happen, using inotify or the
+	// platform's equivalent, instead of reading it once per interval. Where
+	// watching isn't supported, or stops working, it falls back to polling.
+	Watch bool
+
+	// MaxInterval, if longer than Interval, lets polling slow down while
+	// there's nothing to hurry for: each read that Hurry doesn't flag doubles
+	// the wait before the next one, up to MaxInterval, and one it flags brings
+	// it back to Interval. While watching, writes are also read at most once
+	// per wait, so a file that's written every step isn't shown every step.
+	MaxInterval time.Duration
+
+	// Hurry, if set, tells whether reading text after prev should bring
+	// polling back to Interval. Without it, any change does.
+	Hurry func(prev, text string) bool
+
+	// StaleAfter, if positive, calls OnStale once the file's content has gone
+	// this many Intervals without changing, e.g. because the writer hung. It's
+	// called again only after the content changes and goes stale once more.
+	StaleAfter int
+	OnStale    func(idle time.Duration)
+
+	dir string // the file's own directory, if NewPollableFile made one
 }
 
 // NewPollableFile creates a PollableFile with a unique temporary file, polling interval, and update callback.
-// The temporary file is created in the OS temp directory with a unique name.
+// The file is alone in a directory of its own under TempDir, so watching it
+// isn't woken by writes to any other file; Remove deletes both.
 func NewPollableFile(interval time.Duration, onUpdate func(string)) (*PollableFile, error) {
 	if onUpdate == nil {
 		return nil, fmt.Errorf("received nil onUpdate callback")
 	}
-	tmpFile, err := os.CreateTemp("", "pollable-*.progress")
+	dir, err := os.MkdirTemp(TempDir(), "pollable-*")
 	if err != nil {
 		return nil, err
 	}
-	tmpFile.Close()
-	return &PollableFile{File: tmpFile.Name(), Interval: interval, OnUpdate: onUpdate}, nil
+	file := filepath.Join(dir, "progress")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &PollableFile{File: file, Interval: interval, OnUpdate: onUpdate, dir: dir}, nil
+}
+
+// Remove deletes the polled file, and the directory NewPollableFile made for it.
+func (pf *PollableFile) Remove() error {
+	if pf.dir != "" {
+		return os.RemoveAll(pf.dir)
+	}
+	return os.Remove(pf.File)
 }
 
-// Start polls the file until done is closed, calling OnUpdate on each non-empty read.
+// Start polls the file until done is closed, calling
+// OnUpdate on each non-empty read.
 func (pf *PollableFile) Start(done <-chan struct{}) {
-	ticker := time.NewTicker(pf.Interval)
-	defer ticker.Stop()
+	pace := &Backoff{Min: pf.Interval, Max: pf.MaxInterval}
+	adaptive := pf.MaxInterval > pf.Interval
+	poll := time.NewTimer(pace.Interval())
+	defer poll.Stop()
+	nextPoll := time.Now().Add(pace.Interval())
+	schedule := func(wait time.Duration) {
+		poll.Reset(wait)
+		nextPoll = time.Now().Add(wait)
+	}
+
+	var events <-chan fsnotify.Event
+	var watchErrors <-chan error
+	if pf.Watch {
+		watcher, err := pf.watch()
+		if err != nil {
+			slog.Warn("couldn't watch ", pf.File, "; polling it instead: ", err)
+		} else {
+			defer watcher.Close()
+			events, watchErrors = watcher.Events, watcher.Errors
+		}
+	}
+
+	last := ""
+	lastChange := time.Now()
+	var lastRead time.Time
+	pending := false // a write was seen while watching, but not read yet
+	stale := false
+	read := func() {
+		lastRead, pending = time.Now(), false
+		text := ""
+		if data, err := os.ReadFile(pf.File); err == nil {
+			text = strings.TrimSpace(string(data))
+		}
+		prev := last
+		if text != last {
+			last, lastChange, stale = text, lastRead, false
+		}
+		pace.Next(pf.hurry(prev, text))
+		if text == prev && (events != nil || adaptive) {
+			// a single write can raise several events, and adaptive
+			// polling doesn't show the same text twice
+			return
+		}
+		if text != "" && pf.OnUpdate != nil {
+			pf.OnUpdate(text)
+		}
+	}
 
 	for {
 		select {
 		case <-done:
 			return
-		case <-ticker.C:
-			data, err := os.ReadFile(pf.File)
-			if err != nil {
+		case event, ok := <-events:
+			if !ok {
+				events, watchErrors = nil, nil
+				continue
+			}
+			if event.Name != pf.File || !event.Has(fsnotify.Write|fsnotify.Create) {
 				continue
 			}
-			text := strings.TrimSpace(string(data))
-			if text != "" && pf.OnUpdate != nil {
-				pf.OnUpdate(text)
+			wait := pace.Interval() - time.Since(lastRead)
+			if !adaptive || wait <= 0 {
+				read()
+				continue
+			}
+			if !pending {
+				pending = true
+				if time.Until(nextPoll) > wait {
+					schedule(wait)
+				}
+			}
+		case err := <-watchErrors:
+			slog.Warn("stopped watching ", pf.File, "; polling it instead: ", err)
+			events, watchErrors = nil, nil
+		case <-poll.C:
+			if events == nil || pending {
+				read()
 			}
+			// the interval still paces staleness checks while watching
+			if idle := time.Since(lastChange); pf.StaleAfter > 0 && !stale && idle >= time.Duration(pf.StaleAfter)*pf.Interval {
+				stale = true
+				if pf.OnStale != nil {
+					pf.OnStale(idle)
+				}
+			}
+			schedule(pace.Interval())
 		}
 	}
 }
+
+// hurry tells whether reading text after prev should bring polling back to
+// Interval.
+func (pf *PollableFile) hurry(prev, text string) bool {
+	if pf.Hurry != nil {
+		return pf.Hurry(prev, text)
+	}
+	return text != prev
+}
+
+// watch starts watching the file's directory, so the file is still seen if
+// a writer replaces it instead of writing to it in place. That should be a
+// directory of its own, as NewPollableFile makes, or every write to another
+// file in it wakes the watcher too.
+func (pf *PollableFile) watch() (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(pf.File)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	return watcher, nil
+}
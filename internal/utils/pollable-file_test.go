@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollableFile_OnStaleOncePerStretch(t *testing.T) {
+	interval := 5 * time.Millisecond
+	pf, err := NewPollableFile(interval, func(string) {})
+	require.NoError(t, err)
+	defer pf.Remove()
+
+	var stalls atomic.Int32
+	pf.StaleAfter = 3
+	pf.OnStale = func(idle time.Duration) {
+		require.GreaterOrEqual(t, idle, 3*interval)
+		stalls.Add(1)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go pf.Start(done)
+
+	require.Eventually(t, func() bool { return stalls.Load() == 1 }, time.Second, interval)
+	time.Sleep(5 * interval)
+	require.Equal(t, int32(1), stalls.Load())
+
+	// a change resets it, so it can go stale again
+	require.NoError(t, os.WriteFile(pf.File, []byte("10%"), 0644))
+	require.Eventually(t, func() bool { return stalls.Load() == 2 }, time.Second, interval)
+}
+
+func TestNewPollableFile_KeepsEachFileInItsOwnDirectory(t *testing.T) {
+	pf, err := NewPollableFile(time.Second, func(string) {})
+	require.NoError(t, err)
+
+	dir := filepath.Dir(pf.File)
+	require.Equal(t, TempDir(), filepath.Dir(dir))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	require.NoError(t, pf.Remove())
+	require.NoDirExists(t, dir)
+}
+
+func TestPollableFile_WatchSeesWritesBeforeTheInterval(t *testing.T) {
+	updates := make(chan string, 10)
+	pf, err := NewPollableFile(time.Hour, func(text string) { updates <- text })
+	require.NoError(t, err)
+	defer pf.Remove()
+	pf.Watch = true
+
+	done := make(chan struct{})
+	defer close(done)
+	go pf.Start(done)
+
+	// the watcher may not be set up yet, so keep writing until it sees one
+	require.Eventually(t, func() bool {
+		require.NoError(t, os.WriteFile(pf.File, []byte("42%"), 0644))
+		select {
+		case text := <-updates:
+			return text == "42%"
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPollableFile_AdaptiveWatchSpacesOutUpdates(t *testing.T) {
+	var updates atomic.Int32
+	var latest atomic.Value
+	pf, err := NewPollableFile(10*time.Millisecond, func(text string) {
+		updates.Add(1)
+		latest.Store(text)
+	})
+	require.NoError(t, err)
+	defer pf.Remove()
+	pf.Watch = true
+	pf.MaxInterval = 40 * time.Millisecond
+	pf.Hurry = func(prev, text string) bool { return false }
+
+	done := make(chan struct{})
+	defer close(done)
+	go pf.Start(done)
+
+	// a writer that never lets up is shown now and then, and its last word
+	// still gets through
+	for i := range 100 {
+		require.NoError(t, os.WriteFile(pf.File, []byte(strconv.Itoa(i)), 0644))
+		time.Sleep(time.Millisecond)
+	}
+	require.Eventually(t, func() bool { return latest.Load() == "99" }, time.Second, 5*time.Millisecond)
+	require.Less(t, updates.Load(), int32(25))
+}
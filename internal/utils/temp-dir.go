@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// TempDir returns the directory the bot keeps its temp files in, creating it
+// if it doesn't exist yet. It's a directory of the bot's own under the OS temp
+// dir, so that sweeping or watching it never touches another program's files.
+// Should it fail to be created, creating temp files in it will fail too.
+func TempDir() string {
+	dir := filepath.Join(os.TempDir(), "slugbot-tmp")
+	os.MkdirAll(dir, 0o700)
+	return dir
+}
@@ -0,0 +1,192 @@
+// Package webhook accepts signed HTTP requests from external tools that
+// submit generation jobs.
+//
+// A request is signed with HMAC-SHA256 over "<timestamp>.<body>" using the
+// shared webhook secret. The timestamp (Unix seconds) goes in the
+// X-Slugbot-Timestamp header and the signature, hex-encoded, in
+// X-Slugbot-Signature as "sha256=<hex>". A signed request is accepted once:
+// its timestamp has to be within the handler's MaxSkew of now, and the same
+// signature is refused while that could still hold.
+//
+// Instead of signing, a request may carry a personal API token as
+// "Authorization: Bearer <token>", in which case its job runs as the token's
+// owner.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"slugbot/internal/apitoken"
+	"slugbot/internal/io/slog"
+)
+
+// Headers a signed request carries.
+const (
+	TimestampHeader = "X-Slugbot-Timestamp"
+	SignatureHeader = "X-Slugbot-Signature"
+)
+
+const maxBodyBytes = 64 << 10
+
+// ErrBadSignature is returned for requests whose signature or timestamp doesn't check out.
+var ErrBadSignature = errors.New("bad signature")
+
+// ErrReplayed is returned for signed requests that were already accepted.
+var ErrReplayed = errors.New("request was already accepted")
+
+// Sign returns the signature header value for a body sent at timestamp.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a request's signature, and that its timestamp is within
+// maxSkew of now, so a captured request goes stale. It can still be replayed
+// until then; Handler refuses signatures it has already seen.
+func Verify(secret string, timestamp string, signature string, body []byte, now time.Time, maxSkew time.Duration) error {
+	if secret == "" {
+		return fmt.Errorf("%w: no webhook secret is configured", ErrBadSignature)
+	}
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp", ErrBadSignature)
+	}
+	if skew := math.Abs(float64(now.Unix() - sent)); skew > maxSkew.Seconds() {
+		return fmt.Errorf("%w: timestamp is %.0fs off", ErrBadSignature, skew)
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// Request is the JSON body of a job submission.
+type Request struct {
+	ChannelID string `json:"channel_id"` // where progress and results are posted
+	Command   string `json:"command"`    // e.g. ".saudio rain on a tin roof --length 20"
+	Source    string `json:"source"`     // optional name of the submitting tool, shown in the channel
+}
+
+// Response is returned for an accepted submission.
+type Response struct {
+	MessageID string   `json:"message_id"` // the bot's message the job's output replies to
+	JobIDs    []string `json:"job_ids"`    // look these up with `.sjob`
+}
+
+// Handler serves job submissions.
+type Handler struct {
+	Secret       func() string // returns the shared secret; looked up per request so it can be rotated
+	MaxSkew      time.Duration
+	Channels     []string                                                   // channels requests may post to
+	Commands     []string                                                   // top-level commands requests may run
+	Authenticate func(token string) (apitoken.Token, error)                 // optional; accepts personal tokens in place of a signature
+	Submit       func(req Request, owner *apitoken.Token) (Response, error) // owner is nil for signed requests
+
+	mutex sync.Mutex
+	seen  map[string]time.Time // signatures accepted, until they'd be too old to pass Verify
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+	if err != nil || len(body) > maxBodyBytes {
+		http.Error(w, "body too large or unreadable", http.StatusRequestEntityTooLarge)
+		return
+	}
+	owner, err := h.authenticate(r, body)
+	if err != nil {
+		slog.Warn("rejected webhook from ", r.RemoteAddr, ": ", err)
+		http.Error(w, "invalid signature or token", http.StatusUnauthorized)
+		return
+	}
+
+	var req Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.validate(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.Submit(req, owner)
+	if err != nil {
+		slog.Error("couldn't submit webhook job: ", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// authenticate checks a request's personal token if it has one, and its
+// signature otherwise. It returns the token's owner, or nil for signed requests.
+func (h *Handler) authenticate(r *http.Request, body []byte) (*apitoken.Token, error) {
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && h.Authenticate != nil {
+		owner, err := h.Authenticate(bearer)
+		if err != nil {
+			return nil, err
+		}
+		return &owner, nil
+	}
+	signature := r.Header.Get(SignatureHeader)
+	now := time.Now()
+	if err := Verify(h.Secret(), r.Header.Get(TimestampHeader), signature, body, now, h.MaxSkew); err != nil {
+		return nil, err
+	}
+	return nil, h.firstUse(signature, now)
+}
+
+// firstUse remembers a verified signature, or returns ErrReplayed if it was
+// seen before. A signature is forgotten once its timestamp, which can be up to
+// MaxSkew ahead of when it was first seen, is more than MaxSkew old.
+func (h *Handler) firstUse(signature string, now time.Time) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for seen, expires := range h.seen {
+		if now.After(expires) {
+			delete(h.seen, seen)
+		}
+	}
+	if _, ok := h.seen[signature]; ok {
+		return ErrReplayed
+	}
+	if h.seen == nil {
+		h.seen = map[string]time.Time{}
+	}
+	h.seen[signature] = now.Add(2 * h.MaxSkew)
+	return nil
+}
+
+func (h *Handler) validate(req Request) error {
+	if !slices.Contains(h.Channels, req.ChannelID) {
+		return fmt.Errorf("channel %q isn't open to webhooks", req.ChannelID)
+	}
+	fields := strings.Fields(req.Command)
+	if len(fields) < 2 || !slices.Contains(h.Commands, fields[0]) {
+		return fmt.Errorf("command must be one of %s followed by its arguments", strings.Join(h.Commands, ", "))
+	}
+	return nil
+}
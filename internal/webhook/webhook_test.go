@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"slugbot/internal/apitoken"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	body := []byte(`{"command":".saudio rain"}`)
+	sig := Sign("s3cret", ts, body)
+
+	require.NoError(t, Verify("s3cret", ts, sig, body, now, time.Minute))
+	require.ErrorIs(t, Verify("other", ts, sig, body, now, time.Minute), ErrBadSignature)
+	require.ErrorIs(t, Verify("s3cret", ts, sig, []byte(`{"command":".saudio hail"}`), now, time.Minute), ErrBadSignature)
+	require.ErrorIs(t, Verify("s3cret", ts, sig, body, now.Add(2*time.Minute), time.Minute), ErrBadSignature)
+	require.ErrorIs(t, Verify("", ts, Sign("", ts, body), body, now, time.Minute), ErrBadSignature)
+}
+
+func TestHandler_SubmitsSignedRequests(t *testing.T) {
+	var submitted []Request
+	h := &Handler{
+		Secret:   func() string { return "s3cret" },
+		MaxSkew:  time.Minute,
+		Channels: []string{"c1"},
+		Commands: []string{".saudio"},
+		Submit: func(req Request, owner *apitoken.Token) (Response, error) {
+			require.Nil(t, owner)
+			submitted = append(submitted, req)
+			return Response{MessageID: "m1", JobIDs: []string{"ABCD"}}, nil
+		},
+	}
+
+	post := func(body string, secret string) *httptest.ResponseRecorder {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		r := httptest.NewRequest(http.MethodPost, "/webhooks/jobs", strings.NewReader(body))
+		r.Header.Set(TimestampHeader, ts)
+		r.Header.Set(SignatureHeader, Sign(secret, ts, []byte(body)))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := post(`{"channel_id":"c1","command":".saudio rain --length 20","source":"ci"}`, "s3cret")
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.JSONEq(t, `{"message_id":"m1","job_ids":["ABCD"]}`, w.Body.String())
+	require.Equal(t, []Request{{ChannelID: "c1", Command: ".saudio rain --length 20", Source: "ci"}}, submitted)
+
+	require.Equal(t, http.StatusUnauthorized, post(`{"channel_id":"c1","command":".saudio rain"}`, "wrong").Code)
+	require.Equal(t, http.StatusBadRequest, post(`{"channel_id":"c2","command":".saudio rain"}`, "s3cret").Code)
+	require.Equal(t, http.StatusBadRequest, post(`{"channel_id":"c1","command":".sadmin stats"}`, "s3cret").Code)
+	require.Len(t, submitted, 1)
+}
+
+func TestHandler_RefusesReplayedRequests(t *testing.T) {
+	submitted := 0
+	h := &Handler{
+		Secret:   func() string { return "s3cret" },
+		MaxSkew:  time.Minute,
+		Channels: []string{"c1"},
+		Commands: []string{".saudio"},
+		Submit: func(req Request, owner *apitoken.Token) (Response, error) {
+			submitted++
+			return Response{}, nil
+		},
+	}
+
+	body := `{"channel_id":"c1","command":".saudio rain"}`
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := Sign("s3cret", ts, []byte(body))
+	post := func() int {
+		r := httptest.NewRequest(http.MethodPost, "/webhooks/jobs", strings.NewReader(body))
+		r.Header.Set(TimestampHeader, ts)
+		r.Header.Set(SignatureHeader, signature)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusAccepted, post())
+	require.Equal(t, http.StatusUnauthorized, post())
+	require.Equal(t, 1, submitted)
+
+	// signatures are forgotten once they'd be refused as stale anyway
+	require.NoError(t, h.firstUse("old", time.Now().Add(-3*time.Minute)))
+	require.NoError(t, h.firstUse("new", time.Now()))
+	require.NotContains(t, h.seen, "old")
+	require.ErrorIs(t, h.firstUse("new", time.Now()), ErrReplayed)
+}
+
+func TestHandler_AcceptsPersonalTokens(t *testing.T) {
+	var owners []*apitoken.Token
+	h := &Handler{
+		Secret:   func() string { return "s3cret" },
+		MaxSkew:  time.Minute,
+		Channels: []string{"c1"},
+		Commands: []string{".saudio"},
+		Authenticate: func(token string) (apitoken.Token, error) {
+			if token != "slug_id_secret" {
+				return apitoken.Token{}, apitoken.ErrInvalid
+			}
+			return apitoken.Token{ID: "id", UserID: "u1", Username: "slug"}, nil
+		},
+		Submit: func(req Request, owner *apitoken.Token) (Response, error) {
+			owners = append(owners, owner)
+			return Response{}, nil
+		},
+	}
+
+	post := func(token string) int {
+		r := httptest.NewRequest(http.MethodPost, "/webhooks/jobs", strings.NewReader(`{"channel_id":"c1","command":".saudio rain"}`))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusAccepted, post("slug_id_secret"))
+	require.Equal(t, http.StatusUnauthorized, post("slug_id_wrong"))
+	require.Len(t, owners, 1)
+	require.Equal(t, "u1", owners[0].UserID)
+}
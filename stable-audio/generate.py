@@ -57,6 +57,7 @@ default_cfg = {
     "init_audio": None,
     "seed": -1,
     "small": False,
+    "model_dir": None,
 }
 
 
@@ -188,8 +189,9 @@ def shared_model_invocation(args, inv_type) -> None:
         args["sampler"] = "pingpong"
         args["cfg_scale"] = args.get("cfg_scale", 6.0)
     else:
-        config_path = (project_dir / STABLE_AUDIO_OPEN_1_0_PATH) / "model_config.json"
-        ckpt_path = (project_dir / STABLE_AUDIO_OPEN_1_0_PATH) / "model.ckpt"
+        model_dir = args["model_dir"] or STABLE_AUDIO_OPEN_1_0_PATH
+        config_path = (project_dir / model_dir) / "model_config.json"
+        ckpt_path = (project_dir / model_dir) / "model.ckpt"
 
     # If a progress file was indicated, create it to track progress, then delete it on cleanup
     if args["progress_file"] is not None:
@@ -454,6 +456,9 @@ def simple_prompt() -> None:
     parser.add_argument(
         "--small", action="store_true", help="If set, uses the small version of Stable Audio Open"
     )
+    parser.add_argument(
+        "--model_dir", help="Checkpoint directory to use instead of Stable Audio Open 1.0 (ignored with --small)"
+    )
     args = parser.parse_args().__dict__
     args = {
         **default_cfg,
@@ -474,6 +479,7 @@ def toml_prompt() -> None:
     parser.add_argument("--output", type=str, default="", help="Output WAV file path")
     parser.add_argument("--progress_file", type=str, default="", help="File to write progress output to")
     parser.add_argument("--init_audio", type=str, default="", help="Path to a WAV file to condition on (audio2audio)")
+    parser.add_argument("--model_dir", type=str, default="", help="Checkpoint directory to use instead of Stable Audio Open 1.0")
     parser.add_argument("--toml", action="store_true", help="Read TOML from stdin")
     args_in = parser.parse_args().__dict__
     try:
@@ -498,6 +504,8 @@ def toml_prompt() -> None:
             args["progress_file"] = args_in.get("progress_file")
         if args_in.get("init_audio"):
             args["init_audio"] = args_in.get("init_audio")
+        if args_in.get("model_dir"):
+            args["model_dir"] = args_in.get("model_dir")
 
         neg_prompts = toml.get("neg_prompts", None)
         if neg_prompts is not None: